@@ -0,0 +1,149 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Change is a single difference between two configs. See ast.Change.
+type Change = ast.Change
+
+// ApplyOptions controls Tree.Apply.
+type ApplyOptions struct {
+	// Prune removes the sections, which are not part of the desired
+	// config. By default, they are left alone.
+	Prune bool
+
+	// KeepOptions keeps the options of existing sections, which are
+	// not part of the desired section. By default, they are removed,
+	// so that the sections match the desired ones exactly.
+	KeepOptions bool
+
+	// DryRun only computes the changes, without making them. It works
+	// on read-only trees, too.
+	DryRun bool
+}
+
+func (t *tree) Apply(desired *Config, opts ApplyOptions) ([]Change, error) {
+	if t.readOnly && !opts.DryRun {
+		return nil, ErrReadOnly
+	}
+	config := desired.Name
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.allowed(config); err != nil {
+		if t.mode == IgnoreUnlisted {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cfg, err := t.ensureConfig(context.Background(), config)
+	isNew := errors.Is(err, ErrConfigNotFound)
+	if isNew {
+		cfg = ast.NewConfig(config)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// reconcile a copy first, so that nothing is changed, if a value
+	// is rejected
+	changes := ast.Diff(cfg, reconcile(cfg.Clone(), desired, opts))
+	var errs []error
+	for _, c := range changes {
+		if c.Op != ast.OpSetOption {
+			continue
+		}
+		for _, value := range c.New {
+			if err := t.checkValue(config, c.Type, c.Option, value); err != nil {
+				path := Path{Config: config, Section: c.Section, Option: c.Option}
+				errs = append(errs, &ValidationError{Path: path, Value: value, Err: err})
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("apply %s: %w", config, err)
+	}
+	if opts.DryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	snap := t.snapshot(config)
+	reconcile(cfg, desired, opts)
+	for _, c := range changes {
+		switch c.Op {
+		case ast.OpAddSection:
+			t.markEdited(config, cfg.Get(c.Section), nil)
+		case ast.OpSetOption:
+			if sec := cfg.Get(c.Section); sec != nil {
+				t.markEdited(config, nil, sec.Get(c.Option))
+			}
+		}
+	}
+	if isNew {
+		t.configs[config] = cfg
+	}
+	cfg.SetTainted()
+	t.record(snap)
+	return changes, nil
+}
+
+// reconcile changes cfg to match desired, and returns it. Sections are
+// matched like in ast.Diff: by name, or by their "@type[index]"
+// selector, if unnamed. Existing options and sections keep their
+// position.
+func reconcile(cfg, desired *Config, opts ApplyOptions) *Config {
+	matched := make(map[*Section]bool, len(desired.Sections))
+	for _, want := range desired.Sections {
+		sec := cfg.Get(desired.SectionName(want))
+		switch {
+		case sec == nil:
+			sec = cfg.Add(ast.NewSection(want.Type, want.Name))
+		case sec.Type != want.Type:
+			// a named section of another type is replaced
+			sec.Type = want.Type
+			sec.Options = nil
+			cfg.Reindex()
+		}
+		matched[sec] = true
+
+		for _, opt := range want.Options {
+			if len(opt.Values) == 0 {
+				continue // can't be written
+			}
+			have := sec.Get(opt.Name)
+			if have != nil && have.Type == opt.Type && equalValues(have.Values, opt.Values) {
+				continue
+			}
+			if opt.Type == TypeList {
+				sec.SetList(opt.Name, opt.Values...)
+			} else {
+				sec.SetOption(opt.Name, opt.Values[len(opt.Values)-1])
+			}
+		}
+		if !opts.KeepOptions {
+			options := sec.Options[:0]
+			for _, opt := range sec.Options {
+				if w := want.Get(opt.Name); w != nil && len(w.Values) > 0 {
+					options = append(options, opt)
+				}
+			}
+			sec.Options = options
+		}
+	}
+
+	if opts.Prune {
+		sections := cfg.Sections[:0]
+		for _, sec := range cfg.Sections {
+			if matched[sec] {
+				sections = append(sections, sec)
+			}
+		}
+		cfg.Sections = sections
+	}
+	return cfg
+}
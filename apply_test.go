@@ -0,0 +1,208 @@
+package uci
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+	"github.com/wsiner/go-uci/validate"
+)
+
+const tcApplyCurrent = `
+config defaults
+	option input 'ACCEPT'
+	option output 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+
+config rule
+	option name 'Allow-SSH'
+	option dest_port '22'
+
+config rule 'custom'
+	option name 'Custom'
+`
+
+const tcApplyDesired = `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	list network 'lan6'
+
+config rule
+	option name 'Allow-SSH'
+
+config zone 'guest'
+	option name 'guest'
+`
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	desired, err := ast.Parse("firewall", tcApplyDesired)
+	require.NoError(err)
+
+	for _, tc := range []struct {
+		name string
+		opts ApplyOptions
+		want string
+	}{{
+		name: "default",
+		want: `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	list network 'lan6'
+
+config rule
+	option name 'Allow-SSH'
+
+config rule 'custom'
+	option name 'Custom'
+
+config zone 'guest'
+	option name 'guest'
+
+`,
+	}, {
+		name: "prune",
+		opts: ApplyOptions{Prune: true, KeepOptions: true},
+		want: `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	list network 'lan6'
+
+config rule
+	option name 'Allow-SSH'
+	option dest_port '22'
+
+config zone 'guest'
+	option name 'guest'
+
+`,
+	}} {
+		store := NewMemoryStore(map[string]string{"firewall": tcApplyCurrent})
+		r := NewStoreTree(store)
+
+		changes, err := r.Apply(desired, tc.opts)
+		require.NoError(err, tc.name)
+		assert.NotEmpty(changes, tc.name)
+		require.NoError(r.Commit(), tc.name)
+		assert.Equal(tc.want, string(store.files["firewall"]), tc.name)
+
+		// idempotent
+		changes, err = r.Apply(desired, tc.opts)
+		require.NoError(err, tc.name)
+		assert.Empty(changes, tc.name)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"firewall": tcApplyCurrent})
+	r := NewStoreTree(store)
+	desired, err := ast.Parse("firewall", tcApplyDesired)
+	require.NoError(err)
+
+	changes, err := r.Apply(desired, ApplyOptions{Prune: true, DryRun: true})
+	require.NoError(err)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	assert.Equal([]string{
+		"@defaults[0].input='REJECT'",
+		"lan.network='lan' 'lan6'",
+		"-@rule[0].dest_port",
+		"+guest=zone",
+		"guest.name='guest'",
+		"-custom",
+	}, got)
+
+	// dry runs don't change anything
+	require.NoError(r.Commit())
+	assert.Equal(tcApplyCurrent, string(store.files["firewall"]))
+
+	// new configs are created
+	desired = ast.NewConfig("guest")
+	desired.Add(ast.NewSection("zone", "guest")).SetOption("input", "REJECT")
+	changes, err = r.Apply(desired, ApplyOptions{})
+	require.NoError(err)
+	assert.Len(changes, 2)
+	value, ok := r.GetLast("guest", "guest", "input")
+	assert.True(ok)
+	assert.Equal("REJECT", value)
+}
+
+func TestApplyTypeChangeIndexed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// enough sections to be indexed, see ast.IndexThreshold
+	current, desired := ast.NewConfig("dhcp"), ast.NewConfig("dhcp")
+	current.Add(ast.NewSection("domain", "router"))
+	desired.Add(ast.NewSection("host", "router"))
+	for i := 0; i < ast.IndexThreshold+8; i++ {
+		current.Add(ast.NewSection("host", "")).SetOption("ip", fmt.Sprintf("10.0.0.%d", i))
+		desired.Add(ast.NewSection("host", "")).SetOption("ip", fmt.Sprintf("10.0.0.%d", i))
+	}
+	r := NewStoreTree(NewMemoryStore(nil))
+	_, err := r.Apply(current, ApplyOptions{})
+	require.NoError(err)
+
+	_, err = r.Apply(desired, ApplyOptions{})
+	require.NoError(err)
+	cfg, ok := r.CopyConfig("dhcp")
+	require.True(ok)
+	assert.Len(cfg.Sections, len(desired.Sections))
+	assert.Equal("host", cfg.Get("router").Type)
+	assert.Equal("10.0.0.0", cfg.Get("@host[1]").LastValue("ip"))
+}
+
+func TestApplyErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"firewall": tcApplyCurrent})
+	r := NewStoreTree(store)
+	port, err := validate.Validator("port")
+	require.NoError(err)
+	r.RegisterValidator("dest_port", port)
+
+	desired, err := ast.Parse("firewall", "config rule\n\toption dest_port '70000'\n\nconfig zone 'new'\n")
+	require.NoError(err)
+	_, err = r.Apply(desired, ApplyOptions{Prune: true})
+	var verr *ValidationError
+	require.True(errors.As(err, &verr))
+	assert.Equal("70000", verr.Value)
+
+	// nothing was changed
+	require.NoError(r.Commit())
+	assert.Equal(tcApplyCurrent, string(store.files["firewall"]))
+
+	_, err = NewRestrictedTree("testdata", RejectUnlisted, "network").Apply(desired, ApplyOptions{})
+	var notAllowed *ErrConfigNotAllowed
+	assert.True(errors.As(err, &notAllowed))
+}
@@ -0,0 +1,87 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoSection is returned by Builder.Config, if an option was added
+// before the first section.
+var ErrNoSection = errors.New("option outside of a section")
+
+// A Builder generates a config programmatically:
+//
+//	cfg, err := ast.NewBuilder("network").
+//		Section("interface", "lan").
+//		Option("proto", "static").
+//		List("dns", "1.1.1.1", "9.9.9.9").
+//		Section("route", "").
+//		Option("target", "10.0.0.0/8").
+//		Config()
+//
+// Options and lists are added to the most recent section. The first
+// invalid call is reported by Config; the calls following it are
+// ignored.
+type Builder struct {
+	cfg *Config
+	sec *Section
+	err error
+}
+
+// NewBuilder returns a builder for a config with the given name.
+func NewBuilder(name string) *Builder {
+	return &Builder{cfg: NewConfig(name)}
+}
+
+// Section appends a section of type typ. An empty name adds an unnamed
+// section. Names must be unique within the config.
+func (b *Builder) Section(typ, name string) *Builder {
+	switch {
+	case b.err != nil:
+	case !isIdent(typ):
+		b.err = fmt.Errorf("%w: %q", ErrInvalidType, typ)
+	case name != "" && !isName(name):
+		b.err = fmt.Errorf("%w: %q", ErrInvalidName, name)
+	case name != "" && b.cfg.getNamed(name) != nil:
+		b.err = ErrSectionExists{b.cfg.Name, name}
+	default:
+		b.sec = b.cfg.Add(NewSection(typ, name))
+	}
+	return b
+}
+
+// Option sets an option of the current section.
+func (b *Builder) Option(name, value string) *Builder {
+	return b.set(name, TypeOption, value)
+}
+
+// List sets a list of the current section.
+func (b *Builder) List(name string, values ...string) *Builder {
+	return b.set(name, TypeList, values...)
+}
+
+func (b *Builder) set(name string, typ OptionType, values ...string) *Builder {
+	switch {
+	case b.err != nil:
+	case b.sec == nil:
+		b.err = fmt.Errorf("%w: %s", ErrNoSection, name)
+	case !isIdent(name):
+		b.err = fmt.Errorf("%w: %q", ErrInvalidName, name)
+	default:
+		if opt := b.sec.Get(name); opt != nil {
+			opt.Type = typ
+			opt.SetValues(values...)
+		} else {
+			b.sec.Add(NewOption(name, typ, values...))
+		}
+	}
+	return b
+}
+
+// Config returns the generated config, or the first error.
+func (b *Builder) Config() (*Config, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.cfg, nil
+}
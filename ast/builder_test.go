@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := NewBuilder("network").
+		Section("interface", "lan").
+		Option("proto", "static").
+		List("dns", "1.1.1.1", "9.9.9.9").
+		Option("proto", "dhcp").
+		Section("route", "").
+		Option("target", "10.0.0.0/8").
+		Config()
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(err)
+	assert.Equal(t, `
+config interface 'lan'
+	option proto 'dhcp'
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+
+config route
+	option target '10.0.0.0/8'
+
+`, buf.String())
+}
+
+func TestBuilderErrors(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		b   *Builder
+		err error
+	}{
+		{NewBuilder("network").Option("proto", "static"), ErrNoSection},
+		{NewBuilder("network").Section("not a type", ""), ErrInvalidType},
+		{NewBuilder("network").Section("interface", "a b"), ErrInvalidName},
+		{NewBuilder("network").Section("interface", "lan").Option("", "x"), ErrInvalidName},
+		{NewBuilder("network").Section("interface", "lan").Section("interface", "lan"), ErrSectionExists{"network", "lan"}},
+		// the first error wins
+		{NewBuilder("network").Section("interface", "a b").Option("proto", "static"), ErrInvalidName},
+	} {
+		cfg, err := tc.b.Config()
+		assert.Nil(cfg)
+		assert.True(errors.Is(err, tc.err), "got %v, want %v", err, tc.err)
+	}
+}
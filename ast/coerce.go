@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMultipleValues is returned by Write, if an option of TypeOption has
+// more than one value. Only the first one could be written; make it a
+// list, or use CoerceTypes.
+var ErrMultipleValues = errors.New("option has multiple values")
+
+// checkTypes returns an error for the first option of c, which can't be
+// written without losing values.
+func (c *Config) checkTypes() error {
+	for _, sec := range c.Sections {
+		for _, opt := range sec.Options {
+			switch opt.Type {
+			case TypeOption:
+				if len(opt.Values) > 1 {
+					return fmt.Errorf("%s: %w", Path{Config: c.Name, Section: c.SectionName(sec), Option: opt.Name}, ErrMultipleValues)
+				}
+			case TypeList:
+			default:
+				return ErrUnknownOptionType{Type: fmt.Sprintf("!OptionType(%02x)", int(opt.Type))}
+			}
+		}
+	}
+	return nil
+}
+
+// CoerceTypes makes the types of the options agree with their values:
+// options with multiple values become lists, and options without values
+// (which can't be written) are removed. It reports whether c changed.
+// Unlike Normalize, it keeps the order and the values of sections and
+// options.
+func (c *Config) CoerceTypes() bool {
+	var changed bool
+	for _, sec := range c.Sections {
+		options := sec.Options[:0]
+		for _, opt := range sec.Options {
+			switch {
+			case len(opt.Values) == 0:
+				changed = true
+				continue
+			case opt.Type == TypeOption && len(opt.Values) > 1:
+				opt.Type = TypeList
+				changed = true
+			}
+			options = append(options, opt)
+		}
+		sec.Options = options
+	}
+	return changed
+}
+
+// Set replaces the values of the named option, adding it if necessary,
+// and returns it. The type follows the values: multiple values make a
+// list, a single value an option (unless the option already is a list).
+// Without values, the option is removed, and nil is returned.
+func (s *Section) Set(name string, values ...string) *Option {
+	if len(values) == 0 {
+		s.Del(name)
+		return nil
+	}
+	typ := TypeOption
+	opt := s.Get(name)
+	if len(values) > 1 || opt != nil && opt.Type == TypeList {
+		typ = TypeList
+	}
+	values = append([]string(nil), values...)
+	if opt == nil {
+		return s.Add(NewOption(name, typ, values...))
+	}
+	opt.Type = typ
+	opt.SetValues(values...)
+	return opt
+}
@@ -0,0 +1,66 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMultipleValues(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := NewConfig("test")
+	sec := cfg.Add(NewSection("foo", "bar"))
+	sec.Add(NewOption("a", TypeOption, "1", "2"))
+	cfg.Add(NewSection("foo", "")).Add(NewOption("b", TypeOption))
+
+	var buf bytes.Buffer
+	n, err := cfg.WriteTo(&buf)
+	assert.True(errors.Is(err, ErrMultipleValues))
+	assert.EqualError(err, "test.bar.a: option has multiple values")
+	assert.Zero(n)
+	assert.Zero(buf.Len())
+
+	_, err = cfg.AppendText(nil)
+	assert.True(errors.Is(err, ErrMultipleValues))
+
+	cfg.Sections[0].Options[0].Type = OptionType(7)
+	_, err = cfg.WriteTo(&buf)
+	assert.Equal(ErrUnknownOptionType{Type: "!OptionType(07)"}, err)
+	cfg.Sections[0].Options[0].Type = TypeOption
+
+	assert.True(cfg.CoerceTypes())
+	assert.False(cfg.CoerceTypes())
+	assert.Equal(TypeList, sec.Get("a").Type)
+	assert.Empty(cfg.Sections[1].Options)
+
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(err)
+	assert.Equal("\nconfig foo 'bar'\n\tlist a '1'\n\tlist a '2'\n\nconfig foo\n\n", buf.String())
+}
+
+func TestSectionSet(t *testing.T) {
+	assert := assert.New(t)
+
+	sec := NewSection("foo", "bar")
+	values := []string{"1", "2"}
+
+	opt := sec.Set("a", values...)
+	assert.Equal(NewOption("a", TypeList, "1", "2"), opt)
+	values[0] = "x"
+	assert.Equal([]string{"1", "2"}, opt.Values, "values must be copied")
+
+	assert.Same(opt, sec.Set("a", "3"))
+	assert.Equal(NewOption("a", TypeList, "3"), opt, "lists stay lists")
+
+	assert.Equal(NewOption("b", TypeOption, "4"), sec.Set("b", "4"))
+	assert.Equal(NewOption("b", TypeList, "5", "6"), sec.Set("b", "5", "6"))
+
+	assert.Nil(sec.Set("a"))
+	assert.Nil(sec.Get("a"))
+	assert.Len(sec.Options, 1)
+}
@@ -0,0 +1,460 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DeltaCmd is the kind of a Delta.
+type DeltaCmd int
+
+const (
+	DeltaChange  DeltaCmd = iota // set an option, or the type of a section (creating it)
+	DeltaAdd                     // add an unnamed section ("uci add")
+	DeltaRemove                  // remove a section or option
+	DeltaRename                  // rename a section or option
+	DeltaReorder                 // move a section to another position
+	DeltaListAdd                 // append a value to a list
+	DeltaListDel                 // remove a value from a list
+)
+
+// deltaPrefixes are the line prefixes used by libuci.
+var deltaPrefixes = [...]string{
+	DeltaChange:  "",
+	DeltaAdd:     "+",
+	DeltaRemove:  "-",
+	DeltaRename:  "@",
+	DeltaReorder: "^",
+	DeltaListAdd: "|",
+	DeltaListDel: "~",
+}
+
+func (cmd DeltaCmd) String() string {
+	switch cmd {
+	case DeltaChange:
+		return "change"
+	case DeltaAdd:
+		return "add"
+	case DeltaRemove:
+		return "remove"
+	case DeltaRename:
+		return "rename"
+	case DeltaReorder:
+		return "reorder"
+	case DeltaListAdd:
+		return "list-add"
+	case DeltaListDel:
+		return "list-del"
+	}
+	return fmt.Sprintf("%%DeltaCmd(%d)", int(cmd))
+}
+
+// A Delta is a single uncommitted change, as stored by libuci in its
+// delta files (one file per config in the save directory, usually
+// /tmp/.uci), and listed by "uci changes".
+//
+// Unnamed sections are referred to by the ID libuci assigns to them (see
+// AnonymousID), or by their "@type[index]" selector.
+type Delta struct {
+	Cmd     DeltaCmd
+	Package string
+	Section string
+	Option  string // empty for section changes
+	Value   string // type, value, new name or position, depending on Cmd
+}
+
+// String formats d like a line of a delta file (without line break).
+func (d Delta) String() string {
+	var sb strings.Builder
+	if int(d.Cmd) >= 0 && int(d.Cmd) < len(deltaPrefixes) {
+		sb.WriteString(deltaPrefixes[d.Cmd])
+	}
+	sb.WriteString(d.Package)
+	sb.WriteByte('.')
+	sb.WriteString(d.Section)
+	if d.Option != "" {
+		sb.WriteByte('.')
+		sb.WriteString(d.Option)
+	}
+	if d.Cmd != DeltaRemove || d.Value != "" {
+		sb.WriteByte('=')
+		sb.Write(appendShowValue(nil, d.Value))
+	}
+	return sb.String()
+}
+
+// WriteDeltas writes the deltas in the format of libuci's delta files.
+// libuci reads the keys of delta lines unquoted, so it returns an error
+// wrapping ErrInvalidName, and writes nothing, if a package, section or
+// option name contains a dot, an equals sign, a quote, a backslash or
+// whitespace.
+func WriteDeltas(w io.Writer, deltas []Delta) error {
+	var b []byte
+	for _, d := range deltas {
+		for _, name := range []string{d.Package, d.Section, d.Option} {
+			if strings.ContainsAny(name, ".='\" \t\n\\") {
+				return fmt.Errorf("%w: %q", ErrInvalidName, name)
+			}
+		}
+		b = append(b, d.String()...)
+		b = append(b, '\n')
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// ParseDeltas reads a libuci delta file. Errors are returned as
+// *PositionError wrapping a *ParseError.
+func ParseDeltas(input string) ([]Delta, error) {
+	var deltas []Delta
+	idx := newLineIndex("", input)
+
+	offset := 0
+	for _, line := range showLines(input) {
+		start := offset
+		offset += len(line)
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			perr := ParseError(fmt.Sprintf(format, args...))
+			return &PositionError{Pos: idx.position(start), Err: &perr}
+		}
+
+		d := Delta{Cmd: DeltaChange}
+		for cmd, prefix := range deltaPrefixes {
+			if prefix != "" && strings.HasPrefix(line, prefix) {
+				d.Cmd = DeltaCmd(cmd)
+				line = line[len(prefix):]
+				break
+			}
+		}
+
+		// libuci reads the line as a single shell-like argument
+		words, err := splitShowValue(line)
+		if err != nil {
+			return deltas, fail("%v", err)
+		}
+		if len(words) != 1 {
+			return deltas, fail("unexpected whitespace in %q", line)
+		}
+		key, value, hasValue := strings.Cut(words[0], "=")
+		if !hasValue && d.Cmd != DeltaRemove {
+			return deltas, fail("missing value in %q", line)
+		}
+		d.Value = value
+
+		parts := strings.Split(key, ".")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return deltas, fail("invalid key %q", key)
+		}
+		d.Package, d.Section = parts[0], parts[1]
+		if len(parts) == 3 {
+			d.Option = parts[2]
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas, nil
+}
+
+// AnonymousID returns the name libuci assigns to the unnamed section s
+// of c when reading the config file, e.g. "cfg02dc81" for the second
+// section, if it is of type "zone". Delta files and "uci show -X" refer
+// to unnamed sections by this ID. It returns an empty string if s is
+// named, or not part of c.
+func AnonymousID(c *Config, s *Section) string {
+	if s.Name != "" {
+		return ""
+	}
+	for i, sec := range c.Sections {
+		if sec == s {
+			return anonymousID(i+1, s.Type)
+		}
+	}
+	return ""
+}
+
+// anonymousID replicates uci_fixup_section: n is the number of sections
+// allocated in the package so far (including the new one).
+func anonymousID(n int, typ string) string {
+	return fmt.Sprintf("cfg%02x%04x", n, djbhash(typ)%(1<<16))
+}
+
+// ParseAnonymousID splits an ID, as returned by AnonymousID, into the
+// position of the section (starting at 1) and the hash of its type. ok
+// is false, if id isn't of that form.
+func ParseAnonymousID(id string) (n int, typeHash uint16, ok bool) {
+	if len(id) < 9 || !strings.HasPrefix(id, "cfg") {
+		return 0, 0, false
+	}
+	digits := id[3 : len(id)-4]
+	if len(digits) > 2 && digits[0] == '0' {
+		return 0, 0, false // not produced by %02x
+	}
+	for i := 3; i < len(id); i++ {
+		if c := id[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return 0, 0, false
+		}
+	}
+	pos, err := strconv.ParseUint(digits, 16, 31)
+	if err != nil || pos == 0 {
+		return 0, 0, false
+	}
+	hash, _ := strconv.ParseUint(id[len(id)-4:], 16, 16)
+	return int(pos), uint16(hash), true
+}
+
+// getAnonymous returns the unnamed section identified by id (see
+// AnonymousID), or nil.
+func (c *Config) getAnonymous(id string) *Section {
+	n, hash, ok := ParseAnonymousID(id)
+	if !ok || n > len(c.Sections) {
+		return nil
+	}
+	if sec := c.Sections[n-1]; sec.Name == "" && uint16(djbhash(sec.Type)) == hash {
+		return sec
+	}
+	return nil
+}
+
+// djbhash is the string hash of libuci (with a signed char, as on most
+// OpenWrt targets).
+func djbhash(s string) uint32 {
+	hash := uint32(5381)
+	for i := 0; i < len(s); i++ {
+		hash = hash<<5 + hash + uint32(int32(int8(s[i])))
+	}
+	return hash & 0x7FFFFFFF
+}
+
+// ApplyDeltas applies the deltas for c (deltas of other packages are
+// ignored), like libuci does when reading a config with pending changes.
+// Deltas which can't be applied (e.g. because they refer to a missing
+// section) are skipped; their errors are joined and returned.
+//
+// Sections added by DeltaAdd are unnamed.
+func (c *Config) ApplyDeltas(deltas []Delta) error {
+	a := deltaApplier{
+		c:     c,
+		ids:   make(map[string]*Section),
+		added: make(map[string]*Section),
+	}
+	for _, sec := range c.Sections {
+		if id := AnonymousID(c, sec); id != "" {
+			a.ids[id] = sec
+		}
+	}
+
+	var errs []error
+	for _, d := range deltas {
+		if d.Package != c.Name {
+			continue
+		}
+		if err := a.apply(d); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type deltaApplier struct {
+	c     *Config
+	ids   map[string]*Section // anonymous IDs of the unnamed sections
+	added map[string]*Section // sections added by DeltaAdd
+}
+
+// section resolves a delta's section reference.
+func (a *deltaApplier) section(ref string) *Section {
+	if sec := a.added[ref]; sec != nil {
+		return sec
+	}
+	if sec := a.c.Get(ref); sec != nil {
+		return sec
+	}
+	return a.ids[ref]
+}
+
+func (a *deltaApplier) apply(d Delta) error { //nolint:cyclop
+	sec := a.section(d.Section)
+	if d.Cmd == DeltaAdd || d.Cmd == DeltaChange && d.Option == "" {
+		if !isIdent(d.Value) {
+			return ErrInvalidType
+		}
+		switch {
+		case sec != nil:
+			sec.Type = d.Value
+			a.c.Reindex()
+		case d.Cmd == DeltaAdd:
+			a.added[d.Section] = a.c.Add(NewSection(d.Value, ""))
+		default:
+			a.c.Add(NewSection(d.Value, d.Section))
+		}
+		return nil
+	}
+	if sec == nil {
+		return ErrSectionNotFound{a.c.Name, d.Section}
+	}
+
+	var opt *Option
+	if d.Option != "" {
+		opt = sec.Get(d.Option)
+	}
+
+	switch d.Cmd { //nolint:exhaustive
+	case DeltaChange:
+		if opt == nil {
+			sec.Add(NewOption(d.Option, TypeOption, d.Value))
+		} else {
+			opt.Type = TypeOption
+			opt.SetValues(d.Value)
+		}
+
+	case DeltaRemove:
+		if d.Option == "" {
+			a.c.remove(sec)
+			a.forget(sec)
+		} else if !sec.Del(d.Option) {
+			return fmt.Errorf("%w: %s", ErrOptionNotFound, d.Option)
+		}
+
+	case DeltaRename:
+		if d.Option == "" {
+			if !isName(d.Value) {
+				return ErrInvalidName
+			}
+			sec.Name = d.Value
+			a.c.Reindex()
+		} else if opt == nil {
+			return fmt.Errorf("%w: %s", ErrOptionNotFound, d.Option)
+		} else {
+			opt.Name = d.Value
+		}
+
+	case DeltaReorder:
+		pos, err := strconv.Atoi(d.Value)
+		if err != nil || d.Option != "" {
+			return fmt.Errorf("invalid position %q", d.Value)
+		}
+		a.reorder(sec, pos)
+
+	case DeltaListAdd:
+		if opt == nil {
+			sec.Add(NewOption(d.Option, TypeList, d.Value))
+		} else {
+			opt.Type = TypeList
+			opt.AddValue(d.Value)
+		}
+
+	case DeltaListDel:
+		if opt == nil {
+			return fmt.Errorf("%w: %s", ErrOptionNotFound, d.Option)
+		}
+		if opt.Type != TypeList {
+			return fmt.Errorf("option %s is not a list", d.Option)
+		}
+		_ = opt.RemoveValue(d.Value) // like uci, ignore missing values
+	}
+	return nil
+}
+
+// forget drops the references to a removed section.
+func (a *deltaApplier) forget(sec *Section) {
+	for _, refs := range []map[string]*Section{a.ids, a.added} {
+		for ref, s := range refs {
+			if s == sec {
+				delete(refs, ref)
+			}
+		}
+	}
+}
+
+// reorder moves sec to the given position (clamped to the valid range).
+func (a *deltaApplier) reorder(sec *Section, pos int) {
+	a.c.remove(sec)
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(a.c.Sections) {
+		pos = len(a.c.Sections)
+	}
+	sections := make([]*Section, 0, len(a.c.Sections)+1)
+	sections = append(sections, a.c.Sections[:pos]...)
+	sections = append(sections, sec)
+	sections = append(sections, a.c.Sections[pos:]...)
+	a.c.Sections = sections
+	a.c.Reindex()
+}
+
+// Deltas returns the deltas which turn from into to, in the format of
+// libuci. Writing them into the save directory makes them show up in
+// "uci changes", and "uci commit" applies them.
+//
+// Unnamed sections of from are referred to by their anonymous ID (see
+// AnonymousID); unnamed sections only present in to are added with a new
+// ID, as "uci add" would do.
+func Deltas(from, to *Config) []Delta {
+	ids := make(map[*Section]string, len(from.Sections))
+	for _, sec := range from.Sections {
+		ids[sec] = AnonymousID(from, sec)
+	}
+	ref := func(sel string) string {
+		sec := from.Get(sel)
+		if sec != nil && sec.Name == "" {
+			return ids[sec]
+		}
+		return sel
+	}
+
+	var removed, deltas []Delta
+	added := make(map[string]string) // selector in to → ID
+	n := len(from.Sections)
+	for _, ch := range Diff(from, to) {
+		d := Delta{Package: to.Name, Section: ref(ch.Section), Option: ch.Option}
+		switch ch.Op {
+		case OpAddSection:
+			d.Cmd, d.Section, d.Value = DeltaChange, ch.Section, ch.Type
+			if sec := to.Get(ch.Section); sec != nil && sec.Name == "" {
+				n++
+				d.Cmd, d.Section = DeltaAdd, anonymousID(n, ch.Type)
+				added[ch.Section] = d.Section
+			}
+			deltas = append(deltas, d)
+
+		case OpDelSection, OpDelOption:
+			d.Cmd = DeltaRemove
+			removed = append(removed, d)
+
+		case OpSetOption:
+			if id, ok := added[ch.Section]; ok {
+				d.Section = id
+			}
+			if ch.OptionType == TypeOption && len(ch.New) == 0 {
+				d.Cmd = DeltaRemove // not representable
+				deltas = append(deltas, d)
+				continue
+			}
+			if ch.OptionType == TypeOption {
+				d.Cmd, d.Value = DeltaChange, ch.New[0]
+				deltas = append(deltas, d)
+				continue
+			}
+			if ch.Old != nil {
+				rm := d
+				rm.Cmd = DeltaRemove
+				deltas = append(deltas, rm)
+			}
+			for _, v := range ch.New {
+				d.Cmd, d.Value = DeltaListAdd, v
+				deltas = append(deltas, d)
+			}
+		}
+	}
+
+	// removals first, so that named sections, which changed their type,
+	// are removed before they are added again
+	return append(removed, deltas...)
+}
@@ -0,0 +1,260 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousID(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("firewall", `
+config defaults
+	option input 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+
+config zone
+	option name 'wan'
+
+config rule
+	option name 'Allow-Ping'
+`)
+	require.NoError(t, err)
+
+	assert.Equal("cfg01e63d", AnonymousID(cfg, cfg.Sections[0]))
+	assert.Equal("", AnonymousID(cfg, cfg.Sections[1]))
+	assert.Equal("cfg03dc81", AnonymousID(cfg, cfg.Sections[2]))
+	assert.Equal("cfg0492bd", AnonymousID(cfg, cfg.Sections[3]))
+	assert.Equal("", AnonymousID(cfg, NewSection("rule", "")))
+
+	// lookup by ID
+	assert.Same(cfg.Sections[2], cfg.Get("cfg03dc81"))
+	assert.Same(cfg.Sections[3], cfg.Get("cfg0492bd"))
+	assert.Nil(cfg.Get("cfg02dc81")) // named
+	assert.Nil(cfg.Get("cfg03e63d")) // other type
+	assert.Nil(cfg.Get("cfg05dc81"))
+	sec, err := cfg.Lookup("cfg01e63d")
+	assert.NoError(err)
+	assert.Same(cfg.Sections[0], sec)
+
+	cfg.Add(NewSection("zone", "cfg0492bd")) // named sections take precedence
+	assert.Same(cfg.Sections[4], cfg.Get("cfg0492bd"))
+}
+
+func TestParseAnonymousID(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		id   string
+		n    int
+		hash uint16
+		ok   bool
+	}{
+		{"cfg03dc81", 3, 0xdc81, true},
+		{"cfgff0000", 255, 0, true},
+		{"cfg100e63d", 256, 0xe63d, true},
+		{"cfg00dc81", 0, 0, false},
+		{"cfg0100dc81", 0, 0, false},
+		{"cfg3dc81", 0, 0, false},
+		{"cfg03DC81", 0, 0, false},
+		{"cfg03dc8x", 0, 0, false},
+		{"lan", 0, 0, false},
+		{"zone03dc81", 0, 0, false},
+	}
+	for _, tc := range tt {
+		n, hash, ok := ParseAnonymousID(tc.id)
+		assert.Equal(tc.ok, ok, tc.id)
+		assert.Equal(tc.n, n, tc.id)
+		assert.Equal(tc.hash, hash, tc.id)
+	}
+}
+
+const tcDeltas = `+firewall.cfg05dc81='zone'
+firewall.cfg05dc81.name='guest'
+firewall.cfg03dc81.input='REJECT'
+|firewall.lan.network='lan'
+|firewall.lan.network='lan6'
+~firewall.lan.network='lan'
+-firewall.cfg0492bd
+@firewall.lan='home'
+^firewall.cfg05dc81='0'
+firewall.cfg01e63d.syn_flood='it'\''s on'
+-firewall.cfg01e63d.input
+`
+
+func TestParseDeltas(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	deltas, err := ParseDeltas(tcDeltas)
+	require.NoError(err)
+	require.Len(deltas, 11)
+
+	assert.Equal(Delta{DeltaAdd, "firewall", "cfg05dc81", "", "zone"}, deltas[0])
+	assert.Equal(Delta{DeltaListDel, "firewall", "lan", "network", "lan"}, deltas[5])
+	assert.Equal(Delta{DeltaRemove, "firewall", "cfg0492bd", "", ""}, deltas[6])
+	assert.Equal(Delta{DeltaRename, "firewall", "lan", "", "home"}, deltas[7])
+	assert.Equal(Delta{DeltaReorder, "firewall", "cfg05dc81", "", "0"}, deltas[8])
+	assert.Equal("it's on", deltas[9].Value)
+
+	var buf bytes.Buffer
+	require.NoError(WriteDeltas(&buf, deltas))
+	assert.Equal(tcDeltas, buf.String())
+
+	for _, input := range []string{
+		"firewall.lan",             // missing value
+		"firewall=zone",            // missing section
+		"firewall.lan.a.b='x'",     // too many components
+		"firewall.lan.name='open",  // unterminated quote
+		"firewall.lan.name=a b",    // more than one word
+		"|firewall..network='lan'", // empty section
+	} {
+		_, err := ParseDeltas("firewall.lan.name='ok'\n" + input + "\n")
+		require.Error(err, input)
+
+		var perr *PositionError
+		require.True(errors.As(err, &perr), input)
+		assert.Equal(2, perr.Pos.Line, input)
+	}
+}
+
+func TestApplyDeltas(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("firewall", `
+config defaults
+	option input 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+
+config zone
+	option name 'wan'
+
+config rule
+	option name 'Allow-Ping'
+`)
+	require.NoError(err)
+
+	deltas, err := ParseDeltas(tcDeltas + "network.lan.proto='dhcp'\n")
+	require.NoError(err)
+	require.NoError(cfg.ApplyDeltas(deltas))
+
+	want, err := Parse("firewall", `
+config zone
+	option name 'guest'
+
+config defaults
+	option syn_flood "it's on"
+
+config zone 'home'
+	option name 'lan'
+	list network 'lan6'
+
+config zone
+	option name 'wan'
+	option input 'REJECT'
+`)
+	require.NoError(err)
+	assert.Empty(Diff(cfg, want))
+
+	err = cfg.ApplyDeltas([]Delta{
+		{DeltaChange, "firewall", "missing", "name", "x"},
+		{DeltaRemove, "firewall", "home", "missing", ""},
+		{DeltaChange, "firewall", "home", "name", "home"},
+		{DeltaAdd, "firewall", "cfg06dc81", "", "not a type"},
+	})
+	require.Error(err)
+	assert.True(errors.Is(err, ErrOptionNotFound))
+	assert.True(errors.Is(err, ErrInvalidType))
+	assert.True(errors.As(err, new(ErrSectionNotFound)))
+	assert.Equal("home", cfg.Get("home").LastValue("name"))
+}
+
+func TestDeltas(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const original = `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	list dns '1.1.1.1'
+
+config interface 'wan'
+	option proto 'dhcp'
+
+config rule
+	option name 'r0'
+
+config rule
+	option name 'r1'
+`
+	from, err := Parse("network", original)
+	require.NoError(err)
+	to, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.2.1'
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+
+config device 'wan'
+	option name 'eth1'
+
+config rule
+	option name 'r0'
+	option src 'wan'
+
+config route
+	option target '10.0.0.0/8'
+`)
+	require.NoError(err)
+
+	deltas := Deltas(from, to)
+	var buf bytes.Buffer
+	require.NoError(WriteDeltas(&buf, deltas))
+	assert.Equal(`-network.wan
+-network.cfg0492bd
+network.lan.ipaddr='192.168.2.1'
+-network.lan.dns
+|network.lan.dns='1.1.1.1'
+|network.lan.dns='9.9.9.9'
+network.wan='device'
+network.wan.name='eth1'
+network.cfg0392bd.src='wan'
++network.cfg05c8b4='route'
+network.cfg05c8b4.target='10.0.0.0/8'
+`, buf.String())
+
+	// applying the deltas to the original yields the new config
+	cfg, err := Parse("network", original)
+	require.NoError(err)
+	parsed, err := ParseDeltas(buf.String())
+	require.NoError(err)
+	require.NoError(cfg.ApplyDeltas(parsed))
+	assert.Empty(Diff(cfg, to))
+}
+
+func TestWriteDeltasInvalidName(t *testing.T) {
+	assert := assert.New(t)
+
+	from := NewConfig("network")
+	to := NewConfig("network")
+	to.Add(NewSection("interface", "lan.1")).SetOption("proto", "dhcp")
+
+	var buf bytes.Buffer
+	err := WriteDeltas(&buf, Deltas(from, to))
+	assert.True(errors.Is(err, ErrInvalidName), "%v", err)
+	assert.Empty(buf.String())
+
+	err = WriteDeltas(&buf, []Delta{{Cmd: DeltaChange, Package: "network", Section: "lan", Option: "a b", Value: "x"}})
+	assert.True(errors.Is(err, ErrInvalidName), "%v", err)
+}
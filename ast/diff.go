@@ -0,0 +1,162 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeOp describes the kind of a Change.
+type ChangeOp int
+
+const (
+	OpAddSection ChangeOp = iota // section was added
+	OpDelSection                 // section was removed
+	OpSetOption                  // option was added or its values changed
+	OpDelOption                  // option was removed
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case OpAddSection:
+		return "add"
+	case OpDelSection:
+		return "delete"
+	case OpSetOption:
+		return "set"
+	case OpDelOption:
+		return "delete-option"
+	}
+	return fmt.Sprintf("%%ChangeOp(%d)", int(op))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (op ChangeOp) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+// A Change describes a single difference between two configs.
+//
+// Section is the section name, or its "@type[index]" selector for
+// unnamed sections. Selectors of removed sections and options refer to
+// the old config, all others refer to the new config.
+type Change struct {
+	Op         ChangeOp   `json:"op"`
+	Section    string     `json:"section"`
+	Type       string     `json:"type"`
+	Option     string     `json:"option,omitempty"`
+	OptionType OptionType `json:"option_type"`
+	Old        []string   `json:"old,omitempty"`
+	New        []string   `json:"new,omitempty"`
+}
+
+// String formats the change similar to "uci changes".
+func (c Change) String() string {
+	switch c.Op {
+	case OpAddSection:
+		return fmt.Sprintf("+%s=%s", QuoteName(c.Section), c.Type)
+	case OpDelSection:
+		return fmt.Sprintf("-%s", QuoteName(c.Section))
+	case OpSetOption:
+		return fmt.Sprintf("%s.%s=%s", QuoteName(c.Section), c.Option, quoteValues(c.New))
+	case OpDelOption:
+		return fmt.Sprintf("-%s.%s", QuoteName(c.Section), c.Option)
+	}
+	return fmt.Sprintf("%%Change(%d)", int(c.Op))
+}
+
+func quoteValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Diff computes the changes required to turn one config into another.
+// Named sections are matched by name, unnamed sections by their
+// "@type[index]" selector. A named section, which changed its type, is
+// reported as removed and added again.
+//
+// Changes are ordered as follows: first come option changes and section
+// additions (in the order of the new config), then section removals (in
+// the order of the old config).
+func Diff(from, to *Config) []Change {
+	var changes []Change
+
+	matched := make(map[*Section]bool, len(from.Sections))
+	for _, sec := range to.Sections {
+		name := to.SectionName(sec)
+		prev := from.Get(name)
+		if prev != nil && prev.Type != sec.Type {
+			prev = nil
+		}
+
+		if prev == nil {
+			changes = append(changes, Change{Op: OpAddSection, Section: name, Type: sec.Type})
+			for _, opt := range sec.Options {
+				changes = append(changes, setChange(name, sec.Type, nil, opt))
+			}
+			continue
+		}
+
+		matched[prev] = true
+		changes = append(changes, diffOptions(name, prev, sec)...)
+	}
+
+	for _, sec := range from.Sections {
+		if !matched[sec] {
+			changes = append(changes, Change{Op: OpDelSection, Section: from.SectionName(sec), Type: sec.Type})
+		}
+	}
+	return changes
+}
+
+func diffOptions(name string, from, to *Section) []Change {
+	var changes []Change
+	for _, opt := range to.Options {
+		prev := from.Get(opt.Name)
+		if prev == nil || prev.Type != opt.Type || !equalValues(prev.Values, opt.Values) {
+			changes = append(changes, setChange(name, to.Type, prev, opt))
+		}
+	}
+	for _, opt := range from.Options {
+		if to.Get(opt.Name) == nil {
+			changes = append(changes, Change{
+				Op:         OpDelOption,
+				Section:    name,
+				Type:       from.Type,
+				Option:     opt.Name,
+				OptionType: opt.Type,
+				Old:        opt.Values,
+			})
+		}
+	}
+	return changes
+}
+
+func setChange(name, typ string, prev, opt *Option) Change {
+	c := Change{
+		Op:         OpSetOption,
+		Section:    name,
+		Type:       typ,
+		Option:     opt.Name,
+		OptionType: opt.Type,
+		New:        opt.Values,
+	}
+	if prev != nil {
+		c.Old = prev.Values
+	}
+	return c
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
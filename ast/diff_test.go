@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	from, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	list dns '1.1.1.1'
+
+config interface 'wan'
+	option proto 'dhcp'
+
+config rule
+	option name 'r0'
+
+config rule
+	option name 'r1'
+`)
+	require.NoError(t, err)
+
+	to, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.2.1'
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+	option mtu '1500'
+
+config device 'wan'
+	option name 'eth1'
+
+config rule
+	option name 'r0'
+	option src 'wan'
+`)
+	require.NoError(t, err)
+
+	changes := Diff(from, to)
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	assert.Equal(t, []string{
+		"lan.ipaddr='192.168.2.1'",
+		"lan.dns='1.1.1.1' '9.9.9.9'",
+		"lan.mtu='1500'",
+		"+wan=device",
+		"wan.name='eth1'",
+		"@rule[0].src='wan'",
+		"-wan",
+		"-@rule[1]",
+	}, lines)
+
+	assert.Equal(t, []string{"192.168.1.1"}, changes[0].Old)
+	assert.Equal(t, TypeList, changes[1].OptionType)
+
+	assert.Empty(t, Diff(from, from))
+}
+
+func TestDiff_delOption(t *testing.T) {
+	from, err := Parse("system", "config system\n\toption hostname 'foo'\n\toption timezone 'UTC'\n")
+	require.NoError(t, err)
+	to, err := Parse("system", "config system\n\toption hostname 'foo'\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, []Change{{
+		Op:      OpDelOption,
+		Section: "@system[0]",
+		Type:    "system",
+		Option:  "timezone",
+		Old:     []string{"UTC"},
+	}}, Diff(from, to))
+}
@@ -0,0 +1,61 @@
+/*
+Package ast contains the UCI syntax tree (Config, Section and Option),
+as well as the lexer, parser and serializer for UCI files.
+
+It has no dependencies on the file system (or any other part of the
+operating system), so it can be used in constrained environments, e.g.
+when compiling to WebAssembly or with TinyGo:
+
+	cfg, err := ast.Parse("network", input)
+	if err != nil {
+		// handle error
+	}
+	cfg.Get("lan").Get("proto") //=> &Option{Name: "proto", ...}
+	cfg.WriteTo(os.Stdout)
+
+Configs can also be written and read in the key=value format of "uci
+show" (see Config.Show and ParseShow), e.g. to compare them with output
+captured from devices. The delta files, in which libuci keeps
+uncommitted changes, are supported by ParseDeltas, WriteDeltas,
+Config.ApplyDeltas and Deltas.
+
+The lexer is heavily inspired by Rob Pike's 2011 GTUG Sydney talk
+"Lexical Scanning in Go" (https://talks.golang.org/2011/lex.slide,
+https://youtu.be/HxaD_trXwRE), which in turn was a presentation of
+an early version of Go's text/template parser. It follows, that this
+library borrows code from Go's standard library (BSD-style licensed).
+
+The UCI grammar (for the purpose of this library) is defined as follows:
+
+	uci
+			packageDecl*
+			configDecl*
+
+	packageDecl
+			`package` value CRLF configDecl*
+
+	configDecl
+			`config` ident name? CRLF optionDecl*
+
+	optionDecl
+			`option` ident value
+			`list` ident value
+
+	ident
+			[-_a-zA-Z0-9]+
+
+	name
+			`'` STRING `'`
+			`"` STRING `"`
+			[-_.a-zA-Z0-9]+
+
+	value
+			`'` STRING `'`
+			`"` STRING `"`
+			ident
+
+For now, UCI imports/exports (packageDecl production) are not supported
+yet. The STRING token (value production) is also somewhat vaguely
+defined, and needs to be aligned with the actual C implementation.
+*/
+package ast
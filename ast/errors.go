@@ -0,0 +1,19 @@
+package ast
+
+import "fmt"
+
+// ErrUnknownOptionType is returned when trying to parse an invalid OptionType.
+type ErrUnknownOptionType struct {
+	Type string
+}
+
+func (err ErrUnknownOptionType) Error() string {
+	return fmt.Sprintf("Unknown Option type %s", err.Type)
+}
+
+// ParseError is returned by Parse, if the input is not a valid UCI file.
+type ParseError string
+
+func (err ParseError) Error() string {
+	return fmt.Sprintf("parse error: %s", string(err))
+}
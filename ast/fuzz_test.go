@@ -0,0 +1,163 @@
+package ast
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// addSeeds adds the test inputs, and the config files of the root
+// package's testdata (including the OpenWrt release corpus), to the seed
+// corpus of f.
+func addSeeds(f *testing.F) {
+	f.Helper()
+	for _, tc := range []string{
+		tcEmptyInput1, tcSimpleInput, tcExportInput, tcUnquotedInput,
+		tcUnnamedInput, tcHyphenatedInput, tcComment, tcUnterminatedQuoted, tcUnterminatedUnquoted,
+		"config foo '@foo[0]'\n\toption a 'b'\n",
+		"config foo\n\tlist a 'b'\n\tlist a 'c'\n",
+		"config foo\n\toption a \"it's\"\n\toption b 'say \"hi\"'\n",
+		"\ufeffconfig foo\r\n\toption a b\r\n",
+	} {
+		f.Add(tc)
+	}
+	files, _ := filepath.Glob(filepath.Join("..", "testdata", "*"))
+	releases, _ := filepath.Glob(filepath.Join("..", "testdata", "openwrt", "*", "*"))
+	for _, name := range append(files, releases...) {
+		if fi, err := os.Stat(name); err != nil || fi.IsDir() || strings.HasSuffix(name, ".json") {
+			continue
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(b))
+	}
+}
+
+// FuzzParse feeds arbitrary input to the parser, and exercises the API
+// on the result. Nothing may panic.
+func FuzzParse(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		cfg, err := Parse("fuzz", input)
+		if err != nil {
+			return
+		}
+		for _, sec := range cfg.Sections {
+			name := cfg.SectionName(sec)
+			if sec.Name == "" && cfg.Get(name) != sec {
+				t.Errorf("Get(%q) doesn't return the section", name)
+			}
+			for _, opt := range sec.Options {
+				_ = sec.LastValue(opt.Name)
+			}
+		}
+		_, _ = cfg.AppendText(nil)
+		_ = Deltas(NewConfig("fuzz"), cfg)
+		_ = GeneratePatch(NewConfig("fuzz"), cfg)
+		_ = Overlay(cfg, cfg)
+	})
+}
+
+// FuzzWriteToRoundTrip checks that written configs parse to the same
+// config.
+func FuzzWriteToRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		cfg, err := Parse("fuzz", input)
+		if err != nil || !representable(cfg) {
+			return
+		}
+		for _, style := range []Style{{}, {Quote: QuoteDouble}, {Quote: QuoteMinimal}, {Indent: "  ", NoBlankLines: true}} {
+			var buf bytes.Buffer
+			if _, err := cfg.Write(&buf, WithStyle(style)); err != nil {
+				t.Fatal(err)
+			}
+			again, err := Parse("fuzz", buf.String())
+			if err != nil {
+				t.Fatalf("reparsing %q (style %+v): %v", buf.String(), style, err)
+			}
+			if changes := Diff(cfg, again); len(changes) > 0 {
+				t.Fatalf("round trip of %q (style %+v) changed the config: %v", buf.String(), style, changes)
+			}
+		}
+	})
+}
+
+// representable reports whether all names and values of cfg can be
+// written. Without escape sequences, this isn't possible for values
+// containing whitespace and both kinds of quotation marks.
+func representable(cfg *Config) bool {
+	ok := func(v string) bool {
+		return quotable(v, '\'') || quotable(v, '"') || isRawWord(v)
+	}
+	for _, sec := range cfg.Sections {
+		if !quotable(sec.Name, '\'') && !quotable(sec.Name, '"') {
+			return false
+		}
+		for _, opt := range sec.Options {
+			for _, v := range opt.Values {
+				if !ok(v) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// FuzzUnmangleSectionName checks that valid selectors survive
+// formatting and parsing again.
+func FuzzUnmangleSectionName(f *testing.F) {
+	for _, sel := range []string{"", "aa[0]", "@@[0]", "@[[0]", "@][0]", "@aa0]", "@a[b]", "@a[0]", "@a[-1]", "@abcdEFGHijkl[-255]", "@a[0xff]", "@a[+1]"} {
+		f.Add(sel)
+	}
+	f.Fuzz(func(t *testing.T, sel string) {
+		typ, idx, err := unmangleSectionName(sel)
+		if err != nil {
+			return
+		}
+		typ2, idx2, err := unmangleSectionName(Num2PlaceholderSection(typ, idx))
+		if err != nil || typ2 != typ || idx2 != idx {
+			t.Fatalf("%q: got %q, %d, reparsed %q, %d, %v", sel, typ, idx, typ2, idx2, err)
+		}
+	})
+}
+
+// TestHardening covers cases, which used to panic.
+func TestHardening(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := NewConfig("test")
+	cfg.Add(NewSection("foo", ""))
+
+	foreign := NewSection("foo", "")
+	assert.Equal("", cfg.SectionName(foreign))
+	assert.Equal(-1, cfg.ordinal(foreign))
+	cfg.Merge(foreign) // appends unnamed sections of other configs
+	assert.Len(cfg.Sections, 2)
+
+	cfg.Insert(0, NewSection("bar", "first"))
+	assert.Len(cfg.Sections, 3)
+	assert.Equal("first", cfg.SectionName(cfg.Sections[0]))
+	assert.Equal("@foo[1]", cfg.SectionName(cfg.Sections[2]))
+
+	sec := cfg.Get("first")
+	sec.Add(NewOption("b", TypeOption, "2"))
+	sec.Insert(0, NewOption("a", TypeOption))
+	assert.Len(sec.Options, 2)
+	assert.Equal("", sec.LastValue("a"))
+	assert.Equal("x", sec.LastValueDefault("a", "x"))
+	assert.Equal("2", sec.LastValue("b"))
+
+	// options without values are not representable, and hence omitted
+	b, err := cfg.AppendText(nil)
+	assert.NoError(err)
+	assert.Equal("\nconfig bar 'first'\n\toption b '2'\n\nconfig foo\n\nconfig foo\n\n", string(b))
+	assert.Contains(Deltas(NewConfig("test"), cfg), Delta{Cmd: DeltaRemove, Package: "test", Section: "first", Option: "a"})
+}
@@ -0,0 +1,158 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOptionNotFound  = errors.New("option not found")
+	ErrUnsupportedType = errors.New("unsupported type")
+	ErrTypeMismatch    = errors.New("type mismatch")
+)
+
+// ValueError is returned by Get, if a value can't be converted to the
+// requested type.
+type ValueError struct {
+	Option string
+	Value  string
+	Type   string // requested type, e.g. "int"
+	Err    error
+}
+
+func (err *ValueError) Error() string {
+	return fmt.Sprintf("option %s: invalid %s value %q: %v", err.Option, err.Type, err.Value, err.Err)
+}
+
+// Unwrap returns the conversion error.
+func (err *ValueError) Unwrap() error {
+	return err.Err
+}
+
+// Is makes value errors match ErrTypeMismatch.
+func (err *ValueError) Is(target error) bool {
+	return target == ErrTypeMismatch //nolint:errorlint
+}
+
+// Get returns the value of the named option, converted to T. Supported
+// types are string, int, bool, float64, time.Duration, netip.Addr and
+// Rate, as well as slices of them. Other types result in ErrUnsupportedType.
+//
+// Scalar types use the last value of list options. Slices contain all
+// values; the value of a (non-list) option is split at whitespace,
+// following the convention of many OpenWrt configs (e.g. "option ports
+// '1 2 3'").
+//
+// Booleans accept the same spellings as uci (1/0, on/off, true/false,
+// yes/no, enabled/disabled). Durations without unit are interpreted as
+// seconds.
+//
+// If the option does not exist, ErrOptionNotFound is returned.
+// Conversion errors are of type *ValueError.
+func Get[T any](s *Section, name string) (T, error) {
+	var v T
+	opt := s.Get(name)
+	if opt == nil || len(opt.Values) == 0 {
+		return v, fmt.Errorf("%s: %w", name, ErrOptionNotFound)
+	}
+	err := getValue(&v, opt)
+	return v, err
+}
+
+func getValue(dst interface{}, opt *Option) error {
+	last := opt.Values[len(opt.Values)-1]
+	var err error
+	switch p := dst.(type) {
+	case *string:
+		*p = last
+	case *int:
+		*p, err = parseValue(opt.Name, "int", last, strconv.Atoi)
+	case *bool:
+		*p, err = parseValue(opt.Name, "bool", last, parseBool)
+	case *float64:
+		*p, err = parseValue(opt.Name, "float64", last, parseFloat)
+	case *time.Duration:
+		*p, err = parseValue(opt.Name, "duration", last, parseDuration)
+	case *netip.Addr:
+		*p, err = parseValue(opt.Name, "address", last, netip.ParseAddr)
+	case *Rate:
+		*p, err = parseValue(opt.Name, "rate", last, ParseRate)
+	case *[]string:
+		*p = sliceValues(opt)
+	case *[]int:
+		*p, err = parseSlice(opt, "int", strconv.Atoi)
+	case *[]bool:
+		*p, err = parseSlice(opt, "bool", parseBool)
+	case *[]float64:
+		*p, err = parseSlice(opt, "float64", parseFloat)
+	case *[]time.Duration:
+		*p, err = parseSlice(opt, "duration", parseDuration)
+	case *[]netip.Addr:
+		*p, err = parseSlice(opt, "address", netip.ParseAddr)
+	case *[]Rate:
+		*p, err = parseSlice(opt, "rate", ParseRate)
+	default:
+		typ := strings.TrimPrefix(fmt.Sprintf("%T", dst), "*")
+		return fmt.Errorf("option %s: %w %s", opt.Name, ErrUnsupportedType, typ)
+	}
+	return err
+}
+
+func parseValue[T any](name, typ, v string, parse func(string) (T, error)) (T, error) {
+	x, err := parse(v)
+	if err != nil {
+		var zero T
+		return zero, &ValueError{Option: name, Value: v, Type: typ, Err: err}
+	}
+	return x, nil
+}
+
+func parseSlice[T any](opt *Option, typ string, parse func(string) (T, error)) ([]T, error) {
+	values := sliceValues(opt)
+	result := make([]T, 0, len(values))
+	for _, v := range values {
+		x, err := parseValue(opt.Name, typ, v, parse)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, x)
+	}
+	return result, nil
+}
+
+// sliceValues returns the values of a list, or the whitespace separated
+// words of an option.
+func sliceValues(opt *Option) []string {
+	if opt.Type == TypeList {
+		return append([]string(nil), opt.Values...)
+	}
+	return strings.Fields(opt.Values[len(opt.Values)-1])
+}
+
+var errInvalidBool = errors.New("not a boolean")
+
+// parseBool interprets v like uci's config_get_bool.
+func parseBool(v string) (bool, error) {
+	switch v {
+	case "1", "on", "true", "yes", "enabled":
+		return true, nil
+	case "0", "off", "false", "no", "disabled":
+		return false, nil
+	}
+	return false, errInvalidBool
+}
+
+func parseFloat(v string) (float64, error) {
+	return strconv.ParseFloat(v, 64)
+}
+
+func parseDuration(v string) (time.Duration, error) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return time.ParseDuration(v)
+}
@@ -0,0 +1,150 @@
+package ast
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcTyped = `
+config interface 'lan'
+	option proto 'static'
+	option mtu '1500'
+	option auto '0'
+	option metric '1.5'
+	option leasetime '12h'
+	option timeout '30'
+	option limit '10/sec'
+	option ipaddr '192.168.1.1'
+	option ports '1 2 3'
+	list dns '1.1.1.1'
+	list dns '2606:4700:4700::1111'
+	list flags 'yes'
+	list flags 'disabled'
+	option broken 'x'
+`
+
+func TestGet(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", tcTyped)
+	require.NoError(t, err)
+	s := cfg.Get("lan")
+
+	proto, err := Get[string](s, "proto")
+	assert.NoError(err)
+	assert.Equal("static", proto)
+
+	mtu, err := Get[int](s, "mtu")
+	assert.NoError(err)
+	assert.Equal(1500, mtu)
+
+	auto, err := Get[bool](s, "auto")
+	assert.NoError(err)
+	assert.False(auto)
+
+	metric, err := Get[float64](s, "metric")
+	assert.NoError(err)
+	assert.Equal(1.5, metric)
+
+	lease, err := Get[time.Duration](s, "leasetime")
+	assert.NoError(err)
+	assert.Equal(12*time.Hour, lease)
+	timeout, err := Get[time.Duration](s, "timeout")
+	assert.NoError(err)
+	assert.Equal(30*time.Second, timeout)
+
+	limit, err := Get[Rate](s, "limit")
+	assert.NoError(err)
+	assert.Equal(Rate{Count: 10, Per: time.Second}, limit)
+
+	addr, err := Get[netip.Addr](s, "ipaddr")
+	assert.NoError(err)
+	assert.Equal(netip.MustParseAddr("192.168.1.1"), addr)
+
+	// scalars use the last list value
+	dns, err := Get[netip.Addr](s, "dns")
+	assert.NoError(err)
+	assert.Equal(netip.MustParseAddr("2606:4700:4700::1111"), dns)
+
+	// slices
+	ports, err := Get[[]int](s, "ports")
+	assert.NoError(err)
+	assert.Equal([]int{1, 2, 3}, ports)
+	servers, err := Get[[]netip.Addr](s, "dns")
+	assert.NoError(err)
+	assert.Len(servers, 2)
+	flags, err := Get[[]bool](s, "flags")
+	assert.NoError(err)
+	assert.Equal([]bool{true, false}, flags)
+	words, err := Get[[]string](s, "ports")
+	assert.NoError(err)
+	assert.Equal([]string{"1", "2", "3"}, words)
+}
+
+func TestGet_errors(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", tcTyped)
+	require.NoError(t, err)
+	s := cfg.Get("lan")
+
+	_, err = Get[int](s, "missing")
+	assert.True(errors.Is(err, ErrOptionNotFound))
+
+	_, err = Get[struct{}](s, "proto")
+	assert.True(errors.Is(err, ErrUnsupportedType))
+	assert.EqualError(err, "option proto: unsupported type struct {}")
+
+	var verr *ValueError
+	for _, get := range []func() error{
+		func() error { _, err := Get[int](s, "broken"); return err },
+		func() error { _, err := Get[bool](s, "broken"); return err },
+		func() error { _, err := Get[float64](s, "broken"); return err },
+		func() error { _, err := Get[time.Duration](s, "broken"); return err },
+		func() error { _, err := Get[netip.Addr](s, "broken"); return err },
+		func() error { _, err := Get[[]int](s, "dns"); return err },
+	} {
+		err := get()
+		assert.True(errors.As(err, &verr), "%v", err)
+		assert.True(errors.Is(err, ErrTypeMismatch), "%v", err)
+	}
+
+	_, err = Get[int](s, "broken")
+	assert.EqualError(err, `option broken: invalid int value "x": strconv.Atoi: parsing "x": invalid syntax`)
+}
+
+func TestLookup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("network", tcTyped)
+	require.NoError(err)
+
+	sec, err := cfg.Lookup("lan")
+	require.NoError(err)
+	assert.Same(cfg.Get("lan"), sec)
+
+	opt, err := sec.Lookup("proto")
+	require.NoError(err)
+	assert.Same(sec.Get("proto"), opt)
+
+	_, err = sec.Lookup("missing")
+	assert.True(errors.Is(err, ErrOptionNotFound))
+	assert.EqualError(err, "missing: option not found")
+
+	for _, name := range []string{"wan", "@interface[5]", "@route[0]"} {
+		_, err = cfg.Lookup(name)
+		assert.True(errors.Is(err, ErrSectionNotFound{}), name)
+		assert.True(errors.Is(err, ErrSectionNotFound{Config: "network", Section: name}), name)
+		assert.False(errors.Is(err, ErrSectionNotFound{Config: "network", Section: "lan"}), name)
+	}
+
+	_, err = cfg.Lookup("@interface[x]")
+	assert.Error(err)
+	assert.False(errors.Is(err, ErrSectionNotFound{}))
+}
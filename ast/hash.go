@@ -0,0 +1,53 @@
+package ast
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+)
+
+// Clone returns a deep copy of the config.
+func (c *Config) Clone() *Config {
+	clone := &Config{
+		Name:     c.Name,
+		Sections: make([]*Section, 0, len(c.Sections)),
+		tainted:  c.tainted,
+		encoding: c.encoding,
+		included: slices.Clone(c.included),
+	}
+	for _, sec := range c.Sections {
+		clone.Sections = append(clone.Sections, sec.Clone())
+	}
+	return clone
+}
+
+// Clone returns a deep copy of the section.
+func (s *Section) Clone() *Section {
+	clone := NewSection(s.Type, s.Name)
+	clone.include = s.include
+	for _, opt := range s.Options {
+		clone.Add(opt.Clone())
+	}
+	return clone
+}
+
+// Clone returns a deep copy of the option.
+func (o *Option) Clone() *Option {
+	values := make([]string, len(o.Values))
+	copy(values, o.Values)
+	return NewOption(o.Name, o.Type, values...)
+}
+
+// Hash returns the hex-encoded SHA-256 checksum of the config's
+// canonical form (see Normalize). Two configs with the same hash are
+// semantically equal, even if their files differ in formatting, order
+// of sections, or duplicate list values. The config name is not part
+// of the checksum.
+func (c *Config) Hash() string {
+	canonical := c.Clone()
+	canonical.Normalize()
+
+	h := sha256.New()
+	_, _ = canonical.WriteTo(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
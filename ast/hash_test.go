@@ -0,0 +1,66 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	a, err := Parse("a", `
+config foo 'b'
+	option x '1'
+
+config foo 'a'
+	list l 'v1'
+	list l 'v2'
+`)
+	require.NoError(t, err)
+
+	b, err := Parse("b", `
+# same content, different formatting and order
+config foo a
+	list l v1
+	list l "v2"
+	list l v1
+config foo b
+	option x 1
+`)
+	require.NoError(t, err)
+
+	assert.Len(t, a.Hash(), 64)
+	assert.Equal(t, a.Hash(), b.Hash())
+
+	// hashing must not modify the config
+	assert.Equal(t, "b", a.Sections[0].Name)
+
+	b.Get("b").Get("x").SetValues("2")
+	assert.NotEqual(t, a.Hash(), b.Hash())
+}
+
+func TestClone(t *testing.T) {
+	cfg, err := Parse("test", tcUnnamedInput)
+	require.NoError(t, err)
+	cfg.SetTainted()
+
+	clone := cfg.Clone()
+	assert.EqualValues(t, cfg, clone)
+	assert.True(t, clone.Tainted())
+
+	clone.Sections[0].Options[0].Values[0] = "changed"
+	clone.Sections[0].Add(NewOption("new", TypeOption, "1"))
+	assert.Equal(t, "3", cfg.Sections[0].Options[0].Values[0])
+	assert.Nil(t, cfg.Sections[0].Get("new"))
+}
+
+func TestTainted(t *testing.T) {
+	cfg := NewConfig("test")
+	assert.False(t, cfg.Tainted())
+
+	cfg.SetTainted()
+	assert.True(t, cfg.Tainted())
+
+	cfg.ResetTainted()
+	assert.False(t, cfg.Tainted())
+}
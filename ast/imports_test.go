@@ -0,0 +1,47 @@
+package ast
+
+import (
+	"go/parser"
+	gotoken "go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestImports ensures that the package stays free of file system and
+// syscall dependencies, so that it can be compiled to GOOS=js/wasm and
+// with TinyGo.
+func TestImports(t *testing.T) {
+	forbidden := map[string]bool{
+		"io/ioutil":     true,
+		"net":           true,
+		"os":            true,
+		"os/exec":       true,
+		"path/filepath": true,
+		"syscall":       true,
+		"unsafe":        true,
+	}
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := gotoken.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if forbidden[path] {
+				t.Errorf("%s: forbidden import %q", name, path)
+			}
+		}
+	}
+}
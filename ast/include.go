@@ -0,0 +1,83 @@
+package ast
+
+import (
+	"fmt"
+	"slices"
+)
+
+// A Resolver resolves the fragment a section includes, for Config.Inline.
+// It returns nil, if sec includes nothing.
+//
+// How includes are expressed is up to the resolver; e.g. a section of
+// type "include" could name another config in its path option.
+type Resolver func(sec *Section) (*Config, error)
+
+// Inline inserts the sections of the fragments included by the config's
+// sections (see Resolver) right after the including section, which is
+// kept. The inlined sections are marked with the fragment's name (see
+// Section.Included), so that writing the config leaves them out, and
+// Fragments splits them back out. Includes within fragments are not
+// resolved.
+//
+// Sections added to the config later belong to it, even if they're
+// placed between inlined sections.
+func (c *Config) Inline(resolve Resolver) error {
+	sections := make([]*Section, 0, len(c.Sections))
+	for _, sec := range c.Sections {
+		sections = append(sections, sec)
+		if sec.include != "" {
+			continue
+		}
+		frag, err := resolve(sec)
+		if err != nil {
+			return fmt.Errorf("%s: include %s: %w", c.Name, sec.Name, err)
+		}
+		if frag == nil {
+			continue
+		}
+		if !slices.Contains(c.included, frag.Name) {
+			c.included = append(c.included, frag.Name)
+		}
+		for _, s := range frag.Sections {
+			s.include = frag.Name
+			sections = append(sections, s)
+		}
+	}
+	c.Sections = sections
+	c.Reindex()
+	return nil
+}
+
+// Included returns the name of the fragment the section was inlined from
+// by Config.Inline, or "" if it belongs to its config.
+func (s *Section) Included() string {
+	return s.include
+}
+
+// Fragments returns the fragments inlined into the config, in the order
+// of their first section, with the sections currently inlined from them.
+// The fragments share their sections with the config, so that they can
+// be written after changing the config. Fragments of which all sections
+// have been deleted are returned without sections.
+func (c *Config) Fragments() []*Config {
+	var frags []*Config
+	index := make(map[string]*Config)
+	for _, sec := range c.Sections {
+		if sec.include == "" {
+			continue
+		}
+		frag, ok := index[sec.include]
+		if !ok {
+			frag = &Config{Name: sec.include}
+			index[sec.include] = frag
+			frags = append(frags, frag)
+		}
+		frag.Sections = append(frag.Sections, sec)
+	}
+	for _, name := range c.included {
+		if _, ok := index[name]; !ok {
+			frags = append(frags, &Config{Name: name})
+		}
+	}
+	return frags
+}
@@ -0,0 +1,83 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInline(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("firewall", `
+config defaults
+	option input 'REJECT'
+
+config include 'user'
+	option path 'firewall_user'
+
+config zone 'lan'
+	option name 'lan'
+`)
+	require.NoError(err)
+	frag, err := Parse("firewall_user", `
+config rule 'ssh'
+	option name 'Allow-SSH'
+
+config rule 'web'
+	option name 'Allow-Web'
+`)
+	require.NoError(err)
+
+	require.NoError(cfg.Inline(func(sec *Section) (*Config, error) {
+		if sec.Type != "include" {
+			return nil, nil
+		}
+		return frag, nil
+	}))
+	names := make([]string, 0, len(cfg.Sections))
+	for _, sec := range cfg.Sections {
+		names = append(names, sec.Name)
+	}
+	assert.Equal([]string{"", "user", "ssh", "web", "lan"}, names)
+	assert.Equal("firewall_user", cfg.Get("ssh").Included())
+	assert.Empty(cfg.Get("lan").Included())
+
+	// changes to inlined sections end up in their fragment
+	cfg.Get("ssh").SetOption("dest_port", "22")
+	cfg.Del("web")
+	cfg.Add(NewSection("rule", "dns"))
+
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(err)
+	assert.NotContains(buf.String(), "Allow-SSH")
+	assert.Contains(buf.String(), "config include 'user'")
+	assert.Contains(buf.String(), "config rule 'dns'")
+
+	// cloning keeps track of inlined sections
+	frags := cfg.Clone().Fragments()
+	require.Len(frags, 1)
+	assert.Equal("firewall_user", frags[0].Name)
+	require.Len(frags[0].Sections, 1)
+	buf.Reset()
+	_, err = frags[0].WriteTo(&buf)
+	require.NoError(err)
+	assert.Contains(buf.String(), "option dest_port '22'")
+
+	// fragments without sections are still returned
+	cfg.Del("ssh")
+	frags = cfg.Fragments()
+	require.Len(frags, 1)
+	assert.Empty(frags[0].Sections)
+
+	errCustom := errors.New("custom")
+	err = NewConfig("firewall").Inline(func(*Section) (*Config, error) { return nil, errCustom })
+	assert.NoError(err) // no sections to resolve
+	err = cfg.Inline(func(*Section) (*Config, error) { return nil, errCustom })
+	assert.ErrorIs(err, errCustom)
+}
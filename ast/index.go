@@ -0,0 +1,198 @@
+package ast
+
+import "sync/atomic"
+
+// IndexThreshold is the number of sections in a config, from which on
+// lookups by name or "@type[index]" selector use an index instead of a
+// linear scan. The index is built on the first lookup and maintained by
+// Add, Del, Merge and the like.
+//
+// Smaller configs are always scanned, as this is faster for few sections
+// and needs no extra memory. Set IndexThreshold to 0 to disable indexes
+// entirely, e.g. on memory-constrained targets.
+//
+// Options are not indexed, as sections rarely have more than a few dozen.
+var IndexThreshold = 32
+
+// sectionIndex maps names and types of a config's sections to their
+// position in Config.Sections.
+type sectionIndex struct {
+	sections []*Section       // Config.Sections, when the index was built
+	retyped  uint64           // typeChanges, when the index was built
+	names    map[string]int   // name → position (first section with that name)
+	types    map[string][]int // type → positions
+	ordinals map[*Section]int // section → index within its type
+}
+
+// typeChanges counts the calls of Section.SetType. Sections don't know
+// their config, so a type change invalidates the indexes of all configs,
+// which are then rebuilt on their next lookup. Type changes are rare.
+var typeChanges atomic.Uint64
+
+// sectionIndexHolder is embedded in Config. Its zero value is an empty
+// holder.
+type sectionIndexHolder struct {
+	p atomic.Pointer[sectionIndex]
+}
+
+func newSectionIndex(sections []*Section) *sectionIndex {
+	idx := &sectionIndex{
+		sections: sections,
+		retyped:  typeChanges.Load(),
+		names:    make(map[string]int, len(sections)),
+		types:    make(map[string][]int),
+		ordinals: make(map[*Section]int, len(sections)),
+	}
+	for i, sec := range sections {
+		idx.add(i, sec)
+	}
+	return idx
+}
+
+func (idx *sectionIndex) add(i int, sec *Section) {
+	if sec.Name != "" {
+		if _, exists := idx.names[sec.Name]; !exists {
+			idx.names[sec.Name] = i
+		}
+	}
+	idx.ordinals[sec] = len(idx.types[sec.Type])
+	idx.types[sec.Type] = append(idx.types[sec.Type], i)
+}
+
+// sameSlice reports whether a and b share length, capacity and backing
+// array. This detects most modifications of Config.Sections which
+// bypass the methods of Config.
+func sameSlice[T any](a, b []T) bool {
+	if len(a) != len(b) || cap(a) != cap(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
+
+// sectionIndex returns an up-to-date index of c's sections, or nil if c
+// is too small to be indexed.
+func (c *Config) sectionIndex() *sectionIndex {
+	if IndexThreshold <= 0 || len(c.Sections) < IndexThreshold {
+		return nil
+	}
+	if idx := c.index.p.Load(); idx != nil && sameSlice(idx.sections, c.Sections) && idx.retyped == typeChanges.Load() {
+		return idx
+	}
+	idx := newSectionIndex(c.Sections)
+	c.index.p.Store(idx)
+	return idx
+}
+
+// indexAppended updates the index after a section was appended to
+// c.Sections.
+func (c *Config) indexAppended(before []*Section) {
+	idx := c.index.p.Load()
+	if idx == nil {
+		return
+	}
+	if !sameSlice(idx.sections, before) {
+		c.index.p.Store(nil)
+		return
+	}
+	i := len(c.Sections) - 1
+	idx.add(i, c.Sections[i])
+	idx.sections = c.Sections
+}
+
+// Reindex drops the index of c's sections. This is only needed after
+// changing Sections, or the Name or Type of its sections, directly
+// (instead of using the methods of Config, and Section.SetType). Lookups
+// missing the index are re-checked, but assigning Type may still shift
+// the selectors of other sections unnoticed. Reindex is cheap: the index
+// is rebuilt on the next lookup.
+func (c *Config) Reindex() {
+	c.index.p.Store(nil)
+}
+
+// lookupNamed returns the first section with the given name.
+func (c *Config) lookupNamed(name string) *Section {
+	idx := c.sectionIndex()
+	if idx != nil {
+		if i, ok := idx.names[name]; ok && c.Sections[i].Name == name {
+			return c.Sections[i]
+		}
+	}
+	// a miss is re-checked, as sections may have been renamed behind
+	// our back
+	for _, sec := range c.Sections {
+		if sec.Name == name {
+			if idx != nil {
+				c.Reindex()
+			}
+			return sec
+		}
+	}
+	return nil
+}
+
+// lookupType returns the idx-th section of the given type (idx may be
+// negative to count from the end). The second return value is the
+// number of sections of that type.
+func (c *Config) lookupType(typ string, idx int) (*Section, int) {
+	index := c.sectionIndex()
+	if index == nil {
+		count := c.count(typ)
+		if idx < 0 {
+			idx += count
+		}
+		if idx < 0 || idx >= count {
+			return nil, count
+		}
+		for i, n := 0, 0; i < len(c.Sections); i++ {
+			if c.Sections[i].Type == typ {
+				if idx == n {
+					return c.Sections[i], count
+				}
+				n++
+			}
+		}
+		return nil, count // not reached
+	}
+
+	positions := index.types[typ]
+	count := len(positions)
+	n := idx
+	if n < 0 {
+		n += count
+	}
+	if n < 0 || n >= count {
+		// a miss is re-checked, as types may have changed behind our back
+		if c.count(typ) == count {
+			return nil, count
+		}
+	} else if sec := c.Sections[positions[n]]; sec.Type == typ && index.ordinals[sec] == n {
+		// the ordinal check detects reordered sections (e.g. sorted in place)
+		return sec, count
+	}
+	c.Reindex()
+	return c.lookupType(typ, idx)
+}
+
+// ordinal returns the index of s among the sections of its type, or -1
+// if s is not part of c.
+func (c *Config) ordinal(s *Section) int {
+	if idx := c.sectionIndex(); idx != nil {
+		if n, ok := idx.ordinals[s]; ok {
+			if positions := idx.types[s.Type]; n < len(positions) && c.Sections[positions[n]] == s {
+				return n
+			}
+			c.Reindex()
+			return c.ordinal(s)
+		}
+	}
+	var i int
+	for _, sec := range c.Sections {
+		if sec == s {
+			return i
+		}
+		if sec.Type == s.Type {
+			i++
+		}
+	}
+	return -1
+}
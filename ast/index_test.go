@@ -0,0 +1,114 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newIndexTestConfig(n int) *Config {
+	cfg := NewConfig("test")
+	for i := 0; i < n; i++ {
+		cfg.Add(NewSection("host", fmt.Sprintf("host%d", i)))
+		cfg.Add(NewSection("rule", ""))
+	}
+	return cfg
+}
+
+// checkLookups compares indexed lookups with linear scans.
+func checkLookups(t *testing.T, cfg *Config) {
+	t.Helper()
+	assert := assert.New(t)
+
+	counts := make(map[string]int)
+	for _, sec := range cfg.Sections {
+		sel := fmt.Sprintf("@%s[%d]", sec.Type, counts[sec.Type])
+		counts[sec.Type]++
+		assert.Same(sec, cfg.Get(sel), sel)
+		if sec.Name != "" {
+			assert.Same(sec, cfg.Get(sec.Name), sec.Name)
+		} else {
+			assert.Equal(sel, cfg.SectionName(sec))
+		}
+	}
+	for typ, n := range counts {
+		assert.Nil(cfg.Get(fmt.Sprintf("@%s[%d]", typ, n)))
+		assert.NotNil(cfg.Get(fmt.Sprintf("@%s[-%d]", typ, n)))
+	}
+	assert.Nil(cfg.Get("missing"))
+}
+
+func TestSectionIndex(t *testing.T) {
+	cfg := newIndexTestConfig(50)
+	checkLookups(t, cfg)
+	assert.NotNil(t, cfg.index.p.Load())
+
+	t.Run("methods", func(t *testing.T) {
+		cfg.Add(NewSection("host", "added"))
+		cfg.Merge(NewSection("host", "merged"))
+		cfg.Insert(3, NewSection("rule", "inserted"))
+		cfg.Del("host7")
+		cfg.Del("@rule[2]")
+		_, err := cfg.Rename("host8", "renamed", nil)
+		assert.NoError(t, err)
+		assert.Nil(t, cfg.Get("host8"))
+		checkLookups(t, cfg)
+	})
+
+	t.Run("direct modification", func(t *testing.T) {
+		cfg.Sections = append(cfg.Sections, NewSection("host", "appended"))
+		checkLookups(t, cfg)
+
+		// keeps length and backing array
+		sort.SliceStable(cfg.Sections, func(i, j int) bool {
+			return cfg.Sections[i].Type > cfg.Sections[j].Type
+		})
+		checkLookups(t, cfg)
+
+		// renames and type changes are detected without Reindex
+		sec := cfg.Get("host9")
+		sec.Name = "direct"
+		assert.Nil(t, cfg.Get("host9"))
+		assert.Same(t, sec, cfg.Get("direct"))
+		checkLookups(t, cfg)
+
+		n := cfg.count("rule")
+		cfg.Get("host10").Type = "rule"
+		assert.NotNil(t, cfg.Get(fmt.Sprintf("@rule[%d]", n)))
+		checkLookups(t, cfg)
+	})
+
+	t.Run("type changes", func(t *testing.T) {
+		// SetType shifts the selectors of later sections of both types
+		cfg := newIndexTestConfig(50)
+		checkLookups(t, cfg)
+		sec := cfg.Get("@host[1]")
+		assert.NoError(t, sec.SetType("rule"))
+		assert.Equal(t, "rule", cfg.Get(cfg.SectionName(sec)).Type)
+		checkLookups(t, cfg)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		defer func(n int) { IndexThreshold = n }(IndexThreshold)
+		IndexThreshold = 0
+
+		cfg := newIndexTestConfig(50)
+		checkLookups(t, cfg)
+		assert.Nil(t, cfg.index.p.Load())
+	})
+}
+
+func BenchmarkSectionLookup(b *testing.B) {
+	cfg := newIndexTestConfig(2000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, sec := range cfg.Sections {
+			if cfg.Get(cfg.SectionName(sec)) != sec {
+				b.Fatal("section not found")
+			}
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package uci
+package ast
 
 import (
 	"bytes"
@@ -11,7 +11,7 @@ import (
 type item struct {
 	typ itemType
 	val string
-	pos int
+	pos int // offset of the item in the input (of the error for itemError)
 }
 
 type OptionType int
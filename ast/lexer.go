@@ -1,4 +1,4 @@
-package uci
+package ast
 
 import (
 	"fmt"
@@ -16,64 +16,70 @@ type stateFn func(*lexer) stateFn
 
 // lexer holds the state of the scanner.
 //
+// Items refer to slices of the input, so lexing does not allocate (apart
+// from error messages). Unlike in the talk, items are not passed through
+// a channel, but through a small queue, which is drained by nextItem
+// before running the next state.
+//
 // https://talks.golang.org/2011/lex.slide#22
 type lexer struct {
-	name  string    // used only in error reports
-	input string    // the string being scanned
-	start int       // start position of the current item
-	pos   int       // current position in the input
-	width int       // width of last rune read from input
-	state stateFn   // current state (see *lexer.nextItem())
-	items chan item // channel of scanned items
+	name  string  // used only in error reports
+	input string  // the string being scanned
+	start int     // start position of the current item
+	pos   int     // current position in the input
+	width int     // width of last rune read from input
+	state stateFn // current state (see *lexer.nextItem())
+	items []item  // queue of scanned items
+	head  int     // index of the next item in the queue
 }
 
 // lex starts the lexer
 //
 // https://talks.golang.org/2011/lex.slide#41
 func lex(name, input string) *lexer {
-	return &lexer{
+	l := &lexer{
 		name:  name,
 		input: input,
 		state: lexKeyword,
-		items: make(chan item, 2),
+		items: make([]item, 0, 2),
+	}
+	// skip a UTF-8 byte order mark, as written by some Windows editors
+	if strings.HasPrefix(input, byteOrderMark) {
+		l.pos = len(byteOrderMark)
+		l.start = l.pos
 	}
+	return l
 }
 
+const byteOrderMark = "\ufeff"
+
 // nextItem returns the next item from the input
 //
 // https://talks.golang.org/2011/lex.slide#41
 func (l *lexer) nextItem() item {
-	for l.state != nil {
-		select {
-		case it, ok := <-l.items:
-			if ok {
-				return it
-			}
+	for {
+		if l.head < len(l.items) {
+			it := l.items[l.head]
+			l.head++
+			return it
+		}
+		l.items, l.head = l.items[:0], 0
+		if l.state == nil {
 			return l.eof()
-
-		default:
-			s := l.state(l)
-			l.state = s
-			if s == nil {
-				return l.stop()
-			}
 		}
+		l.state = l.state(l)
 	}
-	return l.eof()
 }
 
-// stop closes the item channel, so that nextItem will (eventually)
-// return an EOF token.
+// stop terminates the lexer, and returns the next pending item (or an
+// EOF token).
 func (l *lexer) stop() item {
 	it := l.eof()
-	if l.items == nil {
-		return it
+	if l.head < len(l.items) {
+		it = l.items[l.head]
 	}
-	if len(l.items) > 0 {
-		it = <-l.items
-	}
-	close(l.items)
-	l.items = nil
+	l.items, l.head = l.items[:0], 0
+	l.state = nil
 	return it
 }
 
@@ -87,7 +93,7 @@ func (l *lexer) eof() item {
 // https://talks.golang.org/2011/lex.slide#25
 func (l *lexer) emit(t itemType) {
 	if l.pos > l.start {
-		l.items <- item{t, l.input[l.start:l.pos], l.pos}
+		l.items = append(l.items, item{t, l.input[l.start:l.pos], l.start})
 		l.start = l.pos
 	}
 }
@@ -95,7 +101,7 @@ func (l *lexer) emit(t itemType) {
 // emitString emits a string token. it removes the surrounding quotes.
 func (l *lexer) emitString(t itemType) {
 	if l.pos-1 > l.start+1 {
-		l.items <- item{t, l.input[l.start+1 : l.pos-1], l.pos}
+		l.items = append(l.items, item{t, l.input[l.start+1 : l.pos-1], l.start})
 		l.start = l.pos
 	}
 }
@@ -108,6 +114,12 @@ func (l *lexer) next() (r rune) {
 		l.width = 0
 		return eof
 	}
+	if c := l.input[l.pos]; c < utf8.RuneSelf {
+		// fast path for ASCII
+		l.width = 1
+		l.pos++
+		return rune(c)
+	}
 	r, l.width = utf8.DecodeRuneInString(l.input[l.pos:])
 	l.pos += l.width
 	return r
@@ -178,7 +190,19 @@ func (l *lexer) acceptIdent() {
 	}
 }
 
-// consumeWhitespace consumes (and ignores) space and tab characters.
+// acceptName consumes an unquoted section name [-_.a-zA-Z0-9].
+func (l *lexer) acceptName() {
+	for {
+		r := l.next()
+		if !(r == '.' || r == '-' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			l.backup()
+			break
+		}
+	}
+}
+
+// consumeWhitespace consumes (and ignores) space and tab characters, as
+// well as the carriage returns of CRLF line endings.
 func (l *lexer) consumeWhitespace() {
 	for isSpace(l.peek()) {
 		l.next()
@@ -187,7 +211,7 @@ func (l *lexer) consumeWhitespace() {
 }
 
 func isSpace(r rune) bool {
-	return r == ' ' || r == '\t'
+	return r == ' ' || r == '\t' || r == '\r'
 }
 
 // errorf returns an error token and terminates the scan by passing back
@@ -195,7 +219,7 @@ func isSpace(r rune) bool {
 //
 // https://talks.golang.org/2011/lex.slide#37
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- item{itemError, fmt.Sprintf(format, args...), l.pos}
+	l.items = append(l.items, item{itemError, fmt.Sprintf(format, args...), l.pos})
 	return nil
 }
 
@@ -205,7 +229,7 @@ func (l *lexer) rest() string {
 }
 
 func lexKeyword(l *lexer) stateFn {
-	l.acceptRun(" \t\n")
+	l.acceptRun(" \t\r\n")
 	l.ignore()
 	switch curr := l.rest(); {
 	case strings.HasPrefix(curr, "#"):
@@ -248,6 +272,7 @@ func lexPackageName(l *lexer) stateFn {
 	for {
 		switch r := l.next(); {
 		case r == eof || r == '\n':
+			l.backup()
 			return l.errorf("incomplete package name")
 		case isSpace(r):
 			l.ignore()
@@ -280,7 +305,7 @@ func lexOptionalName(l *lexer) stateFn {
 		l.backup()
 		return lexQuoted
 	default:
-		l.acceptIdent()
+		l.acceptName()
 		l.emit(itemString)
 	}
 	return lexKeyword
@@ -314,7 +339,8 @@ func lexValue(l *lexer) stateFn {
 	return lexUnquoted
 }
 
-// lexQuote scans a quoted string.
+// lexQuote scans a quoted string. Like libuci, it accepts line breaks
+// within quotes, which become part of the value.
 func lexQuoted(l *lexer) stateFn {
 	q := l.next()
 	if q != '"' && q != '\'' {
@@ -329,7 +355,9 @@ Loop:
 				break // switch
 			}
 			fallthrough
-		case eof, '\n':
+		case eof:
+			// report the opening quote, rather than the end of input
+			l.pos = l.start
 			return l.errorf("unterminated quoted string")
 		case q:
 			break Loop
@@ -350,8 +378,9 @@ Loop:
 			}
 			fallthrough
 		case eof:
+			l.backup()
 			return l.errorf("unterminated unquoted string")
-		case ' ', '\t', '#', '\n':
+		case ' ', '\t', '\r', '#', '\n':
 			break Loop
 		}
 	}
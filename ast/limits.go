@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned when parsing input, which exceeds the
+// Limits of the parser.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bound the resources spent on parsing a config, to protect
+// daemons parsing untrusted input (e.g. uploaded config files) from
+// memory exhaustion. Zero values mean no limit.
+type Limits struct {
+	MaxSize        int // of the input, in bytes
+	MaxSections    int // number of section statements
+	MaxOptions     int // number of option and list statements per section
+	MaxValueLength int // of option and list values, in bytes
+}
+
+// Parse works like the package function Parse, but fails with
+// ErrLimitExceeded as soon as the input exceeds a limit. Inputs larger
+// than MaxSize are rejected before parsing; the other errors are
+// returned as *PositionError, pointing to the offending statement.
+func (l Limits) Parse(name, input string) (*Config, error) {
+	return Parser{Limits: l}.Parse(name, input)
+}
+
+// ParsePositions works like Limits.Parse, but additionally returns the
+// location of each section and option in the input.
+func (l Limits) ParsePositions(name, input string) (*Config, *Positions, error) {
+	return Parser{Limits: l}.ParsePositions(name, input)
+}
+
+func (l Limits) checkSize(name, input string) error {
+	if l.MaxSize > 0 && len(input) > l.MaxSize {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrLimitExceeded, name, l.MaxSize)
+	}
+	return nil
+}
+
+// limiter enforces Limits while parsing.
+type limiter struct {
+	limits   *Limits
+	sections int
+	options  map[*Section]int // merged sections count as one
+}
+
+// section counts a section statement.
+func (l *limiter) section() error {
+	if l.limits.MaxSections <= 0 {
+		return nil
+	}
+	l.sections++
+	if l.sections > l.limits.MaxSections {
+		return fmt.Errorf("%w: more than %d sections", ErrLimitExceeded, l.limits.MaxSections)
+	}
+	return nil
+}
+
+// option counts an option or list statement of sec with the given value.
+func (l *limiter) option(sec *Section, val string) error {
+	if l.limits.MaxValueLength > 0 && len(val) > l.limits.MaxValueLength {
+		return fmt.Errorf("%w: value longer than %d bytes", ErrLimitExceeded, l.limits.MaxValueLength)
+	}
+	if l.limits.MaxOptions <= 0 {
+		return nil
+	}
+	if l.options == nil {
+		l.options = make(map[*Section]int)
+	}
+	l.options[sec]++
+	if l.options[sec] > l.limits.MaxOptions {
+		return fmt.Errorf("%w: more than %d options in section", ErrLimitExceeded, l.limits.MaxOptions)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package ast
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	limits := Limits{MaxSize: 200, MaxSections: 2, MaxOptions: 3, MaxValueLength: 8}
+	tt := []struct {
+		name    string
+		input   string
+		message string
+	}{
+		{"within limits", "config a 'x'\n\toption foo 'a'\n\tlist bar 'b'\n\tlist bar 'c'\n\nconfig a\n\toption foo '12345678'\n", ""},
+		{"size", strings.Repeat("#", 201), "limit exceeded: test is larger than 200 bytes"},
+		{"sections", "config a\nconfig a\nconfig a\n", "test:3:8: limit exceeded: more than 2 sections"},
+		{"options", "config a 'x'\n\tlist foo 'a'\n\tlist foo 'b'\n\tlist foo 'c'\n\tlist foo 'd'\n", "test:5:7: limit exceeded: more than 3 options in section"},
+		{"options in reopened section", "config a 'x'\n\toption foo 'a'\n\toption bar 'b'\nconfig a 'x'\n\toption baz 'c'\n\toption qux 'd'\n", "test:6:9: limit exceeded: more than 3 options in section"},
+		{"value length", "config a 'x'\n\toption foo '123456789'\n", "test:2:9: limit exceeded: value longer than 8 bytes"},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cfg, err := limits.Parse("test", tc.input)
+			_, pos, perr := limits.ParsePositions("test", tc.input)
+			assert.Equal(err, perr)
+			if tc.message == "" {
+				assert.NoError(err)
+				assert.Len(cfg.Sections, 2)
+				assert.NotNil(pos)
+				return
+			}
+			assert.True(errors.Is(err, ErrLimitExceeded))
+			assert.EqualError(err, tc.message)
+		})
+	}
+
+	// zero values mean no limit
+	_, err := Limits{}.Parse("test", "config a\n\toption foo '"+strings.Repeat("x", 1<<16)+"'\n")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,91 @@
+package ast
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidMAC is returned (wrapped) by ParseMAC and Section.GetMAC.
+var ErrInvalidMAC = errors.New("invalid MAC address")
+
+// ParseMAC parses a 48-bit MAC address, in any of the notations found
+// in configs: octets separated by colons or dashes (leading zeros may be
+// omitted, e.g. "0:1a:2b:3c:4d:5e"), groups of four hex digits separated
+// by dots ("001a.2b3c.4d5e"), or twelve hex digits. Hex digits may be
+// upper or lower case.
+//
+// The six bytes can be converted to a net.HardwareAddr; the package
+// doesn't import net, to keep it free of operating system dependencies.
+func ParseMAC(v string) ([]byte, error) {
+	s := strings.TrimSpace(v)
+	var digits string
+	switch {
+	case strings.ContainsAny(s, ":-"):
+		parts := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' })
+		if len(parts) != 6 || strings.Count(s, ":")+strings.Count(s, "-") != 5 {
+			return nil, fmt.Errorf("%w %q", ErrInvalidMAC, v)
+		}
+		for _, p := range parts {
+			if len(p) > 2 {
+				return nil, fmt.Errorf("%w %q", ErrInvalidMAC, v)
+			}
+			digits += strings.Repeat("0", 2-len(p)) + p
+		}
+	case strings.Contains(s, "."):
+		parts := strings.Split(s, ".")
+		if len(parts) != 3 || len(parts[0]) != 4 || len(parts[1]) != 4 {
+			return nil, fmt.Errorf("%w %q", ErrInvalidMAC, v)
+		}
+		digits = strings.Join(parts, "")
+	default:
+		digits = s
+	}
+	mac, err := hex.DecodeString(digits)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("%w %q", ErrInvalidMAC, v)
+	}
+	return mac, nil
+}
+
+// NormalizeMAC returns the canonical notation of a MAC address, as
+// written by uci and OpenWrt's tools: lower case octets separated by
+// colons (e.g. "00:1a:2b:3c:4d:5e").
+func NormalizeMAC(v string) (string, error) {
+	mac, err := ParseMAC(v)
+	if err != nil {
+		return "", err
+	}
+	return formatMAC(mac), nil
+}
+
+// formatMAC formats mac like net.HardwareAddr.String.
+func formatMAC(mac []byte) string {
+	var b strings.Builder
+	for i, octet := range mac {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02x", octet)
+	}
+	return b.String()
+}
+
+// GetMAC returns the last value of the named option as MAC address (see
+// ParseMAC), which can be assigned to a net.HardwareAddr. If the option
+// does not exist, ErrOptionNotFound is returned; invalid addresses
+// result in a *ValueError.
+func (s *Section) GetMAC(option string) ([]byte, error) {
+	opt := s.Get(option)
+	if opt == nil || len(opt.Values) == 0 {
+		return nil, fmt.Errorf("%s: %w", option, ErrOptionNotFound)
+	}
+	return parseValue(option, "MAC address", opt.Values[len(opt.Values)-1], ParseMAC)
+}
+
+// SetMAC sets the named option to mac (e.g. a net.HardwareAddr), in
+// canonical notation (see NormalizeMAC), and returns it.
+func (s *Section) SetMAC(option string, mac []byte) *Option {
+	return s.SetOption(option, formatMAC(mac))
+}
@@ -0,0 +1,63 @@
+package ast
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMAC(t *testing.T) {
+	for _, in := range []string{
+		"00:1a:2b:3c:4d:5e",
+		"00:1A:2B:3C:4D:5E",
+		"00-1a-2b-3c-4d-5e",
+		"0:1a:2b:3c:4d:5e",
+		"001a.2b3c.4d5e",
+		"001A2B3C4D5E",
+		" 00:1a:2b:3c:4d:5e ",
+	} {
+		mac, err := NormalizeMAC(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, "00:1a:2b:3c:4d:5e", mac, in)
+	}
+	for _, in := range []string{
+		"",
+		"00:1a:2b:3c:4d",
+		"00:1a:2b:3c:4d:5e:6f",
+		"00::1a:2b:3c:4d:5e",
+		"000:1a:2b:3c:4d:5e",
+		"00:1a:2b:3c:4d:5g",
+		"001a.2b3c.4d5e.6f70",
+		"001a2b3c4d",
+		"00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00", // IPoIB
+	} {
+		_, err := ParseMAC(in)
+		assert.True(t, errors.Is(err, ErrInvalidMAC), "%q: got %v", in, err)
+	}
+}
+
+func TestSectionMAC(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("dhcp", "config host\n\toption mac '00-1A-2B-3C-4D-5E'\n\toption name 'x'\n")
+	require.NoError(t, err)
+	s := cfg.Sections[0]
+
+	var mac net.HardwareAddr
+	mac, err = s.GetMAC("mac")
+	assert.NoError(err)
+	assert.Equal(net.HardwareAddr{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}, mac)
+
+	_, err = s.GetMAC("name")
+	var verr *ValueError
+	assert.True(errors.As(err, &verr))
+	assert.True(errors.Is(err, ErrInvalidMAC))
+	_, err = s.GetMAC("missing")
+	assert.True(errors.Is(err, ErrOptionNotFound))
+
+	s.SetMAC("mac", mac)
+	assert.Equal([]string{"00:1a:2b:3c:4d:5e"}, s.Get("mac").Values)
+}
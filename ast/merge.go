@@ -0,0 +1,77 @@
+package ast
+
+// MergeOptions merges the options of other into s. Options existing in
+// both sections get the values of other merged in (see MergeValues).
+// New options keep their position relative to their neighbours in
+// other: they are inserted after the option preceding them in other,
+// or before the option following them, if they come first. Options of
+// sections without common options are appended.
+func (s *Section) MergeOptions(other *Section) {
+	anchor := -1 // index in s of the option processed last
+	for i, o := range other.Options {
+		if j := s.index(o.Name); j >= 0 {
+			s.Options[j].MergeValues(o.Values...)
+			anchor = j
+			continue
+		}
+		pos := anchor + 1
+		if anchor < 0 {
+			pos = len(s.Options)
+			for _, next := range other.Options[i+1:] {
+				if j := s.index(next.Name); j >= 0 {
+					pos = j
+					break
+				}
+			}
+		}
+		s.Insert(pos, o)
+		anchor = pos
+	}
+}
+
+// index returns the position of the named option, or -1.
+func (s *Section) index(name string) int {
+	for i, opt := range s.Options {
+		if opt.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// MergeConfig merges the sections of other into c. Sections are matched
+// by an explicit key: named sections by their name, unnamed sections by
+// their "@type[index]" selector within other (so that the n-th unnamed
+// section of a type is merged into the n-th section of that type in c).
+// Matched sections get their options merged (see MergeOptions). Other
+// sections are inserted after the section preceding them in other, or
+// appended, if there is none.
+//
+// The sections of other are not copied; don't use other afterwards.
+func (c *Config) MergeConfig(other *Config) {
+	anchor := -1 // index in c of the section processed last
+	for _, s := range other.Sections {
+		if sec := c.find(other.SectionName(s)); sec != nil {
+			sec.MergeOptions(s)
+			anchor = c.position(sec)
+			continue
+		}
+		if anchor < 0 {
+			c.Add(s)
+			anchor = len(c.Sections) - 1
+			continue
+		}
+		anchor++
+		c.Insert(anchor, s)
+	}
+}
+
+// position returns the index of s in c.Sections, or -1.
+func (c *Config) position(s *Section) int {
+	for i, sec := range c.Sections {
+		if sec == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,113 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		dst, src string
+		merged   string
+	}{
+		{"after predecessor", "a b c", "b x", "a b x c"},
+		{"before successor", "a b c", "x c", "a b x c"},
+		{"chain", "a d", "a b c d", "a b c d"},
+		{"nothing in common", "a b", "x y", "a b x y"},
+		{"empty", "", "x y", "x y"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := sectionWith(tc.dst)
+			dst.MergeOptions(sectionWith(tc.src))
+			assert.Equal(t, tc.merged, optionNames(dst))
+		})
+	}
+
+	dst := NewSection("zone", "lan")
+	dst.Add(NewOption("network", TypeList, "lan"))
+	src := NewSection("zone", "lan")
+	src.Add(NewOption("network", TypeList, "lan", "guest"))
+	dst.MergeOptions(src)
+	assert.Equal(t, []string{"lan", "guest"}, dst.Get("network").Values)
+}
+
+// sectionWith returns a section with options named by the words of s.
+func sectionWith(s string) *Section {
+	sec := NewSection("test", "")
+	for _, name := range strings.Fields(s) {
+		sec.Add(NewOption(name, TypeOption, name))
+	}
+	return sec
+}
+
+func optionNames(s *Section) string {
+	names := make([]string, len(s.Options))
+	for i, opt := range s.Options {
+		names[i] = opt.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMergeConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dst, err := Parse("firewall", `
+config defaults
+	option input 'ACCEPT'
+
+config zone 'lan'
+	option input 'ACCEPT'
+
+config rule
+	option name 'Allow-DHCP'
+
+config rule
+	option name 'Allow-Ping'
+	option proto 'icmp'
+`)
+	require.NoError(err)
+	src, err := Parse("firewall", `
+config zone 'lan'
+	option network 'lan'
+
+config zone 'guest'
+	option input 'REJECT'
+
+config rule
+	option target 'ACCEPT'
+
+config rule
+	option family 'ipv4'
+	option proto 'icmp'
+
+config rule
+	option name 'Allow-SSH'
+`)
+	require.NoError(err)
+
+	dst.MergeConfig(src)
+	assert.Equal([]string{"@defaults[0]", "lan", "guest", "@rule[0]", "@rule[1]", "@rule[2]"}, sectionNames(dst))
+	assert.Equal("input network", optionNames(dst.Get("lan")))
+	assert.Equal("name target", optionNames(dst.Get("@rule[0]")))
+	assert.Equal("name family proto", optionNames(dst.Get("@rule[1]")))
+	assert.Equal("Allow-SSH", dst.Get("@rule[2]").LastValue("name"))
+
+	// unnamed sections of other configs are appended by Merge
+	cfg := NewConfig("firewall")
+	cfg.Add(NewSection("rule", ""))
+	cfg.Merge(NewSection("rule", ""))
+	assert.Len(cfg.Sections, 2)
+}
+
+func sectionNames(c *Config) []string {
+	names := make([]string, len(c.Sections))
+	for i, sec := range c.Sections {
+		names[i] = c.SectionName(sec)
+	}
+	return names
+}
@@ -0,0 +1,70 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SectionMeta is the metadata of a section, which is computed from its
+// position in the config. The JSON keys match those of "uci show -X"
+// style dumps, and of the ubus uci object.
+type SectionMeta struct {
+	// Name is the section name or, for unnamed sections, the ID libuci
+	// assigns (see AnonymousID), as printed by "uci show -X".
+	Name string `json:".name"`
+
+	// Selector is the section name or, for unnamed sections, the
+	// "@type[index]" selector (see Config.SectionName).
+	Selector string `json:".selector"`
+
+	Type      string `json:".type"`
+	Anonymous bool   `json:".anonymous"`
+	Index     int    `json:".index"`      // position in the config, from 0
+	TypeIndex int    `json:".type_index"` // position among the sections of its type, from 0
+}
+
+// Meta returns the metadata of s, and false if s is not part of c.
+func (c *Config) Meta(s *Section) (SectionMeta, bool) {
+	for i, sec := range c.Sections {
+		if sec == s {
+			return c.meta(s, i, c.ordinal(s)), true
+		}
+	}
+	return SectionMeta{}, false
+}
+
+func (c *Config) meta(s *Section, index, typeIndex int) SectionMeta {
+	m := SectionMeta{
+		Name:      s.Name,
+		Selector:  s.Name,
+		Type:      s.Type,
+		Anonymous: s.Name == "",
+		Index:     index,
+		TypeIndex: typeIndex,
+	}
+	if m.Anonymous {
+		m.Name = anonymousID(index+1, s.Type)
+		m.Selector = fmt.Sprintf("@%s[%d]", s.Type, typeIndex)
+	}
+	return m
+}
+
+// MarshalJSON implements json.Marshaler. Sections carry their metadata
+// (see SectionMeta) in an additional "meta" object, which is ignored
+// when decoding.
+func (c *Config) MarshalJSON() ([]byte, error) {
+	type section struct {
+		*Section
+		Meta SectionMeta `json:"meta"`
+	}
+	var sections []section
+	counts := make(map[string]int)
+	for i, s := range c.Sections {
+		sections = append(sections, section{s, c.meta(s, i, counts[s.Type])})
+		counts[s.Type]++
+	}
+	return json.Marshal(struct {
+		Name     string    `json:"name"`
+		Sections []section `json:"sections,omitempty"`
+	}{c.Name, sections})
+}
@@ -0,0 +1,60 @@
+package ast
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectionMeta(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("firewall", `
+config defaults
+	option input 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+
+config zone
+	option name 'wan'
+`)
+	require.NoError(err)
+
+	for i, want := range []SectionMeta{
+		{Name: "cfg01e63d", Selector: "@defaults[0]", Type: "defaults", Anonymous: true, Index: 0, TypeIndex: 0},
+		{Name: "lan", Selector: "lan", Type: "zone", Index: 1, TypeIndex: 0},
+		{Name: "cfg03dc81", Selector: "@zone[1]", Type: "zone", Anonymous: true, Index: 2, TypeIndex: 1},
+	} {
+		m, ok := cfg.Meta(cfg.Sections[i])
+		assert.True(ok)
+		assert.Equal(want, m)
+		if want.Anonymous {
+			assert.Equal(AnonymousID(cfg, cfg.Sections[i]), m.Name)
+		}
+	}
+	_, ok := cfg.Meta(NewSection("zone", ""))
+	assert.False(ok)
+
+	b, err := json.Marshal(cfg)
+	require.NoError(err)
+	var dump struct {
+		Sections []struct {
+			Meta map[string]any `json:"meta"`
+		} `json:"sections"`
+	}
+	require.NoError(json.Unmarshal(b, &dump))
+	require.Len(dump.Sections, 3)
+	assert.Equal(map[string]any{
+		".name": "cfg03dc81", ".selector": "@zone[1]", ".type": "zone",
+		".anonymous": true, ".index": float64(2), ".type_index": float64(1),
+	}, dump.Sections[2].Meta)
+
+	// the metadata is ignored when decoding
+	var decoded Config
+	require.NoError(json.Unmarshal(b, &decoded))
+	assert.Equal(cfg.Hash(), decoded.Hash())
+}
@@ -0,0 +1,129 @@
+package ast
+
+// Minimize returns the parts of cfg which differ from defaults, e.g. the
+// configs of a freshly flashed device, so that only they need to be kept
+// in backups or version control. Expand reverses it.
+//
+// Sections are matched like by Diff: by name, or by their "@type[index]"
+// selector. Options with the same type and values as in the matching
+// section are left out, and so are matching sections without other
+// options. Since selectors count all sections of a type, matching
+// sections are only left out after the last section of their type which
+// differs; before it, they are kept without options, so that the
+// selectors of the following sections stay the same.
+//
+// What defaults has, but cfg doesn't, can't be expressed by a config;
+// the paths of such sections and options are returned as deleted.
+// Named sections whose type changed are deleted, and kept completely.
+// Neither cfg nor defaults are changed.
+func Minimize(cfg, defaults *Config) (*Config, []Path) {
+	minimal := NewConfig(cfg.Name)
+	var deleted []Path
+
+	matched := make(map[*Section]bool, len(defaults.Sections))
+	pending := make(map[string][]*Section) // placeholders by type
+	add := func(sec *Section) {
+		for _, s := range pending[sec.Type] {
+			minimal.Add(s)
+		}
+		delete(pending, sec.Type)
+		minimal.Add(sec)
+	}
+	for _, sec := range cfg.Sections {
+		name := cfg.SectionName(sec)
+		def := defaults.find(name)
+		if def != nil && def.Type != sec.Type {
+			def = nil // reported as deleted below
+		}
+		if def == nil {
+			add(sec.Clone())
+			continue
+		}
+		matched[def] = true
+
+		diff := NewSection(sec.Type, sec.Name)
+		for _, opt := range sec.Options {
+			if o := def.Get(opt.Name); o == nil || o.Type != opt.Type || !equalValues(o.Values, opt.Values) {
+				diff.Add(opt.Clone())
+			}
+		}
+		for _, o := range def.Options {
+			if sec.Get(o.Name) == nil {
+				deleted = append(deleted, Path{Config: cfg.Name, Section: name, Option: o.Name})
+			}
+		}
+
+		if len(diff.Options) == 0 {
+			pending[sec.Type] = append(pending[sec.Type], diff)
+		} else {
+			add(diff)
+		}
+	}
+
+	for _, def := range defaults.Sections {
+		if !matched[def] {
+			deleted = append(deleted, Path{Config: cfg.Name, Section: defaults.SectionName(def)})
+		}
+	}
+	return minimal, deleted
+}
+
+// Expand reverses Minimize: it returns a copy of defaults with the
+// deleted sections and options removed, and the sections of minimal
+// merged in. Options of matching sections replace those of defaults;
+// other sections are inserted after the section preceding them in
+// minimal, or appended. The order of sections and options may differ
+// from the minimized config. Neither minimal nor defaults are changed.
+func Expand(minimal, defaults *Config, deleted ...Path) *Config {
+	cfg := defaults.Clone()
+	cfg.Name = minimal.Name
+
+	// resolve all selectors before sections are removed
+	targets := make([]*Section, len(minimal.Sections))
+	for i, sec := range minimal.Sections {
+		if t := cfg.find(minimal.SectionName(sec)); t != nil && t.Type == sec.Type {
+			targets[i] = t
+		}
+	}
+	removed := make(map[*Section]bool)
+	for _, p := range deleted {
+		sec := cfg.find(p.Section)
+		switch {
+		case sec == nil:
+		case p.Option == "":
+			removed[sec] = true
+		default:
+			sec.Del(p.Option)
+		}
+	}
+	sections := cfg.Sections[:0]
+	for _, sec := range cfg.Sections {
+		if !removed[sec] {
+			sections = append(sections, sec)
+		}
+	}
+	cfg.Sections = sections
+	cfg.Reindex()
+
+	anchor := -1 // index in cfg of the section processed last
+	for i, sec := range minimal.Sections {
+		target := targets[i]
+		if target == nil || removed[target] {
+			anchor++
+			if anchor == 0 {
+				anchor = len(cfg.Sections)
+			}
+			cfg.Insert(anchor, sec.Clone())
+			continue
+		}
+		for _, opt := range sec.Options {
+			if prev := target.Get(opt.Name); prev != nil {
+				*prev = *opt.Clone()
+			} else {
+				target.Add(opt.Clone())
+			}
+		}
+		anchor = cfg.position(target)
+	}
+	return cfg
+}
@@ -0,0 +1,165 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const minimizeDefaults = `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+	option forward 'REJECT'
+	option synflood_protect '1'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	option input 'ACCEPT'
+
+config zone
+	option name 'wan'
+	list network 'wan'
+	list network 'wan6'
+	option input 'REJECT'
+	option masq '1'
+
+config forwarding
+	option src 'lan'
+	option dest 'wan'
+
+config rule
+	option name 'Allow-Ping'
+	option src 'wan'
+	option target 'ACCEPT'
+`
+
+func TestMinimize(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defaults, err := Parse("firewall", minimizeDefaults)
+	require.NoError(err)
+	cfg, err := Parse("firewall", `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+	option forward 'REJECT'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	option input 'ACCEPT'
+
+config zone
+	option name 'wan'
+	list network 'wan'
+	option input 'REJECT'
+	option masq '1'
+
+config forwarding
+	option src 'lan'
+	option dest 'wan'
+
+config redirect
+	option name 'ssh'
+	option src_dport '2222'
+`)
+	require.NoError(err)
+
+	minimal, deleted := Minimize(cfg, defaults)
+	var buf bytes.Buffer
+	_, err = minimal.WriteTo(&buf)
+	require.NoError(err)
+	assert.Equal(`
+config zone 'lan'
+
+config zone
+	list network 'wan'
+
+config redirect
+	option name 'ssh'
+	option src_dport '2222'
+
+`, buf.String())
+	assert.Equal([]Path{
+		{Config: "firewall", Section: "@defaults[0]", Option: "synflood_protect"},
+		{Config: "firewall", Section: "@rule[0]"},
+	}, deleted)
+
+	expanded := Expand(minimal, defaults, deleted...)
+	assert.Equal(cfg.Hash(), expanded.Hash())
+	assert.Equal("firewall", expanded.Name)
+
+	// the inputs are unchanged
+	assert.Len(defaults.Sections, 5)
+	assert.Len(cfg.Sections, 5)
+
+	// identical configs minimize to nothing
+	minimal, deleted = Minimize(defaults, defaults)
+	assert.Empty(minimal.Sections)
+	assert.Empty(deleted)
+	assert.Equal(defaults.Hash(), Expand(minimal, defaults).Hash())
+}
+
+func TestMinimizeChangedType(t *testing.T) {
+	defaults, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+
+config device
+	option name 'br-lan'
+`)
+	require.NoError(t, err)
+	cfg, err := Parse("network", `
+config device 'lan'
+	option name 'br-lan'
+
+config device
+	option name 'br-lan'
+
+config device
+	option name 'eth1'
+	option mtu '1500'
+`)
+	require.NoError(t, err)
+
+	// the named device shifts the selectors of the unnamed ones
+	minimal, deleted := Minimize(cfg, defaults)
+	assert.Equal(t, []Path{{Config: "network", Section: "lan"}, {Config: "network", Section: "@device[0]"}}, deleted)
+	assert.Len(t, minimal.Sections, 3)
+	assert.Equal(t, cfg.Hash(), Expand(minimal, defaults, deleted...).Hash())
+}
+
+func TestMinimizePlaceholders(t *testing.T) {
+	defaults, err := Parse("dhcp", `
+config host
+	option name 'a'
+
+config host
+	option name 'b'
+`)
+	require.NoError(t, err)
+	cfg, err := Parse("dhcp", `
+config host
+	option name 'a'
+
+config host
+	option name 'b'
+
+config host
+	option name 'c'
+`)
+	require.NoError(t, err)
+
+	minimal, deleted := Minimize(cfg, defaults)
+	assert.Empty(t, deleted)
+	require.Len(t, minimal.Sections, 3)
+	assert.Empty(t, minimal.Sections[0].Options)
+	assert.Empty(t, minimal.Sections[1].Options)
+	assert.Equal(t, "c", minimal.Sections[2].LastValue("name"))
+	assert.Equal(t, cfg.Hash(), Expand(minimal, defaults).Hash())
+}
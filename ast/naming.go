@@ -0,0 +1,93 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A NamingPolicy chooses the name of a section, which is created by a
+// higher-level API (e.g. a builder or reconciler), before it is added
+// to c. An empty name keeps the section anonymous.
+//
+// Names returned by a policy are sanitized and made unique by
+// Config.AddNamed, so policies don't need to care about that.
+type NamingPolicy func(c *Config, s *Section) string
+
+// Anonymous is the naming policy, which keeps all sections anonymous.
+// This matches what "uci add" does.
+func Anonymous(*Config, *Section) string {
+	return ""
+}
+
+// NameFromOption names sections after the value of an option, e.g.
+// NameFromOption("name") names a host section with "option name 'nas'"
+// "nas". Sections without the option stay anonymous.
+func NameFromOption(option string) NamingPolicy {
+	return func(_ *Config, s *Section) string {
+		return s.LastValue(option)
+	}
+}
+
+// WithPrefix prefixes the names chosen by p with prefix, e.g. to mark
+// the owner of created sections. Sections left anonymous by p are named
+// after their type ("<prefix><type>"), so that all sections created by
+// an owner are addressable. A nil p is treated as Anonymous.
+func WithPrefix(prefix string, p NamingPolicy) NamingPolicy {
+	return func(c *Config, s *Section) string {
+		var name string
+		if p != nil {
+			name = p(c, s)
+		}
+		if name == "" {
+			name = s.Type
+		}
+		return prefix + name
+	}
+}
+
+// AddNamed names s using the policy p (if s has no name yet), and adds
+// it to c. Characters not allowed in section names are replaced with
+// underscores, and a numeric suffix ("_2", "_3", ...) is appended, if
+// the name is already taken. A nil p is treated as Anonymous.
+func (c *Config) AddNamed(s *Section, p NamingPolicy) *Section {
+	if s.Name == "" && p != nil {
+		s.Name = c.uniqueName(sanitizeName(p(c, s)))
+	}
+	return c.Add(s)
+}
+
+// AddUnnamed appends an unnamed section of type typ, like
+// "uci add <config> <type>", and marks c as tainted. It returns the
+// section and its "@type[index]" selector. The ID libuci generates for
+// the section ("cfgXXXXXX", as printed by "uci add" and used in delta
+// files) is AnonymousID(c, s); Get accepts both.
+func (c *Config) AddUnnamed(typ string) (*Section, string) {
+	s := c.Add(NewSection(typ, ""))
+	c.SetTainted()
+	return s, c.SectionName(s)
+}
+
+// sanitizeName replaces all characters of name, which are not allowed
+// in section names, with underscores.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// uniqueName appends a numeric suffix to name, if c already contains a
+// section with that name.
+func (c *Config) uniqueName(name string) string {
+	if name == "" || c.getNamed(name) == nil {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := name + "_" + strconv.Itoa(i)
+		if c.getNamed(candidate) == nil {
+			return candidate
+		}
+	}
+}
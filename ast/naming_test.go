@@ -0,0 +1,72 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddNamed(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("dhcp", `
+config host 'nas'
+	option name 'nas'
+`)
+	assert.NoError(err)
+
+	host := func(name string) *Section {
+		s := NewSection("host", "")
+		if name != "" {
+			s.Add(NewOption("name", TypeOption, name))
+		}
+		return s
+	}
+
+	tt := []struct {
+		policy   NamingPolicy
+		section  *Section
+		expected string
+	}{
+		{nil, host("x"), ""},
+		{Anonymous, host("x"), ""},
+		{NameFromOption("name"), host("printer"), "printer"},
+		{NameFromOption("name"), host("nas"), "nas_2"},
+		{NameFromOption("name"), host("nas"), "nas_3"},
+		{NameFromOption("name"), host("Jane's phone"), "Jane_s_phone"},
+		{NameFromOption("name"), host(""), ""},
+		{WithPrefix("agent_", NameFromOption("name")), host("tv"), "agent_tv"},
+		{WithPrefix("agent_", nil), host("tv"), "agent_host"},
+		{WithPrefix("agent_", nil), host("tv"), "agent_host_2"},
+		{NameFromOption("name"), NewSection("host", "fixed"), "fixed"},
+	}
+	for _, tc := range tt {
+		s := cfg.AddNamed(tc.section, tc.policy)
+		assert.Equal(tc.expected, s.Name)
+		assert.Same(s, cfg.Sections[len(cfg.Sections)-1])
+	}
+}
+
+func TestAddUnnamed(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("firewall", `
+config rule
+	option name 'a'
+
+config zone 'lan'
+`)
+	assert.NoError(err)
+
+	s, sel := cfg.AddUnnamed("rule")
+	assert.Equal("@rule[1]", sel)
+	assert.Same(s, cfg.Get(sel))
+	assert.Equal("", s.Name)
+	assert.True(cfg.Tainted())
+	id := AnonymousID(cfg, s)
+	assert.Equal(anonymousID(3, "rule"), id)
+	assert.Same(s, cfg.Get(id))
+
+	_, sel = cfg.AddUnnamed("redirect")
+	assert.Equal("@redirect[0]", sel)
+}
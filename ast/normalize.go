@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"sort"
+	"strings"
+)
+
+// Normalize rewrites the config into a canonical form, so that two
+// semantically equal configs produce the same output when serialized.
+// This is useful for diffing and hashing.
+//
+// In particular, Normalize
+//   - sorts sections by type. Within a type, unnamed sections come first
+//     (keeping their relative order, hence "@type[n]" selectors for
+//     unnamed sections stay valid, as long as no named section of the
+//     same type preceded them), followed by named sections sorted by name,
+//   - collapses options with the same name in a section (the last
+//     TypeOption value wins, list values are merged),
+//   - trims surrounding whitespace from values, and removes duplicate
+//     list values,
+//   - removes options without values and sets the option type based on
+//     the number of values (TypeList for more than one value, TypeOption
+//     otherwise).
+//
+// Like the other Config methods, Normalize does not mark the config as
+// tainted.
+func (c *Config) Normalize() {
+	sort.SliceStable(c.Sections, func(i, j int) bool {
+		a, b := c.Sections[i], c.Sections[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name == "" || b.Name == "" {
+			return a.Name == "" && b.Name != ""
+		}
+		return a.Name < b.Name
+	})
+	c.Reindex()
+
+	for _, sec := range c.Sections {
+		sec.normalize()
+	}
+}
+
+func (s *Section) normalize() {
+	options := make([]*Option, 0, len(s.Options))
+	byName := make(map[string]*Option, len(s.Options))
+
+	for _, opt := range s.Options {
+		values := make([]string, 0, len(opt.Values))
+		for _, v := range opt.Values {
+			values = append(values, strings.TrimSpace(v))
+		}
+
+		if have, exists := byName[opt.Name]; exists {
+			if opt.Type == TypeList {
+				have.Values = append(have.Values, values...)
+			} else {
+				have.Values = values
+			}
+			continue
+		}
+
+		o := NewOption(opt.Name, opt.Type, values...)
+		byName[opt.Name] = o
+		options = append(options, o)
+	}
+
+	s.Options = options[:0]
+	for _, opt := range options {
+		opt.Values = dedup(opt.Values)
+		switch len(opt.Values) {
+		case 0:
+			continue
+		case 1:
+			opt.Type = TypeOption
+		default:
+			opt.Type = TypeList
+		}
+		s.Options = append(s.Options, opt)
+	}
+}
+
+// dedup removes duplicate values, keeping the first occurrence.
+func dedup(values []string) []string {
+	have := make(map[string]struct{}, len(values))
+	res := values[:0]
+	for _, v := range values {
+		if _, exists := have[v]; exists {
+			continue
+		}
+		have[v] = struct{}{}
+		res = append(res, v)
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	cfg, err := Parse("test", `
+config foo 'zzz'
+	option a ' 1 '
+	list b '2'
+	list b '3'
+	list b '2'
+
+config bar
+	list single 'x'
+
+config foo
+	option pos '0'
+
+config foo 'aaa'
+
+config foo
+	option pos '1'
+`)
+	require.NoError(t, err)
+
+	// not produced by the parser, but by API users
+	sec := cfg.Get("zzz")
+	sec.Add(NewOption("a", TypeOption, "4"))
+	sec.Add(NewOption("c", TypeOption, "5", "6"))
+	sec.Add(NewOption("d", TypeList))
+
+	cfg.Normalize()
+
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `
+config bar
+	option single 'x'
+
+config foo
+	option pos '0'
+
+config foo
+	option pos '1'
+
+config foo 'aaa'
+
+config foo 'zzz'
+	option a '4'
+	list b '2'
+	list b '3'
+	list c '5'
+	list c '6'
+
+`, buf.String())
+
+	// idempotency
+	before := buf.String()
+	cfg.Normalize()
+	buf.Reset()
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, before, buf.String())
+}
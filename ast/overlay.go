@@ -0,0 +1,57 @@
+package ast
+
+// Overlay returns a new config, which consists of the sections of
+// defaults, overridden by the sections of overrides. Neither input is
+// modified.
+//
+// Named sections are matched by name, unnamed sections by type and
+// position among the unnamed sections of that type (i.e. the second
+// unnamed "rule" in overrides overrides the second unnamed "rule" in
+// defaults). Options of matching sections are replaced individually; if
+// the section types differ, the whole section is replaced. Unmatched
+// sections of overrides are appended.
+//
+// The result is named after overrides.
+func Overlay(defaults, overrides *Config) *Config {
+	result := defaults.Clone()
+	result.Name = overrides.Name
+	result.tainted = false
+
+	// count unnamed sections per type, to match them positionally
+	seen := make(map[string]int)
+	for _, s := range overrides.Sections {
+		var target *Section
+		if s.Name != "" {
+			target = result.getNamed(s.Name)
+		} else {
+			target = nthUnnamed(result, s.Type, seen[s.Type])
+			seen[s.Type]++
+		}
+
+		switch {
+		case target == nil:
+			result.Add(s.Clone())
+		case target.Type != s.Type:
+			*target = *s.Clone()
+		default:
+			for _, o := range s.Options {
+				target.SaveOrInsert(o.Clone())
+			}
+		}
+	}
+	return result
+}
+
+// nthUnnamed returns the n-th unnamed section of type typ, or nil.
+func nthUnnamed(c *Config, typ string, n int) *Section {
+	for _, s := range c.Sections {
+		if s.Name != "" || s.Type != typ {
+			continue
+		}
+		if n == 0 {
+			return s
+		}
+		n--
+	}
+	return nil
+}
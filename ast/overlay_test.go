@@ -0,0 +1,85 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlay(t *testing.T) {
+	defaults, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	option netmask '255.255.255.0'
+
+config interface 'wan'
+	option proto 'dhcp'
+
+config route
+	option target '10.0.0.0/8'
+
+config route
+	option target '172.16.0.0/12'
+`)
+	require.NoError(t, err)
+
+	overrides, err := Parse("network", `
+config interface 'lan'
+	option ipaddr '10.1.1.1'
+	list dns '1.1.1.1'
+
+config device 'wan'
+	option name 'eth1'
+
+config route
+
+config route
+	option gateway '10.1.1.254'
+
+config route
+	option target '192.168.0.0/16'
+
+config interface 'guest'
+	option proto 'static'
+`)
+	require.NoError(t, err)
+	before := defaults.Hash()
+
+	result := Overlay(defaults, overrides)
+	assert.Equal(t, before, defaults.Hash(), "defaults modified")
+
+	var buf bytes.Buffer
+	_, err = result.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '10.1.1.1'
+	option netmask '255.255.255.0'
+	list dns '1.1.1.1'
+
+config device 'wan'
+	option name 'eth1'
+
+config route
+	option target '10.0.0.0/8'
+
+config route
+	option target '172.16.0.0/12'
+	option gateway '10.1.1.254'
+
+config route
+	option target '192.168.0.0/16'
+
+config interface 'guest'
+	option proto 'static'
+
+`, buf.String())
+
+	// the result must not share options with overrides
+	result.Get("lan").Get("ipaddr").Values[0] = "x"
+	assert.Equal(t, "10.1.1.1", overrides.Get("lan").LastValue("ipaddr"))
+}
@@ -0,0 +1,455 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scanner is intertwined with lexer and groups lexemes into token
+// (which is a typed list of items).
+//
+// Refer to the implementation of lexer for hints about the design.
+// The scanner is strongly modeled after the same principles, although
+// a bit less elegant at times.
+type scanner struct {
+	lexer   *lexer
+	state   scanFn
+	last    item    // last item read from the lexer, but deffered by the state
+	hasLast bool    // whether last is set
+	pos     int     // position of the item returned by the last call to next
+	curr    []item  // accepted items
+	tokens  []token // queue of scanned tokens
+	head    int     // index of the next token in the queue
+}
+
+func scan(name, input string) *scanner {
+	return &scanner{
+		lexer:  lex(name, input),
+		state:  scanStart,
+		curr:   make([]item, 0, 3),
+		tokens: make([]token, 0, 2),
+	}
+}
+
+// nextToken returns the next token. Its items are only valid until the
+// next call, as their storage is reused.
+func (s *scanner) nextToken() token {
+	for {
+		if s.head < len(s.tokens) {
+			tok := s.tokens[s.head]
+			s.head++
+			return tok
+		}
+		s.tokens, s.head = s.tokens[:0], 0
+		if s.state == nil {
+			return s.eof()
+		}
+		s.state = s.state(s)
+	}
+}
+
+func (s *scanner) eof() token {
+	return token{typ: tokEOF}
+}
+
+func (s *scanner) stop() token {
+	tok := s.eof()
+	if s.head < len(s.tokens) {
+		tok = s.tokens[s.head]
+	}
+	s.tokens, s.head = s.tokens[:0], 0
+	s.state = nil
+	s.lexer.stop()
+	return tok
+}
+
+func (s *scanner) next() item {
+	var it item
+	if s.hasLast {
+		it = s.last
+		s.hasLast = false
+	} else {
+		it = s.lexer.nextItem()
+	}
+	s.pos = it.pos
+	return it
+}
+
+func (s *scanner) peek() item {
+	it := s.next()
+	s.backup(it)
+	return it
+}
+
+func (s *scanner) backup(it item) {
+	s.last = it
+	s.hasLast = true
+}
+
+func (s *scanner) accept(it itemType) bool {
+	tok := s.next()
+	if tok.typ == it {
+		s.curr = append(s.curr, tok)
+		return true
+	}
+	s.backup(tok)
+	return false
+}
+
+// emit queues a token with the accepted items. To avoid allocations, the
+// item slice is reused for the next token (see nextToken).
+func (s *scanner) emit(typ scanToken) {
+	s.tokens = append(s.tokens, token{typ: typ, items: s.curr})
+	s.curr = s.curr[:0]
+}
+
+func (s *scanner) errorf(format string, args ...interface{}) scanFn {
+	s.tokens = append(s.tokens, token{
+		typ:   tokError,
+		items: []item{{itemError, fmt.Sprintf(format, args...), s.pos}},
+	})
+	return nil
+}
+
+// scanStart looks for a "package" or "config" item.
+func scanStart(s *scanner) scanFn {
+	switch it := s.next(); it.typ { //nolint:exhaustive
+	case itemPackage:
+		return scanPackage
+	case itemConfig:
+		return scanSection
+	case itemError:
+		return s.errorf("%s", it.val)
+	case itemEOF:
+		return nil
+	default:
+		return s.errorf("expected package or config token, got %s", it)
+	}
+}
+
+// scanPackage looks for a package name.
+func scanPackage(s *scanner) scanFn {
+	switch it := s.next(); it.typ { //nolint:exhaustive
+	case itemString:
+		s.curr = append(s.curr, it)
+		s.emit(tokPackage)
+		return scanStart
+	case itemError:
+		return s.errorf("%s", it.val)
+	default:
+		return s.errorf("expected string value while parsing package, got %s", it)
+	}
+}
+
+// scanSection looks for a section type and optional a name.
+func scanSection(s *scanner) scanFn {
+	switch it := s.next(); it.typ { //nolint:exhaustive
+	case itemIdent:
+		s.curr = append(s.curr, it)
+		// the name is optional
+		if tok := s.peek(); tok.typ == itemString {
+			s.accept(itemString)
+		}
+		s.emit(tokSection)
+		return scanOption
+	case itemError:
+		return s.errorf("%s", it.val)
+	default:
+		return s.errorf("expected identifier while parsing config section, got %s", it)
+	}
+}
+
+// scanOption looks for either an "option" or "list" keyword (with name
+// and value), or it falls back to scanStart.
+func scanOption(s *scanner) scanFn {
+	it := s.next()
+	switch it.typ { //nolint:exhaustive
+	case itemOption:
+		return scanOptionName
+	case itemList:
+		return scanListName
+	case itemError:
+		return s.errorf("%s", it.val)
+	default:
+		s.backup(it)
+		return scanStart
+	}
+}
+
+// scanOptionName looks for a name of a string option.
+func scanOptionName(s *scanner) scanFn {
+	if s.accept(itemIdent) {
+		return scanOptionValue
+	}
+	return s.errorf("expected option name")
+}
+
+// scanListName looks for a name of a list option.
+func scanListName(s *scanner) scanFn {
+	if s.accept(itemIdent) {
+		return scanListValue
+	}
+	return s.errorf("expected option name")
+}
+
+// scanOptionValue looks for the value associated with an option.
+func scanOptionValue(s *scanner) scanFn {
+	switch it := s.next(); it.typ { //nolint:exhaustive
+	case itemString:
+		s.curr = append(s.curr, it)
+		s.emit(tokOption)
+		return scanOption
+	case itemError:
+		return s.errorf("%s", it.val)
+	default:
+		return s.errorf("expected option value, got %s", it)
+	}
+}
+
+// scanListValue looks for the value associated with an option.
+func scanListValue(s *scanner) scanFn {
+	switch it := s.next(); it.typ { //nolint:exhaustive
+	case itemString:
+		s.curr = append(s.curr, it)
+		s.emit(tokList)
+		return scanOption
+	case itemError:
+		return s.errorf("%s", it.val)
+	default:
+		return s.errorf("expected option value, got %s", it)
+	}
+}
+
+func (s *scanner) each(fn func(token) bool) bool {
+	for tok := s.nextToken(); tok.typ != tokEOF; tok = s.nextToken() {
+		if !fn(tok) {
+			s.stop()
+			return false
+		}
+	}
+	return true
+}
+
+// Parse tries to parse a named input string into a config object.
+// Syntax errors are returned as *PositionError wrapping a *ParseError.
+//
+// Like libuci, it merges sections redefined later in the input into
+// the first definition (keeping its type and position): options replace
+// the previous value, and list values are appended. Use a Parser to
+// reject such redefinitions.
+func Parse(name, input string) (*Config, error) {
+	cfg, _, err := parse(name, input, Parser{}, false)
+	return cfg, err
+}
+
+// ParsePositions works like Parse, but additionally returns the
+// location of each section and option in the input.
+func ParsePositions(name, input string) (cfg *Config, pos *Positions, err error) {
+	return parse(name, input, Parser{}, true)
+}
+
+// A Parser parses configs with non-default settings. The zero value
+// behaves like Parse.
+type Parser struct {
+	Strict                  bool // see ParseStrict
+	Limits                  Limits
+	RejectDuplicateSections bool // fail on redefined named sections, with ErrDuplicateSection
+}
+
+// Parse works like the package function Parse, with the settings of p.
+// Inputs larger than p.Limits.MaxSize are rejected before parsing.
+func (p Parser) Parse(name, input string) (*Config, error) {
+	if err := p.Limits.checkSize(name, input); err != nil {
+		return nil, err
+	}
+	cfg, _, err := parse(name, input, p, false)
+	return cfg, err
+}
+
+// ParsePositions works like Parser.Parse, but additionally returns the
+// location of each section and option in the input.
+func (p Parser) ParsePositions(name, input string) (*Config, *Positions, error) {
+	if err := p.Limits.checkSize(name, input); err != nil {
+		return nil, nil, err
+	}
+	return parse(name, input, p, true)
+}
+
+// parse implements Parse, ParsePositions and the Parser methods. The
+// positions are only recorded if requested (otherwise pos is nil).
+func parse(name, input string, p Parser, positions bool) (cfg *Config, pos *Positions, err error) { //nolint:cyclop
+	cfg = NewConfig(name)
+	cfg.encoding = detectEncoding(input)
+	idx := newLineIndex(name, input)
+	if positions {
+		pos = newPositions(idx)
+	}
+	var sec *Section
+	named := make(map[string]*Section) // avoids the linear search of cfg.Merge
+	var mem arena
+	limit := limiter{limits: &p.Limits}
+	strict := p.Strict
+
+	if strict {
+		if err = checkIndentation(input, idx); err != nil {
+			return cfg, pos, err
+		}
+	}
+
+	scan(name, input).each(func(tok token) bool {
+		switch tok.typ { //nolint:exhaustive
+		case tokError:
+			perr := ParseError(tok.items[0].val)
+			err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: &perr}
+			return false
+
+		case tokPackage:
+			perr := ParseError("UCI imports/exports are not yet supported")
+			err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: &perr}
+			return false
+
+		case tokSection:
+			if strict {
+				if err = checkSeparated(input, tok.items, idx); err != nil {
+					return false
+				}
+			}
+			if lerr := limit.section(); lerr != nil {
+				err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: lerr}
+				return false
+			}
+			name := tok.items[0].val
+			if len(tok.items) == 2 {
+				secName := tok.items[1].val
+				switch {
+				case strings.HasPrefix(secName, "@"):
+					// may refer to an unnamed section
+					sec = cfg.Merge(mem.section(name, secName))
+				case named[secName] != nil:
+					sec = named[secName]
+					switch {
+					case p.RejectDuplicateSections:
+						err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: fmt.Errorf("%w: %s", ErrDuplicateSection, secName)}
+						return false
+					case strict && sec.Type != name:
+						err = &PositionError{
+							Pos: idx.position(tok.items[0].pos),
+							Err: fmt.Errorf("%w: %s redefined as %s, was %s", ErrDuplicateSection, secName, name, sec.Type),
+						}
+						return false
+					}
+				default:
+					sec = cfg.Add(mem.section(name, secName))
+					named[secName] = sec
+				}
+			} else {
+				sec = cfg.Add(mem.section(name, ""))
+			}
+			pos.setSection(sec, tok.items[0].pos)
+
+		case tokOption:
+			name := tok.items[0].val
+			val := tok.items[1].val
+
+			if lerr := limit.option(sec, val); lerr != nil {
+				err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: lerr}
+				return false
+			}
+			opt := sec.Get(name)
+			if strict {
+				if err = checkSeparated(input, tok.items, idx); err != nil {
+					return false
+				}
+				if opt != nil {
+					err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: fmt.Errorf("%w: %s", ErrDuplicateOption, name)}
+					return false
+				}
+			}
+			if opt != nil {
+				opt.Type = TypeOption // like libuci, replace lists
+				opt.SetValues(val)
+			} else {
+				opt = sec.Add(mem.option(name, TypeOption, val))
+			}
+			pos.setOption(opt, tok.items[0].pos)
+
+		case tokList:
+			name := tok.items[0].val
+			val := tok.items[1].val
+
+			if lerr := limit.option(sec, val); lerr != nil {
+				err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: lerr}
+				return false
+			}
+			opt := sec.Get(name)
+			if strict {
+				if err = checkSeparated(input, tok.items, idx); err != nil {
+					return false
+				}
+				if opt != nil && opt.Type != TypeList {
+					err = &PositionError{Pos: idx.position(tok.items[0].pos), Err: fmt.Errorf("%w: %s", ErrDuplicateOption, name)}
+					return false
+				}
+			}
+			if opt != nil {
+				opt.Type = TypeList // like libuci, turn options into lists
+				opt.AddValue(val)
+			} else {
+				opt = sec.Add(mem.option(name, TypeList, val))
+			}
+			pos.setOption(opt, tok.items[0].pos)
+		}
+		return true
+	})
+	return cfg, pos, err
+}
+
+// arenaBlock is the number of sections/options allocated at once.
+const arenaBlock = 64
+
+// arena hands out sections and options from larger blocks, to reduce the
+// number of allocations while parsing. All slices are capped, so that
+// appending to them later moves them out of the arena.
+type arena struct {
+	sections []Section
+	options  []Option
+	optPtrs  []*Option
+	values   []string
+}
+
+// optionsPerSection is the initial capacity of Section.Options.
+const optionsPerSection = 8
+
+func (a *arena) section(typ, name string) *Section {
+	if len(a.sections) == cap(a.sections) {
+		a.sections = make([]Section, 0, arenaBlock)
+	}
+	if cap(a.optPtrs)-len(a.optPtrs) < optionsPerSection {
+		a.optPtrs = make([]*Option, 0, arenaBlock*optionsPerSection)
+	}
+	n := len(a.optPtrs)
+	a.sections = append(a.sections, Section{
+		Type:    typ,
+		Name:    name,
+		Options: a.optPtrs[n : n : n+optionsPerSection],
+	})
+	a.optPtrs = a.optPtrs[:n+optionsPerSection]
+	return &a.sections[len(a.sections)-1]
+}
+
+func (a *arena) option(name string, typ OptionType, val string) *Option {
+	if len(a.options) == cap(a.options) {
+		a.options = make([]Option, 0, arenaBlock)
+	}
+	if len(a.values) == cap(a.values) {
+		a.values = make([]string, 0, arenaBlock)
+	}
+	a.values = append(a.values, val)
+	n := len(a.values)
+	a.options = append(a.options, Option{
+		Name:   name,
+		Type:   typ,
+		Values: a.values[n-1 : n : n],
+	})
+	return &a.options[len(a.options)-1]
+}
@@ -0,0 +1,151 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser(t *testing.T) {
+	for i := range parserTests {
+		tc := parserTests[i]
+		t.Run(tc.name, func(t *testing.T) {
+			testParser(t, tc.name, tc.input, tc.expected)
+		})
+	}
+}
+
+func testParser(t *testing.T, name, input string, expected []token) {
+	t.Helper()
+
+	var i int
+	ok := scan(name, input).each(func(tok token) bool {
+		if dump["token"] {
+			fmt.Println(tok) //nolint:forbidigo
+		}
+
+		if i >= len(expected) {
+			t.Errorf("token %d, unexpected item: %s", i, tok)
+			return false
+		}
+		if ex := expected[i]; tok.typ != ex.typ || !equalItemList(tok.items, ex.items) {
+			t.Errorf("token %d\nexpected %s\ngot      %s", i, ex, tok)
+			return false
+		}
+
+		i++
+		return true
+	})
+	if !ok {
+		return
+	}
+
+	if l := len(expected); i != l {
+		t.Errorf("expected to scan %d token, actually scanned %d", l, i)
+	}
+}
+
+func equalItemList(a, b []item) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].typ != b[i].typ || a[i].val != b[i].val {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseArena(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("test", "config a 'x'\n\toption one '1'\n\toption two '2'\n\nconfig a\n\toption three '3'\n\nconfig b '@a[1]'\n\toption four '4'\n")
+	require.NoError(err)
+
+	// growing a value list or an option list must not overwrite neighbours
+	x := cfg.Get("x")
+	x.Get("one").AddValue("1b")
+	for i := 0; i < 10; i++ {
+		x.Add(NewOption(fmt.Sprintf("extra%d", i), TypeOption, "x"))
+	}
+	assert.Equal([]string{"2"}, x.Get("two").Values)
+	assert.Equal([]*Option{
+		NewOption("three", TypeOption, "3"),
+		NewOption("four", TypeOption, "4"),
+	}, cfg.Get("@a[1]").Options)
+	assert.Len(cfg.Sections, 2)
+}
+
+func TestMultilineValues(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const input = "config a 'x'\n\toption script 'echo one\necho \"two\"\n'\n\toption after \"it's\nhere\"\n"
+	cfg, pos, err := ParsePositions("test", input)
+	require.NoError(err)
+	assert.Equal("echo one\necho \"two\"\n", cfg.Get("x").Get("script").Values[0])
+	assert.Equal("it's\nhere", cfg.Get("x").Get("after").Values[0])
+	after, _ := pos.Option(cfg.Get("x").Get("after"))
+	assert.Equal(5, after.Line)
+
+	for _, st := range []Style{{}, {Quote: QuoteDouble}, {Quote: QuoteMinimal}, {CRLF: true}} {
+		var buf bytes.Buffer
+		_, err = cfg.Write(&buf, WithStyle(st))
+		require.NoError(err)
+		written, err := Parse("test", buf.String())
+		require.NoError(err, buf.String())
+		assert.Equal(cfg.Get("x").Get("script").Values, written.Get("x").Get("script").Values)
+		assert.Equal(cfg.Get("x").Get("after").Values, written.Get("x").Get("after").Values)
+	}
+
+	// the line based formats of "uci show" and delta files
+	var buf bytes.Buffer
+	require.NoError(cfg.Show(&buf))
+	configs, err := ParseShow(buf.String())
+	require.NoError(err)
+	assert.Equal(cfg.Get("x").Get("script").Values, configs[0].Get("x").Get("script").Values)
+
+	deltas := []Delta{{DeltaChange, "test", "x", "note", "one\nx.y='two'"}, {DeltaChange, "test", "x", "other", "three"}}
+	buf.Reset()
+	require.NoError(WriteDeltas(&buf, deltas))
+	parsed, err := ParseDeltas(buf.String())
+	require.NoError(err)
+	assert.Equal(deltas, parsed)
+
+	_, err = Parse("test", "config a 'x'\n\toption script 'echo\n\n\toption b c\n")
+	assert.EqualError(err, "test:2:16: parse error: unterminated quoted string")
+	_, err = ParseShow("test.x=a\ntest.x.b='c\n")
+	assert.EqualError(err, "2:10: parse error: unterminated quoted string")
+}
+
+func TestParseDuplicateSections(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const input = "config a 'x'\n\toption foo 'a'\n\tlist bar 'b'\n\nconfig a 'y'\n\nconfig b 'x'\n\toption foo 'c'\n\tlist bar 'b'\n\toption baz 'd'\n"
+
+	// like libuci: the first definition keeps its type and position,
+	// options are replaced, and list values appended
+	cfg, err := Parse("test", input)
+	require.NoError(err)
+	require.Len(cfg.Sections, 2)
+	x := cfg.Sections[0]
+	assert.Equal("a", x.Type)
+	assert.Equal("c", x.LastValue("foo"))
+	assert.Equal([]string{"b", "b"}, x.Get("bar").Values)
+	assert.Equal("d", x.LastValue("baz"))
+
+	_, err = Parser{RejectDuplicateSections: true}.Parse("test", input)
+	assert.True(errors.Is(err, ErrDuplicateSection))
+	assert.EqualError(err, "test:7:8: duplicate section: x")
+
+	// unnamed sections may repeat
+	_, err = Parser{RejectDuplicateSections: true}.Parse("test", "config a\nconfig a\n")
+	assert.NoError(err)
+}
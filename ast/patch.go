@@ -0,0 +1,257 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PatchOp is the operation of a PatchOperation.
+type PatchOp string
+
+const (
+	// PatchAdd adds a section (the value is its type), or sets an
+	// option, replacing its values if it exists. The section selector
+	// "@type[-]" appends a new unnamed section; later operations can
+	// refer to it as "@type[-1]".
+	PatchAdd PatchOp = "add"
+
+	// PatchRemove removes an existing section or option.
+	PatchRemove PatchOp = "remove"
+
+	// PatchReplace changes the type of an existing section, or the
+	// values of an existing option.
+	PatchReplace PatchOp = "replace"
+)
+
+// A Patch is a list of operations modifying a config, modeled after JSON
+// Patch (RFC 6902). Paths use the dotted notation of Path instead of
+// JSON pointers:
+//
+//	[
+//	  {"op": "add", "path": "network.guest", "value": "interface"},
+//	  {"op": "add", "path": "network.guest.proto", "value": "static"},
+//	  {"op": "replace", "path": "network.lan.dns", "value": ["1.1.1.1"]},
+//	  {"op": "remove", "path": "network.@route[0]"}
+//	]
+type Patch []PatchOperation
+
+// A PatchOperation is a single operation of a Patch.
+type PatchOperation struct {
+	Op    PatchOp     `json:"op"`
+	Path  string      `json:"path"`
+	Value *PatchValue `json:"value,omitempty"`
+}
+
+// A PatchValue is the section type (a string), or the value of an option:
+// a string for options, an array of strings for lists.
+type PatchValue struct {
+	Values []string
+	List   bool
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v PatchValue) MarshalJSON() ([]byte, error) {
+	if v.List || len(v.Values) != 1 {
+		values := v.Values
+		if values == nil {
+			values = []string{}
+		}
+		return json.Marshal(values)
+	}
+	return json.Marshal(v.Values[0])
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *PatchValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = PatchValue{Values: []string{s}}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(b, &values); err != nil {
+		return errors.New("patch value must be a string or an array of strings")
+	}
+	*v = PatchValue{Values: values, List: true}
+	return nil
+}
+
+var (
+	ErrPatchPath      = errors.New("path must address a section or option of the config")
+	ErrPatchValue     = errors.New("missing or invalid value")
+	ErrPatchNotFound  = errors.New("path not found")
+	ErrPatchOperation = errors.New("unknown operation")
+)
+
+// PatchError is returned by ApplyPatch, if an operation fails.
+type PatchError struct {
+	Index int // of the operation in the patch
+	Op    PatchOperation
+	Err   error
+}
+
+func (err *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s %s): %v", err.Index, err.Op.Op, err.Op.Path, err.Err)
+}
+
+func (err *PatchError) Unwrap() error {
+	return err.Err
+}
+
+// ApplyPatch applies the operations of patch to cfg, and marks it as
+// tainted. The patch is applied atomically: if any operation fails, cfg
+// is left untouched, and a *PatchError is returned.
+func ApplyPatch(cfg *Config, patch Patch) error {
+	work := cfg.Clone()
+	for i, op := range patch {
+		if err := work.applyPatchOperation(op); err != nil {
+			return &PatchError{Index: i, Op: op, Err: err}
+		}
+	}
+	if len(patch) > 0 {
+		cfg.Sections = work.Sections
+		cfg.Reindex()
+		cfg.SetTainted()
+	}
+	return nil
+}
+
+func (c *Config) applyPatchOperation(op PatchOperation) error { //nolint:cyclop
+	p, err := ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if p.Config != c.Name || p.Section == "" {
+		return ErrPatchPath
+	}
+	if op.Op != PatchRemove && (op.Value == nil || p.Option == "" && (op.Value.List || len(op.Value.Values) != 1)) {
+		return ErrPatchValue
+	}
+
+	if p.Option == "" {
+		sec := c.Get(p.Section)
+		switch op.Op {
+		case PatchAdd:
+			return c.patchAddSection(p.Section, sec, op.Value.Values[0])
+		case PatchReplace:
+			if sec == nil {
+				return ErrPatchNotFound
+			}
+			if !isIdent(op.Value.Values[0]) {
+				return ErrInvalidType
+			}
+			sec.Type = op.Value.Values[0]
+			c.Reindex()
+			return nil
+		case PatchRemove:
+			if sec == nil {
+				return ErrPatchNotFound
+			}
+			c.remove(sec)
+			return nil
+		}
+		return ErrPatchOperation
+	}
+
+	sec := c.Get(p.Section)
+	if sec == nil {
+		return ErrPatchNotFound
+	}
+	opt := sec.Get(p.Option)
+	switch op.Op {
+	case PatchAdd, PatchReplace:
+		if opt == nil && op.Op == PatchReplace {
+			return ErrPatchNotFound
+		}
+		if !isIdent(p.Option) {
+			return ErrInvalidName
+		}
+		typ := TypeOption
+		if op.Value.List {
+			typ = TypeList
+		} else if len(op.Value.Values) != 1 {
+			return ErrPatchValue
+		}
+		values := append([]string(nil), op.Value.Values...)
+		if opt == nil {
+			sec.Add(NewOption(p.Option, typ, values...))
+		} else {
+			opt.Type = typ
+			opt.SetValues(values...)
+		}
+		return nil
+	case PatchRemove:
+		if !sec.Del(p.Option) {
+			return ErrPatchNotFound
+		}
+		return nil
+	}
+	return ErrPatchOperation
+}
+
+func (c *Config) patchAddSection(sel string, sec *Section, typ string) error {
+	if !isIdent(typ) {
+		return ErrInvalidType
+	}
+	if strings.HasPrefix(sel, "@") {
+		if sel != "@"+typ+"[-]" {
+			return ErrPatchPath // only appending unnamed sections is supported
+		}
+		c.Add(NewSection(typ, ""))
+		return nil
+	}
+	if sec != nil {
+		if sec.Type != typ {
+			return ErrSectionExists{Config: c.Name, Section: sel}
+		}
+		return nil
+	}
+	if !isName(sel) {
+		return ErrInvalidName
+	}
+	c.Add(NewSection(typ, sel))
+	return nil
+}
+
+// GeneratePatch returns a patch, which turns from into to. Section
+// order is not preserved: new unnamed sections are appended.
+func GeneratePatch(from, to *Config) Patch {
+	var removals, patch Patch
+	appended := make(map[string]string) // selector in to → "@type[-1]"
+	for _, ch := range Diff(from, to) {
+		path := Path{Config: to.Name, Section: ch.Section, Option: ch.Option}
+		switch ch.Op {
+		case OpAddSection:
+			if sec := to.Get(ch.Section); sec != nil && sec.Name == "" {
+				appended[ch.Section] = "@" + ch.Type + "[-1]"
+				path.Section = "@" + ch.Type + "[-]"
+			}
+			patch = append(patch, PatchOperation{Op: PatchAdd, Path: path.String(), Value: &PatchValue{Values: []string{ch.Type}}})
+
+		case OpSetOption:
+			if sel, ok := appended[ch.Section]; ok {
+				path.Section = sel
+			}
+			op := PatchAdd
+			if ch.Old != nil {
+				op = PatchReplace
+			}
+			value := &PatchValue{Values: ch.New, List: ch.OptionType == TypeList}
+			patch = append(patch, PatchOperation{Op: op, Path: path.String(), Value: value})
+
+		case OpDelSection, OpDelOption:
+			removals = append(removals, PatchOperation{Op: PatchRemove, Path: path.String()})
+		}
+	}
+
+	// Removals come first (so that sections, which changed their type,
+	// are removed before being added again), in reverse order, so that
+	// removing an unnamed section doesn't shift the selectors of the
+	// following ones.
+	for i, j := 0, len(removals)-1; i < j; i, j = i+1, j-1 {
+		removals[i], removals[j] = removals[j], removals[i]
+	}
+	return append(removals, patch...)
+}
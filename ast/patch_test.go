@@ -0,0 +1,154 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcPatchNetwork = `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	list dns '1.1.1.1'
+
+config interface 'wan'
+	option proto 'dhcp'
+
+config route
+	option target '10.0.0.0/8'
+
+config route
+	option target '172.16.0.0/12'
+`
+
+func TestApplyPatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var patch Patch
+	require.NoError(json.Unmarshal([]byte(`[
+		{"op": "add", "path": "network.guest", "value": "interface"},
+		{"op": "add", "path": "network.guest.proto", "value": "static"},
+		{"op": "replace", "path": "network.lan.dns", "value": ["9.9.9.9", "1.1.1.1"]},
+		{"op": "replace", "path": "network.lan.ipaddr", "value": "192.168.2.1"},
+		{"op": "remove", "path": "network.wan.proto"},
+		{"op": "remove", "path": "network.@route[0]"},
+		{"op": "add", "path": "network.@route[-]", "value": "route"},
+		{"op": "add", "path": "network.@route[-1].target", "value": "192.168.0.0/16"}
+	]`), &patch))
+
+	cfg, err := Parse("network", tcPatchNetwork)
+	require.NoError(err)
+	require.NoError(ApplyPatch(cfg, patch))
+	assert.True(cfg.Tainted())
+
+	want, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.2.1'
+	list dns '9.9.9.9'
+	list dns '1.1.1.1'
+
+config interface 'wan'
+
+config route
+	option target '172.16.0.0/12'
+
+config interface 'guest'
+	option proto 'static'
+
+config route
+	option target '192.168.0.0/16'
+`)
+	require.NoError(err)
+	assert.Empty(Diff(want, cfg))
+	assert.Equal("guest", cfg.Sections[3].Name)
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	value := func(v ...string) *PatchValue { return &PatchValue{Values: v} }
+	for _, tc := range []struct {
+		op  PatchOperation
+		err error
+	}{
+		{PatchOperation{Op: PatchRemove, Path: "network.missing"}, ErrPatchNotFound},
+		{PatchOperation{Op: PatchRemove, Path: "network.lan.missing"}, ErrPatchNotFound},
+		{PatchOperation{Op: PatchReplace, Path: "network.lan.missing", Value: value("x")}, ErrPatchNotFound},
+		{PatchOperation{Op: PatchReplace, Path: "network.missing", Value: value("x")}, ErrPatchNotFound},
+		{PatchOperation{Op: PatchAdd, Path: "network.missing.proto", Value: value("x")}, ErrPatchNotFound},
+		{PatchOperation{Op: PatchAdd, Path: "firewall.lan", Value: value("zone")}, ErrPatchPath},
+		{PatchOperation{Op: PatchAdd, Path: "network", Value: value("zone")}, ErrPatchPath},
+		{PatchOperation{Op: PatchAdd, Path: "network.@route[3]", Value: value("route")}, ErrPatchPath},
+		{PatchOperation{Op: PatchAdd, Path: "network.lan"}, ErrPatchValue},
+		{PatchOperation{Op: PatchAdd, Path: "network.lan", Value: value("a", "b")}, ErrPatchValue},
+		{PatchOperation{Op: PatchAdd, Path: "network.lan.proto", Value: value("a", "b")}, ErrPatchValue},
+		{PatchOperation{Op: PatchAdd, Path: "network.lan", Value: value("device")}, ErrSectionExists{"network", "lan"}},
+		{PatchOperation{Op: PatchAdd, Path: "network.x", Value: value("not a type")}, ErrInvalidType},
+		{PatchOperation{Op: PatchAdd, Path: "network.lan.bad-name!", Value: value("x")}, ErrInvalidName},
+		{PatchOperation{Op: "move", Path: "network.lan", Value: value("x")}, ErrPatchOperation},
+	} {
+		cfg, err := Parse("network", tcPatchNetwork)
+		require.NoError(t, err)
+
+		patch := Patch{{Op: PatchRemove, Path: "network.wan"}, tc.op}
+		err = ApplyPatch(cfg, patch)
+		var perr *PatchError
+		if assert.True(errors.As(err, &perr), tc.op.Path) {
+			assert.Equal(1, perr.Index)
+		}
+		assert.True(errors.Is(err, tc.err), "%s %s: %v", tc.op.Op, tc.op.Path, err)
+		assert.NotNil(cfg.Get("wan"), "patch must be atomic")
+	}
+}
+
+func TestGeneratePatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	from, err := Parse("network", tcPatchNetwork)
+	require.NoError(err)
+	to, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+
+config device 'wan'
+	option name 'eth1'
+
+config route
+	option target '10.0.0.0/8'
+	option gateway '192.168.1.2'
+
+config rule
+	option src '10.0.0.0/8'
+`)
+	require.NoError(err)
+
+	patch := GeneratePatch(from, to)
+	b, err := json.Marshal(patch)
+	require.NoError(err)
+	assert.JSONEq(`[
+		{"op": "remove", "path": "network.@route[1]"},
+		{"op": "remove", "path": "network.wan"},
+		{"op": "replace", "path": "network.lan.dns", "value": ["1.1.1.1", "9.9.9.9"]},
+		{"op": "add", "path": "network.wan", "value": "device"},
+		{"op": "add", "path": "network.wan.name", "value": "eth1"},
+		{"op": "add", "path": "network.@route[0].gateway", "value": "192.168.1.2"},
+		{"op": "add", "path": "network.@rule[-]", "value": "rule"},
+		{"op": "add", "path": "network.@rule[-1].src", "value": "10.0.0.0/8"}
+	]`, string(b))
+
+	var decoded Patch
+	require.NoError(json.Unmarshal(b, &decoded))
+	require.NoError(ApplyPatch(from, decoded))
+	assert.Empty(Diff(from, to))
+	assert.Empty(GeneratePatch(from, to))
+}
@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrEmptyPath          = errors.New("invalid path: empty")
+	ErrInvalidPath        = errors.New("invalid path: must have format 'config[.section[.option]]'")
+	ErrEmptyPathComponent = errors.New("invalid path: empty path component")
+	ErrUnterminatedQuote  = errors.New("invalid path: unterminated quote")
+)
+
+// A Path addresses a config, a section within a config, or an option
+// within a section, using the same dotted notation as the uci CLI
+// (e.g. "network.lan.ipaddr" or "system.@system[0].hostname").
+//
+// Components containing dots, quotes or whitespace (e.g. section names
+// like "my.iface") can be quoted with single or double quotes, as in
+// "network.'my.iface'.proto". Quotes must enclose a whole component,
+// there are no escape sequences.
+//
+// Section and Option are empty, if the path addresses a config or
+// section, respectively.
+type Path struct {
+	Config  string
+	Section string
+	Option  string
+}
+
+// ParsePath parses a dotted path.
+func ParsePath(s string) (Path, error) {
+	if s == "" {
+		return Path{}, ErrEmptyPath
+	}
+
+	parts, err := splitPath(s)
+	if err != nil {
+		return Path{}, err
+	}
+	if len(parts) > 3 {
+		return Path{}, ErrInvalidPath
+	}
+	for _, p := range parts {
+		if p == "" {
+			return Path{}, ErrEmptyPathComponent
+		}
+	}
+
+	var p Path
+	p.Config = parts[0]
+	if len(parts) > 1 {
+		p.Section = parts[1]
+	}
+	if len(parts) > 2 {
+		p.Option = parts[2]
+	}
+	return p, nil
+}
+
+// splitPath splits s at dots outside of quotes, and removes the quotes.
+func splitPath(s string) ([]string, error) {
+	var parts []string
+	for {
+		var part string
+		if q := s[0]; q == '\'' || q == '"' {
+			end := strings.IndexByte(s[1:], q)
+			if end < 0 {
+				return nil, ErrUnterminatedQuote
+			}
+			part, s = s[1:end+1], s[end+2:]
+			if s != "" && s[0] != '.' {
+				return nil, ErrInvalidPath // quote must end the component
+			}
+		} else {
+			end := strings.IndexByte(s, '.')
+			if end < 0 {
+				end = len(s)
+			}
+			part, s = s[:end], s[end:]
+		}
+		parts = append(parts, part)
+
+		if s == "" {
+			return parts, nil
+		}
+		s = s[1:] // skip dot
+		if s == "" {
+			return append(parts, ""), nil
+		}
+	}
+}
+
+// String returns the dotted notation of p. Components, which contain
+// dots, quotes or whitespace, are quoted.
+func (p Path) String() string {
+	s := QuoteName(p.Config)
+	if p.Section != "" {
+		s += "." + QuoteName(p.Section)
+		if p.Option != "" {
+			s += "." + QuoteName(p.Option)
+		}
+	}
+	return s
+}
+
+// QuoteName quotes a path component, if it contains dots, quotes or
+// whitespace. It uses single quotes, unless name contains a single
+// quote.
+func QuoteName(name string) string {
+	if !strings.ContainsAny(name, ".'\" \t\n") {
+		return name
+	}
+	if strings.ContainsRune(name, '\'') {
+		return `"` + name + `"`
+	}
+	return "'" + name + "'"
+}
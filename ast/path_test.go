@@ -0,0 +1,82 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	tt := map[string]struct {
+		path Path
+		err  error
+	}{
+		"network":                    {path: Path{Config: "network"}},
+		"network.lan":                {path: Path{Config: "network", Section: "lan"}},
+		"network.lan.ipaddr":         {path: Path{"network", "lan", "ipaddr"}},
+		"system.@system[0].hostname": {path: Path{"system", "@system[0]", "hostname"}},
+
+		"":                     {err: ErrEmptyPath},
+		"network..ipaddr":      {err: ErrEmptyPathComponent},
+		"network.lan.":         {err: ErrEmptyPathComponent},
+		"network.lan.ipaddr.x": {err: ErrInvalidPath},
+
+		"network.'my.iface'.proto":   {path: Path{"network", "my.iface", "proto"}},
+		"network.my-iface.proto":     {path: Path{"network", "my-iface", "proto"}},
+		`network."it's".proto`:       {path: Path{"network", "it's", "proto"}},
+		"network.'a.b.c'":            {path: Path{Config: "network", Section: "a.b.c"}},
+		"network.'lan":               {err: ErrUnterminatedQuote},
+		"network.'la'n.proto":        {err: ErrInvalidPath},
+		"network.''.proto":           {err: ErrEmptyPathComponent},
+		"network.'a.b'.'c.d'.'e.f'.": {err: ErrInvalidPath},
+	}
+
+	for input := range tt {
+		input, tc := input, tt[input]
+		t.Run(input, func(t *testing.T) {
+			p, err := ParsePath(input)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.path, p)
+			assert.Equal(t, input, p.String())
+		})
+	}
+}
+
+func TestDottedNames(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", `
+config interface my.iface
+	option proto 'static'
+
+config interface 'vlan-10.lan'
+	option proto 'dhcp'
+`)
+	assert.NoError(err)
+
+	p, err := ParsePath("network.'vlan-10.lan'.proto")
+	assert.NoError(err)
+	assert.Equal("dhcp", cfg.Get(p.Section).LastValue(p.Option))
+	assert.Equal("static", cfg.Get("my.iface").LastValue("proto"))
+
+	_, err = cfg.Rename("my.iface", "my.other-iface", nil)
+	assert.NoError(err)
+	assert.Equal("network.'my.other-iface'", Path{Config: "network", Section: "my.other-iface"}.String())
+
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	assert.NoError(err)
+	reparsed, err := Parse("network", buf.String())
+	assert.NoError(err)
+	assert.Equal(cfg.Sections, reparsed.Sections)
+
+	changes := Diff(reparsed, cfg)
+	assert.Empty(changes)
+	cfg.Get("vlan-10.lan").Get("proto").SetValues("static")
+	assert.Equal("'vlan-10.lan'.proto='static'", Diff(reparsed, cfg)[0].String())
+}
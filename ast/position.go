@@ -0,0 +1,141 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position describes a location within a UCI file.
+type Position struct {
+	Filename string // config name, may be empty
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number (in bytes), starting at 1
+}
+
+// String returns "filename:line:column", or "line:column" if the
+// filename is empty.
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// PositionError annotates an error with its location in the input.
+// Parse returns its errors wrapped in a PositionError.
+type PositionError struct {
+	Pos Position
+	Err error
+}
+
+func (err *PositionError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Pos, err.Err)
+}
+
+// Unwrap returns the annotated error.
+func (err *PositionError) Unwrap() error {
+	return err.Err
+}
+
+// lineIndex converts byte offsets into line and column numbers. The
+// line starts are only computed when needed.
+type lineIndex struct {
+	name  string
+	input string
+	lines []int // offsets of line starts
+}
+
+func newLineIndex(name, input string) *lineIndex {
+	return &lineIndex{name: name, input: input}
+}
+
+func (idx *lineIndex) position(offset int) Position {
+	if idx.lines == nil {
+		idx.lines = []int{0}
+		for i := 0; i < len(idx.input); i++ {
+			if idx.input[i] == '\n' {
+				idx.lines = append(idx.lines, i+1)
+			}
+		}
+	}
+	line := sort.Search(len(idx.lines), func(i int) bool { return idx.lines[i] > offset }) - 1
+	return Position{
+		Filename: idx.name,
+		Offset:   offset,
+		Line:     line + 1,
+		Column:   offset - idx.lines[line] + 1,
+	}
+}
+
+// Positions maps the sections and options of a parsed config to their
+// location in the input. Sections and options which are defined more
+// than once (e.g. named sections, which are extended later in the file,
+// or list options) are mapped to their first definition.
+type Positions struct {
+	idx       *lineIndex
+	sections  map[*Section]int // offsets
+	options   map[*Option]int
+	redefined map[*Section][]int
+}
+
+func newPositions(idx *lineIndex) *Positions {
+	return &Positions{
+		idx:       idx,
+		sections:  make(map[*Section]int),
+		options:   make(map[*Option]int),
+		redefined: make(map[*Section][]int),
+	}
+}
+
+// Section returns the position of the section's type identifier.
+func (p *Positions) Section(s *Section) (Position, bool) {
+	offset, ok := p.sections[s]
+	if !ok {
+		return Position{}, false
+	}
+	return p.idx.position(offset), true
+}
+
+// Option returns the position of the option's name.
+func (p *Positions) Option(o *Option) (Position, bool) {
+	offset, ok := p.options[o]
+	if !ok {
+		return Position{}, false
+	}
+	return p.idx.position(offset), true
+}
+
+// Redefinitions returns the positions of the section's headers after
+// the first one, i.e. where a named section is defined again (and the
+// parser merged both definitions).
+func (p *Positions) Redefinitions(s *Section) []Position {
+	var positions []Position
+	for _, offset := range p.redefined[s] {
+		positions = append(positions, p.idx.position(offset))
+	}
+	return positions
+}
+
+// setSection records the offset of s, unless p is nil (i.e. positions
+// are not requested). Later offsets of s are recorded as redefinitions.
+func (p *Positions) setSection(s *Section, offset int) {
+	if p == nil {
+		return
+	}
+	if _, exists := p.sections[s]; exists {
+		p.redefined[s] = append(p.redefined[s], offset)
+		return
+	}
+	p.sections[s] = offset
+}
+
+// setOption is like setSection.
+func (p *Positions) setOption(o *Option, offset int) {
+	if p == nil {
+		return
+	}
+	if _, exists := p.options[o]; !exists {
+		p.options[o] = offset
+	}
+}
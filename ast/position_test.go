@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorPosition(t *testing.T) {
+	tt := []struct {
+		input string
+		pos   string
+		msg   string
+	}{
+		{tcInvalid, "test:2:1", `expected keyword (package, config, option, list) or eof, got "<?xml vers…"`},
+		{tcIncompletePackage, "test:2:8", "incomplete package name"},
+		{tcUnterminatedQuoted, "test:2:12", "unterminated quoted string"},
+		{tcUnterminatedUnquoted, "test:4:1", "unterminated unquoted string"},
+		{"config foo\n\toption\n", "test:3:1", "expected option name"},
+	}
+
+	for _, tc := range tt {
+		_, err := Parse("test", tc.input)
+		require.Error(t, err)
+
+		var perr *PositionError
+		if assert.True(t, errors.As(err, &perr)) {
+			assert.Equal(t, tc.pos, perr.Pos.String())
+		}
+		var msg *ParseError
+		if assert.True(t, errors.As(err, &msg)) {
+			assert.Equal(t, tc.msg, string(*msg))
+		}
+		assert.Equal(t, tc.pos+": parse error: "+tc.msg, err.Error())
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	cfg, pos, err := ParsePositions("test", tcUnnamedInput)
+	require.NoError(t, err)
+
+	p, ok := pos.Section(cfg.Get("named"))
+	assert.True(t, ok)
+	assert.Equal(t, Position{Filename: "test", Offset: 8, Line: 2, Column: 8}, p)
+
+	p, ok = pos.Section(cfg.Get("@foo[2]"))
+	assert.True(t, ok)
+	assert.Equal(t, 12, p.Line)
+
+	// merged options are mapped to their first definition
+	p, ok = pos.Option(cfg.Get("named").Get("list"))
+	assert.True(t, ok)
+	assert.Equal(t, 5, p.Line)
+	assert.Equal(t, 7, p.Column)
+
+	_, ok = pos.Option(NewOption("foo", TypeOption, "bar"))
+	assert.False(t, ok)
+
+	assert.Equal(t, "1:1", Position{Line: 1, Column: 1}.String())
+}
+
+func TestRedefinitions(t *testing.T) {
+	cfg, pos, err := ParsePositions("test", "config a 'x'\nconfig b 'y'\nconfig a 'x'\n\toption foo 'bar'\n")
+	require.NoError(t, err)
+	require.Len(t, cfg.Sections, 2)
+
+	assert.Equal(t, []Position{{Filename: "test", Offset: 33, Line: 3, Column: 8}}, pos.Redefinitions(cfg.Get("x")))
+	assert.Empty(t, pos.Redefinitions(cfg.Get("y")))
+}
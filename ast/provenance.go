@@ -0,0 +1,87 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provenance describes where a section or option came from.
+type Provenance struct {
+	File   string // path of the file defining it, empty if unknown
+	Line   int    // line number of its first definition, starting at 1
+	Layer  string // layer of a layered tree (e.g. "/rom/etc/config")
+	Edited bool   // modified in memory, after it was read
+}
+
+// String returns "file:line", followed by the layer and an "edited"
+// marker, if applicable. Entirely unknown provenance is reported as
+// "unknown".
+func (p Provenance) String() string {
+	var parts []string
+	switch {
+	case p.File != "" && p.Line > 0:
+		parts = append(parts, fmt.Sprintf("%s:%d", p.File, p.Line))
+	case p.File != "":
+		parts = append(parts, p.File)
+	}
+	if p.Layer != "" {
+		parts = append(parts, fmt.Sprintf("(layer %s)", p.Layer))
+	}
+	if p.Edited {
+		parts = append(parts, "(edited)")
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Provenances maps the sections and options of a config to their
+// provenance. Like Positions, it is a side table: the sections and
+// options themselves don't carry their provenance.
+type Provenances struct {
+	sections map[*Section]Provenance
+	options  map[*Option]Provenance
+}
+
+// NewProvenances returns a provenance table for a config parsed with
+// ParsePositions from the file at the given path. The layer may be
+// empty.
+func NewProvenances(pos *Positions, file, layer string) *Provenances {
+	p := &Provenances{
+		sections: make(map[*Section]Provenance),
+		options:  make(map[*Option]Provenance),
+	}
+	if pos == nil {
+		return p
+	}
+	for s, offset := range pos.sections {
+		p.sections[s] = Provenance{File: file, Line: pos.idx.position(offset).Line, Layer: layer}
+	}
+	for o, offset := range pos.options {
+		p.options[o] = Provenance{File: file, Line: pos.idx.position(offset).Line, Layer: layer}
+	}
+	return p
+}
+
+// Section returns the provenance of s.
+func (p *Provenances) Section(s *Section) (Provenance, bool) {
+	prov, ok := p.sections[s]
+	return prov, ok
+}
+
+// Option returns the provenance of o.
+func (p *Provenances) Option(o *Option) (Provenance, bool) {
+	prov, ok := p.options[o]
+	return prov, ok
+}
+
+// SetSection records the provenance of s.
+func (p *Provenances) SetSection(s *Section, prov Provenance) {
+	p.sections[s] = prov
+}
+
+// SetOption records the provenance of o.
+func (p *Provenances) SetOption(o *Option, prov Provenance) {
+	p.options[o] = prov
+}
@@ -0,0 +1,56 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenances(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, pos, err := ParsePositions("system", `
+config system
+	option hostname 'ap1'
+
+config timeserver 'ntp'
+	list server '0.pool.ntp.org'
+	list server '1.pool.ntp.org'
+`)
+	require.NoError(t, err)
+
+	prov := NewProvenances(pos, "/etc/config/system", "")
+	p, ok := prov.Section(cfg.Get("ntp"))
+	assert.True(ok)
+	assert.Equal(Provenance{File: "/etc/config/system", Line: 5}, p)
+	p, ok = prov.Option(cfg.Get("ntp").Get("server"))
+	assert.True(ok)
+	assert.Equal(6, p.Line)
+	_, ok = prov.Option(NewOption("server", TypeList))
+	assert.False(ok)
+
+	opt := cfg.Get("@system[0]").Get("hostname")
+	prov.SetOption(opt, Provenance{Edited: true})
+	p, _ = prov.Option(opt)
+	assert.True(p.Edited)
+}
+
+func TestProvenance_String(t *testing.T) {
+	tt := []struct {
+		prov     Provenance
+		expected string
+	}{
+		{Provenance{}, "unknown"},
+		{Provenance{File: "/etc/config/system", Line: 3}, "/etc/config/system:3"},
+		{Provenance{File: "network"}, "network"},
+		{Provenance{Edited: true}, "(edited)"},
+		{
+			Provenance{File: "/rom/etc/config/system", Line: 1, Layer: "/rom/etc/config", Edited: true},
+			"/rom/etc/config/system:1 (layer /rom/etc/config) (edited)",
+		},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, tc.prov.String())
+	}
+}
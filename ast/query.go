@@ -0,0 +1,185 @@
+package ast
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidQuery = errors.New("invalid query: must have format 'config[.section[.option]][=value]'")
+
+// A Query selects configs, sections or options by patterns, using the
+// notation of "uci show": "firewall.@rule[*].dest_port=22" matches the
+// dest_port options of all rules, which have the value 22.
+//
+// Each component is a pattern, in which "*" matches any sequence of
+// characters, and "?" matches a single character ("\" escapes them).
+// Sections are matched by name; "*" hence also matches unnamed
+// sections. Selectors like "@rule[*]" or "@*[0]" match sections by type
+// and index (the index may be "*", or a number, negative numbers count
+// from the end).
+//
+// The value pattern is matched against the values of options (a list
+// matches if any of its values does), or against the type of sections.
+// Without value pattern, all values match.
+type Query struct {
+	Config   string
+	Section  string // empty to match configs only
+	Option   string // empty to match sections only
+	Value    string
+	HasValue bool
+}
+
+// ParseQuery parses a query expression. Components may be quoted, as
+// described for Path.
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		s, q.Value, q.HasValue = s[:i], s[i+1:], true
+	}
+	p, err := ParsePath(s)
+	if err != nil {
+		return Query{}, err
+	}
+	q.Config, q.Section, q.Option = p.Config, p.Section, p.Option
+	if q.Section != "" && strings.HasPrefix(q.Section, "@") {
+		if _, _, err := parseSelectorPattern(q.Section); err != nil {
+			return Query{}, err
+		}
+	}
+	if q.HasValue && q.Section == "" {
+		return Query{}, ErrInvalidQuery
+	}
+	return q, nil
+}
+
+// parseSelectorPattern splits "@type[index]" into its type pattern and
+// index; "*" is returned as index nil.
+func parseSelectorPattern(sel string) (typ string, index *int, err error) {
+	bra := strings.IndexByte(sel, '[')
+	if bra < 0 || !strings.HasSuffix(sel, "]") {
+		return "", nil, ErrInvalidSectionSelector
+	}
+	typ, idx := sel[1:bra], sel[bra+1:len(sel)-1]
+	if typ == "" {
+		return "", nil, ErrInvalidSectionSelector
+	}
+	if idx == "*" {
+		return typ, nil, nil
+	}
+	i, err := strconv.Atoi(idx)
+	if err != nil {
+		return "", nil, ErrInvalidSectionSelector
+	}
+	return typ, &i, nil
+}
+
+// MatchConfig reports whether q's config pattern matches the name.
+func (q Query) MatchConfig(name string) bool {
+	return wildcardMatch(q.Config, name)
+}
+
+// Match returns the paths of the sections or options of c matching q,
+// in the order of c. If q only has a config pattern, c's path is
+// returned if it matches.
+func (q Query) Match(c *Config) []Path {
+	if !q.MatchConfig(c.Name) {
+		return nil
+	}
+	if q.Section == "" {
+		return []Path{{Config: c.Name}}
+	}
+
+	var paths []Path
+	for _, sec := range c.Sections {
+		if !q.matchSection(c, sec) {
+			continue
+		}
+		name := c.SectionName(sec)
+		if q.Option == "" {
+			if !q.HasValue || wildcardMatch(q.Value, sec.Type) {
+				paths = append(paths, Path{Config: c.Name, Section: name})
+			}
+			continue
+		}
+		for _, opt := range sec.Options {
+			if wildcardMatch(q.Option, opt.Name) && q.matchValues(opt.Values) {
+				paths = append(paths, Path{Config: c.Name, Section: name, Option: opt.Name})
+			}
+		}
+	}
+	return paths
+}
+
+func (q Query) matchSection(c *Config, sec *Section) bool {
+	if !strings.HasPrefix(q.Section, "@") {
+		return wildcardMatch(q.Section, sec.Name)
+	}
+	typ, index, err := parseSelectorPattern(q.Section)
+	if err != nil || !wildcardMatch(typ, sec.Type) {
+		return false
+	}
+	if index == nil {
+		return true
+	}
+	i := *index
+	if i < 0 {
+		i += c.count(sec.Type)
+	}
+	return c.ordinal(sec) == i
+}
+
+func (q Query) matchValues(values []string) bool {
+	if !q.HasValue {
+		return true
+	}
+	for _, v := range values {
+		if wildcardMatch(q.Value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch reports whether s matches pattern, in which "*" matches
+// any sequence of bytes, "?" any single byte, and "\" escapes the next
+// byte.
+func wildcardMatch(pattern, s string) bool {
+	// backtracking to the last star suffices, as any later star can
+	// match whatever an earlier one would have
+	p, i := 0, 0
+	star, next := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch c := pattern[p]; {
+			case c == '*':
+				star, next = p, i
+				p++
+				continue
+			case c == '?':
+				p++
+				i++
+				continue
+			case c == '\\' && p+1 < len(pattern):
+				if pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+			case c == s[i]:
+				p++
+				i++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star+1, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,106 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWildcardMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "10.0.0.0/8", true},
+		{"lan", "lan", true},
+		{"lan", "lan6", false},
+		{"lan*", "lan6", true},
+		{"*an*", "wan6", true},
+		{"l?n", "lan", true},
+		{"l?n", "ln", false},
+		{"*.*.*.1", "192.168.1.1", true},
+		{"*.*.*.1", "192.168.1.10", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{`\*`, "*", true},
+		{`\*`, "x", false},
+		{`a\?`, "a?", true},
+	} {
+		assert.Equal(tc.match, wildcardMatch(tc.pattern, tc.s), "%q ~ %q", tc.pattern, tc.s)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("firewall", `
+config defaults
+	option input 'ACCEPT'
+
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	list network 'lan6'
+
+config rule
+	option name 'Allow-SSH'
+	option dest_port '22'
+
+config rule 'web'
+	option name 'Allow-Web'
+	option dest_port '80 443'
+
+config rule
+	option name 'Allow-SSH-Alt'
+	option dest_port '22'
+	option src 'wan'
+`)
+	require.NoError(err)
+
+	for _, tc := range []struct {
+		query string
+		paths []string
+	}{
+		{"firewall", []string{"firewall"}},
+		{"fire*", []string{"firewall"}},
+		{"network", nil},
+		{"firewall.@rule[*].dest_port=22", []string{
+			"firewall.@rule[0].dest_port",
+			"firewall.@rule[2].dest_port",
+		}},
+		{"*.*.dest_port=*443*", []string{"firewall.web.dest_port"}},
+		{"firewall.@rule[-1].*", []string{
+			"firewall.@rule[2].name",
+			"firewall.@rule[2].dest_port",
+			"firewall.@rule[2].src",
+		}},
+		{"firewall.@*[0]", []string{"firewall.@defaults[0]", "firewall.lan", "firewall.@rule[0]"}},
+		{"firewall.*=rule", []string{"firewall.@rule[0]", "firewall.web", "firewall.@rule[2]"}},
+		{"firewall.lan.network=lan6", []string{"firewall.lan.network"}},
+		{"firewall.l*.*=lan?", []string{"firewall.lan.network"}},
+		{"firewall.@zone[1].name", nil},
+	} {
+		q, err := ParseQuery(tc.query)
+		require.NoError(err, tc.query)
+
+		var paths []string
+		for _, p := range q.Match(cfg) {
+			paths = append(paths, p.String())
+		}
+		assert.Equal(tc.paths, paths, tc.query)
+	}
+
+	for _, s := range []string{"", "firewall=x", "firewall.@rule[x]", "firewall.@rule", "firewall.@[0]", "a.b.c.d"} {
+		_, err := ParseQuery(s)
+		assert.Error(err, s)
+	}
+	_, err = ParseQuery("firewall=x")
+	assert.True(errors.Is(err, ErrInvalidQuery))
+}
@@ -0,0 +1,101 @@
+package ast
+
+import (
+	"errors"
+	"strings"
+)
+
+// ParseRecover works like ParsePositions, but does not stop at the first
+// syntax error. Instead, it skips the malformed line and continues, so
+// that tools (like editors or linters) can report every problem in a
+// single pass. If a section header is malformed, the whole section is
+// skipped, to not attribute its options to the preceding section.
+//
+// It returns the config built from the well-formed lines, and the
+// syntax errors in order of appearance. Each error wraps a *ParseError.
+func ParseRecover(name, input string) (*Config, *Positions, []*PositionError) {
+	var errs []*PositionError
+	buf := []byte(input)
+	for {
+		cfg, pos, err := ParsePositions(name, string(buf))
+		if err == nil {
+			return cfg, pos, errs
+		}
+		var perr *PositionError
+		if !errors.As(err, &perr) {
+			// not reached: all errors of ParsePositions are positioned
+			e := ParseError(err.Error())
+			perr = &PositionError{Err: &e}
+		}
+		errs = append(errs, perr)
+		if !blankStatement(buf, perr.Pos.Offset) {
+			return cfg, pos, errs
+		}
+	}
+}
+
+// blankStatement replaces the statement containing the error at offset
+// with spaces (keeping line breaks, so that positions stay valid). It
+// reports whether anything was blanked.
+func blankStatement(buf []byte, offset int) bool {
+	if offset > len(buf) {
+		offset = len(buf)
+	}
+	start, end := lineBounds(buf, offset)
+
+	// Errors at the start of a line (or at the end of input) are caused
+	// by an incomplete statement on a preceding line, e.g. an option
+	// without value.
+	if strings.TrimSpace(string(buf[start:offset])) == "" {
+		for start > 0 {
+			prevStart, prevEnd := lineBounds(buf, start-1)
+			start, end = prevStart, prevEnd
+			if strings.TrimSpace(string(buf[start:end])) != "" {
+				break
+			}
+		}
+	}
+	if strings.TrimSpace(string(buf[start:end])) == "" {
+		return false
+	}
+
+	// skip the whole section, if its header is malformed
+	if firstWord(buf[start:end]) == string(kwConfig) {
+		for end < len(buf) {
+			nextStart, nextEnd := lineBounds(buf, end+1)
+			if kw := firstWord(buf[nextStart:nextEnd]); kw == string(kwConfig) || kw == string(kwPackage) {
+				break
+			}
+			end = nextEnd
+		}
+	}
+
+	for i := start; i < end; i++ {
+		if buf[i] != '\n' {
+			buf[i] = ' '
+		}
+	}
+	return true
+}
+
+// lineBounds returns the start and end offset (excluding the line
+// break) of the line containing offset.
+func lineBounds(buf []byte, offset int) (start, end int) {
+	start, end = offset, offset
+	for start > 0 && buf[start-1] != '\n' {
+		start--
+	}
+	for end < len(buf) && buf[end] != '\n' {
+		end++
+	}
+	return start, end
+}
+
+// firstWord returns the first word of a line.
+func firstWord(line []byte) string {
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
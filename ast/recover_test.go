@@ -0,0 +1,62 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRecover(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	input := "config a 'x'\n" +
+		"\toption foo 'a'\n" +
+		"\toption missing\n" +
+		"\toption bar 'b'\n" +
+		"\n" +
+		"config 'broken\n" +
+		"\toption skipped 'c'\n" +
+		"\n" +
+		"config a 'y'\n" +
+		"\toption baz 'd'\n" +
+		"\toption unterminated 'e\n"
+
+	cfg, pos, errs := ParseRecover("test", input)
+	require.NotNil(cfg)
+	require.NotNil(pos)
+	require.Len(errs, 3)
+
+	lines := make([]int, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Pos.Line
+		var perr *ParseError
+		assert.True(errors.As(err, &perr), "got %v", err)
+	}
+	assert.Equal([]int{4, 6, 11}, lines)
+
+	require.Len(cfg.Sections, 2)
+	x := cfg.Get("x")
+	require.NotNil(x)
+	assert.Equal([]string{"a"}, x.Get("foo").Values)
+	assert.Equal([]string{"b"}, x.Get("bar").Values)
+	assert.Nil(x.Get("missing"))
+	assert.Nil(x.Get("skipped"))
+
+	y := cfg.Get("y")
+	require.NotNil(y)
+	assert.Equal([]string{"d"}, y.Get("baz").Values)
+
+	// positions refer to the original input
+	p, ok := pos.Option(y.Get("baz"))
+	require.True(ok)
+	assert.Equal(10, p.Line)
+}
+
+func TestParseRecover_valid(t *testing.T) {
+	cfg, _, errs := ParseRecover("test", "config a 'x'\n\toption foo 'a'\n")
+	assert.Empty(t, errs)
+	assert.Len(t, cfg.Sections, 1)
+}
@@ -0,0 +1,74 @@
+package ast
+
+import "path"
+
+// RedactedValue replaces the values of redacted options.
+const RedactedValue = "***"
+
+// SecretOptions lists the names of options holding credentials. It is
+// used by Redacted and Redact, if no patterns are given.
+var SecretOptions = []string{
+	"key",
+	"key[0-9]",
+	"password",
+	"psk",
+	"private_key",
+	"preshared_key",
+	"sae_password",
+	"auth_secret",
+	"acct_secret",
+	"*_password",
+	"*_secret",
+}
+
+// MatchOption reports whether the option name matches any of the
+// patterns. Patterns use the syntax of path.Match.
+func MatchOption(name string, patterns ...string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of the config, where the values of options
+// matching any of the patterns (or SecretOptions, if none are given)
+// are replaced with RedactedValue. Use it before logging a config or
+// putting it into a support bundle.
+func (c *Config) Redacted(patterns ...string) *Config {
+	if len(patterns) == 0 {
+		patterns = SecretOptions
+	}
+	clone := c.Clone()
+	for _, sec := range clone.Sections {
+		for _, opt := range sec.Options {
+			if !MatchOption(opt.Name, patterns...) {
+				continue
+			}
+			for i := range opt.Values {
+				opt.Values[i] = RedactedValue
+			}
+		}
+	}
+	return clone
+}
+
+// A WriteOption modifies the output of Config.Write.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	redact []string
+	style  Style
+}
+
+// Redact masks the values of options matching any of the patterns (or
+// SecretOptions, if none are given), like Config.Redacted.
+func Redact(patterns ...string) WriteOption {
+	if len(patterns) == 0 {
+		patterns = SecretOptions
+	}
+	return func(o *writeOptions) {
+		o.redact = append(o.redact, patterns...)
+	}
+}
@@ -0,0 +1,87 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcSecrets = `
+config wifi-iface 'default_radio0'
+	option ssid 'OpenWrt'
+	option key 'hunter22'
+
+config interface 'wg0'
+	option proto 'wireguard'
+	option private_key 'cHJpdmF0ZQ=='
+	list addresses '10.0.0.1/24'
+
+config wireguard_wg0
+	option public_key 'cHVibGlj'
+	option preshared_key 'cHNr'
+
+config interface 'wan'
+	option proto 'pppoe'
+	option password 'secret'
+	list auth_secret 'one'
+	list auth_secret 'two'
+`
+
+func TestRedacted(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", tcSecrets)
+	require.NoError(t, err)
+
+	red := cfg.Redacted()
+	tt := []struct {
+		section, option string
+		expected        []string
+	}{
+		{"default_radio0", "ssid", []string{"OpenWrt"}},
+		{"default_radio0", "key", []string{RedactedValue}},
+		{"wg0", "private_key", []string{RedactedValue}},
+		{"wg0", "addresses", []string{"10.0.0.1/24"}},
+		{"@wireguard_wg0[0]", "public_key", []string{"cHVibGlj"}},
+		{"@wireguard_wg0[0]", "preshared_key", []string{RedactedValue}},
+		{"wan", "password", []string{RedactedValue}},
+		{"wan", "auth_secret", []string{RedactedValue, RedactedValue}},
+	}
+	for _, tc := range tt {
+		assert.Equal(tc.expected, red.Get(tc.section).Value(tc.option), "%s.%s", tc.section, tc.option)
+	}
+
+	// the original stays untouched
+	assert.Equal("hunter22", cfg.Get("default_radio0").LastValue("key"))
+
+	red = cfg.Redacted("ssid", "*_key")
+	assert.Equal(RedactedValue, red.Get("default_radio0").LastValue("ssid"))
+	assert.Equal("hunter22", red.Get("default_radio0").LastValue("key"))
+	assert.Equal(RedactedValue, red.Get("@wireguard_wg0[0]").LastValue("public_key"))
+}
+
+func TestWrite_redact(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", tcSecrets)
+	require.NoError(t, err)
+
+	var plain, redacted bytes.Buffer
+	_, err = cfg.Write(&plain)
+	require.NoError(t, err)
+	_, err = cfg.Write(&redacted, Redact())
+	require.NoError(t, err)
+
+	assert.Contains(plain.String(), "hunter22")
+	assert.NotContains(redacted.String(), "hunter22")
+	assert.NotContains(redacted.String(), "secret'")
+	assert.Contains(redacted.String(), "\toption key '***'\n")
+	assert.Contains(redacted.String(), "\toption ssid 'OpenWrt'\n")
+
+	var expected bytes.Buffer
+	_, err = cfg.WriteTo(&expected)
+	require.NoError(t, err)
+	assert.Equal(expected.String(), plain.String())
+}
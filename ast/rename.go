@@ -0,0 +1,207 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidName       = errors.New("invalid name: must consist of [-_.a-zA-Z0-9]")
+	ErrInvalidType       = errors.New("invalid section type: must consist of [-_a-zA-Z0-9]")
+	ErrInvalidIdentifier = errors.New("invalid identifier: must consist of [A-Za-z0-9_]")
+	ErrInvalidConfigName = errors.New("invalid config name: must consist of [-_A-Za-z0-9]")
+)
+
+// ErrSectionNotFound is returned by Rename and Config.Lookup, if the
+// section does not exist.
+type ErrSectionNotFound struct {
+	Config, Section string
+}
+
+func (err ErrSectionNotFound) Error() string {
+	return fmt.Sprintf("section %s.%s not found", err.Config, err.Section)
+}
+
+// Is reports whether target is an ErrSectionNotFound for the same
+// section. The zero value matches any section, so that
+// errors.Is(err, ErrSectionNotFound{}) can be used like a sentinel.
+func (err ErrSectionNotFound) Is(target error) bool {
+	t, ok := target.(ErrSectionNotFound) //nolint:errorlint
+	return ok && (t == ErrSectionNotFound{} || t == err)
+}
+
+// ErrSectionExists is returned by Rename, if another section already
+// has the new name.
+type ErrSectionExists struct {
+	Config, Section string
+}
+
+func (err ErrSectionExists) Error() string {
+	return fmt.Sprintf("section %s.%s already exists", err.Config, err.Section)
+}
+
+// A Ref describes an option whose values are names of sections, e.g.
+// the "network" list of firewall zones names interface sections of the
+// network config:
+//
+//	Ref{Config: "firewall", SectionType: "zone", Option: "network", TargetType: "interface"}
+type Ref struct {
+	Config      string // config containing the option
+	SectionType string // type of the sections containing the option, "" for any
+	Option      string
+	TargetType  string // type of the referenced sections, "" for any
+}
+
+// A RefMap lists, per config name, the options referring to sections of
+// that config. Renames only update references listed in the map; there
+// is no built-in knowledge of UCI packages.
+type RefMap map[string][]Ref
+
+// Rename changes the name of the section selected by sel (a name or an
+// "@type[index]" selector) to name. References to the section within c
+// are updated, if they are listed in refs (other configs are ignored,
+// see UpdateRefs). Rename returns the paths of all changed options.
+//
+// Renaming a section to its current name is a no-op. The config is
+// marked as tainted, if anything changed.
+func (c *Config) Rename(sel, name string, refs []Ref) ([]Path, error) {
+	if !isName(name) {
+		return nil, ErrInvalidName
+	}
+	sec := c.Get(sel)
+	if sec == nil {
+		return nil, ErrSectionNotFound{c.Name, sel}
+	}
+	if sec.Name == name {
+		return nil, nil
+	}
+	if other := c.getNamed(name); other != nil {
+		return nil, ErrSectionExists{c.Name, name}
+	}
+
+	old := sec.Name
+	sec.Name = name
+	c.Reindex()
+	c.SetTainted()
+	if old == "" {
+		// nothing could have referred to the section by name
+		return nil, nil
+	}
+	return c.UpdateRefs(refs, sec.Type, old, name), nil
+}
+
+// UpdateRefs replaces old with name in the values of all options listed
+// in refs, which belong to c and refer to sections of type typ. It
+// returns the paths of the changed options, and marks c as tainted, if
+// anything changed.
+func (c *Config) UpdateRefs(refs []Ref, typ, old, name string) []Path {
+	var changed []Path
+	for _, ref := range refs {
+		if ref.Config != c.Name || ref.TargetType != "" && ref.TargetType != typ {
+			continue
+		}
+		for _, sec := range c.Sections {
+			if ref.SectionType != "" && ref.SectionType != sec.Type {
+				continue
+			}
+			opt := sec.Get(ref.Option)
+			if opt == nil || !replaceValue(opt.Values, old, name) {
+				continue
+			}
+			changed = append(changed, Path{c.Name, c.SectionName(sec), opt.Name})
+		}
+	}
+	if len(changed) > 0 {
+		c.SetTainted()
+	}
+	return changed
+}
+
+// replaceValue replaces all occurrences of old in values with name, and
+// reports whether there were any. Values may contain multiple
+// whitespace separated names (e.g. "option network 'lan guest'").
+func replaceValue(values []string, old, name string) (found bool) {
+	for i, v := range values {
+		if v == old {
+			values[i] = name
+			found = true
+			continue
+		}
+		words := strings.Fields(v)
+		if len(words) < 2 {
+			continue
+		}
+		var replaced bool
+		for j, w := range words {
+			if w == old {
+				words[j] = name
+				replaced = true
+			}
+		}
+		if replaced {
+			values[i] = strings.Join(words, " ")
+			found = true
+		}
+	}
+	return found
+}
+
+// SetType changes the type of s. References to s are not affected, as
+// they use its name. Note however, that the "@type[index]" selectors of
+// unnamed sections change. Unlike after assigning Type, lookups see the
+// new selectors without Config.Reindex.
+func (s *Section) SetType(typ string) error {
+	if !isIdent(typ) {
+		return ErrInvalidType
+	}
+	if s.Type != typ {
+		s.Type = typ
+		typeChanges.Add(1)
+	}
+	return nil
+}
+
+// isName reports whether s is a valid section name. Besides identifier
+// characters, names may contain dots, as long as they are not
+// "@type[index]" selectors. Such names must be quoted in paths, see
+// Path.
+func isName(s string) bool {
+	return isIdent(strings.ReplaceAll(s, ".", "_"))
+}
+
+// ValidIdentifier reports whether s is a section or option name libuci
+// accepts in "uci set" and "uci add": a non-empty string of ASCII
+// letters, digits and underscores. The parser is more lenient, as it
+// also accepts dashes (and dots in section names).
+func ValidIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidConfigName reports whether s is a config name libuci accepts: a
+// non-empty string of ASCII letters, digits, underscores and dashes.
+// In particular, it can't be a path.
+func ValidConfigName(s string) bool {
+	return isIdent(s)
+}
+
+// isIdent reports whether s is a valid UCI identifier.
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '-' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
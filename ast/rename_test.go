@@ -0,0 +1,105 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const renameInput = `
+config interface 'lan'
+	option proto 'static'
+
+config interface 'guest'
+	option proto 'static'
+
+config route
+	option interface 'lan'
+
+config rule
+	option in 'lan'
+	option out 'guest'
+
+config alias
+	list interface 'lan'
+	list interface 'lan guest'
+`
+
+var renameRefs = []Ref{
+	{Config: "network", SectionType: "route", Option: "interface", TargetType: "interface"},
+	{Config: "network", SectionType: "rule", Option: "in", TargetType: "interface"},
+	{Config: "network", SectionType: "alias", Option: "interface"},
+	{Config: "firewall", SectionType: "zone", Option: "network", TargetType: "interface"},
+}
+
+func TestRename(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", renameInput)
+	assert.NoError(err)
+
+	changed, err := cfg.Rename("lan", "home", renameRefs)
+	assert.NoError(err)
+	assert.True(cfg.Tainted())
+	assert.Equal([]Path{
+		{"network", "@route[0]", "interface"},
+		{"network", "@rule[0]", "in"},
+		{"network", "@alias[0]", "interface"},
+	}, changed)
+
+	assert.Nil(cfg.Get("lan"))
+	assert.NotNil(cfg.Get("home"))
+	assert.Equal([]string{"home"}, cfg.Get("@route[0]").Value("interface"))
+	assert.Equal([]string{"guest"}, cfg.Get("@rule[0]").Value("out"))
+	assert.Equal([]string{"home", "home guest"}, cfg.Get("@alias[0]").Value("interface"))
+}
+
+func TestRenameErrors(t *testing.T) {
+	tt := []struct {
+		sel, name string
+		err       error
+	}{
+		{"lan", "", ErrInvalidName},
+		{"lan", "a b", ErrInvalidName},
+		{"wan", "home", ErrSectionNotFound{"network", "wan"}},
+		{"lan", "guest", ErrSectionExists{"network", "guest"}},
+	}
+	for _, tc := range tt {
+		cfg, err := Parse("network", renameInput)
+		assert.NoError(t, err)
+
+		_, err = cfg.Rename(tc.sel, tc.name, renameRefs)
+		assert.True(t, errors.Is(err, tc.err), "%s -> %q: %v", tc.sel, tc.name, err)
+		assert.False(t, cfg.Tainted())
+	}
+}
+
+func TestRenameUnnamed(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := Parse("network", renameInput)
+	assert.NoError(err)
+
+	changed, err := cfg.Rename("@route[0]", "default", renameRefs)
+	assert.NoError(err)
+	assert.Empty(changed)
+	assert.Equal("route", cfg.Get("default").Type)
+
+	// no-op
+	cfg.ResetTainted()
+	changed, err = cfg.Rename("default", "default", renameRefs)
+	assert.NoError(err)
+	assert.Empty(changed)
+	assert.False(cfg.Tainted())
+}
+
+func TestSectionSetType(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSection("interface", "lan")
+	assert.NoError(s.SetType("device"))
+	assert.Equal("device", s.Type)
+	assert.Equal(ErrInvalidType, s.SetType("no way"))
+	assert.Equal("device", s.Type)
+}
@@ -0,0 +1,104 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Set changes the config like "uci set" does, creating what is missing,
+// and returns the changes made (none, if the values were already set).
+// The config is marked as tainted, if anything changed.
+//
+// Without option, Set creates the named section sel with the type given
+// as only value, or changes the type of an existing section:
+//
+//	c.Set("lan", "", "interface") // uci set network.lan=interface
+//
+// Otherwise, Set replaces the values of the option (see Section.Set;
+// without values, the option is removed). Named sections must exist.
+// An "@type[index]" selector, which is one past the last section of the
+// type, appends an unnamed section, as does "@type[-1]" if there is no
+// section of the type, so that a sequence of Set calls can fill a new
+// section like "uci add" followed by "uci set":
+//
+//	c.Set("@rule[-1]", "name", "Allow-SSH") // creates the first rule
+func (c *Config) Set(sel, option string, values ...string) ([]Change, error) {
+	if option == "" {
+		return c.setSection(sel, values)
+	}
+	if !isIdent(option) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidName, option)
+	}
+
+	var changes []Change
+	sec, err := c.Lookup(sel)
+	if err != nil && strings.HasPrefix(sel, "@") {
+		typ, idx, serr := unmangleSectionName(sel)
+		if serr != nil {
+			return nil, serr
+		}
+		if n := c.count(typ); idx == n || idx == -1 && n == 0 {
+			if !isIdent(typ) {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidType, typ)
+			}
+			sec, err = c.Add(NewSection(typ, "")), nil
+			changes = append(changes, Change{Op: OpAddSection, Section: c.SectionName(sec), Type: typ})
+		} else {
+			err = ErrUnnamedIndexOutOfBounds
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opt := sec.Get(option)
+	change := Change{Op: OpSetOption, Section: c.SectionName(sec), Type: sec.Type, Option: option}
+	if opt != nil {
+		change.Old = append([]string(nil), opt.Values...)
+		change.OptionType = opt.Type
+	}
+	if opt = sec.Set(option, values...); opt == nil {
+		if change.Old != nil {
+			change.Op = OpDelOption
+			changes = append(changes, change)
+		}
+	} else if change.Old == nil || opt.Type != change.OptionType || !equalValues(change.Old, opt.Values) {
+		change.OptionType = opt.Type
+		change.New = append([]string(nil), opt.Values...)
+		changes = append(changes, change)
+	}
+	if len(changes) > 0 {
+		c.SetTainted()
+	}
+	return changes, nil
+}
+
+// setSection implements Set for section types.
+func (c *Config) setSection(name string, values []string) ([]Change, error) {
+	if len(values) != 1 || !isIdent(values[0]) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidType, strings.Join(values, " "))
+	}
+	typ := values[0]
+	sec := c.Get(name)
+	if sec == nil {
+		if !isName(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+		c.Add(NewSection(typ, name))
+		c.SetTainted()
+		return []Change{{Op: OpAddSection, Section: name, Type: typ}}, nil
+	}
+	if sec.Type == typ {
+		return nil, nil
+	}
+
+	// like Diff, report the type change as removal and addition
+	removed := Change{Op: OpDelSection, Section: c.SectionName(sec), Type: sec.Type}
+	_ = sec.SetType(typ) // typ is valid
+	c.Reindex()
+	c.SetTainted()
+	return []Change{
+		removed,
+		{Op: OpAddSection, Section: c.SectionName(sec), Type: typ},
+	}, nil
+}
@@ -0,0 +1,81 @@
+package ast
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSet(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := NewConfig("firewall")
+	changes, err := cfg.Set("lan", "", "zone")
+	require.NoError(err)
+	assert.Equal([]Change{{Op: OpAddSection, Section: "lan", Type: "zone"}}, changes)
+	assert.True(cfg.Tainted())
+
+	changes, err = cfg.Set("lan", "input", "ACCEPT")
+	require.NoError(err)
+	assert.Equal([]Change{{Op: OpSetOption, Section: "lan", Type: "zone", Option: "input", New: []string{"ACCEPT"}}}, changes)
+
+	// nothing changes
+	cfg.ResetTainted()
+	changes, err = cfg.Set("lan", "input", "ACCEPT")
+	require.NoError(err)
+	assert.Empty(changes)
+	changes, err = cfg.Set("lan", "", "zone")
+	require.NoError(err)
+	assert.Empty(changes)
+	assert.False(cfg.Tainted())
+
+	// "uci add" semantics
+	changes, err = cfg.Set("@rule[-1]", "name", "Allow-SSH")
+	require.NoError(err)
+	assert.Equal([]Change{
+		{Op: OpAddSection, Section: "@rule[0]", Type: "rule"},
+		{Op: OpSetOption, Section: "@rule[0]", Type: "rule", Option: "name", New: []string{"Allow-SSH"}},
+	}, changes)
+	changes, err = cfg.Set("@rule[-1]", "proto", "tcp", "udp")
+	require.NoError(err)
+	assert.Equal([]Change{{Op: OpSetOption, Section: "@rule[0]", Type: "rule", Option: "proto", OptionType: TypeList, New: []string{"tcp", "udp"}}}, changes)
+	changes, err = cfg.Set("@rule[1]", "name", "Allow-Ping")
+	require.NoError(err)
+	assert.Len(changes, 2)
+	assert.Len(cfg.Sections, 3)
+
+	changes, err = cfg.Set("@rule[0]", "proto")
+	require.NoError(err)
+	assert.Equal([]Change{{Op: OpDelOption, Section: "@rule[0]", Type: "rule", Option: "proto", OptionType: TypeList, Old: []string{"tcp", "udp"}}}, changes)
+	changes, err = cfg.Set("@rule[0]", "proto")
+	require.NoError(err)
+	assert.Empty(changes)
+
+	changes, err = cfg.Set("lan", "", "interface")
+	require.NoError(err)
+	assert.Equal([]Change{
+		{Op: OpDelSection, Section: "lan", Type: "zone"},
+		{Op: OpAddSection, Section: "lan", Type: "interface"},
+	}, changes)
+
+	for _, tc := range []struct {
+		sel, option string
+		values      []string
+		err         error
+	}{
+		{"wan", "proto", []string{"dhcp"}, ErrSectionNotFound{}},
+		{"@rule[5]", "name", []string{"x"}, ErrUnnamedIndexOutOfBounds},
+		{"@rule[x]", "name", []string{"x"}, strconv.ErrSyntax},
+		{"lan", "a b", []string{"x"}, ErrInvalidName},
+		{"wan", "", []string{"not a type"}, ErrInvalidType},
+		{"wan", "", nil, ErrInvalidType},
+		{"a b", "", []string{"zone"}, ErrInvalidName},
+	} {
+		_, err := cfg.Set(tc.sel, tc.option, tc.values...)
+		assert.True(errors.Is(err, tc.err), "%s.%s: got %v", tc.sel, tc.option, err)
+	}
+}
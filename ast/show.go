@@ -0,0 +1,242 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Show writes the config in the format of "uci show": one line per
+// section ("package.section=type") and option ("package.section.option=
+// 'value'"). Unnamed sections are referred to by their "@type[index]"
+// selector, list values are quoted individually and separated by
+// spaces.
+func (c *Config) Show(w io.Writer) error {
+	_, err := w.Write(c.AppendShow(nil))
+	return err
+}
+
+// ShowIDs is like Show, but refers to unnamed sections by the IDs libuci
+// assigns to them (see AnonymousID), like "uci show -X".
+func (c *Config) ShowIDs(w io.Writer) error {
+	_, err := w.Write(c.appendShow(nil, true))
+	return err
+}
+
+// AppendShow appends the output of Show to b, and returns the extended
+// buffer.
+func (c *Config) AppendShow(b []byte) []byte {
+	return c.appendShow(b, false)
+}
+
+func (c *Config) appendShow(b []byte, ids bool) []byte {
+	for _, sec := range c.Sections {
+		name := c.SectionName(sec)
+		if id := AnonymousID(c, sec); ids && id != "" {
+			name = id
+		}
+		prefix := c.Name + "." + QuoteName(name)
+		b = append(b, prefix...)
+		b = append(b, '=')
+		b = append(b, sec.Type...)
+		b = append(b, '\n')
+
+		for _, opt := range sec.Options {
+			if len(opt.Values) == 0 {
+				continue
+			}
+			b = append(b, prefix...)
+			b = append(b, '.')
+			b = append(b, opt.Name...)
+			b = append(b, '=')
+			values := opt.Values
+			if opt.Type == TypeOption {
+				values = values[:1]
+			}
+			for i, v := range values {
+				if i > 0 {
+					b = append(b, ' ')
+				}
+				b = appendShowValue(b, v)
+			}
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+// appendShowValue quotes v like uci does: each single quote within v
+// ends the quoted string, followed by an escaped quote, and a new quoted
+// string.
+func appendShowValue(b []byte, v string) []byte {
+	b = append(b, '\'')
+	for {
+		i := strings.IndexByte(v, '\'')
+		if i < 0 {
+			break
+		}
+		b = append(b, v[:i]...)
+		b = append(b, `'\''`...)
+		v = v[i+1:]
+	}
+	b = append(b, v...)
+	return append(b, '\'')
+}
+
+// showLines splits the output of "uci show" (or a delta file) into
+// lines, like strings.SplitAfter. Line breaks within single quotes are
+// part of the value, so the lines containing them are joined.
+func showLines(input string) []string {
+	lines := strings.SplitAfter(input, "\n")
+	joined := lines[:0]
+	open := false // within single quotes, at the end of the previous line
+	for _, line := range lines {
+		if open {
+			joined[len(joined)-1] += line
+		} else {
+			joined = append(joined, line)
+		}
+		for i := 0; i < len(line); i++ {
+			switch {
+			case line[i] == '\'':
+				open = !open
+			case line[i] == '\\' && !open:
+				i++
+			}
+		}
+	}
+	return joined
+}
+
+// ParseShow reads the output of "uci show", as produced by Show, and
+// returns the configs it contains (in order of their first appearance).
+//
+// Since "uci show" prints lists with a single value like options, such
+// lists are returned as TypeOption. Sections referred to by an
+// "@type[index]" selector, or by an ID matching their type (see
+// AnonymousID, as printed by "uci show -X"), are added as unnamed
+// sections.
+//
+// Syntax errors are returned as *PositionError wrapping a *ParseError.
+func ParseShow(input string) ([]*Config, error) {
+	var configs []*Config
+	byName := make(map[string]*Config)
+	sections := make(map[Path]*Section)
+	idx := newLineIndex("", input)
+
+	offset := 0
+	for _, line := range showLines(input) {
+		start := offset
+		offset += len(line)
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fail := func(col int, format string, args ...interface{}) error {
+			perr := ParseError(fmt.Sprintf(format, args...))
+			return &PositionError{Pos: idx.position(start + col), Err: &perr}
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return configs, fail(0, "expected key=value, got %q", line)
+		}
+		key, value := line[:eq], line[eq+1:]
+
+		if key == "" {
+			return configs, fail(0, "invalid key %q", key)
+		}
+		parts, err := splitPath(key)
+		if err != nil || len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+			return configs, fail(0, "invalid key %q", key)
+		}
+		pkg := parts[0]
+		cfg := byName[pkg]
+		if cfg == nil {
+			cfg = NewConfig(pkg)
+			byName[pkg] = cfg
+			configs = append(configs, cfg)
+		}
+
+		values, err := splitShowValue(value)
+		if err != nil {
+			return configs, fail(eq+1, "%v", err)
+		}
+
+		path := Path{Config: pkg, Section: parts[1]}
+		if len(parts) == 2 {
+			// package.section=type
+			if len(values) != 1 || !isIdent(values[0]) {
+				return configs, fail(eq+1, "invalid section type %q", value)
+			}
+			if sections[path] != nil {
+				return configs, fail(0, "duplicate section %s", path)
+			}
+			name := parts[1]
+			if _, hash, ok := ParseAnonymousID(name); ok && uint16(djbhash(values[0])) == hash ||
+				strings.HasPrefix(name, "@") {
+				name = ""
+			}
+			sections[path] = cfg.Add(NewSection(values[0], name))
+			continue
+		}
+
+		// package.section.option=value
+		sec := sections[path]
+		if sec == nil {
+			return configs, fail(0, "option of undeclared section %s", path)
+		}
+		typ := TypeOption
+		if len(values) > 1 {
+			typ = TypeList
+		}
+		sec.Add(NewOption(parts[2], typ, values...))
+	}
+	return configs, nil
+}
+
+// splitShowValue splits a value printed by "uci show" into words,
+// removing quotes and backslash escapes, like a shell would.
+func splitShowValue(v string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		started bool // word may be empty, e.g. ''
+	)
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case ' ', '\t':
+			if started {
+				words = append(words, word.String())
+				word.Reset()
+				started = false
+			}
+		case '\'':
+			end := strings.IndexByte(v[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted string")
+			}
+			word.WriteString(v[i+1 : i+1+end])
+			started = true
+			i += end + 1
+		case '\\':
+			if i+1 < len(v) {
+				i++
+			}
+			word.WriteByte(v[i])
+			started = true
+		default:
+			word.WriteByte(c)
+			started = true
+		}
+	}
+	if started {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, errors.New("missing value")
+	}
+	return words, nil
+}
@@ -0,0 +1,159 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcShowInput = `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+
+config route
+	option target '10.0.0.0/8'
+	option description "it's a route"
+
+config route
+	option target '172.16.0.0/12'
+`
+
+const tcShowOutput = `network.lan=interface
+network.lan.proto='static'
+network.lan.ipaddr='192.168.1.1'
+network.lan.dns='1.1.1.1' '9.9.9.9'
+network.@route[0]=route
+network.@route[0].target='10.0.0.0/8'
+network.@route[0].description='it'\''s a route'
+network.@route[1]=route
+network.@route[1].target='172.16.0.0/12'
+`
+
+func TestShow(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("network", tcShowInput)
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	require.NoError(cfg.Show(&buf))
+	assert.Equal(tcShowOutput, buf.String())
+
+	configs, err := ParseShow(buf.String())
+	require.NoError(err)
+	require.Len(configs, 1)
+	assert.Equal(cfg.Hash(), configs[0].Hash())
+	assert.Equal("", configs[0].Sections[1].Name)
+}
+
+func TestShowIDs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("network", tcShowInput)
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	require.NoError(cfg.ShowIDs(&buf))
+	assert.Equal(`network.lan=interface
+network.lan.proto='static'
+network.lan.ipaddr='192.168.1.1'
+network.lan.dns='1.1.1.1' '9.9.9.9'
+network.cfg02c8b4=route
+network.cfg02c8b4.target='10.0.0.0/8'
+network.cfg02c8b4.description='it'\''s a route'
+network.cfg03c8b4=route
+network.cfg03c8b4.target='172.16.0.0/12'
+`, buf.String())
+
+	configs, err := ParseShow(buf.String())
+	require.NoError(err)
+	require.Len(configs, 1)
+	assert.Equal(cfg.Hash(), configs[0].Hash())
+	assert.Equal("", configs[0].Sections[1].Name)
+
+	// IDs not matching the type are section names
+	configs, err = ParseShow("network.cfg02c8b4=interface\n")
+	require.NoError(err)
+	assert.Equal("cfg02c8b4", configs[0].Sections[0].Name)
+}
+
+func TestParseShow(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	configs, err := ParseShow("system.@system[0]=system\n" +
+		"system.@system[0].hostname='OpenWrt'\n" +
+		"network.wan=interface\n" +
+		"network.wan.proto=dhcp\n" +
+		"network.wan.empty=''\n" +
+		"system.ntp=timeserver\n" +
+		"system.ntp.server='0.pool' \"1.pool\"\n")
+	require.NoError(err)
+	require.Len(configs, 2)
+	assert.Equal("system", configs[0].Name)
+	assert.Equal("network", configs[1].Name)
+
+	sys := configs[0].Get("@system[0]")
+	require.NotNil(sys)
+	assert.Equal([]string{"OpenWrt"}, sys.Get("hostname").Values)
+
+	wan := configs[1].Get("wan")
+	assert.Equal([]string{"dhcp"}, wan.Get("proto").Values)
+	assert.Equal([]string{""}, wan.Get("empty").Values)
+
+	server := configs[0].Get("ntp").Get("server")
+	assert.Equal(TypeList, server.Type)
+	assert.Equal([]string{"0.pool", `"1.pool"`}, server.Values)
+}
+
+func TestShowDottedNames(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := NewConfig("p")
+	cfg.Add(NewSection("host", "a.b")).SetOption("ip", "10.0.0.1")
+	cfg.Add(NewSection("host", ""))
+	var b bytes.Buffer
+	require.NoError(cfg.Show(&b))
+	assert.Equal("p.'a.b'=host\np.'a.b'.ip='10.0.0.1'\np.@host[1]=host\n", b.String())
+
+	configs, err := ParseShow(b.String())
+	require.NoError(err)
+	require.Len(configs, 1)
+	assert.Equal("a.b", configs[0].Sections[0].Name)
+	assert.Equal("10.0.0.1", configs[0].Get("a.b").LastValue("ip"))
+	assert.Equal("", configs[0].Sections[1].Name)
+}
+
+func TestParseShow_errors(t *testing.T) {
+	tt := []struct {
+		input string
+		msg   string
+	}{
+		{"network.lan=interface\nnetwork.lan.proto\n", "2:1: parse error: expected key=value, got \"network.lan.proto\""},
+		{"network=interface\n", "1:1: parse error: invalid key \"network\""},
+		{"=interface\n", "1:1: parse error: invalid key \"\""},
+		{"network.'lan=interface\n", "1:1: parse error: invalid key \"network.'lan\""},
+		{"network.lan.proto.x=static\n", "1:1: parse error: invalid key \"network.lan.proto.x\""},
+		{"network.lan=inter face\n", "1:13: parse error: invalid section type \"inter face\""},
+		{"network.lan=interface\nnetwork.lan=interface\n", "2:1: parse error: duplicate section network.lan"},
+		{"network.lan.proto='static'\n", "1:1: parse error: option of undeclared section network.lan"},
+		{"network.lan=interface\nnetwork.lan.proto='static\n", "2:19: parse error: unterminated quoted string"},
+		{"network.lan=interface\nnetwork.lan.proto=\n", "2:19: parse error: missing value"},
+	}
+	for _, tc := range tt {
+		_, err := ParseShow(tc.input)
+		assert.EqualError(t, err, tc.msg)
+
+		var perr *ParseError
+		assert.True(t, errors.As(err, &perr))
+	}
+}
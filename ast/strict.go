@@ -0,0 +1,99 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrDuplicateOption   = errors.New("duplicate option")
+	ErrDuplicateSection  = errors.New("duplicate section")
+	ErrMissingWhitespace = errors.New("missing whitespace")
+	ErrStrayToken        = errors.New("stray token")
+	ErrMixedIndentation  = errors.New("mixed indentation")
+)
+
+// ParseStrict works like Parse, but additionally rejects input which
+// uci accepts, but which most likely is a mistake:
+//
+//   - an option defined more than once in a section (Parse keeps the
+//     last value), or an option redefined as list (and vice versa)
+//   - a named section redefined with another type (Parse keeps the
+//     first type)
+//   - keywords, names and values not separated by whitespace (e.g.
+//     "optionfoo 'bar'", which Parse reads as option "foo")
+//   - more than one statement on a line
+//   - lines indented with both tabs and spaces, or files using tabs
+//     in some lines and spaces in others
+//
+// Like Parse, it fails on syntax errors (including options without
+// value). All errors are returned as *PositionError. This is useful to
+// validate configs in CI pipelines, before they are shipped to devices.
+func ParseStrict(name, input string) (*Config, error) {
+	cfg, _, err := parse(name, input, Parser{Strict: true}, false)
+	return cfg, err
+}
+
+// checkSeparated ensures that the items of a token are preceded by
+// whitespace, and that the token is the only one on its line.
+func checkSeparated(input string, items []item, idx *lineIndex) error {
+	for _, it := range items {
+		if it.pos > 0 && !isSpace(rune(input[it.pos-1])) {
+			return &PositionError{
+				Pos: idx.position(it.pos),
+				Err: fmt.Errorf("%w before %q", ErrMissingWhitespace, it.val),
+			}
+		}
+	}
+
+	// the keyword must be the first word on the line
+	start := items[0].pos
+	for start > 0 && input[start-1] != '\n' {
+		start--
+	}
+	prefix := strings.TrimRight(input[start:items[0].pos], " \t")
+	if len(strings.Fields(prefix)) != 1 {
+		keyword := start + strings.LastIndexAny(prefix, " \t") + 1
+		return &PositionError{
+			Pos: idx.position(keyword),
+			Err: fmt.Errorf("%w: more than one statement on a line", ErrStrayToken),
+		}
+	}
+	return nil
+}
+
+// checkIndentation ensures that lines are either indented with tabs or
+// with spaces, but not both.
+func checkIndentation(input string, idx *lineIndex) error {
+	var style byte // first indentation character seen
+	offset := 0
+	for _, line := range strings.SplitAfter(input, "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if indent != "" && strings.TrimSpace(line) != "" {
+			switch {
+			case strings.Contains(indent, " ") && strings.Contains(indent, "\t"):
+				return &PositionError{
+					Pos: idx.position(offset),
+					Err: fmt.Errorf("%w: tabs and spaces", ErrMixedIndentation),
+				}
+			case style == 0:
+				style = indent[0]
+			case indent[0] != style:
+				return &PositionError{
+					Pos: idx.position(offset),
+					Err: fmt.Errorf("%w: %s, but previous lines use %s", ErrMixedIndentation, indentName(indent[0]), indentName(style)),
+				}
+			}
+		}
+		offset += len(line)
+	}
+	return nil
+}
+
+func indentName(c byte) string {
+	if c == '\t' {
+		return "tabs"
+	}
+	return "spaces"
+}
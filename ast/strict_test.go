@@ -0,0 +1,59 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStrict(t *testing.T) {
+	tt := []struct {
+		name     string
+		input    string
+		expected error
+		message  string
+	}{
+		{"valid", "config a 'x'\n\toption foo 'a'\n\tlist bar 'b'\n\tlist bar 'c'\n\nconfig a\n\toption foo bar # comment\n", nil, ""},
+		{"spaces", "config a 'x'\n  option foo 'a'\n  option bar 'b'\n", nil, ""},
+		{"duplicate option", "config a 'x'\n\toption foo 'a'\n\toption foo 'b'\n", ErrDuplicateOption, "test:3:9: duplicate option: foo"},
+		{"duplicate in reopened section", "config a 'x'\n\toption foo 'a'\n\nconfig a 'x'\n\toption foo 'b'\n", ErrDuplicateOption, "test:5:9: duplicate option: foo"},
+		{"option as list", "config a 'x'\n\toption foo 'a'\n\tlist foo 'b'\n", ErrDuplicateOption, "test:3:7: duplicate option: foo"},
+		{"redefined type", "config a 'x'\n\toption foo 'a'\n\nconfig b 'x'\n\toption bar 'b'\n", ErrDuplicateSection, "test:4:8: duplicate section: x redefined as b, was a"},
+		{"list as option", "config a 'x'\n\tlist foo 'a'\n\toption foo 'b'\n", ErrDuplicateOption, "test:3:9: duplicate option: foo"},
+		{"glued keyword", "config a 'x'\n\toptionfoo 'a'\n", ErrMissingWhitespace, `test:2:8: missing whitespace before "foo"`},
+		{"glued config", "configa\n", ErrMissingWhitespace, `test:1:7: missing whitespace before "a"`},
+		{"glued value", "config a 'x'\n\toption foo'a'\n", ErrMissingWhitespace, `test:2:12: missing whitespace before "a"`},
+		{"two statements", "config a 'x'\n\toption foo 'a' option bar 'b'\n", ErrStrayToken, "test:2:17: stray token: more than one statement on a line"},
+		{"mixed line", "config a 'x'\n\t option foo 'a'\n", ErrMixedIndentation, "test:2:1: mixed indentation: tabs and spaces"},
+		{"mixed file", "config a 'x'\n\toption foo 'a'\n    option bar 'b'\n", ErrMixedIndentation, "test:3:1: mixed indentation: spaces, but previous lines use tabs"},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := ParseStrict("test", tc.input)
+			if tc.expected == nil {
+				assert.NoError(err)
+				return
+			}
+			assert.True(errors.Is(err, tc.expected), "got %v", err)
+			assert.EqualError(err, tc.message)
+
+			var perr *PositionError
+			assert.True(errors.As(err, &perr))
+
+			// the lenient parser accepts the input
+			_, err = Parse("test", tc.input)
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestParseStrict_syntax(t *testing.T) {
+	_, err := ParseStrict("test", "config a 'x'\n\toption foo\n")
+	var perr *ParseError
+	assert.True(t, errors.As(err, &perr))
+}
@@ -0,0 +1,173 @@
+package ast
+
+import "strings"
+
+// A QuoteMode determines how Config.Write quotes names and values.
+type QuoteMode int
+
+const (
+	// QuoteSingle encloses all values in single quotes ('value'), like
+	// uci does.
+	QuoteSingle QuoteMode = iota
+
+	// QuoteDouble encloses values in double quotes ("value"). Values
+	// containing double quotes or backslashes use single quotes.
+	QuoteDouble
+
+	// QuoteMinimal leaves values unquoted, if they consist of letters,
+	// digits and any of "-_.:/@+", and uses single quotes otherwise.
+	QuoteMinimal
+)
+
+// Style describes the formatting of Config.Write. The zero value is the
+// default style: options indented by a tab, values in single quotes,
+// sections separated by blank lines, a blank line at the end, and Unix
+// line endings.
+type Style struct {
+	Indent            string // indentation of options; empty means a tab
+	Quote             QuoteMode
+	NoBlankLines      bool // omit blank lines between (and before) sections
+	NoTrailingNewline bool // omit the blank line at the end
+
+	CRLF bool // end lines with "\r\n", as Windows editors do
+	BOM  bool // start with a UTF-8 byte order mark
+
+	// PreserveEncoding overrides CRLF and BOM with the conventions of
+	// the file the config was parsed from (configs created in memory
+	// use Unix line endings without BOM).
+	PreserveEncoding bool
+}
+
+// textEncoding records the quirks of a parsed file, see
+// Style.PreserveEncoding.
+type textEncoding struct {
+	crlf bool // the first line ends with "\r\n"
+	bom  bool
+}
+
+func detectEncoding(input string) textEncoding {
+	var enc textEncoding
+	enc.bom = strings.HasPrefix(input, byteOrderMark)
+	if i := strings.IndexByte(input, '\n'); i > 0 {
+		enc.crlf = input[i-1] == '\r'
+	}
+	return enc
+}
+
+// WithStyle formats the output according to s.
+func WithStyle(s Style) WriteOption {
+	return func(o *writeOptions) {
+		o.style = s
+	}
+}
+
+var defaultStyle Style
+
+func (st *Style) indent() string {
+	if st.Indent == "" {
+		return "\t"
+	}
+	return st.Indent
+}
+
+func (st *Style) appendNewline(b []byte) []byte {
+	if st.CRLF {
+		return append(b, '\r', '\n')
+	}
+	return append(b, '\n')
+}
+
+func (st *Style) appendQuoted(b []byte, v string) []byte {
+	q := byte('\'')
+	switch st.Quote {
+	case QuoteSingle:
+	case QuoteDouble:
+		if quotable(v, '"') {
+			q = '"'
+		}
+	case QuoteMinimal:
+		if isBareWord(v) {
+			return append(b, v...)
+		}
+	}
+	if q == '\'' && !quotable(v, q) {
+		switch {
+		case quotable(v, '"'):
+			q = '"' // e.g. "it's"
+		case isRawWord(v):
+			return append(b, v...) // e.g. it's_"quoted"
+		}
+	}
+	b = append(b, q)
+	b = append(b, v...)
+	return append(b, q)
+}
+
+// isRawWord reports whether the lexer reads v back unchanged without
+// quotes. Unlike isBareWord, it accepts any character, which doesn't
+// end an unquoted value.
+func isRawWord(v string) bool {
+	if v == "" || v[0] == '\'' || v[0] == '"' {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+			if i == len(v) {
+				return false
+			}
+		case ' ', '\t', '\r', '\n', '#':
+			return false
+		}
+	}
+	return true
+}
+
+// appendName appends a section name. Unlike values, names can't be
+// written unquoted, unless they are plain names.
+func (st *Style) appendName(b []byte, name string) []byte {
+	if st.Quote == QuoteMinimal && isName(name) {
+		return append(b, name...)
+	}
+	q := byte('\'')
+	if st.Quote == QuoteDouble && quotable(name, '"') || !quotable(name, q) {
+		q = '"'
+	}
+	b = append(b, q)
+	b = append(b, name...)
+	return append(b, q)
+}
+
+// quotable reports whether the lexer reads v enclosed in q back
+// unchanged. As it keeps escape sequences verbatim, v must not contain
+// an unescaped q, nor end in an unpaired backslash. Line breaks are
+// written as they are.
+func quotable(v string, q byte) bool {
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+			if i == len(v) {
+				return false
+			}
+		case q:
+			return false
+		}
+	}
+	return true
+}
+
+// isBareWord reports whether v can be written without quotes.
+func isBareWord(v string) bool {
+	if v == "" {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.:/@+", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStyle(t *testing.T) {
+	const input = `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1/24'
+	option description 'LAN port'
+	list dns '1.1.1.1'
+
+config rule
+	option name 'say "hi"'
+`
+	cfg, err := Parse("network", input)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		style  Style
+		output string
+	}{
+		{"default", Style{}, input + "\n"},
+		{"double", Style{Quote: QuoteDouble, Indent: "    "}, `
+config interface "lan"
+    option proto "static"
+    option ipaddr "192.168.1.1/24"
+    option description "LAN port"
+    list dns "1.1.1.1"
+
+config rule
+    option name 'say "hi"'
+
+`},
+		{"minimal", Style{Quote: QuoteMinimal, NoBlankLines: true, NoTrailingNewline: true}, `config interface lan
+	option proto static
+	option ipaddr 192.168.1.1/24
+	option description 'LAN port'
+	list dns 1.1.1.1
+config rule
+	option name 'say "hi"'
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := cfg.Write(&buf, WithStyle(tc.style))
+			require.NoError(t, err)
+			assert.Equal(t, tc.output, buf.String())
+
+			parsed, err := Parse("network", buf.String())
+			require.NoError(t, err)
+			assert.Empty(t, Diff(cfg, parsed))
+		})
+	}
+}
+
+func TestWindowsEncoding(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const unix = "\nconfig interface 'lan'\n\toption proto static\n\tlist dns '1.1.1.1' # comment\n\nconfig rule\n\n"
+	want, err := Parse("network", unix)
+	require.NoError(err)
+
+	for name, input := range map[string]string{
+		"crlf":     strings.ReplaceAll(unix, "\n", "\r\n"),
+		"bom":      byteOrderMark + unix,
+		"crlf+bom": byteOrderMark + strings.ReplaceAll(unix, "\n", "\r\n"),
+	} {
+		cfg, err := Parse("network", input)
+		require.NoError(err, name)
+		assert.Empty(Diff(want, cfg), name)
+
+		cfg, err = ParseStrict("network", input)
+		require.NoError(err, name)
+		assert.Empty(Diff(want, cfg), name)
+
+		// the original conventions are kept only on request
+		var buf bytes.Buffer
+		_, err = cfg.Write(&buf)
+		require.NoError(err)
+		assert.NotContains(buf.String(), "\r", name)
+		assert.False(strings.HasPrefix(buf.String(), byteOrderMark), name)
+
+		buf.Reset()
+		_, err = cfg.Clone().Write(&buf, WithStyle(Style{PreserveEncoding: true}))
+		require.NoError(err)
+		assert.Equal(strings.Contains(input, "\r"), strings.Contains(buf.String(), "\r\n"), name)
+		assert.NotContains(strings.ReplaceAll(buf.String(), "\r\n", ""), "\r", name)
+		assert.Equal(strings.HasPrefix(input, byteOrderMark), strings.HasPrefix(buf.String(), byteOrderMark), name)
+	}
+
+	var buf bytes.Buffer
+	_, err = want.Write(&buf, WithStyle(Style{CRLF: true, BOM: true}))
+	require.NoError(err)
+	assert.Equal("\ufeff\r\nconfig interface 'lan'\r\n\toption proto 'static'\r\n\tlist dns '1.1.1.1'\r\n\r\nconfig rule\r\n\r\n", buf.String())
+}
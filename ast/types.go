@@ -0,0 +1,838 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NOTE: config, section and option types basically are AST nodes for the
+// parser. The JSON struct tags are mainly for development and testing
+// purposes: We'er generating JSON dumps of the tree when running tests
+// with DUMP="json". After a manual comparison with the corresponding UCI
+// file in testdata/, we can use the dumps to read them back as test case
+// expectations.
+
+// Config represents a file in UCI. It consists of sections.
+type Config struct {
+	Name     string     `json:"name"`
+	Sections []*Section `json:"sections,omitempty"`
+
+	tainted  bool // changed by tree methods when things were modified
+	index    sectionIndexHolder
+	encoding textEncoding // of the parsed input
+	included []string     // names of the inlined fragments, see Inline
+}
+
+// NewConfig returns a new config object.
+func NewConfig(name string) *Config {
+	return &Config{
+		Name:     name,
+		Sections: make([]*Section, 0, 1),
+	}
+}
+
+// WriteTo serializes the config in UCI syntax. It implements
+// io.WriterTo.
+func (c *Config) WriteTo(w io.Writer) (n int64, err error) {
+	return c.Write(w)
+}
+
+// Write serializes the config in UCI syntax, like WriteTo, but accepts
+// options to modify the output.
+//
+// The output is streamed to w in chunks of about 32 KiB, so that large
+// configs don't need to be held in memory twice. Nothing is written, if
+// an option has multiple values, but isn't a list (ErrMultipleValues).
+func (c *Config) Write(w io.Writer, opts ...WriteOption) (n int64, err error) {
+	if err := c.checkTypes(); err != nil {
+		return 0, err
+	}
+	var o writeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.redact) > 0 {
+		c = c.Redacted(o.redact...)
+	}
+	if o.style.PreserveEncoding {
+		o.style.CRLF, o.style.BOM = c.encoding.crlf, c.encoding.bom
+	}
+
+	bufp := writeBufPool.Get().(*[]byte)
+	buf := (*bufp)[:0]
+	defer func() {
+		if cap(buf) <= maxPooledBuf {
+			*bufp = buf[:0]
+			writeBufPool.Put(bufp)
+		}
+	}()
+
+	flush := func() error {
+		m, err := w.Write(buf)
+		n += int64(m)
+		buf = buf[:0]
+		return err
+	}
+	if o.style.BOM {
+		buf = append(buf, byteOrderMark...)
+	}
+	for _, sec := range c.Sections {
+		if sec.include != "" && sec.include != c.Name {
+			continue // written to its fragment
+		}
+		buf = o.style.appendSection(buf, sec)
+		if len(buf) >= writeChunkSize {
+			if err = flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if !o.style.NoTrailingNewline {
+		buf = o.style.appendNewline(buf)
+	}
+	err = flush()
+	return n, err
+}
+
+const (
+	writeChunkSize = 32 << 10
+	maxPooledBuf   = 4 * writeChunkSize // don't keep huge buffers around
+)
+
+var writeBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 2*writeChunkSize)
+		return &b
+	},
+}
+
+// AppendText appends the config in UCI syntax to b, and returns the
+// extended buffer. It implements encoding.TextAppender, and fails like
+// Write.
+func (c *Config) AppendText(b []byte) ([]byte, error) {
+	if err := c.checkTypes(); err != nil {
+		return b, err
+	}
+	for _, sec := range c.Sections {
+		b = defaultStyle.appendSection(b, sec)
+	}
+	return append(b, '\n'), nil
+}
+
+func (st *Style) appendSection(b []byte, sec *Section) []byte {
+	if !st.NoBlankLines {
+		b = st.appendNewline(b)
+	}
+	b = append(b, "config "...)
+	b = append(b, sec.Type...)
+	if sec.Name != "" && !IsPlaceholderName(sec.Name, sec.Type) {
+		b = append(b, ' ')
+		b = st.appendName(b, sec.Name)
+	}
+	b = st.appendNewline(b)
+
+	for _, opt := range sec.Options {
+		switch opt.Type {
+		case TypeOption:
+			if len(opt.Values) > 0 { // can't be represented otherwise
+				b = st.appendOption(b, "option", opt.Name, opt.Values[0])
+			}
+		case TypeList:
+			for _, v := range opt.Values {
+				b = st.appendOption(b, "list", opt.Name, v)
+			}
+		}
+	}
+	return b
+}
+
+func (st *Style) appendOption(b []byte, keyword, name, value string) []byte {
+	b = append(b, st.indent()...)
+	b = append(b, keyword...)
+	b = append(b, ' ')
+	b = append(b, name...)
+	b = append(b, ' ')
+	b = st.appendQuoted(b, value)
+	return st.appendNewline(b)
+}
+
+// Get fetches a section by name.
+//
+// Support for unnamed Section notation (@foo[idx]) is present, as is
+// for the IDs libuci assigns to unnamed sections (see AnonymousID).
+func (c *Config) Get(name string) *Section {
+	if strings.HasPrefix(name, "@") {
+		if sec, _ := c.getUnnamed(name); sec != nil {
+			return sec
+		}
+		// the parser keeps names of unresolvable selectors
+	}
+	if sec := c.getNamed(name); sec != nil || !strings.HasPrefix(name, "cfg") {
+		return sec
+	}
+	return c.getAnonymous(name)
+}
+
+func (c *Config) getNamed(name string) *Section {
+	return c.lookupNamed(name)
+}
+
+var (
+	ErrImplausibleSectionSelector = errors.New("implausible section selector: must be at least 5 characters long")
+	ErrMustStartWithAt            = errors.New("invalid syntax: section selector must start with @ sign")
+	ErrMultipleAtSigns            = errors.New("invalid syntax: multiple @ signs found")
+	ErrMultipleOpenBrackets       = errors.New("invalid syntax: multiple open brackets found")
+	ErrMultipleCloseBrackets      = errors.New("invalid syntax: multiple closed brackets found")
+	ErrInvalidSectionSelector     = errors.New("invalid syntax: section selector must have format '@type[index]'")
+)
+
+func unmangleSectionName(name string) (typ string, index int, err error) { //nolint:cyclop
+	l := len(name)
+	if l < 5 { // "@a[0]"
+		err = ErrImplausibleSectionSelector
+		return
+	}
+	if name[0] != '@' {
+		err = ErrMustStartWithAt
+		return
+	}
+
+	bra, ket := 0, l-1 // bracket positions
+	for i, r := range name {
+		switch {
+		case i != 0 && r == '@':
+			err = ErrMultipleAtSigns
+			return
+		case r == '[' && bra > 0:
+			err = ErrMultipleOpenBrackets
+			return
+		case r == ']' && i != ket:
+			err = ErrMultipleCloseBrackets
+			return
+		case r == '[':
+			bra = i
+		}
+	}
+
+	if bra <= 1 || bra >= ket || name[ket] != ']' { // bra == 1: empty type
+		err = ErrInvalidSectionSelector
+		return
+	}
+
+	typ = name[1:bra]
+	index, err = strconv.Atoi(name[bra+1 : ket])
+	if err != nil {
+		err = fmt.Errorf("invalid syntax: index must be numeric: %w", err)
+	}
+	return typ, index, err
+}
+
+var ErrUnnamedIndexOutOfBounds = errors.New("invalid name: index out of bounds")
+
+func (c *Config) getUnnamed(name string) (*Section, error) {
+	typ, idx, err := unmangleSectionName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sec, _ := c.lookupType(typ, idx) // negative idx count from the end
+	if sec == nil {
+		return nil, ErrUnnamedIndexOutOfBounds
+	}
+	return sec, nil
+}
+
+// Lookup works like Get, but returns an ErrSectionNotFound instead of
+// nil, if the section does not exist, and the syntax error of malformed
+// selectors.
+func (c *Config) Lookup(name string) (*Section, error) {
+	if sec := c.Get(name); sec != nil {
+		return sec, nil
+	}
+	if strings.HasPrefix(name, "@") {
+		if _, _, err := unmangleSectionName(name); err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrSectionNotFound{Config: c.Name, Section: name}
+}
+
+func (c *Config) Add(s *Section) *Section {
+	before := c.Sections
+	c.Sections = append(c.Sections, s)
+	c.indexAppended(before)
+	return s
+}
+
+func (c *Config) Insert(index int, s *Section) *Section {
+	if index > len(c.Sections)-1 {
+		return c.Add(s)
+	}
+
+	if index <= 0 {
+		// insert at the beginning of the slice
+		sections := make([]*Section, 0, len(c.Sections)+1)
+		sections = append(sections, s)
+		sections = append(sections, c.Sections...)
+		c.Sections = sections
+		c.Reindex()
+
+		return s
+	}
+
+	var sections []*Section
+	sections = append(sections, c.Sections[:index]...)
+	sections = append(sections, s)
+	sections = append(sections, c.Sections[index:]...)
+	c.Sections = sections
+	c.Reindex()
+	return s
+}
+
+// Merge merges s into the section of c with the same name (or, if the
+// name of s is an "@type[index]" selector, into the selected section),
+// and returns it. See Section.MergeOptions. If there is no such section,
+// s is appended. Unnamed sections not part of c are always appended;
+// use MergeConfig to match them by their selector in another config.
+func (c *Config) Merge(s *Section) *Section {
+	var sec *Section
+	if name := c.SectionName(s); name != "" {
+		sec = c.find(name)
+	}
+	if sec == nil {
+		return c.Add(s)
+	}
+	sec.MergeOptions(s)
+	return sec
+}
+
+func (c *Config) MergeReplace(original, section *Section) {
+	for index := range original.Options {
+		name := original.Options[index].Name
+		r := section.Get(name)
+		if r != nil {
+			original.Options[index] = r
+		}
+	}
+
+	for index := range section.Options {
+		name := section.Options[index].Name
+		r := original.Get(name)
+		if r == nil {
+			original.Add(section.Options[index])
+		}
+	}
+}
+
+func (c *Config) Del(name string) {
+	// parse "@type[index]" selectors once, not per section
+	typ, index, err := unmangleSectionName(name)
+	selector := err == nil && IsPlaceholderName(name, typ)
+
+	var i, n int // n counts the sections of type typ
+	for i = 0; i < len(c.Sections); i++ {
+		if selector && c.Sections[i].Type == typ {
+			if n == index {
+				break
+			}
+			n++
+		}
+
+		if c.Sections[i].Name == name {
+			break
+		}
+	}
+	if i < len(c.Sections) {
+		c.Sections = append(c.Sections[:i], c.Sections[i+1:]...)
+		c.Reindex()
+	}
+}
+
+// remove removes the section s.
+func (c *Config) remove(s *Section) {
+	for i, sec := range c.Sections {
+		if sec == s {
+			c.Sections = append(c.Sections[:i], c.Sections[i+1:]...)
+			c.Reindex()
+			return
+		}
+	}
+}
+
+func (c *Config) SetTainted() {
+	c.tainted = true
+}
+
+// Tainted reports whether the config was modified since it was loaded
+// or last written.
+func (c *Config) Tainted() bool {
+	return c.tainted
+}
+
+// ResetTainted marks the config as unmodified, e.g. after it has been
+// written back to disk.
+func (c *Config) ResetTainted() {
+	c.tainted = false
+}
+
+// SectionName returns the name of s, or its synthetic "@type[index]"
+// selector, if s is unnamed. Unnamed sections, which are not part of c,
+// have no selector; an empty string is returned for them.
+func (c *Config) SectionName(s *Section) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	n := c.ordinal(s)
+	if n < 0 {
+		return ""
+	}
+	return fmt.Sprintf("@%s[%d]", s.Type, n)
+}
+
+// find returns the first section, whose name (or synthetic name, for
+// unnamed sections) equals name.
+func (c *Config) find(name string) *Section {
+	if !strings.HasPrefix(name, "@") {
+		return c.lookupNamed(name)
+	}
+	counts := make(map[string]int)
+	for _, sec := range c.Sections {
+		if sec.Name == name {
+			return sec
+		}
+		if sec.Name == "" && name == fmt.Sprintf("@%s[%d]", sec.Type, counts[sec.Type]) {
+			return sec
+		}
+		counts[sec.Type]++
+	}
+	return nil
+}
+
+func (c *Config) count(typ string) (n int) {
+	for _, sec := range c.Sections {
+		if sec.Type == typ {
+			n++
+		}
+	}
+	return
+}
+
+// A Section represents a group of options in UCI. It may be named or
+// unnamed. In the latter case, its synthetic name is constructed from
+// the Section type and index (e.g. "@system[0]").
+type Section struct {
+	Name    string    `json:"name,omitempty"`
+	Type    string    `json:"type"`
+	Options []*Option `json:"options,omitempty"`
+
+	include string // fragment the section was inlined from, see Config.Inline
+}
+
+// NewSection returns a new Section object.
+func NewSection(typ, name string) *Section {
+	return &Section{
+		Type:    typ,
+		Name:    name,
+		Options: make([]*Option, 0, 1),
+	}
+}
+
+func (s *Section) Add(o *Option) *Option {
+	s.Options = append(s.Options, o)
+	return o
+}
+
+func (s *Section) Insert(index int, o *Option) *Option {
+	if index > len(s.Options)-1 {
+		return s.Add(o)
+	}
+
+	if index <= 0 {
+		// insert at the beginning of the slice
+		options := make([]*Option, 0, len(s.Options)+1)
+		options = append(options, o)
+		options = append(options, s.Options...)
+		s.Options = options
+
+		return o
+	}
+
+	var options []*Option
+	options = append(options, s.Options[:index]...)
+	options = append(options, o)
+	options = append(options, s.Options[index:]...)
+	s.Options = options
+	return o
+}
+
+func (s *Section) Merge(o *Option) {
+	for _, opt := range s.Options {
+		if opt.Name == o.Name {
+			opt.MergeValues(o.Values...)
+			return
+		}
+	}
+	s.Options = append(s.Options, o)
+}
+
+// Del removes an Option with the given name. It returns whether the
+// Option actually existed.
+func (s *Section) Del(name string) bool {
+	var i int
+	for i = 0; i < len(s.Options); i++ {
+		if s.Options[i].Name == name {
+			break
+		}
+	}
+
+	if i == len(s.Options) {
+		return false
+	}
+
+	s.Options = append(s.Options[:i], s.Options[i+1:]...)
+
+	return true
+}
+
+// Get fetches an Option by name.
+func (s *Section) Get(name string) *Option {
+	for _, opt := range s.Options {
+		if opt.Name == name {
+			return opt
+		}
+	}
+	return nil
+}
+
+// Lookup works like Get, but returns ErrOptionNotFound (wrapped)
+// instead of nil, if the option does not exist.
+func (s *Section) Lookup(name string) (*Option, error) {
+	if opt := s.Get(name); opt != nil {
+		return opt, nil
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrOptionNotFound)
+}
+
+// AddListValue appends v to the named list, according to the policy p,
+// and returns the list. Missing lists are created, and options are
+// converted to lists, like "uci add_list" does.
+func (s *Section) AddListValue(name string, p DuplicatePolicy, v string) *Option {
+	opt := s.Get(name)
+	if opt == nil {
+		return s.Add(NewOption(name, TypeList, v))
+	}
+	opt.Type = TypeList
+	opt.AppendValues(p, v)
+	return opt
+}
+
+// SetOption sets the named option to the single value v, and returns it.
+// An existing option (or list) of that name is replaced in place, so it
+// keeps its position in the section; otherwise the option is appended.
+func (s *Section) SetOption(name, v string) *Option {
+	return s.replace(name, TypeOption, []string{v})
+}
+
+// SetList sets the named list to the values vs, and returns it. Like
+// SetOption, it replaces an existing option of that name in place. An
+// empty list can't be written, hence without values the option is
+// removed, and nil is returned.
+func (s *Section) SetList(name string, vs ...string) *Option {
+	if len(vs) == 0 {
+		s.Del(name)
+		return nil
+	}
+	return s.replace(name, TypeList, append([]string(nil), vs...))
+}
+
+// ReplaceOptions copies the named options of from to s, replacing them
+// in place like SetOption and SetList. Named options missing in from are
+// removed from s. Packages rendering typed structs use it to update the
+// options they manage, keeping the others and the order of the section.
+func (s *Section) ReplaceOptions(from *Section, names ...string) {
+	for _, name := range names {
+		opt := from.Get(name)
+		if opt == nil {
+			s.Del(name)
+			continue
+		}
+		s.replace(name, opt.Type, append([]string(nil), opt.Values...))
+	}
+}
+
+// replace updates the type and values of the named option, or appends a
+// new one.
+func (s *Section) replace(name string, typ OptionType, vs []string) *Option {
+	opt := s.Get(name)
+	if opt == nil {
+		return s.Add(NewOption(name, typ, vs...))
+	}
+	opt.Type = typ
+	opt.SetValues(vs...)
+	return opt
+}
+
+func (s *Section) SaveOrInsert(option *Option) {
+	original := s.Get(option.Name)
+
+	if original == nil {
+		s.Add(option)
+	} else {
+		*original = *option
+	}
+}
+
+func (s *Section) Value(option string) []string {
+	for _, opt := range s.Options {
+		if opt.Name == option {
+			return opt.Values
+		}
+	}
+	return []string{}
+}
+
+// Fields returns the whitespace separated words of the named option's
+// values (see Option.Fields), or nil if the option does not exist.
+func (s *Section) Fields(option string) []string {
+	if opt := s.Get(option); opt != nil {
+		return opt.Fields()
+	}
+	return nil
+}
+
+func (s *Section) ValueDefault(option string, values ...string) []string {
+	for _, opt := range s.Options {
+		if opt.Name == option {
+			return opt.Values
+		}
+	}
+	return values
+}
+
+// LastValue returns the last value of the named option, or an empty
+// string, if the option does not exist or has no values.
+func (s *Section) LastValue(option string) string {
+	return s.LastValueDefault(option, "")
+}
+
+// LastValueDefault returns the last value of the named option, or value,
+// if the option does not exist or has no values.
+func (s *Section) LastValueDefault(option string, value string) string {
+	for _, opt := range s.Options {
+		if opt.Name == option && len(opt.Values) > 0 {
+			return opt.Values[len(opt.Values)-1]
+		}
+	}
+	return value
+}
+
+// An Option is the key to one or more values. Multiple values indicate
+// a list option.
+type Option struct {
+	Name   string     `json:"name"`
+	Values []string   `json:"values"`
+	Type   OptionType `json:"type"`
+}
+
+// NewOption returns a new option object.
+func NewOption(name string, optionType OptionType, values ...string) *Option {
+	return &Option{
+		Name:   name,
+		Values: values,
+		Type:   optionType,
+	}
+}
+
+func (o *Option) SetValues(vs ...string) {
+	o.Values = vs
+}
+
+// Fields returns the whitespace separated words of the values. Several
+// OpenWrt options hold multiple items in a single value (e.g. "option
+// dest_port '22 80 443'"), which services treat like a list.
+func (o *Option) Fields() []string {
+	var fields []string
+	for _, v := range o.Values {
+		fields = append(fields, strings.Fields(v)...)
+	}
+	return fields
+}
+
+// SetFields replaces the values by fields: lists get one value per
+// field, other options a single value joining the fields by spaces.
+func (o *Option) SetFields(fields []string) {
+	switch {
+	case o.Type == TypeList:
+		o.Values = append([]string(nil), fields...)
+	case len(fields) == 0:
+		o.Values = nil
+	default:
+		o.Values = []string{strings.Join(fields, " ")}
+	}
+}
+
+func (o *Option) AddValue(v string) {
+	o.Values = append(o.Values, v)
+}
+
+// MergeValues appends the values of vs, which are not yet present.
+// Unlike AppendValues(DedupeValues, vs...), it keeps the option type.
+func (o *Option) MergeValues(vs ...string) {
+	have := make(map[string]struct{})
+	for _, v := range o.Values {
+		have[v] = struct{}{}
+	}
+
+	for _, v := range vs {
+		if _, exists := have[v]; exists {
+			continue
+		}
+		o.AddValue(v)
+	}
+}
+
+var (
+	ErrValueIndexOutOfBounds = errors.New("value index out of bounds")
+	ErrValueNotFound         = errors.New("value not found")
+)
+
+// InsertValueAt inserts v before the i-th value. An index equal to the
+// number of values appends v. Options with multiple values become
+// lists.
+func (o *Option) InsertValueAt(i int, v string) error {
+	if i < 0 || i > len(o.Values) {
+		return fmt.Errorf("insert %s[%d]: %w (have %d values)", o.Name, i, ErrValueIndexOutOfBounds, len(o.Values))
+	}
+	o.Values = append(o.Values, "")
+	copy(o.Values[i+1:], o.Values[i:])
+	o.Values[i] = v
+	if len(o.Values) > 1 {
+		o.Type = TypeList
+	}
+	return nil
+}
+
+// RemoveValueAt removes the i-th value.
+func (o *Option) RemoveValueAt(i int) error {
+	if i < 0 || i >= len(o.Values) {
+		return fmt.Errorf("remove %s[%d]: %w (have %d values)", o.Name, i, ErrValueIndexOutOfBounds, len(o.Values))
+	}
+	o.Values = append(o.Values[:i], o.Values[i+1:]...)
+	return nil
+}
+
+// RemoveValue removes all occurrences of v.
+func (o *Option) RemoveValue(v string) error {
+	values := o.Values[:0]
+	for _, x := range o.Values {
+		if x != v {
+			values = append(values, x)
+		}
+	}
+	if len(values) == len(o.Values) {
+		return fmt.Errorf("remove %s: %w: %q", o.Name, ErrValueNotFound, v)
+	}
+	o.Values = values
+	return nil
+}
+
+// A DuplicatePolicy determines whether adding a value to a list, which
+// already contains it, creates a duplicate. Methods taking a policy
+// expect it right before the values to add.
+type DuplicatePolicy int
+
+const (
+	// DedupeValues skips values already present. This is what
+	// MergeValues does, when sections of the same name are merged.
+	DedupeValues DuplicatePolicy = iota
+
+	// AllowDuplicates adds values regardless, like "uci add_list". The
+	// parser keeps repeated values of list lines as well, like libuci.
+	AllowDuplicates
+)
+
+// AppendValues appends vs according to the policy p. Options with
+// multiple values become lists.
+func (o *Option) AppendValues(p DuplicatePolicy, vs ...string) {
+	for _, v := range vs {
+		if p == DedupeValues && o.hasValue(v) {
+			continue
+		}
+		o.Values = append(o.Values, v)
+	}
+	if len(o.Values) > 1 {
+		o.Type = TypeList
+	}
+}
+
+// PrependValue inserts v before the first value, according to the
+// policy p. It reports whether v was added. Options with multiple
+// values become lists.
+func (o *Option) PrependValue(p DuplicatePolicy, v string) bool {
+	if p == DedupeValues && o.hasValue(v) {
+		return false
+	}
+	_ = o.InsertValueAt(0, v)
+	return true
+}
+
+func (o *Option) hasValue(v string) bool {
+	for _, x := range o.Values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceValue replaces the first occurrence of old with v, keeping its
+// position.
+func (o *Option) ReplaceValue(old, v string) error {
+	for i := range o.Values {
+		if o.Values[i] == old {
+			o.Values[i] = v
+			return nil
+		}
+	}
+	return fmt.Errorf("replace %s: %w: %q", o.Name, ErrValueNotFound, old)
+}
+
+var placeholderSectionPattern, _ = regexp.Compile(`^@(.*?)\[(\d+)\]$`)
+
+func Num2PlaceholderSection(sectionType string, num int) string {
+	return strings.Join([]string{"@", sectionType, "[", strconv.Itoa(num), "]"}, "")
+}
+
+func PlaceholderSection2Num(section string) (int, error) {
+	submatchs := placeholderSectionPattern.FindStringSubmatch(section)
+
+	if len(submatchs) < 3 {
+		return 0, errors.New("匿名section 格式错误")
+	}
+
+	atoi, _ := strconv.Atoi(submatchs[2])
+
+	return atoi, nil
+}
+
+func IsPlaceholderName(name, secType string) bool {
+	// equivalent to matching `^@<secType>\[(\d+)\]$` (with secType
+	// taken literally), but this is called for every section when
+	// serializing, and compiling a regexp each time is way too slow
+	n := len(secType)
+	if len(name) < n+4 || name[0] != '@' || name[1:1+n] != secType || name[1+n] != '[' || name[len(name)-1] != ']' {
+		return false
+	}
+	idx := name[n+2 : len(name)-1]
+	for _, r := range idx {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,381 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmangleSectionName(t *testing.T) {
+	tt := map[string]struct {
+		typ string
+		idx int
+		err string
+	}{
+		// simple test cases
+		"":            {err: "implausible section selector: must be at least 5 characters long"},
+		"aa[0]":       {err: "invalid syntax: section selector must start with @ sign"},
+		"@@[0]":       {err: "invalid syntax: multiple @ signs found"},
+		"@@@@@@@@@@@": {err: "invalid syntax: multiple @ signs found"},
+		"@[[0]":       {err: "invalid syntax: multiple open brackets found"},
+		"@][0]":       {err: "invalid syntax: multiple closed brackets found"},
+		"@aa0]":       {err: "invalid syntax: section selector must have format '@type[index]'"},
+		"@a[b]":       {err: `invalid syntax: index must be numeric: strconv.Atoi: parsing "b": invalid syntax`},
+		"@[000":       {err: "invalid syntax: section selector must have format '@type[index]'"},
+		"@[00]":       {err: "invalid syntax: section selector must have format '@type[index]'"},
+		"@a[00":       {err: "invalid syntax: section selector must have format '@type[index]'"},
+
+		// valid test cases
+		"@a[0]":    {typ: "a", idx: 0},
+		"@a[4223]": {typ: "a", idx: 4223},
+		"@a[-1]":   {typ: "a", idx: -1},
+
+		// longer types/indices
+		"@abcdEFGHijkl[-255]": {typ: "abcdEFGHijkl", idx: -255},
+		"@abcdEFGHijkl[0xff]": {err: `invalid syntax: index must be numeric: strconv.Atoi: parsing "0xff": invalid syntax`},
+	}
+
+	for input := range tt {
+		input, tc := input, tt[input]
+		t.Run(input, func(t *testing.T) {
+			assert := assert.New(t)
+			typ, idx, err := unmangleSectionName(input)
+
+			if tc.err != "" {
+				assert.EqualError(err, tc.err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(tc.idx, idx)
+				assert.Equal(tc.typ, typ)
+			}
+		})
+	}
+}
+
+func TestIsPlaceholderName(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsPlaceholderName("@route[0]", "route"))
+	assert.True(IsPlaceholderName("@route[12]", "route"))
+	assert.False(IsPlaceholderName("@route[]", "route"))
+	assert.False(IsPlaceholderName("@route[-1]", "route"))
+	assert.False(IsPlaceholderName("@route[1]x", "route"))
+	assert.False(IsPlaceholderName("@rule[1]", "route"))
+	assert.False(IsPlaceholderName("route", "route"))
+
+	// section types are not patterns
+	assert.True(IsPlaceholderName("@a.b[0]", "a.b"))
+	assert.False(IsPlaceholderName("@axb[0]", "a.b"))
+	assert.False(IsPlaceholderName("@aaa[0]", "a+"))
+	assert.False(IsPlaceholderName("@route[0]", ".*"))
+}
+
+func BenchmarkConfigDel(b *testing.B) {
+	// deleting a section, which doesn't exist, scans all sections
+	for _, name := range []string{"@rule[5000]", "missing"} {
+		b.Run(name, func(b *testing.B) {
+			cfg := newIndexTestConfig(2000)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cfg.Del(name)
+			}
+			if len(cfg.Sections) != 4000 {
+				b.Fatal("section deleted")
+			}
+		})
+	}
+}
+
+func BenchmarkIsPlaceholderName(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !IsPlaceholderName("@rule[12]", "rule") {
+			b.Fatal("not a placeholder")
+		}
+	}
+}
+
+func TestValidIdentifier(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(ValidIdentifier("lan"))
+	assert.True(ValidIdentifier("Wan_6"))
+	assert.True(ValidIdentifier("0"))
+	assert.False(ValidIdentifier(""))
+	assert.False(ValidIdentifier("guest-wifi"))
+	assert.False(ValidIdentifier("a.b"))
+	assert.False(ValidIdentifier("küche"))
+	assert.False(ValidIdentifier("@route[0]"))
+
+	assert.True(ValidConfigName("https-dns-proxy"))
+	assert.True(ValidConfigName("luci_statistics"))
+	assert.False(ValidConfigName(""))
+	assert.False(ValidConfigName("../network"))
+	assert.False(ValidConfigName("network.bak"))
+}
+
+func TestConfigGet(t *testing.T) { //nolint:funlen
+	config, err := Parse("unnamed", tcUnnamedInput)
+	assert.NoError(t, err)
+
+	cases := []*Section{
+		// for fun, tcUnnamedInput starts with a named section. for extra
+		// fun, tcUnnamedInput extends the named section at the end.
+		{Name: "named", Type: "foo", Options: []*Option{
+			NewOption("pos", TypeOption, "3"), // gets overwritten by last section
+			NewOption("unnamed", TypeOption, "0"),
+			NewOption("list", TypeList, "0", "30"), // gets merged with last Section
+		}},
+
+		// the @foo[0] selector only compares type (foo) and index (0)
+		{Name: "@foo[0]", Type: "foo", Options: []*Option{ // alias for "named"
+			NewOption("pos", TypeOption, "3"),
+			NewOption("unnamed", TypeOption, "0"),
+			NewOption("list", TypeList, "0", "30"),
+		}},
+		{Name: "@foo[1]", Type: "foo", Options: []*Option{
+			NewOption("pos", TypeOption, "1"),
+			NewOption("unnamed", TypeOption, "1"),
+			NewOption("list", TypeOption, "10"),
+		}},
+		{Name: "@foo[2]", Type: "foo", Options: []*Option{
+			NewOption("pos", TypeOption, "2"),
+			NewOption("unnamed", TypeOption, "1"),
+			NewOption("list", TypeList, "20"),
+		}},
+
+		// negative indices count from the end
+		{Name: "@foo[-3]", Type: "foo", Options: []*Option{ // alias for "@foo[0]" == "named"
+			NewOption("pos", TypeOption, "3"),
+			NewOption("unnamed", TypeOption, "0"),
+			NewOption("list", TypeList, "0", "30"),
+		}},
+		{Name: "@foo[-2]", Type: "foo", Options: []*Option{ // alias for "@foo[1]"
+			NewOption("pos", TypeOption, "1"),
+			NewOption("unnamed", TypeOption, "1"),
+			NewOption("list", TypeList, "10"),
+		}},
+		{Name: "@foo[-1]", Type: "foo", Options: []*Option{ // alias for "@foo[2]"
+			NewOption("pos", TypeOption, "2"),
+			NewOption("unnamed", TypeOption, "1"),
+			NewOption("list", TypeList, "20"),
+		}},
+	}
+
+	for i := range cases {
+		s := cases[i]
+		for j := range s.Options {
+			o := s.Options[j]
+			t.Run("unnamed."+s.Name+"."+o.Name, func(t *testing.T) {
+				sec := config.Get(s.Name)
+				if !assert.NotNil(t, sec) {
+					return
+				}
+
+				opt := sec.Get(o.Name)
+				if !assert.NotNil(t, opt) {
+					return
+				}
+
+				assert.EqualValues(t, o.Values, opt.Values)
+			})
+		}
+	}
+}
+
+func TestOptionValueEditing(t *testing.T) {
+	assert := assert.New(t)
+
+	o := NewOption("server", TypeOption, "b")
+	assert.NoError(o.InsertValueAt(0, "a"))
+	assert.NoError(o.InsertValueAt(2, "d"))
+	assert.NoError(o.InsertValueAt(2, "c"))
+	assert.Equal([]string{"a", "b", "c", "d"}, o.Values)
+	assert.Equal(TypeList, o.Type)
+
+	assert.NoError(o.RemoveValueAt(1))
+	assert.NoError(o.RemoveValueAt(2))
+	assert.Equal([]string{"a", "c"}, o.Values)
+
+	assert.NoError(o.ReplaceValue("c", "x"))
+	assert.Equal([]string{"a", "x"}, o.Values)
+
+	for _, err := range []error{
+		o.InsertValueAt(-1, "z"),
+		o.InsertValueAt(3, "z"),
+		o.RemoveValueAt(2),
+		o.RemoveValueAt(-1),
+	} {
+		assert.True(errors.Is(err, ErrValueIndexOutOfBounds), "got %v", err)
+	}
+	assert.EqualError(o.RemoveValueAt(5), "remove server[5]: value index out of bounds (have 2 values)")
+
+	err := o.ReplaceValue("c", "y")
+	assert.True(errors.Is(err, ErrValueNotFound))
+	assert.EqualError(err, `replace server: value not found: "c"`)
+	assert.Equal([]string{"a", "x"}, o.Values)
+}
+
+func TestListValueEditing(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSection("dnsmasq", "")
+	opt := s.AddListValue("server", DedupeValues, "a")
+	assert.Equal(TypeList, opt.Type)
+	assert.Same(opt, s.AddListValue("server", DedupeValues, "b"))
+	s.AddListValue("server", DedupeValues, "a")
+	assert.Equal([]string{"a", "b"}, opt.Values)
+	s.AddListValue("server", AllowDuplicates, "a")
+	assert.Equal([]string{"a", "b", "a"}, opt.Values)
+
+	assert.False(opt.PrependValue(DedupeValues, "b"))
+	assert.True(opt.PrependValue(DedupeValues, "z"))
+	assert.True(opt.PrependValue(AllowDuplicates, "z"))
+	assert.Equal([]string{"z", "z", "a", "b", "a"}, opt.Values)
+
+	assert.NoError(opt.RemoveValue("a"))
+	assert.Equal([]string{"z", "z", "b"}, opt.Values)
+	err := opt.RemoveValue("a")
+	assert.True(errors.Is(err, ErrValueNotFound))
+	assert.EqualError(err, `remove server: value not found: "a"`)
+
+	opt.AppendValues(DedupeValues, "b", "c", "c")
+	assert.Equal([]string{"z", "z", "b", "c"}, opt.Values)
+	opt.AppendValues(AllowDuplicates, "c")
+	assert.Equal([]string{"z", "z", "b", "c", "c"}, opt.Values)
+
+	// options are converted to lists
+	s.Add(NewOption("port", TypeOption, "53"))
+	port := s.AddListValue("port", DedupeValues, "5353")
+	assert.Equal(TypeList, port.Type)
+	assert.Equal([]string{"53", "5353"}, port.Values)
+}
+
+func TestOptionFields(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSection("rule", "")
+	port := s.Add(NewOption("dest_port", TypeOption, " 22  80\t443 "))
+	proto := s.Add(NewOption("proto", TypeList, "tcp", "udp icmp"))
+	assert.Equal([]string{"22", "80", "443"}, port.Fields())
+	assert.Equal([]string{"tcp", "udp", "icmp"}, s.Fields("proto"))
+	assert.Nil(s.Fields("missing"))
+
+	port.SetFields([]string{"22", "8080"})
+	assert.Equal([]string{"22 8080"}, port.Values)
+	port.SetFields(nil)
+	assert.Empty(port.Values)
+	proto.SetFields([]string{"tcp", "udp"})
+	assert.Equal([]string{"tcp", "udp"}, proto.Values)
+}
+
+func TestSectionSetOptionList(t *testing.T) {
+	assert := assert.New(t)
+
+	sec := NewSection("foo", "bar")
+	sec.Add(NewOption("a", TypeOption, "1"))
+	b := sec.Add(NewOption("b", TypeList, "2", "3"))
+	sec.Add(NewOption("c", TypeOption, "4"))
+
+	assert.Same(b, sec.SetOption("b", "5"))
+	assert.Equal(NewOption("b", TypeOption, "5"), b)
+
+	values := []string{"6", "7"}
+	assert.Same(b, sec.SetList("b", values...))
+	values[0] = "x"
+	assert.Equal(NewOption("b", TypeList, "6", "7"), b, "values must be copied")
+
+	assert.Equal(NewOption("d", TypeList, "8"), sec.SetList("d", "8"))
+	assert.Equal(NewOption("e", TypeOption, "9"), sec.SetOption("e", "9"))
+
+	var names []string
+	for _, opt := range sec.Options {
+		names = append(names, opt.Name)
+	}
+	assert.Equal([]string{"a", "b", "c", "d", "e"}, names)
+
+	assert.Nil(sec.SetList("b"))
+	assert.Nil(sec.Get("b"))
+}
+
+func TestSectionReplaceOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	sec := NewSection("foo", "bar")
+	sec.Add(NewOption("a", TypeOption, "1"))
+	b := sec.Add(NewOption("b", TypeOption, "2"))
+	sec.Add(NewOption("c", TypeOption, "3"))
+	sec.Add(NewOption("keep", TypeOption, "4"))
+
+	from := NewSection("foo", "bar")
+	from.Add(NewOption("d", TypeOption, "5"))
+	from.Add(NewOption("b", TypeList, "6", "7"))
+
+	sec.ReplaceOptions(from, "a", "b", "d")
+	assert.Equal([]*Option{
+		b,
+		NewOption("c", TypeOption, "3"),
+		NewOption("keep", TypeOption, "4"),
+		NewOption("d", TypeOption, "5"),
+	}, sec.Options)
+	assert.Equal(NewOption("b", TypeList, "6", "7"), b)
+
+	from.Get("b").Values[0] = "x"
+	assert.Equal([]string{"6", "7"}, b.Values, "values must be copied")
+}
+
+func TestConfigWrite(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := NewConfig("test")
+	for i := 0; i < 5000; i++ {
+		sec := NewSection("item", fmt.Sprintf("sec%d", i))
+		sec.Add(NewOption("index", TypeOption, strconv.Itoa(i)))
+		sec.Add(NewOption("tags", TypeList, "a", "b"))
+		cfg.Add(sec)
+	}
+	cfg.Add(NewSection("anonymous", ""))
+
+	text, err := cfg.AppendText([]byte("# header\n"))
+	require.NoError(err)
+	assert.True(strings.HasPrefix(string(text), "# header\n\nconfig item 'sec0'\n\toption index '0'\n\tlist tags 'a'\n\tlist tags 'b'\n"))
+	assert.True(strings.HasSuffix(string(text), "\nconfig anonymous\n\n"))
+
+	// the output is larger than a chunk, and must be written in parts
+	w := &chunkWriter{}
+	n, err := cfg.WriteTo(w)
+	require.NoError(err)
+	assert.EqualValues(len(text)-len("# header\n"), n)
+	assert.Equal(string(text[len("# header\n"):]), w.buf.String())
+	assert.Greater(w.writes, 1)
+
+	parsed, err := Parse("test", w.buf.String())
+	require.NoError(err)
+	assert.Len(parsed.Sections, 5001)
+
+	// write errors are returned
+	errWrite := errors.New("disk full")
+	n, err = cfg.WriteTo(&chunkWriter{fail: errWrite})
+	assert.Equal(errWrite, err)
+	assert.EqualValues(0, n)
+}
+
+type chunkWriter struct {
+	buf    bytes.Buffer
+	writes int
+	fail   error
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.fail != nil {
+		return 0, w.fail
+	}
+	w.writes++
+	return w.buf.Write(p)
+}
@@ -0,0 +1,149 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidUnit is returned (wrapped) by ParseLeaseTime, ParseRate and
+// ParseSize, if a value is not a number with a known unit suffix.
+var ErrInvalidUnit = errors.New("invalid number or unit")
+
+// InfiniteLease is returned by ParseLeaseTime for "infinite" leases.
+const InfiniteLease = time.Duration(math.MaxInt64)
+
+var leaseUnits = []struct {
+	suffix string
+	d      time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// ParseLeaseTime parses a lease time, as used by the leasetime options
+// of dhcp pools and hosts: a number of seconds, optionally followed by
+// one of the units w, d, h, m and s (e.g. "12h"), or "infinite".
+func ParseLeaseTime(v string) (time.Duration, error) {
+	s := strings.ToLower(strings.TrimSpace(v))
+	if s == "infinite" {
+		return InfiniteLease, nil
+	}
+	unit := time.Second
+	for _, u := range leaseUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSuffix(s, u.suffix), u.d
+			break
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: lease time %q", ErrInvalidUnit, v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// FormatLeaseTime formats d for ParseLeaseTime, using the largest unit
+// which represents it exactly. Fractions of seconds are truncated.
+func FormatLeaseTime(d time.Duration) string {
+	if d == InfiniteLease {
+		return "infinite"
+	}
+	d = d.Truncate(time.Second)
+	for _, u := range leaseUnits {
+		if d != 0 && d%u.d == 0 {
+			return strconv.FormatInt(int64(d/u.d), 10) + u.suffix
+		}
+	}
+	return "0"
+}
+
+// A Rate is a number of events per time unit, as used by the limit
+// options of firewall rules (e.g. "10/minute").
+type Rate struct {
+	Count int
+	Per   time.Duration // time.Second, time.Minute, time.Hour or 24 hours
+}
+
+var rateUnits = []struct {
+	name string
+	d    time.Duration
+}{
+	{"second", time.Second},
+	{"minute", time.Minute},
+	{"hour", time.Hour},
+	{"day", 24 * time.Hour},
+}
+
+// ParseRate parses a rate like "10/minute". Like fw3, it accepts any
+// prefix of the units second, minute, hour and day (e.g. "10/sec" or
+// "3/h").
+func ParseRate(v string) (Rate, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(v), "/")
+	n, err := strconv.Atoi(count)
+	if ok && err == nil && n >= 0 && unit != "" {
+		unit = strings.ToLower(unit)
+		for _, u := range rateUnits {
+			if strings.HasPrefix(u.name, unit) {
+				return Rate{Count: n, Per: u.d}, nil
+			}
+		}
+	}
+	return Rate{}, fmt.Errorf("%w: rate %q", ErrInvalidUnit, v)
+}
+
+// String formats the rate for ParseRate. Rates per other durations than
+// the units of ParseRate are formatted as Go durations.
+func (r Rate) String() string {
+	for _, u := range rateUnits {
+		if r.Per == u.d {
+			return strconv.Itoa(r.Count) + "/" + u.name
+		}
+	}
+	return strconv.Itoa(r.Count) + "/" + r.Per.String()
+}
+
+var sizeUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"g", 1 << 30},
+	{"m", 1 << 20},
+	{"k", 1 << 10},
+}
+
+// ParseSize parses a size in bytes, optionally followed by one of the
+// binary units k, m and g (e.g. "64k" is 65536). Units are case
+// insensitive.
+func ParseSize(v string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(v))
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSuffix(s, u.suffix), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("%w: size %q", ErrInvalidUnit, v)
+	}
+	return n * mult, nil
+}
+
+// FormatSize formats n for ParseSize, using the largest unit which
+// represents it exactly.
+func FormatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n != 0 && n%u.n == 0 {
+			return strconv.FormatInt(n/u.n, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,88 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseTime(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		out  string
+	}{
+		{"12h", 12 * time.Hour, "12h"},
+		{"90m", 90 * time.Minute, "90m"},
+		{"120m", 2 * time.Hour, "2h"},
+		{"2D", 48 * time.Hour, "2d"},
+		{"1w", 7 * 24 * time.Hour, "1w"},
+		{"3600", time.Hour, "1h"},
+		{"45s", 45 * time.Second, "45s"},
+		{"0", 0, "0"},
+		{"infinite", InfiniteLease, "infinite"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			d, err := ParseLeaseTime(tc.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, d)
+			assert.Equal(t, tc.out, FormatLeaseTime(d))
+		})
+	}
+	for _, in := range []string{"", "h", "12x", "-1h", "1.5h", "forever"} {
+		_, err := ParseLeaseTime(in)
+		assert.True(t, errors.Is(err, ErrInvalidUnit), "%q: got %v", in, err)
+	}
+}
+
+func TestRate(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Rate
+		out  string
+	}{
+		{"10/second", Rate{10, time.Second}, "10/second"},
+		{"10/sec", Rate{10, time.Second}, "10/second"},
+		{"3/m", Rate{3, time.Minute}, "3/minute"},
+		{"5/Hour", Rate{5, time.Hour}, "5/hour"},
+		{"1/d", Rate{1, 24 * time.Hour}, "1/day"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			r, err := ParseRate(tc.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, r)
+			assert.Equal(t, tc.out, r.String())
+		})
+	}
+	for _, in := range []string{"", "10", "10/", "x/sec", "-1/sec", "10/week"} {
+		_, err := ParseRate(in)
+		assert.True(t, errors.Is(err, ErrInvalidUnit), "%q: got %v", in, err)
+	}
+}
+
+func TestSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+		out  string
+	}{
+		{"64k", 64 << 10, "64k"},
+		{"1024K", 1 << 20, "1m"},
+		{"2G", 2 << 30, "2g"},
+		{"1500", 1500, "1500"},
+		{"0", 0, "0"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			n, err := ParseSize(tc.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, n)
+			assert.Equal(t, tc.out, FormatSize(n))
+		})
+	}
+	for _, in := range []string{"", "k", "64kb", "-1k", "9999999999999g"} {
+		_, err := ParseSize(in)
+		assert.True(t, errors.Is(err, ErrInvalidUnit), "%q: got %v", in, err)
+	}
+}
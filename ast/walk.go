@@ -0,0 +1,59 @@
+package ast
+
+import "errors"
+
+var (
+	// SkipSection can be returned by a WalkFunc visiting a section, to
+	// skip the section's options.
+	SkipSection = errors.New("skip this section") //nolint:errname,revive,stylecheck
+
+	// SkipAll can be returned by a WalkFunc, to stop the walk.
+	SkipAll = errors.New("skip everything") //nolint:errname,revive,stylecheck
+)
+
+// A WalkFunc is called by Config.Walk for each section (node is a
+// *Section) and option (node is an *Option). The path is the dotted
+// notation of the node, as in "network.lan.proto", using the synthetic
+// "@type[index]" selector for unnamed sections.
+type WalkFunc func(path string, node interface{}) error
+
+// Walk calls fn for each section of c, followed by the section's
+// options, in order. If fn returns an error, Walk stops and returns it,
+// except for SkipSection and SkipAll (see there), which make Walk
+// return nil.
+//
+// fn may modify the nodes (e.g. replace option values), but must not add
+// or remove sections.
+func (c *Config) Walk(fn WalkFunc) error {
+	for _, sec := range c.Sections {
+		err := c.walkSection(sec, fn)
+		if errors.Is(err, SkipAll) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) walkSection(sec *Section, fn WalkFunc) error {
+	p := Path{Config: c.Name, Section: c.SectionName(sec)}
+	if err := fn(p.String(), sec); err != nil {
+		return skipSection(err)
+	}
+	for _, opt := range sec.Options {
+		p.Option = opt.Name
+		if err := fn(p.String(), opt); err != nil {
+			return skipSection(err) // also skips the remaining options
+		}
+	}
+	return nil
+}
+
+func skipSection(err error) error {
+	if errors.Is(err, SkipSection) {
+		return nil
+	}
+	return err
+}
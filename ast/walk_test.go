@@ -0,0 +1,77 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalk(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	list dns '1.1.1.1'
+
+config route
+	option target '10.0.0.0/8'
+	option gateway '192.168.1.2'
+
+config interface 'x'
+	option proto 'dhcp'
+`)
+	require.NoError(err)
+
+	var visited []string
+	require.NoError(cfg.Walk(func(path string, node interface{}) error {
+		switch n := node.(type) {
+		case *Section:
+			visited = append(visited, fmt.Sprintf("%s (%s)", path, n.Type))
+		case *Option:
+			visited = append(visited, fmt.Sprintf("%s=%v", path, n.Values))
+		}
+		return nil
+	}))
+	assert.Equal([]string{
+		"network.lan (interface)",
+		"network.lan.proto=[static]",
+		"network.lan.dns=[1.1.1.1]",
+		"network.@route[0] (route)",
+		"network.@route[0].target=[10.0.0.0/8]",
+		"network.@route[0].gateway=[192.168.1.2]",
+	}, visited[:6])
+
+	// skipping
+	visited = nil
+	require.NoError(cfg.Walk(func(path string, node interface{}) error {
+		visited = append(visited, path)
+		switch path {
+		case "network.lan":
+			return SkipSection
+		case "network.@route[0].target":
+			return SkipSection
+		case "network.x":
+			return SkipAll
+		}
+		return nil
+	}))
+	assert.Equal([]string{"network.lan", "network.@route[0]", "network.@route[0].target", "network.x"}, visited)
+
+	// errors abort the walk, nodes can be modified
+	errStop := errors.New("stop")
+	err = cfg.Walk(func(path string, node interface{}) error {
+		if opt, ok := node.(*Option); ok {
+			opt.SetValues("redacted")
+			return errStop
+		}
+		return nil
+	})
+	assert.True(errors.Is(err, errStop))
+	assert.Equal([]string{"redacted"}, cfg.Get("lan").Get("proto").Values)
+	assert.Equal([]string{"1.1.1.1"}, cfg.Get("lan").Get("dns").Values)
+}
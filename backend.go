@@ -0,0 +1,214 @@
+package uci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// backend loads and stores the configs of a tree.
+type backend interface {
+	// load reads and parses the named config. It also returns the
+	// provenance of the config's sections and options, if known.
+	load(ctx context.Context, name string) (*Config, *ast.Provenances, error)
+
+	// save writes the config back, and returns the new provenance of
+	// its sections and options. The tree resets the config's taint flag
+	// afterwards.
+	save(ctx context.Context, c *Config) (*ast.Provenances, error)
+
+	// list returns the names of all configs, in alphabetical order.
+	list(ctx context.Context) ([]string, error)
+
+	// preview returns the bytes save would write for c, and the bytes
+	// they replace (nil, if the config does not exist yet).
+	preview(ctx context.Context, c *Config) (old, new []byte, err error)
+
+	// restore replaces the named config with body, as returned by
+	// preview. A nil body removes the config.
+	restore(ctx context.Context, name string, body []byte) error
+}
+
+// styledBackend is implemented by backends writing config files, whose
+// formatting can be changed (see Tree.SetStyle).
+type styledBackend interface {
+	setStyle(style Style)
+}
+
+// limitedBackend is implemented by backends parsing configs, which can
+// bound the resources spent on it (see Tree.SetLimits).
+type limitedBackend interface {
+	setLimits(limits Limits)
+}
+
+// versionedBackend is implemented by backends, which can tell whether a
+// config has been changed by others since it was loaded (see
+// ErrConcurrentModification).
+type versionedBackend interface {
+	// version returns the Hash of the config load would return now,
+	// or "" if the config does not exist.
+	version(ctx context.Context, name string) (string, error)
+}
+
+// storeBackend implements the backend interface using a Store.
+type storeBackend struct {
+	store  Store
+	layer  string // for layered trees, see NewLayeredTree
+	style  Style
+	limits Limits
+	strict bool         // reject what warn logs
+	log    *slog.Logger // nil discards
+}
+
+func (b *storeBackend) setStyle(style Style) {
+	b.style = style
+}
+
+func (b *storeBackend) setLimits(limits Limits) {
+	b.limits = limits
+}
+
+// path returns the file name of a config, if the store has files, or
+// the config name otherwise.
+func (b *storeBackend) path(name string) string {
+	if s, ok := b.store.(interface{ Path(string) string }); ok {
+		return s.Path(name)
+	}
+	return name
+}
+
+// read returns the contents of the named config. If the size of configs
+// is limited, stores supporting it read at most one byte more than
+// MaxSize, which is enough for the parser to reject the config.
+func (b *storeBackend) read(ctx context.Context, name string) ([]byte, error) {
+	type limitedStore interface {
+		readLimited(ctx context.Context, name string, n int64) ([]byte, error)
+	}
+	if s, ok := b.store.(limitedStore); ok && b.limits.MaxSize > 0 {
+		return s.readLimited(ctx, name, int64(b.limits.MaxSize)+1)
+	}
+	return b.store.Read(ctx, name)
+}
+
+func (b *storeBackend) load(ctx context.Context, name string) (*Config, *ast.Provenances, error) {
+	body, err := b.read(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, pos, err := ast.Parser{Strict: b.strict, Limits: b.limits}.ParsePositions(name, string(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	b.warn(ctx, name, body)
+	return cfg, ast.NewProvenances(pos, b.path(name), b.layer), nil
+}
+
+func (b *storeBackend) version(ctx context.Context, name string) (string, error) {
+	body, err := b.read(ctx, name)
+	if err != nil {
+		return versionOf(nil, err)
+	}
+	return versionOf(ast.Parser{Strict: b.strict, Limits: b.limits}.Parse(name, string(body)))
+}
+
+func (b *storeBackend) setLogger(l *slog.Logger) {
+	b.log = l
+}
+
+// warn logs the first issue found by ast.ParseStrict in a config, which
+// uci accepts, but which most likely is a mistake.
+func (b *storeBackend) warn(ctx context.Context, name string, body []byte) {
+	if b.strict || b.log == nil || !b.log.Enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	if _, err := ast.ParseStrict(name, string(body)); err != nil {
+		b.log.WarnContext(ctx, "suspicious config syntax", "config", name, "path", b.path(name), "err", err)
+	}
+}
+
+func (b *storeBackend) save(ctx context.Context, c *Config) (*ast.Provenances, error) {
+	body, err := b.render(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.store.Write(ctx, c.Name, body); err != nil {
+		return nil, err
+	}
+	return writtenProvenances(c, string(body), b.path(c.Name), b.layer), nil
+}
+
+func (b *storeBackend) preview(ctx context.Context, c *Config) ([]byte, []byte, error) {
+	old, err := b.store.Read(ctx, c.Name)
+	if errors.Is(err, os.ErrNotExist) {
+		old, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := b.render(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return old, body, nil
+}
+
+func (b *storeBackend) restore(ctx context.Context, name string, body []byte) error {
+	if body == nil {
+		return b.store.Delete(ctx, name)
+	}
+	return b.store.Write(ctx, name, body)
+}
+
+// render serializes c in the style of the backend.
+func (b *storeBackend) render(c *Config) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.Write(&buf, ast.WithStyle(b.style)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *storeBackend) list(ctx context.Context) ([]string, error) {
+	return b.store.List(ctx)
+}
+
+// versionOf returns the version of a loaded config, see versionedBackend.
+func versionOf(cfg *Config, err error) (string, error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	return cfg.Hash(), nil
+}
+
+// writtenProvenances returns the provenance of the sections and options
+// of c, which was serialized to body and written to file.
+func writtenProvenances(c *Config, body, file, layer string) *ast.Provenances {
+	written, pos, err := ast.ParsePositions(c.Name, body)
+	if err != nil || len(written.Sections) != len(c.Sections) {
+		return ast.NewProvenances(nil, file, layer)
+	}
+	// written has the same structure as c, so we can map the positions
+	// of its sections and options
+	provs := ast.NewProvenances(nil, file, layer)
+	for i, sec := range written.Sections {
+		if at, ok := pos.Section(sec); ok {
+			provs.SetSection(c.Sections[i], ast.Provenance{File: file, Line: at.Line, Layer: layer})
+		}
+		if len(sec.Options) != len(c.Sections[i].Options) {
+			continue
+		}
+		for j, opt := range sec.Options {
+			if at, ok := pos.Option(opt); ok {
+				provs.SetOption(c.Sections[i].Options[j], ast.Provenance{File: file, Line: at.Line, Layer: layer})
+			}
+		}
+	}
+	return provs
+}
@@ -0,0 +1,232 @@
+package uci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Paths of the files in a backup bundle. Like "sysupgrade -b", we store
+// paths relative to the root directory, so that the bundle can be
+// extracted with "tar -C / -xzf" (which is what "sysupgrade -r" does).
+const (
+	backupConfigDir = "etc/config/"
+	backupManifest  = "etc/backup/go-uci.json"
+)
+
+// BackupManifest describes a backup bundle. It is stored alongside the
+// configs, and is ignored (but restored) by sysupgrade.
+type BackupManifest struct {
+	Created time.Time         `json:"created"`
+	Comment string            `json:"comment,omitempty"`
+	Configs map[string]string `json:"configs"` // name → Config.Hash()
+}
+
+// A BackupOption configures Tree.Backup.
+type BackupOption func(*backupOptions)
+
+type backupOptions struct {
+	manifest *BackupManifest
+}
+
+// WithManifest adds a BackupManifest with the given comment to the
+// bundle. Tree.Restore verifies the configs against the manifest.
+func WithManifest(comment string) BackupOption {
+	return func(o *backupOptions) {
+		o.manifest = &BackupManifest{Comment: comment}
+	}
+}
+
+func (t *tree) Backup(w io.Writer, opts ...BackupOption) error {
+	var o backupOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	names, err := t.configNames(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if o.manifest != nil {
+		o.manifest.Created = now
+		o.manifest.Configs = make(map[string]string, len(names))
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		if t.allowed(name) != nil || t.ephemeral[name] {
+			continue
+		}
+		cfg, err := t.ensureConfig(context.Background(), name)
+		if err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		var buf bytes.Buffer
+		if _, err := cfg.WriteTo(&buf); err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, backupConfigDir+name, buf.Bytes(), now); err != nil {
+			return err
+		}
+		if o.manifest != nil {
+			o.manifest.Configs[name] = cfg.Hash()
+		}
+	}
+	if o.manifest != nil {
+		body, err := json.MarshalIndent(o.manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, backupManifest, append(body, '\n'), now); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, body []byte, mtime time.Time) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(body)),
+		ModTime:  mtime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if _, err := tw.Write(body); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+func (t *tree) Restore(r io.Reader) (*BackupManifest, error) {
+	if t.readOnly {
+		return nil, ErrReadOnly
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *BackupManifest
+	configs := make(map[string]*Config)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF { //nolint:errorlint
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("restore: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// accept "etc/config/x", "./etc/config/x" and "/etc/config/x"
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		switch {
+		case name == backupManifest:
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("restore: reading manifest failed: %w", err)
+			}
+		case path.Dir(name)+"/" == backupConfigDir:
+			name = path.Base(name)
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if err := t.allowed(name); err != nil {
+				if t.mode == IgnoreUnlisted {
+					continue
+				}
+				return nil, err
+			}
+			body, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("restore: %w", err)
+			}
+			cfg, err := ast.Parse(name, string(body))
+			if err != nil {
+				return nil, err
+			}
+			configs[name] = cfg
+		}
+	}
+
+	if manifest != nil {
+		for name, hash := range manifest.Configs {
+			if t.allowed(name) != nil {
+				continue
+			}
+			if cfg := configs[name]; cfg == nil || cfg.Hash() != hash {
+				return nil, &ErrBackupCorrupt{Config: name}
+			}
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if t.configs == nil {
+		t.configs = make(map[string]*Config)
+	}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	snap := t.snapshot(names...)
+	for name, cfg := range configs {
+		cfg.SetTainted()
+		t.configs[name] = cfg
+		t.setProvenances(name, nil)
+	}
+	t.record(snap)
+	return manifest, nil
+}
+
+// configNames lists the configs of the backend, and those created in
+// memory, but not committed yet, sorted by name. Its call must be
+// guarded by locking the tree's mutex.
+func (t *tree) configNames(ctx context.Context) ([]string, error) {
+	names, err := t.backend.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for name := range t.configs {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
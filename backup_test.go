@@ -0,0 +1,162 @@
+package uci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tarFiles lists the regular files of a backup archive.
+func tarFiles(t *testing.T, b []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF { //nolint:errorlint
+			return files
+		}
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(body)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	assert := assert.New(t)
+
+	src := t.TempDir()
+	for _, name := range []string{"system", "network", "wireless"} {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(src, name), body, 0644))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, ".42.system"), nil, 0644))
+
+	r := NewTree(src)
+	assert.True(r.Set("system", "ntp", "enabled", "0")) // uncommitted
+	require.NoError(t, r.AddSection("newconfig", "sec", "type"))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Backup(&buf, WithManifest("nightly")))
+
+	files := tarFiles(t, buf.Bytes())
+	assert.Len(files, 5)
+	for _, name := range []string{"system", "network", "wireless", "newconfig"} {
+		assert.Contains(files, "etc/config/"+name)
+	}
+	assert.Contains(files["etc/config/system"], "option enabled '0'")
+	assert.Contains(files, "etc/backup/go-uci.json")
+
+	dir := t.TempDir()
+	dst := NewTree(dir)
+	manifest, err := dst.Restore(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.NotNil(t, manifest)
+	assert.Equal("nightly", manifest.Comment)
+	assert.Len(manifest.Configs, 4)
+	require.NoError(t, dst.Commit())
+
+	enabled, ok := NewTree(dir).GetLast("system", "ntp", "enabled")
+	assert.True(ok)
+	assert.Equal("0", enabled)
+	cfg, _ := r.EnsureConfigLoaded("network")
+	restored, _ := dst.EnsureConfigLoaded("network")
+	assert.Equal(cfg.Hash(), restored.Hash())
+
+	// without manifest
+	buf.Reset()
+	require.NoError(t, r.Backup(&buf))
+	assert.NotContains(tarFiles(t, buf.Bytes()), "etc/backup/go-uci.json")
+	manifest, err = NewTree(t.TempDir()).Restore(&buf)
+	assert.NoError(err)
+	assert.Nil(manifest)
+}
+
+func TestRestore_sysupgrade(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range map[string]string{
+		"./etc/config/system": "config system\n\toption hostname 'ap1'\n",
+		"/etc/config/dhcp":    "config dnsmasq\n",
+		"etc/passwd":          "root:x:0:0:root:/root:/bin/ash\n",
+		"etc/config/.hidden":  "garbage",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(body))}))
+		_, err := tw.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	r := NewTree(t.TempDir())
+	manifest, err := r.Restore(&buf)
+	require.NoError(t, err)
+	assert.Nil(manifest)
+	assert.Len(r.(*tree).configs, 2)
+
+	hostname, ok := r.GetLast("system", "@system[0]", "hostname")
+	assert.True(ok)
+	assert.Equal("ap1", hostname)
+}
+
+func TestRestore_errors(t *testing.T) {
+	assert := assert.New(t)
+
+	src := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "system"), []byte("config system\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "network"), []byte("config interface 'lan'\n"), 0644))
+
+	var buf bytes.Buffer
+	require.NoError(t, NewTree(src).Backup(&buf, WithManifest("")))
+
+	// restricted trees skip or reject unlisted configs
+	ignore := NewRestrictedTree(t.TempDir(), IgnoreUnlisted, "system")
+	_, err := ignore.Restore(bytes.NewReader(buf.Bytes()))
+	assert.NoError(err)
+	assert.Len(ignore.(*tree).configs, 1)
+
+	reject := NewRestrictedTree(t.TempDir(), RejectUnlisted, "system")
+	_, err = reject.Restore(bytes.NewReader(buf.Bytes()))
+	var notAllowed *ErrConfigNotAllowed
+	assert.True(errors.As(err, &notAllowed))
+	assert.Len(reject.(*tree).configs, 0)
+
+	// tampered config
+	files := tarFiles(t, buf.Bytes())
+	files["etc/config/network"] = "config interface 'wan'\n"
+	var tampered bytes.Buffer
+	gz := gzip.NewWriter(&tampered)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body))}))
+		_, err := tw.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	r := NewTree(t.TempDir())
+	_, err = r.Restore(&tampered)
+	var corrupt *ErrBackupCorrupt
+	if assert.True(errors.As(err, &corrupt)) {
+		assert.Equal("network", corrupt.Config)
+	}
+	assert.Len(r.(*tree).configs, 0)
+
+	_, err = r.Restore(bytes.NewReader([]byte("not gzip")))
+	assert.Error(err)
+}
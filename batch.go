@@ -0,0 +1,323 @@
+package uci
+
+import (
+	"context"
+	"errors"
+)
+
+// A Tx collects the changes made by the function passed to Tree.Batch.
+// Its methods work like the tree's ones of the same name, but report why
+// a change could not be made. The first error aborts the batch, even if
+// the function ignores it.
+type Tx struct {
+	t        *tree
+	saved    historyEntry // configs before their first change
+	sections map[[2]string]*Section
+	err      error
+	commit   bool
+}
+
+func (t *tree) Batch(fn func(tx *Tx) error) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	tx := &Tx{t: t, saved: make(historyEntry), sections: make(map[[2]string]*Section)}
+	err := func() error {
+		// the batch is recorded as a whole
+		history := t.history
+		t.history = nil
+		defer func() { t.history = history }()
+		return fn(tx)
+	}()
+	if err == nil {
+		err = tx.err
+	}
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+	if len(tx.saved) > 0 {
+		t.record(tx.saved)
+	}
+	if tx.commit {
+		return t.commit(context.Background())
+	}
+	return nil
+}
+
+// touch loads a config, and remembers its state before the batch
+// changes it.
+func (tx *Tx) touch(config string) (*Config, error) {
+	cfg, err := tx.t.ensureConfig(context.Background(), config)
+	if _, saved := tx.saved[config]; !saved {
+		if err == nil {
+			tx.saved[config] = cfg.Clone()
+		} else {
+			tx.saved[config] = nil
+		}
+	}
+	return cfg, err
+}
+
+// resolve returns a section, resolving each selector only once per
+// batch (or until sections are added or deleted).
+func (tx *Tx) resolve(config, section string) (*Config, *Section, error) {
+	cfg, err := tx.touch(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := [2]string{config, section}
+	if sec, ok := tx.sections[key]; ok {
+		return cfg, sec, nil
+	}
+	sec, err := cfg.Lookup(section)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx.sections[key] = sec
+	return cfg, sec, nil
+}
+
+// fail records the first error of the batch.
+func (tx *Tx) fail(err error) error {
+	if tx.err == nil {
+		tx.err = err
+	}
+	return err
+}
+
+// rollback restores the configs changed by the batch. Like Undo, it
+// changes loaded configs in place.
+func (tx *Tx) rollback() {
+	t := tx.t
+	for name, state := range tx.saved {
+		cfg := t.configs[name]
+		switch {
+		case state == nil:
+			delete(t.configs, name)
+			delete(t.prov, name)
+		case cfg != nil:
+			cfg.Sections = state.Sections
+			cfg.Reindex()
+			if !state.Tainted() {
+				cfg.ResetTainted()
+			}
+		}
+	}
+}
+
+// Get returns the values of an option, including the changes made by the
+// batch so far.
+func (tx *Tx) Get(config, section, option string) ([]string, bool) {
+	if _, err := tx.t.ensureConfig(context.Background(), config); err != nil {
+		return nil, false
+	}
+	return tx.t.lookupValues(config, section, option)
+}
+
+// Set sets the values of an option, like SetType with TypeList for
+// multiple values, and TypeOption otherwise.
+func (tx *Tx) Set(config, section, option string, values ...string) error {
+	if len(values) > 1 {
+		return tx.SetType(config, section, option, TypeList, values...)
+	}
+	return tx.SetType(config, section, option, TypeOption, values...)
+}
+
+// SetType sets the type and values of an option. The section must exist.
+func (tx *Tx) SetType(config, section, option string, typ OptionType, values ...string) error {
+	cfg, sec, err := tx.resolve(config, section)
+	if err != nil {
+		return tx.fail(err)
+	}
+	if err := tx.t.setOption(cfg, sec, section, option, typ, values); err != nil {
+		return tx.fail(err)
+	}
+	return nil
+}
+
+// Del deletes an option. Missing configs, sections and options are
+// ignored.
+func (tx *Tx) Del(config, section, option string) error {
+	cfg, sec, err := tx.resolve(config, section)
+	switch {
+	case errors.Is(err, ErrConfigNotFound), errors.As(err, new(ErrSectionNotFound)):
+		return nil
+	case err != nil:
+		return tx.fail(err)
+	}
+	if err := tx.t.delOption(cfg, sec, section, option); err != nil {
+		return tx.fail(err)
+	}
+	return nil
+}
+
+// AddSection adds a section, see Tree.AddSection.
+func (tx *Tx) AddSection(config, section, typ string) error {
+	_, _ = tx.touch(config)
+	clear(tx.sections)
+	if err := tx.t.addSection(config, section, typ); err != nil {
+		return tx.fail(err)
+	}
+	return nil
+}
+
+// DelSection deletes a section. Missing configs and sections are
+// ignored.
+func (tx *Tx) DelSection(config, section string) error {
+	if _, err := tx.touch(config); err != nil {
+		return nil //nolint:nilerr // nothing to delete
+	}
+	clear(tx.sections)
+	if err := tx.t.delSection(config, section); err != nil {
+		return tx.fail(err)
+	}
+	return nil
+}
+
+// GetSections returns the names of the sections of a type, like
+// Tree.GetSections, including the changes made by the batch so far.
+func (tx *Tx) GetSections(config, secType string) ([]string, bool) {
+	cfg, err := tx.t.ensureConfig(context.Background(), config)
+	if err != nil {
+		return nil, false
+	}
+	names := []string{}
+	for _, sec := range cfg.Sections {
+		if sec.Type == secType {
+			names = append(names, cfg.SectionName(sec))
+		}
+	}
+	return names, true
+}
+
+// SectionType returns the type of a section, and whether it exists.
+func (tx *Tx) SectionType(config, section string) (string, bool) {
+	cfg, err := tx.t.ensureConfig(context.Background(), config)
+	if err != nil {
+		return "", false
+	}
+	sec := cfg.Get(section)
+	if sec == nil {
+		return "", false
+	}
+	return sec.Type, true
+}
+
+// SetSectionType changes the type of a section, and returns its name
+// afterwards, which differs for unnamed sections. References to the
+// section are not affected.
+func (tx *Tx) SetSectionType(config, section, typ string) (string, error) {
+	cfg, sec, err := tx.resolve(config, section)
+	if err != nil {
+		return "", tx.fail(err)
+	}
+	if err := sec.SetType(typ); err != nil {
+		return "", tx.fail(err)
+	}
+	clear(tx.sections)
+	cfg.Reindex()
+	cfg.SetTainted()
+	tx.t.markEdited(config, sec, nil)
+	return cfg.SectionName(sec), nil
+}
+
+// RenameSection renames a section, and updates the references to it,
+// see Tree.RenameSection.
+func (tx *Tx) RenameSection(config, section, name string, refs RefMap) ([]Path, error) {
+	_, _ = tx.touch(config)
+	for _, ref := range refs[config] {
+		_, _ = tx.touch(ref.Config)
+	}
+	clear(tx.sections)
+	changed, err := tx.t.renameSection(config, section, name, refs)
+	if err != nil {
+		return nil, tx.fail(err)
+	}
+	return changed, nil
+}
+
+// AddUnnamedSection appends an unnamed section, and returns its
+// selector, see Tree.AddUnnamedSection.
+func (tx *Tx) AddUnnamedSection(config, typ string) (string, error) {
+	_, _ = tx.touch(config)
+	clear(tx.sections)
+	sel, err := tx.t.addUnnamedSection(config, typ)
+	if err != nil {
+		return "", tx.fail(err)
+	}
+	return sel, nil
+}
+
+// CopyConfig returns a copy of a config, like Tree.CopyConfig, including
+// the changes made by the batch so far. Sections of the copy have the
+// same names and selectors as in the tree, so they can be changed
+// through the batch.
+func (tx *Tx) CopyConfig(config string) (*Config, bool) {
+	cfg, err := tx.t.ensureConfig(context.Background(), config)
+	if err != nil {
+		return nil, false
+	}
+	return cfg.Clone(), true
+}
+
+// ReplaceOptions copies the named options of from to a section, like
+// Section.ReplaceOptions, but running the tree's hooks and validators.
+// Named options missing in from are deleted.
+func (tx *Tx) ReplaceOptions(config, section string, from *Section, names ...string) error {
+	for _, name := range names {
+		opt := from.Get(name)
+		if opt == nil {
+			if err := tx.Del(config, section, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.SetType(config, section, name, opt.Type, opt.Values...); err != nil {
+			return err
+		}
+		if _, sec, err := tx.resolve(config, section); err == nil {
+			if o := sec.Get(name); o != nil {
+				o.Type = opt.Type
+			}
+		}
+	}
+	return nil
+}
+
+// PutSection stores a section rendered by a package for typed configs.
+// A named section is created, if necessary, and the managed options are
+// replaced by those of rendered (see ReplaceOptions); its other options
+// are kept. An unnamed section is appended. Without managed options, all
+// options of rendered are set. It returns the name or selector of the
+// section.
+func (tx *Tx) PutSection(config string, rendered *Section, managed ...string) (string, error) {
+	if err := tx.t.allowed(config); err != nil && tx.t.mode == IgnoreUnlisted {
+		return "", nil
+	}
+	name := rendered.Name
+	if name == "" {
+		sel, err := tx.AddUnnamedSection(config, rendered.Type)
+		if err != nil {
+			return "", err
+		}
+		name = sel
+	} else if err := tx.AddSection(config, name, rendered.Type); err != nil {
+		return "", err
+	}
+	if len(managed) == 0 {
+		for _, opt := range rendered.Options {
+			managed = append(managed, opt.Name)
+		}
+	}
+	return name, tx.ReplaceOptions(config, name, rendered, managed...)
+}
+
+// Commit makes Batch commit the tree once the changes have been made,
+// like Tree.Commit.
+func (tx *Tx) Commit() {
+	tx.commit = true
+}
@@ -0,0 +1,212 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork})
+	r := NewStoreTree(store)
+	h := NewHistory(0)
+	r.SetHistory(h)
+
+	err := r.Batch(func(tx *Tx) error {
+		if err := tx.Set("network", "lan", "ipaddr", "10.0.0.1"); err != nil {
+			return err
+		}
+		if err := tx.Set("network", "lan", "dns", "1.1.1.1", "9.9.9.9"); err != nil {
+			return err
+		}
+		if err := tx.AddSection("network", "wan", "interface"); err != nil {
+			return err
+		}
+		if err := tx.Set("network", "wan", "proto", "dhcp"); err != nil {
+			return err
+		}
+		ipaddr, _ := tx.Get("network", "lan", "ipaddr")
+		assert.Equal([]string{"10.0.0.1"}, ipaddr)
+		tx.Commit()
+		return nil
+	})
+	require.NoError(err)
+	undo, _ := h.Len()
+	assert.Equal(1, undo)
+	body, err := store.Read(context.Background(), "network")
+	require.NoError(err)
+	assert.Contains(string(body), "option ipaddr '10.0.0.1'")
+	assert.Contains(string(body), "list dns '9.9.9.9'")
+	assert.Contains(string(body), "option proto 'dhcp'")
+
+	// a failing change rolls back the whole batch
+	errCustom := errors.New("custom")
+	for _, tc := range []struct {
+		name string
+		fn   func(tx *Tx) error
+		want error
+	}{
+		{"returned error", func(tx *Tx) error {
+			_ = tx.Set("network", "lan", "ipaddr", "10.0.0.2")
+			return errCustom
+		}, errCustom},
+		{"ignored error", func(tx *Tx) error {
+			_ = tx.DelSection("network", "wan")
+			_ = tx.Set("network", "missing", "proto", "static")
+			return nil
+		}, ErrSectionNotFound{}},
+		{"invalid name", func(tx *Tx) error {
+			_ = tx.AddSection("system", "main", "system")
+			return tx.Set("network", "lan", "in-valid", "1")
+		}, ErrInvalidIdentifier},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.Batch(tc.fn)
+			assert.ErrorIs(err, tc.want)
+			ipaddr, _ := r.GetLast("network", "lan", "ipaddr")
+			assert.Equal("10.0.0.1", ipaddr)
+			_, ok := r.Get("network", "wan", "proto")
+			assert.True(ok)
+			assert.NotContains(r.(*tree).configs, "system")
+			cfg, _ := r.EnsureConfigLoaded("network")
+			assert.False(cfg.Tainted())
+		})
+	}
+	undo, _ = h.Len()
+	assert.Equal(1, undo)
+
+	// rejected changes
+	r.OnDelete(func(e DeleteEvent) error {
+		return errCustom
+	})
+	err = r.Batch(func(tx *Tx) error {
+		return tx.Del("network", "lan", "ipaddr")
+	})
+	var rejected ErrRejected
+	require.ErrorAs(err, &rejected)
+	assert.ErrorIs(err, errCustom)
+
+	assert.ErrorIs(NewTree(t.TempDir(), WithReadOnly()).Batch(func(*Tx) error { return nil }), ErrReadOnly)
+}
+
+func TestBatchRename(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"network":  "\nconfig interface 'wg0'\n\toption proto 'wireguard'\n\nconfig wireguard_wg0\n\toption description 'a'\n\nconfig wireguard_wg0 'b'\n\n",
+		"firewall": "\nconfig zone\n\tlist network 'wg0'\n\n",
+	})
+	r := NewStoreTree(store)
+	refs := RefMap{"network": {{Config: "firewall", SectionType: "zone", Option: "network", TargetType: "interface"}}}
+	rename := func(tx *Tx) error {
+		typ, ok := tx.SectionType("network", "wg0")
+		assert.True(ok)
+		assert.Equal("interface", typ)
+		changed, err := tx.RenameSection("network", "wg0", "vpn", refs)
+		if err != nil {
+			return err
+		}
+		assert.Equal([]Path{{Config: "firewall", Section: "@zone[0]", Option: "network"}}, changed)
+		peers, _ := tx.GetSections("network", "wireguard_wg0")
+		assert.Equal([]string{"@wireguard_wg0[0]", "b"}, peers)
+		for range peers {
+			if _, err := tx.SetSectionType("network", "@wireguard_wg0[0]", "wireguard_vpn"); err != nil {
+				return err
+			}
+		}
+		peers, _ = tx.GetSections("network", "wireguard_vpn")
+		assert.Equal([]string{"@wireguard_vpn[0]", "b"}, peers)
+		return nil
+	}
+
+	// a failure rolls back the rename, the reference and the types
+	errCustom := errors.New("custom")
+	err := r.Batch(func(tx *Tx) error {
+		if err := rename(tx); err != nil {
+			return err
+		}
+		_, err := tx.SetSectionType("network", "b", "in valid")
+		return err
+	})
+	assert.ErrorIs(err, ErrInvalidType)
+	err = r.Batch(func(tx *Tx) error {
+		_ = rename(tx)
+		return errCustom
+	})
+	assert.ErrorIs(err, errCustom)
+	values, _ := r.Get("firewall", "@zone[0]", "network")
+	assert.Equal([]string{"wg0"}, values)
+	peers, _ := r.GetSections("network", "wireguard_wg0")
+	assert.Len(peers, 2)
+
+	require.NoError(r.Batch(rename))
+	values, _ = r.Get("firewall", "@zone[0]", "network")
+	assert.Equal([]string{"vpn"}, values)
+	err = r.Batch(func(tx *Tx) error {
+		_, err := tx.SetSectionType("network", "missing", "foo")
+		return err
+	})
+	assert.ErrorAs(err, new(ErrSectionNotFound))
+}
+
+func TestBatchPutSection(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"network": "\nconfig interface 'lan'\n\toption proto 'static'\n\toption ipaddr '10.0.0.1'\n\toption custom 'x'\n\toption dns '8.8.8.8'\n\n",
+	})
+	r := NewStoreTree(store)
+	r.SetHistory(NewHistory(0))
+	var deleted []DeleteEvent
+	r.OnDelete(func(e DeleteEvent) error {
+		deleted = append(deleted, e)
+		return nil
+	})
+
+	lan := NewSection("interface", "lan")
+	lan.Add(NewOption("proto", TypeOption, "dhcp"))
+	lan.Add(NewOption("dns", TypeList, "1.1.1.1", "9.9.9.9"))
+	route := NewSection("route", "")
+	route.Add(NewOption("target", TypeOption, "10.1.0.0/16"))
+	require.NoError(r.Batch(func(tx *Tx) error {
+		name, err := tx.PutSection("network", lan, "proto", "ipaddr", "dns")
+		assert.Equal("lan", name)
+		if err != nil {
+			return err
+		}
+		if name, err = tx.PutSection("network", route); err != nil {
+			return err
+		}
+		assert.Equal("@route[0]", name)
+		cfg, ok := tx.CopyConfig("network")
+		assert.True(ok)
+		assert.Len(cfg.Sections, 2)
+		return nil
+	}))
+
+	cfg, ok := r.CopyConfig("network")
+	require.True(ok)
+	assert.Equal([]*Option{
+		NewOption("proto", TypeOption, "dhcp"),
+		NewOption("custom", TypeOption, "x"),
+		NewOption("dns", TypeList, "1.1.1.1", "9.9.9.9"),
+	}, cfg.Get("lan").Options)
+	values, _ := r.Get("network", "@route[0]", "target")
+	assert.Equal([]string{"10.1.0.0/16"}, values)
+	assert.Equal([]DeleteEvent{{Config: "network", Section: "lan", Option: "ipaddr"}}, deleted)
+
+	// the batch is undone as a whole
+	assert.Equal(1, r.Undo(1))
+	values, _ = r.Get("network", "lan", "ipaddr")
+	assert.Equal([]string{"10.0.0.1"}, values)
+	_, ok = r.Get("network", "@route[0]", "target")
+	assert.False(ok)
+}
@@ -0,0 +1,336 @@
+// Package bench contains the benchmark suite of go-uci. It is a
+// regular package (instead of a set of _test.go files), so that it can
+// be run on target hardware, e.g. via "go-uci bench", and by embedders
+// of vendored copies:
+//
+//	results := bench.Run(bench.Suite())
+//	regressions := bench.Compare(baseline, results, 0.2)
+//
+// The recorded baseline in testdata/baseline.json was taken on a
+// developer machine. It is only meaningful as a reference for runs on
+// comparable hardware; record your own with Baseline.Save.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Benchmark is a named benchmark function.
+type Benchmark struct {
+	Name string
+	F    func(b *testing.B)
+}
+
+// Suite returns the standard benchmarks: parsing and serializing a
+// small and a large config, parsing a 1 MB firewall config, serializing
+// a huge config (20000 sections), section lookup by selector, deleting
+// an unnamed section, diffing, and committing a config to a temporary
+// directory (which is a tmpfs on OpenWrt).
+func Suite() []Benchmark {
+	small := Input(5)
+	large := Input(500)
+	return []Benchmark{
+		{"Parse/small", benchParse(small)},
+		{"Parse/large", benchParse(large)},
+		{"Parse/firewall", benchParse(Firewall(4000))},
+		{"Serialize/small", benchSerialize(func() *ast.Config { return mustParse(small) })},
+		{"Serialize/large", benchSerialize(func() *ast.Config { return mustParse(large) })},
+		{"Serialize/huge", benchSerialize(func() *ast.Config { return Generate(10000) })},
+		{"AppendText/huge", benchAppendText(func() *ast.Config { return Generate(10000) })},
+		{"Get/named", benchGet(large, "iface250")},
+		{"Get/selector", benchGet(large, "@route[249]")},
+		{"Del/selector", benchDel(large, "@route[499]")},
+		{"Diff/large", benchDiff(large)},
+		{"Commit/small", benchCommit(small)},
+	}
+}
+
+// Input generates a network config with n interfaces and n unnamed
+// routes.
+func Input(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "\nconfig interface 'iface%d'\n", i)
+		fmt.Fprintf(&sb, "\toption proto 'static'\n")
+		fmt.Fprintf(&sb, "\toption ipaddr '10.%d.%d.1'\n", i/256, i%256)
+		fmt.Fprintf(&sb, "\toption netmask '255.255.255.0'\n")
+		fmt.Fprintf(&sb, "\tlist dns '1.1.1.1'\n\tlist dns '9.9.9.9'\n")
+		fmt.Fprintf(&sb, "\nconfig route\n")
+		fmt.Fprintf(&sb, "\toption interface 'iface%d'\n", i)
+		fmt.Fprintf(&sb, "\toption target '172.%d.%d.0/24'\n", 16+i/256, i%256)
+	}
+	return sb.String()
+}
+
+// Generate builds the config described by Input(n) directly, which is
+// much faster than parsing it for large n.
+func Generate(n int) *ast.Config {
+	cfg := ast.NewConfig("network")
+	cfg.Sections = make([]*ast.Section, 0, 2*n)
+	for i := 0; i < n; i++ {
+		iface := ast.NewSection("interface", fmt.Sprintf("iface%d", i))
+		iface.Options = []*ast.Option{
+			ast.NewOption("proto", ast.TypeOption, "static"),
+			ast.NewOption("ipaddr", ast.TypeOption, fmt.Sprintf("10.%d.%d.1", i/256, i%256)),
+			ast.NewOption("netmask", ast.TypeOption, "255.255.255.0"),
+			ast.NewOption("dns", ast.TypeList, "1.1.1.1", "9.9.9.9"),
+		}
+		route := ast.NewSection("route", "")
+		route.Options = []*ast.Option{
+			ast.NewOption("interface", ast.TypeOption, fmt.Sprintf("iface%d", i)),
+			ast.NewOption("target", ast.TypeOption, fmt.Sprintf("172.%d.%d.0/24", 16+i/256, i%256)),
+		}
+		cfg.Sections = append(cfg.Sections, iface, route)
+	}
+	return cfg
+}
+
+// Firewall generates a firewall config with n rules, of which every
+// fourth is named. With n=4000, the config is about 1 MB large.
+func Firewall(n int) string {
+	var sb strings.Builder
+	sb.WriteString("config defaults\n\toption input 'REJECT'\n\toption output 'ACCEPT'\n\toption forward 'REJECT'\n")
+	sb.WriteString("\nconfig zone 'lan'\n\toption name 'lan'\n\tlist network 'lan'\n\toption input 'ACCEPT'\n")
+	sb.WriteString("\nconfig zone 'wan'\n\toption name 'wan'\n\tlist network 'wan'\n\tlist network 'wan6'\n\toption masq '1'\n")
+	for i := 0; i < n; i++ {
+		if i%4 == 0 {
+			fmt.Fprintf(&sb, "\nconfig rule 'rule%d'\n", i)
+		} else {
+			sb.WriteString("\nconfig rule\n")
+		}
+		fmt.Fprintf(&sb, "\toption name 'Allow-Service-%d'\n", i)
+		sb.WriteString("\toption src 'wan'\n\toption dest 'lan'\n\toption proto 'tcp udp'\n")
+		fmt.Fprintf(&sb, "\toption dest_ip '192.168.%d.%d'\n", i/256%256, i%256)
+		fmt.Fprintf(&sb, "\toption dest_port '%d'\n", 1024+i)
+		sb.WriteString("\tlist icmp_type 'echo-request'\n\tlist icmp_type 'echo-reply'\n")
+		sb.WriteString("\toption target 'ACCEPT' # keep\n")
+	}
+	return sb.String()
+}
+
+func mustParse(input string) *ast.Config {
+	cfg, err := ast.Parse("network", input)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+func benchParse(input string) func(b *testing.B) {
+	return func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := ast.Parse("network", input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchSerialize(load func() *ast.Config) func(b *testing.B) {
+	return func(b *testing.B) {
+		cfg := load()
+		text, _ := cfg.AppendText(nil)
+		b.SetBytes(int64(len(text)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := cfg.WriteTo(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchAppendText(load func() *ast.Config) func(b *testing.B) {
+	return func(b *testing.B) {
+		cfg := load()
+		buf, _ := cfg.AppendText(nil)
+		b.SetBytes(int64(len(buf)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var err error
+			if buf, err = cfg.AppendText(buf[:0]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchGet(input, sel string) func(b *testing.B) {
+	return func(b *testing.B) {
+		cfg := mustParse(input)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if cfg.Get(sel) == nil {
+				b.Fatalf("section %s not found", sel)
+			}
+		}
+	}
+}
+
+// benchDel deletes the section selected by sel, and adds it back at the
+// end, so that sel selects it again in the next iteration.
+func benchDel(input, sel string) func(b *testing.B) {
+	return func(b *testing.B) {
+		cfg := mustParse(input)
+		sec := cfg.Get(sel)
+		if sec == nil {
+			b.Fatalf("section %s not found", sel)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cfg.Del(sel)
+			cfg.Add(sec)
+		}
+	}
+}
+
+func benchDiff(input string) func(b *testing.B) {
+	return func(b *testing.B) {
+		from := mustParse(input)
+		to := from.Clone()
+		to.Sections[len(to.Sections)/2].Options[0].SetValues("dhcp")
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if len(ast.Diff(from, to)) != 1 {
+				b.Fatal("unexpected diff")
+			}
+		}
+	}
+}
+
+func benchCommit(input string) func(b *testing.B) {
+	return func(b *testing.B) {
+		dir, err := ioutil.TempDir("", "go-uci-bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if err := ioutil.WriteFile(dir+"/network", []byte(input), 0o644); err != nil {
+			b.Fatal(err)
+		}
+
+		tree := uci.NewTree(dir)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tree.Set("network", "iface0", "mtu", fmt.Sprint(1000+i%500))
+			if err := tree.Commit(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Result is the outcome of a benchmark.
+type Result struct {
+	Name        string `json:"name"`
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp int64  `json:"allocs_per_op"`
+	BytesPerOp  int64  `json:"bytes_per_op"`
+}
+
+// Run runs the benchmarks, and returns their results.
+func Run(benchmarks []Benchmark) []Result {
+	results := make([]Result, 0, len(benchmarks))
+	for _, bm := range benchmarks {
+		r := testing.Benchmark(bm.F)
+		results = append(results, Result{
+			Name:        bm.Name,
+			NsPerOp:     r.NsPerOp(),
+			AllocsPerOp: r.AllocsPerOp(),
+			BytesPerOp:  r.AllocedBytesPerOp(),
+		})
+	}
+	return results
+}
+
+// Baseline holds reference results, by benchmark name.
+type Baseline map[string]Result
+
+// NewBaseline converts results into a baseline.
+func NewBaseline(results []Result) Baseline {
+	b := make(Baseline, len(results))
+	for _, r := range results {
+		b[r.Name] = r
+	}
+	return b
+}
+
+// LoadBaseline reads a baseline saved with Save.
+func LoadBaseline(r io.Reader) (Baseline, error) {
+	var results []Result
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, fmt.Errorf("cannot read baseline: %w", err)
+	}
+	return NewBaseline(results), nil
+}
+
+// Save writes the baseline as JSON list, sorted by name.
+func (b Baseline) Save(w io.Writer) error {
+	results := make([]Result, 0, len(b))
+	for _, r := range b {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// Regression describes a benchmark, which got slower or allocates more
+// than its baseline.
+type Regression struct {
+	Name     string
+	Baseline Result
+	Current  Result
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %d ns/op (baseline %d, %+.1f%%), %d allocs/op (baseline %d)",
+		r.Name,
+		r.Current.NsPerOp, r.Baseline.NsPerOp, change(r.Baseline.NsPerOp, r.Current.NsPerOp)*100,
+		r.Current.AllocsPerOp, r.Baseline.AllocsPerOp)
+}
+
+// Compare reports the results, which are slower than their baseline by
+// more than the given tolerance (e.g. 0.2 for 20%), or which allocate
+// more often. Results without baseline are ignored.
+func Compare(baseline Baseline, results []Result, tolerance float64) []Regression {
+	var regressions []Regression
+	for _, r := range results {
+		base, ok := baseline[r.Name]
+		if !ok {
+			continue
+		}
+		if change(base.NsPerOp, r.NsPerOp) > tolerance || r.AllocsPerOp > base.AllocsPerOp {
+			regressions = append(regressions, Regression{r.Name, base, r})
+		}
+	}
+	return regressions
+}
+
+// change returns the relative change from base to current.
+func change(base, current int64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return float64(current-base) / float64(base)
+}
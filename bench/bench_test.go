@@ -0,0 +1,88 @@
+package bench
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func BenchmarkSuite(b *testing.B) {
+	for _, bm := range Suite() {
+		b.Run(bm.Name, bm.F)
+	}
+}
+
+func TestRecordedBaseline(t *testing.T) {
+	f, err := os.Open("testdata/baseline.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	baseline, err := LoadBaseline(f)
+	require.NoError(t, err)
+	for _, bm := range Suite() {
+		assert.Contains(t, baseline, bm.Name)
+	}
+}
+
+func TestBaselineSave(t *testing.T) {
+	baseline := NewBaseline([]Result{
+		{Name: "b", NsPerOp: 2},
+		{Name: "a", NsPerOp: 1, AllocsPerOp: 3, BytesPerOp: 4},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, baseline.Save(&buf))
+	assert.Equal(t, `[
+  {
+    "name": "a",
+    "ns_per_op": 1,
+    "allocs_per_op": 3,
+    "bytes_per_op": 4
+  },
+  {
+    "name": "b",
+    "ns_per_op": 2,
+    "allocs_per_op": 0,
+    "bytes_per_op": 0
+  }
+]
+`, buf.String())
+
+	loaded, err := LoadBaseline(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, baseline, loaded)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := NewBaseline([]Result{
+		{Name: "fast", NsPerOp: 100, AllocsPerOp: 2},
+		{Name: "slow", NsPerOp: 100, AllocsPerOp: 2},
+		{Name: "allocs", NsPerOp: 100, AllocsPerOp: 2},
+	})
+	results := []Result{
+		{Name: "fast", NsPerOp: 115, AllocsPerOp: 2},
+		{Name: "slow", NsPerOp: 130, AllocsPerOp: 2},
+		{Name: "allocs", NsPerOp: 90, AllocsPerOp: 3},
+		{Name: "new", NsPerOp: 1000, AllocsPerOp: 100},
+	}
+
+	regressions := Compare(baseline, results, 0.2)
+	require.Len(t, regressions, 2)
+	assert.Equal(t, "slow: 130 ns/op (baseline 100, +30.0%), 2 allocs/op (baseline 2)", regressions[0].String())
+	assert.Equal(t, "allocs", regressions[1].Name)
+}
+
+func TestGenerate(t *testing.T) {
+	parsed := mustParse(Input(20))
+	generated := Generate(20)
+
+	var expected, actual bytes.Buffer
+	_, err := parsed.WriteTo(&expected)
+	require.NoError(t, err)
+	_, err = generated.WriteTo(&actual)
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), actual.String())
+}
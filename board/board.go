@@ -0,0 +1,240 @@
+// Package board reads OpenWrt's /etc/board.json, which the board.d
+// scripts generate on first boot to describe the device (its network
+// ports, switch, LEDs and system defaults), and synthesizes the network
+// and system configs from it, the way config_generate does. This way,
+// tools can predict the out-of-the-box configuration of a device model:
+//
+//	b, err := board.ReadFile("board.json")
+//	configs := b.Configs() // network and system
+package board
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Board describes a device.
+type Board struct {
+	Model   Model    `json:"model"`
+	Network Networks `json:"network"`
+	System  System   `json:"system"`
+}
+
+// Model identifies the device.
+type Model struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Network describes the device or ports of a network role (e.g. "lan"
+// or "wan"), and its default protocol.
+type Network struct {
+	Role     string   `json:"-"`
+	Device   string   `json:"device,omitempty"`
+	Ports    []string `json:"ports,omitempty"`
+	Protocol string   `json:"protocol,omitempty"`
+	MACAddr  string   `json:"macaddr,omitempty"`
+	IPAddr   string   `json:"ipaddr,omitempty"`
+	Netmask  string   `json:"netmask,omitempty"`
+}
+
+// Networks are the network roles, in the order of board.json, which
+// determines the order of the generated sections.
+type Networks []Network
+
+// UnmarshalJSON decodes a JSON object keyed by role, keeping the order.
+func (n *Networks) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("network: expected object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var net Network
+		if err := dec.Decode(&net); err != nil {
+			return fmt.Errorf("network %v: %w", tok, err)
+		}
+		net.Role = tok.(string)
+		*n = append(*n, net)
+	}
+	return nil
+}
+
+// System contains the system defaults.
+type System struct {
+	Hostname      string   `json:"hostname,omitempty"`
+	CompatVersion string   `json:"compat_version,omitempty"`
+	NTPServers    []string `json:"ntpserver,omitempty"`
+}
+
+// Read decodes a board.json document.
+func Read(r io.Reader) (*Board, error) {
+	var b Board
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("reading board.json failed: %w", err)
+	}
+	return &b, nil
+}
+
+// ReadFile reads a board.json file.
+func ReadFile(name string) (*Board, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// builder adds sections to a config, like config_generate's uci calls.
+type builder struct {
+	cfg *ast.Config
+}
+
+func (b builder) section(typ, name string) *ast.Section {
+	if name != "" {
+		b.cfg.Del(name) // like "delete network.$1"
+	}
+	return b.cfg.Add(ast.NewSection(typ, name))
+}
+
+func set(sec *ast.Section, name, value string) {
+	if value != "" {
+		sec.SetOption(name, value)
+	}
+}
+
+// Configs returns the network and system configs.
+func (b *Board) Configs() []*ast.Config {
+	return []*ast.Config{b.NetworkConfig(), b.SystemConfig()}
+}
+
+// NetworkConfig returns the default network config: the loopback interface,
+// the globals, and an interface per network role. Roles with ports, and
+// the lan role, are bridged. As on devices with IPv6 support, static
+// interfaces delegate prefixes, and dhcp and pppoe interfaces get an
+// IPv6 counterpart.
+func (b *Board) NetworkConfig() *ast.Config {
+	g := builder{ast.NewConfig("network")}
+
+	lo := g.section("interface", "loopback")
+	set(lo, "device", "lo")
+	set(lo, "proto", "static")
+	set(lo, "ipaddr", "127.0.0.1")
+	set(lo, "netmask", "255.0.0.0")
+	set(g.section("globals", "globals"), "ula_prefix", "auto")
+
+	offset := 2 // for the addresses of static interfaces other than lan
+	for _, n := range b.Network {
+		offset = g.network(n, offset)
+	}
+	return g.cfg
+}
+
+// network generates the sections of a network role, and returns the
+// next address offset.
+func (g builder) network(n Network, offset int) int {
+	device, ports, macaddr := n.Device, n.Ports, n.MACAddr
+	if device == "" && len(ports) == 0 {
+		return offset
+	}
+	// lan is always bridged, as other devices (e.g. wireless) may join
+	if n.Role == "lan" && len(ports) == 0 {
+		ports = []string{device}
+	}
+	if len(ports) > 0 {
+		br := g.section("device", "")
+		set(br, "name", "br-"+n.Role)
+		set(br, "type", "bridge")
+		br.SetList("ports", ports...)
+		if macaddr != "" {
+			for _, port := range ports {
+				dev := g.section("device", "")
+				set(dev, "name", port)
+				set(dev, "macaddr", macaddr)
+			}
+		}
+		device, macaddr = "br-"+n.Role, ""
+	}
+	if macaddr != "" {
+		dev := g.section("device", "")
+		set(dev, "name", device)
+		set(dev, "macaddr", macaddr)
+	}
+
+	iface := g.section("interface", n.Role)
+	set(iface, "device", device)
+	set(iface, "proto", "none")
+
+	switch n.Protocol {
+	case "static":
+		ipaddr := n.IPAddr
+		if ipaddr == "" && n.Role == "lan" {
+			ipaddr = "192.168.1.1"
+		} else if ipaddr == "" {
+			ipaddr = fmt.Sprintf("192.168.%d.1", offset)
+			offset++
+		}
+		netmask := n.Netmask
+		if netmask == "" {
+			netmask = "255.255.255.0"
+		}
+		set(iface, "proto", "static")
+		set(iface, "ipaddr", ipaddr)
+		set(iface, "netmask", netmask)
+		set(iface, "ip6assign", "60")
+	case "dhcp":
+		set(iface, "proto", "dhcp")
+		v6 := g.section("interface", n.Role+"6")
+		set(v6, "device", device)
+		set(v6, "proto", "dhcpv6")
+	case "pppoe":
+		set(iface, "proto", "pppoe")
+		set(iface, "username", "username")
+		set(iface, "password", "password")
+		set(iface, "ipv6", "1")
+		v6 := g.section("interface", n.Role+"6")
+		set(v6, "device", "@"+n.Role)
+		set(v6, "proto", "dhcpv6")
+	}
+	return offset
+}
+
+// SystemConfig returns the default system config, with the hostname, compat
+// version and NTP servers of the board, if set.
+func (b *Board) SystemConfig() *ast.Config {
+	g := builder{ast.NewConfig("system")}
+
+	sys := g.section("system", "")
+	set(sys, "hostname", "OpenWrt")
+	set(sys, "timezone", "UTC")
+	set(sys, "ttylogin", "0")
+	set(sys, "log_size", "128")
+	set(sys, "urandom_seed", "0")
+
+	ntp := g.section("timeserver", "ntp")
+	set(ntp, "enabled", "1")
+	set(ntp, "enable_server", "0")
+	ntp.SetList("server",
+		"0.openwrt.pool.ntp.org", "1.openwrt.pool.ntp.org",
+		"2.openwrt.pool.ntp.org", "3.openwrt.pool.ntp.org")
+
+	set(sys, "hostname", b.System.Hostname)
+	if b.System.CompatVersion != "" {
+		set(sys, "compat_version", b.System.CompatVersion)
+	} else {
+		set(sys, "compat_version", "1.0")
+	}
+	if len(b.System.NTPServers) > 0 {
+		ntp.SetList("server", b.System.NTPServers...)
+	}
+	return g.cfg
+}
@@ -0,0 +1,123 @@
+package board
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+func text(t *testing.T, cfg *ast.Config) string {
+	t.Helper()
+	var buf bytes.Buffer
+	_, err := cfg.WriteTo(&buf)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+func TestReadFile(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := ReadFile("testdata/board.json")
+	require.NoError(t, err)
+	assert.Equal("tplink,archer-c7-v2", b.Model.ID)
+	require.Len(t, b.Network, 2)
+	assert.Equal("lan", b.Network[0].Role)
+	assert.Equal("wan", b.Network[1].Role)
+
+	assert.Equal(`
+config interface 'loopback'
+	option device 'lo'
+	option proto 'static'
+	option ipaddr '127.0.0.1'
+	option netmask '255.0.0.0'
+
+config globals 'globals'
+	option ula_prefix 'auto'
+
+config device
+	option name 'br-lan'
+	option type 'bridge'
+	list ports 'lan1'
+	list ports 'lan2'
+	list ports 'lan3'
+	list ports 'lan4'
+
+config interface 'lan'
+	option device 'br-lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	option netmask '255.255.255.0'
+	option ip6assign '60'
+
+config device
+	option name 'wan'
+	option macaddr 'c4:6e:1f:00:00:01'
+
+config interface 'wan'
+	option device 'wan'
+	option proto 'dhcp'
+
+config interface 'wan6'
+	option device 'wan'
+	option proto 'dhcpv6'
+
+`, text(t, b.NetworkConfig()))
+
+	assert.Equal(`
+config system
+	option hostname 'archer'
+	option timezone 'UTC'
+	option ttylogin '0'
+	option log_size '128'
+	option urandom_seed '0'
+	option compat_version '1.1'
+
+config timeserver 'ntp'
+	option enabled '1'
+	option enable_server '0'
+	list server '0.openwrt.pool.ntp.org'
+	list server '1.openwrt.pool.ntp.org'
+	list server '2.openwrt.pool.ntp.org'
+	list server '3.openwrt.pool.ntp.org'
+
+`, text(t, b.SystemConfig()))
+}
+
+func TestNetworkConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := Read(strings.NewReader(`{"network": {
+		"lan": {"device": "eth0", "protocol": "static", "macaddr": "00:11:22:33:44:55"},
+		"wan": {"device": "eth1", "protocol": "pppoe"},
+		"guest": {"device": "eth2", "protocol": "static"},
+		"iot": {"device": "eth3", "protocol": "static"},
+		"none": {}
+	}, "system": {"ntpserver": ["ntp.example.com"]}}`))
+	require.NoError(t, err)
+
+	cfg := b.NetworkConfig()
+	assert.Equal([]string{"eth0"}, cfg.Get("@device[0]").Get("ports").Values)
+	assert.Equal("eth0", cfg.Get("@device[1]").Get("name").Values[0])
+	assert.Equal("00:11:22:33:44:55", cfg.Get("@device[1]").Get("macaddr").Values[0])
+	assert.Equal("br-lan", cfg.Get("lan").Get("device").Values[0])
+	assert.Equal("username", cfg.Get("wan").Get("username").Values[0])
+	assert.Equal("@wan", cfg.Get("wan6").Get("device").Values[0])
+	assert.Equal("192.168.2.1", cfg.Get("guest").Get("ipaddr").Values[0])
+	assert.Equal("192.168.3.1", cfg.Get("iot").Get("ipaddr").Values[0])
+	assert.Nil(cfg.Get("none"))
+
+	system := b.SystemConfig()
+	assert.Equal([]string{"ntp.example.com"}, system.Get("ntp").Get("server").Values)
+	assert.Equal("1.0", system.Get("@system[0]").Get("compat_version").Values[0])
+	assert.Equal("OpenWrt", system.Get("@system[0]").Get("hostname").Values[0])
+
+	_, err = Read(strings.NewReader(`{"network": []}`))
+	assert.Error(err)
+	_, err = ReadFile("testdata/missing.json")
+	assert.Error(err)
+}
@@ -0,0 +1,103 @@
+package uci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// DefaultSaveDir is where libuci keeps uncommitted changes ("uci -P"
+// changes the directory).
+const DefaultSaveDir = "/tmp/.uci"
+
+// Delta is a single uncommitted change. See ast.Delta.
+type Delta = ast.Delta
+
+// LoadChanges reads the uncommitted changes of a config, made by the uci
+// tool (or SaveChanges), from the delta file in savedir, and applies them
+// to the config, loading it first if needed. A missing delta file is not
+// an error. Changes which can't be applied are skipped, and reported in
+// the returned error.
+func (t *tree) LoadChanges(config, savedir string) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	body, err := ioutil.ReadFile(filepath.Join(t.saveDir(savedir), config))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	deltas, err := ast.ParseDeltas(string(body))
+	if err != nil {
+		return fmt.Errorf("changes of %s: %w", config, err)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.allowed(config); err != nil {
+		return err
+	}
+	cfg, ok := t.configs[config]
+	if !ok {
+		if err := t.loadConfig(context.Background(), config); err != nil {
+			return err
+		}
+		cfg = t.configs[config]
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	snap := t.snapshot(config)
+	cfg.SetTainted()
+	defer t.record(snap) // skipped deltas don't prevent the others
+	return cfg.ApplyDeltas(deltas)
+}
+
+// SaveChanges writes the changes of a config since it was last read
+// from (or committed to) the backend into the delta file in savedir, so
+// that "uci changes" lists them, and "uci commit" applies them. The
+// delta file is replaced; if there are no changes, it is removed.
+func (t *tree) SaveChanges(config, savedir string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	cfg, ok := t.configs[config]
+	if !ok || t.ephemeral[config] {
+		return nil // nothing changed, or nothing to change
+	}
+	orig, _, err := t.backend.load(context.Background(), config)
+	if errors.Is(err, os.ErrNotExist) {
+		orig, err = ast.NewConfig(config), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	savedir = t.saveDir(savedir)
+	file := filepath.Join(savedir, config)
+	deltas := ast.Deltas(orig, cfg)
+	if len(deltas) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := ast.WriteDeltas(&buf, deltas); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(savedir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), 0600)
+}
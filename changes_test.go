@@ -0,0 +1,61 @@
+package uci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoadChanges(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	root := t.TempDir()
+	savedir := filepath.Join(t.TempDir(), ".uci")
+	require.NoError(ioutil.WriteFile(filepath.Join(root, "system"), []byte(`
+config system
+	option hostname 'OpenWrt'
+
+config timeserver 'ntp'
+	list server '0.openwrt.pool.ntp.org'
+`), 0644))
+
+	r := NewTree(root)
+	assert.True(r.SetType("system", "@system[0]", "hostname", TypeOption, "router"))
+	assert.True(r.SetType("system", "ntp", "server", TypeList, "a.pool.ntp.org", "b.pool.ntp.org"))
+	require.NoError(r.SaveChanges("system", savedir))
+
+	body, err := ioutil.ReadFile(filepath.Join(savedir, "system"))
+	require.NoError(err)
+	assert.Equal(`system.cfg01e48a.hostname='router'
+-system.ntp.server
+|system.ntp.server='a.pool.ntp.org'
+|system.ntp.server='b.pool.ntp.org'
+`, string(body))
+
+	// changes made by the uci tool are picked up
+	require.NoError(ioutil.WriteFile(filepath.Join(savedir, "system"),
+		append(body, "system.ntp.enabled='0'\n"...), 0600))
+	r = NewTree(root)
+	require.NoError(r.LoadChanges("system", savedir))
+	host, _ := r.GetLast("system", "@system[0]", "hostname")
+	assert.Equal("router", host)
+	servers, _ := r.Get("system", "ntp", "server")
+	assert.Equal([]string{"a.pool.ntp.org", "b.pool.ntp.org"}, servers)
+	enabled, _ := r.GetLast("system", "ntp", "enabled")
+	assert.Equal("0", enabled)
+
+	// no delta file, no changes
+	require.NoError(r.LoadChanges("network", savedir))
+
+	// reverting the changes removes the delta file
+	r.Revert("system")
+	_, _ = r.Get("system", "ntp", "server")
+	require.NoError(r.SaveChanges("system", savedir))
+	_, err = os.Stat(filepath.Join(savedir, "system"))
+	assert.True(os.IsNotExist(err))
+}
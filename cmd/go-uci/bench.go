@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/bench"
+)
+
+var errRegression = fmt.Errorf("performance regression")
+
+func runBench(_ uci.Tree, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	compare := fs.String("compare", "", "compare the results with a baseline `file`")
+	save := fs.String("save", "", "save the results as baseline `file`")
+	tolerance := fs.Float64("tolerance", 0.2, "tolerated slowdown when comparing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	results := bench.Run(bench.Suite())
+	for _, r := range results {
+		fmt.Printf("%-16s %10d ns/op %8d B/op %6d allocs/op\n", r.Name, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp)
+	}
+
+	if *save != "" {
+		f, err := os.Create(*save)
+		if err != nil {
+			return err
+		}
+		if err := bench.NewBaseline(results).Save(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if *compare != "" {
+		f, err := os.Open(*compare)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		baseline, err := bench.LoadBaseline(f)
+		if err != nil {
+			return err
+		}
+		regressions := bench.Compare(baseline, results, *tolerance)
+		for _, r := range regressions {
+			fmt.Println(r)
+		}
+		if len(regressions) > 0 {
+			return fmt.Errorf("%w in %d benchmarks", errRegression, len(regressions))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/schema"
+)
+
+var errUsage = errors.New("invalid number of arguments")
+
+func explain(t uci.Tree, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ex, err := schema.Explain(t, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (section type %s)\n", ex.Path, ex.SectionType)
+	if spec := ex.Spec; spec != nil {
+		typ := "option"
+		if spec.Type == uci.TypeList {
+			typ = "list"
+		}
+		fmt.Printf("  description: %s\n", spec.Description)
+		fmt.Printf("  type:        %s (%s)\n", typ, spec.Datatype)
+		fmt.Printf("  default:     %s\n", quote(spec.Default))
+	} else {
+		fmt.Println("  (not described by the schema)")
+	}
+	fmt.Printf("  value:       %s (%s)\n", quote(ex.Values), ex.Provenance)
+	if ex.Provenance == schema.FromConfig {
+		fmt.Printf("  set at:      %s\n", ex.Origin)
+	}
+	return nil
+}
+
+func quote(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return "'" + strings.Join(values, "' '") + "'"
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/schema"
+)
+
+func export(t uci.Tree, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	prune := fs.Bool("prune-defaults", false, "omit options set to their schema default")
+	redact := fs.Bool("redact", false, "mask the values of secret options (keys, passwords)")
+	shell := fs.Bool("shell", false, "print the variables config_load of /lib/functions.sh sets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	cfg, ok := t.EnsureConfigLoaded(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("config %q not found", fs.Arg(0))
+	}
+	if *prune {
+		cfg = cfg.Clone()
+		schema.Prune(cfg)
+	}
+	if *shell {
+		return uci.WriteShellVars(os.Stdout, cfg)
+	}
+	var opts []uci.WriteOption
+	if *redact {
+		opts = append(opts, uci.Redact())
+	}
+	_, err := cfg.Write(os.Stdout, opts...)
+	return err
+}
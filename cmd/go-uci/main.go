@@ -0,0 +1,85 @@
+// Command go-uci is a companion tool to OpenWrt's uci CLI. It provides
+// additional commands built on top of the go-uci library.
+//
+// Usage:
+//
+//	go-uci [-c confdir] <command> [arguments]
+//
+// Commands:
+//
+//	bench [-compare file] [-save file] [-tolerance 0.2]
+//		run the benchmark suite, optionally comparing the results
+//		with a baseline, or saving them as new baseline
+//
+//	explain <config>.<section>.<option>
+//		describe an option: its schema description, type, default
+//		and current value
+//
+//	export [-prune-defaults] [-redact] [-shell] <config>
+//		print a config; with -prune-defaults, options set to their
+//		schema default are omitted, with -redact, the values of
+//		secret options (keys, passwords) are masked, with -shell,
+//		the variables config_load of /lib/functions.sh sets are
+//		printed as shell assignments instead
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/wsiner/go-uci"
+)
+
+type command struct {
+	usage string
+	run   func(t uci.Tree, args []string) error
+}
+
+var commands = map[string]command{
+	"bench":   {"[-compare file] [-save file] [-tolerance 0.2]", runBench},
+	"explain": {"<config>.<section>.<option>", explain},
+	"export":  {"[-prune-defaults] [-redact] [-shell] <config>", export},
+}
+
+func main() {
+	confdir := flag.String("c", "", "set the search path for config files (default $UCI_CONFIG_DIR or "+uci.DefaultTreePath+")")
+	statedir := flag.String("P", "", "overlay the state variables in statedir (e.g. "+uci.DefaultStateDir+")")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	var opts []uci.TreeOption
+	if *statedir != "" {
+		opts = append(opts, uci.WithStateDir(*statedir))
+	}
+	if err := cmd.run(uci.NewTree(*confdir, opts...), flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "go-uci: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-c confdir] [-P statedir] <command> [arguments]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nOptions:")
+	flag.PrintDefaults()
+}
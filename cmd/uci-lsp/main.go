@@ -0,0 +1,20 @@
+// Command uci-lsp is a Language Server Protocol server for UCI files.
+// It communicates with the editor over stdin and stdout. See package
+// lsp for the supported features.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/wsiner/go-uci/lsp"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("uci-lsp: ")
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout, nil).Serve(); err != nil {
+		log.Fatal(err)
+	}
+}
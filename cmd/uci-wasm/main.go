@@ -0,0 +1,108 @@
+//go:build js && wasm
+
+// Command uci-wasm exposes the ast package to JavaScript. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o uci.wasm ./cmd/uci-wasm
+//
+// and load it with the wasm_exec.js shim shipped with Go. Afterwards, a
+// global "uci" object provides these functions:
+//
+//	uci.parse(name, text)     // => {config: {...}} or {error: "..."}
+//	uci.serialize(config)     // => {text: "..."} or {error: "..."}
+//	uci.diff(from, to)        // => {changes: [...]} or {error: "..."}
+//
+// Configs are passed as plain objects in the same JSON representation
+// the library uses elsewhere (see ast.Config). The diff function also
+// accepts UCI text for both arguments.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+var errArgs = errors.New("invalid number of arguments")
+
+func main() {
+	js.Global().Set("uci", js.ValueOf(map[string]interface{}{
+		"parse":     js.FuncOf(parse),
+		"serialize": js.FuncOf(serialize),
+		"diff":      js.FuncOf(diff),
+	}))
+
+	// keep the Go runtime (and hence the callbacks) alive
+	select {}
+}
+
+func parse(_ js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return failure(errArgs)
+	}
+	cfg, err := ast.Parse(args[0].String(), args[1].String())
+	if err != nil {
+		return failure(err)
+	}
+	return success("config", cfg)
+}
+
+func serialize(_ js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return failure(errArgs)
+	}
+	cfg, err := toConfig(args[0])
+	if err != nil {
+		return failure(err)
+	}
+	var buf bytes.Buffer
+	if _, err = cfg.WriteTo(&buf); err != nil {
+		return failure(err)
+	}
+	return map[string]interface{}{"text": buf.String()}
+}
+
+func diff(_ js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return failure(errArgs)
+	}
+	from, err := toConfig(args[0])
+	if err != nil {
+		return failure(err)
+	}
+	to, err := toConfig(args[1])
+	if err != nil {
+		return failure(err)
+	}
+	return success("changes", ast.Diff(from, to))
+}
+
+// toConfig converts either a config object or UCI text into a config.
+func toConfig(v js.Value) (*ast.Config, error) {
+	if v.Type() == js.TypeString {
+		return ast.Parse("", v.String())
+	}
+	s := js.Global().Get("JSON").Call("stringify", v).String()
+	cfg := &ast.Config{}
+	if err := json.Unmarshal([]byte(s), cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// success converts result into a JS value (via JSON) and returns it
+// as {key: value}.
+func success(key string, result interface{}) interface{} {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return failure(err)
+	}
+	v := js.Global().Get("JSON").Call("parse", string(b))
+	return map[string]interface{}{key: v}
+}
+
+func failure(err error) interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
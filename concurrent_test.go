@@ -0,0 +1,50 @@
+package uci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentModification(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx := context.Background()
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork})
+	r1, r2 := NewStoreTree(store), NewStoreTree(store)
+	require.NoError(r1.LoadConfig("network", false))
+	require.NoError(r2.LoadConfig("network", false))
+
+	// the first commit wins
+	assert.True(r1.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	require.NoError(r1.Commit())
+	assert.True(r1.Set("network", "lan", "proto", "static"))
+	require.NoError(r1.Commit()) // own commits are no conflict
+	assert.True(r2.Set("network", "lan", "ipaddr", "10.0.0.2"))
+	assert.ErrorIs(r2.Commit(), ErrConcurrentModification)
+
+	// reloading resolves the conflict
+	require.NoError(r2.LoadConfig("network", true))
+	assert.True(r2.Set("network", "lan", "ipaddr", "10.0.0.2"))
+	require.NoError(r2.Commit())
+
+	// reformatting is no change
+	require.NoError(store.Write(ctx, "network", []byte("config interface 'lan'\n  option ipaddr \"10.0.0.2\"\n  option proto static\n")))
+	assert.True(r2.Set("network", "lan", "ipaddr", "10.0.0.3"))
+	require.NoError(r2.Commit())
+
+	// configs created by others
+	require.NoError(r1.AddSection("guest", "guest", "interface"))
+	require.NoError(store.Write(ctx, "guest", []byte("config interface 'guest'\n\toption proto 'dhcp'\n")))
+	assert.ErrorIs(r1.Commit(), ErrConcurrentModification)
+
+	// forced trees overwrite
+	r1.SetForce(true)
+	require.NoError(r1.Commit())
+	body, err := store.Read(ctx, "guest")
+	require.NoError(err)
+	assert.NotContains(string(body), "dhcp")
+}
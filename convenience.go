@@ -1,16 +1,40 @@
 package uci
 
+import (
+	"context"
+	"io"
+	"log/slog"
+	"text/template"
+	"time"
+)
+
 // DefaultTreePath points to the default UCI location.
 const DefaultTreePath = "/etc/config"
 
-// defaultTree is a convenient accessor to the UCI default location.
-var defaultTree = NewTree(DefaultTreePath)
+// defaultTree is a convenient accessor to the UCI default location (or
+// the one named by UCI_CONFIG_DIR).
+var defaultTree = NewTree("")
 
 // LoadConfig delegates to the default tree. See Tree for details.
 func LoadConfig(name string, forceReload bool) error {
 	return defaultTree.LoadConfig(name, forceReload)
 }
 
+// LoadConfigContext delegates to the default tree. See Tree for details.
+func LoadConfigContext(ctx context.Context, name string, forceReload bool) error {
+	return defaultTree.LoadConfigContext(ctx, name, forceReload)
+}
+
+// CommitContext delegates to the default tree. See Tree for details.
+func CommitContext(ctx context.Context) error {
+	return defaultTree.CommitContext(ctx)
+}
+
+// CommitConfigs delegates to the default tree. See Tree for details.
+func CommitConfigs(ctx context.Context, configs ...string) error {
+	return defaultTree.CommitConfigs(ctx, configs...)
+}
+
 // Commit delegates to the default tree. See Tree for details.
 func Commit() error {
 	return defaultTree.Commit()
@@ -53,6 +77,16 @@ func GetSlice(config, section, option, separator string) ([]string, bool) {
 	return defaultTree.GetSlice(config, section, option, separator)
 }
 
+// GetLeaseDuration delegates to the default tree. See Tree for details.
+func GetLeaseDuration(config, section, option string) (time.Duration, bool) {
+	return defaultTree.GetLeaseDuration(config, section, option)
+}
+
+// GetRate delegates to the default tree. See Tree for details.
+func GetRate(config, section, option string) (Rate, bool) {
+	return defaultTree.GetRate(config, section, option)
+}
+
 // Set delegates to the default tree. See Tree for details.
 func Set(config, section, option string, values ...string) bool {
 	return defaultTree.Set(config, section, option, values...)
@@ -73,7 +107,192 @@ func AddSection(config, section, typ string) error {
 	return defaultTree.AddSection(config, section, typ)
 }
 
+// AddUnnamedSection delegates to the default tree. See Tree for details.
+func AddUnnamedSection(config, typ string) (string, error) {
+	return defaultTree.AddUnnamedSection(config, typ)
+}
+
 // DelSection delegates to the default tree. See Tree for details.
 func DelSection(config, section string) {
 	defaultTree.DelSection(config, section)
 }
+
+// RenameSection delegates to the default tree. See Tree for details.
+func RenameSection(config, section, name string, refs RefMap) ([]Path, error) {
+	return defaultTree.RenameSection(config, section, name, refs)
+}
+
+// Backup delegates to the default tree. See Tree for details.
+func Backup(w io.Writer, opts ...BackupOption) error {
+	return defaultTree.Backup(w, opts...)
+}
+
+// Restore delegates to the default tree. See Tree for details.
+func Restore(r io.Reader) (*BackupManifest, error) {
+	return defaultTree.Restore(r)
+}
+
+// LoadChanges delegates to the default tree. See Tree for details.
+func LoadChanges(config, savedir string) error {
+	return defaultTree.LoadChanges(config, savedir)
+}
+
+// SaveChanges delegates to the default tree. See Tree for details.
+func SaveChanges(config, savedir string) error {
+	return defaultTree.SaveChanges(config, savedir)
+}
+
+// OnSet delegates to the default tree. See Tree for details.
+func OnSet(h SetHook) {
+	defaultTree.OnSet(h)
+}
+
+// OnDelete delegates to the default tree. See Tree for details.
+func OnDelete(h DeleteHook) {
+	defaultTree.OnDelete(h)
+}
+
+// OnCommit delegates to the default tree. See Tree for details.
+func OnCommit(h CommitHook) {
+	defaultTree.OnCommit(h)
+}
+
+// Query delegates to the default tree. See Tree for details.
+func Query(expr string) ([]Path, error) {
+	return defaultTree.Query(expr)
+}
+
+// RegisterValidator delegates to the default tree. See Tree for details.
+func RegisterValidator(option string, v Validator) {
+	defaultTree.RegisterValidator(option, v)
+}
+
+// Validate delegates to the default tree. See Tree for details.
+func Validate(configs ...string) error {
+	return defaultTree.Validate(configs...)
+}
+
+// SetStyle delegates to the default tree. See Tree for details.
+func SetStyle(style Style) {
+	defaultTree.SetStyle(style)
+}
+
+// ApplyTemplate delegates to the default tree. See Tree for details.
+func ApplyTemplate(config string, tmpl *template.Template, data interface{}) error {
+	return defaultTree.ApplyTemplate(config, tmpl, data)
+}
+
+// Lookup delegates to the default tree. See Tree for details.
+func Lookup(config, section, option string) ([]string, error) {
+	return defaultTree.Lookup(config, section, option)
+}
+
+// Apply delegates to the default tree. See Tree for details.
+func Apply(desired *Config, opts ApplyOptions) ([]Change, error) {
+	return defaultTree.Apply(desired, opts)
+}
+
+// CommitDryRun delegates to the default tree. See Tree for details.
+func CommitDryRun(ctx context.Context) ([]CommitPreview, error) {
+	return defaultTree.CommitDryRun(ctx)
+}
+
+// OnPreCommit delegates to the default tree. See Tree for details.
+func OnPreCommit(h PackageHook) {
+	defaultTree.OnPreCommit(h)
+}
+
+// OnPostCommit delegates to the default tree. See Tree for details.
+func OnPostCommit(h PackageHook) {
+	defaultTree.OnPostCommit(h)
+}
+
+// SafeCommit delegates to the default tree. See Tree for details.
+func SafeCommit(ctx context.Context, timeout time.Duration) (*PendingCommit, error) {
+	return defaultTree.SafeCommit(ctx, timeout)
+}
+
+// OnLoad delegates to the default tree. See Tree for details.
+func OnLoad(h LoadHook) {
+	defaultTree.OnLoad(h)
+}
+
+// OnCommitDone delegates to the default tree. See Tree for details.
+func OnCommitDone(h CommitDoneHook) {
+	defaultTree.OnCommitDone(h)
+}
+
+// Stats delegates to the default tree. See Tree for details.
+func Stats() []ConfigStats {
+	return defaultTree.Stats()
+}
+
+// SetLogger delegates to the default tree. See Tree for details.
+func SetLogger(l *slog.Logger) {
+	defaultTree.SetLogger(l)
+}
+
+// SetHistory delegates to the default tree. See Tree for details.
+func SetHistory(h *History) {
+	defaultTree.SetHistory(h)
+}
+
+// Undo delegates to the default tree. See Tree for details.
+func Undo(n int) int {
+	return defaultTree.Undo(n)
+}
+
+// Redo delegates to the default tree. See Tree for details.
+func Redo(n int) int {
+	return defaultTree.Redo(n)
+}
+
+// SetLimits delegates to the default tree. See Tree for details.
+func SetLimits(limits Limits) {
+	defaultTree.SetLimits(limits)
+}
+
+// TakeSnapshot delegates to the default tree. See Tree for details.
+func TakeSnapshot() *Snapshot {
+	return defaultTree.Snapshot()
+}
+
+// RegisterMigration delegates to the default tree. See Tree for details.
+func RegisterMigration(m Migration) {
+	defaultTree.RegisterMigration(m)
+}
+
+// Migrate delegates to the default tree. See Tree for details.
+func Migrate(configs ...string) ([]AppliedMigration, error) {
+	return defaultTree.Migrate(configs...)
+}
+
+// AddEphemeral delegates to the default tree. See Tree for details.
+func AddEphemeral(cfg *Config) error {
+	return defaultTree.AddEphemeral(cfg)
+}
+
+// IsEphemeral delegates to the default tree. See Tree for details.
+func IsEphemeral(config string) bool {
+	return defaultTree.IsEphemeral(config)
+}
+
+// Batch delegates to the default tree. See Tree for details.
+func Batch(fn func(tx *Tx) error) error {
+	return defaultTree.Batch(fn)
+}
+
+// SetForce delegates to the default tree. See Tree for details.
+func SetForce(force bool) {
+	defaultTree.SetForce(force)
+}
+
+// SetIncludes delegates to the default tree. See Tree for details.
+func SetIncludes(include func(sec *Section) string) {
+	defaultTree.SetIncludes(include)
+}
+
+// CopyConfig delegates to the default tree. See Tree for details.
+func CopyConfig(config string) (*Config, bool) {
+	return defaultTree.CopyConfig(config)
+}
@@ -0,0 +1,16 @@
+package convert
+
+import "github.com/wsiner/go-uci/ast"
+
+// addSection adds a decoded section to cfg. Like the UCI parser, it
+// merges sections with the same name.
+func addSection(cfg *ast.Config, typ, name string, opts []*ast.Option) {
+	sec := ast.NewSection(typ, name)
+	sec.Options = append(sec.Options, opts...)
+
+	if name == "" {
+		cfg.Add(sec)
+	} else {
+		cfg.Merge(sec)
+	}
+}
@@ -0,0 +1,174 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wsiner/go-uci/ast"
+)
+
+const tcNetwork = `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+
+config rule
+	option src 'wan'
+	list proto 'tcp'
+
+config rule
+	option src 'lan'
+`
+
+const tcNetworkYAML = `sections:
+    - name: lan
+      type: interface
+      options:
+        proto: static
+        ipaddr: 192.168.1.1
+        dns: [1.1.1.1, 9.9.9.9]
+    - type: rule
+      options:
+        src: wan
+        proto: [tcp]
+    - type: rule
+      options:
+        src: lan
+`
+
+const tcNetworkTOML = `[[sections]]
+  name = "lan"
+  type = "interface"
+  [sections.options]
+    dns = ["1.1.1.1", "9.9.9.9"]
+    ipaddr = "192.168.1.1"
+    proto = "static"
+
+[[sections]]
+  type = "rule"
+  [sections.options]
+    proto = ["tcp"]
+    src = "wan"
+
+[[sections]]
+  type = "rule"
+  [sections.options]
+    src = "lan"
+`
+
+func TestYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := ast.Parse("network", tcNetwork)
+	require.NoError(t, err)
+
+	b, err := MarshalYAML(cfg)
+	require.NoError(t, err)
+	assert.Equal(tcNetworkYAML, string(b))
+
+	actual, err := UnmarshalYAML("network", b)
+	require.NoError(t, err)
+	assert.EqualValues(cfg, actual)
+}
+
+func TestTOML(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := ast.Parse("network", tcNetwork)
+	require.NoError(t, err)
+
+	b, err := MarshalTOML(cfg)
+	require.NoError(t, err)
+	assert.Equal(tcNetworkTOML, string(b))
+
+	actual, err := UnmarshalTOML("network", b)
+	require.NoError(t, err)
+	assert.Equal("network", actual.Name)
+	assert.Len(actual.Sections, 3)
+
+	// options are sorted by the encoder, the decoder keeps that order
+	lan := actual.Get("lan")
+	require.NotNil(t, lan)
+	assert.Equal("dns", lan.Options[0].Name)
+	assert.EqualValues(cfg.Get("lan").Get("dns"), lan.Get("dns"))
+	assert.EqualValues(cfg.Get("@rule[0]").Get("proto"), actual.Get("@rule[0]").Get("proto"))
+}
+
+func TestUnmarshalTOML_order(t *testing.T) {
+	cfg, err := UnmarshalTOML("system", []byte(`
+[[sections]]
+type = "system"
+[sections.options]
+zonename = "UTC"
+hostname = "OpenWrt"
+log_size = 64
+`))
+	require.NoError(t, err)
+
+	sec := cfg.Get("@system[0]")
+	require.NotNil(t, sec)
+	assert.EqualValues(t, []*ast.Option{
+		ast.NewOption("zonename", ast.TypeOption, "UTC"),
+		ast.NewOption("hostname", ast.TypeOption, "OpenWrt"),
+		ast.NewOption("log_size", ast.TypeOption, "64"),
+	}, sec.Options)
+}
+
+func TestUnmarshalTOML_inline(t *testing.T) {
+	cfg, err := UnmarshalTOML("firewall", []byte(`
+sections = [
+	{type = "defaults", options = {output = "ACCEPT", input = "REJECT"}},
+	{type = "zone", name = "lan", options = {network = ["lan"]}},
+]
+`))
+	require.NoError(t, err)
+	require.Len(t, cfg.Sections, 2)
+	assert.EqualValues(t, []*ast.Option{
+		ast.NewOption("input", ast.TypeOption, "REJECT"),
+		ast.NewOption("output", ast.TypeOption, "ACCEPT"),
+	}, cfg.Sections[0].Options)
+	assert.Equal(t, "lan", cfg.Sections[1].Name)
+
+	_, err = UnmarshalTOML("firewall", []byte(`sections = [{type = "a"}, {type = "b", options = {c = {d = 1}}}]`))
+	assert.ErrorContains(t, err, "section 1: ")
+}
+
+func TestUnmarshal_invalid(t *testing.T) {
+	tt := map[string]struct {
+		yaml, toml string
+		err        string
+	}{
+		"missing type": {
+			yaml: "sections: [{name: foo}]",
+			toml: "[[sections]]\nname = \"foo\"",
+			err:  "section 0: missing type",
+		},
+		"empty list": {
+			yaml: "sections: [{type: foo, options: {bar: []}}]",
+			toml: "[[sections]]\ntype = \"foo\"\n[sections.options]\nbar = []",
+			err:  `list "bar" is empty`,
+		},
+		"nested": {
+			yaml: "sections: [{type: foo, options: {bar: {baz: 1}}}]",
+			toml: "[[sections]]\ntype = \"foo\"\n[sections.options.bar]\nbaz = 1",
+			err:  `option "bar" must be a scalar`,
+		},
+	}
+
+	for name := range tt {
+		tc := tt[name]
+		t.Run(name, func(t *testing.T) {
+			_, err := UnmarshalYAML("foo", []byte(tc.yaml))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+			_, err = UnmarshalTOML("foo", []byte(tc.toml))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
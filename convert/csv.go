@@ -0,0 +1,162 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// A CSVMapping describes how UnmarshalCSV turns the rows of a table into
+// sections of the same type.
+type CSVMapping struct {
+	// Type of the sections, e.g. "host".
+	Type string
+
+	// Name is the header of the column holding the section names. If
+	// empty, the sections are unnamed.
+	Name string
+
+	// Options maps column headers to option names. Columns without
+	// mapping are ignored. If nil, every column (except Name) is mapped
+	// to the option named like its header.
+	Options map[string]string
+
+	// Lists names the options whose cells are split into list values
+	// at Separator (spaces, if empty), e.g. "mac" for hosts with
+	// several MAC addresses.
+	Lists     []string
+	Separator string
+
+	// Defaults are set in every section, unless a row sets them.
+	Defaults map[string]string
+
+	// Comma is the field delimiter (',', if zero). Spreadsheets saved
+	// in some locales use ';'.
+	Comma rune
+}
+
+// UnmarshalCSV converts a CSV table into a config with the given name.
+// The first row holds the column headers, every other row becomes a
+// section (see CSVMapping). Empty cells are skipped. Rows with the same
+// name are merged, later rows overriding the options of earlier ones.
+//
+// The config can be merged into a tree with Tree.Apply:
+//
+//	hosts, err := convert.UnmarshalCSV("dhcp", body, convert.CSVMapping{
+//		Type: "host", Name: "hostname",
+//		Options: map[string]string{"hostname": "name", "mac": "mac", "ip": "ip"},
+//	})
+//	...
+//	changes, err := tree.Apply(hosts, uci.ApplyOptions{})
+func UnmarshalCSV(name string, b []byte, m CSVMapping) (*ast.Config, error) {
+	if !ast.ValidIdentifier(m.Type) {
+		return nil, fmt.Errorf("invalid section type %q", m.Type)
+	}
+	r := csv.NewReader(bytes.NewReader(b))
+	if m.Comma != 0 {
+		r.Comma = m.Comma
+	}
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("decoding CSV failed: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("decoding CSV failed: missing header")
+	}
+
+	header := rows[0]
+	nameCol := -1
+	options := make([]string, len(header)) // by column, "" for ignored ones
+	for i, col := range header {
+		col = strings.TrimSpace(col)
+		header[i] = col
+		switch opt, ok := m.Options[col]; {
+		case m.Name != "" && col == m.Name:
+			nameCol = i
+			if ok {
+				options[i] = opt
+			}
+		case m.Options == nil:
+			options[i] = col
+		case ok:
+			options[i] = opt
+		}
+		if options[i] != "" && !ast.ValidIdentifier(options[i]) {
+			return nil, fmt.Errorf("column %q: invalid option name %q", col, options[i])
+		}
+	}
+	if m.Name != "" && nameCol < 0 {
+		return nil, fmt.Errorf("missing column %q", m.Name)
+	}
+	for col := range m.Options {
+		if !slices.Contains(header, col) {
+			return nil, fmt.Errorf("missing column %q", col)
+		}
+	}
+	defaults := make([]string, 0, len(m.Defaults))
+	for opt := range m.Defaults {
+		defaults = append(defaults, opt)
+	}
+	sort.Strings(defaults)
+
+	cfg := ast.NewConfig(name)
+	for n, row := range rows[1:] {
+		var secName string
+		if nameCol >= 0 {
+			secName = strings.TrimSpace(row[nameCol])
+			if !ast.ValidIdentifier(secName) {
+				return nil, fmt.Errorf("row %d: invalid section name %q", n+2, secName)
+			}
+		}
+		sec := cfg.Get(secName)
+		if secName == "" || sec == nil {
+			sec = cfg.Add(ast.NewSection(m.Type, secName))
+		}
+		for i, cell := range row {
+			cell = strings.TrimSpace(cell)
+			if options[i] == "" || cell == "" {
+				continue
+			}
+			if opt := m.option(options[i], cell); opt != nil {
+				sec.SaveOrInsert(opt)
+			}
+		}
+	}
+	for _, sec := range cfg.Sections {
+		for _, opt := range defaults {
+			if o := m.option(opt, m.Defaults[opt]); sec.Get(opt) == nil && o != nil {
+				sec.Add(o)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// option returns the option for a cell, or nil if a list cell has no
+// values.
+func (m CSVMapping) option(name, cell string) *ast.Option {
+	if !slices.Contains(m.Lists, name) {
+		return ast.NewOption(name, ast.TypeOption, cell)
+	}
+	var values []string
+	if m.Separator == "" {
+		values = strings.Fields(cell)
+	} else {
+		for _, v := range strings.Split(cell, m.Separator) {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return ast.NewOption(name, ast.TypeList, values...)
+}
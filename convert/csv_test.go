@@ -0,0 +1,81 @@
+package convert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcHostsCSV = `hostname, mac,               ip,           comment
+laptop,   00:11:22:33:44:55, 192.168.1.10, Alice
+printer,  "00:11:22:33:44:66 00:11:22:33:44:67", 192.168.1.20,
+laptop,   ,                  192.168.1.11, moved
+`
+
+const tcHosts = `
+config host 'laptop'
+	option name 'laptop'
+	list mac '00:11:22:33:44:55'
+	option ip '192.168.1.11'
+	option leasetime '12h'
+
+config host 'printer'
+	option name 'printer'
+	list mac '00:11:22:33:44:66'
+	list mac '00:11:22:33:44:67'
+	option ip '192.168.1.20'
+	option leasetime '12h'
+
+`
+
+func TestUnmarshalCSV(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := UnmarshalCSV("dhcp", []byte(tcHostsCSV), CSVMapping{
+		Type:     "host",
+		Name:     "hostname",
+		Options:  map[string]string{"hostname": "name", "mac": "mac", "ip": "ip"},
+		Lists:    []string{"mac"},
+		Defaults: map[string]string{"leasetime": "12h"},
+	})
+	require.NoError(err)
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(err)
+	assert.Equal(t, tcHosts, buf.String())
+
+	// unnamed sections, all columns
+	cfg, err = UnmarshalCSV("firewall", []byte("name;src;dest_port;target\nAllow-SSH;wan;22;ACCEPT\nAllow-Web;wan;80 443;ACCEPT\n"),
+		CSVMapping{Type: "rule", Comma: ';'})
+	require.NoError(err)
+	require.Len(cfg.Sections, 2)
+	assert.Equal(t, "", cfg.Sections[1].Name)
+	assert.Equal(t, "80 443", cfg.Sections[1].LastValue("dest_port"))
+}
+
+func TestUnmarshalCSVErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		csv string
+		m   CSVMapping
+		err string
+	}{
+		"empty":          {"", CSVMapping{Type: "host"}, "missing header"},
+		"ragged":         {"a,b\n1\n", CSVMapping{Type: "host"}, "decoding CSV failed"},
+		"type":           {"a\n1\n", CSVMapping{Type: "dhcp-host"}, `invalid section type "dhcp-host"`},
+		"name column":    {"a\n1\n", CSVMapping{Type: "host", Name: "name"}, `missing column "name"`},
+		"option column":  {"a\n1\n", CSVMapping{Type: "host", Options: map[string]string{"b": "b"}}, `missing column "b"`},
+		"option name":    {"a-b\n1\n", CSVMapping{Type: "host"}, `column "a-b": invalid option name "a-b"`},
+		"section name":   {"name\nmy host\n", CSVMapping{Type: "host", Name: "name"}, `row 2: invalid section name "my host"`},
+		"missing name":   {"name,ip\n,10.0.0.1\n", CSVMapping{Type: "host", Name: "name"}, `row 2: invalid section name ""`},
+		"quoted newline": {"a\n\"1\n", CSVMapping{Type: "host"}, "decoding CSV failed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := UnmarshalCSV("dhcp", []byte(tc.csv), tc.m)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Package convert maps UCI configs to and from YAML and TOML documents,
+so that configurations can be kept in (GitOps) repositories and be
+converted to UCI at deploy time.
+
+Both formats share the same document structure. A config consists of a
+list of sections, each with a type, an optional name and a mapping of
+option names to values:
+
+	sections:
+	  - type: interface
+	    name: lan
+	    options:
+	      proto: static
+	      ipaddr: 192.168.1.1
+	      dns: [1.1.1.1, 9.9.9.9]
+	  - type: rule
+	    options:
+	      src: wan
+
+The mapping follows these rules:
+
+  - Unnamed sections simply omit the name. They are identified by their
+    position in the document, just like in UCI files, where the n-th
+    unnamed section of type "rule" is addressed as "@rule[n]".
+  - A scalar value represents a TypeOption option. Non-string scalars
+    (numbers, booleans) are converted to their textual representation.
+  - A sequence of scalars represents a TypeList option, even if it only
+    contains a single value. Empty sequences are rejected.
+  - The config name is not part of the document. Like ast.Parse, the
+    decoders take it as an argument (usually derived from the file name).
+
+The equivalent TOML document uses an array of tables:
+
+	[[sections]]
+	type = "interface"
+	name = "lan"
+	[sections.options]
+	proto = "static"
+	dns = ["1.1.1.1", "9.9.9.9"]
+
+YAML documents preserve the order of options in both directions. The
+TOML encoder writes options in alphabetical order; the TOML decoder
+keeps the order of the document.
+
+UnmarshalCSV imports tables of homogeneous sections, like DHCP hosts or
+firewall rules maintained as spreadsheets (see CSVMapping).
+*/
+package convert
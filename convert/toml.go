@@ -0,0 +1,138 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+	"github.com/wsiner/go-uci/ast"
+)
+
+// tomlSection is the TOML representation of a section. Options are
+// encoded as map, hence the encoder sorts them by name.
+type tomlSection struct {
+	Name    string                 `toml:"name,omitempty"`
+	Type    string                 `toml:"type"`
+	Options map[string]interface{} `toml:"options,omitempty"`
+}
+
+// MarshalTOML converts a config into a TOML document.
+func MarshalTOML(cfg *ast.Config) ([]byte, error) {
+	doc := struct {
+		Sections []tomlSection `toml:"sections"`
+	}{
+		Sections: make([]tomlSection, 0, len(cfg.Sections)),
+	}
+
+	for _, sec := range cfg.Sections {
+		ts := tomlSection{
+			Name:    sec.Name,
+			Type:    sec.Type,
+			Options: make(map[string]interface{}, len(sec.Options)),
+		}
+		for _, opt := range sec.Options {
+			switch opt.Type {
+			case ast.TypeOption:
+				if len(opt.Values) == 0 {
+					return nil, fmt.Errorf("option %q has no value", opt.Name)
+				}
+				ts.Options[opt.Name] = opt.Values[0]
+			case ast.TypeList:
+				ts.Options[opt.Name] = opt.Values
+			default:
+				return nil, ast.ErrUnknownOptionType{Type: fmt.Sprintf("!OptionType(%02x)", opt.Type)}
+			}
+		}
+		doc.Sections = append(doc.Sections, ts)
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding TOML failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalTOML converts a TOML document into a config with the given
+// name.
+func UnmarshalTOML(name string, b []byte) (*ast.Config, error) {
+	var doc struct {
+		Sections []tomlSection `toml:"sections"`
+	}
+	md, err := toml.Decode(string(b), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("decoding TOML failed: %w", err)
+	}
+
+	// The decoded option maps are unordered, but the meta data lists
+	// all keys in document order. Each "sections" key starts a new
+	// section. Inline tables don't, so their options are sorted by
+	// name instead.
+	order := make([][]string, 0, len(doc.Sections))
+	for _, key := range md.Keys() {
+		switch {
+		case len(key) == 1 && key[0] == "sections":
+			order = append(order, nil)
+		case len(key) == 3 && key[0] == "sections" && key[1] == "options" && len(order) > 0:
+			order[len(order)-1] = append(order[len(order)-1], key[2])
+		}
+	}
+
+	cfg := ast.NewConfig(name)
+	for i, s := range doc.Sections {
+		if s.Type == "" {
+			return nil, fmt.Errorf("section %d: missing type", i)
+		}
+		var names []string
+		if len(order) == len(doc.Sections) && len(order[i]) == len(s.Options) {
+			names = order[i]
+		} else {
+			names = slices.Sorted(maps.Keys(s.Options))
+		}
+		opts := make([]*ast.Option, 0, len(s.Options))
+		for _, oname := range names {
+			opt, err := tomlOption(oname, s.Options[oname])
+			if err != nil {
+				return nil, fmt.Errorf("section %d: %w", i, err)
+			}
+			opts = append(opts, opt)
+		}
+		addSection(cfg, s.Type, s.Name, opts)
+	}
+	return cfg, nil
+}
+
+func tomlOption(name string, val interface{}) (*ast.Option, error) {
+	switch v := val.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("list %q is empty", name)
+		}
+		values := make([]string, 0, len(v))
+		for _, elem := range v {
+			s, ok := tomlScalar(elem)
+			if !ok {
+				return nil, fmt.Errorf("list %q must only contain scalars", name)
+			}
+			values = append(values, s)
+		}
+		return ast.NewOption(name, ast.TypeList, values...), nil
+	default:
+		s, ok := tomlScalar(v)
+		if !ok {
+			return nil, fmt.Errorf("option %q must be a scalar or an array", name)
+		}
+		return ast.NewOption(name, ast.TypeOption, s), nil
+	}
+}
+
+func tomlScalar(v interface{}) (string, bool) {
+	switch v.(type) {
+	case string, int64, float64, bool:
+		return fmt.Sprint(v), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,124 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/wsiner/go-uci/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlDocument is the YAML representation of a config.
+type yamlDocument struct {
+	Sections []yamlSection `yaml:"sections"`
+}
+
+type yamlSection struct {
+	Name    string      `yaml:"name,omitempty"`
+	Type    string      `yaml:"type"`
+	Options yamlOptions `yaml:"options,omitempty"`
+}
+
+// yamlOptions keeps the options in document order (a plain map would
+// not).
+type yamlOptions []*ast.Option
+
+// IsZero implements yaml.IsZeroer, required for "omitempty".
+func (opts yamlOptions) IsZero() bool {
+	return len(opts) == 0
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (opts yamlOptions) MarshalYAML() (interface{}, error) {
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	for _, opt := range opts {
+		val, err := optionNode(opt)
+		if err != nil {
+			return nil, err
+		}
+		m.Content = append(m.Content, strNode(opt.Name), val)
+	}
+	return m, nil
+}
+
+func optionNode(opt *ast.Option) (*yaml.Node, error) {
+	switch opt.Type {
+	case ast.TypeOption:
+		if len(opt.Values) == 0 {
+			return nil, fmt.Errorf("option %q has no value", opt.Name)
+		}
+		return strNode(opt.Values[0]), nil
+	case ast.TypeList:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, v := range opt.Values {
+			seq.Content = append(seq.Content, strNode(v))
+		}
+		return seq, nil
+	default:
+		return nil, ast.ErrUnknownOptionType{Type: fmt.Sprintf("!OptionType(%02x)", opt.Type)}
+	}
+}
+
+func strNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (opts *yamlOptions) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: options must be a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+
+		switch val.Kind { //nolint:exhaustive
+		case yaml.ScalarNode:
+			*opts = append(*opts, ast.NewOption(key.Value, ast.TypeOption, val.Value))
+		case yaml.SequenceNode:
+			if len(val.Content) == 0 {
+				return fmt.Errorf("line %d: list %q is empty", val.Line, key.Value)
+			}
+			values := make([]string, 0, len(val.Content))
+			for _, v := range val.Content {
+				if v.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: list %q must only contain scalars", v.Line, key.Value)
+				}
+				values = append(values, v.Value)
+			}
+			*opts = append(*opts, ast.NewOption(key.Value, ast.TypeList, values...))
+		default:
+			return fmt.Errorf("line %d: option %q must be a scalar or a sequence", val.Line, key.Value)
+		}
+	}
+	return nil
+}
+
+// MarshalYAML converts a config into a YAML document.
+func MarshalYAML(cfg *ast.Config) ([]byte, error) {
+	doc := yamlDocument{Sections: make([]yamlSection, 0, len(cfg.Sections))}
+	for _, sec := range cfg.Sections {
+		doc.Sections = append(doc.Sections, yamlSection{
+			Name:    sec.Name,
+			Type:    sec.Type,
+			Options: sec.Options,
+		})
+	}
+	return yaml.Marshal(&doc)
+}
+
+// UnmarshalYAML converts a YAML document into a config with the given
+// name.
+func UnmarshalYAML(name string, b []byte) (*ast.Config, error) {
+	var doc yamlDocument
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("decoding YAML failed: %w", err)
+	}
+
+	cfg := ast.NewConfig(name)
+	for i, s := range doc.Sections {
+		if s.Type == "" {
+			return nil, fmt.Errorf("section %d: missing type", i)
+		}
+		addSection(cfg, s.Type, s.Name, s.Options)
+	}
+	return cfg, nil
+}
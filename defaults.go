@@ -0,0 +1,160 @@
+package uci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// WriteDefaults writes a shell script for /etc/uci-defaults, which
+// applies the configs using the uci CLI on first boot. This way, image
+// builders can bake configuration defined in Go into firmware images.
+//
+// The script is idempotent: named sections are created with "uci set",
+// unnamed sections are only added, if their "@type[index]" selector
+// doesn't exist yet, and lists are replaced. Sections and options of
+// the device's configs which the given configs don't mention are kept.
+// The script commits the configs, and exits with a non-zero status if
+// any command fails, so that it is run again on next boot.
+func WriteDefaults(w io.Writer, configs ...*Config) error {
+	s := newDefaultsScript(w)
+	for _, cfg := range configs {
+		for _, sec := range cfg.Sections {
+			ref := cfg.Name + "." + cfg.SectionName(sec)
+			s.section(cfg.Name, sec.Name, sec.Type, ref)
+			for _, opt := range sec.Options {
+				s.option(ref, opt.Name, opt.Type, opt.Values)
+			}
+		}
+		s.commit(cfg.Name)
+	}
+	return s.close()
+}
+
+// WriteDefaultsChanges works like WriteDefaults, but writes a script
+// applying changes (see Diff) to config. Its sections are removed
+// first; unnamed ones are addressed by the selector they had when the
+// changes were computed, and are only removed if the highest of those
+// selectors still exists, to not remove other sections when the script
+// runs again.
+func WriteDefaultsChanges(w io.Writer, config string, changes []Change) error {
+	s := newDefaultsScript(w)
+
+	removed := make(map[string][]int) // unnamed sections by type
+	var types []string
+	for _, c := range changes {
+		if c.Op != ast.OpDelSection {
+			continue
+		}
+		typ, idx, err := unnamedSelector(c.Section)
+		if err != nil {
+			s.printf("uci -q delete %s || true\n", quoteValue(config+"."+c.Section))
+			continue
+		}
+		if removed[typ] == nil {
+			types = append(types, typ)
+		}
+		removed[typ] = append(removed[typ], idx)
+	}
+	for _, typ := range types {
+		indices := removed[typ]
+		sort.Sort(sort.Reverse(sort.IntSlice(indices)))
+		s.printf("if uci -q get %s >/dev/null; then\n", quoteValue(fmt.Sprintf("%s.@%s[%d]", config, typ, indices[0])))
+		for _, idx := range indices {
+			s.printf("\tuci delete %s\n", quoteValue(fmt.Sprintf("%s.@%s[%d]", config, typ, idx)))
+		}
+		s.printf("fi\n")
+	}
+
+	for _, c := range changes {
+		ref := config + "." + c.Section
+		switch c.Op {
+		case ast.OpAddSection:
+			name := c.Section
+			if _, _, err := unnamedSelector(name); err == nil {
+				name = ""
+			}
+			s.section(config, name, c.Type, ref)
+		case ast.OpSetOption:
+			s.option(ref, c.Option, c.OptionType, c.New)
+		case ast.OpDelOption:
+			s.printf("uci -q delete %s || true\n", quoteValue(ref+"."+c.Option))
+		case ast.OpDelSection:
+			// see above
+		}
+	}
+	s.commit(config)
+	return s.close()
+}
+
+// unnamedSelector parses an "@type[index]" selector.
+func unnamedSelector(name string) (typ string, idx int, err error) {
+	open := strings.IndexByte(name, '[')
+	if !strings.HasPrefix(name, "@") || open < 2 || !strings.HasSuffix(name, "]") {
+		return "", 0, ast.ErrInvalidSectionSelector
+	}
+	idx, err = strconv.Atoi(name[open+1 : len(name)-1])
+	return name[1:open], idx, err
+}
+
+// defaultsScript writes the commands of a uci-defaults script. The first
+// write error is kept, and reported by close.
+type defaultsScript struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newDefaultsScript(w io.Writer) *defaultsScript {
+	s := &defaultsScript{w: bufio.NewWriter(w)}
+	s.printf("#!/bin/sh\nset -e\n\n")
+	return s
+}
+
+func (s *defaultsScript) printf(format string, args ...interface{}) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, args...)
+	}
+}
+
+// section writes the commands creating a section, unless it exists.
+// name is empty for unnamed sections, which ref addresses by selector.
+func (s *defaultsScript) section(config, name, typ, ref string) {
+	if name == "" {
+		s.printf("uci -q get %s >/dev/null || uci add %s %s >/dev/null\n",
+			quoteValue(ref), quoteValue(config), quoteValue(typ))
+		return
+	}
+	s.printf("uci set %s\n", quoteValue(ref+"="+typ))
+}
+
+// option writes the commands setting an option of the section ref.
+func (s *defaultsScript) option(ref, name string, typ OptionType, values []string) {
+	ref += "." + name
+	if typ == TypeList {
+		s.printf("uci -q delete %s || true\n", quoteValue(ref))
+		for _, v := range values {
+			s.printf("uci add_list %s\n", quoteValue(ref+"="+v))
+		}
+		return
+	}
+	if len(values) > 0 {
+		s.printf("uci set %s\n", quoteValue(ref+"="+values[len(values)-1]))
+	}
+}
+
+func (s *defaultsScript) commit(config string) {
+	s.printf("uci commit %s\n", quoteValue(config))
+}
+
+func (s *defaultsScript) close() error {
+	s.printf("\nexit 0\n")
+	if s.err != nil {
+		return s.err
+	}
+	return s.w.Flush()
+}
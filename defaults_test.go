@@ -0,0 +1,99 @@
+package uci
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestWriteDefaults(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := ast.Parse("firewall", `
+config zone 'lan'
+	option name 'lan'
+	list network 'lan'
+	list network 'guest'
+
+config rule
+	option name "Allow-SSH 'admin'"
+	option dest_port '22'
+`)
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	require.NoError(WriteDefaults(&buf, cfg))
+	assert.Equal(`#!/bin/sh
+set -e
+
+uci set 'firewall.lan=zone'
+uci set 'firewall.lan.name=lan'
+uci -q delete 'firewall.lan.network' || true
+uci add_list 'firewall.lan.network=lan'
+uci add_list 'firewall.lan.network=guest'
+uci -q get 'firewall.@rule[0]' >/dev/null || uci add 'firewall' 'rule' >/dev/null
+uci set 'firewall.@rule[0].name=Allow-SSH '\''admin'\'''
+uci set 'firewall.@rule[0].dest_port=22'
+uci commit 'firewall'
+
+exit 0
+`, buf.String())
+	if sh, err := exec.LookPath("sh"); err == nil {
+		assert.NoError(exec.Command(sh, "-n", "-c", buf.String()).Run())
+	}
+}
+
+func TestWriteDefaultsChanges(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	from, err := ast.Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+
+config interface 'wan'
+
+config route
+	option target '10.0.0.0/8'
+
+config route
+	option target '10.1.0.0/16'
+
+config route
+	option target '10.2.0.0/16'
+`)
+	require.NoError(err)
+	to := from.Clone()
+	to.Get("lan").Del("ipaddr")
+	to.Get("lan").Add(ast.NewOption("dns", ast.TypeList, "1.1.1.1"))
+	to.Del("wan")
+	to.Del("@route[2]")
+	to.Del("@route[1]")
+	to.Add(ast.NewSection("device", "br-lan"))
+
+	var buf bytes.Buffer
+	require.NoError(WriteDefaultsChanges(&buf, "network", ast.Diff(from, to)))
+	assert.Equal(`#!/bin/sh
+set -e
+
+uci -q delete 'network.wan' || true
+if uci -q get 'network.@route[2]' >/dev/null; then
+	uci delete 'network.@route[2]'
+	uci delete 'network.@route[1]'
+fi
+uci -q delete 'network.lan.dns' || true
+uci add_list 'network.lan.dns=1.1.1.1'
+uci -q delete 'network.lan.ipaddr' || true
+uci set 'network.br-lan=device'
+uci commit 'network'
+
+exit 0
+`, buf.String())
+}
@@ -0,0 +1,238 @@
+// Package dhcp manages static DHCP leases ("config host" sections) of
+// the dhcp config, which is shared by dnsmasq and odhcpd.
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrNoConfig     = errors.New("dhcp config not found")
+	ErrInvalidMAC   = uci.ErrInvalidMAC
+	ErrInvalidIP    = errors.New("invalid IP address")
+	ErrMissingMAC   = errors.New("lease without MAC address or DUID")
+	ErrDuplicateMAC = errors.New("duplicate MAC address")
+	ErrDuplicateIP  = errors.New("duplicate IP address")
+)
+
+// StaticLease is a static DHCP lease ("config host").
+type StaticLease struct {
+	Name      string // hostname
+	MACs      []net.HardwareAddr
+	IP        netip.Addr // IPv4 address, may be invalid (i.e. unset)
+	DUID      string     // DHCPv6 client identifier
+	HostID    string     // IPv6 interface identifier suffix, in hex
+	LeaseTime string     // e.g. "12h" or "infinite"
+	DNS       bool       // add a DNS entry for the host
+}
+
+// ParseLease converts a host section. The "mac" option may contain
+// multiple, whitespace separated addresses, in any notation accepted by
+// uci.ParseMAC.
+func ParseLease(sec *uci.Section) (*StaticLease, error) {
+	lease := &StaticLease{
+		Name:      sec.LastValue("name"),
+		DUID:      sec.LastValue("duid"),
+		HostID:    sec.LastValue("hostid"),
+		LeaseTime: sec.LastValue("leasetime"),
+		DNS:       sec.LastValue("dns") == "1",
+	}
+	for _, s := range sec.Fields("mac") {
+		mac, err := uci.ParseMAC(s)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", lease.Name, err)
+		}
+		lease.MACs = append(lease.MACs, mac)
+	}
+	if ip := sec.LastValue("ip"); ip != "" {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !addr.Is4() {
+			return nil, fmt.Errorf("host %s: %w %q", lease.Name, ErrInvalidIP, ip)
+		}
+		lease.IP = addr
+	}
+	return lease, nil
+}
+
+// Section renders lease into an unnamed host section. Multiple MAC
+// addresses are rendered as list.
+func (lease *StaticLease) Section() *uci.Section {
+	s := uci.NewSection("host", "")
+	set := func(name, value string) {
+		if value != "" {
+			s.Add(uci.NewOption(name, uci.TypeOption, value))
+		}
+	}
+
+	set("name", lease.Name)
+	switch len(lease.MACs) {
+	case 0:
+	case 1:
+		set("mac", lease.MACs[0].String())
+	default:
+		macs := make([]string, len(lease.MACs))
+		for i, mac := range lease.MACs {
+			macs[i] = mac.String()
+		}
+		s.Add(uci.NewOption("mac", uci.TypeList, macs...))
+	}
+	if lease.IP.IsValid() {
+		set("ip", lease.IP.String())
+	}
+	set("duid", lease.DUID)
+	set("hostid", lease.HostID)
+	set("leasetime", lease.LeaseTime)
+	if lease.DNS {
+		set("dns", "1")
+	}
+	return s
+}
+
+// Leases returns all static leases of the dhcp config.
+func Leases(t uci.Tree) ([]*StaticLease, error) {
+	cfg, ok := t.EnsureConfigLoaded("dhcp")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	return leases(cfg)
+}
+
+func leases(cfg *uci.Config) ([]*StaticLease, error) {
+	var leases []*StaticLease
+	for _, sec := range cfg.Sections {
+		if sec.Type != "host" {
+			continue
+		}
+		lease, err := ParseLease(sec)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// AddLease appends an anonymous host section for lease to the dhcp
+// config. It refuses to add a lease for a MAC address or IP address,
+// which already has a static lease.
+func AddLease(t uci.Tree, lease *StaticLease) error {
+	return AddLeaseNamed(t, lease, nil)
+}
+
+// AddLeaseNamed works like AddLease, but names the host section using
+// the naming policy p (e.g. uci.NameFromOption("name")).
+func AddLeaseNamed(t uci.Tree, lease *StaticLease, p uci.NamingPolicy) error {
+	if len(lease.MACs) == 0 && lease.DUID == "" {
+		return ErrMissingMAC
+	}
+	for _, mac := range lease.MACs {
+		if len(mac) != 6 {
+			return fmt.Errorf("%w %s", ErrInvalidMAC, mac)
+		}
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		cfg, ok := tx.CopyConfig("dhcp")
+		if !ok {
+			return ErrNoConfig
+		}
+		leases, err := leases(cfg)
+		if err != nil {
+			return err
+		}
+		for _, other := range leases {
+			for _, mac := range lease.MACs {
+				if hasMAC(other, mac) {
+					return fmt.Errorf("%w %s (host %s)", ErrDuplicateMAC, mac, other.Name)
+				}
+			}
+			if lease.IP.IsValid() && lease.IP == other.IP {
+				return fmt.Errorf("%w %s (host %s)", ErrDuplicateIP, lease.IP, other.Name)
+			}
+		}
+		// name the section in the copy, where names are unique as well
+		_, err = tx.PutSection("dhcp", cfg.AddNamed(lease.Section(), p))
+		return err
+	})
+}
+
+// RemoveLeaseByMAC removes the MAC address from the static leases. A
+// host section left without MAC address and DUID is removed entirely.
+// It reports whether a lease with the MAC address was found.
+func RemoveLeaseByMAC(t uci.Tree, mac net.HardwareAddr) (bool, error) {
+	var found bool
+	err := t.Batch(func(tx *uci.Tx) error {
+		cfg, ok := tx.CopyConfig("dhcp")
+		if !ok {
+			return ErrNoConfig
+		}
+
+		// parse all leases first, so that we don't leave a half modified
+		// config behind on errors
+		leases := make(map[*uci.Section]*StaticLease)
+		for _, sec := range cfg.Sections {
+			if sec.Type != "host" {
+				continue
+			}
+			lease, err := ParseLease(sec)
+			if err != nil {
+				return err
+			}
+			leases[sec] = lease
+		}
+
+		var removed []string
+		for _, sec := range cfg.Sections {
+			lease := leases[sec]
+			if lease == nil || !hasMAC(lease, mac) {
+				continue
+			}
+
+			found = true
+			var macs []net.HardwareAddr
+			for _, m := range lease.MACs {
+				if !equalMAC(m, mac) {
+					macs = append(macs, m)
+				}
+			}
+			if len(macs) == 0 && lease.DUID == "" {
+				removed = append(removed, cfg.SectionName(sec))
+				continue
+			}
+			lease.MACs = macs
+			// keeps the position of the option
+			if err := tx.ReplaceOptions("dhcp", cfg.SectionName(sec), lease.Section(), "mac"); err != nil {
+				return err
+			}
+		}
+		// backwards, so that the selectors of the others stay valid
+		for _, name := range slices.Backward(removed) {
+			if err := tx.DelSection("dhcp", name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+func hasMAC(lease *StaticLease, mac net.HardwareAddr) bool {
+	for _, m := range lease.MACs {
+		if equalMAC(m, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalMAC(a, b net.HardwareAddr) bool {
+	return a.String() == b.String()
+}
@@ -0,0 +1,141 @@
+package dhcp
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func mac(s string) net.HardwareAddr {
+	m, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestLeases(t *testing.T) {
+	leases, err := Leases(uci.NewTree("../testdata"))
+	require.NoError(t, err)
+	assert.Equal(t, []*StaticLease{{
+		Name: "nas",
+		MACs: []net.HardwareAddr{mac("00:11:22:33:44:55")},
+		IP:   netip.MustParseAddr("192.168.1.10"),
+		DNS:  true,
+	}, {
+		Name: "laptop",
+		MACs: []net.HardwareAddr{mac("00:11:22:33:44:66"), mac("00:11:22:33:44:77")},
+		IP:   netip.MustParseAddr("192.168.1.11"),
+	}}, leases)
+
+	_, err = Leases(uci.NewTree(t.TempDir()))
+	assert.Equal(t, ErrNoConfig, err)
+}
+
+func TestAddLease(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+
+	err := AddLease(tree, &StaticLease{Name: "nas2", MACs: []net.HardwareAddr{mac("00:11:22:33:44:55")}})
+	assert.True(errors.Is(err, ErrDuplicateMAC))
+	assert.EqualError(err, "duplicate MAC address 00:11:22:33:44:55 (host nas)")
+
+	err = AddLease(tree, &StaticLease{Name: "nas2", MACs: []net.HardwareAddr{mac("00:11:22:33:44:88")}, IP: netip.MustParseAddr("192.168.1.11")})
+	assert.True(errors.Is(err, ErrDuplicateIP))
+
+	assert.Equal(ErrMissingMAC, AddLease(tree, &StaticLease{Name: "nobody"}))
+
+	lease := &StaticLease{Name: "printer", MACs: []net.HardwareAddr{mac("AA:BB:CC:DD:EE:FF")}, IP: netip.MustParseAddr("192.168.1.12")}
+	assert.NoError(AddLease(tree, lease))
+	leases, err := Leases(tree)
+	assert.NoError(err)
+	assert.Len(leases, 3)
+	assert.Equal(lease, leases[2])
+
+	values, _ := tree.Get("dhcp", "@host[2]", "mac")
+	assert.Equal([]string{"aa:bb:cc:dd:ee:ff"}, values)
+}
+
+func TestRemoveLeaseByMAC(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+
+	found, err := RemoveLeaseByMAC(tree, mac("00:11:22:33:44:77"))
+	assert.NoError(err)
+	assert.True(found)
+	values, _ := tree.Get("dhcp", "@host[1]", "mac")
+	assert.Equal([]string{"00:11:22:33:44:66"}, values)
+	cfg, _ := tree.EnsureConfigLoaded("dhcp")
+	assert.Equal("mac", cfg.Get("@host[1]").Options[1].Name, "option keeps its position")
+
+	found, err = RemoveLeaseByMAC(tree, mac("00:11:22:33:44:55"))
+	assert.NoError(err)
+	assert.True(found)
+	hosts, _ := tree.GetSections("dhcp", "host")
+	assert.Len(hosts, 1)
+
+	found, err = RemoveLeaseByMAC(tree, mac("00:11:22:33:44:55"))
+	assert.NoError(err)
+	assert.False(found)
+}
+
+func TestRemoveLeaseByMACHooks(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+	tree.SetHistory(uci.NewHistory(0))
+	var deleted []uci.DeleteEvent
+	tree.OnDelete(func(e uci.DeleteEvent) error {
+		deleted = append(deleted, e)
+		return nil
+	})
+
+	found, err := RemoveLeaseByMAC(tree, mac("00:11:22:33:44:55"))
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal([]uci.DeleteEvent{{Config: "dhcp", Section: "@host[0]"}}, deleted)
+	hosts, _ := tree.GetSections("dhcp", "host")
+	assert.Len(hosts, 1)
+
+	assert.Equal(1, tree.Undo(1))
+	hosts, _ = tree.GetSections("dhcp", "host")
+	assert.Len(hosts, 2)
+}
+
+func TestAddLeaseNamed(t *testing.T) {
+	tree := uci.NewTree("../testdata")
+	lease := &StaticLease{Name: "printer", MACs: []net.HardwareAddr{mac("aa:bb:cc:dd:ee:ff")}}
+	assert.NoError(t, AddLeaseNamed(tree, lease, uci.NameFromOption("name")))
+
+	values, ok := tree.Get("dhcp", "printer", "mac")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"aa:bb:cc:dd:ee:ff"}, values)
+}
+
+func TestLeaseMACNotations(t *testing.T) {
+	assert := assert.New(t)
+
+	lease, err := ParseLease(uci.NewSection("host", ""))
+	require.NoError(t, err)
+	assert.Empty(lease.MACs)
+
+	sec := uci.NewSection("host", "")
+	sec.SetOption("mac", "00-11-22-AA-BB-CC 0011.22aa.bbdd")
+	lease, err = ParseLease(sec)
+	require.NoError(t, err)
+	assert.Equal([]net.HardwareAddr{mac("00:11:22:aa:bb:cc"), mac("00:11:22:aa:bb:dd")}, lease.MACs)
+	assert.Equal([]string{"00:11:22:aa:bb:cc", "00:11:22:aa:bb:dd"}, lease.Section().Get("mac").Values)
+
+	sec.SetOption("mac", "00:11:22:aa:bb")
+	_, err = ParseLease(sec)
+	assert.True(errors.Is(err, ErrInvalidMAC))
+
+	// only 48-bit addresses are supported by dnsmasq
+	tree := uci.NewTree("../testdata")
+	err = AddLease(tree, &StaticLease{Name: "eui64", MACs: []net.HardwareAddr{mac("00:11:22:33:44:55:66:77")}})
+	assert.True(errors.Is(err, ErrInvalidMAC))
+}
@@ -0,0 +1,215 @@
+// Package driftwatch detects configs drifting from a desired state, e.g.
+// because of manual edits on a managed device, and optionally reverts
+// them:
+//
+//	w := driftwatch.New(tree, desired, driftwatch.Options{
+//		Remediate: true,
+//		OnDrift: func(e driftwatch.Event) {
+//			log.Printf("%s drifted: %d changes", e.Config, len(e.Changes))
+//		},
+//	})
+//	prometheus.MustRegister(w)
+//	err := w.Run(ctx)
+//
+// Drift is computed like uci.Tree.Apply computes the changes needed to
+// reach the desired state, so Options.Apply determines what counts as
+// drift: by default, sections missing from the desired configs are left
+// alone.
+package driftwatch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wsiner/go-uci"
+)
+
+const namespace = "uci"
+
+// DefaultInterval is the interval of the checks made by Run, if
+// Options.Interval is zero.
+const DefaultInterval = time.Minute
+
+// Options configures a Watcher.
+type Options struct {
+	// Interval between the checks made by Run.
+	Interval time.Duration
+
+	// Apply controls how the live configs are compared with the
+	// desired ones, and how drift is remediated. DryRun is ignored.
+	Apply uci.ApplyOptions
+
+	// Remediate applies the desired configs and commits them, when
+	// they drifted.
+	Remediate bool
+
+	// OnDrift is called for every config which drifted, or couldn't
+	// be checked.
+	OnDrift func(Event)
+}
+
+// An Event describes the drift of a config.
+type Event struct {
+	Time    time.Time
+	Config  string
+	Changes []uci.Change // needed to reach the desired state
+
+	// Remediated is set, if the changes have been applied and
+	// committed.
+	Remediated bool
+
+	// Err is set, if the config couldn't be checked or remediated.
+	Err error
+}
+
+// A Watcher compares the configs of a tree with the desired ones. It is
+// a prometheus.Collector, reporting the results of the checks.
+type Watcher struct {
+	tree    uci.Tree
+	desired []*uci.Config
+	opts    Options
+
+	checks       prometheus.Counter
+	failures     *prometheus.CounterVec
+	remediations *prometheus.CounterVec
+	drift        *prometheus.GaugeVec
+}
+
+// New returns a watcher comparing the configs of t with desired.
+func New(t uci.Tree, desired []*uci.Config, opts Options) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	opts.Apply.DryRun = false
+	return &Watcher{
+		tree:    t,
+		desired: desired,
+		opts:    opts,
+		checks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "drift_checks_total",
+			Help:      "Number of drift checks.",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "drift_check_failures_total",
+			Help:      "Number of configs which couldn't be checked or remediated.",
+		}, []string{"config"}),
+		remediations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "drift_remediations_total",
+			Help:      "Number of times a drifted config was remediated.",
+		}, []string{"config"}),
+		drift: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "drift_changes",
+			Help:      "Number of changes needed to reach the desired state, as of the last check.",
+		}, []string{"config"}),
+	}
+}
+
+// LoadBundle reads the desired configs from a backup bundle, as written
+// by uci.Tree.Backup. If the bundle has a manifest, the configs are
+// verified against it.
+func LoadBundle(r io.Reader) ([]*uci.Config, error) {
+	t := uci.NewStoreTree(uci.NewMemoryStore(nil))
+	if _, err := t.Restore(r); err != nil {
+		return nil, err
+	}
+	stats := t.Stats()
+	configs := make([]*uci.Config, 0, len(stats))
+	for _, s := range stats {
+		cfg, _ := t.EnsureConfigLoaded(s.Name)
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// Run checks for drift periodically, until ctx is done, and returns the
+// context's error.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		w.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check compares the configs once, remediating drift if requested, and
+// returns the events passed to Options.OnDrift.
+//
+// Configs without uncommitted changes are reloaded first, so that the
+// watcher sees changes made by others. Remediation commits the tree, so
+// that changes made through it by others are committed as well.
+func (w *Watcher) Check(ctx context.Context) []Event {
+	w.checks.Inc()
+	var events []Event
+	for _, desired := range w.desired {
+		e := w.check(ctx, desired)
+		if e.Err != nil {
+			w.failures.WithLabelValues(e.Config).Inc()
+		}
+		if e.Remediated {
+			w.remediations.WithLabelValues(e.Config).Inc()
+		}
+		if len(e.Changes) == 0 && e.Err == nil {
+			continue
+		}
+		events = append(events, e)
+		if w.opts.OnDrift != nil {
+			w.opts.OnDrift(e)
+		}
+	}
+	return events
+}
+
+func (w *Watcher) check(ctx context.Context, desired *uci.Config) Event {
+	e := Event{Time: time.Now(), Config: desired.Name}
+	if cfg, ok := w.tree.EnsureConfigLoaded(desired.Name); !ok || !cfg.Tainted() {
+		err := w.tree.LoadConfigContext(ctx, desired.Name, true)
+		if err != nil && !errors.Is(err, uci.ErrConfigNotFound) {
+			e.Err = err
+			return e
+		}
+	}
+
+	opts := w.opts.Apply
+	opts.DryRun = true
+	e.Changes, e.Err = w.tree.Apply(desired, opts)
+	w.drift.WithLabelValues(desired.Name).Set(float64(len(e.Changes)))
+	if e.Err != nil || len(e.Changes) == 0 || !w.opts.Remediate {
+		return e
+	}
+
+	if _, e.Err = w.tree.Apply(desired, w.opts.Apply); e.Err != nil {
+		return e
+	}
+	if e.Err = w.tree.CommitContext(ctx); e.Err != nil {
+		return e
+	}
+	e.Remediated = true
+	return e
+}
+
+func (w *Watcher) Describe(ch chan<- *prometheus.Desc) {
+	w.checks.Describe(ch)
+	w.failures.Describe(ch)
+	w.remediations.Describe(ch)
+	w.drift.Describe(ch)
+}
+
+func (w *Watcher) Collect(ch chan<- prometheus.Metric) {
+	w.checks.Collect(ch)
+	w.failures.Collect(ch)
+	w.remediations.Collect(ch)
+	w.drift.Collect(ch)
+}
@@ -0,0 +1,112 @@
+package driftwatch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+const tcSystem = `
+config system
+	option hostname 'OpenWrt'
+	option timezone 'UTC'
+`
+
+func TestWatcher(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx := context.Background()
+	desired, err := ast.Parse("system", tcSystem)
+	require.NoError(err)
+	store := uci.NewMemoryStore(map[string]string{"system": tcSystem})
+	tree := uci.NewStoreTree(store)
+
+	var drifted []Event
+	w := New(tree, []*uci.Config{desired}, Options{
+		OnDrift: func(e Event) { drifted = append(drifted, e) },
+	})
+	assert.Empty(w.Check(ctx))
+
+	// edits by others are detected
+	require.NoError(store.Write(ctx, "system", []byte("config system\n\toption hostname 'edited'\n\toption timezone 'UTC'\n")))
+	events := w.Check(ctx)
+	require.Len(events, 1)
+	assert.Equal("system", events[0].Config)
+	require.Len(events[0].Changes, 1)
+	assert.Equal("hostname", events[0].Changes[0].Option)
+	assert.False(events[0].Remediated)
+	assert.Equal(events, drifted)
+
+	// and reverted
+	w.opts.Remediate = true
+	events = w.Check(ctx)
+	require.Len(events, 1)
+	assert.True(events[0].Remediated)
+	assert.NoError(events[0].Err)
+	body, err := store.Read(ctx, "system")
+	require.NoError(err)
+	assert.Contains(string(body), "option hostname 'OpenWrt'")
+	assert.Empty(w.Check(ctx))
+
+	require.NoError(testutil.CollectAndCompare(w, strings.NewReader(`
+# HELP uci_drift_changes Number of changes needed to reach the desired state, as of the last check.
+# TYPE uci_drift_changes gauge
+uci_drift_changes{config="system"} 0
+# HELP uci_drift_checks_total Number of drift checks.
+# TYPE uci_drift_checks_total counter
+uci_drift_checks_total 4
+# HELP uci_drift_remediations_total Number of times a drifted config was remediated.
+# TYPE uci_drift_remediations_total counter
+uci_drift_remediations_total{config="system"} 1
+`)))
+
+	// failures are reported
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "system"), []byte("config system\n\toption hostname 'edited'\n"), 0o644))
+	w = New(uci.NewTree(dir, uci.WithReadOnly()), []*uci.Config{desired}, Options{Remediate: true})
+	events = w.Check(ctx)
+	require.Len(events, 1)
+	assert.Len(events[0].Changes, 2)
+	assert.ErrorIs(events[0].Err, uci.ErrReadOnly)
+	assert.False(events[0].Remediated)
+}
+
+func TestRun(t *testing.T) {
+	desired, err := ast.Parse("system", tcSystem)
+	require.NoError(t, err)
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"system": "config system\n"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	w := New(tree, []*uci.Config{desired}, Options{
+		Interval:  time.Millisecond,
+		Remediate: true,
+		OnDrift:   func(Event) { cancel() },
+	})
+	assert.ErrorIs(t, w.Run(ctx), context.Canceled)
+	hostname, _ := tree.GetLast("system", "@system[0]", "hostname")
+	assert.Equal(t, "OpenWrt", hostname)
+}
+
+func TestLoadBundle(t *testing.T) {
+	require := require.New(t)
+
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"system": tcSystem, "dhcp": "config dnsmasq\n"}))
+	var buf bytes.Buffer
+	require.NoError(tree.Backup(&buf, uci.WithManifest("desired")))
+	configs, err := LoadBundle(&buf)
+	require.NoError(err)
+	require.Len(configs, 2)
+	assert.Equal(t, "dhcp", configs[0].Name)
+	assert.Equal(t, "OpenWrt", configs[1].Get("@system[0]").LastValue("hostname"))
+}
@@ -0,0 +1,27 @@
+package uci
+
+func (t *tree) AddEphemeral(cfg *Config) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.allowed(cfg.Name); err != nil {
+		return err
+	}
+	if t.configs == nil {
+		t.configs = make(map[string]*Config)
+	}
+	if t.ephemeral == nil {
+		t.ephemeral = make(map[string]bool)
+	}
+	t.configs[cfg.Name] = cfg
+	t.ephemeral[cfg.Name] = true
+	t.setProvenances(cfg.Name, nil)
+	return nil
+}
+
+func (t *tree) IsEphemeral(config string) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.ephemeral[config]
+}
@@ -0,0 +1,58 @@
+package uci
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEphemeral(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork})
+	r := NewStoreTree(store)
+
+	status := NewConfig("wireless_status")
+	status.Add(NewSection("iface", "wlan0")).Add(NewOption("up", TypeOption, "1"))
+	require.NoError(r.AddEphemeral(status))
+	assert.True(r.IsEphemeral("wireless_status"))
+	assert.False(r.IsEphemeral("network"))
+
+	up, ok := r.GetBool("wireless_status", "wlan0", "up")
+	assert.True(ok)
+	assert.True(up)
+	require.NoError(r.LoadConfig("wireless_status", true)) // not replaced
+	assert.True(r.Set("wireless_status", "wlan0", "up", "0"))
+	paths, err := r.Query("wireless_status.@iface[*].up=0")
+	require.NoError(err)
+	assert.Len(paths, 1)
+
+	// would-be state of a stored config
+	network, _ := r.EnsureConfigLoaded("network")
+	wouldBe := network.Clone()
+	require.NoError(r.AddEphemeral(wouldBe))
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+
+	preview, err := r.CommitDryRun(t.Context())
+	require.NoError(err)
+	assert.Empty(preview)
+	require.NoError(r.Commit())
+	assert.Equal(map[string][]byte{"network": []byte(tcSafeNetwork)}, store.files)
+
+	var buf bytes.Buffer
+	require.NoError(r.Backup(&buf))
+	restored := NewStoreTree(NewMemoryStore(nil))
+	_, err = restored.Restore(&buf)
+	require.NoError(err)
+	assert.Empty(restored.Stats()) // both configs are ephemeral
+
+	r.Revert()
+	assert.False(r.IsEphemeral("network"))
+	ipaddr, _ := r.GetLast("network", "lan", "ipaddr")
+	assert.Equal("192.168.1.1", ipaddr)
+	_, ok = r.Get("wireless_status", "wlan0", "up")
+	assert.False(ok)
+}
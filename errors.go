@@ -1,6 +1,14 @@
 package uci
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConfigNotFound is returned (wrapped) by LoadConfig, Lookup and
+// the other functions returning errors, if a config does not exist.
+// Such errors also match os.ErrNotExist.
+var ErrConfigNotFound = errors.New("config not found")
 
 // ErrConfigAlreadyLoaded is returned by LoadConfig, if the given config
 // name is already present.
@@ -12,15 +20,6 @@ func (err ErrConfigAlreadyLoaded) Error() string {
 	return fmt.Sprintf("%s already loaded", err.Name)
 }
 
-// ErrUnknownOptionType is returned when trying to parse an invalid OptionType.
-type ErrUnknownOptionType struct {
-	Type string
-}
-
-func (err ErrUnknownOptionType) Error() string {
-	return fmt.Sprintf("Unknown Option type %s", err.Type)
-}
-
 // IsConfigAlreadyLoaded reports, whether err is of type ErrConfigAlredyLoaded.
 //
 // Deprecated: use errors.Is or errors.As.
@@ -45,6 +44,11 @@ func (err ErrSectionTypeMismatch) Error() string {
 		err.Config, err.Section, err.ExistingType, err.NewType)
 }
 
+// Is makes section type mismatches match ErrTypeMismatch.
+func (err ErrSectionTypeMismatch) Is(target error) bool {
+	return target == ErrTypeMismatch //nolint:errorlint
+}
+
 // IsSectionTypeMismatch reports, whether err is of type ErrSectionTypeMismatch.
 //
 // Deprecated: use errors.Is or errors.As.
@@ -56,10 +60,76 @@ func IsSectionTypeMismatch(err error) bool {
 	return is
 }
 
-type ParseError string
+// ErrConfigNotAllowed is returned by restricted trees (see
+// NewRestrictedTree), if a config is not on the allowlist.
+type ErrConfigNotAllowed struct {
+	Name string
+}
+
+func (err ErrConfigNotAllowed) Error() string {
+	return fmt.Sprintf("config %s not allowed", err.Name)
+}
+
+// ErrBackupCorrupt is returned by Tree.Restore, if a config is missing
+// or does not match the checksum recorded in the bundle's manifest.
+type ErrBackupCorrupt struct {
+	Config string
+}
+
+func (err ErrBackupCorrupt) Error() string {
+	return fmt.Sprintf("backup corrupt: config %s does not match manifest", err.Config)
+}
+
+// ErrVetoed is returned by Commit, if a hook (see Tree.OnCommit) rejects
+// the commit. Set, SetType, Del and DelSection don't report errors; a
+// rejected change simply isn't made.
+type ErrVetoed struct {
+	Err error // as returned by the hook
+}
 
-func (err ParseError) Error() string {
-	return fmt.Sprintf("parse error: %s", string(err))
+func (err ErrVetoed) Error() string {
+	return fmt.Sprintf("commit vetoed: %v", err.Err)
+}
+
+func (err ErrVetoed) Unwrap() error {
+	return err.Err
+}
+
+// ErrRejected is returned by the methods of Tx, if a hook (see
+// Tree.OnSet and Tree.OnDelete) rejects a change.
+type ErrRejected struct {
+	Err error // as returned by the hook
+}
+
+func (err ErrRejected) Error() string {
+	return fmt.Sprintf("change rejected: %v", err.Err)
+}
+
+func (err ErrRejected) Unwrap() error {
+	return err.Err
+}
+
+// ErrConcurrentModification is returned (wrapped) by Commit, if a
+// config has been changed by others since it was loaded, unless the
+// tree is forced (see Tree.SetForce). Reloading the config (with
+// LoadConfig) discards the changes made to it, and resolves the
+// conflict.
+var ErrConcurrentModification = errors.New("config modified concurrently")
+
+// ErrRolledBack is returned by Commit, if a post-commit hook (see
+// Tree.OnPostCommit) fails. The previous contents of the config have
+// been restored, unless Err says otherwise.
+type ErrRolledBack struct {
+	Config string
+	Err    error // as returned by the hook
+}
+
+func (err ErrRolledBack) Error() string {
+	return fmt.Sprintf("commit of %s rolled back: %v", err.Config, err.Err)
+}
+
+func (err ErrRolledBack) Unwrap() error {
+	return err.Err
 }
 
 // IsParseError reports, whether err is of type ParseError.
@@ -69,6 +139,6 @@ func IsParseError(err error) bool {
 	if err == nil {
 		return false
 	}
-	_, is := err.(*ParseError) //nolint:errorlint
-	return is
+	var perr *ParseError
+	return errors.As(err, &perr)
 }
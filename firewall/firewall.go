@@ -0,0 +1,458 @@
+// Package firewall builds firewall configs from typed zones, rules,
+// redirects and forwardings:
+//
+//	cfg, err := firewall.New().
+//		Defaults(firewall.Defaults{Input: firewall.Reject, Output: firewall.Accept, Forward: firewall.Reject}).
+//		Zone(firewall.Zone{Name: "lan", Networks: []string{"lan"}, Input: firewall.Accept, Output: firewall.Accept, Forward: firewall.Accept}).
+//		Zone(firewall.Zone{Name: "wan", Networks: []string{"wan", "wan6"}, Input: firewall.Reject, Output: firewall.Accept, Forward: firewall.Reject, Masq: true, MTUFix: true}).
+//		Forwarding(firewall.Forwarding{Src: "lan", Dest: "wan"}).
+//		Build()
+//
+// The sections are rendered in the order used by OpenWrt's default
+// config: defaults, zones, forwardings, rules, redirects.
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrZoneName      = errors.New("zone name must not be empty")
+	ErrZoneNameTaken = errors.New("zone name already taken")
+	ErrUnknownZone   = errors.New("unknown zone")
+	ErrPolicy        = errors.New("invalid policy")
+	ErrTarget        = errors.New("invalid target")
+	ErrFamily        = errors.New("invalid family")
+)
+
+// Policy is the default action of a chain.
+type Policy string
+
+// Policies. The empty policy means "inherit".
+const (
+	Accept Policy = "ACCEPT"
+	Reject Policy = "REJECT"
+	Drop   Policy = "DROP"
+)
+
+func (p Policy) valid() bool {
+	return p == "" || p == Accept || p == Reject || p == Drop
+}
+
+// Target is the action of a rule or redirect.
+type Target string
+
+// Targets of rules (ACCEPT, REJECT, DROP, MARK, NOTRACK) and redirects
+// (DNAT, SNAT).
+const (
+	TargetAccept  Target = "ACCEPT"
+	TargetReject  Target = "REJECT"
+	TargetDrop    Target = "DROP"
+	TargetMark    Target = "MARK"
+	TargetNoTrack Target = "NOTRACK"
+	TargetDNAT    Target = "DNAT"
+	TargetSNAT    Target = "SNAT"
+)
+
+// Family restricts a section to a protocol family. The empty family
+// means "any".
+type Family string
+
+// Protocol families.
+const (
+	Any  Family = "any"
+	IPv4 Family = "ipv4"
+	IPv6 Family = "ipv6"
+)
+
+func (f Family) valid() bool {
+	return f == "" || f == Any || f == IPv4 || f == IPv6
+}
+
+// Defaults holds the global firewall settings.
+type Defaults struct {
+	Input, Output, Forward Policy
+	SynFloodProtect        bool
+	DropInvalid            bool
+	FlowOffloading         bool
+	FlowOffloadingHW       bool
+}
+
+// Zone groups interfaces.
+type Zone struct {
+	Name                   string
+	Networks               []string // logical interfaces
+	Devices                []string // raw devices
+	Subnets                []string
+	Input, Output, Forward Policy
+	Masq                   bool
+	MTUFix                 bool
+	Family                 Family
+	Log                    bool
+}
+
+// Forwarding allows traffic from one zone to another.
+type Forwarding struct {
+	Src, Dest string
+	Family    Family
+	Disabled  bool
+}
+
+// Rule matches traffic, and accepts, rejects or drops it. Src and Dest
+// name zones; "*" matches any zone.
+type Rule struct {
+	Name     string
+	Src      string
+	SrcIP    []string
+	SrcMAC   string
+	SrcPort  string
+	Proto    []string
+	Dest     string
+	DestIP   []string
+	DestPort string
+	ICMPType []string
+	Target   Target
+	Family   Family
+	Limit    string
+	Disabled bool
+}
+
+// Redirect forwards ports (DNAT), or rewrites source addresses (SNAT).
+type Redirect struct {
+	Name         string
+	Src          string
+	SrcIP        string
+	SrcDPort     string
+	Proto        []string
+	Dest         string
+	DestIP       string
+	DestPort     string
+	Target       Target // defaults to DNAT
+	NoReflection bool
+	Disabled     bool
+}
+
+// A ZoneError describes an invalid reference to, or definition of, a
+// zone.
+type ZoneError struct {
+	Section string // e.g. "forwarding #0" or "rule Allow-Ping"
+	Zone    string
+	Err     error
+}
+
+func (err *ZoneError) Error() string {
+	return fmt.Sprintf("%s: %v %q", err.Section, err.Err, err.Zone)
+}
+
+func (err *ZoneError) Unwrap() error {
+	return err.Err
+}
+
+// Builder collects the sections of a firewall config.
+type Builder struct {
+	defaults    *Defaults
+	zones       []Zone
+	forwardings []Forwarding
+	rules       []Rule
+	redirects   []Redirect
+	naming      uci.NamingPolicy
+}
+
+// New returns an empty builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+// Naming sets the policy to name the rendered sections. By default,
+// all sections are anonymous.
+func (b *Builder) Naming(p uci.NamingPolicy) *Builder {
+	b.naming = p
+	return b
+}
+
+// Defaults sets the global settings.
+func (b *Builder) Defaults(d Defaults) *Builder {
+	b.defaults = &d
+	return b
+}
+
+// Zone adds a zone.
+func (b *Builder) Zone(z Zone) *Builder {
+	b.zones = append(b.zones, z)
+	return b
+}
+
+// Forwarding adds a forwarding.
+func (b *Builder) Forwarding(f Forwarding) *Builder {
+	b.forwardings = append(b.forwardings, f)
+	return b
+}
+
+// Rule adds a traffic rule.
+func (b *Builder) Rule(r Rule) *Builder {
+	b.rules = append(b.rules, r)
+	return b
+}
+
+// Redirect adds a redirect.
+func (b *Builder) Redirect(r Redirect) *Builder {
+	b.redirects = append(b.redirects, r)
+	return b
+}
+
+// Build validates the collected sections, and renders them into a
+// firewall config. Zones must have unique names, and all zones
+// referenced by forwardings, rules and redirects must be defined.
+func (b *Builder) Build() (*uci.Config, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg := uci.NewConfig("firewall")
+	if b.defaults != nil {
+		cfg.AddNamed(b.defaults.Section(), b.naming)
+	}
+	for i := range b.zones {
+		cfg.AddNamed(b.zones[i].Section(), b.naming)
+	}
+	for i := range b.forwardings {
+		cfg.AddNamed(b.forwardings[i].Section(), b.naming)
+	}
+	for i := range b.rules {
+		cfg.AddNamed(b.rules[i].Section(), b.naming)
+	}
+	for i := range b.redirects {
+		cfg.AddNamed(b.redirects[i].Section(), b.naming)
+	}
+	return cfg, nil
+}
+
+func (b *Builder) validate() error { //nolint:cyclop
+	if d := b.defaults; d != nil {
+		for _, p := range []Policy{d.Input, d.Output, d.Forward} {
+			if !p.valid() {
+				return fmt.Errorf("defaults: %w %q", ErrPolicy, p)
+			}
+		}
+	}
+
+	zones := make(map[string]bool, len(b.zones))
+	for i, z := range b.zones {
+		name := fmt.Sprintf("zone #%d", i)
+		switch {
+		case z.Name == "":
+			return fmt.Errorf("%s: %w", name, ErrZoneName)
+		case zones[z.Name]:
+			return &ZoneError{name, z.Name, ErrZoneNameTaken}
+		case !z.Family.valid():
+			return fmt.Errorf("zone %s: %w %q", z.Name, ErrFamily, z.Family)
+		}
+		for _, p := range []Policy{z.Input, z.Output, z.Forward} {
+			if !p.valid() {
+				return fmt.Errorf("zone %s: %w %q", z.Name, ErrPolicy, p)
+			}
+		}
+		zones[z.Name] = true
+	}
+
+	checkZone := func(section, zone string, required bool) error {
+		if zone == "" && !required || zone == "*" || zones[zone] {
+			return nil
+		}
+		return &ZoneError{section, zone, ErrUnknownZone}
+	}
+
+	for i, f := range b.forwardings {
+		name := fmt.Sprintf("forwarding #%d", i)
+		if err := checkZone(name, f.Src, true); err != nil {
+			return err
+		}
+		if err := checkZone(name, f.Dest, true); err != nil {
+			return err
+		}
+		if !f.Family.valid() {
+			return fmt.Errorf("%s: %w %q", name, ErrFamily, f.Family)
+		}
+	}
+
+	for i, r := range b.rules {
+		name := describe("rule", r.Name, i)
+		if err := checkZone(name, r.Src, false); err != nil {
+			return err
+		}
+		if err := checkZone(name, r.Dest, false); err != nil {
+			return err
+		}
+		switch r.Target {
+		case "", TargetAccept, TargetReject, TargetDrop, TargetMark, TargetNoTrack:
+		default:
+			return fmt.Errorf("%s: %w %q", name, ErrTarget, r.Target)
+		}
+		if !r.Family.valid() {
+			return fmt.Errorf("%s: %w %q", name, ErrFamily, r.Family)
+		}
+	}
+
+	for i, r := range b.redirects {
+		name := describe("redirect", r.Name, i)
+		if err := checkZone(name, r.Src, false); err != nil {
+			return err
+		}
+		if err := checkZone(name, r.Dest, false); err != nil {
+			return err
+		}
+		switch r.Target {
+		case "", TargetDNAT, TargetSNAT:
+		default:
+			return fmt.Errorf("%s: %w %q", name, ErrTarget, r.Target)
+		}
+	}
+	return nil
+}
+
+func describe(typ, name string, i int) string {
+	if name != "" {
+		return typ + " " + name
+	}
+	return fmt.Sprintf("%s #%d", typ, i)
+}
+
+// sectionBuilder renders options, skipping empty values.
+type sectionBuilder struct {
+	*uci.Section
+}
+
+func newSection(typ string) sectionBuilder {
+	return sectionBuilder{uci.NewSection(typ, "")}
+}
+
+func (s sectionBuilder) option(name, value string) {
+	if value != "" {
+		s.Add(uci.NewOption(name, uci.TypeOption, value))
+	}
+}
+
+func (s sectionBuilder) list(name string, values []string) {
+	if len(values) > 0 {
+		s.Add(uci.NewOption(name, uci.TypeList, values...))
+	}
+}
+
+func (s sectionBuilder) flag(name string, value bool) {
+	if value {
+		s.Add(uci.NewOption(name, uci.TypeOption, "1"))
+	}
+}
+
+// disabled renders the "enabled" option, which defaults to true.
+func (s sectionBuilder) disabled(value bool) {
+	if value {
+		s.Add(uci.NewOption("enabled", uci.TypeOption, "0"))
+	}
+}
+
+// Section renders d into a "defaults" section.
+func (d *Defaults) Section() *uci.Section {
+	s := newSection("defaults")
+	s.option("input", string(d.Input))
+	s.option("output", string(d.Output))
+	s.option("forward", string(d.Forward))
+	s.flag("synflood_protect", d.SynFloodProtect)
+	s.flag("drop_invalid", d.DropInvalid)
+	s.flag("flow_offloading", d.FlowOffloading)
+	s.flag("flow_offloading_hw", d.FlowOffloadingHW)
+	return s.Section
+}
+
+// Section renders z into a "zone" section.
+func (z *Zone) Section() *uci.Section {
+	s := newSection("zone")
+	s.option("name", z.Name)
+	s.list("network", z.Networks)
+	s.list("device", z.Devices)
+	s.list("subnet", z.Subnets)
+	s.option("input", string(z.Input))
+	s.option("output", string(z.Output))
+	s.option("forward", string(z.Forward))
+	s.flag("masq", z.Masq)
+	s.flag("mtu_fix", z.MTUFix)
+	s.option("family", family(z.Family))
+	s.flag("log", z.Log)
+	return s.Section
+}
+
+// Section renders f into a "forwarding" section.
+func (f *Forwarding) Section() *uci.Section {
+	s := newSection("forwarding")
+	s.option("src", f.Src)
+	s.option("dest", f.Dest)
+	s.option("family", family(f.Family))
+	s.disabled(f.Disabled)
+	return s.Section
+}
+
+// Section renders r into a "rule" section.
+func (r *Rule) Section() *uci.Section {
+	s := newSection("rule")
+	s.option("name", r.Name)
+	s.option("src", r.Src)
+	s.list("src_ip", r.SrcIP)
+	s.option("src_mac", r.SrcMAC)
+	s.option("src_port", r.SrcPort)
+	s.list("proto", lower(r.Proto))
+	s.option("dest", r.Dest)
+	s.list("dest_ip", r.DestIP)
+	s.option("dest_port", r.DestPort)
+	s.list("icmp_type", r.ICMPType)
+	s.option("target", string(r.Target))
+	s.option("family", family(r.Family))
+	s.option("limit", r.Limit)
+	s.disabled(r.Disabled)
+	return s.Section
+}
+
+// Section renders r into a "redirect" section.
+func (r *Redirect) Section() *uci.Section {
+	s := newSection("redirect")
+	s.option("name", r.Name)
+	s.option("src", r.Src)
+	s.option("src_ip", r.SrcIP)
+	s.option("src_dport", r.SrcDPort)
+	s.list("proto", lower(r.Proto))
+	s.option("dest", r.Dest)
+	s.option("dest_ip", r.DestIP)
+	s.option("dest_port", r.DestPort)
+	target := r.Target
+	if target == "" {
+		target = TargetDNAT
+	}
+	s.option("target", string(target))
+	if r.NoReflection {
+		s.Add(uci.NewOption("reflection", uci.TypeOption, "0"))
+	}
+	s.disabled(r.Disabled)
+	return s.Section
+}
+
+// family omits the default family.
+func family(f Family) string {
+	if f == Any {
+		return ""
+	}
+	return string(f)
+}
+
+func lower(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(v)
+	}
+	return out
+}
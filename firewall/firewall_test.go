@@ -0,0 +1,125 @@
+package firewall
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestBuild(t *testing.T) {
+	cfg, err := New().
+		Redirect(Redirect{Name: "ssh", Src: "wan", SrcDPort: "2222", Proto: []string{"TCP"}, Dest: "lan", DestIP: "192.168.1.10", DestPort: "22", NoReflection: true}).
+		Rule(Rule{Name: "Allow-Ping", Src: "wan", Proto: []string{"icmp"}, ICMPType: []string{"echo-request"}, Target: TargetAccept, Family: IPv4}).
+		Forwarding(Forwarding{Src: "lan", Dest: "wan"}).
+		Zone(Zone{Name: "lan", Networks: []string{"lan"}, Input: Accept, Output: Accept, Forward: Accept}).
+		Zone(Zone{Name: "wan", Networks: []string{"wan", "wan6"}, Input: Reject, Output: Accept, Forward: Reject, Masq: true, MTUFix: true, Family: Any}).
+		Defaults(Defaults{Input: Reject, Output: Accept, Forward: Reject, SynFloodProtect: true}).
+		Build()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+	option forward 'REJECT'
+	option synflood_protect '1'
+
+config zone
+	option name 'lan'
+	list network 'lan'
+	option input 'ACCEPT'
+	option output 'ACCEPT'
+	option forward 'ACCEPT'
+
+config zone
+	option name 'wan'
+	list network 'wan'
+	list network 'wan6'
+	option input 'REJECT'
+	option output 'ACCEPT'
+	option forward 'REJECT'
+	option masq '1'
+	option mtu_fix '1'
+
+config forwarding
+	option src 'lan'
+	option dest 'wan'
+
+config rule
+	option name 'Allow-Ping'
+	option src 'wan'
+	list proto 'icmp'
+	list icmp_type 'echo-request'
+	option target 'ACCEPT'
+	option family 'ipv4'
+
+config redirect
+	option name 'ssh'
+	option src 'wan'
+	option src_dport '2222'
+	list proto 'tcp'
+	option dest 'lan'
+	option dest_ip '192.168.1.10'
+	option dest_port '22'
+	option target 'DNAT'
+	option reflection '0'
+
+`, buf.String())
+}
+
+func TestBuildErrors(t *testing.T) {
+	lan := Zone{Name: "lan"}
+
+	tt := []struct {
+		name string
+		b    *Builder
+		err  error
+		msg  string
+	}{
+		{"empty zone name", New().Zone(Zone{}), ErrZoneName, "zone #0: zone name must not be empty"},
+		{"duplicate zone", New().Zone(lan).Zone(lan), ErrZoneNameTaken, `zone #1: zone name already taken "lan"`},
+		{"bad policy", New().Zone(Zone{Name: "lan", Input: "allow"}), ErrPolicy, `zone lan: invalid policy "allow"`},
+		{"bad defaults", New().Defaults(Defaults{Forward: "nope"}), ErrPolicy, `defaults: invalid policy "nope"`},
+		{"unknown forwarding zone", New().Zone(lan).Forwarding(Forwarding{Src: "lan", Dest: "wan"}), ErrUnknownZone, `forwarding #0: unknown zone "wan"`},
+		{"missing forwarding zone", New().Zone(lan).Forwarding(Forwarding{Src: "lan"}), ErrUnknownZone, `forwarding #0: unknown zone ""`},
+		{"unknown rule zone", New().Rule(Rule{Name: "r", Src: "wan"}), ErrUnknownZone, `rule r: unknown zone "wan"`},
+		{"bad rule target", New().Rule(Rule{Target: TargetDNAT}), ErrTarget, `rule #0: invalid target "DNAT"`},
+		{"bad family", New().Rule(Rule{Family: "ipv5"}), ErrFamily, `rule #0: invalid family "ipv5"`},
+		{"bad redirect target", New().Redirect(Redirect{Target: TargetAccept}), ErrTarget, `redirect #0: invalid target "ACCEPT"`},
+	}
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.b.Build()
+			assert.True(t, errors.Is(err, tc.err), "got %v", err)
+			assert.EqualError(t, err, tc.msg)
+		})
+	}
+
+	// any zone
+	_, err := New().Rule(Rule{Src: "*", Dest: "*"}).Build()
+	assert.NoError(t, err)
+}
+
+func TestBuildNaming(t *testing.T) {
+	cfg, err := New().
+		Naming(uci.WithPrefix("fw_", uci.NameFromOption("name"))).
+		Zone(Zone{Name: "lan"}).
+		Rule(Rule{Name: "Allow Ping", Src: "lan"}).
+		Forwarding(Forwarding{Src: "lan", Dest: "lan"}).
+		Build()
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(cfg.Sections))
+	for _, s := range cfg.Sections {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"fw_lan", "fw_forwarding", "fw_Allow_Ping"}, names)
+}
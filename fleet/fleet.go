@@ -0,0 +1,238 @@
+// Package fleet applies the same changes to the trees of many devices,
+// e.g. local trees, remote trees over SSH (see sshremote), or trees of
+// devices reached via ubus, with bounded parallelism and retries:
+//
+//	c := fleet.New(fleet.Options{Parallelism: 8, Retries: 2})
+//	report := c.Apply(ctx, devices, func(tx *uci.Tx) error {
+//		return tx.Set("system", "ntp", "server", "ntp1.example.com", "ntp2.example.com")
+//	})
+//	for _, r := range report.Failed() {
+//		log.Printf("%s: %v", r.Device, r.Err)
+//	}
+//
+// The changes are made in a batch (see uci.Tree.Batch), and committed.
+// The coordinator assumes to own the trees: after a failed attempt, all
+// uncommitted changes of the device's tree are reverted.
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wsiner/go-uci"
+)
+
+// Defaults of Options.
+const (
+	DefaultParallelism = 4
+	DefaultRetryDelay  = time.Second
+)
+
+// A Changeset makes the changes to a device's tree.
+type Changeset func(tx *uci.Tx) error
+
+// A Device is a tree to change.
+type Device struct {
+	Name string
+	Tree uci.Tree
+}
+
+// Options configure a Coordinator.
+type Options struct {
+	// Parallelism is the maximum number of devices changed at the
+	// same time.
+	Parallelism int
+
+	// Retries is the number of attempts made after the first one
+	// failed. The delay between attempts starts at RetryDelay, and
+	// doubles after each attempt.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Retryable reports whether a failed attempt is retried. By
+	// default, errors caused by the changes themselves, like missing
+	// sections, invalid values or vetoes of hooks, are not retried,
+	// since they'd fail again; others, like I/O errors or concurrent
+	// modifications, are.
+	Retryable func(err error) bool
+
+	// OnResult is called with the result of each device, once it is
+	// done, e.g. to report progress. It may be called concurrently.
+	OnResult func(Result)
+}
+
+// A Result is the outcome of changing a device.
+type Result struct {
+	Device   string
+	Attempts int
+	Duration time.Duration
+	Err      error // of the last attempt
+}
+
+// A Report aggregates the results of all devices.
+type Report struct {
+	Results  []Result // in the order of the devices
+	Duration time.Duration
+}
+
+// Succeeded returns the names of the devices changed successfully.
+func (r *Report) Succeeded() []string {
+	var names []string
+	for _, res := range r.Results {
+		if res.Err == nil {
+			names = append(names, res.Device)
+		}
+	}
+	return names
+}
+
+// Failed returns the results of the devices which failed.
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of the failed devices, prefixed with their
+// names, or returns nil, if all succeeded.
+func (r *Report) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", res.Device, res.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// String summarizes the report, e.g. "3 of 4 devices changed, failed:
+// ap2".
+func (r *Report) String() string {
+	s := fmt.Sprintf("%d of %d devices changed", len(r.Results)-len(r.Failed()), len(r.Results))
+	if failed := r.Failed(); len(failed) > 0 {
+		names := make([]string, len(failed))
+		for i, res := range failed {
+			names[i] = res.Device
+		}
+		s += ", failed: " + strings.Join(names, ", ")
+	}
+	return s
+}
+
+// A Coordinator applies changesets to devices.
+type Coordinator struct {
+	opts Options
+}
+
+// New returns a coordinator.
+func New(opts Options) *Coordinator {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = DefaultParallelism
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = retryable
+	}
+	return &Coordinator{opts: opts}
+}
+
+// Apply makes the changes of cs to the trees of all devices, and
+// commits them. Devices not started when ctx is done fail with its
+// error.
+func (c *Coordinator) Apply(ctx context.Context, devices []Device, cs Changeset) *Report {
+	start := time.Now()
+	report := &Report{Results: make([]Result, len(devices))}
+	sem := make(chan struct{}, c.opts.Parallelism)
+	var wg sync.WaitGroup
+	for i, d := range devices {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			report.Results[i] = Result{Device: d.Name, Err: ctx.Err()}
+			c.done(report.Results[i])
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Results[i] = c.apply(ctx, d, cs)
+			c.done(report.Results[i])
+		}()
+	}
+	wg.Wait()
+	report.Duration = time.Since(start)
+	return report
+}
+
+func (c *Coordinator) done(res Result) {
+	if c.opts.OnResult != nil {
+		c.opts.OnResult(res)
+	}
+}
+
+// apply changes a single device, retrying failed attempts.
+func (c *Coordinator) apply(ctx context.Context, d Device, cs Changeset) Result {
+	start := time.Now()
+	res := Result{Device: d.Name}
+	delay := c.opts.RetryDelay
+	for {
+		res.Attempts++
+		res.Err = attempt(ctx, d.Tree, cs)
+		if res.Err == nil {
+			break
+		}
+		d.Tree.Revert()
+		if res.Attempts > c.opts.Retries || !c.opts.Retryable(res.Err) {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			res.Err = errors.Join(res.Err, ctx.Err())
+			res.Duration = time.Since(start)
+			return res
+		}
+		delay *= 2
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+func attempt(ctx context.Context, t uci.Tree, cs Changeset) error {
+	if err := t.Batch(func(tx *uci.Tx) error { return cs(tx) }); err != nil {
+		return err
+	}
+	return t.CommitContext(ctx)
+}
+
+// retryable reports false for errors caused by the changes themselves.
+func retryable(err error) bool {
+	var (
+		notFound  uci.ErrSectionNotFound
+		exists    uci.ErrSectionExists
+		mismatch  uci.ErrSectionTypeMismatch
+		vetoed    uci.ErrVetoed
+		rejected  uci.ErrRejected
+		value     *uci.ValueError
+		forbidden *uci.ErrConfigNotAllowed
+	)
+	switch {
+	case errors.Is(err, uci.ErrReadOnly), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &notFound), errors.As(err, &exists), errors.As(err, &mismatch),
+		errors.As(err, &vetoed), errors.As(err, &rejected), errors.As(err, &value), errors.As(err, &forbidden):
+		return false
+	}
+	return true
+}
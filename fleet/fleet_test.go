@@ -0,0 +1,122 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+var errNoConnectivity = errors.New("no connectivity")
+
+func devices(n int) []Device {
+	devices := make([]Device, n)
+	for i := range devices {
+		store := uci.NewMemoryStore(map[string]string{"system": "\nconfig system\n\toption hostname 'ap'\n\n"})
+		devices[i] = Device{Name: fmt.Sprintf("ap%d", i), Tree: uci.NewStoreTree(store)}
+	}
+	return devices
+}
+
+func setNTP(tx *uci.Tx) error {
+	return tx.Set("system", "@system[0]", "ntp", "ntp.example.com")
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	devs := devices(5)
+	// ap1 loses connectivity after the first commit
+	var flaky atomic.Int32
+	devs[1].Tree.OnPostCommit(func(*uci.PackageEvent) error {
+		if flaky.Add(1) == 1 {
+			return errNoConnectivity
+		}
+		return nil
+	})
+	// ap3 refuses all changes
+	errLocked := errors.New("locked")
+	devs[3].Tree.OnPreCommit(func(*uci.PackageEvent) error { return errLocked })
+
+	var done atomic.Int32
+	c := New(Options{Retries: 2, RetryDelay: time.Millisecond, OnResult: func(Result) { done.Add(1) }})
+	report := c.Apply(context.Background(), devs, setNTP)
+	assert.Equal(int32(5), done.Load())
+	require.Len(report.Results, 5)
+	assert.Equal([]string{"ap0", "ap1", "ap2", "ap4"}, report.Succeeded())
+	assert.Equal(2, report.Results[1].Attempts)
+	assert.Equal(1, report.Results[3].Attempts, "vetoes aren't retried")
+	assert.ErrorIs(report.Err(), errLocked)
+	assert.ErrorContains(report.Err(), "ap3: ")
+	assert.Equal("4 of 5 devices changed, failed: ap3", report.String())
+
+	for i, d := range devs {
+		values, _ := d.Tree.Get("system", "@system[0]", "ntp")
+		if i == 3 {
+			assert.Empty(values)
+			assert.False(d.Tree.Stats()[0].Tainted, "reverted")
+		} else {
+			assert.Equal([]string{"ntp.example.com"}, values, d.Name)
+		}
+	}
+}
+
+func TestParallelism(t *testing.T) {
+	var running, peak atomic.Int32
+	c := New(Options{Parallelism: 2})
+	report := c.Apply(context.Background(), devices(6), func(tx *uci.Tx) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		return setNTP(tx)
+	})
+	assert.NoError(t, report.Err())
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestCanceled(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := New(Options{Parallelism: 1, Retries: 5, RetryDelay: time.Hour})
+	report := c.Apply(ctx, devices(3), func(tx *uci.Tx) error {
+		cancel()
+		return uci.ErrReadOnly
+	})
+	assert.Empty(report.Succeeded())
+	assert.Equal(1, report.Results[0].Attempts)
+	for _, r := range report.Results[1:] {
+		assert.Equal(0, r.Attempts)
+		assert.ErrorIs(r.Err, context.Canceled)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	restricted := uci.NewRestrictedTree(t.TempDir(), uci.RejectUnlisted, "system")
+	_, denied := restricted.Apply(uci.NewConfig("network"), uci.ApplyOptions{})
+	require.Error(t, denied)
+
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errNoConnectivity, true},
+		{fmt.Errorf("commit: %w", uci.ErrConcurrentModification), true},
+		{uci.ErrReadOnly, false},
+		{context.Canceled, false},
+		{uci.ErrSectionNotFound{Config: "system", Section: "x"}, false},
+		{denied, false},
+	} {
+		assert.Equal(t, tc.want, retryable(tc.err), "%v", tc.err)
+	}
+}
@@ -0,0 +1,437 @@
+// Package githistory records every commit of a tree in a local git
+// repository, so that the history of the configs can be inspected, and
+// any revision restored:
+//
+//	repo, err := githistory.Open("/etc/config.git", githistory.Options{})
+//	repo.Attach(tree)
+//	...
+//	revs, err := repo.Log("network")
+//	changes, err := repo.Restore(tree, revs[1].Hash, "network")
+//	err = tree.Commit()
+//
+// The repository is bare: the configs are stored from the bytes written
+// by the tree (see uci.PackageEvent), so any backend works, and the
+// config directory is left alone. Each commit of the tree becomes one
+// git commit, whose message lists the changes of each config.
+package githistory
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	uci "github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+var (
+	ErrNoHistory = errors.New("no history")
+	ErrNotFound  = errors.New("config not found in revision")
+)
+
+// Options configure a Repo.
+type Options struct {
+	// Author is the author and committer of the commits. It defaults
+	// to "go-uci <go-uci@localhost>".
+	Name, Email string
+
+	// Now returns the commit time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Repo is a git repository holding the history of configs.
+type Repo struct {
+	repo *git.Repository
+	opts Options
+
+	mu      sync.Mutex
+	pending map[string]*pending // by config name
+	order   []string
+	err     error
+}
+
+// pending is a config written by a commit in progress.
+type pending struct {
+	old, new []byte
+}
+
+// Open opens the bare git repository at dir, creating it if necessary.
+func Open(dir string, opts Options) (*Repo, error) {
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(dir, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("githistory: %s: %w", dir, err)
+	}
+	if opts.Name == "" {
+		opts.Name, opts.Email = "go-uci", "go-uci@localhost"
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Repo{repo: repo, opts: opts, pending: make(map[string]*pending)}, nil
+}
+
+// Attach registers hooks on t, which record each successful commit of t
+// (see Tree.OnPostCommit and Tree.OnCommitDone). Commits that fail are
+// not recorded; configs restored by a rolled back SafeCommit are
+// recorded with the next commit. Errors writing the repository don't
+// fail the commit; they are reported by Err.
+func (r *Repo) Attach(t uci.Tree) {
+	t.OnPostCommit(func(e *uci.PackageEvent) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if p, ok := r.pending[e.Config]; ok {
+			p.new = e.New
+			return nil
+		}
+		r.pending[e.Config] = &pending{old: e.Old, new: e.New}
+		r.order = append(r.order, e.Config)
+		return nil
+	})
+	t.OnCommitDone(func(e uci.CommitDoneEvent) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if e.Err != nil {
+			r.pending, r.order = make(map[string]*pending), nil
+			return
+		}
+		if len(r.order) == 0 {
+			return
+		}
+		sort.Strings(r.order) // configs are written in no particular order
+		files := make(map[string][]byte, len(r.order))
+		for _, name := range r.order {
+			files[name] = r.pending[name].new
+		}
+		_, err := r.commit(files, r.message())
+		r.err = err
+		r.pending, r.order = make(map[string]*pending), nil
+	})
+}
+
+// Err returns the error of recording the last commit of an attached
+// tree, if any.
+func (r *Repo) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// message describes the pending configs: a subject naming them, and
+// the changes of each config (see uci.Change) in the body.
+func (r *Repo) message() string {
+	var body strings.Builder
+	for _, name := range r.order {
+		p := r.pending[name]
+		changes, err := diff(name, p.old, p.new)
+		fmt.Fprintf(&body, "\n%s:\n", name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(&body, "\t(%v)\n", err)
+		case len(changes) == 0:
+			body.WriteString("\t(reformatted)\n")
+		}
+		for _, c := range changes {
+			fmt.Fprintf(&body, "\t%s\n", c)
+		}
+	}
+	return "Update " + strings.Join(r.order, ", ") + "\n" + body.String()
+}
+
+func diff(name string, old, new []byte) ([]uci.Change, error) {
+	from, err := ast.Parse(name, string(old))
+	if err != nil {
+		return nil, err
+	}
+	to, err := ast.Parse(name, string(new))
+	if err != nil {
+		return nil, err
+	}
+	return ast.Diff(from, to), nil
+}
+
+// Record commits the given configs (by name) with a message, e.g. to
+// import the current configs before attaching a tree. Configs not
+// given are kept as they are in the latest revision. It returns the
+// hash of the commit, or of the latest revision, if nothing changed.
+func (r *Repo) Record(message string, files map[string][]byte) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, err := r.commit(files, message)
+	return h.String(), err
+}
+
+func (r *Repo) commit(files map[string][]byte, message string) (plumbing.Hash, error) {
+	entries := make(map[string]object.TreeEntry)
+	var parents []plumbing.Hash
+	var parentTree plumbing.Hash
+	head, err := r.repo.Head()
+	switch {
+	case err == nil:
+		c, err := r.repo.CommitObject(head.Hash())
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		for _, e := range tree.Entries {
+			entries[e.Name] = e
+		}
+		parents, parentTree = []plumbing.Hash{c.Hash}, c.TreeHash
+	case !errors.Is(err, plumbing.ErrReferenceNotFound):
+		return plumbing.ZeroHash, err
+	}
+
+	for name, b := range files {
+		h, err := r.blob(b)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries[name] = object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: h}
+	}
+	tree := &object.Tree{}
+	for _, e := range entries {
+		tree.Entries = append(tree.Entries, e)
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool { return tree.Entries[i].Name < tree.Entries[j].Name })
+	treeHash, err := r.encode(tree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if len(parents) > 0 && treeHash == parentTree {
+		return parents[0], nil // nothing changed
+	}
+
+	sig := object.Signature{Name: r.opts.Name, Email: r.opts.Email, When: r.opts.Now()}
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	h, err := r.encode(commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// HEAD refers to the branch, which doesn't exist before the first
+	// commit
+	ref, err := r.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	branch := ref.Name()
+	if ref.Type() == plumbing.SymbolicReference {
+		branch = ref.Target()
+	}
+	return h, r.repo.Storer.SetReference(plumbing.NewHashReference(branch, h))
+}
+
+// encode stores a tree or commit.
+func (r *Repo) encode(o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+func (r *Repo) blob(b []byte) (plumbing.Hash, error) {
+	obj := r.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+// A Revision is a commit of the history.
+type Revision struct {
+	Hash    string
+	Time    time.Time
+	Author  string
+	Message string
+	Configs []string // changed configs
+}
+
+// Log returns the revisions, newest first. If config is not empty, only
+// revisions changing it are returned.
+func (r *Repo) Log(config string) ([]Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	head, err := r.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	iter, err := r.repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	return revs, iter.ForEach(func(c *object.Commit) error {
+		changed, err := changedFiles(c)
+		if err != nil {
+			return err
+		}
+		if config != "" && !slices.Contains(changed, config) {
+			return nil
+		}
+		revs = append(revs, Revision{
+			Hash:    c.Hash.String(),
+			Time:    c.Author.When,
+			Author:  c.Author.Name,
+			Message: c.Message,
+			Configs: changed,
+		})
+		return nil
+	})
+}
+
+// changedFiles returns the names of the files c changed compared to its
+// first parent.
+func changedFiles(c *object.Commit) ([]string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parent *object.Tree
+	if c.NumParents() > 0 {
+		p, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parent, err = p.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(parent, tree)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, ch := range changes {
+		name := ch.To.Name
+		if name == "" {
+			name = ch.From.Name
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load returns a config at a revision: a hash (which may be
+// abbreviated), or an expression like "HEAD~2" (see git rev-parse).
+func (r *Repo) Load(rev, config string) (*uci.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tree, err := r.tree(rev)
+	if err != nil {
+		return nil, err
+	}
+	return load(tree, rev, config)
+}
+
+// Configs returns the names of the configs at a revision.
+func (r *Repo) Configs(rev string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tree, err := r.tree(rev)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tree.Entries))
+	for _, e := range tree.Entries {
+		names = append(names, e.Name)
+	}
+	return names, nil
+}
+
+func (r *Repo) tree(rev string) (*object.Tree, error) {
+	h, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("%w: revision %s", ErrNoHistory, rev)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("revision %s: %w", rev, err)
+	}
+	c, err := r.repo.CommitObject(*h)
+	if err != nil {
+		return nil, fmt.Errorf("revision %s: %w", rev, err)
+	}
+	return c.Tree()
+}
+
+func load(tree *object.Tree, rev, config string) (*uci.Config, error) {
+	f, err := tree.File(config)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("%w: %s at %s", ErrNotFound, config, rev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return ast.Parse(config, s)
+}
+
+// Restore changes the given configs of t (all configs of the revision,
+// if none are given) to their contents at a revision (see Load), and
+// returns the changes made to each config (see Tree.Apply). The changes
+// are not committed; committing them records a new revision, if t is
+// attached.
+func (r *Repo) Restore(t uci.Tree, rev string, configs ...string) (map[string][]uci.Change, error) {
+	r.mu.Lock()
+	tree, err := r.tree(rev)
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		for _, e := range tree.Entries {
+			configs = append(configs, e.Name)
+		}
+	}
+	changes := make(map[string][]uci.Change, len(configs))
+	for _, name := range configs {
+		cfg, err := load(tree, rev, name)
+		if err != nil {
+			return changes, err
+		}
+		c, err := t.Apply(cfg, uci.ApplyOptions{Prune: true})
+		if err != nil {
+			return changes, fmt.Errorf("restore %s: %w", name, err)
+		}
+		changes[name] = c
+	}
+	return changes, nil
+}
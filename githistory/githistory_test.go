@@ -0,0 +1,105 @@
+package githistory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestHistory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "system"), []byte("\nconfig system\n\toption hostname 'OpenWrt'\n"), 0o644))
+	tree := uci.NewTree(dir)
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	repo, err := Open(filepath.Join(t.TempDir(), "config.git"), Options{Now: func() time.Time { return now }})
+	require.NoError(err)
+	revs, err := repo.Log("")
+	require.NoError(err)
+	assert.Empty(revs)
+	_, err = repo.Load("HEAD", "system")
+	assert.ErrorIs(err, ErrNoHistory)
+
+	// import the current state
+	b, err := os.ReadFile(filepath.Join(dir, "system"))
+	require.NoError(err)
+	initial, err := repo.Record("Import", map[string][]byte{"system": b})
+	require.NoError(err)
+	again, err := repo.Record("Import again", map[string][]byte{"system": b})
+	require.NoError(err)
+	assert.Equal(initial, again)
+
+	repo.Attach(tree)
+	require.True(tree.SetType("system", "@system[0]", "hostname", uci.TypeOption, "router"))
+	require.NoError(tree.AddSection("network", "lan", "interface"))
+	require.True(tree.SetType("network", "lan", "proto", uci.TypeOption, "static"))
+	require.NoError(tree.Commit())
+	require.NoError(repo.Err())
+
+	require.True(tree.SetType("network", "lan", "ipaddr", uci.TypeOption, "192.168.2.1"))
+	require.NoError(tree.Commit())
+
+	revs, err = repo.Log("")
+	require.NoError(err)
+	require.Len(revs, 3)
+	assert.Equal([]string{"network"}, revs[0].Configs)
+	assert.Equal([]string{"network", "system"}, revs[1].Configs)
+	assert.Equal([]string{"system"}, revs[2].Configs)
+	assert.Equal(initial, revs[2].Hash)
+	assert.Equal("go-uci", revs[0].Author)
+	assert.True(now.Equal(revs[0].Time))
+	assert.Equal("Update network\n\nnetwork:\n\tlan.ipaddr='192.168.2.1'\n", revs[0].Message)
+	assert.Contains(revs[1].Message, "Update network, system\n")
+	assert.Contains(revs[1].Message, "\t@system[0].hostname='router'\n")
+
+	revs, err = repo.Log("system")
+	require.NoError(err)
+	assert.Len(revs, 2)
+
+	cfg, err := repo.Load("HEAD~1", "network")
+	require.NoError(err)
+	assert.Nil(cfg.Get("lan").Get("ipaddr"))
+	_, err = repo.Load(initial[:8], "network")
+	assert.ErrorIs(err, ErrNotFound)
+	names, err := repo.Configs("HEAD")
+	require.NoError(err)
+	assert.Equal([]string{"network", "system"}, names)
+
+	// restore the initial hostname
+	changes, err := repo.Restore(tree, initial)
+	require.NoError(err)
+	require.Len(changes["system"], 1)
+	require.NoError(tree.Commit())
+	hostname, _ := tree.GetLast("system", "@system[0]", "hostname")
+	assert.Equal("OpenWrt", hostname)
+	revs, err = repo.Log("")
+	require.NoError(err)
+	assert.Len(revs, 4)
+
+	_, err = repo.Restore(tree, "0123456789abcdef")
+	assert.Error(err)
+}
+
+func TestFailedCommit(t *testing.T) {
+	dir := t.TempDir()
+	tree := uci.NewTree(dir)
+	repo, err := Open(filepath.Join(t.TempDir(), "config.git"), Options{})
+	require.NoError(t, err)
+	repo.Attach(tree)
+	tree.OnPostCommit(func(*uci.PackageEvent) error { return errors.New("reload failed") })
+
+	require.NoError(t, tree.AddSection("network", "lan", "interface"))
+	require.Error(t, tree.Commit())
+	revs, err := repo.Log("")
+	require.NoError(t, err)
+	assert.Empty(t, revs)
+}
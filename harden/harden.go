@@ -0,0 +1,140 @@
+// Package harden provides presets applying recommended security settings
+// to the dropbear (SSH) and uhttpd (LuCI web server) configs. Presets
+// are config fragments, which are merged into a tree by Apply, reporting
+// what changed:
+//
+//	results, err := harden.Apply(tree, false, harden.Recommended("lan", netip.MustParseAddr("192.168.1.1"))...)
+//	for _, r := range results {
+//		for _, c := range r.Changes {
+//			fmt.Println(r.Config, c)
+//		}
+//	}
+//
+// Only the options named by a preset are changed; other options of the
+// sections are kept. Unnamed sections are matched by their position,
+// i.e. dropbear presets apply to the first dropbear instance.
+package harden
+
+import (
+	"net/netip"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// uhttpdSection is the name of the uhttpd instance serving LuCI.
+const uhttpdSection = "main"
+
+// DisablePasswordAuth makes dropbear accept public keys only, for all
+// users including root. Add keys to /etc/dropbear/authorized_keys
+// first.
+func DisablePasswordAuth() *uci.Config {
+	cfg := uci.NewConfig("dropbear")
+	s := cfg.Add(uci.NewSection("dropbear", ""))
+	s.SetOption("PasswordAuth", "off")
+	s.SetOption("RootPasswordAuth", "off")
+	return cfg
+}
+
+// DropbearInterface makes dropbear listen on the given logical
+// interface only, e.g. "lan".
+func DropbearInterface(iface string) *uci.Config {
+	cfg := uci.NewConfig("dropbear")
+	cfg.Add(uci.NewSection("dropbear", "")).SetOption("Interface", iface)
+	return cfg
+}
+
+// RedirectHTTPS makes uhttpd redirect HTTP requests to HTTPS.
+func RedirectHTTPS() *uci.Config {
+	cfg := uci.NewConfig("uhttpd")
+	cfg.Add(uci.NewSection("uhttpd", uhttpdSection)).SetOption("redirect_https", "1")
+	return cfg
+}
+
+// UhttpdListen makes uhttpd listen on the given addresses only (e.g.
+// the LAN addresses, instead of all addresses), on ports 80 and 443,
+// and reject requests from public to private addresses (DNS rebinding).
+func UhttpdListen(addrs ...netip.Addr) *uci.Config {
+	cfg := uci.NewConfig("uhttpd")
+	s := cfg.Add(uci.NewSection("uhttpd", uhttpdSection))
+	var http, https []string
+	for _, addr := range addrs {
+		http = append(http, netip.AddrPortFrom(addr, 80).String())
+		https = append(https, netip.AddrPortFrom(addr, 443).String())
+	}
+	if len(addrs) > 0 {
+		s.SetList("listen_http", http...)
+		s.SetList("listen_https", https...)
+	}
+	s.SetOption("rfc1918_filter", "1")
+	return cfg
+}
+
+// Recommended returns all presets: dropbear with public key
+// authentication only, and dropbear and uhttpd reachable from the given
+// LAN interface and its addresses only, with HTTPS.
+func Recommended(lan string, addrs ...netip.Addr) []*uci.Config {
+	return []*uci.Config{
+		DisablePasswordAuth(),
+		DropbearInterface(lan),
+		RedirectHTTPS(),
+		UhttpdListen(addrs...),
+	}
+}
+
+// Merge merges fragments of the same config, and returns one fragment
+// per config, in the order of their first fragment. Sections are
+// matched like by uci.Config.MergeConfig, but options set by several
+// fragments get the values of the last one. The fragments are not
+// changed.
+func Merge(fragments ...*uci.Config) []*uci.Config {
+	var merged []*uci.Config
+	index := make(map[string]*uci.Config)
+	for _, frag := range fragments {
+		cfg, ok := index[frag.Name]
+		if !ok {
+			cfg = uci.NewConfig(frag.Name)
+			index[frag.Name] = cfg
+			merged = append(merged, cfg)
+		}
+		for _, s := range frag.Clone().Sections {
+			sec := cfg.Get(frag.SectionName(s))
+			if sec == nil {
+				cfg.Add(s)
+				continue
+			}
+			for _, opt := range s.Options {
+				if prev := sec.Get(opt.Name); prev != nil {
+					*prev = *opt
+				} else {
+					sec.Add(opt)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// A Result lists the changes a preset made to a config.
+type Result struct {
+	Config  string
+	Changes []uci.Change
+}
+
+// Apply merges the fragments (see Merge), and applies them to the tree,
+// keeping the options they don't name. With dryRun, it only reports
+// the changes, which works on read-only trees, too. Configs which
+// didn't change are left out of the results. The changes are not
+// committed.
+func Apply(t uci.Tree, dryRun bool, fragments ...*uci.Config) ([]Result, error) {
+	var results []Result
+	for _, cfg := range Merge(fragments...) {
+		changes, err := t.Apply(cfg, uci.ApplyOptions{KeepOptions: true, DryRun: dryRun})
+		if err != nil {
+			return results, err
+		}
+		if len(changes) > 0 {
+			results = append(results, Result{Config: cfg.Name, Changes: changes})
+		}
+	}
+	return results, nil
+}
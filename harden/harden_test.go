@@ -0,0 +1,94 @@
+package harden
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func testTree(t *testing.T, opts ...uci.TreeOption) uci.Tree {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dropbear"), []byte(`
+config dropbear
+	option PasswordAuth 'on'
+	option RootPasswordAuth 'on'
+	option Port '22'
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "uhttpd"), []byte(`
+config uhttpd 'main'
+	list listen_http '0.0.0.0:80'
+	list listen_http '[::]:80'
+	list listen_https '0.0.0.0:443'
+	list listen_https '[::]:443'
+	option redirect_https '0'
+	option home '/www'
+`), 0o644))
+	return uci.NewTree(dir, opts...)
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tree := testTree(t)
+
+	lan := Recommended("lan", netip.MustParseAddr("192.168.1.1"), netip.MustParseAddr("fd00::1"))
+	results, err := Apply(tree, false, lan...)
+	require.NoError(err)
+	require.Len(results, 2)
+	assert.Equal("dropbear", results[0].Config)
+	assert.Len(results[0].Changes, 3)
+	assert.Equal("uhttpd", results[1].Config)
+	assert.Len(results[1].Changes, 4)
+
+	auth, _ := tree.GetLast("dropbear", "@dropbear[0]", "PasswordAuth")
+	assert.Equal("off", auth)
+	iface, _ := tree.GetLast("dropbear", "@dropbear[0]", "Interface")
+	assert.Equal("lan", iface)
+	port, _ := tree.GetLast("dropbear", "@dropbear[0]", "Port")
+	assert.Equal("22", port)
+	listen, _ := tree.Get("uhttpd", "main", "listen_https")
+	assert.Equal([]string{"192.168.1.1:443", "[fd00::1]:443"}, listen)
+	home, _ := tree.GetLast("uhttpd", "main", "home")
+	assert.Equal("/www", home)
+
+	// idempotent
+	results, err = Apply(tree, false, lan...)
+	require.NoError(err)
+	assert.Empty(results)
+}
+
+func TestApplyDryRun(t *testing.T) {
+	tree := testTree(t, uci.WithReadOnly())
+	results, err := Apply(tree, true, RedirectHTTPS())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	c := results[0].Changes[0]
+	assert.Equal(t, "main", c.Section)
+	assert.Equal(t, "redirect_https", c.Option)
+	assert.Equal(t, []string{"0"}, c.Old)
+	assert.Equal(t, []string{"1"}, c.New)
+	redirect, _ := tree.GetLast("uhttpd", "main", "redirect_https")
+	assert.Equal(t, "0", redirect)
+
+	_, err = Apply(tree, false, RedirectHTTPS())
+	assert.ErrorIs(t, err, uci.ErrReadOnly)
+}
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	first := DropbearInterface("lan")
+	merged := Merge(first, DisablePasswordAuth(), DropbearInterface("mgmt"), RedirectHTTPS())
+	assert.Len(merged, 2)
+	assert.Equal("dropbear", merged[0].Name)
+	sec := merged[0].Get("@dropbear[0]")
+	assert.Equal("mgmt", sec.LastValue("Interface"))
+	assert.Equal("off", sec.LastValue("PasswordAuth"))
+	assert.Len(merged[0].Sections, 1)
+	assert.Equal("lan", first.Get("@dropbear[0]").LastValue("Interface"))
+}
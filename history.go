@@ -0,0 +1,227 @@
+package uci
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// History is an undo log of the changes made to a tree (see
+// Tree.SetHistory). Each entry keeps a copy of the configs changed by a
+// single method call, as they were before (or, for redo, after) the
+// call.
+type History struct {
+	limit      int
+	undo, redo []historyEntry
+}
+
+// historyEntry maps config names to their state. A nil state means the
+// config was not loaded (e.g. before AddSection created it).
+type historyEntry map[string]*Config
+
+// NewHistory returns an empty history, keeping the given number of
+// undo steps, or all, if limit is 0.
+func NewHistory(limit int) *History {
+	return &History{limit: limit}
+}
+
+// Len returns the number of changes, which can be undone and redone.
+func (h *History) Len() (undo, redo int) {
+	return len(h.undo), len(h.redo)
+}
+
+// push records a new change, which clears the redo steps.
+func (h *History) push(e historyEntry) {
+	h.undo = append(h.undo, e)
+	if h.limit > 0 && len(h.undo) > h.limit {
+		h.undo = h.undo[len(h.undo)-h.limit:]
+	}
+	h.redo = nil
+}
+
+type historyJSON struct {
+	Limit int                           `json:"limit"`
+	Undo  []map[string]*json.RawMessage `json:"undo"`
+	Redo  []map[string]*json.RawMessage `json:"redo"`
+}
+
+// WriteTo writes the history as JSON document, with the configs in uci
+// syntax, so that it can be persisted across sessions.
+func (h *History) WriteTo(w io.Writer) (int64, error) {
+	doc := historyJSON{Limit: h.limit}
+	var err error
+	if doc.Undo, err = marshalEntries(h.undo); err != nil {
+		return 0, err
+	}
+	if doc.Redo, err = marshalEntries(h.redo); err != nil {
+		return 0, err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func marshalEntries(entries []historyEntry) ([]map[string]*json.RawMessage, error) {
+	docs := make([]map[string]*json.RawMessage, len(entries))
+	for i, e := range entries {
+		docs[i] = make(map[string]*json.RawMessage, len(e))
+		for name, cfg := range e {
+			if cfg == nil {
+				docs[i][name] = nil
+				continue
+			}
+			var buf bytes.Buffer
+			if _, err := cfg.WriteTo(&buf); err != nil {
+				return nil, err
+			}
+			raw, err := json.Marshal(buf.String())
+			if err != nil {
+				return nil, err
+			}
+			docs[i][name] = (*json.RawMessage)(&raw)
+		}
+	}
+	return docs, nil
+}
+
+// ReadHistory reads a history written by History.WriteTo.
+func ReadHistory(r io.Reader) (*History, error) {
+	var doc historyJSON
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("reading history failed: %w", err)
+	}
+	h := &History{limit: doc.Limit}
+	var err error
+	if h.undo, err = unmarshalEntries(doc.Undo); err != nil {
+		return nil, err
+	}
+	if h.redo, err = unmarshalEntries(doc.Redo); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func unmarshalEntries(docs []map[string]*json.RawMessage) ([]historyEntry, error) {
+	entries := make([]historyEntry, len(docs))
+	for i, doc := range docs {
+		entries[i] = make(historyEntry, len(doc))
+		for name, raw := range doc {
+			if raw == nil {
+				entries[i][name] = nil
+				continue
+			}
+			var text string
+			if err := json.Unmarshal(*raw, &text); err != nil {
+				return nil, fmt.Errorf("reading history of %s failed: %w", name, err)
+			}
+			cfg, err := ast.Parse(name, text)
+			if err != nil {
+				return nil, fmt.Errorf("reading history of %s failed: %w", name, err)
+			}
+			entries[i][name] = cfg
+		}
+	}
+	return entries, nil
+}
+
+func (t *tree) SetHistory(h *History) {
+	t.Lock()
+	t.history = h
+	t.Unlock()
+}
+
+func (t *tree) Undo(n int) int {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.history == nil || t.readOnly {
+		return 0
+	}
+	i := 0
+	for ; i < n && len(t.history.undo) > 0; i++ {
+		e := t.history.undo[len(t.history.undo)-1]
+		t.history.undo = t.history.undo[:len(t.history.undo)-1]
+		t.history.redo = append(t.history.redo, t.restoreState(e))
+	}
+	return i
+}
+
+func (t *tree) Redo(n int) int {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.history == nil || t.readOnly {
+		return 0
+	}
+	i := 0
+	for ; i < n && len(t.history.redo) > 0; i++ {
+		e := t.history.redo[len(t.history.redo)-1]
+		t.history.redo = t.history.redo[:len(t.history.redo)-1]
+		t.history.undo = append(t.history.undo, t.restoreState(e))
+	}
+	return i
+}
+
+// snapshot returns copies of the configs, to be recorded after changing
+// them. It returns nil, if the tree has no history. Its call must be
+// guarded by locking the tree's mutex.
+func (t *tree) snapshot(configs ...string) historyEntry {
+	if t.history == nil {
+		return nil
+	}
+	e := make(historyEntry, len(configs))
+	for _, name := range configs {
+		if cfg, ok := t.configs[name]; ok {
+			e[name] = cfg.Clone()
+		} else {
+			e[name] = nil
+		}
+	}
+	return e
+}
+
+// record adds a snapshot to the history. Its call must be guarded by
+// locking the tree's mutex.
+func (t *tree) record(e historyEntry) {
+	if e != nil && t.history != nil {
+		t.history.push(e)
+	}
+}
+
+// restoreState changes the configs to the state of e, and returns their
+// current state. Loaded configs are changed in place, so that pointers
+// returned by EnsureConfigLoaded stay valid. Its call must be guarded
+// by locking the tree's mutex.
+func (t *tree) restoreState(e historyEntry) historyEntry {
+	current := make(historyEntry, len(e))
+	for name, state := range e {
+		cfg := t.configs[name]
+		switch {
+		case state == nil:
+			current[name] = cfg
+			delete(t.configs, name)
+			delete(t.prov, name)
+			continue
+		case cfg == nil:
+			current[name] = nil
+			if t.configs == nil {
+				t.configs = make(map[string]*Config)
+			}
+			t.configs[name] = state
+			cfg = state
+		default:
+			current[name] = cfg.Clone()
+			cfg.Sections = state.Sections
+			cfg.Reindex()
+		}
+		// the state may differ from the committed one
+		cfg.SetTainted()
+	}
+	return current
+}
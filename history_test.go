@@ -0,0 +1,111 @@
+package uci
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndoRedo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{"network": tcSafeNetwork}))
+	assert.Equal(0, r.Undo(1)) // no history
+
+	h := NewHistory(0)
+	r.SetHistory(h)
+	cfg, ok := r.EnsureConfigLoaded("network")
+	require.True(ok)
+
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	require.NoError(r.AddSection("network", "wan", "interface"))
+	require.NoError(r.AddSection("network", "wan", "interface")) // not a change
+	r.DelSection("network", "missing")                           // neither
+	assert.True(r.Set("network", "wan", "proto", "dhcp"))
+	r.Del("network", "lan", "ipaddr")
+	require.NoError(r.AddSection("system", "main", "system")) // creates the config
+	undo, redo := h.Len()
+	assert.Equal(5, undo)
+	assert.Equal(0, redo)
+
+	assert.Equal(2, r.Undo(2))
+	assert.NotContains(r.(*tree).configs, "system")
+	ipaddr, _ := r.GetLast("network", "lan", "ipaddr")
+	assert.Equal("10.0.0.1", ipaddr)
+
+	assert.Equal(3, r.Undo(5))
+	assert.Same(cfg, r.(*tree).configs["network"]) // changed in place
+	assert.Nil(cfg.Get("wan"))
+	ipaddr, _ = r.GetLast("network", "lan", "ipaddr")
+	assert.Equal("192.168.1.1", ipaddr)
+	assert.True(cfg.Tainted())
+
+	assert.Equal(4, r.Redo(4))
+	proto, _ := r.GetLast("network", "wan", "proto")
+	assert.Equal("dhcp", proto)
+	assert.Nil(cfg.Get("lan").Get("ipaddr"))
+
+	// new changes discard the undone ones
+	assert.True(r.Set("network", "lan", "proto", "static"))
+	undo, redo = h.Len()
+	assert.Equal(5, undo)
+	assert.Equal(0, redo)
+
+	r.Revert()
+	undo, _ = h.Len()
+	assert.Equal(0, undo)
+}
+
+func TestHistoryLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{"network": tcSafeNetwork}))
+	h := NewHistory(2)
+	r.SetHistory(h)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		assert.True(r.Set("network", "lan", "ipaddr", ip))
+	}
+	assert.Equal(2, r.Undo(3))
+	ipaddr, _ := r.GetLast("network", "lan", "ipaddr")
+	assert.Equal("10.0.0.1", ipaddr)
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{"network": tcSafeNetwork}))
+	r.SetHistory(NewHistory(10))
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	require.NoError(r.AddSection("guest", "guest", "interface"))
+	assert.Equal(1, r.Undo(1))
+
+	var buf bytes.Buffer
+	_, err := r.(*tree).history.WriteTo(&buf)
+	require.NoError(err)
+	h, err := ReadHistory(&buf)
+	require.NoError(err)
+	undo, redo := h.Len()
+	assert.Equal(1, undo)
+	assert.Equal(1, redo)
+
+	// a new session continues with the persisted history
+	r = NewStoreTree(NewMemoryStore(map[string]string{"network": tcSafeNetwork}))
+	require.NoError(r.LoadConfig("network", false))
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	r.SetHistory(h)
+	assert.Equal(1, r.Redo(1))
+	guest, ok := r.EnsureConfigLoaded("guest")
+	require.True(ok)
+	assert.NotNil(guest.Get("guest"))
+	assert.Equal(2, r.Undo(2))
+	ipaddr, _ := r.GetLast("network", "lan", "ipaddr")
+	assert.Equal("192.168.1.1", ipaddr)
+
+	_, err = ReadHistory(strings.NewReader(`{"undo": [{"network": "config"}]}`))
+	assert.Error(err)
+}
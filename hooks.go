@@ -0,0 +1,344 @@
+package uci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// A SetEvent describes a call to Tree.Set or Tree.SetType. Hooks may
+// rewrite Values (and Type, which only applies to new options; existing
+// options keep their type).
+type SetEvent struct {
+	Config  string
+	Section string // as given to Set, i.e. possibly a "@type[index]" selector
+	Option  string
+	Type    OptionType
+	Old     []string // nil, if the option does not exist yet
+	Values  []string
+}
+
+// A DeleteEvent describes a call to Tree.Del (Option is set) or
+// Tree.DelSection (Option is empty).
+type DeleteEvent struct {
+	Config  string
+	Section string
+	Option  string
+}
+
+// A CommitEvent describes a call to Tree.Commit. Configs are the
+// configs about to be written; hooks may modify them.
+type CommitEvent struct {
+	Configs []*Config
+
+	ctx     context.Context
+	backend backend
+}
+
+// Changes returns the changes of the given config, compared to the
+// version currently stored in the backend.
+func (e *CommitEvent) Changes(cfg *Config) ([]ast.Change, error) {
+	orig, _, err := e.backend.load(e.ctx, cfg.Name)
+	if errors.Is(err, os.ErrNotExist) {
+		orig, err = ast.NewConfig(cfg.Name), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ast.Diff(orig, cfg), nil
+}
+
+// A PackageEvent describes the writing of a single config by Commit.
+// Old are the bytes replaced (nil, if the config is new), New are the
+// bytes written; see CommitPreview.
+type PackageEvent struct {
+	Config string
+	Old    []byte
+	New    []byte
+
+	ctx context.Context
+}
+
+// Context returns the context of the commit.
+func (e *PackageEvent) Context() context.Context {
+	return e.ctx
+}
+
+// ExecHook returns a package hook running a program, e.g.
+//
+//	tree.OnPostCommit(uci.ExecHook("/etc/init.d/network", "reload"))
+//
+// The name of the config is passed in the UCI_CONFIG environment
+// variable. The hook fails, if the program does; the error includes the
+// program's output.
+func ExecHook(name string, args ...string) PackageHook {
+	return func(e *PackageEvent) error {
+		cmd := exec.CommandContext(e.Context(), name, args...)
+		cmd.Env = append(os.Environ(), "UCI_CONFIG="+e.Config)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+}
+
+// A LoadEvent describes the reading of a config from the backend.
+// Duration includes parsing.
+type LoadEvent struct {
+	Config   string
+	Duration time.Duration
+	Err      error
+}
+
+// A CommitDoneEvent describes the outcome of a commit. Configs are the
+// names of the changed configs, which were to be written.
+type CommitDoneEvent struct {
+	Configs  []string
+	Duration time.Duration
+	Err      error
+}
+
+// Hooks are called by the tree with its lock held, and hence must not
+// call the tree's methods. A hook returning an error vetoes the change;
+// later hooks are not called.
+type (
+	SetHook    func(e *SetEvent) error
+	DeleteHook func(e DeleteEvent) error
+	CommitHook func(e *CommitEvent) error
+
+	PackageHook func(e *PackageEvent) error
+
+	// Observing hooks can't veto anything.
+	LoadHook       func(e LoadEvent)
+	CommitDoneHook func(e CommitDoneEvent)
+)
+
+type hooks struct {
+	set        []SetHook
+	del        []DeleteHook
+	commit     []CommitHook
+	preCommit  []PackageHook
+	postCommit []PackageHook
+	load       []LoadHook
+	commitDone []CommitDoneHook
+}
+
+func (t *tree) OnSet(h SetHook) {
+	t.Lock()
+	t.hooks.set = append(t.hooks.set, h)
+	t.Unlock()
+}
+
+func (t *tree) OnDelete(h DeleteHook) {
+	t.Lock()
+	t.hooks.del = append(t.hooks.del, h)
+	t.Unlock()
+}
+
+func (t *tree) OnCommit(h CommitHook) {
+	t.Lock()
+	t.hooks.commit = append(t.hooks.commit, h)
+	t.Unlock()
+}
+
+func (t *tree) OnPreCommit(h PackageHook) {
+	t.Lock()
+	t.hooks.preCommit = append(t.hooks.preCommit, h)
+	t.Unlock()
+}
+
+func (t *tree) OnPostCommit(h PackageHook) {
+	t.Lock()
+	t.hooks.postCommit = append(t.hooks.postCommit, h)
+	t.Unlock()
+}
+
+func (t *tree) OnLoad(h LoadHook) {
+	t.Lock()
+	t.hooks.load = append(t.hooks.load, h)
+	t.Unlock()
+}
+
+func (t *tree) OnCommitDone(h CommitDoneHook) {
+	t.Lock()
+	t.hooks.commitDone = append(t.hooks.commitDone, h)
+	t.Unlock()
+}
+
+// runSetHooks calls the set hooks, and returns ErrRejected if the
+// change may not be made. Its call must be guarded by locking the
+// tree's mutex.
+func (t *tree) runSetHooks(e *SetEvent) error {
+	for _, h := range t.hooks.set {
+		if err := h(e); err != nil {
+			t.logger().Info("change rejected by hook", "config", e.Config, "section", e.Section,
+				"option", e.Option, "err", err)
+			return ErrRejected{err}
+		}
+	}
+	return nil
+}
+
+// runDeleteHooks calls the delete hooks, and returns ErrRejected if
+// the deletion may not be made. Its call must be guarded by locking
+// the tree's mutex.
+func (t *tree) runDeleteHooks(e DeleteEvent) error {
+	for _, h := range t.hooks.del {
+		if err := h(e); err != nil {
+			t.logger().Info("deletion rejected by hook", "config", e.Config, "section", e.Section,
+				"option", e.Option, "err", err)
+			return ErrRejected{err}
+		}
+	}
+	return nil
+}
+
+// runCommitHooks calls the commit hooks. Its call must be guarded by
+// locking the tree's mutex.
+func (t *tree) runCommitHooks(ctx context.Context, configs []*Config) error {
+	if len(t.hooks.commit) == 0 {
+		return nil
+	}
+	e := &CommitEvent{Configs: configs, ctx: ctx, backend: t.backend}
+	for _, h := range t.hooks.commit {
+		if err := h(e); err != nil {
+			t.logger().WarnContext(ctx, "commit vetoed by hook", "err", err)
+			return ErrVetoed{err}
+		}
+	}
+	return nil
+}
+
+// save writes a config, running the pre- and post-commit hooks. If a
+// post-commit hook fails, the previous contents of the config and its
+// fragments are restored, and the config is dropped from the tree. Its call must be guarded by locking
+// the tree's mutex.
+func (t *tree) save(ctx context.Context, config *Config) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	if err := t.checkConcurrent(ctx, config.Name); err != nil {
+		return err
+	}
+	var (
+		e       *PackageEvent
+		oldFrag map[string][]byte
+	)
+	if len(t.hooks.preCommit) > 0 || len(t.hooks.postCommit) > 0 {
+		old, body, err := t.backend.preview(ctx, config)
+		if err != nil {
+			return err
+		}
+		e = &PackageEvent{Config: config.Name, Old: old, New: body, ctx: ctx}
+		for _, h := range t.hooks.preCommit {
+			if err := h(e); err != nil {
+				t.logger().WarnContext(ctx, "commit vetoed by pre-commit hook", "config", config.Name, "err", err)
+				return ErrVetoed{err}
+			}
+		}
+		if oldFrag, err = t.previewFragments(ctx, config); err != nil {
+			return err
+		}
+	}
+
+	prov, err := t.backend.save(ctx, config)
+	if err != nil {
+		t.logger().ErrorContext(ctx, "writing config failed", "config", config.Name, "err", err)
+		return err
+	}
+	t.setProvenances(config.Name, prov)
+	t.setLoaded(config.Name, config)
+	for _, frag := range config.Fragments() {
+		if _, err := t.backend.save(ctx, frag); err != nil {
+			t.logger().ErrorContext(ctx, "writing fragment failed", "config", config.Name, "fragment", frag.Name, "err", err)
+			return err
+		}
+	}
+	config.ResetTainted()
+	t.logger().InfoContext(ctx, "config committed", "config", config.Name)
+	if e == nil {
+		return nil
+	}
+
+	for _, h := range t.hooks.postCommit {
+		if err := h(e); err != nil {
+			// the hook may have failed because ctx is done
+			t.logger().ErrorContext(ctx, "post-commit hook failed, restoring config", "config", config.Name, "err", err)
+			rctx := context.WithoutCancel(ctx)
+			rerr := errors.Join(t.backend.restore(rctx, config.Name, e.Old), t.restoreFragments(rctx, oldFrag))
+			if rerr != nil {
+				t.logger().ErrorContext(ctx, "restoring config failed", "config", config.Name, "err", rerr)
+				err = errors.Join(err, rerr)
+			}
+			delete(t.configs, config.Name)
+			delete(t.prov, config.Name)
+			delete(t.loaded, config.Name)
+			return ErrRolledBack{Config: config.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// previewFragments returns the contents of the fragments of a config
+// (see SetIncludes), which save is about to replace, with nil for
+// missing ones. Its call must be guarded by locking the tree's mutex.
+func (t *tree) previewFragments(ctx context.Context, config *Config) (map[string][]byte, error) {
+	frags := config.Fragments()
+	if len(frags) == 0 {
+		return nil, nil
+	}
+	old := make(map[string][]byte, len(frags))
+	for _, frag := range frags {
+		body, _, err := t.backend.preview(ctx, frag)
+		if err != nil {
+			return nil, err
+		}
+		old[frag.Name] = body
+	}
+	return old, nil
+}
+
+// restoreFragments writes the contents returned by previewFragments
+// back, removing fragments which did not exist. Its call must be
+// guarded by locking the tree's mutex.
+func (t *tree) restoreFragments(ctx context.Context, old map[string][]byte) error {
+	var errs []error
+	for name, body := range old {
+		if err := t.backend.restore(ctx, name, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runLoadHooks calls the load hooks. Its call must be guarded by locking
+// the tree's mutex.
+func (t *tree) runLoadHooks(e LoadEvent) {
+	for _, h := range t.hooks.load {
+		h(e)
+	}
+}
+
+// runCommitDoneHooks calls the commit done hooks for a commit of the
+// given configs, which started at start. Its call must be guarded by
+// locking the tree's mutex.
+func (t *tree) runCommitDoneHooks(configs []*Config, start time.Time, err error) {
+	if len(t.hooks.commitDone) == 0 {
+		return
+	}
+	e := CommitDoneEvent{Duration: time.Since(start), Err: err}
+	for _, config := range configs {
+		e.Configs = append(e.Configs, config.Name)
+	}
+	sort.Strings(e.Configs)
+	for _, h := range t.hooks.commitDone {
+		h(e)
+	}
+}
@@ -0,0 +1,198 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errFirewallOff = errors.New("the firewall must stay enabled")
+
+func TestHooks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"firewall": `
+config defaults
+	option input 'REJECT'
+
+config zone 'lan'
+	option name 'lan'
+	option input 'ACCEPT'
+`,
+	})
+	r := NewStoreTree(store)
+
+	var log []string
+	r.OnSet(func(e *SetEvent) error {
+		if e.Config == "firewall" && e.Option == "input" && e.Values[0] == "ACCEPT" && e.Section == "@defaults[0]" {
+			return errFirewallOff
+		}
+		if e.Option == "name" {
+			e.Values = []string{e.Values[0] + "_zone"} // rewrite
+		}
+		log = append(log, "set "+e.Section+"."+e.Option)
+		return nil
+	})
+	r.OnDelete(func(e DeleteEvent) error {
+		if e.Section == "@defaults[0]" && e.Option == "" {
+			return errFirewallOff
+		}
+		log = append(log, "del "+e.Section+"."+e.Option)
+		return nil
+	})
+
+	assert.False(r.Set("firewall", "@defaults[0]", "input", "ACCEPT"))
+	assert.True(r.Set("firewall", "@defaults[0]", "input", "DROP"))
+	assert.True(r.Set("firewall", "lan", "name", "guest"))
+	r.DelSection("firewall", "@defaults[0]")
+	r.Del("firewall", "lan", "input")
+	r.Del("firewall", "lan", "missing") // no hook for missing options
+
+	input, _ := r.GetLast("firewall", "@defaults[0]", "input")
+	assert.Equal("DROP", input)
+	name, _ := r.GetLast("firewall", "lan", "name")
+	assert.Equal("guest_zone", name)
+	assert.Equal([]string{
+		"set @defaults[0].input",
+		"set lan.name",
+		"del lan.input",
+	}, log)
+
+	// commit hooks see the changes, and may veto them
+	var changes []string
+	r.OnCommit(func(e *CommitEvent) error {
+		require.Len(e.Configs, 1)
+		diff, err := e.Changes(e.Configs[0])
+		if err != nil {
+			return err
+		}
+		for _, ch := range diff {
+			changes = append(changes, ch.String())
+		}
+		if e.Configs[0].Get("lan").Get("input") == nil {
+			return errFirewallOff
+		}
+		return nil
+	})
+	err := r.Commit()
+	assert.True(errors.Is(err, errFirewallOff))
+	assert.True(errors.As(err, new(ErrVetoed)))
+	assert.Equal([]string{
+		"@defaults[0].input='DROP'",
+		"lan.name='guest_zone'",
+		"-lan.input",
+	}, changes)
+
+	body, err := store.Read(context.Background(), "firewall")
+	require.NoError(err)
+	assert.Contains(string(body), "REJECT") // nothing written
+
+	assert.True(r.Set("firewall", "lan", "input", "REJECT"))
+	require.NoError(r.Commit())
+}
+
+var errNoConnectivity = errors.New("no connectivity")
+
+func TestPackageHooks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const input = "\nconfig interface 'lan'\n\toption proto 'static'\n\n"
+	store := NewMemoryStore(map[string]string{"network": input, "system": "\nconfig system\n\n"})
+	r := NewStoreTree(store)
+
+	var log []string
+	r.OnPreCommit(func(e *PackageEvent) error {
+		log = append(log, "pre "+e.Config)
+		if e.Config == "system" {
+			return errFirewallOff
+		}
+		return nil
+	})
+	r.OnPostCommit(func(e *PackageEvent) error {
+		log = append(log, "post "+e.Config)
+		assert.Equal(input, string(e.Old))
+		assert.Equal(string(e.New), string(store.files["network"]))
+		if strings.Contains(string(e.New), "dhcp") {
+			return errNoConnectivity
+		}
+		return nil
+	})
+
+	// post-commit failures restore the file, and revert the changes
+	assert.True(r.Set("network", "lan", "proto", "dhcp"))
+	err := r.Commit()
+	assert.True(errors.Is(err, errNoConnectivity))
+	var rolledBack ErrRolledBack
+	require.True(errors.As(err, &rolledBack))
+	assert.Equal("network", rolledBack.Config)
+	assert.Equal(input, string(store.files["network"]))
+	proto, _ := r.GetLast("network", "lan", "proto")
+	assert.Equal("static", proto)
+
+	assert.True(r.Set("network", "lan", "proto", "none"))
+	require.NoError(r.Commit())
+	assert.Contains(string(store.files["network"]), "none")
+
+	// pre-commit hooks veto
+	assert.True(r.Set("system", "@system[0]", "hostname", "router"))
+	assert.True(errors.As(r.Commit(), new(ErrVetoed)))
+	assert.Equal("\nconfig system\n\n", string(store.files["system"]))
+
+	assert.Equal([]string{"pre network", "post network", "pre network", "post network", "pre system"}, log)
+}
+
+func TestExecHook(t *testing.T) {
+	assert := assert.New(t)
+
+	e := &PackageEvent{Config: "network", ctx: context.Background()}
+	assert.NoError(ExecHook("sh", "-c", `test "$UCI_CONFIG" = network`)(e))
+	err := ExecHook("sh", "-c", "echo unreachable; exit 1")(e)
+	assert.Error(err)
+	assert.Contains(err.Error(), "unreachable")
+}
+
+func TestObservingHooks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork, "system": "\nconfig system\n\n"})
+	r := NewStoreTree(store)
+	var loads []string
+	var commits []CommitDoneEvent
+	r.OnLoad(func(e LoadEvent) {
+		loads = append(loads, fmt.Sprintf("%s %v", e.Config, e.Err != nil))
+	})
+	r.OnCommitDone(func(e CommitDoneEvent) {
+		commits = append(commits, e)
+	})
+
+	assert.True(r.Set("system", "@system[0]", "hostname", "router"))
+	require.NoError(r.LoadConfig("network", false))
+	assert.Error(r.LoadConfig("missing", false))
+	assert.Equal([]string{"system false", "network false", "missing true"}, loads)
+
+	assert.Equal([]ConfigStats{
+		{Name: "network", Sections: 1, Options: 1, Values: 1},
+		{Name: "system", Sections: 1, Options: 1, Values: 1, Tainted: true},
+	}, r.Stats())
+
+	require.NoError(r.Commit())
+	r.OnPreCommit(func(*PackageEvent) error { return errFirewallOff })
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	assert.True(r.Set("system", "@system[0]", "hostname", "ap"))
+	assert.Error(r.Commit())
+
+	require.Len(commits, 2)
+	assert.Equal([]string{"system"}, commits[0].Configs)
+	assert.NoError(commits[0].Err)
+	assert.Equal([]string{"network", "system"}, commits[1].Configs)
+	assert.True(errors.As(commits[1].Err, new(ErrVetoed)))
+}
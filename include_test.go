@@ -0,0 +1,90 @@
+package uci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx := context.Background()
+	store := NewMemoryStore(map[string]string{
+		"firewall":      "config include 'user'\n\toption path 'firewall_user'\n\nconfig include 'extra'\n\toption path 'firewall_extra'\n",
+		"firewall_user": "config rule 'ssh'\n\toption name 'Allow-SSH'\n",
+	})
+	r := NewStoreTree(store)
+	r.SetIncludes(func(sec *Section) string {
+		if sec.Type != "include" {
+			return ""
+		}
+		return sec.LastValue("path")
+	})
+
+	name, ok := r.GetLast("firewall", "ssh", "name")
+	assert.True(ok)
+	assert.Equal("Allow-SSH", name)
+
+	assert.True(r.Set("firewall", "ssh", "dest_port", "22"))
+	require.NoError(r.AddSection("firewall", "web", "rule"))
+	require.NoError(r.Commit())
+
+	body, err := store.Read(ctx, "firewall")
+	require.NoError(err)
+	assert.NotContains(string(body), "ssh")
+	assert.Contains(string(body), "config rule 'web'")
+	body, err = store.Read(ctx, "firewall_user")
+	require.NoError(err)
+	assert.Contains(string(body), "option dest_port '22'")
+
+	// missing fragments are empty
+	body, err = store.Read(ctx, "firewall_extra")
+	require.NoError(err)
+	assert.Empty(string(bytes.TrimSpace(body)))
+
+	// the committed config is no concurrent modification
+	assert.True(r.Set("firewall", "ssh", "dest_port", "2222"))
+	require.NoError(r.Commit())
+}
+
+func TestIncludesRollback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx := context.Background()
+	files := map[string]string{
+		"firewall":      "config include 'user'\n\toption path 'firewall_user'\n\nconfig include 'extra'\n\toption path 'firewall_extra'\n",
+		"firewall_user": "config rule 'ssh'\n\toption name 'Allow-SSH'\n",
+	}
+	store := NewMemoryStore(files)
+	r := NewStoreTree(store)
+	r.SetIncludes(func(sec *Section) string {
+		return sec.LastValue("path")
+	})
+	errFailed := errors.New("failed")
+	r.OnPostCommit(func(e *PackageEvent) error {
+		return errFailed
+	})
+
+	assert.True(r.Set("firewall", "ssh", "dest_port", "22"))
+	require.NoError(r.AddSection("firewall", "web", "rule"))
+	err := r.Commit()
+	assert.ErrorIs(err, errFailed)
+	assert.ErrorAs(err, new(ErrRolledBack))
+
+	// the config and its fragments are restored, new fragments removed
+	names, err := store.List(ctx)
+	require.NoError(err)
+	assert.Equal([]string{"firewall", "firewall_user"}, names)
+	for name, want := range files {
+		body, err := store.Read(ctx, name)
+		require.NoError(err)
+		assert.Equal(want, string(body), name)
+	}
+}
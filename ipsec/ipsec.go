@@ -0,0 +1,339 @@
+// Package ipsec provides typed access to the ipsec config of OpenWrt's
+// strongswan package: remotes (IKE peers), the tunnels (child SAs) they
+// list, and the crypto proposals both refer to by name.
+//
+//	err := ipsec.PutProposal(tree, &ipsec.Proposal{Name: "aes256", Encryption: "aes256", Hash: "sha256", DHGroup: "modp2048"})
+//	err = ipsec.PutTunnel(tree, &ipsec.Tunnel{
+//		Name: "lan", LocalSubnets: []netip.Prefix{lan}, RemoteSubnets: []netip.Prefix{office},
+//		StartAction: "start", Proposals: []string{"aes256"},
+//	})
+//	err = ipsec.PutRemote(tree, &ipsec.Remote{
+//		Name: "office", Enabled: true, Gateway: "vpn.example.com",
+//		AuthMethod: ipsec.AuthPSK, PreSharedKey: psk,
+//		Proposals: []string{"aes256"}, Tunnels: []string{"lan"},
+//	})
+//	err = ipsec.Validate(tree) // checks the references
+package ipsec
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrNoConfig         = errors.New("ipsec config not found")
+	ErrInvalidAddress   = errors.New("invalid address")
+	ErrInvalidNumber    = errors.New("invalid number")
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrMissingOption    = errors.New("missing option")
+	ErrUnknownReference = errors.New("unknown reference")
+)
+
+// Authentication methods of remotes.
+const (
+	AuthPSK    = "psk"
+	AuthPubkey = "pubkey"
+)
+
+// Section types of the ipsec config.
+const (
+	TypeRemote   = "remote"
+	TypeTunnel   = "tunnel"
+	TypeProposal = "crypto_proposal"
+)
+
+// startActions, closeActions and dpdActions list the values of the
+// startaction, closeaction and dpdaction options.
+var (
+	startActions = []string{"none", "trap", "start"}
+	closeActions = []string{"none", "trap", "start"}
+	dpdActions   = []string{"none", "clear", "hold", "restart", "trap", "start"}
+)
+
+// Remote is an IKE peer ("config remote").
+type Remote struct {
+	Name    string
+	Enabled bool
+	Gateway string // host name or address of the peer, "any" for roadwarriors
+
+	LocalGateway     string
+	LocalIdentifier  string
+	RemoteIdentifier string
+
+	AuthMethod   string // AuthPSK or AuthPubkey
+	PreSharedKey string
+	LocalCert    string // file name in /etc/ipsec.d/certs
+	LocalKey     string // file name in /etc/ipsec.d/private
+	CACert       string // file name in /etc/ipsec.d/cacerts
+
+	KeyingTries int    // 0 means unset
+	RekeyTime   string // e.g. "4h"
+	Proposals   []string
+	Tunnels     []string
+}
+
+// Tunnel is a child SA of remotes ("config tunnel").
+type Tunnel struct {
+	Name          string
+	LocalSubnets  []netip.Prefix
+	RemoteSubnets []netip.Prefix
+	StartAction   string // "none", "trap" or "start"
+	CloseAction   string
+	DPDAction     string
+	RekeyTime     string
+	Proposals     []string
+}
+
+// Proposal is a set of algorithms ("config crypto_proposal"), e.g.
+// aes256, sha256 and modp2048.
+type Proposal struct {
+	Name       string
+	Encryption string // "encryption_algorithm"
+	Hash       string // "hash_algorithm"
+	DHGroup    string // "dh_group"
+	PRF        string // "prf_algorithm", for IKE proposals
+}
+
+// remoteOptions, tunnelOptions and proposalOptions list the managed
+// options.
+var (
+	remoteOptions = []string{
+		"enabled", "gateway", "local_gateway", "local_identifier", "remote_identifier",
+		"authentication_method", "pre_shared_key", "local_cert", "local_key", "ca_cert",
+		"keyingtries", "rekeytime", "crypto_proposal", "tunnel",
+	}
+	tunnelOptions = []string{
+		"local_subnet", "remote_subnet", "startaction", "closeaction", "dpdaction",
+		"rekeytime", "crypto_proposal",
+	}
+	proposalOptions = []string{"encryption_algorithm", "hash_algorithm", "dh_group", "prf_algorithm"}
+)
+
+// ParseRemote converts a remote section.
+func ParseRemote(sec *uci.Section) (*Remote, error) {
+	r := &Remote{
+		Name:             sec.Name,
+		Enabled:          sec.LastValue("enabled") == "1",
+		Gateway:          sec.LastValue("gateway"),
+		LocalGateway:     sec.LastValue("local_gateway"),
+		LocalIdentifier:  sec.LastValue("local_identifier"),
+		RemoteIdentifier: sec.LastValue("remote_identifier"),
+		AuthMethod:       sec.LastValue("authentication_method"),
+		PreSharedKey:     sec.LastValue("pre_shared_key"),
+		LocalCert:        sec.LastValue("local_cert"),
+		LocalKey:         sec.LastValue("local_key"),
+		CACert:           sec.LastValue("ca_cert"),
+		RekeyTime:        sec.LastValue("rekeytime"),
+		Proposals:        sec.Fields("crypto_proposal"),
+		Tunnels:          sec.Fields("tunnel"),
+	}
+	var err error
+	if r.KeyingTries, err = parseInt(sec.LastValue("keyingtries")); err != nil {
+		return nil, fmt.Errorf("remote %s: %w", sec.Name, err)
+	}
+	return r, nil
+}
+
+// ParseTunnel converts a tunnel section.
+func ParseTunnel(sec *uci.Section) (*Tunnel, error) {
+	tun := &Tunnel{
+		Name:        sec.Name,
+		StartAction: sec.LastValue("startaction"),
+		CloseAction: sec.LastValue("closeaction"),
+		DPDAction:   sec.LastValue("dpdaction"),
+		RekeyTime:   sec.LastValue("rekeytime"),
+		Proposals:   sec.Fields("crypto_proposal"),
+	}
+	var err error
+	if tun.LocalSubnets, err = parsePrefixes(sec.Fields("local_subnet")); err != nil {
+		return nil, fmt.Errorf("tunnel %s: %w", sec.Name, err)
+	}
+	if tun.RemoteSubnets, err = parsePrefixes(sec.Fields("remote_subnet")); err != nil {
+		return nil, fmt.Errorf("tunnel %s: %w", sec.Name, err)
+	}
+	return tun, nil
+}
+
+// ParseProposal converts a crypto_proposal section.
+func ParseProposal(sec *uci.Section) *Proposal {
+	return &Proposal{
+		Name:       sec.Name,
+		Encryption: sec.LastValue("encryption_algorithm"),
+		Hash:       sec.LastValue("hash_algorithm"),
+		DHGroup:    sec.LastValue("dh_group"),
+		PRF:        sec.LastValue("prf_algorithm"),
+	}
+}
+
+// Validate checks the gateway, and that the credentials of the
+// authentication method are set. References are checked by the
+// package-level Validate.
+func (r *Remote) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("remote %s: %w", r.Name, err)
+	}
+	if r.Gateway == "" {
+		return wrap(fmt.Errorf("%w: gateway", ErrMissingOption))
+	}
+	switch r.AuthMethod {
+	case AuthPSK:
+		if r.PreSharedKey == "" {
+			return wrap(fmt.Errorf("%w: pre_shared_key", ErrMissingOption))
+		}
+	case AuthPubkey:
+		if r.LocalCert == "" || r.LocalKey == "" {
+			return wrap(fmt.Errorf("%w: local_cert and local_key", ErrMissingOption))
+		}
+	default:
+		return wrap(fmt.Errorf("%w: authentication_method %q", ErrInvalidValue, r.AuthMethod))
+	}
+	if len(r.Tunnels) == 0 {
+		return wrap(fmt.Errorf("%w: tunnel", ErrMissingOption))
+	}
+	return nil
+}
+
+// Validate checks that the tunnel has local and remote subnets, and its
+// actions.
+func (tun *Tunnel) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("tunnel %s: %w", tun.Name, err)
+	}
+	if len(tun.LocalSubnets) == 0 {
+		return wrap(fmt.Errorf("%w: local_subnet", ErrMissingOption))
+	}
+	if len(tun.RemoteSubnets) == 0 {
+		return wrap(fmt.Errorf("%w: remote_subnet", ErrMissingOption))
+	}
+	for _, actions := range []struct {
+		option, value string
+		valid         []string
+	}{
+		{"startaction", tun.StartAction, startActions},
+		{"closeaction", tun.CloseAction, closeActions},
+		{"dpdaction", tun.DPDAction, dpdActions},
+	} {
+		if actions.value != "" && !slices.Contains(actions.valid, actions.value) {
+			return wrap(fmt.Errorf("%w: %s %q", ErrInvalidValue, actions.option, actions.value))
+		}
+	}
+	return nil
+}
+
+// Validate checks that the proposal has an encryption algorithm.
+func (p *Proposal) Validate() error {
+	if p.Encryption == "" {
+		return fmt.Errorf("crypto_proposal %s: %w: encryption_algorithm", p.Name, ErrMissingOption)
+	}
+	return nil
+}
+
+// Section renders the remote section.
+func (r *Remote) Section() *uci.Section {
+	s := uci.NewSection(TypeRemote, r.Name)
+	if r.Enabled {
+		s.SetOption("enabled", "1")
+	} else {
+		s.SetOption("enabled", "0")
+	}
+	setOptions(s,
+		"gateway", r.Gateway,
+		"local_gateway", r.LocalGateway,
+		"local_identifier", r.LocalIdentifier,
+		"remote_identifier", r.RemoteIdentifier,
+		"authentication_method", r.AuthMethod,
+		"pre_shared_key", r.PreSharedKey,
+		"local_cert", r.LocalCert,
+		"local_key", r.LocalKey,
+		"ca_cert", r.CACert,
+	)
+	if r.KeyingTries > 0 {
+		s.SetOption("keyingtries", strconv.Itoa(r.KeyingTries))
+	}
+	setOptions(s, "rekeytime", r.RekeyTime)
+	setList(s, "crypto_proposal", r.Proposals)
+	setList(s, "tunnel", r.Tunnels)
+	return s
+}
+
+// Section renders the tunnel section.
+func (tun *Tunnel) Section() *uci.Section {
+	s := uci.NewSection(TypeTunnel, tun.Name)
+	setList(s, "local_subnet", prefixStrings(tun.LocalSubnets))
+	setList(s, "remote_subnet", prefixStrings(tun.RemoteSubnets))
+	setOptions(s,
+		"startaction", tun.StartAction,
+		"closeaction", tun.CloseAction,
+		"dpdaction", tun.DPDAction,
+		"rekeytime", tun.RekeyTime,
+	)
+	setList(s, "crypto_proposal", tun.Proposals)
+	return s
+}
+
+// Section renders the crypto_proposal section.
+func (p *Proposal) Section() *uci.Section {
+	s := uci.NewSection(TypeProposal, p.Name)
+	setOptions(s,
+		"encryption_algorithm", p.Encryption,
+		"hash_algorithm", p.Hash,
+		"dh_group", p.DHGroup,
+		"prf_algorithm", p.PRF,
+	)
+	return s
+}
+
+// setOptions sets the non-empty values of name/value pairs.
+func setOptions(s *uci.Section, pairs ...string) {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			s.SetOption(pairs[i], pairs[i+1])
+		}
+	}
+}
+
+func setList(s *uci.Section, name string, values []string) {
+	if len(values) > 0 {
+		s.SetList(name, values...)
+	}
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range values {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return nil, fmt.Errorf("%w %q", ErrInvalidAddress, v)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+	}
+	return n, nil
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	s := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+package ipsec
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+const testConfig = `
+config ipsec
+	option debug '0'
+
+config remote 'office'
+	option enabled '1'
+	option gateway 'vpn.example.com'
+	option authentication_method 'psk'
+	option pre_shared_key 'secret'
+	option keyingtries '3'
+	list crypto_proposal 'ike'
+	list tunnel 'lan'
+	option mobike '1'
+
+config tunnel 'lan'
+	list local_subnet '192.168.1.0/24'
+	list remote_subnet '10.0.0.0/16'
+	option startaction 'start'
+	list crypto_proposal 'esp'
+
+config crypto_proposal 'ike'
+	option encryption_algorithm 'aes256'
+	option hash_algorithm 'sha256'
+	option dh_group 'modp2048'
+
+config crypto_proposal 'esp'
+	option encryption_algorithm 'aes128gcm128'
+`
+
+func testTree(t *testing.T) uci.Tree {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ipsec"), []byte(testConfig), 0o644))
+	return uci.NewTree(dir)
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tree := testTree(t)
+
+	remotes, err := Remotes(tree)
+	require.NoError(err)
+	require.Len(remotes, 1)
+	r := remotes[0]
+	assert.True(r.Enabled)
+	assert.Equal(AuthPSK, r.AuthMethod)
+	assert.Equal(3, r.KeyingTries)
+	assert.Equal([]string{"ike"}, r.Proposals)
+	assert.Equal([]string{"lan"}, r.Tunnels)
+	assert.NoError(r.Validate())
+
+	tunnels, err := Tunnels(tree)
+	require.NoError(err)
+	require.Len(tunnels, 1)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}, tunnels[0].LocalSubnets)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/16")}, tunnels[0].RemoteSubnets)
+	assert.NoError(tunnels[0].Validate())
+
+	proposals, err := Proposals(tree)
+	require.NoError(err)
+	require.Len(proposals, 2)
+	assert.Equal(Proposal{Name: "ike", Encryption: "aes256", Hash: "sha256", DHGroup: "modp2048"}, *proposals[0])
+	assert.NoError(Validate(tree))
+
+	sec := uci.NewSection(TypeTunnel, "bad")
+	sec.SetList("local_subnet", "192.168.1.0/33")
+	_, err = ParseTunnel(sec)
+	assert.ErrorIs(err, ErrInvalidAddress)
+	sec = uci.NewSection(TypeRemote, "bad")
+	sec.SetOption("keyingtries", "x")
+	_, err = ParseRemote(sec)
+	assert.ErrorIs(err, ErrInvalidNumber)
+
+	_, err = Remotes(uci.NewTree(t.TempDir()))
+	assert.ErrorIs(err, ErrNoConfig)
+}
+
+func TestValidate(t *testing.T) {
+	lan := []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}
+	for _, tc := range []struct {
+		name string
+		v    interface{ Validate() error }
+		err  error
+	}{
+		{"no gateway", &Remote{Name: "r", AuthMethod: AuthPSK, PreSharedKey: "s", Tunnels: []string{"t"}}, ErrMissingOption},
+		{"no psk", &Remote{Name: "r", Gateway: "gw", AuthMethod: AuthPSK, Tunnels: []string{"t"}}, ErrMissingOption},
+		{"no cert", &Remote{Name: "r", Gateway: "gw", AuthMethod: AuthPubkey, LocalKey: "k", Tunnels: []string{"t"}}, ErrMissingOption},
+		{"auth method", &Remote{Name: "r", Gateway: "gw", AuthMethod: "xauth", Tunnels: []string{"t"}}, ErrInvalidValue},
+		{"no tunnel", &Remote{Name: "r", Gateway: "gw", AuthMethod: AuthPSK, PreSharedKey: "s"}, ErrMissingOption},
+		{"pubkey", &Remote{Name: "r", Gateway: "gw", AuthMethod: AuthPubkey, LocalCert: "c", LocalKey: "k", Tunnels: []string{"t"}}, nil},
+		{"no local subnet", &Tunnel{Name: "t", RemoteSubnets: lan}, ErrMissingOption},
+		{"no remote subnet", &Tunnel{Name: "t", LocalSubnets: lan}, ErrMissingOption},
+		{"startaction", &Tunnel{Name: "t", LocalSubnets: lan, RemoteSubnets: lan, StartAction: "add"}, ErrInvalidValue},
+		{"dpdaction", &Tunnel{Name: "t", LocalSubnets: lan, RemoteSubnets: lan, DPDAction: "restart"}, nil},
+		{"no encryption", &Proposal{Name: "p", Hash: "sha256"}, ErrMissingOption},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.v.Validate()
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestPut(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tree := testTree(t)
+
+	require.NoError(PutProposal(tree, &Proposal{Name: "esp256", Encryption: "aes256gcm128", DHGroup: "ecp256"}))
+	require.NoError(PutTunnel(tree, &Tunnel{
+		Name:          "dmz",
+		LocalSubnets:  []netip.Prefix{netip.MustParsePrefix("192.168.2.0/24")},
+		RemoteSubnets: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00::/64")},
+		StartAction:   "trap",
+		Proposals:     []string{"esp256"},
+	}))
+	remotes, err := Remotes(tree)
+	require.NoError(err)
+	r := remotes[0]
+	r.Tunnels = append(r.Tunnels, "dmz")
+	r.KeyingTries = 0
+	require.NoError(PutRemote(tree, r))
+	require.NoError(Validate(tree))
+
+	tunnels, _ := tree.Get("ipsec", "office", "tunnel")
+	assert.Equal([]string{"lan", "dmz"}, tunnels)
+	mobike, _ := tree.GetLast("ipsec", "office", "mobike")
+	assert.Equal("1", mobike)
+	cfg, _ := tree.EnsureConfigLoaded("ipsec")
+	assert.Nil(cfg.Get("office").Get("keyingtries"))
+	subnets, _ := tree.Get("ipsec", "dmz", "remote_subnet")
+	assert.Equal([]string{"10.1.0.0/16", "fd00::/64"}, subnets)
+
+	r.Tunnels = append(r.Tunnels, "missing")
+	require.NoError(PutRemote(tree, r))
+	assert.ErrorIs(Validate(tree), ErrUnknownReference)
+	r.Tunnels = []string{"ike"}
+	require.NoError(PutRemote(tree, r))
+	assert.ErrorIs(Validate(tree), ErrUnknownReference)
+	r.Tunnels = []string{"lan"}
+	require.NoError(PutRemote(tree, r))
+	require.NoError(PutTunnel(tree, &Tunnel{
+		Name:          "lan",
+		LocalSubnets:  []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+		RemoteSubnets: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/16")},
+		Proposals:     []string{"missing"},
+	}))
+	assert.ErrorIs(Validate(tree), ErrUnknownReference)
+
+	// changes run the tree's hooks, and are rolled back as a whole
+	errDenied := errors.New("denied")
+	tree.OnSet(func(e *uci.SetEvent) error {
+		if e.Option == "dh_group" {
+			return errDenied
+		}
+		return nil
+	})
+	assert.ErrorIs(PutProposal(tree, &Proposal{Name: "aes", Encryption: "aes256", DHGroup: "modp2048"}), errDenied)
+	cfg, _ = tree.CopyConfig("ipsec")
+	assert.Nil(cfg.Get("aes"))
+}
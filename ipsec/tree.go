@@ -0,0 +1,125 @@
+package ipsec
+
+import (
+	"fmt"
+	"slices"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// Remotes returns the remotes of the ipsec config.
+func Remotes(t uci.Tree) ([]*Remote, error) {
+	cfg, ok := t.EnsureConfigLoaded("ipsec")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	var remotes []*Remote
+	for _, sec := range sectionsOfType(cfg, TypeRemote) {
+		r, err := ParseRemote(sec)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, r)
+	}
+	return remotes, nil
+}
+
+// Tunnels returns the tunnels of the ipsec config.
+func Tunnels(t uci.Tree) ([]*Tunnel, error) {
+	cfg, ok := t.EnsureConfigLoaded("ipsec")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	var tunnels []*Tunnel
+	for _, sec := range sectionsOfType(cfg, TypeTunnel) {
+		tun, err := ParseTunnel(sec)
+		if err != nil {
+			return nil, err
+		}
+		tunnels = append(tunnels, tun)
+	}
+	return tunnels, nil
+}
+
+// Proposals returns the crypto proposals of the ipsec config.
+func Proposals(t uci.Tree) ([]*Proposal, error) {
+	cfg, ok := t.EnsureConfigLoaded("ipsec")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	var proposals []*Proposal
+	for _, sec := range sectionsOfType(cfg, TypeProposal) {
+		proposals = append(proposals, ParseProposal(sec))
+	}
+	return proposals, nil
+}
+
+// Validate checks that the tunnels and proposals referred to by remotes,
+// and the proposals referred to by tunnels, exist with the right type.
+func Validate(t uci.Tree) error {
+	cfg, ok := t.EnsureConfigLoaded("ipsec")
+	if !ok {
+		return ErrNoConfig
+	}
+	check := func(kind, name, option string, refs []string, typ string) error {
+		for _, ref := range refs {
+			if sec := cfg.Get(ref); sec == nil || sec.Type != typ {
+				return fmt.Errorf("%s %s: %w: %s %q", kind, name, ErrUnknownReference, option, ref)
+			}
+		}
+		return nil
+	}
+	for _, sec := range cfg.Sections {
+		switch sec.Type {
+		case TypeRemote:
+			if err := check("remote", sec.Name, "tunnel", sec.Fields("tunnel"), TypeTunnel); err != nil {
+				return err
+			}
+			fallthrough
+		case TypeTunnel:
+			if err := check(sec.Type, sec.Name, "crypto_proposal", sec.Fields("crypto_proposal"), TypeProposal); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PutRemote validates r, and stores it in the ipsec config, creating the
+// section (and config), if necessary. Options not represented by Remote
+// are kept.
+func PutRemote(t uci.Tree, r *Remote) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return put(t, r.Section(), remoteOptions)
+}
+
+// PutTunnel validates tun, and stores it like PutRemote.
+func PutTunnel(t uci.Tree, tun *Tunnel) error {
+	if err := tun.Validate(); err != nil {
+		return err
+	}
+	return put(t, tun.Section(), tunnelOptions)
+}
+
+// PutProposal validates p, and stores it like PutRemote.
+func PutProposal(t uci.Tree, p *Proposal) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return put(t, p.Section(), proposalOptions)
+}
+
+func put(t uci.Tree, rendered *uci.Section, managed []string) error {
+	return t.Batch(func(tx *uci.Tx) error {
+		_, err := tx.PutSection("ipsec", rendered, managed...)
+		return err
+	})
+}
+
+func sectionsOfType(cfg *uci.Config, typ string) []*uci.Section {
+	return slices.DeleteFunc(slices.Clone(cfg.Sections), func(sec *uci.Section) bool {
+		return sec.Type != typ
+	})
+}
@@ -0,0 +1,224 @@
+// Package lint checks UCI configs for mistakes, which uci accepts, but
+// which most likely break the consuming services, e.g. references to
+// sections which don't exist:
+//
+//	findings, err := lint.Source("firewall", input, lint.WithConfigs(tree.EnsureConfigLoaded))
+//	for _, f := range findings {
+//		fmt.Println(f)
+//	}
+//
+// Checks are implemented as rules. The built-in rules use the
+// descriptions of the schema package; users can register their own
+// rules with Register.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wsiner/go-uci/ast"
+	"github.com/wsiner/go-uci/schema"
+)
+
+// Severity classifies findings.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// A Finding is a problem reported by a rule.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Path     ast.Path     // the config, section and (if any) option concerned
+	Pos      ast.Position // zero, if unknown
+	Message  string
+}
+
+// String returns "position: severity: message (rule)", or
+// "path: severity: message (rule)", if the position is unknown.
+func (f Finding) String() string {
+	where := f.Path.String()
+	if f.Pos.Line > 0 {
+		where = f.Pos.String()
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", where, f.Severity, f.Message, f.Rule)
+}
+
+// A Rule checks a config.
+type Rule struct {
+	// Name identifies the rule, e.g. "dangling-reference".
+	Name        string
+	Description string
+	Severity    Severity // of the rule's findings
+
+	// Check reports the findings of the rule with Pass.Report.
+	Check func(p *Pass)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Rule)
+)
+
+// Register adds a rule to the rules used by default. An existing rule
+// with the same name is replaced.
+func Register(r *Rule) {
+	registryMu.Lock()
+	registry[r.Name] = r
+	registryMu.Unlock()
+}
+
+// Rules returns the registered rules, ordered by name.
+func Rules() []*Rule {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	rules := make([]*Rule, 0, len(registry))
+	for _, r := range registry {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// A Pass provides a rule with the config to check.
+type Pass struct {
+	Config    *ast.Config
+	Positions *ast.Positions // nil, if unknown
+	Schema    *schema.Schema
+
+	configs  func(name string) (*ast.Config, bool)
+	rule     *Rule
+	findings []Finding
+}
+
+// Lookup returns the named config, to check references to other
+// configs (see WithConfigs). The checked config is always found.
+func (p *Pass) Lookup(name string) (*ast.Config, bool) {
+	if name == p.Config.Name {
+		return p.Config, true
+	}
+	if p.configs == nil {
+		return nil, false
+	}
+	return p.configs(name)
+}
+
+// Report adds a finding for a section, or an option (if opt is not
+// nil) of the section.
+func (p *Pass) Report(sec *ast.Section, opt *ast.Option, format string, args ...interface{}) {
+	f := Finding{
+		Rule:     p.rule.Name,
+		Severity: p.rule.Severity,
+		Path:     ast.Path{Config: p.Config.Name, Section: p.Config.SectionName(sec)},
+		Message:  fmt.Sprintf(format, args...),
+	}
+	switch {
+	case opt != nil:
+		f.Path.Option = opt.Name
+		if p.Positions != nil {
+			f.Pos, _ = p.Positions.Option(opt)
+		}
+	case p.Positions != nil:
+		f.Pos, _ = p.Positions.Section(sec)
+	}
+	p.ReportFinding(f)
+}
+
+// ReportFinding adds a finding, e.g. for a position not belonging to a
+// section or option. Empty Rule names are set to the rule's name.
+func (p *Pass) ReportFinding(f Finding) {
+	if f.Rule == "" {
+		f.Rule = p.rule.Name
+	}
+	p.findings = append(p.findings, f)
+}
+
+type options struct {
+	rules   []*Rule
+	schema  *schema.Schema
+	configs func(name string) (*ast.Config, bool)
+}
+
+// An Option changes the behaviour of Config and Source.
+type Option func(o *options)
+
+// WithRules checks the given rules, instead of the registered ones.
+func WithRules(rules ...*Rule) Option {
+	return func(o *options) {
+		o.rules = rules
+	}
+}
+
+// WithSchema uses the given schema, instead of schema.Default.
+func WithSchema(s *schema.Schema) Option {
+	return func(o *options) {
+		o.schema = s
+	}
+}
+
+// WithConfigs provides the configs referenced by the checked config
+// (e.g. Tree.EnsureConfigLoaded). Without, references to other configs
+// are not checked.
+func WithConfigs(lookup func(name string) (*ast.Config, bool)) Option {
+	return func(o *options) {
+		o.configs = lookup
+	}
+}
+
+// Config checks cfg. pos may be nil, but then findings lack positions
+// and redefined sections aren't detected. The findings are ordered by
+// position (findings without position last), and rule name.
+func Config(cfg *ast.Config, pos *ast.Positions, opts ...Option) []Finding {
+	o := options{schema: schema.Default}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.rules == nil {
+		o.rules = Rules()
+	}
+
+	var findings []Finding
+	for _, r := range o.rules {
+		p := &Pass{Config: cfg, Positions: pos, Schema: o.schema, configs: o.configs, rule: r}
+		r.Check(p)
+		findings = append(findings, p.findings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if (a.Pos.Line > 0) != (b.Pos.Line > 0) {
+			return a.Pos.Line > 0
+		}
+		if a.Pos.Offset != b.Pos.Offset {
+			return a.Pos.Offset < b.Pos.Offset
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
+
+// Source parses and checks a config file. It fails on syntax errors.
+func Source(name, input string, opts ...Option) ([]Finding, error) {
+	cfg, pos, err := ast.ParsePositions(name, input)
+	if err != nil {
+		return nil, err
+	}
+	return Config(cfg, pos, opts...), nil
+}
@@ -0,0 +1,114 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+const tcNetwork = `
+config interface 'lan'
+	option device 'br-lan'
+	option ifname 'eth0'
+
+config interface 'wan'
+	option ifname 'eth1'
+
+config route
+	option interface 'guest'
+	list gateway '10.0.0.1'
+	list gateway '10.0.0.2'
+
+config interface 'lan'
+	option proto 'static'
+`
+
+const tcFirewall = `
+config zone
+	option name 'lan'
+	list network 'lan'
+
+config forwarding
+	option src 'lan'
+	option dest 'wan'
+`
+
+func TestSource(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	findings, err := Source("network", tcNetwork)
+	require.NoError(err)
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	assert.Equal([]string{
+		"network:4:9: warning: option ifname is deprecated in favor of device, which is set as well (deprecated-option)",
+		"network:7:9: warning: option ifname is deprecated, use device instead (deprecated-option)",
+		"network:10:9: error: interface guest not found in network (dangling-reference)",
+		"network:11:7: warning: gateway is a list, but expects a single value (list-as-option)",
+		"network:14:8: warning: section lan defined more than once, the definitions are merged (duplicate-section)",
+	}, got)
+	assert.Equal(ast.Path{Config: "network", Section: "@route[0]", Option: "interface"}, findings[2].Path)
+
+	_, err = Source("network", "config")
+	assert.Error(err)
+}
+
+func TestReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	network, err := ast.Parse("network", tcNetwork)
+	require.NoError(t, err)
+	firewall, pos, err := ast.ParsePositions("firewall", tcFirewall)
+	require.NoError(t, err)
+
+	// without the network config, only references within the firewall
+	// config are checked
+	findings := Config(firewall, pos, WithRules(DanglingReference))
+	require.Len(t, findings, 1)
+	assert.Equal("firewall:8:9: error: zone wan not found in firewall (dangling-reference)", findings[0].String())
+
+	lookup := func(name string) (*ast.Config, bool) {
+		return network, name == "network"
+	}
+	firewall.Get("@zone[0]").Get("network").AddValue("guest")
+	findings = Config(firewall, nil, WithRules(DanglingReference), WithConfigs(lookup))
+	require.Len(t, findings, 2)
+	assert.Equal("firewall.@zone[0].network: error: interface guest not found in network (dangling-reference)", findings[0].String())
+	assert.Equal("firewall.@forwarding[0].dest: error: zone wan not found in firewall (dangling-reference)", findings[1].String())
+}
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := &Rule{
+		Name:     "test-no-anonymous",
+		Severity: SeverityInfo,
+		Check: func(p *Pass) {
+			for _, sec := range p.Config.Sections {
+				if sec.Name == "" {
+					p.Report(sec, nil, "anonymous %s section", sec.Type)
+				}
+			}
+		},
+	}
+	Register(rule)
+	defer func() {
+		registryMu.Lock()
+		delete(registry, rule.Name)
+		registryMu.Unlock()
+	}()
+	assert.Contains(Rules(), rule)
+
+	findings, err := Source("firewall", tcFirewall)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+	assert.Equal("firewall:2:8: info: anonymous zone section (test-no-anonymous)", findings[0].String())
+	assert.Equal("dangling-reference", findings[2].Rule)
+	assert.Equal("Severity(7)", Severity(7).String())
+}
@@ -0,0 +1,119 @@
+package lint
+
+import (
+	"github.com/wsiner/go-uci/ast"
+	"github.com/wsiner/go-uci/schema"
+)
+
+// The built-in rules.
+var (
+	DuplicateSection = &Rule{
+		Name:        "duplicate-section",
+		Description: "Named sections defined more than once, which uci merges into one",
+		Severity:    SeverityWarning,
+		Check:       checkDuplicateSections,
+	}
+	DanglingReference = &Rule{
+		Name:        "dangling-reference",
+		Description: "Options referring to sections which don't exist",
+		Severity:    SeverityError,
+		Check:       checkReferences,
+	}
+	DeprecatedOption = &Rule{
+		Name:        "deprecated-option",
+		Description: "Options which should no longer be used",
+		Severity:    SeverityWarning,
+		Check:       checkDeprecated,
+	}
+	ListAsOption = &Rule{
+		Name:        "list-as-option",
+		Description: "Lists, where a single value is expected",
+		Severity:    SeverityWarning,
+		Check:       checkLists,
+	}
+)
+
+func init() {
+	for _, r := range []*Rule{DuplicateSection, DanglingReference, DeprecatedOption, ListAsOption} {
+		Register(r)
+	}
+}
+
+func checkDuplicateSections(p *Pass) {
+	seen := make(map[string]bool)
+	for _, sec := range p.Config.Sections {
+		if sec.Name == "" {
+			continue
+		}
+		if seen[sec.Name] {
+			p.Report(sec, nil, "section name %s used more than once", sec.Name)
+		}
+		seen[sec.Name] = true
+
+		if p.Positions == nil {
+			continue
+		}
+		for _, pos := range p.Positions.Redefinitions(sec) {
+			p.ReportFinding(Finding{
+				Severity: p.rule.Severity,
+				Path:     ast.Path{Config: p.Config.Name, Section: sec.Name},
+				Pos:      pos,
+				Message:  "section " + sec.Name + " defined more than once, the definitions are merged",
+			})
+		}
+	}
+}
+
+// schemaOption returns the schema's description of an option, or nil.
+func schemaOption(p *Pass, sec *ast.Section, opt *ast.Option) *schema.Option {
+	if p.Schema == nil {
+		return nil
+	}
+	return p.Schema.Lookup(p.Config.Name, sec.Type, opt.Name)
+}
+
+// eachOption calls fn for all options described by the schema.
+func eachOption(p *Pass, fn func(sec *ast.Section, opt *ast.Option, desc *schema.Option)) {
+	for _, sec := range p.Config.Sections {
+		for _, opt := range sec.Options {
+			if desc := schemaOption(p, sec, opt); desc != nil {
+				fn(sec, opt, desc)
+			}
+		}
+	}
+}
+
+func checkReferences(p *Pass) {
+	if p.Schema == nil {
+		return
+	}
+	for _, dep := range p.Schema.References(p.Config, p.Lookup) {
+		if !dep.Dangling {
+			continue
+		}
+		sec := p.Config.Get(dep.From.Section)
+		opt := sec.Get(dep.From.Option)
+		ref := schemaOption(p, sec, opt).Ref
+		p.Report(sec, opt, "%s %s not found in %s", ref.SectionType, dep.Value, ref.Package)
+	}
+}
+
+func checkDeprecated(p *Pass) {
+	eachOption(p, func(sec *ast.Section, opt *ast.Option, desc *schema.Option) {
+		switch {
+		case desc.Deprecated == "":
+		case sec.Get(desc.Deprecated) != nil:
+			p.Report(sec, opt, "option %s is deprecated in favor of %s, which is set as well", opt.Name, desc.Deprecated)
+		default:
+			p.Report(sec, opt, "option %s is deprecated, use %s instead", opt.Name, desc.Deprecated)
+		}
+	})
+}
+
+func checkLists(p *Pass) {
+	eachOption(p, func(sec *ast.Section, opt *ast.Option, desc *schema.Option) {
+		if desc.Type == ast.TypeOption && (opt.Type == ast.TypeList || len(opt.Values) > 1) {
+			p.Report(sec, opt, "%s is a list, but expects a single value", opt.Name)
+		}
+	})
+}
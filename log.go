@@ -0,0 +1,60 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// discardLogger is used by trees without logger.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// loggingBackend is implemented by backends logging on their own (see
+// Tree.SetLogger).
+type loggingBackend interface {
+	setLogger(l *slog.Logger)
+}
+
+func (t *tree) SetLogger(l *slog.Logger) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.log = l
+	if b, ok := t.backend.(loggingBackend); ok {
+		b.setLogger(t.logger())
+	}
+}
+
+// logger returns the logger of the tree. Its call must be guarded by
+// locking the tree's mutex.
+func (t *tree) logger() *slog.Logger {
+	if t.log == nil {
+		return discardLogger
+	}
+	return t.log
+}
+
+// section works like cfg.Get, but logs malformed selectors, which Get
+// treats like missing sections. Its call must be guarded by locking the
+// tree's mutex.
+func (t *tree) section(cfg *Config, name string) *Section {
+	sec, err := cfg.Lookup(name)
+	if err != nil && !errors.As(err, new(ErrSectionNotFound)) {
+		t.logger().Warn("invalid section selector", "config", cfg.Name, "section", name, "err", err)
+	}
+	return sec
+}
+
+// logLoad logs the outcome of loading a config. Missing configs are
+// common (e.g. before AddSection creates them), and hence only logged
+// at debug level. Its call must be guarded by locking the tree's mutex.
+func (t *tree) logLoad(ctx context.Context, e LoadEvent) {
+	switch log := t.logger(); {
+	case errors.Is(e.Err, ErrConfigNotFound):
+		log.DebugContext(ctx, "config not found", "config", e.Config)
+	case e.Err != nil:
+		log.ErrorContext(ctx, "loading config failed", "config", e.Config, "err", e.Err)
+	default:
+		log.DebugContext(ctx, "config loaded", "config", e.Config, "duration", e.Duration)
+	}
+}
@@ -0,0 +1,64 @@
+package uci
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLogger(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	store := NewMemoryStore(map[string]string{
+		"network": tcSafeNetwork,
+		"system":  "\nconfig system\n\toption hostname 'a'\n\toption hostname 'b'\n",
+	})
+	r := NewStoreTree(store)
+	r.SetLogger(log)
+	r.OnSet(func(e *SetEvent) error {
+		if e.Option == "proto" {
+			return errFirewallOff
+		}
+		return nil
+	})
+
+	assert.False(r.Set("network", "@interface[x]", "ipaddr", "10.0.0.1"))
+	assert.False(r.Set("network", "lan", "proto", "dhcp"))
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	require.NoError(r.LoadConfig("system", false))
+	assert.Error(r.LoadConfig("missing", false))
+	require.NoError(r.Commit())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(lines, 7)
+	assert.Equal(`level=DEBUG msg="config loaded" config=network`, lines[0])
+	assert.Contains(lines[1], `level=WARN msg="invalid section selector" config=network section=@interface[x]`)
+	assert.Equal(`level=INFO msg="change rejected by hook" config=network section=lan option=proto err="the firewall must stay enabled"`, lines[2])
+	assert.Contains(lines[3], `level=WARN msg="suspicious config syntax" config=system path=system err=`)
+	assert.Contains(lines[3], "duplicate option")
+	assert.Equal(`level=DEBUG msg="config loaded" config=system`, lines[4])
+	assert.Equal(`level=DEBUG msg="config not found" config=missing`, lines[5])
+	assert.Equal(`level=INFO msg="config committed" config=network`, lines[6])
+
+	// disabled again
+	buf.Reset()
+	r.SetLogger(nil)
+	r.Del("network", "@interface[x]", "ipaddr")
+	assert.Empty(buf.String())
+}
@@ -0,0 +1,402 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/wsiner/go-uci/ast"
+	"github.com/wsiner/go-uci/schema"
+)
+
+// document is an open text document.
+type document struct {
+	uri   string
+	pkg   string // config name, derived from the file name
+	text  string
+	lines []string
+}
+
+func newDocument(uri, text string) *document {
+	return &document{
+		uri:   uri,
+		pkg:   packageName(uri),
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+}
+
+// packageName returns the base name of the file referenced by uri.
+func packageName(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(uri)
+}
+
+// line returns the n-th line (0-based), or "" if n is out of bounds.
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lines) {
+		return ""
+	}
+	return strings.TrimSuffix(d.lines[n], "\r")
+}
+
+// offset converts pos, whose character is counted in UTF-16 code units
+// like LSP requires, to a byte offset within its line.
+func (d *document) offset(pos Position) int {
+	line := d.line(pos.Line)
+	n := 0
+	for i, r := range line {
+		if n >= pos.Character {
+			return i
+		}
+		n += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// position converts a byte offset within a line to a Position.
+func (d *document) position(line, offset int) Position {
+	text := d.line(line)
+	if offset > len(text) {
+		offset = len(text)
+	}
+	n := 0
+	for _, r := range text[:max(offset, 0)] {
+		n += utf16.RuneLen(r)
+	}
+	return Position{line, n}
+}
+
+// field is a token of a line, with unquoted text and the byte range
+// (including quotes) within the line.
+type field struct {
+	text       string
+	start, end int
+}
+
+// splitLine splits a line into fields, honoring quotes and comments.
+func splitLine(line string) []field {
+	var fields []field
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '#':
+			return fields
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(line[i+1:], c)
+			if end < 0 {
+				end = len(line)
+			} else {
+				end += i + 2
+			}
+			fields = append(fields, field{strings.Trim(line[i:end], string(c)), i, end})
+			i = end
+		default:
+			end := strings.IndexAny(line[i:], " \t#")
+			if end < 0 {
+				end = len(line)
+			} else {
+				end += i
+			}
+			fields = append(fields, field{line[i:end], i, end})
+			i = end
+		}
+	}
+	return fields
+}
+
+// fieldAt returns the index of the field containing col, or -1.
+func fieldAt(fields []field, col int) int {
+	for i, f := range fields {
+		if f.start <= col && col <= f.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// sectionTypeAt returns the type of the section the given line belongs
+// to, or "" if the line precedes the first section.
+func (d *document) sectionTypeAt(line int) string {
+	for n := line; n >= 0; n-- {
+		fields := splitLine(d.line(n))
+		if len(fields) >= 2 && fields[0].text == "config" {
+			return fields[1].text
+		}
+	}
+	return ""
+}
+
+// diagnostics parses the document and reports syntax errors, as well as
+// sections and options unknown to the schema. Malformed lines are skipped,
+// so that all errors are reported at once.
+func (d *document) diagnostics(s *schema.Schema) []Diagnostic {
+	diags := []Diagnostic{}
+
+	cfg, pos, errs := ast.ParseRecover(d.pkg, d.text)
+	for _, perr := range errs {
+		diags = append(diags, Diagnostic{
+			Range:    d.lineRange(perr.Pos.Line-1, perr.Pos.Column-1),
+			Severity: SeverityError,
+			Source:   "uci",
+			Message:  perr.Err.Error(),
+		})
+	}
+
+	pkg := s.Package(d.pkg)
+	if pkg == nil {
+		return diags
+	}
+	for _, sec := range cfg.Sections {
+		spec := pkg.Section(sec.Type)
+		if spec == nil {
+			p, _ := pos.Section(sec)
+			diags = append(diags, Diagnostic{
+				Range:    d.wordRange(p),
+				Severity: SeverityInformation,
+				Source:   "uci",
+				Message:  fmt.Sprintf("unknown section type %q in package %s", sec.Type, d.pkg),
+			})
+			continue
+		}
+		for _, opt := range sec.Options {
+			if spec.Option(opt.Name) != nil {
+				continue
+			}
+			p, _ := pos.Option(opt)
+			diags = append(diags, Diagnostic{
+				Range:    d.wordRange(p),
+				Severity: SeverityInformation,
+				Source:   "uci",
+				Message:  fmt.Sprintf("unknown option %q for section type %s", opt.Name, sec.Type),
+			})
+		}
+	}
+	return diags
+}
+
+// lineRange spans from the given byte column to the end of line.
+func (d *document) lineRange(line, col int) Range {
+	return Range{
+		Start: d.position(line, col),
+		End:   d.position(line, len(d.line(line))),
+	}
+}
+
+// wordRange spans the field starting at p.
+func (d *document) wordRange(p ast.Position) Range {
+	line, col := p.Line-1, p.Column-1
+	for _, f := range splitLine(d.line(line)) {
+		if f.start == col {
+			return Range{Start: d.position(line, f.start), End: d.position(line, f.end)}
+		}
+	}
+	return d.lineRange(line, col)
+}
+
+// hover describes the option name or section type at pos.
+func (d *document) hover(s *schema.Schema, pos Position) *Hover {
+	fields := splitLine(d.line(pos.Line))
+	if fieldAt(fields, d.offset(pos)) != 1 {
+		return nil
+	}
+
+	var text string
+	switch fields[0].text {
+	case "config":
+		pkg := s.Package(d.pkg)
+		if pkg == nil {
+			return nil
+		}
+		spec := pkg.Section(fields[1].text)
+		if spec == nil {
+			return nil
+		}
+		text = fmt.Sprintf("**%s** (%s)\n\n%s", spec.Type, d.pkg, spec.Description)
+
+	case "option", "list":
+		spec := s.Lookup(d.pkg, d.sectionTypeAt(pos.Line), fields[1].text)
+		if spec == nil {
+			return nil
+		}
+		text = describe(spec)
+
+	default:
+		return nil
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}}
+}
+
+func describe(spec *schema.Option) string {
+	typ := "option"
+	if spec.Type == ast.TypeList {
+		typ = "list"
+	}
+	text := fmt.Sprintf("**%s** (%s, %s)\n\n%s", spec.Name, typ, spec.Datatype, spec.Description)
+	if len(spec.Default) > 0 {
+		text += fmt.Sprintf("\n\nDefault: `%s`", strings.Join(spec.Default, " "))
+	}
+	return text
+}
+
+// complete suggests keywords, section types or option names at pos.
+func (d *document) complete(s *schema.Schema, pos Position) []CompletionItem {
+	line := d.line(pos.Line)[:d.offset(pos)]
+	fields := splitLine(line)
+
+	// index of the field being completed
+	n := len(fields)
+	if n > 0 && fields[n-1].end == len(line) {
+		n--
+	}
+
+	items := []CompletionItem{}
+	switch {
+	case n == 0:
+		for _, kw := range []string{"config", "option", "list"} {
+			items = append(items, CompletionItem{Label: kw, Kind: KindKeyword})
+		}
+
+	case n == 1 && fields[0].text == "config":
+		if pkg := s.Package(d.pkg); pkg != nil {
+			for _, sec := range pkg.Sections {
+				items = append(items, CompletionItem{
+					Label:  sec.Type,
+					Kind:   KindClass,
+					Detail: sec.Description,
+				})
+			}
+		}
+
+	case n == 1 && (fields[0].text == "option" || fields[0].text == "list"):
+		pkg := s.Package(d.pkg)
+		if pkg == nil {
+			break
+		}
+		sec := pkg.Section(d.sectionTypeAt(pos.Line - 1))
+		if sec == nil {
+			break
+		}
+		want := ast.TypeOption
+		if fields[0].text == "list" {
+			want = ast.TypeList
+		}
+		for _, opt := range sec.Options {
+			if opt.Type != want {
+				continue
+			}
+			items = append(items, CompletionItem{
+				Label:         opt.Name,
+				Kind:          KindProperty,
+				Detail:        opt.Datatype,
+				Documentation: &MarkupContent{Kind: "markdown", Value: describe(opt)},
+			})
+		}
+	}
+	return items
+}
+
+// reference returns the schema reference and value of the option value
+// at pos, if any.
+func (d *document) reference(s *schema.Schema, pos Position) (*schema.Reference, string) {
+	col := d.offset(pos)
+	fields := splitLine(d.line(pos.Line))
+	i := fieldAt(fields, col)
+	if i < 2 || fields[0].text != "option" && fields[0].text != "list" {
+		return nil, ""
+	}
+
+	spec := s.Lookup(d.pkg, d.sectionTypeAt(pos.Line), fields[1].text)
+	if spec == nil || spec.Ref == nil {
+		return nil, ""
+	}
+
+	// values may contain multiple space separated references
+	value := fields[i].text
+	offset := col - fields[i].start
+	if fields[i].end-fields[i].start > len(value) {
+		offset-- // opening quote
+	}
+	if offset < 0 || offset > len(value) {
+		return spec.Ref, value
+	}
+	start := strings.LastIndexByte(value[:offset], ' ') + 1
+	end := strings.IndexByte(value[offset:], ' ')
+	if end < 0 {
+		return spec.Ref, value[start:]
+	}
+	return spec.Ref, value[start : offset+end]
+}
+
+// format re-indents the document: sections start at column 0 and are
+// separated by one empty line, options (and comments preceding them)
+// are indented by one tab. Everything else, including comments, is
+// left untouched.
+func (d *document) format() string {
+	var out []string
+	blank := false     // an empty line is pending
+	inSection := false // we're past the first config line
+
+	for n := range d.lines {
+		raw := d.line(n)
+		if n > 0 && strings.HasSuffix(d.line(n-1), "\\") {
+			out = append(out, raw) // continuation of a multi-line value
+			continue
+		}
+
+		line := strings.TrimSpace(raw)
+		kw := ""
+		if fields := splitLine(line); len(fields) > 0 {
+			kw = fields[0].text
+		}
+
+		switch {
+		case line == "":
+			blank = len(out) > 0
+			continue
+		case kw == "config" || kw == "package":
+			// keep comments directly preceding the section attached
+			if last := len(out) - 1; last >= 0 && (blank || !strings.HasPrefix(out[last], "#")) {
+				out = append(out, "")
+			}
+			inSection = true
+		case kw == "option" || kw == "list":
+			line = "\t" + line
+		case strings.HasPrefix(line, "#"):
+			if inSection && d.commentsOption(n) {
+				line = "\t" + line
+			} else if blank {
+				out = append(out, "")
+			}
+		default:
+			if blank {
+				out = append(out, "")
+			}
+		}
+		blank = false
+		out = append(out, line)
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// commentsOption reports whether the comment block containing line n
+// is directly followed by an option or list.
+func (d *document) commentsOption(n int) bool {
+	for ; n < len(d.lines); n++ {
+		line := strings.TrimSpace(d.line(n))
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitLine(line)
+		return len(fields) > 0 && (fields[0].text == "option" || fields[0].text == "list")
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wsiner/go-uci/schema"
+)
+
+const tcFirewall = `config zone
+	option name 'lan'
+	list network 'lan'
+	option input 'ACCEPT'
+	option bogus '1'
+
+config forwarding
+	option src 'lan'
+	option dest 'wan'
+
+config zone
+	option name 'wan'
+	list network 'wan wan6'
+`
+
+func TestSplitLine(t *testing.T) {
+	assert.Equal(t, []field{
+		{"option", 1, 7},
+		{"name", 8, 12},
+		{"a b", 13, 18},
+	}, splitLine("\toption name 'a b' # comment"))
+
+	assert.Equal(t, []field{
+		{"list", 0, 4},
+		{"x", 5, 6},
+		{"unterminated", 7, 20},
+	}, splitLine(`list x "unterminated`))
+}
+
+func TestDiagnostics(t *testing.T) {
+	doc := newDocument("file:///etc/config/firewall", tcFirewall)
+	diags := doc.diagnostics(schema.Default)
+	require.Len(t, diags, 1)
+	assert.Equal(t, SeverityInformation, diags[0].Severity)
+	assert.Equal(t, Range{Start: Position{4, 8}, End: Position{4, 13}}, diags[0].Range)
+	assert.Equal(t, `unknown option "bogus" for section type zone`, diags[0].Message)
+
+	doc = newDocument("file:///etc/config/firewall", "config zone\n\toption name 'lan\n")
+	diags = doc.diagnostics(schema.Default)
+	require.Len(t, diags, 1)
+	assert.Equal(t, SeverityError, diags[0].Severity)
+	assert.Equal(t, Range{Start: Position{1, 13}, End: Position{1, 17}}, diags[0].Range)
+	assert.Equal(t, "parse error: unterminated quoted string", diags[0].Message)
+
+	// all syntax errors are reported, and the rest is still checked
+	doc = newDocument("file:///etc/config/firewall", "config zone\n\toption name\n\toption bogus 'x'\n\toption input 'ACCEPT\n")
+	diags = doc.diagnostics(schema.Default)
+	require.Len(t, diags, 3)
+	assert.Equal(t, SeverityError, diags[0].Severity)
+	assert.Equal(t, 2, diags[0].Range.Start.Line)
+	assert.Equal(t, SeverityError, diags[1].Severity)
+	assert.Equal(t, 3, diags[1].Range.Start.Line)
+	assert.Equal(t, `unknown option "bogus" for section type zone`, diags[2].Message)
+
+	// unknown packages are only checked for syntax errors
+	doc = newDocument("file:///etc/config/custom", tcFirewall)
+	assert.Empty(t, doc.diagnostics(schema.Default))
+}
+
+func TestPositionsUTF16(t *testing.T) {
+	// "ü" is 2 bytes, but 1 UTF-16 code unit, "😀" is 4 bytes, but 2
+	doc := newDocument("file:///etc/config/firewall", "config zone 'küche'\n\toption name '😀' # ü\n\toption bogus '1'\n")
+	assert.Equal(t, 16, doc.offset(Position{0, 15}))
+	assert.Equal(t, Position{0, 15}, doc.position(0, 16))
+	assert.Equal(t, 19, doc.offset(Position{1, 17}))
+	assert.Equal(t, 24, doc.offset(Position{1, 99}))
+
+	h := doc.hover(schema.Default, Position{1, 8})
+	if assert.NotNil(t, h) {
+		assert.Contains(t, h.Contents.Value, "**name** (option")
+	}
+
+	doc = newDocument("file:///etc/config/firewall", "config zone\n\toption name 'wän\n")
+	diags := doc.diagnostics(schema.Default)
+	require.Len(t, diags, 1)
+	assert.Equal(t, Range{Start: Position{1, 13}, End: Position{1, 17}}, diags[0].Range)
+}
+
+func TestHover(t *testing.T) {
+	doc := newDocument("file:///etc/config/firewall", tcFirewall)
+
+	h := doc.hover(schema.Default, Position{3, 10})
+	if assert.NotNil(t, h) {
+		assert.Contains(t, h.Contents.Value, "**input** (option")
+		assert.Contains(t, h.Contents.Value, "Policy for incoming traffic")
+	}
+
+	h = doc.hover(schema.Default, Position{0, 8})
+	if assert.NotNil(t, h) {
+		assert.Contains(t, h.Contents.Value, "**zone** (firewall)")
+	}
+
+	assert.Nil(t, doc.hover(schema.Default, Position{3, 2}))  // keyword
+	assert.Nil(t, doc.hover(schema.Default, Position{3, 18})) // value
+	assert.Nil(t, doc.hover(schema.Default, Position{4, 10})) // unknown option
+}
+
+func TestComplete(t *testing.T) {
+	doc := newDocument("file:///etc/config/firewall", "config zone\n\tlist \n\toption ma\nconfig \n")
+
+	labels := func(items []CompletionItem) []string {
+		res := make([]string, 0, len(items))
+		for _, it := range items {
+			res = append(res, it.Label)
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"network", "device", "subnet"}, labels(doc.complete(schema.Default, Position{1, 6})))
+	assert.Contains(t, labels(doc.complete(schema.Default, Position{2, 10})), "masq")
+	assert.Contains(t, labels(doc.complete(schema.Default, Position{3, 7})), "redirect")
+	assert.Equal(t, []string{"config", "option", "list"}, labels(doc.complete(schema.Default, Position{4, 0})))
+}
+
+func TestReference(t *testing.T) {
+	doc := newDocument("file:///etc/config/firewall", tcFirewall)
+
+	ref, value := doc.reference(schema.Default, Position{2, 16})
+	if assert.NotNil(t, ref) {
+		assert.Equal(t, "network", ref.Package)
+		assert.Equal(t, "lan", value)
+	}
+
+	ref, value = doc.reference(schema.Default, Position{7, 14})
+	if assert.NotNil(t, ref) {
+		assert.Equal(t, "zone", ref.SectionType)
+		assert.Equal(t, "name", ref.Key)
+		assert.Equal(t, "lan", value)
+	}
+
+	_, value = doc.reference(schema.Default, Position{12, 16})
+	assert.Equal(t, "wan", value)
+	_, value = doc.reference(schema.Default, Position{12, 20})
+	assert.Equal(t, "wan6", value)
+
+	ref, _ = doc.reference(schema.Default, Position{1, 15})
+	assert.Nil(t, ref)
+}
+
+func TestFormat(t *testing.T) {
+	doc := newDocument("file:///etc/config/system", `
+
+# heading
+config system
+option hostname 'foo'   
+
+    # the timezone
+  option timezone 'UTC'
+config timeserver 'ntp'
+		list server 'a'
+  list server 'b'
+
+
+# trailing comment
+`)
+	assert.Equal(t, `# heading
+config system
+	option hostname 'foo'
+	# the timezone
+	option timezone 'UTC'
+
+config timeserver 'ntp'
+	list server 'a'
+	list server 'b'
+
+# trailing comment
+`, doc.format())
+
+	formatted := newDocument("file:///etc/config/system", doc.format())
+	assert.Equal(t, formatted.text, formatted.format())
+}
@@ -0,0 +1,337 @@
+// Package lsp implements a Language Server Protocol server for UCI
+// files. It offers
+//
+//   - diagnostics for syntax errors, unknown section types and options,
+//   - completion of keywords, section types and option names,
+//   - hover documentation for section types and options,
+//   - go to definition for options referencing other sections (e.g. a
+//     firewall zone's network list), and
+//   - formatting (re-indentation, preserving comments).
+//
+// Section and option descriptions come from a schema.Schema. The config
+// name is derived from the document's file name, referenced configs are
+// looked up in open documents first, then in the document's directory.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wsiner/go-uci/ast"
+	"github.com/wsiner/go-uci/schema"
+)
+
+// maxMessageSize limits the Content-Length of messages, so that a
+// broken client can't make the server allocate unbounded memory.
+const maxMessageSize = 64 << 20
+
+// Server is a language server communicating over a pair of streams
+// (usually stdin and stdout).
+type Server struct {
+	schema *schema.Schema
+	in     *bufio.Reader
+	out    io.Writer
+	docs   map[string]*document
+
+	sync.Mutex // guards out
+}
+
+// NewServer creates a server reading requests from r and writing
+// responses to w. If s is nil, schema.Default is used.
+func NewServer(r io.Reader, w io.Writer, s *schema.Schema) *Server {
+	if s == nil {
+		s = schema.Default
+	}
+	return &Server{
+		schema: s,
+		in:     bufio.NewReader(r),
+		out:    w,
+		docs:   make(map[string]*document),
+	}
+}
+
+var errExit = errors.New("exit")
+
+// Serve handles requests until the client sends an exit notification
+// (then Serve returns nil), or the input stream is closed.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.read()
+		if err != nil {
+			return err
+		}
+		if err = s.handle(msg); errors.Is(err, errExit) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// read reads a single message. Messages are framed with HTTP-like
+// headers, of which only Content-Length is relevant.
+func (s *Server) read() (*message, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+	if length < 0 || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid Content-Length: %d", length)
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	msg := &message{}
+	if err = json.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *Server) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if _, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(body)
+	return err
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&message{Method: method, Params: b})
+}
+
+func (s *Server) handle(msg *message) error {
+	result, rerr := s.dispatch(msg)
+	if errors.Is(rerr, errExit) {
+		return errExit
+	}
+	if msg.ID == nil {
+		return nil // notification
+	}
+
+	resp := &message{ID: msg.ID}
+	if rerr != nil {
+		code := codeInvalidParams
+		var merr methodNotFound
+		if errors.As(rerr, &merr) {
+			code = codeMethodNotFound
+		}
+		resp.Error = &responseError{Code: code, Message: rerr.Error()}
+	} else {
+		resp.Result = result
+		if result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+	}
+	return s.write(resp)
+}
+
+type methodNotFound string
+
+func (m methodNotFound) Error() string {
+	return fmt.Sprintf("method not found: %s", string(m))
+}
+
+func (s *Server) dispatch(msg *message) (interface{}, error) { //nolint:cyclop
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1, // full
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"documentFormattingProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{" "},
+				},
+			},
+			"serverInfo": map[string]string{"name": "go-uci"},
+		}, nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+
+	case "shutdown":
+		return nil, nil
+
+	case "exit":
+		return nil, errExit
+
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			return nil, s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/hover":
+		doc, p, err := s.position(msg)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		if h := doc.hover(s.schema, p.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+
+	case "textDocument/completion":
+		doc, p, err := s.position(msg)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.complete(s.schema, p.Position), nil
+
+	case "textDocument/definition":
+		doc, p, err := s.position(msg)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		if loc := s.definition(doc, p.Position); loc != nil {
+			return loc, nil
+		}
+		return nil, nil
+
+	case "textDocument/formatting":
+		var p formattingParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		doc := s.docs[p.TextDocument.URI]
+		if doc == nil {
+			return nil, nil
+		}
+		formatted := doc.format()
+		if formatted == doc.text {
+			return []TextEdit{}, nil
+		}
+		return []TextEdit{{
+			Range:   Range{End: Position{Line: len(doc.lines)}},
+			NewText: formatted,
+		}}, nil
+	}
+
+	if strings.HasPrefix(msg.Method, "$/") || msg.ID == nil {
+		return nil, nil // optional notifications can be ignored
+	}
+	return nil, methodNotFound(msg.Method)
+}
+
+// update stores the document's content and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics(s.schema),
+	})
+}
+
+func (s *Server) position(msg *message) (*document, *textDocumentPositionParams, error) {
+	var p textDocumentPositionParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		return nil, nil, err
+	}
+	return s.docs[p.TextDocument.URI], &p, nil
+}
+
+// definition resolves the reference at pos to the referenced section.
+func (s *Server) definition(doc *document, pos Position) *Location {
+	ref, value := doc.reference(s.schema, pos)
+	if ref == nil || value == "" {
+		return nil
+	}
+
+	uri := siblingURI(doc.uri, ref.Package)
+	var text string
+	if target, ok := s.docs[uri]; ok {
+		text = target.text
+	} else {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil
+		}
+		b, err := ioutil.ReadFile(u.Path)
+		if err != nil {
+			return nil
+		}
+		text = string(b)
+	}
+
+	cfg, positions, err := ast.ParsePositions(ref.Package, text)
+	if err != nil {
+		return nil
+	}
+	for _, sec := range cfg.Sections {
+		if sec.Type != ref.SectionType {
+			continue
+		}
+		if ref.Key == "" && sec.Name != value || ref.Key != "" && sec.LastValue(ref.Key) != value {
+			continue
+		}
+		p, ok := positions.Section(sec)
+		if !ok {
+			return nil
+		}
+		line := Position{Line: p.Line - 1}
+		return &Location{URI: uri, Range: Range{Start: line, End: line}}
+	}
+	return nil
+}
+
+// siblingURI returns the URI of the named file in the same directory
+// as the file referenced by uri.
+func siblingURI(uri, name string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Path == "" {
+		return path.Join(path.Dir(uri), name)
+	}
+	u.Path = path.Join(path.Dir(u.Path), name)
+	return u.String()
+}
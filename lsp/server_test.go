@@ -0,0 +1,138 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client talks to a Server over a pair of pipes.
+type client struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	seq int
+}
+
+func (c *client) send(method string, params interface{}, request bool) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if request {
+		c.seq++
+		msg["id"] = c.seq
+	}
+	body, err := json.Marshal(msg)
+	require.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	require.NoError(c.t, err)
+}
+
+func (c *client) recv() map[string]interface{} {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	require.NoError(c.t, err)
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	require.NoError(c.t, err)
+	body := make([]byte, n)
+	_, err = io.ReadFull(c.r, body)
+	require.NoError(c.t, err)
+
+	var msg map[string]interface{}
+	require.NoError(c.t, json.Unmarshal(body, &msg))
+	return msg
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uci-lsp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	network := "config interface 'loopback'\n\toption proto 'static'\n\nconfig interface 'lan'\n\toption proto 'dhcp'\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "network"), []byte(network), 0o644))
+	uri := "file://" + filepath.Join(dir, "firewall")
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	srv := NewServer(inR, outW, nil)
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve() }()
+
+	c := &client{t: t, w: inW, r: bufio.NewReader(outR)}
+
+	c.send("initialize", map[string]interface{}{}, true)
+	resp := c.recv()
+	assert.EqualValues(t, 1, resp["id"])
+	assert.Contains(t, resp["result"], "capabilities")
+
+	c.send("initialized", map[string]interface{}{}, false)
+	c.send("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "text": tcFirewall},
+	}, false)
+	notification := c.recv()
+	assert.Equal(t, "textDocument/publishDiagnostics", notification["method"])
+	assert.Len(t, notification["params"].(map[string]interface{})["diagnostics"], 1)
+
+	position := func(line, char int) map[string]interface{} {
+		return map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"position":     map[string]interface{}{"line": line, "character": char},
+		}
+	}
+
+	c.send("textDocument/definition", position(2, 16), true)
+	resp = c.recv()
+	assert.Equal(t, map[string]interface{}{
+		"uri": "file://" + filepath.Join(dir, "network"),
+		"range": map[string]interface{}{
+			"start": map[string]interface{}{"line": 3.0, "character": 0.0},
+			"end":   map[string]interface{}{"line": 3.0, "character": 0.0},
+		},
+	}, resp["result"])
+
+	// zones are referenced by name, within the same document
+	c.send("textDocument/definition", position(8, 15), true)
+	resp = c.recv()
+	if loc, ok := resp["result"].(map[string]interface{}); assert.True(t, ok) {
+		assert.Equal(t, uri, loc["uri"])
+		assert.EqualValues(t, 10, loc["range"].(map[string]interface{})["start"].(map[string]interface{})["line"])
+	}
+
+	c.send("textDocument/hover", position(3, 10), true)
+	resp = c.recv()
+	assert.Contains(t, resp["result"], "contents")
+
+	c.send("textDocument/formatting", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+	}, true)
+	resp = c.recv()
+	assert.Equal(t, []interface{}{}, resp["result"])
+
+	c.send("textDocument/unknown", map[string]interface{}{}, true)
+	resp = c.recv()
+	assert.EqualValues(t, codeMethodNotFound, resp["error"].(map[string]interface{})["code"])
+
+	c.send("shutdown", nil, true)
+	resp = c.recv()
+	assert.Contains(t, resp, "result")
+	assert.Nil(t, resp["result"])
+
+	c.send("exit", nil, false)
+	assert.NoError(t, <-done)
+}
+
+func TestServerInvalidLength(t *testing.T) {
+	for _, length := range []string{"-1", "x", strconv.Itoa(maxMessageSize + 1)} {
+		in := strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")
+		err := NewServer(in, ioutil.Discard, nil).Serve()
+		assert.ErrorContains(t, err, "invalid Content-Length", length)
+	}
+}
@@ -0,0 +1,299 @@
+// Package luci implements the uci methods of LuCI's JSON-RPC API
+// (luci-mod-rpc, usually served at /cgi-bin/luci/rpc/uci), backed by a
+// uci.Tree. Frontends written for that API can use it as a drop-in
+// backend:
+//
+//	http.Handle("/cgi-bin/luci/rpc/uci", luci.NewHandler(tree))
+//
+// Requests are JSON-RPC 1.0 style objects, with positional parameters:
+//
+//	{"id": 1, "method": "get", "params": ["network", "lan", "proto"]}
+//
+// The supported methods are get_all, get, set, delete, add and commit,
+// with the semantics of the Lua uci cursor. Unnamed sections are
+// identified by their "@type[index]" selector (where uci uses IDs like
+// cfg0a1b2c), which all methods accept in turn. As the tree doesn't
+// track changes per config, commit writes all changed configs.
+//
+// The handler does no authentication; LuCI's "auth" query parameter is
+// ignored. Wrap the handler to restrict access.
+package luci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wsiner/go-uci"
+)
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+var errInvalidParams = errors.New("invalid params")
+
+type handler struct {
+	tree uci.Tree
+}
+
+// NewHandler returns an HTTP handler serving the JSON-RPC API for t.
+func NewHandler(t uci.Tree) http.Handler {
+	return &handler{tree: t}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req request
+	resp := response{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error = &responseError{Code: codeParseError, Message: "Parse error."}
+	} else {
+		resp.ID = req.ID
+		resp.Result, resp.Error = h.call(&req)
+	}
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// call dispatches a request, and returns its result or error.
+func (h *handler) call(req *request) (interface{}, *responseError) { //nolint:cyclop
+	var method func(args []string, value []string) (interface{}, error)
+	minArgs, maxArgs := 1, 3
+	switch req.Method {
+	case "get_all":
+		method, maxArgs = h.getAll, 2
+	case "get":
+		method, minArgs = h.get, 2
+	case "set":
+		// set(config, section, type) or set(config, section, option, value)
+		method, minArgs, maxArgs = h.set, 3, 4
+	case "delete":
+		method, minArgs = h.delete, 2
+	case "add":
+		method, minArgs, maxArgs = h.add, 2, 2
+	case "commit":
+		method, maxArgs = h.commit, 1
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: "Method not found."}
+	}
+
+	if len(req.Params) < minArgs || len(req.Params) > maxArgs {
+		return nil, &responseError{Code: codeInvalidParams, Message: "Invalid params."}
+	}
+	args := make([]string, 0, len(req.Params))
+	var value []string
+	for i, raw := range req.Params {
+		values, err := decodeValue(raw)
+		if i == 3 && err == nil && len(values) > 0 {
+			value = values
+			continue
+		}
+		var arg string
+		if err := json.Unmarshal(raw, &arg); err != nil || arg == "" {
+			return nil, &responseError{Code: codeInvalidParams, Message: "Invalid params."}
+		}
+		args = append(args, arg)
+	}
+	if !uci.ValidConfigName(args[0]) {
+		return nil, &responseError{Code: codeInvalidParams, Message: "Invalid params."}
+	}
+
+	result, err := method(args, value)
+	if err != nil {
+		return nil, &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// decodeValue decodes a parameter: a string, number or boolean (as in
+// Lua, converted to a string), or an array of them (a list value).
+func decodeValue(raw json.RawMessage) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	if list, ok := v.([]interface{}); ok {
+		values := make([]string, len(list))
+		for i, item := range list {
+			s, err := scalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = s
+		}
+		return values, nil
+	}
+	s, err := scalar(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func scalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	return "", fmt.Errorf("%w: %v", errInvalidParams, v)
+}
+
+// getAll returns a section table, or all section tables of a config,
+// keyed by section name. Missing configs and sections yield null.
+func (h *handler) getAll(args, _ []string) (interface{}, error) {
+	cfg, ok := h.tree.CopyConfig(args[0])
+	if !ok {
+		return nil, nil
+	}
+	if len(args) == 2 {
+		sec := cfg.Get(args[1])
+		for i, s := range cfg.Sections {
+			if s == sec {
+				return sectionTable(cfg, sec, i), nil
+			}
+		}
+		return nil, nil
+	}
+	all := make(map[string]interface{}, len(cfg.Sections))
+	for i, sec := range cfg.Sections {
+		all[cfg.SectionName(sec)] = sectionTable(cfg, sec, i)
+	}
+	return all, nil
+}
+
+// sectionTable converts sec, the index-th section of cfg, the way the
+// Lua uci cursor does: options map to strings (or arrays, for lists),
+// and the meta data is stored in keys starting with a dot.
+func sectionTable(cfg *uci.Config, sec *uci.Section, index int) map[string]interface{} {
+	table := map[string]interface{}{
+		".name":      cfg.SectionName(sec),
+		".type":      sec.Type,
+		".anonymous": sec.Name == "",
+		".index":     index,
+	}
+	for _, opt := range sec.Options {
+		if opt.Type == uci.TypeList {
+			table[opt.Name] = opt.Values
+		} else if len(opt.Values) > 0 {
+			table[opt.Name] = opt.Values[len(opt.Values)-1]
+		}
+	}
+	return table
+}
+
+// get returns the value of an option, or the type of a section, if no
+// option is given. Missing values yield null.
+func (h *handler) get(args, _ []string) (interface{}, error) {
+	cfg, ok := h.tree.CopyConfig(args[0])
+	if !ok {
+		return nil, nil
+	}
+	sec := cfg.Get(args[1])
+	if sec == nil {
+		return nil, nil
+	}
+	if len(args) == 2 {
+		return sec.Type, nil
+	}
+	opt := sec.Get(args[2])
+	switch {
+	case opt == nil || len(opt.Values) == 0:
+		return nil, nil
+	case opt.Type == uci.TypeList:
+		return opt.Values, nil
+	}
+	return opt.Values[len(opt.Values)-1], nil
+}
+
+// set creates a named section, or sets an option (arrays set lists),
+// and reports whether that worked.
+func (h *handler) set(args, value []string) (interface{}, error) {
+	if value == nil {
+		return h.tree.AddSection(args[0], args[1], args[2]) == nil, nil
+	}
+	if len(value) > 1 {
+		return h.tree.SetType(args[0], args[1], args[2], uci.TypeList, value...), nil
+	}
+	return h.tree.SetType(args[0], args[1], args[2], uci.TypeOption, value...), nil
+}
+
+// delete removes an option, or a section, if no option is given, and
+// reports whether it existed.
+func (h *handler) delete(args, _ []string) (interface{}, error) {
+	cfg, ok := h.tree.CopyConfig(args[0])
+	if !ok {
+		return false, nil
+	}
+	sec := cfg.Get(args[1])
+	if sec == nil {
+		return false, nil
+	}
+	if len(args) == 2 {
+		h.tree.DelSection(args[0], args[1])
+		// selectors of later sections shift, so count the sections
+		after, ok := h.tree.CopyConfig(args[0])
+		return ok && len(after.Sections) < len(cfg.Sections), nil
+	}
+	if sec.Get(args[2]) == nil {
+		return false, nil
+	}
+	h.tree.Del(args[0], args[1], args[2])
+	_, err := h.tree.Lookup(args[0], args[1], args[2])
+	return err != nil, nil
+}
+
+// add appends an unnamed section, and returns its selector.
+func (h *handler) add(args, _ []string) (interface{}, error) {
+	if _, ok := h.tree.CopyConfig(args[0]); !ok {
+		return nil, nil
+	}
+	return h.tree.AddUnnamedSection(args[0], args[1])
+}
+
+// commit writes all changed configs.
+func (h *handler) commit(_, _ []string) (interface{}, error) {
+	if err := h.tree.Commit(); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
@@ -0,0 +1,159 @@
+package luci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci"
+)
+
+const tcNetwork = `
+config interface 'lan'
+	option proto 'static'
+	list ipaddr '192.168.1.1/24'
+
+config route
+	option interface 'lan'
+	option target '10.0.0.0/8'
+`
+
+func call(t *testing.T, h http.Handler, body string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cgi-bin/luci/rpc/uci", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	store := uci.NewMemoryStore(map[string]string{"network": tcNetwork})
+	h := NewHandler(uci.NewStoreTree(store))
+
+	for _, tc := range []struct {
+		req  string
+		want interface{}
+	}{
+		{`{"id":1,"method":"get","params":["network","lan","proto"]}`, "static"},
+		{`{"id":1,"method":"get","params":["network","lan","ipaddr"]}`, []interface{}{"192.168.1.1/24"}},
+		{`{"id":1,"method":"get","params":["network","@route[0]"]}`, "route"},
+		{`{"id":1,"method":"get","params":["network","lan","missing"]}`, nil},
+		{`{"id":1,"method":"get","params":["missing","lan"]}`, nil},
+		{`{"id":1,"method":"get_all","params":["network","@route[0]"]}`, map[string]interface{}{
+			".name": "@route[0]", ".type": "route", ".anonymous": true, ".index": float64(1),
+			"interface": "lan", "target": "10.0.0.0/8",
+		}},
+		{`{"id":1,"method":"set","params":["network","lan","proto","dhcp"]}`, true},
+		{`{"id":1,"method":"set","params":["network","lan","dns",["1.1.1.1",9]]}`, true},
+		{`{"id":1,"method":"set","params":["network","missing","proto","dhcp"]}`, false},
+		{`{"id":1,"method":"set","params":["network","wan","interface"]}`, true},
+		{`{"id":1,"method":"set","params":["network","lan","route"]}`, false},
+		{`{"id":1,"method":"add","params":["network","route"]}`, "@route[1]"},
+		{`{"id":1,"method":"delete","params":["network","lan","ipaddr"]}`, true},
+		{`{"id":1,"method":"delete","params":["network","lan","ipaddr"]}`, false},
+		{`{"id":1,"method":"delete","params":["network","@route[0]"]}`, true},
+		{`{"id":1,"method":"commit","params":["network"]}`, true},
+		{`{"id":1,"method":"get","params":["network","lan","dns"]}`, []interface{}{"1.1.1.1", "9"}},
+	} {
+		resp := call(t, h, tc.req)
+		assert.Nil(resp["error"], tc.req)
+		assert.Equal(tc.want, resp["result"], tc.req)
+		assert.Equal(float64(1), resp["id"], tc.req)
+	}
+
+	all := call(t, h, `{"id":"x","method":"get_all","params":["network"]}`)
+	assert.Equal("x", all["id"])
+	assert.Len(all["result"], 3)
+	assert.Contains(all["result"], "wan")
+	assert.Contains(all["result"], "@route[0]")
+
+	body, err := store.Read(context.Background(), "network")
+	require.NoError(t, err)
+	assert.Equal(`
+config interface 'lan'
+	option proto 'dhcp'
+	list dns '1.1.1.1'
+	list dns '9'
+
+config interface 'wan'
+
+config route
+
+`, string(body))
+}
+
+func TestHandlerErrors(t *testing.T) {
+	assert := assert.New(t)
+	h := NewHandler(uci.NewStoreTree(uci.NewMemoryStore(nil)))
+
+	for _, tc := range []struct {
+		req  string
+		code float64
+	}{
+		{`{"id":1,"method":"get"`, -32700},
+		{`{"id":1,"method":"reboot","params":[]}`, -32601},
+		{`{"id":1,"method":"get","params":["network"]}`, -32602},
+		{`{"id":1,"method":"get","params":["network",{"a":1}]}`, -32602},
+		{`{"id":1,"method":"get","params":["network",["lan"]]}`, -32602},
+		{`{"id":1,"method":"add","params":["network","route","x"]}`, -32602},
+		{`{"id":1,"method":"get","params":["../secret","k","pw"]}`, -32602},
+		{`{"id":1,"method":"set","params":["../secret","k","pw","x"]}`, -32602},
+	} {
+		resp := call(t, h, tc.req)
+		assert.Nil(resp["result"], tc.req)
+		require.IsType(t, map[string]interface{}{}, resp["error"], tc.req)
+		assert.Equal(tc.code, resp["error"].(map[string]interface{})["code"], tc.req)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandlerConcurrent(t *testing.T) {
+	h := NewHandler(uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"network": tcNetwork})))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			call(t, h, `{"id":1,"method":"add","params":["network","route"]}`)
+		}()
+		go func() {
+			defer wg.Done()
+			call(t, h, `{"id":1,"method":"set","params":["network","lan","proto","dhcp"]}`)
+			call(t, h, `{"id":1,"method":"get_all","params":["network"]}`)
+		}()
+	}
+	wg.Wait()
+	all := call(t, h, `{"id":1,"method":"get_all","params":["network"]}`)
+	assert.Len(t, all["result"], 12)
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "network"), []byte(tcNetwork), 0o644))
+	h := NewHandler(uci.NewTree(dir, uci.WithReadOnly()))
+
+	resp := call(t, h, `{"id":1,"method":"add","params":["network","route"]}`)
+	assert.Nil(t, resp["result"])
+	assert.NotNil(t, resp["error"])
+	resp = call(t, h, `{"id":1,"method":"get","params":["network","@route[1]"]}`)
+	assert.Nil(t, resp["result"])
+}
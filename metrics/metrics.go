@@ -0,0 +1,153 @@
+// Package metrics exposes Prometheus metrics of a uci.Tree, so that
+// daemons managing configs can be monitored for configuration churn:
+//
+//	prometheus.MustRegister(metrics.NewCollector(tree))
+//
+// The collector reports the duration and failures of loading configs,
+// commits and their failures, changes made through Set and Del (as seen
+// by OnSet and OnDelete hooks), and the size and taint state of every
+// loaded config.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wsiner/go-uci"
+)
+
+const namespace = "uci"
+
+// Collector is a prometheus.Collector for a tree.
+type Collector struct {
+	tree uci.Tree
+
+	loads          *prometheus.HistogramVec
+	loadFailures   *prometheus.CounterVec
+	commits        prometheus.Counter
+	commitFailures prometheus.Counter
+	commitDuration prometheus.Histogram
+	committed      *prometheus.CounterVec
+	changes        *prometheus.CounterVec
+
+	sections *prometheus.Desc
+	options  *prometheus.Desc
+	values   *prometheus.Desc
+	tainted  *prometheus.Desc
+}
+
+// NewCollector returns a collector for t. It registers hooks with t
+// (see uci.Tree.OnLoad, OnCommitDone, OnSet and OnDelete), which never
+// reject anything.
+func NewCollector(t uci.Tree) *Collector {
+	c := &Collector{
+		tree: t,
+		loads: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "load_duration_seconds",
+			Help:      "Time taken to read and parse configs.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"config"}),
+		loadFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "load_failures_total",
+			Help:      "Number of configs which failed to load.",
+		}, []string{"config"}),
+		commits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commits_total",
+			Help:      "Number of commits.",
+		}),
+		commitFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commit_failures_total",
+			Help:      "Number of failed commits.",
+		}),
+		commitDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "commit_duration_seconds",
+			Help:      "Time taken by commits, including hooks.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+		committed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_commits_total",
+			Help:      "Number of commits including changes of a config.",
+		}, []string{"config"}),
+		changes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "change_events_total",
+			Help:      "Number of changes requested through Set and Del, by kind (set or delete).",
+		}, []string{"config", "kind"}),
+		sections: prometheus.NewDesc(namespace+"_config_sections",
+			"Number of sections of a loaded config.", []string{"config"}, nil),
+		options: prometheus.NewDesc(namespace+"_config_options",
+			"Number of options of a loaded config.", []string{"config"}, nil),
+		values: prometheus.NewDesc(namespace+"_config_values",
+			"Number of option values of a loaded config.", []string{"config"}, nil),
+		tainted: prometheus.NewDesc(namespace+"_config_tainted",
+			"Whether a loaded config has uncommitted changes.", []string{"config"}, nil),
+	}
+
+	t.OnLoad(func(e uci.LoadEvent) {
+		if e.Err != nil {
+			c.loadFailures.WithLabelValues(e.Config).Inc()
+			return
+		}
+		c.loads.WithLabelValues(e.Config).Observe(e.Duration.Seconds())
+	})
+	t.OnCommitDone(func(e uci.CommitDoneEvent) {
+		c.commits.Inc()
+		c.commitDuration.Observe(e.Duration.Seconds())
+		if e.Err != nil {
+			c.commitFailures.Inc()
+			return
+		}
+		for _, config := range e.Configs {
+			c.committed.WithLabelValues(config).Inc()
+		}
+	})
+	t.OnSet(func(e *uci.SetEvent) error {
+		c.changes.WithLabelValues(e.Config, "set").Inc()
+		return nil
+	})
+	t.OnDelete(func(e uci.DeleteEvent) error {
+		c.changes.WithLabelValues(e.Config, "delete").Inc()
+		return nil
+	})
+	return c
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.loads.Describe(ch)
+	c.loadFailures.Describe(ch)
+	c.commits.Describe(ch)
+	c.commitFailures.Describe(ch)
+	c.commitDuration.Describe(ch)
+	c.committed.Describe(ch)
+	c.changes.Describe(ch)
+	ch <- c.sections
+	ch <- c.options
+	ch <- c.values
+	ch <- c.tainted
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.loads.Collect(ch)
+	c.loadFailures.Collect(ch)
+	c.commits.Collect(ch)
+	c.commitFailures.Collect(ch)
+	c.commitDuration.Collect(ch)
+	c.committed.Collect(ch)
+	c.changes.Collect(ch)
+
+	for _, s := range c.tree.Stats() {
+		tainted := 0.0
+		if s.Tainted {
+			tainted = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.sections, prometheus.GaugeValue, float64(s.Sections), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.options, prometheus.GaugeValue, float64(s.Options), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.values, prometheus.GaugeValue, float64(s.Values), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.tainted, prometheus.GaugeValue, tainted, s.Name)
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci"
+)
+
+const tcSystem = `
+config system
+	option hostname 'OpenWrt'
+	list ntp '0.openwrt.pool.ntp.org'
+	list ntp '1.openwrt.pool.ntp.org'
+
+config led 'wan'
+	option trigger 'netdev'
+`
+
+func TestCollector(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"system": tcSystem}))
+	c := NewCollector(tree)
+
+	require.NoError(tree.LoadConfig("system", false))
+	require.Error(tree.LoadConfig("missing", false))
+	assert.True(tree.Set("system", "wan", "mode", "tx"))
+	tree.Del("system", "wan", "trigger")
+
+	require.NoError(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP uci_change_events_total Number of changes requested through Set and Del, by kind (set or delete).
+# TYPE uci_change_events_total counter
+uci_change_events_total{config="system",kind="delete"} 1
+uci_change_events_total{config="system",kind="set"} 1
+# HELP uci_config_options Number of options of a loaded config.
+# TYPE uci_config_options gauge
+uci_config_options{config="system"} 3
+# HELP uci_config_sections Number of sections of a loaded config.
+# TYPE uci_config_sections gauge
+uci_config_sections{config="system"} 2
+# HELP uci_config_tainted Whether a loaded config has uncommitted changes.
+# TYPE uci_config_tainted gauge
+uci_config_tainted{config="system"} 1
+# HELP uci_config_values Number of option values of a loaded config.
+# TYPE uci_config_values gauge
+uci_config_values{config="system"} 4
+# HELP uci_load_failures_total Number of configs which failed to load.
+# TYPE uci_load_failures_total counter
+uci_load_failures_total{config="missing"} 1
+`), "uci_change_events_total", "uci_config_options", "uci_config_sections",
+		"uci_config_tainted", "uci_config_values", "uci_load_failures_total"))
+	assert.Equal(1, testutil.CollectAndCount(c, "uci_load_duration_seconds"))
+
+	require.NoError(tree.CommitContext(context.Background()))
+	assert.Equal(1.0, testutil.ToFloat64(c.commits))
+	assert.Equal(0.0, testutil.ToFloat64(c.commitFailures))
+	assert.Equal(1.0, testutil.ToFloat64(c.committed.WithLabelValues("system")))
+	assert.NoError(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP uci_config_tainted Whether a loaded config has uncommitted changes.
+# TYPE uci_config_tainted gauge
+uci_config_tainted{config="system"} 0
+`), "uci_config_tainted"))
+
+	tree.OnPreCommit(func(*uci.PackageEvent) error { return errors.New("vetoed") })
+	assert.True(tree.Set("system", "wan", "mode", "rx"))
+	require.Error(tree.CommitContext(context.Background()))
+	assert.Equal(2.0, testutil.ToFloat64(c.commits))
+	assert.Equal(1.0, testutil.ToFloat64(c.commitFailures))
+	assert.Equal(1.0, testutil.ToFloat64(c.committed.WithLabelValues("system")))
+}
@@ -0,0 +1,158 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A Migration replaces a deprecated option of a section type with its
+// successor. See Tree.RegisterMigration, and OpenWrtMigrations for
+// the renames of OpenWrt releases.
+type Migration struct {
+	Config      string
+	SectionType string
+	Option      string // deprecated name
+	NewOption   string // successor
+	Since       string // release deprecating Option, e.g. "21.02"
+
+	// Rewrite converts the values of Option for NewOption. If nil, the
+	// values are kept.
+	Rewrite func(values []string) []string
+}
+
+func (m Migration) String() string {
+	s := fmt.Sprintf("%s.@%s[*].%s -> %s", m.Config, m.SectionType, m.Option, m.NewOption)
+	if m.Since != "" {
+		s += " (since " + m.Since + ")"
+	}
+	return s
+}
+
+// An AppliedMigration is returned by Tree.Migrate for each option it
+// rewrote.
+type AppliedMigration struct {
+	Migration Migration
+	Path      Path     // of the deprecated option
+	Old       []string // its values
+	New       []string // values of the new option
+}
+
+// OpenWrtMigrations lists options renamed by OpenWrt releases.
+//
+// Only renames are covered: e.g. bridges configured with "option type
+// 'bridge'" in interface sections (replaced by device sections in
+// 21.02) are left alone, and need to be converted manually.
+var OpenWrtMigrations = []Migration{
+	{Config: "network", SectionType: "interface", Option: "ifname", NewOption: "device", Since: "21.02"},
+	{Config: "network", SectionType: "device", Option: "ifname", NewOption: "ports", Since: "21.02"},
+	{Config: "firewall", SectionType: "defaults", Option: "syn_flood", NewOption: "synflood_protect", Since: "22.03"},
+	{Config: "wireless", SectionType: "wifi-device", Option: "hwmode", NewOption: "band", Since: "22.03", Rewrite: hwmodeBand},
+}
+
+// hwmodeBand converts values of the hwmode option of radios to bands.
+// Modes not tied to a band are kept as is.
+func hwmodeBand(values []string) []string {
+	bands := make([]string, len(values))
+	for i, v := range values {
+		switch v {
+		case "11b", "11g", "11ng":
+			bands[i] = "2g"
+		case "11a", "11na", "11ac":
+			bands[i] = "5g"
+		case "11ad":
+			bands[i] = "60g"
+		default:
+			bands[i] = v
+		}
+	}
+	return bands
+}
+
+func (t *tree) RegisterMigration(m Migration) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.migrations = append(t.migrations, m)
+}
+
+func (t *tree) Migrate(configs ...string) ([]AppliedMigration, error) {
+	if t.readOnly {
+		return nil, ErrReadOnly
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	explicit := len(configs) > 0
+	if !explicit {
+		seen := make(map[string]bool)
+		for _, m := range t.migrations {
+			if !seen[m.Config] && t.allowed(m.Config) == nil {
+				seen[m.Config] = true
+				configs = append(configs, m.Config)
+			}
+		}
+		sort.Strings(configs)
+	}
+
+	var applied []AppliedMigration
+	for _, name := range configs {
+		cfg, err := t.ensureConfig(context.Background(), name)
+		if !explicit && errors.Is(err, ErrConfigNotFound) {
+			continue
+		}
+		if err != nil {
+			return applied, fmt.Errorf("migrate: %w", err)
+		}
+		snap := t.snapshot(name)
+		n := len(applied)
+		for _, m := range t.migrations {
+			if m.Config == name {
+				applied = t.migrate(cfg, m, applied)
+			}
+		}
+		if len(applied) > n {
+			cfg.SetTainted()
+			t.record(snap)
+		}
+	}
+	return applied, nil
+}
+
+// migrate applies m to the sections of cfg, and appends the migrated
+// options to applied. Its call must be guarded by locking the tree's
+// mutex.
+func (t *tree) migrate(cfg *Config, m Migration, applied []AppliedMigration) []AppliedMigration {
+	for _, sec := range cfg.Sections {
+		if sec.Type != m.SectionType {
+			continue
+		}
+		opt := sec.Get(m.Option)
+		if opt == nil {
+			continue
+		}
+		a := AppliedMigration{
+			Migration: m,
+			Path:      Path{Config: cfg.Name, Section: cfg.SectionName(sec), Option: m.Option},
+			Old:       append([]string(nil), opt.Values...),
+		}
+		if existing := sec.Get(m.NewOption); existing != nil {
+			// the successor is set already, and takes precedence
+			sec.Del(m.Option)
+			a.New = append([]string(nil), existing.Values...)
+			t.markEdited(cfg.Name, sec, nil)
+		} else {
+			opt.Name = m.NewOption
+			if m.Rewrite != nil {
+				opt.SetValues(m.Rewrite(opt.Values)...)
+			}
+			a.New = append([]string(nil), opt.Values...)
+			t.markEdited(cfg.Name, sec, opt)
+		}
+		t.logger().Info("option migrated", "config", cfg.Name, "section", a.Path.Section,
+			"option", m.Option, "new", m.NewOption)
+		applied = append(applied, a)
+	}
+	return applied
+}
@@ -0,0 +1,80 @@
+package uci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcMigrateNetwork = `
+config interface 'lan'
+	option ifname 'eth0'
+	option proto 'static'
+
+config interface 'wan'
+	option ifname 'eth1'
+	option device 'eth1.2'
+
+config device
+	option name 'br-lan'
+	list ifname 'lan1'
+	list ifname 'lan2'
+`
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"network":  tcMigrateNetwork,
+		"wireless": "\nconfig wifi-device 'radio0'\n\toption hwmode '11a'\n",
+	})
+	r := NewStoreTree(store)
+	for _, m := range OpenWrtMigrations {
+		r.RegisterMigration(m)
+	}
+
+	applied, err := r.Migrate()
+	require.NoError(err)
+	var got []string
+	for _, a := range applied {
+		got = append(got, a.Path.String()+" "+a.Migration.NewOption)
+	}
+	assert.Equal([]string{
+		"network.lan.ifname device",
+		"network.wan.ifname device",
+		"network.@device[0].ifname ports",
+		"wireless.radio0.hwmode band",
+	}, got)
+	assert.Equal([]string{"eth1"}, applied[1].Old)
+	assert.Equal([]string{"eth1.2"}, applied[1].New)
+	assert.Equal([]string{"5g"}, applied[3].New)
+	assert.Equal("network.@device[*].ifname -> ports (since 21.02)", applied[2].Migration.String())
+
+	require.NoError(r.Commit())
+	assert.Equal(`
+config interface 'lan'
+	option device 'eth0'
+	option proto 'static'
+
+config interface 'wan'
+	option device 'eth1.2'
+
+config device
+	option name 'br-lan'
+	list ports 'lan1'
+	list ports 'lan2'
+
+`, string(store.files["network"]))
+
+	// nothing left to migrate
+	applied, err = r.Migrate("network")
+	require.NoError(err)
+	assert.Empty(applied)
+
+	// explicitly named configs must exist
+	_, err = r.Migrate("missing")
+	assert.True(errors.Is(err, ErrConfigNotFound))
+}
@@ -0,0 +1,441 @@
+// Package mwan3 builds mwan3 configs from typed interfaces, members,
+// policies and rules, checking the references between them, which mwan3
+// itself silently ignores, breaking failover:
+//
+//	cfg, err := mwan3.New().
+//		Interface(mwan3.Interface{Name: "wan", Enabled: true, TrackIPs: []string{"1.1.1.1", "8.8.8.8"}}).
+//		Interface(mwan3.Interface{Name: "wwan", Enabled: true, TrackIPs: []string{"1.1.1.1"}}).
+//		Member(mwan3.Member{Name: "wan_m1", Interface: "wan", Metric: 1}).
+//		Member(mwan3.Member{Name: "wwan_m2", Interface: "wwan", Metric: 2}).
+//		Policy(mwan3.Policy{Name: "failover", Members: []string{"wan_m1", "wwan_m2"}}).
+//		Rule(mwan3.Rule{Name: "default", DestIP: "0.0.0.0/0", Policy: "failover"}).
+//		Build()
+//
+// Existing configs can be checked with Validate.
+package mwan3
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrName             = errors.New("name must not be empty")
+	ErrNameTaken        = errors.New("name already taken")
+	ErrNameTooLong      = errors.New("name longer than 15 characters")
+	ErrUnknownInterface = errors.New("unknown interface")
+	ErrUnknownMember    = errors.New("unknown member")
+	ErrUnknownPolicy    = errors.New("unknown policy")
+	ErrNoMembers        = errors.New("policy without members")
+	ErrFamily           = errors.New("invalid family")
+	ErrTrackMethod      = errors.New("invalid track method")
+	ErrLastResort       = errors.New("invalid last resort")
+	ErrRange            = errors.New("value out of range")
+	ErrInvalidNumber    = errors.New("invalid number")
+)
+
+// maxNameLen is the maximum length of interface and policy names, which
+// mwan3 uses in iptables chain and ipset names.
+const maxNameLen = 15
+
+// Family is the address family of an interface or rule.
+type Family string
+
+// Families. The empty family means "ipv4", or "any" for rules.
+const (
+	IPv4 Family = "ipv4"
+	IPv6 Family = "ipv6"
+	Any  Family = "any"
+)
+
+// TrackMethod is how tracking IPs are probed.
+type TrackMethod string
+
+// Track methods. The empty method means ping.
+const (
+	Ping      TrackMethod = "ping"
+	ARPing    TrackMethod = "arping"
+	HTTPing   TrackMethod = "httping"
+	NPingTCP  TrackMethod = "nping-tcp"
+	NPingUDP  TrackMethod = "nping-udp"
+	NPingICMP TrackMethod = "nping-icmp"
+	NPingARP  TrackMethod = "nping-arp"
+)
+
+func (m TrackMethod) valid() bool {
+	switch m {
+	case "", Ping, ARPing, HTTPing, NPingTCP, NPingUDP, NPingICMP, NPingARP:
+		return true
+	}
+	return false
+}
+
+// LastResort is what a policy does when none of its members is up.
+type LastResort string
+
+// Last resorts. The empty last resort means Unreachable.
+const (
+	Unreachable LastResort = "unreachable"
+	Blackhole   LastResort = "blackhole"
+	Default     LastResort = "default" // use the main routing table
+)
+
+// builtinPolicies can be used by rules without being defined.
+var builtinPolicies = []string{string(Unreachable), string(Blackhole), string(Default)}
+
+// Interface is an interface of the network config tracked by mwan3.
+type Interface struct {
+	Name        string // logical interface of the network config
+	Enabled     bool
+	Family      Family
+	TrackIPs    []string
+	TrackMethod TrackMethod
+	Reliability int // tracking IPs which must answer, 0 means 1
+	Count       int // probes per tracking IP
+	Timeout     int // seconds
+	Interval    int // seconds
+	Down        int // failed tests until the interface is considered down
+	Up          int // succeeded tests until it is considered up again
+}
+
+// Member is an interface with a metric and weight, for use in policies.
+type Member struct {
+	Name      string
+	Interface string
+	Metric    int // 1-256, lower is preferred; 0 means 1
+	Weight    int // 1-1000, for balancing members of the same metric; 0 means 1
+}
+
+// Policy distributes traffic over its members: the members with the
+// lowest metric are used, balanced by their weights.
+type Policy struct {
+	Name       string
+	Members    []string
+	LastResort LastResort
+}
+
+// Rule assigns matching traffic to a policy.
+type Rule struct {
+	Name     string
+	SrcIP    string
+	SrcPort  string
+	DestIP   string
+	DestPort string
+	Proto    string
+	Family   Family
+	Sticky   bool
+	Timeout  int    // of sticky connections, seconds
+	Policy   string // a policy, or a LastResort
+}
+
+// A ReferenceError describes an invalid reference to, or definition of,
+// a named section.
+type ReferenceError struct {
+	Section string // e.g. "member wan_m1"
+	Name    string
+	Err     error
+}
+
+func (err *ReferenceError) Error() string {
+	return fmt.Sprintf("%s: %v %q", err.Section, err.Err, err.Name)
+}
+
+func (err *ReferenceError) Unwrap() error {
+	return err.Err
+}
+
+// Builder collects the sections of a mwan3 config.
+type Builder struct {
+	interfaces []Interface
+	members    []Member
+	policies   []Policy
+	rules      []Rule
+}
+
+// New returns an empty builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+// Interface adds an interface.
+func (b *Builder) Interface(i Interface) *Builder {
+	b.interfaces = append(b.interfaces, i)
+	return b
+}
+
+// Member adds a member.
+func (b *Builder) Member(m Member) *Builder {
+	b.members = append(b.members, m)
+	return b
+}
+
+// Policy adds a policy.
+func (b *Builder) Policy(p Policy) *Builder {
+	b.policies = append(b.policies, p)
+	return b
+}
+
+// Rule adds a rule. Rules are matched in order.
+func (b *Builder) Rule(r Rule) *Builder {
+	b.rules = append(b.rules, r)
+	return b
+}
+
+// Build validates the collected sections, and renders them into a mwan3
+// config. All sections must have unique names, members must reference
+// defined interfaces, policies defined members, and rules defined (or
+// builtin) policies.
+func (b *Builder) Build() (*uci.Config, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg := uci.NewConfig("mwan3")
+	for i := range b.interfaces {
+		cfg.Add(b.interfaces[i].Section())
+	}
+	for i := range b.members {
+		cfg.Add(b.members[i].Section())
+	}
+	for i := range b.policies {
+		cfg.Add(b.policies[i].Section())
+	}
+	for i := range b.rules {
+		cfg.Add(b.rules[i].Section())
+	}
+	return cfg, nil
+}
+
+func (b *Builder) validate() error { //nolint:cyclop
+	names := make(map[string]string) // name -> type
+	define := func(typ, name string, i int) error {
+		switch {
+		case name == "":
+			return fmt.Errorf("%s #%d: %w", typ, i, ErrName)
+		case names[name] != "":
+			return &ReferenceError{fmt.Sprintf("%s #%d", typ, i), name, ErrNameTaken}
+		}
+		names[name] = typ
+		return nil
+	}
+	check := func(section, typ, name string, err error) error {
+		if names[name] != typ {
+			return &ReferenceError{section, name, err}
+		}
+		return nil
+	}
+
+	for i, iface := range b.interfaces {
+		if err := define("interface", iface.Name, i); err != nil {
+			return err
+		}
+		section := "interface " + iface.Name
+		switch {
+		case len(iface.Name) > maxNameLen:
+			return &ReferenceError{section, iface.Name, ErrNameTooLong}
+		case iface.Family != "" && iface.Family != IPv4 && iface.Family != IPv6:
+			return fmt.Errorf("%s: %w %q", section, ErrFamily, iface.Family)
+		case !iface.TrackMethod.valid():
+			return fmt.Errorf("%s: %w %q", section, ErrTrackMethod, iface.TrackMethod)
+		case iface.Reliability > max(len(iface.TrackIPs), 1):
+			return fmt.Errorf("%s: %w: reliability %d with %d tracking IPs", section, ErrRange, iface.Reliability, len(iface.TrackIPs))
+		}
+	}
+	for i, m := range b.members {
+		if err := define("member", m.Name, i); err != nil {
+			return err
+		}
+		section := "member " + m.Name
+		if err := check(section, "interface", m.Interface, ErrUnknownInterface); err != nil {
+			return err
+		}
+		if m.Metric < 0 || m.Metric > 256 {
+			return fmt.Errorf("%s: %w: metric %d", section, ErrRange, m.Metric)
+		}
+		if m.Weight < 0 || m.Weight > 1000 {
+			return fmt.Errorf("%s: %w: weight %d", section, ErrRange, m.Weight)
+		}
+	}
+	for i, p := range b.policies {
+		if err := define("policy", p.Name, i); err != nil {
+			return err
+		}
+		section := "policy " + p.Name
+		if len(p.Name) > maxNameLen {
+			return &ReferenceError{section, p.Name, ErrNameTooLong}
+		}
+		if len(p.Members) == 0 {
+			return fmt.Errorf("%s: %w", section, ErrNoMembers)
+		}
+		for _, m := range p.Members {
+			if err := check(section, "member", m, ErrUnknownMember); err != nil {
+				return err
+			}
+		}
+		switch p.LastResort {
+		case "", Unreachable, Blackhole, Default:
+		default:
+			return fmt.Errorf("%s: %w %q", section, ErrLastResort, p.LastResort)
+		}
+	}
+	for i, r := range b.rules {
+		if err := define("rule", r.Name, i); err != nil {
+			return err
+		}
+		section := "rule " + r.Name
+		if !slices.Contains(builtinPolicies, r.Policy) {
+			if err := check(section, "policy", r.Policy, ErrUnknownPolicy); err != nil {
+				return err
+			}
+		}
+		if r.Family != "" && r.Family != IPv4 && r.Family != IPv6 && r.Family != Any {
+			return fmt.Errorf("%s: %w %q", section, ErrFamily, r.Family)
+		}
+	}
+	return nil
+}
+
+// Section renders i into an "interface" section.
+func (i *Interface) Section() *uci.Section {
+	s := uci.NewSection("interface", i.Name)
+	s.SetOption("enabled", boolString(i.Enabled))
+	setOption(s, "family", string(i.Family))
+	setList(s, "track_ip", i.TrackIPs)
+	setOption(s, "track_method", string(i.TrackMethod))
+	setInt(s, "reliability", i.Reliability)
+	setInt(s, "count", i.Count)
+	setInt(s, "timeout", i.Timeout)
+	setInt(s, "interval", i.Interval)
+	setInt(s, "down", i.Down)
+	setInt(s, "up", i.Up)
+	return s
+}
+
+// Section renders m into a "member" section.
+func (m *Member) Section() *uci.Section {
+	s := uci.NewSection("member", m.Name)
+	s.SetOption("interface", m.Interface)
+	setInt(s, "metric", m.Metric)
+	setInt(s, "weight", m.Weight)
+	return s
+}
+
+// Section renders p into a "policy" section.
+func (p *Policy) Section() *uci.Section {
+	s := uci.NewSection("policy", p.Name)
+	setList(s, "use_member", p.Members)
+	setOption(s, "last_resort", string(p.LastResort))
+	return s
+}
+
+// Section renders r into a "rule" section.
+func (r *Rule) Section() *uci.Section {
+	s := uci.NewSection("rule", r.Name)
+	setOption(s, "src_ip", r.SrcIP)
+	setOption(s, "src_port", r.SrcPort)
+	setOption(s, "dest_ip", r.DestIP)
+	setOption(s, "dest_port", r.DestPort)
+	setOption(s, "proto", r.Proto)
+	setOption(s, "family", string(r.Family))
+	if r.Sticky {
+		s.SetOption("sticky", "1")
+	}
+	setInt(s, "timeout", r.Timeout)
+	s.SetOption("use_policy", r.Policy)
+	return s
+}
+
+// FromConfig reads the sections of a mwan3 config into a builder, so
+// that they can be checked and modified. Sections of other types, like
+// globals, are skipped.
+func FromConfig(cfg *uci.Config) (*Builder, error) { //nolint:cyclop
+	b := New()
+	for _, sec := range cfg.Sections {
+		ints := make(map[string]int)
+		for _, name := range []string{"reliability", "count", "timeout", "interval", "down", "up", "metric", "weight"} {
+			v := sec.LastValue(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s: %w %q", sec.Type, sec.Name, name, ErrInvalidNumber, v)
+			}
+			ints[name] = n
+		}
+
+		switch sec.Type {
+		case "interface":
+			b.Interface(Interface{
+				Name:        sec.Name,
+				Enabled:     sec.LastValue("enabled") == "1",
+				Family:      Family(sec.LastValue("family")),
+				TrackIPs:    sec.Fields("track_ip"),
+				TrackMethod: TrackMethod(sec.LastValue("track_method")),
+				Reliability: ints["reliability"],
+				Count:       ints["count"],
+				Timeout:     ints["timeout"],
+				Interval:    ints["interval"],
+				Down:        ints["down"],
+				Up:          ints["up"],
+			})
+		case "member":
+			b.Member(Member{Name: sec.Name, Interface: sec.LastValue("interface"), Metric: ints["metric"], Weight: ints["weight"]})
+		case "policy":
+			b.Policy(Policy{Name: sec.Name, Members: sec.Fields("use_member"), LastResort: LastResort(sec.LastValue("last_resort"))})
+		case "rule":
+			b.Rule(Rule{
+				Name:     sec.Name,
+				SrcIP:    sec.LastValue("src_ip"),
+				SrcPort:  sec.LastValue("src_port"),
+				DestIP:   sec.LastValue("dest_ip"),
+				DestPort: sec.LastValue("dest_port"),
+				Proto:    sec.LastValue("proto"),
+				Family:   Family(sec.LastValue("family")),
+				Sticky:   sec.LastValue("sticky") == "1",
+				Timeout:  ints["timeout"],
+				Policy:   sec.LastValue("use_policy"),
+			})
+		}
+	}
+	return b, nil
+}
+
+// Validate checks the sections and references of a mwan3 config, like
+// Builder.Build does.
+func Validate(cfg *uci.Config) error {
+	b, err := FromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return b.validate()
+}
+
+func setOption(s *uci.Section, name, value string) {
+	if value != "" {
+		s.SetOption(name, value)
+	}
+}
+
+func setList(s *uci.Section, name string, values []string) {
+	if len(values) > 0 {
+		s.SetList(name, values...)
+	}
+}
+
+func setInt(s *uci.Section, name string, n int) {
+	if n > 0 {
+		s.SetOption(name, strconv.Itoa(n))
+	}
+}
+
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,139 @@
+package mwan3
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestBuild(t *testing.T) {
+	cfg, err := New().
+		Interface(Interface{Name: "wan", Enabled: true, TrackIPs: []string{"1.1.1.1", "8.8.8.8"}, Reliability: 2, Down: 3, Up: 3}).
+		Interface(Interface{Name: "wwan", Enabled: true, TrackIPs: []string{"1.1.1.1"}, TrackMethod: HTTPing}).
+		Member(Member{Name: "wan_m1", Interface: "wan", Metric: 1, Weight: 3}).
+		Member(Member{Name: "wwan_m2", Interface: "wwan", Metric: 2}).
+		Policy(Policy{Name: "failover", Members: []string{"wan_m1", "wwan_m2"}, LastResort: Unreachable}).
+		Rule(Rule{Name: "https", DestPort: "443", Proto: "tcp", Sticky: true, Policy: "failover"}).
+		Rule(Rule{Name: "default", DestIP: "0.0.0.0/0", Policy: "failover"}).
+		Build()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `
+config interface 'wan'
+	option enabled '1'
+	list track_ip '1.1.1.1'
+	list track_ip '8.8.8.8'
+	option reliability '2'
+	option down '3'
+	option up '3'
+
+config interface 'wwan'
+	option enabled '1'
+	list track_ip '1.1.1.1'
+	option track_method 'httping'
+
+config member 'wan_m1'
+	option interface 'wan'
+	option metric '1'
+	option weight '3'
+
+config member 'wwan_m2'
+	option interface 'wwan'
+	option metric '2'
+
+config policy 'failover'
+	list use_member 'wan_m1'
+	list use_member 'wwan_m2'
+	option last_resort 'unreachable'
+
+config rule 'https'
+	option dest_port '443'
+	option proto 'tcp'
+	option sticky '1'
+	option use_policy 'failover'
+
+config rule 'default'
+	option dest_ip '0.0.0.0/0'
+	option use_policy 'failover'
+
+`, buf.String())
+
+	b, err := FromConfig(cfg)
+	require.NoError(t, err)
+	again, err := b.Build()
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Hash(), again.Hash())
+}
+
+func TestBuildErrors(t *testing.T) {
+	wan := Interface{Name: "wan"}
+	m := Member{Name: "wan_m1", Interface: "wan"}
+	base := func() *Builder { return New().Interface(wan).Member(m) }
+
+	tt := []struct {
+		name string
+		b    *Builder
+		err  error
+		msg  string
+	}{
+		{"empty name", New().Interface(Interface{}), ErrName, "interface #0: name must not be empty"},
+		{"duplicate", New().Interface(wan).Member(Member{Name: "wan", Interface: "wan"}), ErrNameTaken, `member #0: name already taken "wan"`},
+		{"long interface", New().Interface(Interface{Name: "wan_backup_link_2"}), ErrNameTooLong, `interface wan_backup_link_2: name longer than 15 characters "wan_backup_link_2"`},
+		{"family", New().Interface(Interface{Name: "wan", Family: "ipx"}), ErrFamily, `interface wan: invalid family "ipx"`},
+		{"track method", New().Interface(Interface{Name: "wan", TrackMethod: "curl"}), ErrTrackMethod, `interface wan: invalid track method "curl"`},
+		{"reliability", New().Interface(Interface{Name: "wan", TrackIPs: []string{"1.1.1.1"}, Reliability: 2}), ErrRange, "interface wan: value out of range: reliability 2 with 1 tracking IPs"},
+		{"unknown interface", New().Interface(wan).Member(Member{Name: "m", Interface: "wan2"}), ErrUnknownInterface, `member m: unknown interface "wan2"`},
+		{"member references member", base().Member(Member{Name: "m", Interface: "wan_m1"}), ErrUnknownInterface, `member m: unknown interface "wan_m1"`},
+		{"metric", New().Interface(wan).Member(Member{Name: "m", Interface: "wan", Metric: 300}), ErrRange, "member m: value out of range: metric 300"},
+		{"unknown member", base().Policy(Policy{Name: "p", Members: []string{"wan_m1", "wan_m2"}}), ErrUnknownMember, `policy p: unknown member "wan_m2"`},
+		{"no members", base().Policy(Policy{Name: "p"}), ErrNoMembers, "policy p: policy without members"},
+		{"last resort", base().Policy(Policy{Name: "p", Members: []string{"wan_m1"}, LastResort: "drop"}), ErrLastResort, `policy p: invalid last resort "drop"`},
+		{"unknown policy", base().Rule(Rule{Name: "r", Policy: "balanced"}), ErrUnknownPolicy, `rule r: unknown policy "balanced"`},
+		{"missing policy", base().Rule(Rule{Name: "r"}), ErrUnknownPolicy, `rule r: unknown policy ""`},
+	}
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.b.Build()
+			assert.True(t, errors.Is(err, tc.err), "got %v", err)
+			assert.EqualError(t, err, tc.msg)
+		})
+	}
+
+	// builtin policies
+	_, err := New().Rule(Rule{Name: "r", Policy: string(Blackhole)}).Build()
+	assert.NoError(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	cfg, err := ast.Parse("mwan3", `
+config globals 'globals'
+	option mmx_mask '0x3F00'
+
+config interface 'wan'
+	option enabled '1'
+
+config member 'wan_m1_w3'
+	option interface 'wan'
+	option metric '1'
+	option weight '3'
+
+config policy 'balanced'
+	list use_member 'wan_m1_w3'
+	list use_member 'wan2_m1_w2'
+`)
+	require.NoError(t, err)
+	err = Validate(cfg)
+	assert.ErrorIs(t, err, ErrUnknownMember)
+	assert.EqualError(t, err, `policy balanced: unknown member "wan2_m1_w2"`)
+
+	cfg.Get("wan_m1_w3").SetOption("metric", "one")
+	assert.ErrorIs(t, Validate(cfg), ErrInvalidNumber)
+}
@@ -0,0 +1,44 @@
+package uci
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations lists letters, which don't decompose into an ASCII
+// letter and combining marks.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "TH",
+}
+
+// SanitizeName turns s into a valid section or option name (see
+// ValidIdentifier), e.g. for names derived from user input. Latin
+// letters lose their diacritics ("Küche" becomes "Kuche"), and runs of
+// other characters are replaced by a single underscore ("guest wifi"
+// becomes "guest_wifi"). Different inputs may map to the same name.
+func SanitizeName(s string) string {
+	var sb strings.Builder
+	underscore := false // last rune written was a replacement
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case r < unicode.MaxASCII && ValidIdentifier(string(r)):
+			sb.WriteRune(r)
+			underscore = false
+		case unicode.Is(unicode.Mn, r):
+			// combining mark of the preceding letter
+		case transliterations[r] != "":
+			sb.WriteString(transliterations[r])
+			underscore = false
+		case !underscore:
+			sb.WriteByte('_')
+			underscore = true
+		}
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
@@ -0,0 +1,27 @@
+package uci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeName(t *testing.T) {
+	assert := assert.New(t)
+	for in, want := range map[string]string{
+		"lan":          "lan",
+		"guest wifi":   "guest_wifi",
+		"guest-wifi":   "guest_wifi",
+		"Küche":        "Kuche",
+		"Straße 1":     "Strasse_1",
+		"Ærø":          "AEro",
+		"a -- b":       "a_b",
+		"客厅":           "_",
+		"":             "_",
+		"crème brûlée": "creme_brulee",
+	} {
+		got := SanitizeName(in)
+		assert.Equal(want, got, in)
+		assert.True(ValidIdentifier(got), in)
+	}
+}
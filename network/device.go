@@ -0,0 +1,68 @@
+package network
+
+import (
+	"fmt"
+	"strconv"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// Device is a network device ("config device"), e.g. a bridge or a
+// VLAN device.
+type Device struct {
+	Name    string
+	Type    string   // e.g. "bridge", "8021q", or empty for plain devices
+	Ports   []string // bridge ports
+	IfName  string   // base device of VLAN devices
+	VID     int      // VLAN ID, 0 means unset
+	MACAddr string
+	MTU     int // 0 means unset
+}
+
+// deviceOptions lists the options managed by Device.
+var deviceOptions = []string{"name", "type", "ports", "ifname", "vid", "macaddr", "mtu"}
+
+// ParseDevice converts a device section.
+func ParseDevice(sec *uci.Section) (*Device, error) {
+	dev := &Device{
+		Name:    sec.LastValue("name"),
+		Type:    sec.LastValue("type"),
+		Ports:   sec.Fields("ports"),
+		IfName:  sec.LastValue("ifname"),
+		MACAddr: sec.LastValue("macaddr"),
+	}
+
+	var err error
+	if dev.VID, err = parseInt(sec.LastValue("vid")); err != nil {
+		return nil, fmt.Errorf("device %s: %w", dev.Name, err)
+	}
+	if dev.MTU, err = parseInt(sec.LastValue("mtu")); err != nil {
+		return nil, fmt.Errorf("device %s: %w", dev.Name, err)
+	}
+	return dev, nil
+}
+
+// Section renders dev into an unnamed device section.
+func (dev *Device) Section() *uci.Section {
+	s := uci.NewSection("device", "")
+	set := func(name, value string) {
+		if value != "" {
+			s.Add(uci.NewOption(name, uci.TypeOption, value))
+		}
+	}
+
+	set("name", dev.Name)
+	set("type", dev.Type)
+	if len(dev.Ports) > 0 {
+		s.Add(uci.NewOption("ports", uci.TypeList, dev.Ports...))
+	}
+	set("ifname", dev.IfName)
+	if dev.VID > 0 {
+		set("vid", strconv.Itoa(dev.VID))
+	}
+	set("macaddr", dev.MACAddr)
+	if dev.MTU > 0 {
+		set("mtu", strconv.Itoa(dev.MTU))
+	}
+	return s
+}
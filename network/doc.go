@@ -0,0 +1,19 @@
+// Package network provides helpers for the network config and the
+// configs of other packages, which refer to its logical interfaces.
+//
+// Interface, Device and Route convert between sections and structs
+// using net/netip types, validating addresses, netmasks and gateways
+// on the way:
+//
+//	ifaces, err := network.Interfaces(tree)
+//	...
+//	err = network.PutInterface(tree, &network.Interface{
+//		Name:      "lan",
+//		Proto:     "static",
+//		Device:    "br-lan",
+//		Addresses: []netip.Prefix{netip.MustParsePrefix("192.168.1.1/24")},
+//	})
+//
+// CheckRuntime compares the interfaces with netifd's runtime state, as
+// reported by "ubus call network.interface dump".
+package network
@@ -0,0 +1,291 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrInvalidAddress     = errors.New("invalid address")
+	ErrInvalidNetmask     = errors.New("invalid netmask")
+	ErrMissingAddress     = errors.New("static interface without address")
+	ErrGatewayUnreachable = errors.New("gateway not within any local subnet")
+	ErrFamilyMismatch     = errors.New("address family mismatch")
+	ErrInvalidNumber      = errors.New("invalid number")
+)
+
+// Interface is a logical interface ("config interface").
+type Interface struct {
+	Name   string
+	Proto  string // e.g. "static", "dhcp", "dhcpv6", "pppoe"
+	Device string
+
+	// Addresses holds the IPv4 addresses, with their prefix length. A
+	// single address is stored as "ipaddr" and "netmask" options,
+	// multiple addresses as an "ipaddr" list in CIDR notation.
+	Addresses []netip.Prefix
+	Gateway   netip.Addr
+
+	// IPv6 addresses and gateway ("ip6addr" and "ip6gw").
+	Addresses6 []netip.Prefix
+	Gateway6   netip.Addr
+	IP6Assign  int // prefix length to delegate, 0 means none
+
+	DNS      []netip.Addr
+	MTU      int // 0 means unset
+	Disabled bool
+}
+
+// interfaceOptions lists the options managed by Interface.
+var interfaceOptions = []string{
+	"proto", "device", "ifname", "ipaddr", "netmask", "gateway", "ip6addr", "ip6gw",
+	"ip6assign", "dns", "mtu", "disabled",
+}
+
+// ParseInterface converts an interface section. It accepts both the
+// "ipaddr"/"netmask" pair and a list of CIDR addresses.
+func ParseInterface(sec *uci.Section) (*Interface, error) {
+	iface := &Interface{
+		Name:     sec.Name,
+		Proto:    sec.LastValue("proto"),
+		Device:   sec.LastValue("device"),
+		Disabled: sec.LastValue("disabled") == "1",
+	}
+	if iface.Device == "" {
+		iface.Device = sec.LastValue("ifname") // before OpenWrt 21.02
+	}
+
+	wrap := func(err error) (*Interface, error) {
+		return nil, fmt.Errorf("interface %s: %w", sec.Name, err)
+	}
+
+	var err error
+	if iface.Addresses, err = parseAddresses(sec.Fields("ipaddr"), sec.LastValue("netmask")); err != nil {
+		return wrap(err)
+	}
+	if iface.Gateway, err = parseAddr(sec.LastValue("gateway")); err != nil {
+		return wrap(err)
+	}
+	if iface.Addresses6, err = parseAddresses(sec.Fields("ip6addr"), ""); err != nil {
+		return wrap(err)
+	}
+	if iface.Gateway6, err = parseAddr(sec.LastValue("ip6gw")); err != nil {
+		return wrap(err)
+	}
+	for _, v := range sec.Fields("dns") {
+		addr, err := parseAddr(v)
+		if err != nil {
+			return wrap(err)
+		}
+		iface.DNS = append(iface.DNS, addr)
+	}
+	if iface.IP6Assign, err = parseInt(sec.LastValue("ip6assign")); err != nil {
+		return wrap(err)
+	}
+	if iface.MTU, err = parseInt(sec.LastValue("mtu")); err != nil {
+		return wrap(err)
+	}
+
+	if err := iface.Validate(); err != nil {
+		return nil, err
+	}
+	return iface, nil
+}
+
+// Validate checks the consistency of addresses and gateways: static
+// interfaces need an address, addresses must have the right family,
+// and gateways must be reachable through one of the local subnets.
+func (iface *Interface) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("interface %s: %w", iface.Name, err)
+	}
+
+	if iface.Proto == "static" && len(iface.Addresses) == 0 && len(iface.Addresses6) == 0 {
+		return wrap(ErrMissingAddress)
+	}
+	for _, p := range iface.Addresses {
+		if !p.Addr().Is4() {
+			return wrap(fmt.Errorf("%w: ipaddr %s", ErrFamilyMismatch, p))
+		}
+	}
+	for _, p := range iface.Addresses6 {
+		if !p.Addr().Is6() {
+			return wrap(fmt.Errorf("%w: ip6addr %s", ErrFamilyMismatch, p))
+		}
+	}
+	if iface.Gateway.IsValid() {
+		if !iface.Gateway.Is4() {
+			return wrap(fmt.Errorf("%w: gateway %s", ErrFamilyMismatch, iface.Gateway))
+		}
+		if !contains(iface.Addresses, iface.Gateway) {
+			return wrap(fmt.Errorf("%w: gateway %s", ErrGatewayUnreachable, iface.Gateway))
+		}
+	}
+	if iface.Gateway6.IsValid() {
+		if !iface.Gateway6.Is6() {
+			return wrap(fmt.Errorf("%w: ip6gw %s", ErrFamilyMismatch, iface.Gateway6))
+		}
+		// link-local gateways are reachable without a local subnet
+		if !iface.Gateway6.IsLinkLocalUnicast() && !contains(iface.Addresses6, iface.Gateway6) {
+			return wrap(fmt.Errorf("%w: ip6gw %s", ErrGatewayUnreachable, iface.Gateway6))
+		}
+	}
+	return nil
+}
+
+// Section renders iface into an interface section.
+func (iface *Interface) Section() *uci.Section {
+	s := uci.NewSection("interface", iface.Name)
+	set := func(name string, values ...string) {
+		if len(values) > 0 && values[0] != "" {
+			s.Add(uci.NewOption(name, uci.TypeOption, values...))
+		}
+	}
+	setList := func(name string, values []string) {
+		if len(values) > 0 {
+			s.Add(uci.NewOption(name, uci.TypeList, values...))
+		}
+	}
+
+	set("proto", iface.Proto)
+	set("device", iface.Device)
+	switch len(iface.Addresses) {
+	case 0:
+	case 1:
+		p := iface.Addresses[0]
+		set("ipaddr", p.Addr().String())
+		set("netmask", netmask(p.Bits()))
+	default:
+		setList("ipaddr", prefixStrings(iface.Addresses))
+	}
+	set("gateway", addrString(iface.Gateway))
+	setList("ip6addr", prefixStrings(iface.Addresses6))
+	set("ip6gw", addrString(iface.Gateway6))
+	if iface.IP6Assign > 0 {
+		set("ip6assign", strconv.Itoa(iface.IP6Assign))
+	}
+	dns := make([]string, 0, len(iface.DNS))
+	for _, a := range iface.DNS {
+		dns = append(dns, a.String())
+	}
+	setList("dns", dns)
+	if iface.MTU > 0 {
+		set("mtu", strconv.Itoa(iface.MTU))
+	}
+	if iface.Disabled {
+		set("disabled", "1")
+	}
+	return s
+}
+
+// parseAddresses parses a list of addresses. Addresses without prefix
+// length use the netmask (or /32, if there's none).
+func parseAddresses(values []string, mask string) ([]netip.Prefix, error) {
+	bits := -1
+	if mask != "" {
+		var err error
+		if bits, err = parseNetmask(mask); err != nil {
+			return nil, err
+		}
+	}
+
+	var prefixes []netip.Prefix
+	for _, v := range values {
+		if p, err := netip.ParsePrefix(v); err == nil {
+			prefixes = append(prefixes, p)
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidAddress, v)
+		}
+		b := bits
+		if b < 0 || !addr.Is4() {
+			b = addr.BitLen()
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, b))
+	}
+	return prefixes, nil
+}
+
+// parseNetmask converts a dotted netmask (or a prefix length) into a
+// prefix length.
+func parseNetmask(mask string) (int, error) {
+	if n, err := strconv.Atoi(mask); err == nil && n >= 0 && n <= 32 {
+		return n, nil
+	}
+	addr, err := netip.ParseAddr(mask)
+	if err != nil || !addr.Is4() {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNetmask, mask)
+	}
+	b := addr.As4()
+	v := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	bits := 0
+	for v&(1<<31) != 0 {
+		bits++
+		v <<= 1
+	}
+	if v != 0 {
+		return 0, fmt.Errorf("%w %q: not contiguous", ErrInvalidNetmask, mask)
+	}
+	return bits, nil
+}
+
+// netmask converts a prefix length into a dotted IPv4 netmask.
+func netmask(bits int) string {
+	v := ^uint32(0) << (32 - bits)
+	if bits == 0 {
+		v = 0
+	}
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}).String()
+}
+
+func parseAddr(v string) (netip.Addr, error) {
+	if v == "" {
+		return netip.Addr{}, nil
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%w %q", ErrInvalidAddress, v)
+	}
+	return addr, nil
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+	}
+	return n, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func addrString(a netip.Addr) string {
+	if !a.IsValid() {
+		return ""
+	}
+	return a.String()
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	s := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return s
+}
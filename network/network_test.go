@@ -0,0 +1,196 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestInterfaces(t *testing.T) {
+	ifaces, err := Interfaces(uci.NewTree("../testdata"))
+	require.NoError(t, err)
+	require.Len(t, ifaces, 6)
+
+	lan := ifaces[1]
+	assert.Equal(t, "lan", lan.Name)
+	assert.Equal(t, "br-lan", lan.Device)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.168.1.1/24")}, lan.Addresses)
+	assert.Equal(t, 60, lan.IP6Assign)
+
+	assert.Equal(t, "dhcp", ifaces[3].Proto)
+}
+
+func TestParseInterface(t *testing.T) {
+	tt := []struct {
+		name    string
+		options []*uci.Option
+		err     error
+	}{
+		{"cidr list", []*uci.Option{
+			uci.NewOption("proto", uci.TypeOption, "static"),
+			uci.NewOption("ipaddr", uci.TypeList, "10.0.0.1/8", "172.16.0.1/12"),
+			uci.NewOption("gateway", uci.TypeOption, "172.16.0.254"),
+			uci.NewOption("dns", uci.TypeOption, "1.1.1.1 9.9.9.9"),
+		}, nil},
+		{"ipv6", []*uci.Option{
+			uci.NewOption("proto", uci.TypeOption, "static"),
+			uci.NewOption("ip6addr", uci.TypeOption, "2001:db8::1/64"),
+			uci.NewOption("ip6gw", uci.TypeOption, "fe80::1"),
+		}, nil},
+		{"no address", []*uci.Option{
+			uci.NewOption("proto", uci.TypeOption, "static"),
+		}, ErrMissingAddress},
+		{"bad netmask", []*uci.Option{
+			uci.NewOption("ipaddr", uci.TypeOption, "10.0.0.1"),
+			uci.NewOption("netmask", uci.TypeOption, "255.0.255.0"),
+		}, ErrInvalidNetmask},
+		{"bad address", []*uci.Option{
+			uci.NewOption("ipaddr", uci.TypeOption, "10.0.0.256"),
+		}, ErrInvalidAddress},
+		{"unreachable gateway", []*uci.Option{
+			uci.NewOption("ipaddr", uci.TypeOption, "10.0.0.1"),
+			uci.NewOption("netmask", uci.TypeOption, "255.255.255.0"),
+			uci.NewOption("gateway", uci.TypeOption, "10.0.1.1"),
+		}, ErrGatewayUnreachable},
+		{"ipv6 in ipaddr", []*uci.Option{
+			uci.NewOption("ipaddr", uci.TypeOption, "2001:db8::1/64"),
+		}, ErrFamilyMismatch},
+		{"bad mtu", []*uci.Option{
+			uci.NewOption("mtu", uci.TypeOption, "big"),
+		}, ErrInvalidNumber},
+	}
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.name, func(t *testing.T) {
+			sec := uci.NewSection("interface", "test")
+			sec.Options = tc.options
+			_, err := ParseInterface(sec)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.err), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestInterfaceSection(t *testing.T) {
+	assert := assert.New(t)
+
+	single := &Interface{
+		Name:      "lan",
+		Proto:     "static",
+		Addresses: []netip.Prefix{netip.MustParsePrefix("192.168.1.1/24")},
+		Gateway:   netip.MustParseAddr("192.168.1.254"),
+	}
+	sec := single.Section()
+	assert.Equal([]string{"192.168.1.1"}, sec.Value("ipaddr"))
+	assert.Equal([]string{"255.255.255.0"}, sec.Value("netmask"))
+
+	parsed, err := ParseInterface(sec)
+	assert.NoError(err)
+	assert.Equal(single, parsed)
+
+	multi := &Interface{
+		Name:      "lan",
+		Proto:     "static",
+		Addresses: []netip.Prefix{netip.MustParsePrefix("10.0.0.1/8"), netip.MustParsePrefix("172.16.0.1/12")},
+		DNS:       []netip.Addr{netip.MustParseAddr("1.1.1.1")},
+		MTU:       1400,
+	}
+	sec = multi.Section()
+	assert.Equal(uci.TypeList, sec.Get("ipaddr").Type)
+	assert.Nil(sec.Get("netmask"))
+	parsed, err = ParseInterface(sec)
+	assert.NoError(err)
+	assert.Equal(multi, parsed)
+}
+
+func TestNetmask(t *testing.T) {
+	for _, bits := range []int{0, 1, 8, 23, 24, 31, 32} {
+		n, err := parseNetmask(netmask(bits))
+		assert.NoError(t, err)
+		assert.Equal(t, bits, n)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	routes, err := Routes(uci.NewTree("../testdata"))
+	require.NoError(t, err)
+	assert.Equal(t, []*Route{{
+		Interface: "guest",
+		Target:    netip.MustParsePrefix("10.0.0.0/8"),
+		Gateway:   netip.MustParseAddr("192.168.2.254"),
+	}}, routes)
+
+	r := &Route{Target: netip.MustParsePrefix("2001:db8::/32"), Gateway: netip.MustParseAddr("10.0.0.1")}
+	assert.True(t, errors.Is(r.Validate(), ErrFamilyMismatch))
+
+	r.Gateway = netip.MustParseAddr("fe80::1")
+	assert.Equal(t, "route6", r.Section().Type)
+}
+
+func TestPut(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+
+	err := PutInterface(tree, &Interface{
+		Name:      "lan",
+		Proto:     "static",
+		Device:    "br-lan",
+		Addresses: []netip.Prefix{netip.MustParsePrefix("10.1.0.1/16")},
+	})
+	assert.NoError(err)
+	values, _ := tree.Get("network", "lan", "netmask")
+	assert.Equal([]string{"255.255.0.0"}, values)
+	_, ok := tree.GetLast("network", "lan", "ip6assign")
+	assert.False(ok)
+
+	assert.NoError(PutDevice(tree, &Device{Name: "br-lan", Type: "bridge", Ports: []string{"lan1"}}))
+	assert.NoError(PutDevice(tree, &Device{Name: "wan.7", Type: "8021q", IfName: "wan", VID: 7}))
+	devs, err := Devices(tree)
+	assert.NoError(err)
+	assert.Len(devs, 2)
+	assert.Equal([]string{"lan1"}, devs[0].Ports)
+	assert.Equal(7, devs[1].VID)
+
+	assert.NoError(AddRoute(tree, &Route{Interface: "wan", Target: netip.MustParsePrefix("0.0.0.0/0")}))
+	routes, err := Routes(tree)
+	assert.NoError(err)
+	assert.Len(routes, 2)
+}
+
+func TestPutConcurrent(t *testing.T) {
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"network": ""}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, PutInterface(tree, &Interface{
+				Name:      fmt.Sprintf("lan%d", i),
+				Proto:     "static",
+				Addresses: []netip.Prefix{netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 1}), 24)},
+			}))
+			assert.NoError(t, AddRoute(tree, &Route{Interface: "wan", Target: netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 0}), 16)}))
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = tree.Get("network", "lan0", "proto")
+		}()
+	}
+	wg.Wait()
+	ifaces, err := Interfaces(tree)
+	require.NoError(t, err)
+	assert.Len(t, ifaces, 10)
+	routes, err := Routes(tree)
+	require.NoError(t, err)
+	assert.Len(t, routes, 10)
+}
@@ -0,0 +1,72 @@
+package network
+
+import uci "github.com/wsiner/go-uci"
+
+// InterfaceRefs lists the options of OpenWrt packages, which are known
+// to refer to logical interfaces by name.
+//
+// The "interface" option of sqm queues is deliberately missing: it
+// names a Linux device (like "wan" or "eth0.2"), not a logical
+// interface.
+var InterfaceRefs = []uci.Ref{
+	{Config: "network", SectionType: "route", Option: "interface", TargetType: "interface"},
+	{Config: "network", SectionType: "route6", Option: "interface", TargetType: "interface"},
+	{Config: "network", SectionType: "rule", Option: "in", TargetType: "interface"},
+	{Config: "network", SectionType: "rule", Option: "out", TargetType: "interface"},
+	{Config: "network", SectionType: "rule6", Option: "in", TargetType: "interface"},
+	{Config: "network", SectionType: "rule6", Option: "out", TargetType: "interface"},
+	{Config: "network", SectionType: "alias", Option: "interface", TargetType: "interface"},
+	{Config: "wireless", SectionType: "wifi-iface", Option: "network", TargetType: "interface"},
+	{Config: "firewall", SectionType: "zone", Option: "network", TargetType: "interface"},
+	{Config: "dhcp", SectionType: "dhcp", Option: "interface", TargetType: "interface"},
+	{Config: "dhcp", SectionType: "dnsmasq", Option: "interface", TargetType: "interface"},
+	{Config: "dhcp", SectionType: "dnsmasq", Option: "notinterface", TargetType: "interface"},
+	{Config: "upnpd", SectionType: "upnpd", Option: "internal_iface", TargetType: "interface"},
+	{Config: "upnpd", SectionType: "upnpd", Option: "external_iface", TargetType: "interface"},
+	{Config: "ddns", SectionType: "service", Option: "interface", TargetType: "interface"},
+	{Config: "mwan3", SectionType: "member", Option: "interface", TargetType: "interface"},
+}
+
+// RenameInterface renames the logical interface old to name, and
+// updates all references to it (see InterfaceRefs). Additionally, the
+// type of sections specific to the interface (like the peers of a
+// WireGuard interface, "wireguard_<name>") is changed accordingly.
+//
+// It returns the paths of all touched sections and options. The changes
+// are only made in memory, until the tree is committed. If old does not
+// exist, is not an interface, or name is invalid or already taken,
+// nothing is changed.
+func RenameInterface(t uci.Tree, old, name string) ([]uci.Path, error) {
+	var changed []uci.Path
+	err := t.Batch(func(tx *uci.Tx) error {
+		if typ, ok := tx.SectionType("network", old); ok && typ != "interface" {
+			return uci.ErrSectionTypeMismatch{
+				Config:       "network",
+				Section:      old,
+				ExistingType: typ,
+				NewType:      "interface",
+			}
+		}
+		refs, err := tx.RenameSection("network", old, name, uci.RefMap{"network": InterfaceRefs})
+		if err != nil {
+			return err
+		}
+		changed = append([]uci.Path{{Config: "network", Section: name}}, refs...)
+
+		// selectors count sections of the type, so the first one is
+		// changed until none is left
+		peers, _ := tx.GetSections("network", "wireguard_"+old)
+		for range peers {
+			sec, err := tx.SetSectionType("network", "@wireguard_"+old+"[0]", "wireguard_"+name)
+			if err != nil {
+				return err // not reached, name is valid
+			}
+			changed = append(changed, uci.Path{Config: "network", Section: sec})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
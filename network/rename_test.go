@@ -0,0 +1,78 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestRenameInterface(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+
+	changed, err := RenameInterface(tree, "lan", "home")
+	assert.NoError(err)
+	assert.Equal([]uci.Path{
+		{Config: "network", Section: "home"},
+		{Config: "firewall", Section: "@zone[0]", Option: "network"},
+		{Config: "dhcp", Section: "lan", Option: "interface"},
+		{Config: "dhcp", Section: "@dnsmasq[0]", Option: "interface"},
+		{Config: "upnpd", Section: "config", Option: "internal_iface"},
+	}, changed)
+
+	values, _ := tree.Get("dhcp", "@dnsmasq[0]", "interface")
+	assert.Equal([]string{"home", "guest"}, values)
+	values, _ = tree.Get("upnpd", "config", "internal_iface")
+	assert.Equal([]string{"home"}, values)
+}
+
+func TestRenameInterfaceWireGuard(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+
+	changed, err := RenameInterface(tree, "wg0", "vpn")
+	assert.NoError(err)
+	assert.Equal([]uci.Path{
+		{Config: "network", Section: "vpn"},
+		{Config: "network", Section: "@wireguard_vpn[0]"},
+	}, changed)
+
+	peers, _ := tree.GetSections("network", "wireguard_vpn")
+	assert.Len(peers, 1)
+}
+
+func TestRenameInterfaceUndo(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree("../testdata")
+	tree.SetHistory(uci.NewHistory(10))
+
+	_, err := RenameInterface(tree, "wg0", "vpn")
+	assert.NoError(err)
+	assert.Equal(1, tree.Undo(1), "the rename is a single change")
+
+	_, ok := tree.Get("network", "wg0", "proto")
+	assert.True(ok)
+	peers, _ := tree.GetSections("network", "wireguard_wg0")
+	assert.Len(peers, 1)
+	peers, _ = tree.GetSections("network", "wireguard_vpn")
+	assert.Empty(peers)
+}
+
+func TestRenameInterfaceErrors(t *testing.T) {
+	tree := uci.NewTree("../testdata")
+
+	_, err := RenameInterface(tree, "globals", "foo")
+	assert.True(t, errors.As(err, &uci.ErrSectionTypeMismatch{}))
+
+	_, err = RenameInterface(tree, "lan", "wan")
+	assert.True(t, errors.Is(err, uci.ErrSectionExists{Config: "network", Section: "wan"}))
+
+	_, err = RenameInterface(tree, "lan", "")
+	assert.True(t, errors.Is(err, uci.ErrInvalidName))
+
+	// nothing changed
+	_, ok := tree.Get("network", "lan", "proto")
+	assert.True(t, ok)
+}
@@ -0,0 +1,79 @@
+package network
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// Route is a static route ("config route" or "config route6", depending
+// on the address family of the target).
+type Route struct {
+	Interface string
+	Target    netip.Prefix
+	Gateway   netip.Addr
+	Metric    int // 0 means unset
+	Table     string
+}
+
+// ParseRoute converts a route or route6 section. It accepts both a
+// target in CIDR notation, and the "target"/"netmask" pair.
+func ParseRoute(sec *uci.Section) (*Route, error) {
+	wrap := func(err error) (*Route, error) {
+		return nil, fmt.Errorf("route %s: %w", sec.Name, err)
+	}
+
+	targets, err := parseAddresses([]string{sec.LastValue("target")}, sec.LastValue("netmask"))
+	if err != nil {
+		return wrap(err)
+	}
+	r := &Route{
+		Interface: sec.LastValue("interface"),
+		Target:    targets[0].Masked(),
+		Table:     sec.LastValue("table"),
+	}
+	if r.Gateway, err = parseAddr(sec.LastValue("gateway")); err != nil {
+		return wrap(err)
+	}
+	if r.Metric, err = parseInt(sec.LastValue("metric")); err != nil {
+		return wrap(err)
+	}
+	if err := r.Validate(); err != nil {
+		return wrap(err)
+	}
+	return r, nil
+}
+
+// Validate checks that the gateway has the same address family as the
+// target.
+func (r *Route) Validate() error {
+	if r.Gateway.IsValid() && r.Gateway.Is4() != r.Target.Addr().Is4() {
+		return fmt.Errorf("%w: target %s, gateway %s", ErrFamilyMismatch, r.Target, r.Gateway)
+	}
+	return nil
+}
+
+// Section renders r into an unnamed route or route6 section.
+func (r *Route) Section() *uci.Section {
+	typ := "route"
+	if r.Target.Addr().Is6() {
+		typ = "route6"
+	}
+	s := uci.NewSection(typ, "")
+	set := func(name, value string) {
+		if value != "" {
+			s.Add(uci.NewOption(name, uci.TypeOption, value))
+		}
+	}
+
+	set("interface", r.Interface)
+	set("target", r.Target.String())
+	set("gateway", addrString(r.Gateway))
+	if r.Metric > 0 {
+		set("metric", strconv.Itoa(r.Metric))
+	}
+	set("table", r.Table)
+	return s
+}
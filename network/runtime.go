@@ -0,0 +1,274 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// RuntimeInterface is the state of a logical interface, as reported by
+// netifd ("ubus call network.interface dump").
+type RuntimeInterface struct {
+	Name      string `json:"interface"`
+	Up        bool   `json:"up"`
+	Pending   bool   `json:"pending"`
+	Available bool   `json:"available"`
+	Autostart bool   `json:"autostart"`
+	Dynamic   bool   `json:"dynamic"` // created by another interface, e.g. wan_6
+	Uptime    int    `json:"uptime"`  // seconds
+	Proto     string `json:"proto"`
+	Device    string `json:"device"`
+	L3Device  string `json:"l3_device"`
+
+	Addresses  []RuntimeAddress `json:"ipv4-address"`
+	Addresses6 []RuntimeAddress `json:"ipv6-address"`
+	Routes     []RuntimeRoute   `json:"route"`
+	DNS        []string         `json:"dns-server"`
+	DNSSearch  []string         `json:"dns-search"`
+}
+
+// RuntimeAddress is an address of a RuntimeInterface.
+type RuntimeAddress struct {
+	Address string `json:"address"`
+	Mask    int    `json:"mask"`
+}
+
+// RuntimeRoute is a route of a RuntimeInterface.
+type RuntimeRoute struct {
+	Target  string `json:"target"`
+	Mask    int    `json:"mask"`
+	Nexthop string `json:"nexthop"`
+}
+
+// ParseDump decodes the output of "ubus call network.interface dump".
+func ParseDump(data []byte) ([]*RuntimeInterface, error) {
+	var dump struct {
+		Interfaces []*RuntimeInterface `json:"interface"`
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("network.interface dump: %w", err)
+	}
+	return dump.Interfaces, nil
+}
+
+// Interface converts the runtime state into an Interface, as it would
+// be configured statically: the gateways are taken from the default
+// routes. Interfaces which are not up are marked as disabled.
+func (ri *RuntimeInterface) Interface() (*Interface, error) {
+	iface := &Interface{
+		Name:     ri.Name,
+		Proto:    ri.Proto,
+		Device:   ri.Device,
+		Disabled: !ri.Up,
+	}
+	if iface.Device == "" {
+		iface.Device = ri.L3Device
+	}
+
+	wrap := func(err error) (*Interface, error) {
+		return nil, fmt.Errorf("interface %s: %w", ri.Name, err)
+	}
+
+	var err error
+	if iface.Addresses, err = runtimePrefixes(ri.Addresses); err != nil {
+		return wrap(err)
+	}
+	if iface.Addresses6, err = runtimePrefixes(ri.Addresses6); err != nil {
+		return wrap(err)
+	}
+	for _, r := range ri.Routes {
+		if r.Mask != 0 || r.Nexthop == "" {
+			continue
+		}
+		gw, err := parseAddr(r.Nexthop)
+		if err != nil {
+			return wrap(err)
+		}
+		switch {
+		case gw.Is4() && !iface.Gateway.IsValid():
+			iface.Gateway = gw
+		case gw.Is6() && !iface.Gateway6.IsValid() && !gw.IsUnspecified():
+			iface.Gateway6 = gw
+		}
+	}
+	for _, v := range ri.DNS {
+		addr, err := parseAddr(v)
+		if err != nil {
+			return wrap(err)
+		}
+		iface.DNS = append(iface.DNS, addr)
+	}
+	return iface, nil
+}
+
+// RuntimeConfig converts the output of "ubus call network.interface
+// dump" into a pseudo network config, with an interface section per
+// logical interface (see RuntimeInterface.Interface).
+func RuntimeConfig(data []byte) (*uci.Config, error) {
+	ris, err := ParseDump(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg := uci.NewConfig("network")
+	for _, ri := range ris {
+		iface, err := ri.Interface()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Add(iface.Section())
+	}
+	return cfg, nil
+}
+
+// A Mismatch is a difference between the configured and the runtime
+// state of an interface.
+type Mismatch struct {
+	Interface string
+	Option    string // e.g. "proto" or "ipaddr", or "interface" if it is missing
+	Config    string // configured value(s)
+	Runtime   string // runtime value(s)
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("interface %s: %s is %q, but configured %q", m.Interface, m.Option, m.Runtime, m.Config)
+}
+
+// CompareRuntime reports how the runtime state differs from the
+// configured interfaces:
+//
+//   - interfaces missing at runtime, or not configured (unless dynamic)
+//   - interfaces starting automatically, which are not up, and disabled
+//     ones which are up
+//   - the protocol, and the device (unless it's an alias like "@wan")
+//   - the addresses and gateways of static interfaces; additional IPv6
+//     addresses, e.g. from prefix delegation, are ignored
+//   - configured DNS servers not in use
+//
+// Interfaces which are down are only compared for being down.
+func CompareRuntime(ifaces []*Interface, runtime []*RuntimeInterface) ([]Mismatch, error) {
+	var mismatches []Mismatch
+	add := func(name, option, config, runtime string) {
+		mismatches = append(mismatches, Mismatch{Interface: name, Option: option, Config: config, Runtime: runtime})
+	}
+
+	states := make(map[string]*RuntimeInterface, len(runtime))
+	for _, ri := range runtime {
+		states[ri.Name] = ri
+	}
+	configured := make(map[string]bool, len(ifaces))
+	for _, want := range ifaces {
+		configured[want.Name] = true
+		ri := states[want.Name]
+		if ri == nil {
+			add(want.Name, "interface", "present", "missing")
+			continue
+		}
+		switch {
+		case want.Disabled && ri.Up:
+			add(want.Name, "disabled", "1", "0")
+		case !want.Disabled && !ri.Up && ri.Autostart && !ri.Pending:
+			add(want.Name, "disabled", "0", "1")
+		}
+		if !ri.Up {
+			continue
+		}
+
+		have, err := ri.Interface()
+		if err != nil {
+			return nil, err
+		}
+		if want.Proto != "" && want.Proto != have.Proto {
+			add(want.Name, "proto", want.Proto, have.Proto)
+		}
+		if want.Device != "" && !strings.HasPrefix(want.Device, "@") &&
+			want.Device != ri.Device && want.Device != ri.L3Device {
+			add(want.Name, "device", want.Device, have.Device)
+		}
+		if want.Proto == "static" {
+			if !equalPrefixes(want.Addresses, have.Addresses) {
+				add(want.Name, "ipaddr", joinPrefixes(want.Addresses), joinPrefixes(have.Addresses))
+			}
+			if !containsPrefixes(have.Addresses6, want.Addresses6) {
+				add(want.Name, "ip6addr", joinPrefixes(want.Addresses6), joinPrefixes(have.Addresses6))
+			}
+			if want.Gateway.IsValid() && want.Gateway != have.Gateway {
+				add(want.Name, "gateway", addrString(want.Gateway), addrString(have.Gateway))
+			}
+			if want.Gateway6.IsValid() && want.Gateway6 != have.Gateway6 {
+				add(want.Name, "ip6gw", addrString(want.Gateway6), addrString(have.Gateway6))
+			}
+		}
+		for _, dns := range want.DNS {
+			if !slices.Contains(have.DNS, dns) {
+				add(want.Name, "dns", joinAddrs(want.DNS), joinAddrs(have.DNS))
+				break
+			}
+		}
+	}
+	for _, ri := range runtime {
+		if !configured[ri.Name] && !ri.Dynamic {
+			add(ri.Name, "interface", "missing", "present")
+		}
+	}
+	return mismatches, nil
+}
+
+// CheckRuntime compares the interfaces of the network config with the
+// output of "ubus call network.interface dump" (see CompareRuntime).
+func CheckRuntime(t uci.Tree, dump []byte) ([]Mismatch, error) {
+	ifaces, err := Interfaces(t)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := ParseDump(dump)
+	if err != nil {
+		return nil, err
+	}
+	return CompareRuntime(ifaces, runtime)
+}
+
+func runtimePrefixes(addrs []RuntimeAddress) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(addrs))
+	for _, a := range addrs {
+		addr, err := netip.ParseAddr(a.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidAddress, a.Address)
+		}
+		p, err := addr.Prefix(a.Mask)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: mask %d", ErrInvalidAddress, a.Address, a.Mask)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, p.Bits()))
+	}
+	return prefixes, nil
+}
+
+func equalPrefixes(a, b []netip.Prefix) bool {
+	return len(a) == len(b) && containsPrefixes(a, b)
+}
+
+// containsPrefixes reports whether all prefixes of want are in have.
+func containsPrefixes(have, want []netip.Prefix) bool {
+	for _, p := range want {
+		if !slices.Contains(have, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	return strings.Join(prefixStrings(prefixes), " ")
+}
+
+func joinAddrs(addrs []netip.Addr) string {
+	s := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		s = append(s, a.String())
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+const tcDump = `{
+	"interface": [
+		{
+			"interface": "lan", "up": true, "pending": false, "available": true, "autostart": true,
+			"dynamic": false, "uptime": 3600, "l3_device": "br-lan", "proto": "static", "device": "br-lan",
+			"ipv4-address": [{"address": "192.168.1.1", "mask": 24}],
+			"ipv6-address": [{"address": "fd12:3456:789a::1", "mask": 60}],
+			"route": [], "dns-server": [], "dns-search": []
+		},
+		{
+			"interface": "loopback", "up": true, "autostart": true, "proto": "static", "device": "lo", "l3_device": "lo",
+			"ipv4-address": [{"address": "127.0.0.1", "mask": 8}]
+		},
+		{
+			"interface": "guest", "up": true, "autostart": true, "proto": "static", "device": "br-guest",
+			"ipv4-address": [{"address": "192.168.3.1", "mask": 24}]
+		},
+		{
+			"interface": "wan", "up": true, "autostart": true, "proto": "dhcp", "device": "wan", "l3_device": "wan",
+			"ipv4-address": [{"address": "203.0.113.7", "mask": 24}],
+			"route": [{"target": "0.0.0.0", "mask": 0, "nexthop": "203.0.113.1", "source": "203.0.113.7/32"}],
+			"dns-server": ["203.0.113.53"]
+		},
+		{
+			"interface": "wan6", "up": false, "pending": true, "autostart": true, "proto": "dhcpv6", "device": "wan"
+		},
+		{
+			"interface": "wan_6", "up": true, "dynamic": true, "proto": "static", "device": "wan",
+			"route": [{"target": "::", "mask": 0, "nexthop": "fe80::1"}]
+		},
+		{
+			"interface": "vpn", "up": false, "autostart": false, "proto": "none"
+		}
+	]
+}`
+
+func TestRuntimeConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ris, err := ParseDump([]byte(tcDump))
+	require.NoError(err)
+	require.Len(ris, 7)
+
+	wan, err := ris[3].Interface()
+	require.NoError(err)
+	assert.Equal(netip.MustParseAddr("203.0.113.1"), wan.Gateway)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("203.0.113.7/24")}, wan.Addresses)
+	wan6, err := ris[5].Interface()
+	require.NoError(err)
+	assert.Equal(netip.MustParseAddr("fe80::1"), wan6.Gateway6)
+
+	cfg, err := RuntimeConfig([]byte(tcDump))
+	require.NoError(err)
+	lan := cfg.Get("lan")
+	require.NotNil(lan)
+	assert.Equal("192.168.1.1", lan.LastValue("ipaddr"))
+	assert.Equal("255.255.255.0", lan.LastValue("netmask"))
+	assert.Equal("1", cfg.Get("vpn").LastValue("disabled"))
+
+	_, err = ParseDump([]byte("{"))
+	assert.Error(err)
+	_, err = RuntimeConfig([]byte(`{"interface": [{"interface": "lan", "ipv4-address": [{"address": "x"}]}]}`))
+	assert.ErrorIs(err, ErrInvalidAddress)
+}
+
+func TestCheckRuntime(t *testing.T) {
+	mismatches, err := CheckRuntime(uci.NewTree("../testdata"), []byte(tcDump))
+	require.NoError(t, err)
+	assert.Equal(t, []Mismatch{
+		{Interface: "guest", Option: "ipaddr", Config: "192.168.2.1/24", Runtime: "192.168.3.1/24"},
+		{Interface: "wg0", Option: "interface", Config: "present", Runtime: "missing"},
+		{Interface: "vpn", Option: "interface", Config: "missing", Runtime: "present"},
+	}, mismatches)
+	assert.Equal(t, `interface guest: ipaddr is "192.168.3.1/24", but configured "192.168.2.1/24"`, mismatches[0].String())
+
+	// disabled interfaces must be down, enabled ones up
+	down := []*RuntimeInterface{{Name: "lan", Autostart: true}, {Name: "guest", Up: true}}
+	mismatches, err = CompareRuntime([]*Interface{{Name: "lan"}, {Name: "guest", Disabled: true}}, down)
+	require.NoError(t, err)
+	assert.Equal(t, []Mismatch{
+		{Interface: "lan", Option: "disabled", Config: "0", Runtime: "1"},
+		{Interface: "guest", Option: "disabled", Config: "1", Runtime: "0"},
+	}, mismatches)
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"errors"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// ErrNoConfig is returned, if the network config does not exist.
+var ErrNoConfig = errors.New("network config not found")
+
+// Interfaces returns all interfaces of the network config.
+func Interfaces(t uci.Tree) ([]*Interface, error) {
+	var ifaces []*Interface
+	err := each(t, "interface", func(sec *uci.Section) error {
+		iface, err := ParseInterface(sec)
+		if err == nil {
+			ifaces = append(ifaces, iface)
+		}
+		return err
+	})
+	return ifaces, err
+}
+
+// Devices returns all devices of the network config.
+func Devices(t uci.Tree) ([]*Device, error) {
+	var devs []*Device
+	err := each(t, "device", func(sec *uci.Section) error {
+		dev, err := ParseDevice(sec)
+		if err == nil {
+			devs = append(devs, dev)
+		}
+		return err
+	})
+	return devs, err
+}
+
+// Routes returns all IPv4 and IPv6 routes of the network config.
+func Routes(t uci.Tree) ([]*Route, error) {
+	var routes []*Route
+	for _, typ := range []string{"route", "route6"} {
+		err := each(t, typ, func(sec *uci.Section) error {
+			r, err := ParseRoute(sec)
+			if err == nil {
+				routes = append(routes, r)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return routes, nil
+}
+
+func each(t uci.Tree, typ string, fn func(*uci.Section) error) error {
+	cfg, ok := t.EnsureConfigLoaded("network")
+	if !ok {
+		return ErrNoConfig
+	}
+	for _, sec := range cfg.Sections {
+		if sec.Type != typ {
+			continue
+		}
+		if err := fn(sec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutInterface validates iface, and stores it in the network config,
+// creating the section (and config), if necessary. Options not
+// represented by Interface (e.g. proto specific ones) are kept.
+func PutInterface(t uci.Tree, iface *Interface) error {
+	if err := iface.Validate(); err != nil {
+		return err
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		_, err := tx.PutSection("network", iface.Section(), interfaceOptions...)
+		return err
+	})
+}
+
+// PutDevice stores dev in the network config. An existing device with
+// the same name is updated, otherwise a new device section is appended.
+func PutDevice(t uci.Tree, dev *Device) error {
+	return t.Batch(func(tx *uci.Tx) error {
+		cfg, ok := tx.CopyConfig("network")
+		if !ok {
+			return ErrNoConfig
+		}
+		for _, sec := range cfg.Sections {
+			if sec.Type == "device" && sec.LastValue("name") == dev.Name {
+				return tx.ReplaceOptions("network", cfg.SectionName(sec), dev.Section(), deviceOptions...)
+			}
+		}
+		_, err := tx.PutSection("network", dev.Section())
+		return err
+	})
+}
+
+// AddRoute validates r, and appends it to the network config.
+func AddRoute(t uci.Tree, r *Route) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		if _, ok := tx.CopyConfig("network"); !ok {
+			return ErrNoConfig
+		}
+		_, err := tx.PutSection("network", r.Section())
+		return err
+	})
+}
@@ -0,0 +1,376 @@
+// Package openvpn provides typed access to the openvpn config, whose
+// "config openvpn" sections are instances of OpenVPN started by
+// OpenWrt's init script:
+//
+//	inst := &openvpn.Instance{
+//		Name: "server", Enabled: true, Dev: "tun", Proto: "udp", Port: 1194,
+//		Server: netip.MustParsePrefix("10.8.0.0/24"),
+//		CA: "/etc/openvpn/ca.crt", Cert: "/etc/openvpn/server.crt", Key: "/etc/openvpn/server.key",
+//	}
+//	inst.PushRoute(netip.MustParsePrefix("192.168.1.0/24"))
+//	inst.PushDNS(netip.MustParseAddr("192.168.1.1"))
+//	err := openvpn.PutInstance(tree, inst)
+package openvpn
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrNoConfig       = errors.New("openvpn config not found")
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidNumber  = errors.New("invalid number")
+	ErrInvalidProto   = errors.New("invalid protocol")
+	ErrInvalidDev     = errors.New("invalid device, expected tun or tap")
+	ErrMissingRemote  = errors.New("client without remote")
+	ErrIncompatible   = errors.New("incompatible options")
+)
+
+// protos lists the values of the proto option.
+var protos = []string{"udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tcp-server", "tcp-client", "tcp4-server", "tcp4-client", "tcp6-server", "tcp6-client"}
+
+// Instance is an OpenVPN instance ("config openvpn").
+type Instance struct {
+	Name    string
+	Enabled bool
+
+	// Config is the path of an OpenVPN config file. If set, OpenWrt
+	// ignores the other options.
+	Config string
+
+	Dev    string // e.g. "tun" or "tap0"
+	Proto  string // e.g. "udp" or "tcp-server", see the proto option
+	Port   int    // 0 means 1194
+	Server netip.Prefix
+	Client bool
+	Remote []Remote
+
+	CA, Cert, Key, DH string // paths
+	TLSCrypt          string // path
+	Cipher            string
+	DataCiphers       []string // "data_ciphers", separated by colons
+
+	KeepaliveInterval int // seconds, 0 means unset
+	KeepaliveTimeout  int
+
+	Routes []netip.Prefix // routes to add, "route" list
+	Push   []string       // directives pushed to clients, "push" list
+	Verb   int            // log verbosity, 0 means unset
+}
+
+// A Remote is a server a client connects to.
+type Remote struct {
+	Host string
+	Port int // 0 means the instance's port
+}
+
+// String formats r like the values of the remote list.
+func (r Remote) String() string {
+	if r.Port == 0 {
+		return r.Host
+	}
+	return r.Host + " " + strconv.Itoa(r.Port)
+}
+
+// instanceOptions lists the options managed by Instance.
+var instanceOptions = []string{
+	"enabled", "config", "dev", "proto", "port", "server", "client", "remote",
+	"ca", "cert", "key", "dh", "tls_crypt", "cipher", "data_ciphers",
+	"keepalive", "route", "push", "verb",
+}
+
+// ParseInstance converts an openvpn section.
+func ParseInstance(sec *uci.Section) (*Instance, error) {
+	inst := &Instance{
+		Name:        sec.Name,
+		Enabled:     parseBool(sec.LastValue("enabled")),
+		Config:      sec.LastValue("config"),
+		Dev:         sec.LastValue("dev"),
+		Proto:       sec.LastValue("proto"),
+		Client:      parseBool(sec.LastValue("client")),
+		CA:          sec.LastValue("ca"),
+		Cert:        sec.LastValue("cert"),
+		Key:         sec.LastValue("key"),
+		DH:          sec.LastValue("dh"),
+		TLSCrypt:    sec.LastValue("tls_crypt"),
+		Cipher:      sec.LastValue("cipher"),
+		DataCiphers: split(sec.LastValue("data_ciphers"), ":"),
+		Push:        sec.Value("push"),
+	}
+	wrap := func(err error) (*Instance, error) {
+		return nil, fmt.Errorf("openvpn %s: %w", sec.Name, err)
+	}
+
+	var err error
+	if inst.Port, err = parseInt(sec.LastValue("port")); err != nil {
+		return wrap(err)
+	}
+	if inst.Verb, err = parseInt(sec.LastValue("verb")); err != nil {
+		return wrap(err)
+	}
+	if v := sec.LastValue("server"); v != "" {
+		if inst.Server, err = parseNetwork(v); err != nil {
+			return wrap(err)
+		}
+	}
+	for _, v := range sec.Value("route") {
+		p, err := parseNetwork(v)
+		if err != nil {
+			return wrap(err)
+		}
+		inst.Routes = append(inst.Routes, p)
+	}
+	for _, v := range sec.Value("remote") {
+		f := strings.Fields(v)
+		if len(f) == 0 {
+			continue
+		}
+		r := Remote{Host: f[0]}
+		if len(f) > 1 {
+			if r.Port, err = parseInt(f[1]); err != nil {
+				return wrap(err)
+			}
+		}
+		inst.Remote = append(inst.Remote, r)
+	}
+	if f := strings.Fields(sec.LastValue("keepalive")); len(f) > 0 {
+		if inst.KeepaliveInterval, err = parseInt(f[0]); err != nil {
+			return wrap(err)
+		}
+		if len(f) > 1 {
+			if inst.KeepaliveTimeout, err = parseInt(f[1]); err != nil {
+				return wrap(err)
+			}
+		}
+	}
+	return inst, nil
+}
+
+// Validate checks the protocol, device and port, and that the instance
+// is either a server or a client with remotes. Instances using a config
+// file aren't checked.
+func (inst *Instance) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("openvpn %s: %w", inst.Name, err)
+	}
+	if inst.Config != "" {
+		return nil
+	}
+	if inst.Proto != "" && !slices.Contains(protos, inst.Proto) {
+		return wrap(fmt.Errorf("%w %q", ErrInvalidProto, inst.Proto))
+	}
+	if inst.Dev != "" && !strings.HasPrefix(inst.Dev, "tun") && !strings.HasPrefix(inst.Dev, "tap") {
+		return wrap(fmt.Errorf("%w: %q", ErrInvalidDev, inst.Dev))
+	}
+	if inst.Port < 0 || inst.Port > 65535 {
+		return wrap(fmt.Errorf("%w: port %d", ErrInvalidNumber, inst.Port))
+	}
+	if inst.Client && inst.Server.IsValid() {
+		return wrap(fmt.Errorf("%w: client and server", ErrIncompatible))
+	}
+	if inst.Client && len(inst.Remote) == 0 {
+		return wrap(ErrMissingRemote)
+	}
+	if inst.Server.IsValid() && !inst.Server.Addr().Is4() {
+		return wrap(fmt.Errorf("%w: server %s is no IPv4 network", ErrInvalidAddress, inst.Server))
+	}
+	return nil
+}
+
+// PushRoute makes the server push a route to p to its clients.
+func (inst *Instance) PushRoute(p netip.Prefix) {
+	inst.Push = append(inst.Push, "route "+formatNetwork(p))
+}
+
+// PushDNS makes the server push a DNS server to its clients.
+func (inst *Instance) PushDNS(addr netip.Addr) {
+	inst.Push = append(inst.Push, "dhcp-option DNS "+addr.String())
+}
+
+// PushRedirectGateway makes the clients route all traffic through the
+// VPN.
+func (inst *Instance) PushRedirectGateway() {
+	inst.Push = append(inst.Push, "redirect-gateway def1")
+}
+
+// Section renders inst into an openvpn section.
+func (inst *Instance) Section() *uci.Section {
+	s := uci.NewSection("openvpn", inst.Name)
+	set := func(name, value string) {
+		if value != "" {
+			s.SetOption(name, value)
+		}
+	}
+	setInt := func(name string, n int) {
+		if n > 0 {
+			s.SetOption(name, strconv.Itoa(n))
+		}
+	}
+	setList := func(name string, values []string) {
+		if len(values) > 0 {
+			s.SetList(name, values...)
+		}
+	}
+
+	s.SetOption("enabled", boolString(inst.Enabled))
+	set("config", inst.Config)
+	set("dev", inst.Dev)
+	set("proto", inst.Proto)
+	setInt("port", inst.Port)
+	if inst.Server.IsValid() {
+		set("server", formatNetwork(inst.Server))
+	}
+	if inst.Client {
+		set("client", "1")
+	}
+	remotes := make([]string, 0, len(inst.Remote))
+	for _, r := range inst.Remote {
+		remotes = append(remotes, r.String())
+	}
+	setList("remote", remotes)
+	set("ca", inst.CA)
+	set("cert", inst.Cert)
+	set("key", inst.Key)
+	set("dh", inst.DH)
+	set("tls_crypt", inst.TLSCrypt)
+	set("cipher", inst.Cipher)
+	set("data_ciphers", strings.Join(inst.DataCiphers, ":"))
+	if inst.KeepaliveInterval > 0 {
+		set("keepalive", strconv.Itoa(inst.KeepaliveInterval)+" "+strconv.Itoa(inst.KeepaliveTimeout))
+	}
+	routes := make([]string, 0, len(inst.Routes))
+	for _, p := range inst.Routes {
+		routes = append(routes, formatNetwork(p))
+	}
+	setList("route", routes)
+	setList("push", inst.Push)
+	setInt("verb", inst.Verb)
+	return s
+}
+
+// Instances returns all instances of the openvpn config.
+func Instances(t uci.Tree) ([]*Instance, error) {
+	cfg, ok := t.EnsureConfigLoaded("openvpn")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	var insts []*Instance
+	for _, sec := range cfg.Sections {
+		if sec.Type != "openvpn" {
+			continue
+		}
+		inst, err := ParseInstance(sec)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+	}
+	return insts, nil
+}
+
+// GetInstance returns the named instance.
+func GetInstance(t uci.Tree, name string) (*Instance, error) {
+	cfg, ok := t.EnsureConfigLoaded("openvpn")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	sec := cfg.Get(name)
+	if sec == nil {
+		return nil, uci.ErrSectionNotFound{Config: "openvpn", Section: name}
+	}
+	return ParseInstance(sec)
+}
+
+// PutInstance validates inst, and stores it in the openvpn config,
+// creating the section (and config), if necessary. Options not
+// represented by Instance are kept.
+func PutInstance(t uci.Tree, inst *Instance) error {
+	if err := inst.Validate(); err != nil {
+		return err
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		_, err := tx.PutSection("openvpn", inst.Section(), instanceOptions...)
+		return err
+	})
+}
+
+// parseNetwork parses an "address netmask" pair, as used by the server
+// and route options, or a prefix in CIDR notation.
+func parseNetwork(v string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(v); err == nil {
+		return p.Masked(), nil
+	}
+	f := strings.Fields(v)
+	if len(f) != 2 {
+		return netip.Prefix{}, fmt.Errorf("%w %q", ErrInvalidAddress, v)
+	}
+	addr, err := netip.ParseAddr(f[0])
+	mask, merr := netip.ParseAddr(f[1])
+	if err != nil || merr != nil || !addr.Is4() || !mask.Is4() {
+		return netip.Prefix{}, fmt.Errorf("%w %q", ErrInvalidAddress, v)
+	}
+	b := mask.As4()
+	m := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	bits := 0
+	for m&(1<<31) != 0 {
+		bits++
+		m <<= 1
+	}
+	if m != 0 {
+		return netip.Prefix{}, fmt.Errorf("%w %q: netmask not contiguous", ErrInvalidAddress, v)
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// formatNetwork formats p as "address netmask".
+func formatNetwork(p netip.Prefix) string {
+	if !p.Addr().Is4() {
+		return p.String()
+	}
+	m := ^uint32(0) << (32 - p.Bits())
+	if p.Bits() == 0 {
+		m = 0
+	}
+	mask := netip.AddrFrom4([4]byte{byte(m >> 24), byte(m >> 16), byte(m >> 8), byte(m)})
+	return p.Masked().Addr().String() + " " + mask.String()
+}
+
+func split(v, sep string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, sep)
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+	}
+	return n, nil
+}
+
+func parseBool(v string) bool {
+	switch v {
+	case "1", "on", "true", "yes", "enabled":
+		return true
+	}
+	return false
+}
+
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,154 @@
+package openvpn
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestNetwork(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		out  string
+	}{
+		{"10.8.0.0 255.255.255.0", "10.8.0.0/24", "10.8.0.0 255.255.255.0"},
+		{"10.8.0.1 255.255.0.0", "10.8.0.0/16", "10.8.0.0 255.255.0.0"},
+		{"192.168.1.0/24", "192.168.1.0/24", "192.168.1.0 255.255.255.0"},
+		{"0.0.0.0 0.0.0.0", "0.0.0.0/0", "0.0.0.0 0.0.0.0"},
+	} {
+		p, err := parseNetwork(tc.in)
+		if assert.NoError(t, err, tc.in) {
+			assert.Equal(t, netip.MustParsePrefix(tc.want), p)
+			assert.Equal(t, tc.out, formatNetwork(p))
+		}
+	}
+	for _, in := range []string{"", "10.8.0.0", "10.8.0.0 255.0.255.0", "10.8.0.0 x", "fd00:: ffff::"} {
+		_, err := parseNetwork(in)
+		assert.ErrorIs(t, err, ErrInvalidAddress, in)
+	}
+}
+
+func TestInstances(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "openvpn"), []byte(`
+config openvpn 'server'
+	option enabled '1'
+	option dev 'tun'
+	option proto 'udp'
+	option port '1194'
+	option server '10.8.0.0 255.255.255.0'
+	option ca '/etc/openvpn/ca.crt'
+	option data_ciphers 'AES-256-GCM:CHACHA20-POLY1305'
+	option keepalive '10 120'
+	list push 'route 192.168.1.0 255.255.255.0'
+	list push 'dhcp-option DNS 192.168.1.1'
+	option custom 'kept'
+
+config openvpn 'client'
+	option client '1'
+	option dev 'tun'
+	list remote 'vpn.example.com 1194'
+	list remote 'backup.example.com'
+
+config openvpn 'custom'
+	option config '/etc/openvpn/custom.ovpn'
+`), 0o644))
+	tree := uci.NewTree(dir)
+
+	insts, err := Instances(tree)
+	require.NoError(err)
+	require.Len(insts, 3)
+	srv := insts[0]
+	assert.True(srv.Enabled)
+	assert.Equal(netip.MustParsePrefix("10.8.0.0/24"), srv.Server)
+	assert.Equal([]string{"AES-256-GCM", "CHACHA20-POLY1305"}, srv.DataCiphers)
+	assert.Equal(10, srv.KeepaliveInterval)
+	assert.Equal(120, srv.KeepaliveTimeout)
+	assert.Len(srv.Push, 2)
+	assert.Equal([]Remote{{"vpn.example.com", 1194}, {"backup.example.com", 0}}, insts[1].Remote)
+	assert.Equal("/etc/openvpn/custom.ovpn", insts[2].Config)
+	for _, inst := range insts {
+		assert.NoError(inst.Validate())
+	}
+
+	srv.Push = nil
+	srv.PushRoute(netip.MustParsePrefix("192.168.2.0/24"))
+	srv.PushDNS(netip.MustParseAddr("192.168.2.1"))
+	srv.PushRedirectGateway()
+	srv.Routes = []netip.Prefix{netip.MustParsePrefix("192.168.10.0/24")}
+	srv.KeepaliveInterval = 0
+	require.NoError(PutInstance(tree, srv))
+
+	srv, err = GetInstance(tree, "server")
+	require.NoError(err)
+	assert.Equal([]string{"route 192.168.2.0 255.255.255.0", "dhcp-option DNS 192.168.2.1", "redirect-gateway def1"}, srv.Push)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("192.168.10.0/24")}, srv.Routes)
+	route, _ := tree.GetLast("openvpn", "server", "route")
+	assert.Equal("192.168.10.0 255.255.255.0", route)
+	cfg, _ := tree.EnsureConfigLoaded("openvpn")
+	assert.Nil(cfg.Get("server").Get("keepalive"))
+	custom, _ := tree.GetLast("openvpn", "server", "custom")
+	assert.Equal("kept", custom)
+
+	require.NoError(PutInstance(tree, &Instance{Name: "new", Client: true, Remote: []Remote{{Host: "vpn.example.com"}}}))
+	enabled, _ := tree.GetLast("openvpn", "new", "enabled")
+	assert.Equal("0", enabled)
+
+	// changes run the tree's hooks
+	errDenied := errors.New("denied")
+	tree.OnSet(func(e *uci.SetEvent) error {
+		if e.Option == "enabled" {
+			return errDenied
+		}
+		return nil
+	})
+	assert.ErrorIs(PutInstance(tree, &Instance{Name: "other", Client: true, Remote: []Remote{{Host: "vpn.example.com"}}}), errDenied)
+	_, err = GetInstance(tree, "other")
+	assert.ErrorAs(err, new(uci.ErrSectionNotFound))
+
+	_, err = GetInstance(tree, "missing")
+	assert.ErrorAs(err, new(uci.ErrSectionNotFound))
+	_, err = Instances(uci.NewTree(t.TempDir()))
+	assert.ErrorIs(err, ErrNoConfig)
+
+	sec := uci.NewSection("openvpn", "bad")
+	sec.SetOption("port", "x")
+	_, err = ParseInstance(sec)
+	assert.ErrorIs(err, ErrInvalidNumber)
+}
+
+func TestValidate(t *testing.T) {
+	server := netip.MustParsePrefix("10.8.0.0/24")
+	for _, tc := range []struct {
+		name string
+		inst Instance
+		err  error
+	}{
+		{"proto", Instance{Name: "vpn", Proto: "sctp"}, ErrInvalidProto},
+		{"dev", Instance{Name: "vpn", Dev: "eth0"}, ErrInvalidDev},
+		{"port", Instance{Name: "vpn", Port: 70000}, ErrInvalidNumber},
+		{"client and server", Instance{Name: "vpn", Client: true, Server: server, Remote: []Remote{{Host: "a"}}}, ErrIncompatible},
+		{"no remote", Instance{Name: "vpn", Client: true}, ErrMissingRemote},
+		{"ipv6 server", Instance{Name: "vpn", Server: netip.MustParsePrefix("fd00::/64")}, ErrInvalidAddress},
+		{"config file", Instance{Name: "vpn", Config: "/etc/openvpn/vpn.ovpn", Proto: "sctp"}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.inst.Validate()
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
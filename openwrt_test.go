@@ -0,0 +1,80 @@
+package uci
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// TestOpenWrtRoundTrip parses the default configs of several OpenWrt
+// releases (testdata/openwrt/<release>/<config>), writes them, and
+// parses the result again. Both ASTs must be identical, and writing the
+// reparsed config must reproduce the output.
+func TestOpenWrtRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "openwrt", "*", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		file := file
+		release, name := filepath.Base(filepath.Dir(file)), filepath.Base(file)
+		t.Run(release+"/"+name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			input, err := ioutil.ReadFile(file)
+			require.NoError(err)
+			cfg, err := ast.Parse(name, string(input))
+			require.NoError(err)
+			require.NotEmpty(cfg.Sections)
+
+			var written bytes.Buffer
+			_, err = cfg.WriteTo(&written)
+			require.NoError(err)
+			reparsed, err := ast.Parse(name, written.String())
+			require.NoError(err)
+			assert.Equal(cfg.Sections, reparsed.Sections)
+			assert.Empty(ast.Diff(cfg, reparsed))
+
+			var again bytes.Buffer
+			_, err = reparsed.WriteTo(&again)
+			require.NoError(err)
+			assert.Equal(written.String(), again.String())
+		})
+	}
+}
+
+// TestOpenWrtReleases loads the configs of each release with a tree,
+// and checks a few values, which changed between the releases.
+func TestOpenWrtReleases(t *testing.T) {
+	for _, tc := range []struct {
+		release string
+		path    [3]string
+		values  []string
+	}{
+		{"21.02", [3]string{"network", "lan", "ipaddr"}, []string{"192.168.1.1"}},
+		{"23.05", [3]string{"network", "lan", "ipaddr"}, []string{"192.168.1.1/24"}},
+		{"21.02", [3]string{"wireless", "radio0", "hwmode"}, []string{"11a"}},
+		{"24.10", [3]string{"wireless", "radio0", "band"}, []string{"5g"}},
+		{"21.02", [3]string{"firewall", "@zone[1]", "network"}, []string{"wan", "wan6"}},
+		{"22.03", [3]string{"firewall", "@defaults[0]", "input"}, []string{"REJECT"}},
+		{"22.03", [3]string{"dhcp", "lan", "ra_flags"}, []string{"managed-config", "other-config"}},
+		{"24.10", [3]string{"system", "@system[0]", "log_size"}, []string{"128"}},
+	} {
+		r := NewTree(filepath.Join("testdata", "openwrt", tc.release))
+		values, err := r.Lookup(tc.path[0], tc.path[1], tc.path[2])
+		if assert.NoError(t, err, "%s: %v", tc.release, tc.path) {
+			assert.Equal(t, tc.values, values, "%s: %v", tc.release, tc.path)
+		}
+	}
+
+	_, err := NewTree(filepath.Join("testdata", "openwrt", "24.10")).Lookup("ucitrack", "@network[0]", "init")
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+}
@@ -0,0 +1,99 @@
+package uci
+
+import (
+	"errors"
+	"os"
+)
+
+// Environment variables consulted by NewTree, like the -c and -P flags
+// of the uci tool.
+const (
+	EnvConfigDir = "UCI_CONFIG_DIR" // config directory, if NewTree is passed ""
+	EnvSaveDir   = "UCI_SAVEDIR"    // default savedir of LoadChanges and SaveChanges
+)
+
+// ErrReadOnly is returned by the methods of trees constructed with
+// WithReadOnly, which would change or write configs.
+var ErrReadOnly = errors.New("tree is read-only")
+
+// A TreeOption configures a tree constructed by NewTree.
+type TreeOption func(*treeOptions)
+
+type treeOptions struct {
+	configDir string
+	saveDir   string
+	stateDir  string
+	readOnly  bool
+	strict    bool
+}
+
+// WithConfigDir reads and writes configs in dir, instead of the
+// directory passed to NewTree.
+func WithConfigDir(dir string) TreeOption {
+	return func(o *treeOptions) {
+		o.configDir = dir
+	}
+}
+
+// WithSaveDir makes dir the savedir used by LoadChanges and SaveChanges,
+// if they are passed an empty one. It overrides UCI_SAVEDIR.
+func WithSaveDir(dir string) TreeOption {
+	return func(o *treeOptions) {
+		o.saveDir = dir
+	}
+}
+
+// WithStateDir overlays the state variables kept in dir (usually
+// DefaultStateDir) over the configs, like "uci -P". The state files use
+// the format of delta files; their changes are visible through the
+// tree, but are never written to the config files. Commit doesn't
+// detect configs changed by others in such trees (see
+// ErrConcurrentModification).
+func WithStateDir(dir string) TreeOption {
+	return func(o *treeOptions) {
+		o.stateDir = dir
+	}
+}
+
+// WithReadOnly makes the tree reject changes: Set and SetType return
+// false, Del and DelSection do nothing, Undo and Redo return 0, and
+// the other methods changing or writing configs return ErrReadOnly.
+// This does not cover configs returned by EnsureConfigLoaded, which
+// can still be modified (use Tree.Snapshot for read-only copies).
+func WithReadOnly() TreeOption {
+	return func(o *treeOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithStrictParsing makes the tree reject configs ast.ParseStrict
+// rejects, instead of logging a warning.
+func WithStrictParsing() TreeOption {
+	return func(o *treeOptions) {
+		o.strict = true
+	}
+}
+
+// configDir returns the directory a tree constructed with an empty root
+// uses: $UCI_CONFIG_DIR, or DefaultTreePath.
+func configDir() string {
+	if dir := os.Getenv(EnvConfigDir); dir != "" {
+		return dir
+	}
+	return DefaultTreePath
+}
+
+// saveDir returns dir, or the tree's default savedir, if dir is empty:
+// the one set with WithSaveDir, $UCI_SAVEDIR, or DefaultSaveDir.
+func (t *tree) saveDir(dir string) string {
+	switch {
+	case dir != "":
+		return dir
+	case t.savedir != "":
+		return t.savedir
+	}
+	if dir := os.Getenv(EnvSaveDir); dir != "" {
+		return dir
+	}
+	return DefaultSaveDir
+}
@@ -0,0 +1,77 @@
+package uci
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestTreeOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	root := t.TempDir()
+	require.NoError(ioutil.WriteFile(filepath.Join(root, "system"), []byte("\nconfig system\n\toption hostname 'OpenWrt'\n"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(root, "mixed"), []byte("config a\n\toption x 1\n  option y 2\n"), 0644))
+
+	// environment
+	t.Setenv(EnvConfigDir, root)
+	savedir := t.TempDir()
+	t.Setenv(EnvSaveDir, savedir)
+	r := NewTree("")
+	assert.True(r.Set("system", "@system[0]", "hostname", "router"))
+	require.NoError(r.SaveChanges("system", ""))
+	body, err := ioutil.ReadFile(filepath.Join(savedir, "system"))
+	require.NoError(err)
+	assert.Equal("system.cfg01e48a.hostname='router'\n", string(body))
+
+	// options override the environment
+	other := t.TempDir()
+	r = NewTree("", WithConfigDir(t.TempDir()), WithSaveDir(other))
+	_, ok := r.Get("system", "@system[0]", "hostname")
+	assert.False(ok)
+	r = NewTree("/nonexistent", WithConfigDir(root), WithSaveDir(other))
+	require.NoError(r.LoadChanges("system", ""))
+	require.NoError(r.LoadChanges("system", savedir))
+	host, _ := r.GetLast("system", "@system[0]", "hostname")
+	assert.Equal("router", host)
+
+	// read-only
+	r = NewTree(root, WithReadOnly())
+	assert.False(r.Set("system", "@system[0]", "hostname", "router"))
+	r.DelSection("system", "@system[0]")
+	r.Del("system", "@system[0]", "hostname")
+	assert.True(errors.Is(r.AddSection("system", "ntp", "timeserver"), ErrReadOnly))
+	_, err = r.RenameSection("system", "@system[0]", "main", nil)
+	assert.True(errors.Is(err, ErrReadOnly))
+	assert.True(errors.Is(r.LoadChanges("system", savedir), ErrReadOnly))
+	desired, err := ast.Parse("system", "config system\n\toption hostname 'router'\n")
+	require.NoError(err)
+	changes, err := r.Apply(desired, ApplyOptions{DryRun: true})
+	require.NoError(err)
+	assert.NotEmpty(changes)
+	_, err = r.Apply(desired, ApplyOptions{})
+	assert.True(errors.Is(err, ErrReadOnly))
+	host, _ = r.GetLast("system", "@system[0]", "hostname")
+	assert.Equal("OpenWrt", host)
+
+	// changes made behind the tree's back are not written either
+	cfg, _ := r.EnsureConfigLoaded("system")
+	cfg.Get("@system[0]").Get("hostname").SetValues("router")
+	cfg.SetTainted()
+	assert.True(errors.Is(r.Commit(), ErrReadOnly))
+	body, err = ioutil.ReadFile(filepath.Join(root, "system"))
+	require.NoError(err)
+	assert.Contains(string(body), "OpenWrt")
+
+	// strict parsing
+	require.NoError(NewTree(root).LoadConfig("mixed", false))
+	err = NewTree(root, WithStrictParsing()).LoadConfig("mixed", false)
+	assert.True(errors.Is(err, ast.ErrMixedIndentation))
+}
@@ -0,0 +1,207 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Overlay returns a new config consisting of the sections of defaults,
+// overridden by the sections of overrides. See ast.Overlay for the
+// matching rules.
+func Overlay(defaults, overrides *Config) *Config {
+	return ast.Overlay(defaults, overrides)
+}
+
+// NewLayeredTree constructs a tree reading configs from multiple
+// directories, like OpenWrt's /rom/etc/config and /etc/config. Layers
+// are given bottom first, e.g.:
+//
+//	uci.NewLayeredTree("/rom/etc/config", "/etc/config")
+//
+// Reads fall through the layers: a config is the Overlay of its files
+// in all layers, so upper layers only need to contain the sections and
+// options they change. A config must exist in at least one layer.
+//
+// Writes go to the top layer. Like a file modified on an overlayfs, the
+// written file contains the complete config, including the sections
+// and options of lower layers.
+func NewLayeredTree(layers ...string) Tree {
+	b := &layeredBackend{}
+	for _, dir := range layers {
+		b.layers = append(b.layers, &storeBackend{store: NewDirStore(dir), layer: dir})
+	}
+	return &tree{
+		backend: b,
+		configs: make(map[string]*Config),
+	}
+}
+
+// layeredBackend implements the backend interface for NewLayeredTree.
+type layeredBackend struct {
+	layers []*storeBackend // bottom first
+}
+
+func (b *layeredBackend) load(ctx context.Context, name string) (*Config, *ast.Provenances, error) {
+	var cfg *Config
+	var layers []*Config
+	var provs []*ast.Provenances
+	var notExist error
+	for _, layer := range b.layers {
+		c, prov, err := layer.load(ctx, name)
+		if errors.Is(err, os.ErrNotExist) {
+			notExist = err
+			layers = append(layers, nil)
+			provs = append(provs, nil)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, c)
+		provs = append(provs, prov)
+		if cfg == nil {
+			cfg = c
+		} else {
+			cfg = Overlay(cfg, c)
+		}
+	}
+	if cfg == nil {
+		if notExist == nil {
+			notExist = os.ErrNotExist // no layers
+		}
+		return nil, nil, notExist
+	}
+	return cfg, provenances(cfg, layers, provs), nil
+}
+
+// provenances maps the sections and options of the overlaid config cfg
+// to the layers they came from. Sections originate from the lowest
+// layer defining them, options from the highest (which overrides the
+// lower ones).
+func provenances(cfg *Config, layers []*Config, provs []*ast.Provenances) *ast.Provenances {
+	result := ast.NewProvenances(nil, "", "")
+	keys := overlayKeys(cfg)
+	layerKeys := make([]map[string]*Section, len(layers))
+	for i, layer := range layers {
+		if layer != nil {
+			layerKeys[i] = make(map[string]*Section, len(layer.Sections))
+			for sec, key := range overlayKeys(layer) {
+				layerKeys[i][key] = sec
+			}
+		}
+	}
+
+	for _, sec := range cfg.Sections {
+		key := keys[sec]
+		for i := range layers {
+			if src := layerKeys[i][key]; src != nil {
+				if prov, ok := provs[i].Section(src); ok {
+					result.SetSection(sec, prov)
+				}
+				break
+			}
+		}
+		for _, opt := range sec.Options {
+			for i := len(layers) - 1; i >= 0; i-- {
+				src := layerKeys[i][key]
+				if src == nil || src.Get(opt.Name) == nil {
+					continue
+				}
+				if prov, ok := provs[i].Option(src.Get(opt.Name)); ok {
+					result.SetOption(opt, prov)
+				}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// overlayKeys identifies the sections of c the way Overlay matches them:
+// named sections by name, unnamed sections by type and position among
+// the unnamed sections of that type.
+func overlayKeys(c *Config) map[*Section]string {
+	keys := make(map[*Section]string, len(c.Sections))
+	seen := make(map[string]int)
+	for _, sec := range c.Sections {
+		if sec.Name != "" {
+			keys[sec] = sec.Name
+			continue
+		}
+		keys[sec] = fmt.Sprintf("@%s[%d]", sec.Type, seen[sec.Type])
+		seen[sec.Type]++
+	}
+	return keys
+}
+
+func (b *layeredBackend) setStyle(style Style) {
+	for _, layer := range b.layers {
+		layer.setStyle(style)
+	}
+}
+
+func (b *layeredBackend) setLimits(limits Limits) {
+	for _, layer := range b.layers {
+		layer.setLimits(limits)
+	}
+}
+
+func (b *layeredBackend) setLogger(l *slog.Logger) {
+	for _, layer := range b.layers {
+		layer.setLogger(l)
+	}
+}
+
+func (b *layeredBackend) save(ctx context.Context, c *Config) (*ast.Provenances, error) {
+	if len(b.layers) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return b.layers[len(b.layers)-1].save(ctx, c)
+}
+
+func (b *layeredBackend) version(ctx context.Context, name string) (string, error) {
+	cfg, _, err := b.load(ctx, name)
+	return versionOf(cfg, err)
+}
+
+func (b *layeredBackend) preview(ctx context.Context, c *Config) ([]byte, []byte, error) {
+	if len(b.layers) == 0 {
+		return nil, nil, os.ErrNotExist
+	}
+	return b.layers[len(b.layers)-1].preview(ctx, c)
+}
+
+func (b *layeredBackend) restore(ctx context.Context, name string, body []byte) error {
+	if len(b.layers) == 0 {
+		return os.ErrNotExist
+	}
+	return b.layers[len(b.layers)-1].restore(ctx, name, body)
+}
+
+func (b *layeredBackend) list(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, layer := range b.layers {
+		layerNames, err := layer.list(ctx)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range layerNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
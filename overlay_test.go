@@ -0,0 +1,62 @@
+package uci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayeredTree(t *testing.T) {
+	assert := assert.New(t)
+
+	top := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(top, "system"), []byte(`
+config system
+	option hostname 'device1'
+
+config timeserver 'ntp'
+	option enabled '0'
+`), 0o644)
+	require.NoError(t, err)
+
+	r := NewLayeredTree("testdata", top)
+
+	// overridden
+	hostname, _ := r.GetLast("system", "@system[0]", "hostname")
+	assert.Equal("device1", hostname)
+	enabled, _ := r.GetBool("system", "ntp", "enabled")
+	assert.False(enabled)
+
+	// fall through
+	servers, _ := r.Get("system", "ntp", "server")
+	assert.Len(servers, 4)
+	_, ok := r.Get("upnpd", "config", "enabled")
+	assert.True(ok)
+	_, ok = r.Get("doesnotexist", "foo", "bar")
+	assert.False(ok)
+
+	// writes go to the top layer
+	assert.True(r.Set("upnpd", "config", "enabled", "0"))
+	assert.NoError(r.Commit())
+	_, err = os.Stat(filepath.Join(top, "upnpd"))
+	assert.NoError(err)
+
+	fresh := NewTree(top)
+	enabledUpnp, _ := fresh.GetLast("upnpd", "config", "enabled")
+	assert.Equal("0", enabledUpnp)
+	port, _ := fresh.GetLast("upnpd", "config", "port")
+	assert.Equal("5000", port)
+}
+
+func TestLayeredTreeParseError(t *testing.T) {
+	top := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(top, "system"), []byte("config\n"), 0o644))
+
+	r := NewLayeredTree("testdata", top)
+	err := r.LoadConfig("system", false)
+	assert.True(t, IsParseError(err))
+}
@@ -0,0 +1,58 @@
+package uci
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// A CommitPreview describes what Commit would write for a config.
+type CommitPreview struct {
+	Config string
+
+	// Old are the bytes currently stored, nil for new configs. New are
+	// the bytes Commit would write. For remote trees (see
+	// NewRemoteTree), Old is the output of "uci export", and New is the
+	// "uci batch" script.
+	Old []byte
+	New []byte
+
+	// Changes are the differences between the stored and the modified
+	// config.
+	Changes []Change
+}
+
+func (t *tree) CommitDryRun(ctx context.Context) ([]CommitPreview, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	var previews []CommitPreview
+	for name, config := range t.configs {
+		if !config.Tainted() || t.ephemeral[name] {
+			continue
+		}
+		config.CoerceTypes() // like Commit
+		old, body, err := t.backend.preview(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		orig := ast.NewConfig(config.Name)
+		if old != nil {
+			if orig, err = ast.Parse(config.Name, string(old)); err != nil {
+				return nil, fmt.Errorf("reading config %s failed: %w", config.Name, err)
+			}
+		}
+		previews = append(previews, CommitPreview{
+			Config:  config.Name,
+			Old:     old,
+			New:     body,
+			Changes: ast.Diff(orig, config),
+		})
+	}
+	sort.Slice(previews, func(i, j int) bool {
+		return previews[i].Config < previews[j].Config
+	})
+	return previews, nil
+}
@@ -0,0 +1,71 @@
+package uci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestCommitDryRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const input = "\nconfig system\n\toption hostname 'OpenWrt'\n\n"
+	store := NewMemoryStore(map[string]string{"system": input})
+	r := NewStoreTree(store)
+	r.SetStyle(Style{Quote: QuoteMinimal})
+
+	previews, err := r.CommitDryRun(context.Background())
+	require.NoError(err)
+	assert.Empty(previews)
+
+	assert.True(r.Set("system", "@system[0]", "hostname", "router"))
+	require.NoError(r.AddSection("dhcp", "lan", "dhcp"))
+	assert.True(r.Set("dhcp", "lan", "start", "100"))
+
+	previews, err = r.CommitDryRun(context.Background())
+	require.NoError(err)
+	assert.Equal([]CommitPreview{{
+		Config: "dhcp",
+		New:    []byte("\nconfig dhcp lan\n\toption start 100\n\n"),
+		Changes: []Change{
+			{Op: ast.OpAddSection, Section: "lan", Type: "dhcp"},
+			{Op: ast.OpSetOption, Section: "lan", Type: "dhcp", Option: "start", OptionType: TypeOption, New: []string{"100"}},
+		},
+	}, {
+		Config: "system",
+		Old:    []byte(input),
+		New:    []byte("\nconfig system\n\toption hostname router\n\n"),
+		Changes: []Change{
+			{Op: ast.OpSetOption, Section: "@system[0]", Type: "system", Option: "hostname", OptionType: TypeOption, Old: []string{"OpenWrt"}, New: []string{"router"}},
+		},
+	}}, previews)
+
+	// nothing was written, and Commit writes the previewed bytes
+	assert.Equal(input, string(store.files["system"]))
+	assert.NotContains(store.files, "dhcp")
+	require.NoError(r.Commit())
+	for _, p := range previews {
+		assert.Equal(string(p.New), string(store.files[p.Config]))
+	}
+}
+
+func TestRemoteCommitDryRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := &fakeRunner{export: remoteExport, show: remoteShow}
+	tree := NewRemoteTree(r)
+	assert.True(tree.Set("network", "lan", "proto", "dhcp"))
+
+	previews, err := tree.CommitDryRun(context.Background())
+	require.NoError(err)
+	require.Len(previews, 1)
+	assert.Equal("set network.lan.proto='dhcp'\ncommit network\n", string(previews[0].New))
+	assert.Len(previews[0].Changes, 1)
+	assert.Empty(r.batches)
+}
@@ -0,0 +1,156 @@
+// Package profile adapts trees to the OpenWrt release of a device, so
+// that a controller managing devices running different releases
+// validates and migrates the configs of each device for its release:
+//
+//	p, err := profile.ForRelease("23.05.3") // e.g. from /etc/openwrt_release
+//	p.Apply(tree)
+//	applied, err := tree.Migrate()
+//
+// A profile registers the migrations of its release and all earlier
+// ones (see uci.OpenWrtMigrations), validators for the datatypes of its
+// schema, and validators rejecting options introduced by later releases.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/schema"
+	"github.com/wsiner/go-uci/validate"
+)
+
+var (
+	ErrInvalidRelease    = errors.New("invalid release")
+	ErrUnsupportedOption = errors.New("option not supported by release")
+)
+
+// A Profile describes the configs understood by an OpenWrt release.
+type Profile struct {
+	Release string // "YY.MM", e.g. "23.05"
+
+	// Schema describes the packages of the release. The datatypes of
+	// its options are used for validation.
+	Schema *schema.Schema
+
+	// Migrations rename the options deprecated by the release, or by
+	// earlier ones.
+	Migrations []uci.Migration
+
+	// Later lists the migrations of later releases. The options they
+	// introduce are rejected.
+	Later []uci.Migration
+}
+
+// Profiles of current OpenWrt releases.
+var (
+	Profile2102 = New("21.02")
+	Profile2305 = New("23.05")
+	Profile2410 = New("24.10")
+)
+
+// New returns the profile for a release ("YY.MM"), splitting
+// uci.OpenWrtMigrations by the release introducing them. The built-in
+// profiles share schema.Default, which only describes options common
+// to these releases.
+func New(release string) *Profile {
+	p := &Profile{Release: release, Schema: schema.Default}
+	for _, m := range uci.OpenWrtMigrations {
+		if compareReleases(m.Since, release) <= 0 {
+			p.Migrations = append(p.Migrations, m)
+		} else {
+			p.Later = append(p.Later, m)
+		}
+	}
+	return p
+}
+
+// ForRelease returns the profile for a version string, as found in
+// DISTRIB_RELEASE of /etc/openwrt_release (e.g. "23.05.3", "24.10-rc2"
+// or "SNAPSHOT", which gets a profile without later migrations).
+func ForRelease(version string) (*Profile, error) {
+	if version == "SNAPSHOT" {
+		return New(version), nil
+	}
+	release, _, _ := strings.Cut(version, "-")
+	parts := strings.Split(release, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRelease, version)
+	}
+	for _, part := range parts {
+		if _, err := strconv.Atoi(part); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRelease, version)
+		}
+	}
+	return New(parts[0] + "." + parts[1]), nil
+}
+
+// compareReleases compares two "YY.MM" releases numerically. Releases
+// which aren't numbers (like "SNAPSHOT") sort last.
+func compareReleases(a, b string) int {
+	parse := func(s string) (int, int, bool) {
+		major, minor, ok := strings.Cut(s, ".")
+		x, err1 := strconv.Atoi(major)
+		y, err2 := strconv.Atoi(minor)
+		return x, y, ok && err1 == nil && err2 == nil
+	}
+	x1, y1, ok1 := parse(a)
+	x2, y2, ok2 := parse(b)
+	switch {
+	case !ok1 || !ok2:
+		if ok1 == ok2 {
+			return 0
+		} else if ok1 {
+			return -1
+		}
+		return 1
+	case x1 != x2:
+		return x1 - x2
+	}
+	return y1 - y2
+}
+
+// Apply registers the profile's migrations and validators with t. It
+// should be called once per tree. Datatypes the validate package can't
+// check (like "file", which refers to the device's file system) are
+// skipped.
+func (p *Profile) Apply(t uci.Tree) {
+	for _, m := range p.Migrations {
+		t.RegisterMigration(m)
+	}
+	for _, pkg := range p.Schema.Packages() {
+		for _, sec := range pkg.Sections {
+			for _, o := range sec.Options {
+				if o.Datatype == "" {
+					continue
+				}
+				datatype := o.Datatype
+				if o.Fields {
+					datatype = "list(" + datatype + ")"
+				}
+				v, err := validate.Validator(datatype)
+				if err != nil {
+					continue
+				}
+				t.RegisterValidator(o.Name, only(pkg.Name, sec.Type, v))
+			}
+		}
+	}
+	for _, m := range p.Later {
+		t.RegisterValidator(m.NewOption, only(m.Config, m.SectionType, func(_, _, option, _ string) error {
+			return fmt.Errorf("%w %s: %s requires OpenWrt %s", ErrUnsupportedOption, p.Release, option, m.Since)
+		}))
+	}
+}
+
+// only restricts v to options of a package and section type.
+func only(pkg, sectionType string, v uci.Validator) uci.Validator {
+	return func(p, st, option, value string) error {
+		if p != pkg || st != sectionType {
+			return nil
+		}
+		return v(p, st, option, value)
+	}
+}
@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci"
+)
+
+func TestForRelease(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		version string
+		release string
+	}{
+		{"21.02.7", "21.02"},
+		{"23.05", "23.05"},
+		{"24.10-rc2", "24.10"},
+		{"SNAPSHOT", "SNAPSHOT"},
+		{"23", ""},
+		{"23.x", ""},
+		{"", ""},
+	} {
+		p, err := ForRelease(tc.version)
+		if tc.release == "" {
+			assert.True(errors.Is(err, ErrInvalidRelease), tc.version)
+			continue
+		}
+		if assert.NoError(err, tc.version) {
+			assert.Equal(tc.release, p.Release, tc.version)
+		}
+	}
+
+	p, _ := ForRelease("SNAPSHOT")
+	assert.Len(p.Migrations, len(uci.OpenWrtMigrations))
+	assert.Empty(p.Later)
+	assert.Empty(Profile2305.Later)
+	assert.NotEmpty(Profile2102.Later)
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	configs := map[string]string{
+		"network":  "\nconfig interface 'lan'\n\toption ifname 'eth0'\n",
+		"wireless": "\nconfig wifi-device 'radio0'\n\toption channel '36'\n",
+	}
+
+	old := uci.NewStoreTree(uci.NewMemoryStore(configs))
+	Profile2102.Apply(old)
+	cur := uci.NewStoreTree(uci.NewMemoryStore(configs))
+	Profile2410.Apply(cur)
+
+	for _, tree := range []uci.Tree{old, cur} {
+		applied, err := tree.Migrate()
+		require.NoError(err)
+		require.Len(applied, 1)
+		assert.Equal("device", applied[0].Migration.NewOption)
+
+		// datatypes of the schema are checked
+		assert.False(tree.Set("network", "lan", "ipaddr", "not an address"))
+		assert.True(tree.Set("network", "lan", "ipaddr", "192.168.1.1"))
+		assert.True(tree.Set("network", "lan", "dns", "1.1.1.1 9.9.9.9")) // fields
+		assert.False(tree.Set("network", "lan", "dns", "1.1.1.1 dns.example"))
+	}
+
+	// options of later releases are rejected
+	assert.False(old.Set("wireless", "radio0", "band", "5g"))
+	assert.True(cur.Set("wireless", "radio0", "band", "5g"))
+	var verr *uci.ValidationError
+	cfg, _ := old.EnsureConfigLoaded("wireless")
+	cfg.Get("radio0").Add(uci.NewOption("band", uci.TypeOption, "5g"))
+	err := old.Validate("wireless")
+	require.True(errors.As(err, &verr))
+	assert.True(errors.Is(err, ErrUnsupportedOption))
+	assert.Equal("wireless.radio0.band: option not supported by release 21.02: band requires OpenWrt 22.03", verr.Error())
+}
@@ -0,0 +1,83 @@
+package uci
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "system"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "system"), body, 0644))
+	file := filepath.Join(dir, "system")
+
+	r := NewTree(dir)
+	p, ok := r.Provenance("system", "ntp", "")
+	assert.True(ok)
+	assert.Equal(Provenance{File: file, Line: 8}, p)
+	p, ok = r.Provenance("system", "@system[0]", "hostname")
+	assert.True(ok)
+	assert.Equal(Provenance{File: file, Line: 6}, p)
+	_, ok = r.Provenance("system", "ntp", "doesnotexist")
+	assert.False(ok)
+	_, ok = r.Provenance("system", "doesnotexist", "")
+	assert.False(ok)
+
+	// edits keep the origin
+	assert.True(r.Set("system", "@system[0]", "hostname", "ap1"))
+	p, _ = r.Provenance("system", "@system[0]", "hostname")
+	assert.Equal(Provenance{File: file, Line: 6, Edited: true}, p)
+
+	// new sections and options have no origin
+	require.NoError(t, r.AddSection("system", "led", "led"))
+	assert.True(r.Set("system", "led", "sysfs", "green:power"))
+	p, _ = r.Provenance("system", "led", "")
+	assert.Equal(Provenance{Edited: true}, p)
+	p, _ = r.Provenance("system", "led", "sysfs")
+	assert.Equal(Provenance{Edited: true}, p)
+
+	// after commit, everything refers to the written file
+	require.NoError(t, r.Commit())
+	p, _ = r.Provenance("system", "led", "sysfs")
+	assert.Equal(file, p.File)
+	assert.False(p.Edited)
+	fresh, _ := NewTree(dir).Provenance("system", "led", "sysfs")
+	assert.Equal(fresh, p)
+}
+
+func TestProvenance_layered(t *testing.T) {
+	assert := assert.New(t)
+
+	top := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(top, "system"), []byte(`
+config system
+	option hostname 'device1'
+
+config timeserver 'ntp'
+	option enabled '0'
+`), 0644))
+
+	r := NewLayeredTree("testdata", top)
+	tt := []struct {
+		section, option string
+		expected        Provenance
+	}{
+		{"@system[0]", "", Provenance{File: filepath.Join("testdata", "system"), Line: 1, Layer: "testdata"}},
+		{"@system[0]", "hostname", Provenance{File: filepath.Join(top, "system"), Line: 3, Layer: top}},
+		{"@system[0]", "timezone", Provenance{File: filepath.Join("testdata", "system"), Line: 2, Layer: "testdata"}},
+		{"ntp", "enabled", Provenance{File: filepath.Join(top, "system"), Line: 6, Layer: top}},
+		{"ntp", "server", Provenance{File: filepath.Join("testdata", "system"), Line: 11, Layer: "testdata"}},
+	}
+	for _, tc := range tt {
+		p, ok := r.Provenance("system", tc.section, tc.option)
+		assert.True(ok)
+		assert.Equal(tc.expected, p, "%s.%s", tc.section, tc.option)
+	}
+}
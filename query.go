@@ -0,0 +1,36 @@
+package uci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+func (t *tree) Query(expr string) ([]Path, error) {
+	q, err := ast.ParseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	names, err := t.configNames(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []Path
+	for _, name := range names {
+		if !q.MatchConfig(name) || t.allowed(name) != nil {
+			continue
+		}
+		cfg, err := t.ensureConfig(context.Background(), name)
+		if err != nil {
+			return paths, fmt.Errorf("query: %w", err)
+		}
+		paths = append(paths, q.Match(cfg)...)
+	}
+	return paths, nil
+}
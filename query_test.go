@@ -0,0 +1,45 @@
+package uci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{
+		"firewall": `
+config rule
+	option dest_port '22'
+
+config rule
+	option dest_port '80'
+`,
+		"dropbear": `
+config dropbear
+	option Port '22'
+`,
+	}))
+	require.NoError(r.AddSection("sshd", "main", "sshd")) // not committed
+	assert.True(r.Set("sshd", "main", "port", "22"))
+
+	paths, err := r.Query("*.*.*ort=22")
+	require.NoError(err)
+	var got []string
+	for _, p := range paths {
+		got = append(got, p.String())
+	}
+	assert.Equal([]string{
+		"dropbear.@dropbear[0].Port",
+		"firewall.@rule[0].dest_port",
+		"sshd.main.port",
+	}, got)
+
+	_, err = r.Query("firewall=rule")
+	assert.True(errors.Is(err, ErrInvalidQuery))
+}
@@ -0,0 +1,336 @@
+package uci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Runner executes a program (usually on a remote system) and returns
+// its standard output. The stdin argument may be nil. Implementations
+// should abort the program, when ctx is done.
+//
+// Implementations must take care of quoting the arguments, if they are
+// passed through a shell. See the sshremote package for an
+// implementation using SSH.
+type Runner interface {
+	Run(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, error)
+}
+
+// NewRemoteTree constructs a tree, which reads and writes configs using
+// the uci CLI of a remote system. This is useful to manage systems,
+// which can't run Go binaries.
+//
+// Configs are read with "uci export" (which, unlike "uci show", retains
+// the difference between options and single-valued lists) and "uci -X
+// show" (to learn the IDs of unnamed sections). Commit translates the
+// local changes into a "uci batch" script (set, add, add_list, delete,
+// rename), which addresses unnamed sections by their ID, and commits
+// it. Afterwards, the config is reloaded from the remote system.
+//
+// Unnamed sections added locally are appended to the remote config,
+// hence their relative order may differ from the local order.
+func NewRemoteTree(r Runner) Tree {
+	return &tree{
+		backend: &remoteBackend{runner: r, states: make(map[string]*remoteState)},
+		configs: make(map[string]*Config),
+	}
+}
+
+// remoteBackend implements the backend interface using a Runner.
+type remoteBackend struct {
+	runner Runner
+	limits Limits
+	states map[string]*remoteState // per config
+}
+
+func (b *remoteBackend) setLimits(limits Limits) {
+	b.limits = limits
+}
+
+// remoteState tracks the state of a config, as seen on the remote
+// system when it was loaded.
+type remoteState struct {
+	loaded []*Section            // in remote order
+	ids    map[*Section]string   // section IDs (names or cfgXXXXXX)
+	orig   map[*Section]*Section // unmodified copies
+}
+
+func (b *remoteBackend) load(ctx context.Context, name string) (*Config, *ast.Provenances, error) {
+	body, err := b.export(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, pos, err := b.limits.ParsePositions(name, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	show, err := b.runner.Run(ctx, nil, "uci", "-X", "show", name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading section IDs of config %s failed: %w", name, err)
+	}
+	ids := sectionIDs(name, string(show))
+	if len(ids) != len(cfg.Sections) {
+		return nil, nil, fmt.Errorf("reading config %s failed: got %d sections, but %d section IDs",
+			name, len(cfg.Sections), len(ids))
+	}
+
+	state := &remoteState{
+		loaded: append([]*Section(nil), cfg.Sections...),
+		ids:    make(map[*Section]string, len(ids)),
+		orig:   make(map[*Section]*Section, len(ids)),
+	}
+	for i, sec := range cfg.Sections {
+		state.ids[sec] = ids[i]
+		state.orig[sec] = sec.Clone()
+	}
+	b.states[name] = state
+	return cfg, ast.NewProvenances(pos, name, ""), nil
+}
+
+// export returns the output of "uci export" for a config, without the
+// package line, but with the line numbers kept intact.
+func (b *remoteBackend) export(ctx context.Context, name string) (string, error) {
+	export, err := b.runner.Run(ctx, nil, "uci", "export", name)
+	if err != nil && strings.Contains(err.Error(), "Entry not found") {
+		// uci's message for missing configs, included by runners
+		// reporting stderr (like sshremote)
+		return "", fmt.Errorf("reading config %s failed: %w: %w", name, os.ErrNotExist, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading config %s failed: %w", name, err)
+	}
+	body := stripPackage(string(export))
+	return strings.Repeat("\n", strings.Count(string(export[:len(export)-len(body)]), "\n")) + body, nil
+}
+
+func (b *remoteBackend) version(ctx context.Context, name string) (string, error) {
+	body, err := b.export(ctx, name)
+	if err != nil {
+		return versionOf(nil, err)
+	}
+	return versionOf(b.limits.Parse(name, body))
+}
+
+// stripPackage removes the "package <name>" line, "uci export" starts
+// its output with.
+func stripPackage(export string) string {
+	trimmed := strings.TrimLeft(export, " \t\n")
+	if strings.HasPrefix(trimmed, "package ") || strings.HasPrefix(trimmed, "package\t") {
+		if i := strings.IndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:]
+		}
+		return ""
+	}
+	return export
+}
+
+// sectionIDs extracts the section IDs from the output of "uci -X show",
+// in order of appearance. Section lines have the form "pkg.id=type".
+// Lines continuing a multi-line value are skipped.
+func sectionIDs(name, show string) []string {
+	var ids []string
+	quoted := false // within single quotes, at the end of the previous line
+	for _, line := range strings.Split(show, "\n") {
+		continued := quoted
+		for i := 0; i < len(line); i++ {
+			switch {
+			case line[i] == '\'':
+				quoted = !quoted
+			case line[i] == '\\' && !quoted:
+				i++
+			}
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 || continued {
+			continue
+		}
+		key := strings.TrimPrefix(line[:eq], name+".")
+		if key == line[:eq] || strings.Contains(key, ".") {
+			continue // other package or option line
+		}
+		ids = append(ids, key)
+	}
+	return ids
+}
+
+func (b *remoteBackend) save(ctx context.Context, c *Config) (*ast.Provenances, error) {
+	state := b.states[c.Name]
+	if state == nil {
+		state = &remoteState{} // new config
+	}
+
+	if state.ids == nil {
+		// "uci batch" can't create packages, but "uci import" can
+		if _, err := b.runner.Run(ctx, strings.NewReader(""), "uci", "import", c.Name); err != nil {
+			return nil, fmt.Errorf("creating config %s failed: %w", c.Name, err)
+		}
+	}
+	script := b.script(c, state)
+
+	if _, err := b.runner.Run(ctx, bytes.NewReader(script), "uci", "batch"); err != nil {
+		return nil, fmt.Errorf("committing config %s failed: %w", c.Name, err)
+	}
+
+	// refresh section IDs and copies; the config object stays the same
+	fresh, prov, err := b.load(ctx, c.Name)
+	if err != nil {
+		return nil, err
+	}
+	c.Sections = fresh.Sections
+	return prov, nil
+}
+
+// preview returns the output of "uci export", and the "uci batch"
+// script save would run.
+func (b *remoteBackend) preview(ctx context.Context, c *Config) ([]byte, []byte, error) {
+	state := b.states[c.Name]
+	if state == nil {
+		state = &remoteState{} // new config
+	}
+	var old []byte
+	if state.ids != nil {
+		export, err := b.runner.Run(ctx, nil, "uci", "export", c.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading config %s failed: %w", c.Name, err)
+		}
+		old = []byte(stripPackage(string(export)))
+	}
+	return old, b.script(c, state), nil
+}
+
+// restore imports body, the output of "uci export" returned by preview.
+// As "uci batch" can't remove packages, a nil body empties the config.
+// The config is reloaded on next use.
+func (b *remoteBackend) restore(ctx context.Context, name string, body []byte) error {
+	delete(b.states, name)
+	if _, err := b.runner.Run(ctx, bytes.NewReader(body), "uci", "import", name); err != nil {
+		return fmt.Errorf("restoring config %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// script returns the "uci batch" script committing the changes of c.
+func (b *remoteBackend) script(c *Config, state *remoteState) []byte {
+	var script bytes.Buffer
+	writeBatch(&script, c, state)
+	fmt.Fprintf(&script, "commit %s\n", c.Name)
+	return script.Bytes()
+}
+
+func (b *remoteBackend) list(ctx context.Context) ([]string, error) {
+	export, err := b.runner.Run(ctx, nil, "uci", "export")
+	if err != nil {
+		return nil, fmt.Errorf("listing configs failed: %w", err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(export), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "package" {
+			names = append(names, strings.Trim(fields[1], `'"`))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeBatch writes the "uci batch" commands to apply the changes of c
+// (compared to the state it was loaded in).
+func writeBatch(w io.Writer, c *Config, state *remoteState) {
+	present := make(map[*Section]bool, len(c.Sections))
+	for _, sec := range c.Sections {
+		present[sec] = true
+	}
+	for _, sec := range state.loaded {
+		if !present[sec] {
+			fmt.Fprintf(w, "delete %s.%s\n", c.Name, state.ids[sec])
+		}
+	}
+
+	for _, sec := range c.Sections {
+		id, loaded := state.ids[sec]
+		if !loaded {
+			ref := sec.Name
+			if sec.Name == "" {
+				fmt.Fprintf(w, "add %s %s\n", c.Name, sec.Type)
+				ref = fmt.Sprintf("@%s[-1]", sec.Type)
+			} else {
+				fmt.Fprintf(w, "set %s.%s=%s\n", c.Name, ref, sec.Type)
+			}
+			writeOptions(w, c.Name+"."+ref, nil, sec)
+			continue
+		}
+
+		orig := state.orig[sec]
+		ref := id
+		if sec.Name != "" && sec.Name != id {
+			fmt.Fprintf(w, "rename %s.%s=%s\n", c.Name, id, sec.Name)
+			ref = sec.Name
+		}
+		if sec.Type != orig.Type {
+			fmt.Fprintf(w, "set %s.%s=%s\n", c.Name, ref, sec.Type)
+		}
+		writeOptions(w, c.Name+"."+ref, orig, sec)
+	}
+}
+
+// writeOptions writes the commands to change the options of orig (may
+// be nil) to the ones of sec. The section is addressed by ref.
+func writeOptions(w io.Writer, ref string, orig, sec *Section) {
+	for _, opt := range sec.Options {
+		var prev *Option
+		if orig != nil {
+			prev = orig.Get(opt.Name)
+		}
+		if prev != nil && prev.Type == opt.Type && equalValues(prev.Values, opt.Values) {
+			continue
+		}
+
+		switch opt.Type {
+		case TypeOption:
+			if len(opt.Values) > 0 {
+				fmt.Fprintf(w, "set %s.%s=%s\n", ref, opt.Name, quoteValue(opt.Values[0]))
+			}
+		case TypeList:
+			if prev != nil {
+				fmt.Fprintf(w, "delete %s.%s\n", ref, opt.Name)
+			}
+			for _, v := range opt.Values {
+				fmt.Fprintf(w, "add_list %s.%s=%s\n", ref, opt.Name, quoteValue(v))
+			}
+		}
+	}
+
+	if orig == nil {
+		return
+	}
+	for _, opt := range orig.Options {
+		if sec.Get(opt.Name) == nil {
+			fmt.Fprintf(w, "delete %s.%s\n", ref, opt.Name)
+		}
+	}
+}
+
+// quoteValue quotes v for the uci CLI.
+func quoteValue(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
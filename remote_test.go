@@ -0,0 +1,133 @@
+package uci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// fakeRunner answers "uci export" and "uci -X show" with canned output
+// and records batch scripts.
+type fakeRunner struct {
+	export  string
+	show    string
+	batches []string
+}
+
+func (r *fakeRunner) Run(_ context.Context, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	cmd := name + " " + strings.Join(args, " ")
+	switch {
+	case cmd == "uci export":
+		return []byte(r.export + "\npackage 'system'\n\nconfig system\n"), nil
+	case strings.HasPrefix(cmd, "uci export "):
+		return []byte(r.export), nil
+	case strings.HasPrefix(cmd, "uci -X show "):
+		return []byte(r.show), nil
+	case cmd == "uci batch":
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		r.batches = append(r.batches, string(b))
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected command %q", cmd)
+}
+
+const remoteExport = `package network
+
+config interface 'lan'
+	option proto 'static'
+	list ipaddr '192.168.1.1/24'
+
+config route
+	option interface 'lan'
+	option target '10.0.0.0/8'
+
+config route
+	option interface 'wan'
+`
+
+const remoteShow = `network.lan=interface
+network.lan.proto='static'
+network.lan.ipaddr='192.168.1.1/24'
+network.cfg0a1b2c=route
+network.cfg0a1b2c.interface='lan'
+network.cfg0a1b2c.target='10.0.0.0/8'
+network.cfg0b3d4e=route
+network.cfg0b3d4e.interface='wan'
+`
+
+func TestRemoteTree(t *testing.T) {
+	assert := assert.New(t)
+	r := &fakeRunner{export: remoteExport, show: remoteShow}
+	tree := NewRemoteTree(r)
+
+	values, ok := tree.Get("network", "lan", "ipaddr")
+	assert.True(ok)
+	assert.Equal([]string{"192.168.1.1/24"}, values)
+	values, ok = tree.Get("network", "@route[1]", "interface")
+	assert.True(ok)
+	assert.Equal([]string{"wan"}, values)
+	prov, ok := tree.Provenance("network", "lan", "")
+	assert.True(ok)
+	assert.Equal(Provenance{File: "network", Line: 3}, prov)
+
+	assert.True(tree.Set("network", "lan", "proto", "dhcp"))
+	assert.True(tree.SetType("network", "lan", "ipaddr", TypeList, "10.0.0.1/8", "it's"))
+	tree.Del("network", "@route[0]", "target")
+	tree.DelSection("network", "@route[1]")
+	assert.NoError(tree.AddSection("network", "wan", "interface"))
+	assert.True(tree.Set("network", "wan", "proto", "dhcp"))
+
+	assert.NoError(tree.Commit())
+	assert.Len(r.batches, 1)
+	assert.Equal(`delete network.cfg0b3d4e
+set network.lan.proto='dhcp'
+delete network.lan.ipaddr
+add_list network.lan.ipaddr='10.0.0.1/8'
+add_list network.lan.ipaddr='it'\''s'
+delete network.cfg0a1b2c.target
+set network.wan=interface
+set network.wan.proto='dhcp'
+commit network
+`, r.batches[0])
+}
+
+func TestRemoteList(t *testing.T) {
+	b := &remoteBackend{runner: &fakeRunner{export: remoteExport}}
+	names, err := b.list(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"network", "system"}, names)
+}
+
+func TestRemoteBatchUnnamed(t *testing.T) {
+	assert := assert.New(t)
+
+	c := ast.NewConfig("firewall")
+	sec := c.Add(NewSection("rule", ""))
+	sec.Add(NewOption("name", TypeOption, "Allow-Ping"))
+	sec.Add(NewOption("proto", TypeList, "icmp"))
+
+	var script strings.Builder
+	writeBatch(&script, c, &remoteState{})
+	assert.Equal(`add firewall rule
+set firewall.@rule[-1].name='Allow-Ping'
+add_list firewall.@rule[-1].proto='icmp'
+`, script.String())
+}
+
+func TestSectionIDs(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"lan", "cfg0a1b2c", "cfg0b3d4e"}, sectionIDs("network", remoteShow))
+	assert.Equal([]string{"lan"}, sectionIDs("network", "network.lan=interface\nnetwork.lan.note='it'\\''s\nnetwork.x=y'\n"))
+	assert.Equal("\nconfig foo\n", stripPackage("package bar\n\nconfig foo\n"))
+	assert.Equal("config foo\n", stripPackage("config foo\n"))
+}
@@ -0,0 +1,161 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotConfirmed is returned by PendingCommit.Confirm and
+	// PendingCommit.Err, if the commit was rolled back.
+	ErrNotConfirmed = errors.New("commit not confirmed")
+
+	// ErrConfirmed is returned by PendingCommit.Rollback, if the commit
+	// was confirmed already.
+	ErrConfirmed = errors.New("commit already confirmed")
+)
+
+// A PendingCommit is a commit made by Tree.SafeCommit, which is rolled
+// back unless it is confirmed in time.
+type PendingCommit struct {
+	t        *tree
+	previous map[string][]byte // contents before the commit, nil for new configs
+	frags    map[string][]byte // likewise, of their fragments (see Tree.SetIncludes)
+	timer    *time.Timer
+
+	mu   sync.Mutex
+	done chan struct{}
+	err  error // nil if confirmed
+}
+
+func (t *tree) SafeCommit(ctx context.Context, timeout time.Duration) (*PendingCommit, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	start := time.Now()
+	tainted := t.tainted()
+	if err := t.runCommitHooks(ctx, tainted); err != nil {
+		t.runCommitDoneHooks(tainted, start, err)
+		return nil, err
+	}
+	p := &PendingCommit{t: t, previous: make(map[string][]byte), frags: make(map[string][]byte), done: make(chan struct{})}
+	for _, config := range tainted {
+		old, _, err := t.backend.preview(ctx, config)
+		var frags map[string][]byte
+		if err == nil {
+			frags, err = t.previewFragments(ctx, config)
+		}
+		if err == nil {
+			err = t.save(ctx, config)
+		}
+		if err != nil {
+			// don't leave a partial commit behind
+			if rerr := p.restore(); rerr != nil {
+				err = errors.Join(err, rerr)
+			}
+			t.runCommitDoneHooks(tainted, start, err)
+			return nil, err
+		}
+		p.previous[config.Name] = old
+		maps.Copy(p.frags, frags)
+	}
+	t.runCommitDoneHooks(tainted, start, nil)
+
+	p.mu.Lock() // the timer may fire right away
+	p.timer = time.AfterFunc(timeout, func() {
+		_ = p.Rollback()
+	})
+	p.mu.Unlock()
+	return p, nil
+}
+
+// Confirm keeps the commit. It returns ErrNotConfirmed, if the commit
+// was rolled back already.
+func (p *PendingCommit) Confirm() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.err
+	default:
+	}
+	p.timer.Stop()
+	close(p.done)
+	return nil
+}
+
+// Rollback restores the previous contents of the committed configs now,
+// instead of waiting for the timeout. The restored configs are dropped
+// from the tree, so that they are reloaded on next use, and the
+// post-commit hooks (see Tree.OnPostCommit) are run for them again, e.g.
+// to reload services. It returns ErrNotConfirmed, or errors restoring
+// the configs; ErrConfirmed, if the commit was confirmed.
+func (p *PendingCommit) Rollback() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		if p.err != nil {
+			return p.err
+		}
+		return ErrConfirmed
+	default:
+	}
+	p.timer.Stop()
+
+	p.t.Lock()
+	err := p.restore()
+	p.t.Unlock()
+
+	p.err = errors.Join(ErrNotConfirmed, err)
+	close(p.done)
+	return p.err
+}
+
+// Done returns a channel, which is closed once the commit is confirmed
+// or rolled back.
+func (p *PendingCommit) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns nil, while the commit is pending or after it was
+// confirmed, and the error returned by Rollback, after it was rolled
+// back.
+func (p *PendingCommit) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// restore writes the previous contents of the configs and their
+// fragments back, and runs the post-commit hooks. Its call must be
+// guarded by locking the tree's mutex.
+func (p *PendingCommit) restore() error {
+	ctx := context.Background()
+	var errs []error
+	if err := p.t.restoreFragments(ctx, p.frags); err != nil {
+		errs = append(errs, err)
+	}
+	for name, old := range p.previous {
+		if err := p.t.backend.restore(ctx, name, old); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(p.t.configs, name)
+		delete(p.t.prov, name)
+		delete(p.t.loaded, name)
+		e := &PackageEvent{Config: name, New: old, ctx: ctx}
+		for _, h := range p.t.hooks.postCommit {
+			if err := h(e); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcSafeNetwork = "\nconfig interface 'lan'\n\toption ipaddr '192.168.1.1'\n\n"
+
+func TestSafeCommitRollback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork})
+	r := NewStoreTree(store)
+	var reloads []string
+	r.OnPostCommit(func(e *PackageEvent) error {
+		reloads = append(reloads, e.Config)
+		return nil
+	})
+
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	require.NoError(r.AddSection("guest", "guest", "interface"))
+	p, err := r.SafeCommit(context.Background(), 10*time.Millisecond)
+	require.NoError(err)
+
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("commit wasn't rolled back")
+	}
+	assert.True(errors.Is(p.Err(), ErrNotConfirmed))
+	assert.True(errors.Is(p.Confirm(), ErrNotConfirmed))
+	assert.Equal(tcSafeNetwork, string(store.files["network"]))
+	assert.NotContains(store.files, "guest")
+	assert.ElementsMatch([]string{"network", "guest", "network", "guest"}, reloads)
+
+	// the tree sees the restored configs
+	ipaddr, _ := r.GetLast("network", "lan", "ipaddr")
+	assert.Equal("192.168.1.1", ipaddr)
+}
+
+func TestSafeCommitIncludes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const user = "config rule 'ssh'\n\toption name 'Allow-SSH'\n"
+	store := NewMemoryStore(map[string]string{
+		"firewall":      "config include 'user'\n\toption path 'firewall_user'\n\nconfig include 'extra'\n\toption path 'firewall_extra'\n",
+		"firewall_user": user,
+	})
+	r := NewStoreTree(store)
+	r.SetIncludes(func(sec *Section) string {
+		return sec.LastValue("path")
+	})
+
+	assert.True(r.Set("firewall", "ssh", "dest_port", "22"))
+	p, err := r.SafeCommit(context.Background(), time.Hour)
+	require.NoError(err)
+	assert.Contains(string(store.files["firewall_user"]), "dest_port")
+	assert.Contains(store.files, "firewall_extra")
+
+	assert.True(errors.Is(p.Rollback(), ErrNotConfirmed))
+	assert.Equal(user, string(store.files["firewall_user"]))
+	assert.NotContains(store.files, "firewall_extra")
+}
+
+func TestSafeCommitConfirm(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork})
+	r := NewStoreTree(store)
+
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	p, err := r.SafeCommit(context.Background(), time.Hour)
+	require.NoError(err)
+	require.NoError(p.Confirm())
+	require.NoError(p.Confirm())
+	assert.NoError(p.Err())
+	assert.Equal(ErrConfirmed, p.Rollback())
+	assert.Contains(string(store.files["network"]), "10.0.0.1")
+
+	// explicit rollback
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.2"))
+	p, err = r.SafeCommit(context.Background(), time.Hour)
+	require.NoError(err)
+	assert.Contains(string(store.files["network"]), "10.0.0.2")
+	assert.True(errors.Is(p.Rollback(), ErrNotConfirmed))
+	assert.Contains(string(store.files["network"]), "10.0.0.1")
+}
+
+func TestSafeCommitFailure(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{"network": tcSafeNetwork, "system": "\nconfig system\n\n"})
+	r := NewStoreTree(store)
+	r.OnPreCommit(func(e *PackageEvent) error {
+		if e.Config == "system" {
+			return errFirewallOff
+		}
+		return nil
+	})
+
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	assert.True(r.Set("system", "@system[0]", "hostname", "router"))
+	_, err := r.SafeCommit(context.Background(), time.Hour)
+	require.True(errors.As(err, new(ErrVetoed)))
+	assert.Equal(tcSafeNetwork, string(store.files["network"]))
+	assert.Equal("\nconfig system\n\n", string(store.files["system"]))
+}
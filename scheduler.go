@@ -0,0 +1,153 @@
+package uci
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Defaults of SchedulerOptions.
+const (
+	DefaultCommitDelay    = time.Second
+	DefaultCommitInterval = time.Minute
+)
+
+// SchedulerOptions configure a CommitScheduler.
+type SchedulerOptions struct {
+	// Delay is how long a config has to be left alone, before its
+	// changes are written. Each change restarts the delay.
+	Delay time.Duration
+
+	// Interval is the minimum time between two writes of a config. It
+	// also bounds how long the write of a config changed again and
+	// again is postponed by Delay.
+	Interval time.Duration
+
+	// OnError is called, if writing a config fails. Its changes stay
+	// pending, and are written by the next commit of the config. It
+	// may be called concurrently.
+	OnError func(config string, err error)
+}
+
+// A CommitScheduler coalesces the commits of frequent small changes,
+// writing each config at most once per interval. This protects the
+// flash memory of devices, where an agent changes the configs often:
+//
+//	s := uci.NewCommitScheduler(tree, uci.SchedulerOptions{Interval: 5 * time.Minute})
+//	defer s.Close(ctx)
+//	tree.Set("network", "lan", "ipaddr", "192.168.1.2")
+//	s.Schedule("network")
+type CommitScheduler struct {
+	t    Tree
+	opts SchedulerOptions
+
+	mu      sync.Mutex
+	pending map[string]*scheduledCommit
+	written map[string]time.Time
+	closed  bool
+}
+
+type scheduledCommit struct {
+	first time.Time // of the changes not written yet
+	due   time.Time
+	timer *time.Timer
+}
+
+// NewCommitScheduler returns a scheduler committing the configs of t.
+func NewCommitScheduler(t Tree, opts SchedulerOptions) *CommitScheduler {
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultCommitDelay
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultCommitInterval
+	}
+	return &CommitScheduler{
+		t:       t,
+		opts:    opts,
+		pending: make(map[string]*scheduledCommit),
+		written: make(map[string]time.Time),
+	}
+}
+
+// Schedule commits the changes of the configs later: after Delay, but
+// not before Interval passed since their last write. Calls after Close
+// are ignored.
+func (s *CommitScheduler) Schedule(configs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	now := time.Now()
+	for _, config := range configs {
+		p := s.pending[config]
+		if p == nil {
+			p = &scheduledCommit{first: now}
+			s.pending[config] = p
+		}
+		p.due = now.Add(s.opts.Delay)
+		if deadline := p.first.Add(s.opts.Interval); p.due.After(deadline) {
+			p.due = deadline
+		}
+		if next := s.written[config].Add(s.opts.Interval); p.due.Before(next) {
+			p.due = next
+		}
+		if p.timer == nil {
+			p.timer = time.AfterFunc(p.due.Sub(now), func() { s.fire(config, p) })
+		} else {
+			p.timer.Reset(p.due.Sub(now))
+		}
+	}
+}
+
+// fire writes a config, when its timer expires.
+func (s *CommitScheduler) fire(config string, p *scheduledCommit) {
+	s.mu.Lock()
+	if s.pending[config] != p || time.Now().Before(p.due) {
+		// flushed, or rescheduled while the timer fired
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, config)
+	s.written[config] = time.Now()
+	s.mu.Unlock()
+
+	if err := s.t.CommitConfigs(context.Background(), config); err != nil && s.opts.OnError != nil {
+		s.opts.OnError(config, err)
+	}
+}
+
+// Pending returns the names of the configs scheduled to be written.
+func (s *CommitScheduler) Pending() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(s.pending))
+}
+
+// Flush writes the scheduled configs right away.
+func (s *CommitScheduler) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	now := time.Now()
+	configs := slices.Sorted(maps.Keys(s.pending))
+	for _, config := range configs {
+		s.pending[config].timer.Stop()
+		s.written[config] = now
+	}
+	clear(s.pending)
+	s.mu.Unlock()
+
+	return s.t.CommitConfigs(ctx, configs...)
+}
+
+// Close flushes the scheduled configs, and stops scheduling commits.
+func (s *CommitScheduler) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	return s.Flush(ctx)
+}
@@ -0,0 +1,105 @@
+package uci
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countCommits counts the writes of each config.
+func countCommits(t Tree) func(config string) int {
+	var mu sync.Mutex
+	writes := make(map[string]int)
+	t.OnCommitDone(func(e CommitDoneEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, config := range e.Configs {
+			writes[config]++
+		}
+	})
+	return func(config string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return writes[config]
+	}
+}
+
+func TestCommitConfigs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"network": "\nconfig interface 'lan'\n\toption proto 'static'\n\n",
+		"system":  "\nconfig system\n\toption hostname 'ap'\n\n",
+	})
+	r := NewStoreTree(store)
+	assert.True(r.Set("network", "lan", "proto", "dhcp"))
+	assert.True(r.Set("system", "@system[0]", "hostname", "ap2"))
+	require.NoError(r.CommitConfigs(context.Background(), "network", "missing"))
+	assert.Contains(string(store.files["network"]), "dhcp")
+	assert.Contains(string(store.files["system"]), "'ap'")
+
+	// other changes stay pending
+	require.NoError(r.Commit())
+	assert.Contains(string(store.files["system"]), "ap2")
+}
+
+func TestCommitScheduler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"network": "\nconfig interface 'lan'\n\toption proto 'static'\n\n",
+		"system":  "\nconfig system\n\toption hostname 'ap'\n\n",
+	})
+	r := NewStoreTree(store)
+	writes := countCommits(r)
+	s := NewCommitScheduler(r, SchedulerOptions{Delay: 10 * time.Millisecond, Interval: 200 * time.Millisecond})
+
+	// rapid changes are coalesced
+	for _, mtu := range []string{"1400", "1450", "1500"} {
+		assert.True(r.Set("network", "lan", "mtu", mtu))
+		s.Schedule("network")
+	}
+	assert.True(r.Set("system", "@system[0]", "hostname", "ap2"))
+	assert.Equal([]string{"network"}, s.Pending())
+	require.Eventually(func() bool { return writes("network") == 1 }, time.Second, time.Millisecond)
+	assert.Contains(string(store.files["network"]), "1500")
+	assert.Contains(string(store.files["system"]), "'ap'", "not scheduled")
+
+	// the next write waits for the interval
+	start := time.Now()
+	assert.True(r.Set("network", "lan", "mtu", "1420"))
+	s.Schedule("network")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(1, writes("network"))
+	require.Eventually(func() bool { return writes("network") == 2 }, time.Second, time.Millisecond)
+	assert.GreaterOrEqual(time.Since(start), 150*time.Millisecond)
+
+	// pending commits are flushed by Close
+	s.Schedule("system")
+	require.NoError(s.Close(context.Background()))
+	assert.Equal(1, writes("system"))
+	assert.Contains(string(store.files["system"]), "ap2")
+	assert.Empty(s.Pending())
+	s.Schedule("network")
+	assert.Empty(s.Pending())
+}
+
+func TestCommitSchedulerError(t *testing.T) {
+	store := NewMemoryStore(map[string]string{"network": "\nconfig interface 'lan'\n\n"})
+	r := NewStoreTree(store)
+	r.OnPreCommit(func(*PackageEvent) error { return errFirewallOff })
+	errs := make(chan error, 1)
+	s := NewCommitScheduler(r, SchedulerOptions{Delay: time.Millisecond, OnError: func(config string, err error) {
+		assert.Equal(t, "network", config)
+		errs <- err
+	}})
+	assert.True(t, r.Set("network", "lan", "proto", "dhcp"))
+	s.Schedule("network")
+	assert.ErrorIs(t, <-errs, errFirewallOff)
+}
@@ -0,0 +1,332 @@
+package schema
+
+import "github.com/wsiner/go-uci/ast"
+
+// opt describes a TypeOption option. An empty def means "no default".
+func opt(name, datatype, def, desc string) *Option {
+	o := &Option{
+		Name:        name,
+		Type:        ast.TypeOption,
+		Datatype:    datatype,
+		Description: desc,
+	}
+	if def != "" {
+		o.Default = []string{def}
+	}
+	return o
+}
+
+// list describes a TypeList option.
+func list(name, datatype, desc string, def ...string) *Option {
+	return &Option{
+		Name:        name,
+		Type:        ast.TypeList,
+		Datatype:    datatype,
+		Default:     def,
+		Description: desc,
+	}
+}
+
+// ref marks o as reference to sections of the given package and type.
+func ref(o *Option, pkg, typ, key string) *Option {
+	o.Ref = &Reference{Package: pkg, SectionType: typ, Key: key}
+	return o
+}
+
+// deprecated marks o as replaced by another option.
+func deprecated(o *Option, replacement string) *Option {
+	o.Deprecated = replacement
+	return o
+}
+
+// fields marks o as holding whitespace separated items.
+func fields(o *Option) *Option {
+	o.Fields = true
+	return o
+}
+
+const (
+	policy = `or("ACCEPT", "REJECT", "DROP")`
+	family = `or("any", "ipv4", "ipv6")`
+)
+
+// builtin contains descriptions of common OpenWrt packages. They are
+// based on the OpenWrt user guide and cover the frequently used options
+// only.
+var builtin = []*Package{
+	{
+		Name:        "system",
+		Description: "Basic system settings",
+		Sections: []*Section{{
+			Type:        "system",
+			Description: "General system settings",
+			Options: []*Option{
+				opt("hostname", "hostname", "OpenWrt", "The hostname for this system"),
+				opt("description", "string", "", "A short, single-line description for this system"),
+				opt("timezone", "string", "UTC", "POSIX.1 time zone string"),
+				opt("zonename", "string", "UTC", "IANA/Olson time zone string"),
+				opt("log_size", "uinteger", "64", "Size of the kernel ring buffer in KiB"),
+				opt("log_ip", "ipaddr", "", "IP address of a syslog server to which log messages should be sent"),
+				opt("log_port", "port", "514", "Port number of the remote syslog server"),
+				opt("log_proto", `or("udp", "tcp")`, "udp", "Protocol used to send log messages to the remote syslog server"),
+				opt("log_file", "file", "", "File to write log messages to"),
+				opt("conloglevel", "uinteger", "7", "Number between 1 (least verbose) and 8 (most verbose) for the console log level"),
+				opt("cronloglevel", "uinteger", "5", "Minimum level for cron messages to be logged to syslog"),
+				opt("ttylogin", "bool", "0", "Require authentication for local users to log in the system"),
+				opt("urandom_seed", "string", "0", "Path of the seed file for the random number generator"),
+			},
+		}, {
+			Type:        "timeserver",
+			Description: "NTP client and server settings",
+			Options: []*Option{
+				opt("enabled", "bool", "1", "Enable the NTP client"),
+				opt("enable_server", "bool", "0", "Enable the NTP server"),
+				list("server", "host", "NTP servers to synchronize the system time with"),
+				opt("use_dhcp", "bool", "1", "Use NTP servers announced via DHCP"),
+				opt("interface", "string", "", "Bind the NTP server to this interface"),
+			},
+		}},
+	},
+	{
+		Name:        "network",
+		Description: "Interfaces, devices and routes",
+		Sections: []*Section{{
+			Type:        "globals",
+			Description: "Global network settings",
+			Options: []*Option{
+				opt("ula_prefix", "ip6addr", "", "IPv6 ULA prefix for this device"),
+				opt("packet_steering", "bool", "0", "Use every CPU to handle packet traffic"),
+			},
+		}, {
+			Type:        "interface",
+			Description: "Logical network interface",
+			Options: []*Option{
+				opt("proto", "string", "none", "Protocol used to configure the interface (e.g. static, dhcp, pppoe)"),
+				opt("device", "string", "", "Name of the network device (or bridge) of the interface"),
+				deprecated(fields(opt("ifname", "string", "", "Name of the physical interface(s) (deprecated in favor of device)")), "device"),
+				opt("ipaddr", "ipaddr", "", "IP address (for proto static)"),
+				opt("netmask", "netmask", "", "Netmask (for proto static)"),
+				opt("gateway", "ipaddr", "", "Default gateway"),
+				opt("broadcast", "ip4addr", "", "Broadcast address"),
+				fields(list("ip6addr", "ip6addr", "IPv6 addresses with prefix length")),
+				opt("ip6assign", "uinteger", "", "Prefix length to delegate to this interface"),
+				fields(list("dns", "ipaddr", "DNS servers")),
+				opt("mtu", "uinteger", "", "Override the MTU of the interface"),
+				opt("metric", "uinteger", "0", "Default route metric"),
+				opt("auto", "bool", "1", "Bring up the interface on boot"),
+				opt("disabled", "bool", "0", "Disable the interface"),
+				opt("peerdns", "bool", "1", "Use DNS servers announced by the peer"),
+				opt("defaultroute", "bool", "1", "Install a default route via the peer's gateway"),
+				opt("macaddr", "macaddr", "", "Override the MAC address of the interface"),
+				opt("delegate", "bool", "1", "Delegate IPv6 prefixes to downstream interfaces"),
+				opt("force_link", "bool", "0", "Set addresses and routes regardless of the link state"),
+			},
+		}, {
+			Type:        "device",
+			Description: "Network device (e.g. bridge, VLAN)",
+			Options: []*Option{
+				opt("name", "string", "", "Name of the device"),
+				opt("type", "string", "", "Device type (e.g. bridge, 8021q)"),
+				fields(list("ports", "string", "Member ports of a bridge device")),
+				opt("macaddr", "macaddr", "", "Override the MAC address"),
+				opt("mtu", "uinteger", "", "Override the MTU"),
+				opt("ipv6", "bool", "1", "Enable IPv6 on the device"),
+			},
+		}, {
+			Type:        "route",
+			Description: "Static IPv4 route",
+			Options: []*Option{
+				ref(opt("interface", "string", "", "Logical interface the route belongs to"), "network", "interface", ""),
+				opt("target", "ipaddr", "", "Network address"),
+				opt("netmask", "netmask", "", "Route netmask"),
+				opt("gateway", "ipaddr", "", "Network gateway"),
+				opt("metric", "uinteger", "0", "Route metric"),
+				opt("mtu", "uinteger", "", "MTU of the route"),
+				opt("table", "string", "", "Routing table"),
+			},
+		}},
+	},
+	{
+		Name:        "dhcp",
+		Description: "DHCP and DNS (dnsmasq, odhcpd)",
+		Sections: []*Section{{
+			Type:        "dnsmasq",
+			Description: "Global dnsmasq settings",
+			Options: []*Option{
+				opt("domainneeded", "bool", "0", "Never forward queries for plain names"),
+				opt("boguspriv", "bool", "0", "Reject reverse lookups of private IP ranges"),
+				opt("rebind_protection", "bool", "1", "Discard upstream responses containing private IP addresses"),
+				opt("local", "string", "", "Domain to be answered from local data only"),
+				opt("domain", "string", "", "Local domain suffix"),
+				opt("authoritative", "bool", "0", "Act as the only DHCP server on the network"),
+				opt("leasefile", "file", "/tmp/dhcp.leases", "File to store DHCP leases in"),
+				opt("localservice", "bool", "1", "Only accept DNS queries from local subnets"),
+				list("server", "string", "Upstream DNS servers"),
+				opt("cachesize", "uinteger", "150", "Size of the DNS cache"),
+				opt("port", "port", "53", "Listening port for DNS queries"),
+				opt("noresolv", "bool", "0", "Do not read upstream servers from resolv.conf"),
+			},
+		}, {
+			Type:        "dhcp",
+			Description: "DHCP pool of an interface",
+			Options: []*Option{
+				ref(opt("interface", "string", "", "Logical interface of the pool"), "network", "interface", ""),
+				opt("start", "uinteger", "100", "Offset from the network address of the first leased address"),
+				opt("limit", "uinteger", "150", "Maximum number of leased addresses"),
+				opt("leasetime", "leasetime", "12h", "Lease time of addresses"),
+				opt("ignore", "bool", "0", "Disable DHCP on this interface"),
+				opt("dynamicdhcp", "bool", "1", "Dynamically allocate addresses (otherwise, only static leases are served)"),
+				opt("force", "bool", "0", "Serve DHCP, even if another server is detected"),
+				opt("dhcpv4", `or("server", "disabled")`, "", "DHCPv4 service mode of odhcpd"),
+				opt("dhcpv6", `or("server", "relay", "hybrid", "disabled")`, "", "DHCPv6 service mode of odhcpd"),
+				opt("ra", `or("server", "relay", "hybrid", "disabled")`, "", "Router advertisement mode of odhcpd"),
+				list("dhcp_option", "string", "Additional options to send to clients"),
+			},
+		}, {
+			Type:        "host",
+			Description: "Static lease",
+			Options: []*Option{
+				opt("name", "hostname", "", "Hostname assigned to the client"),
+				fields(opt("mac", "macaddr", "", "MAC address(es) of the client")),
+				opt("ip", "ipaddr", "", "IP address assigned to the client"),
+				opt("duid", "string", "", "DHCPv6 DUID of the client"),
+				opt("hostid", "string", "", "IPv6 host identifier"),
+				opt("leasetime", "leasetime", "", "Lease time overriding the pool's lease time"),
+				opt("dns", "bool", "0", "Add a static DNS entry for the host"),
+			},
+		}, {
+			Type:        "domain",
+			Description: "Static DNS entry",
+			Options: []*Option{
+				opt("name", "hostname", "", "Hostname"),
+				opt("ip", "ipaddr", "", "IP address the hostname resolves to"),
+			},
+		}},
+	},
+	{
+		Name:        "firewall",
+		Description: "Firewall zones, rules and redirects",
+		Sections: []*Section{{
+			Type:        "defaults",
+			Description: "Global firewall settings",
+			Options: []*Option{
+				opt("input", policy, "REJECT", "Default policy for the INPUT chain"),
+				opt("output", policy, "REJECT", "Default policy for the OUTPUT chain"),
+				opt("forward", policy, "REJECT", "Default policy for the FORWARD chain"),
+				opt("synflood_protect", "bool", "0", "Enable SYN flood protection"),
+				deprecated(opt("syn_flood", "bool", "0", "Enable SYN flood protection (deprecated in favor of synflood_protect)"), "synflood_protect"),
+				opt("drop_invalid", "bool", "0", "Drop invalid packets"),
+				opt("flow_offloading", "bool", "0", "Enable software flow offloading"),
+				opt("flow_offloading_hw", "bool", "0", "Enable hardware flow offloading"),
+			},
+		}, {
+			Type:        "zone",
+			Description: "Firewall zone grouping one or more interfaces",
+			Options: []*Option{
+				opt("name", "string", "", "Unique zone name"),
+				ref(fields(list("network", "string", "Logical interfaces attached to this zone")), "network", "interface", ""),
+				list("device", "string", "Raw network devices attached to this zone"),
+				list("subnet", "cidr", "Subnets attached to this zone"),
+				opt("input", policy, "", "Policy for incoming traffic (defaults to the global input policy)"),
+				opt("output", policy, "", "Policy for outgoing traffic (defaults to the global output policy)"),
+				opt("forward", policy, "", "Policy for forwarded traffic (defaults to the global forward policy)"),
+				opt("masq", "bool", "0", "Enable masquerading of outgoing traffic"),
+				opt("mtu_fix", "bool", "0", "Enable MSS clamping for outgoing traffic"),
+				opt("family", family, "any", "Protocol family"),
+				opt("log", "bool", "0", "Log dropped and rejected traffic"),
+			},
+		}, {
+			Type:        "forwarding",
+			Description: "Traffic forwarding between zones",
+			Options: []*Option{
+				ref(opt("src", "string", "", "Source zone"), "firewall", "zone", "name"),
+				ref(opt("dest", "string", "", "Destination zone"), "firewall", "zone", "name"),
+				opt("family", family, "any", "Protocol family"),
+				opt("enabled", "bool", "1", "Enable the forwarding"),
+			},
+		}, {
+			Type:        "rule",
+			Description: "Traffic rule",
+			Options: []*Option{
+				opt("name", "string", "", "Name of the rule"),
+				ref(opt("src", "string", "", "Source zone"), "firewall", "zone", "name"),
+				list("src_ip", "ipaddr", "Source addresses"),
+				opt("src_mac", "macaddr", "", "Source MAC address"),
+				fields(opt("src_port", "portrange", "", "Source port(s)")),
+				fields(list("proto", "string", "Protocols to match", "tcp", "udp")),
+				ref(opt("dest", "string", "", "Destination zone"), "firewall", "zone", "name"),
+				list("dest_ip", "ipaddr", "Destination addresses"),
+				fields(opt("dest_port", "portrange", "", "Destination port(s)")),
+				fields(list("icmp_type", "string", "ICMP types to match")),
+				opt("target", `or("ACCEPT", "REJECT", "DROP", "MARK", "NOTRACK")`, "DROP", "Action for matched traffic"),
+				opt("family", family, "any", "Protocol family"),
+				opt("limit", "rate", "", "Maximum average matching rate (e.g. 10/minute)"),
+				opt("enabled", "bool", "1", "Enable the rule"),
+			},
+		}, {
+			Type:        "redirect",
+			Description: "Port forwarding (DNAT) or SNAT rule",
+			Options: []*Option{
+				opt("name", "string", "", "Name of the redirect"),
+				ref(opt("src", "string", "", "Source zone"), "firewall", "zone", "name"),
+				opt("src_ip", "ipaddr", "", "Source address"),
+				fields(opt("src_dport", "portrange", "", "Incoming destination port(s)")),
+				fields(list("proto", "string", "Protocols to match", "tcp", "udp")),
+				ref(opt("dest", "string", "", "Destination zone"), "firewall", "zone", "name"),
+				opt("dest_ip", "ipaddr", "", "Internal address to redirect to"),
+				fields(opt("dest_port", "portrange", "", "Internal port(s) to redirect to")),
+				opt("target", `or("DNAT", "SNAT")`, "DNAT", "NAT target"),
+				opt("reflection", "bool", "1", "Enable NAT reflection"),
+				opt("enabled", "bool", "1", "Enable the redirect"),
+			},
+		}, {
+			Type:        "include",
+			Description: "Custom firewall script",
+			Options: []*Option{
+				opt("path", "file", "/etc/firewall.user", "Path of the script"),
+				opt("type", `or("script", "nftables")`, "script", "Type of the include"),
+				opt("enabled", "bool", "1", "Enable the include"),
+			},
+		}},
+	},
+	{
+		Name:        "wireless",
+		Description: "Wireless radios and networks",
+		Sections: []*Section{{
+			Type:        "wifi-device",
+			Description: "Physical radio",
+			Options: []*Option{
+				opt("type", "string", "", "Driver type (e.g. mac80211)"),
+				opt("path", "string", "", "Device path of the radio"),
+				opt("band", `or("2g", "5g", "6g", "60g")`, "", "Frequency band"),
+				opt("channel", "string", "auto", "Wireless channel, or auto"),
+				opt("htmode", "string", "", "Channel width and HT/VHT/HE mode (e.g. HT20, VHT80, HE80)"),
+				deprecated(opt("hwmode", "string", "", "Wireless mode (deprecated in favor of band)"), "band"),
+				opt("country", "string", "", "Country code used to determine regulatory settings"),
+				opt("txpower", "uinteger", "", "Transmit power in dBm"),
+				opt("cell_density", "uinteger", "0", "Configures data rates based on the coverage cell density"),
+				opt("disabled", "bool", "0", "Disable the radio"),
+			},
+		}, {
+			Type:        "wifi-iface",
+			Description: "Wireless network",
+			Options: []*Option{
+				ref(opt("device", "string", "", "Radio the network belongs to"), "wireless", "wifi-device", ""),
+				ref(fields(opt("network", "string", "", "Logical interface(s) the network is attached to")), "network", "interface", ""),
+				opt("mode", `or("ap", "sta", "adhoc", "wds", "monitor", "mesh")`, "ap", "Operation mode"),
+				opt("ssid", "string", "", "Network name"),
+				opt("encryption", "string", "none", "Encryption method (e.g. psk2, sae, sae-mixed)"),
+				opt("key", "string", "", "Pre-shared key or passphrase"),
+				opt("hidden", "bool", "0", "Do not broadcast the SSID"),
+				opt("isolate", "bool", "0", "Isolate wireless clients from each other"),
+				opt("ifname", "string", "", "Name of the network device"),
+				opt("macaddr", "macaddr", "", "Override the MAC address"),
+				opt("ieee80211r", "bool", "0", "Enable fast BSS transition"),
+				opt("ieee80211w", `or("0", "1", "2")`, "0", "Management frame protection (0: disabled, 1: optional, 2: required)"),
+				opt("macfilter", `or("disable", "allow", "deny")`, "disable", "MAC address filter policy"),
+				list("maclist", "macaddr", "MAC addresses for the MAC address filter"),
+				opt("disabled", "bool", "0", "Disable the network"),
+			},
+		}},
+	},
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Provenance describes where the value of an explained option comes from.
+type Provenance int
+
+const (
+	FromConfig  Provenance = iota // value is set in the config file
+	FromDefault                   // value is unset, the schema default applies
+	Unset                         // value is unset, and there is no default
+)
+
+func (p Provenance) String() string {
+	switch p {
+	case FromConfig:
+		return "config"
+	case FromDefault:
+		return "default"
+	case Unset:
+		return "unset"
+	}
+	return fmt.Sprintf("%%Provenance(%d)", int(p))
+}
+
+// An Explanation describes an option and its current value.
+type Explanation struct {
+	Path        ast.Path
+	SectionType string
+
+	// Spec is the option's description. It is nil, if the schema does
+	// not know the option.
+	Spec *Option
+
+	// Values contains the effective value(s), i.e. the configured
+	// values, or the schema default.
+	Values     []string
+	Provenance Provenance
+
+	// Origin tells where a configured value was read from (file and
+	// line), if Provenance is FromConfig.
+	Origin uci.Provenance
+}
+
+// Explain describes the option addressed by path (e.g.
+// "network.lan.ipaddr") using the Default schema. See Schema.Explain.
+func Explain(t uci.Tree, path string) (*Explanation, error) {
+	return Default.Explain(t, path)
+}
+
+// Explain describes the option addressed by path (e.g.
+// "network.lan.ipaddr"): its description, type and default from the
+// schema, as well as its current value in t and where that value comes
+// from. It returns an error, if the path does not address an option,
+// or if the config or section does not exist.
+func (s *Schema) Explain(t uci.Tree, path string) (*Explanation, error) {
+	p, err := ast.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.Option == "" {
+		return nil, fmt.Errorf("explain %s: path does not address an option", path)
+	}
+
+	cfg, ok := t.EnsureConfigLoaded(p.Config)
+	if !ok {
+		return nil, fmt.Errorf("explain %s: config %q not found", path, p.Config)
+	}
+	sec := cfg.Get(p.Section)
+	if sec == nil {
+		return nil, fmt.Errorf("explain %s: section %q not found", path, p.Section)
+	}
+
+	ex := &Explanation{
+		Path:        p,
+		SectionType: sec.Type,
+		Spec:        s.Lookup(p.Config, sec.Type, p.Option),
+	}
+
+	switch opt := sec.Get(p.Option); {
+	case opt != nil:
+		ex.Values = opt.Values
+		ex.Provenance = FromConfig
+		ex.Origin, _ = t.Provenance(p.Config, p.Section, p.Option)
+	case ex.Spec != nil && len(ex.Spec.Default) > 0:
+		ex.Values = ex.Spec.Default
+		ex.Provenance = FromDefault
+	default:
+		ex.Provenance = Unset
+	}
+	return ex, nil
+}
@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestExplain(t *testing.T) {
+	tree := uci.NewTree("../testdata")
+
+	tt := []struct {
+		path       string
+		secType    string
+		datatype   string
+		values     []string
+		provenance Provenance
+	}{
+		{"system.@system[0].hostname", "system", "hostname", []string{"testhost"}, FromConfig},
+		{"system.ntp.server", "timeserver", "host", []string{
+			"0.lede.pool.ntp.org",
+			"1.lede.pool.ntp.org",
+			"2.lede.pool.ntp.org",
+			"3.lede.pool.ntp.org",
+		}, FromConfig},
+		{"system.ntp.use_dhcp", "timeserver", "bool", []string{"1"}, FromDefault},
+		{"system.@system[0].log_ip", "system", "ipaddr", nil, Unset},
+		{"system.@system[0].unknown", "system", "", nil, Unset},
+		{"system.poe_passthrough.value", "gpio_switch", "", []string{"0"}, FromConfig},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.path, func(t *testing.T) {
+			ex, err := Explain(tree, tc.path)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.path, ex.Path.String())
+			assert.Equal(t, tc.secType, ex.SectionType)
+			assert.Equal(t, tc.values, ex.Values)
+			assert.Equal(t, tc.provenance, ex.Provenance)
+			if tc.provenance == FromConfig {
+				assert.Equal(t, "../testdata/system", ex.Origin.File)
+				assert.NotZero(t, ex.Origin.Line)
+			} else {
+				assert.Zero(t, ex.Origin)
+			}
+			if tc.datatype == "" {
+				assert.Nil(t, ex.Spec)
+			} else if assert.NotNil(t, ex.Spec) {
+				assert.Equal(t, tc.datatype, ex.Spec.Datatype)
+				assert.NotEmpty(t, ex.Spec.Description)
+			}
+		})
+	}
+}
+
+func TestExplain_errors(t *testing.T) {
+	tree := uci.NewTree("../testdata")
+
+	for path, msg := range map[string]string{
+		"system.ntp":               "explain system.ntp: path does not address an option",
+		"nonexistent.foo.bar":      `explain nonexistent.foo.bar: config "nonexistent" not found`,
+		"system.nonexistent.bar":   `explain system.nonexistent.bar: section "nonexistent" not found`,
+		"system.@system[9].foobar": `explain system.@system[9].foobar: section "@system[9]" not found`,
+	} {
+		_, err := Explain(tree, path)
+		assert.EqualError(t, err, msg)
+	}
+
+	_, err := Explain(tree, "")
+	assert.True(t, errors.Is(err, ast.ErrEmptyPath))
+}
+
+func TestRegister(t *testing.T) {
+	s := New()
+	assert.Nil(t, s.Lookup("foo", "bar", "baz"))
+
+	s.Register(&Package{Name: "foo", Sections: []*Section{{
+		Type:    "bar",
+		Options: []*Option{opt("baz", "bool", "0", "A switch")},
+	}}})
+	spec := s.Lookup("foo", "bar", "baz")
+	if assert.NotNil(t, spec) {
+		assert.Equal(t, []string{"0"}, spec.Default)
+	}
+	assert.Nil(t, s.Lookup("foo", "bar", "qux"))
+	assert.Nil(t, s.Lookup("foo", "qux", "baz"))
+}
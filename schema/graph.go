@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"sort"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+// A Dependency is a reference of an option value to a section, e.g. of
+// a firewall zone's network to a network interface.
+type Dependency struct {
+	From  ast.Path // the referring option
+	Value string   // the referring value, e.g. a zone name
+
+	// To is the referenced section. If it does not exist, Section is
+	// the value, and Dangling is set.
+	To       ast.Path
+	Dangling bool
+}
+
+// Find returns the section referenced by value in cfg, or nil.
+func (r *Reference) Find(cfg *ast.Config, value string) *ast.Section {
+	if r.Key == "" {
+		if sec := cfg.Get(value); sec != nil && sec.Type == r.SectionType {
+			return sec
+		}
+		return nil
+	}
+	for _, sec := range cfg.Sections {
+		if sec.Type != r.SectionType {
+			continue
+		}
+		if key := sec.Get(r.Key); key != nil {
+			for _, v := range key.Values {
+				if v == value {
+					return sec
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// References returns the dependencies of cfg's options, which the
+// schema describes as references. lookup returns the referenced
+// configs; references to configs it doesn't find are skipped. cfg
+// itself needn't be found by lookup.
+func (s *Schema) References(cfg *ast.Config, lookup func(name string) (*ast.Config, bool)) []Dependency {
+	pkg := s.Package(cfg.Name)
+	if pkg == nil {
+		return nil
+	}
+
+	var deps []Dependency
+	for _, sec := range cfg.Sections {
+		spec := pkg.Section(sec.Type)
+		if spec == nil {
+			continue
+		}
+		for _, opt := range sec.Options {
+			desc := spec.Option(opt.Name)
+			if desc == nil || desc.Ref == nil {
+				continue
+			}
+			target, ok := cfg, desc.Ref.Package == cfg.Name
+			if !ok {
+				target, ok = lookup(desc.Ref.Package)
+			}
+			if !ok {
+				continue
+			}
+			from := ast.Path{Config: cfg.Name, Section: cfg.SectionName(sec), Option: opt.Name}
+			names := opt.Values
+			if desc.Fields {
+				// e.g. wifi-iface's network may hold several names
+				names = opt.Fields()
+			}
+			for _, name := range names {
+				dep := Dependency{From: from, Value: name, To: ast.Path{Config: target.Name, Section: name}}
+				if ref := desc.Ref.Find(target, name); ref != nil {
+					dep.To.Section = target.SectionName(ref)
+				} else {
+					dep.Dangling = true
+				}
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return deps
+}
+
+// Graph describes the dependencies between the configs of a tree.
+type Graph struct {
+	// Dependencies are ordered by config name, and by their order in
+	// the config.
+	Dependencies []Dependency
+}
+
+// Resolve returns the dependency graph of t, using the Default schema.
+// See Schema.Resolve.
+func Resolve(t uci.Tree) *Graph {
+	return Default.Resolve(t)
+}
+
+// Resolve returns the dependency graph of t: it loads the configs of
+// all packages the schema knows references of, and resolves those.
+// Missing configs are treated as empty, i.e. references into them are
+// dangling.
+func (s *Schema) Resolve(t uci.Tree) *Graph {
+	lookup := func(name string) (*ast.Config, bool) {
+		if cfg, ok := t.EnsureConfigLoaded(name); ok {
+			return cfg, true
+		}
+		return ast.NewConfig(name), true
+	}
+
+	g := &Graph{}
+	for _, name := range s.referring() {
+		if cfg, ok := t.EnsureConfigLoaded(name); ok {
+			g.Dependencies = append(g.Dependencies, s.References(cfg, lookup)...)
+		}
+	}
+	return g
+}
+
+// referring returns the names of the packages with references, sorted.
+func (s *Schema) referring() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	var names []string
+	for name, p := range s.packages {
+		if p.hasRefs() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Package) hasRefs() bool {
+	for _, sec := range p.Sections {
+		for _, o := range sec.Options {
+			if o.Ref != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dangling returns the dependencies on sections which don't exist.
+func (g *Graph) Dangling() []Dependency {
+	var deps []Dependency
+	for _, d := range g.Dependencies {
+		if d.Dangling {
+			deps = append(deps, d)
+		}
+	}
+	return deps
+}
+
+// Dependents returns the dependencies on a section, e.g. to find the
+// options to update before deleting it. section may be a name or a
+// "@type[index]" selector, as used in the graph.
+func (g *Graph) Dependents(config, section string) []Dependency {
+	var deps []Dependency
+	for _, d := range g.Dependencies {
+		if !d.Dangling && d.To.Config == config && d.To.Section == section {
+			deps = append(deps, d)
+		}
+	}
+	return deps
+}
+
+// Configs maps the configs to the (other) configs they depend on, e.g.
+// "firewall" to "network". Configs without such dependencies are
+// omitted; the dependencies are sorted.
+func (g *Graph) Configs() map[string][]string {
+	seen := make(map[[2]string]bool)
+	configs := make(map[string][]string)
+	for _, d := range g.Dependencies {
+		edge := [2]string{d.From.Config, d.To.Config}
+		if d.From.Config == d.To.Config || seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		configs[d.From.Config] = append(configs[d.From.Config], d.To.Config)
+	}
+	for _, deps := range configs {
+		sort.Strings(deps)
+	}
+	return configs
+}
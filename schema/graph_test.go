@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestResolve(t *testing.T) {
+	assert := assert.New(t)
+
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{
+		"network": `
+config interface 'lan'
+	option proto 'static'
+
+config interface 'guest'
+	option proto 'static'
+`,
+		"firewall": `
+config zone
+	option name 'lan'
+	list network 'lan'
+	list network 'iot'
+
+config zone
+	option name 'guest'
+	list network 'guest'
+
+config forwarding
+	option src 'guest'
+	option dest 'wan'
+`,
+		"wireless": `
+config wifi-device 'radio0'
+	option band '2g'
+
+config wifi-iface
+	option device 'radio0'
+	option network 'lan guest'
+`,
+	}))
+
+	g := Resolve(tree)
+	assert.Len(g.Dependencies, 8)
+
+	assert.Equal([]Dependency{
+		{From: ast.Path{Config: "firewall", Section: "@zone[0]", Option: "network"}, Value: "iot",
+			To: ast.Path{Config: "network", Section: "iot"}, Dangling: true},
+		{From: ast.Path{Config: "firewall", Section: "@forwarding[0]", Option: "dest"}, Value: "wan",
+			To: ast.Path{Config: "firewall", Section: "wan"}, Dangling: true},
+	}, g.Dangling())
+
+	assert.Equal([]Dependency{
+		{From: ast.Path{Config: "firewall", Section: "@zone[1]", Option: "network"}, Value: "guest",
+			To: ast.Path{Config: "network", Section: "guest"}},
+		{From: ast.Path{Config: "wireless", Section: "@wifi-iface[0]", Option: "network"}, Value: "guest",
+			To: ast.Path{Config: "network", Section: "guest"}},
+	}, g.Dependents("network", "guest"))
+	assert.Equal([]Dependency{
+		{From: ast.Path{Config: "firewall", Section: "@forwarding[0]", Option: "src"}, Value: "guest",
+			To: ast.Path{Config: "firewall", Section: "@zone[1]"}},
+	}, g.Dependents("firewall", "@zone[1]"))
+
+	assert.Equal(map[string][]string{
+		"firewall": {"network"},
+		"wireless": {"network"},
+	}, g.Configs())
+}
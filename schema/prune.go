@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Prune removes the options of cfg whose values equal their defaults,
+// using the Default schema. See Schema.Prune.
+func Prune(cfg *ast.Config) []ast.Path {
+	return Default.Prune(cfg)
+}
+
+// Prune removes the options of cfg whose values equal the defaults
+// described by the schema, yielding configs as minimal as the ones of a
+// freshly flashed device. Boolean options are compared by meaning, so
+// that "off" matches a default of "0". Options without default, and
+// sections or packages unknown to the schema are left untouched.
+//
+// It returns the paths of the removed options, and marks cfg as
+// tainted, if it removed any.
+func (s *Schema) Prune(cfg *ast.Config) []ast.Path {
+	pkg := s.Package(cfg.Name)
+	if pkg == nil {
+		return nil
+	}
+
+	var pruned []ast.Path
+	for _, sec := range cfg.Sections {
+		spec := pkg.Section(sec.Type)
+		if spec == nil {
+			continue
+		}
+		name := cfg.SectionName(sec)
+
+		opts := sec.Options[:0]
+		for _, o := range sec.Options {
+			if IsDefault(spec.Option(o.Name), o.Values) {
+				pruned = append(pruned, ast.Path{Config: cfg.Name, Section: name, Option: o.Name})
+				continue
+			}
+			opts = append(opts, o)
+		}
+		sec.Options = opts
+	}
+	if len(pruned) > 0 {
+		cfg.SetTainted()
+	}
+	return pruned
+}
+
+// IsDefault reports whether values equal the default of o. It returns
+// false, if o is nil or has no default.
+func IsDefault(o *Option, values []string) bool {
+	if o == nil || len(o.Default) == 0 || len(values) != len(o.Default) {
+		return false
+	}
+	for i, v := range values {
+		def := o.Default[i]
+		if o.Datatype == "bool" {
+			b, ok := parseBool(v)
+			if d, _ := parseBool(def); ok && b == d {
+				continue
+			}
+			return false
+		}
+		if v != def {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBool interprets v like OpenWrt's config_get_bool.
+func parseBool(v string) (value, ok bool) {
+	switch v {
+	case "1", "on", "true", "yes", "enabled":
+		return true, true
+	case "0", "off", "false", "no", "disabled":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestPrune(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := ast.Parse("firewall", `
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+	option syn_flood 'off'
+	option drop_invalid '1'
+
+config rule
+	option name 'Allow-Ping'
+	list proto 'tcp'
+	list proto 'udp'
+	option target 'DROP'
+	option enabled 'yes'
+
+config custom
+	option enabled '1'
+`)
+	assert.NoError(err)
+
+	pruned := Prune(cfg)
+	assert.Equal([]ast.Path{
+		{Config: "firewall", Section: "@defaults[0]", Option: "input"},
+		{Config: "firewall", Section: "@defaults[0]", Option: "syn_flood"},
+		{Config: "firewall", Section: "@rule[0]", Option: "proto"},
+		{Config: "firewall", Section: "@rule[0]", Option: "target"},
+		{Config: "firewall", Section: "@rule[0]", Option: "enabled"},
+	}, pruned)
+	assert.True(cfg.Tainted())
+
+	assert.Equal([]string{"ACCEPT"}, cfg.Get("@defaults[0]").Value("output"))
+	assert.Equal([]string{"1"}, cfg.Get("@defaults[0]").Value("drop_invalid"))
+	assert.Len(cfg.Get("@rule[0]").Options, 1)
+	assert.Len(cfg.Get("@custom[0]").Options, 1)
+
+	unknown := ast.NewConfig("unknown")
+	assert.Empty(Prune(unknown))
+}
+
+func TestIsDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	flag := opt("enabled", "bool", "1", "")
+	assert.True(IsDefault(flag, []string{"on"}))
+	assert.False(IsDefault(flag, []string{"0"}))
+	assert.False(IsDefault(flag, []string{"maybe"}))
+	assert.False(IsDefault(flag, nil))
+	assert.False(IsDefault(nil, []string{"1"}))
+	assert.False(IsDefault(opt("name", "string", "", ""), []string{""}))
+}
@@ -0,0 +1,188 @@
+// Package schema describes the well-known UCI packages: which section
+// types they contain, which options those sections have, and what the
+// options mean (type, datatype, default value and description).
+//
+// The Default schema contains descriptions for common OpenWrt packages.
+// Users can register their own packages, or replace built-in ones.
+package schema
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Option describes an option of a section.
+type Option struct {
+	Name string         `json:"name"`
+	Type ast.OptionType `json:"type"`
+
+	// Datatype names the expected value format, using the names of
+	// OpenWrt's validation library (e.g. "ipaddr", "bool", "uinteger").
+	Datatype string `json:"datatype,omitempty"`
+
+	// Default contains the value(s) assumed by the consuming service,
+	// if the option is not set.
+	Default []string `json:"default,omitempty"`
+
+	Description string `json:"description,omitempty"`
+
+	// Ref is set, if the option's values refer to other sections.
+	Ref *Reference `json:"ref,omitempty"`
+
+	// Deprecated names the option replacing this one, if it should no
+	// longer be used.
+	Deprecated string `json:"deprecated,omitempty"`
+
+	// Fields is set, if a value may hold several whitespace separated
+	// items (e.g. "option dest_port '22 80'"), see ast.Option.Fields.
+	// Datatype describes the items then.
+	Fields bool `json:"fields,omitempty"`
+}
+
+// A Reference describes which sections an option's values refer to,
+// e.g. a firewall zone's "network" list refers to interface sections
+// of the network package.
+type Reference struct {
+	Package     string `json:"package"`
+	SectionType string `json:"section_type"`
+
+	// Key names the option identifying the referenced section (e.g.
+	// firewall zones are referenced by their "name" option). If empty,
+	// values refer to the section name.
+	Key string `json:"key,omitempty"`
+}
+
+// Section describes a section type of a package.
+type Section struct {
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Options     []*Option `json:"options"`
+}
+
+// Option looks up the option with the given name.
+func (s *Section) Option(name string) *Option {
+	for _, o := range s.Options {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// Package describes a UCI package (a config file).
+type Package struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Sections    []*Section `json:"sections"`
+}
+
+// Section looks up the section type with the given name.
+func (p *Package) Section(typ string) *Section {
+	for _, s := range p.Sections {
+		if s.Type == typ {
+			return s
+		}
+	}
+	return nil
+}
+
+// Schema is a collection of package descriptions. It is safe for
+// concurrent use.
+type Schema struct {
+	packages map[string]*Package
+	sync.RWMutex
+}
+
+// New creates a schema with the given packages.
+func New(pkgs ...*Package) *Schema {
+	s := &Schema{packages: make(map[string]*Package, len(pkgs))}
+	for _, p := range pkgs {
+		s.packages[p.Name] = p
+	}
+	return s
+}
+
+// Default is the schema with descriptions of common OpenWrt packages.
+var Default = New(builtin...)
+
+// Register adds a package description to the schema. An existing
+// description with the same name is replaced.
+func (s *Schema) Register(p *Package) {
+	s.Lock()
+	s.packages[p.Name] = p
+	s.Unlock()
+}
+
+// Package returns the description of the named package, or nil.
+func (s *Schema) Package(name string) *Package {
+	s.RLock()
+	defer s.RUnlock()
+	return s.packages[name]
+}
+
+// Packages returns the descriptions of all packages, ordered by name.
+func (s *Schema) Packages() []*Package {
+	s.RLock()
+	defer s.RUnlock()
+
+	pkgs := make([]*Package, 0, len(s.packages))
+	for _, p := range s.packages {
+		pkgs = append(pkgs, p)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs
+}
+
+// Lookup returns the description of an option, or nil if the schema
+// does not know the package, section type or option.
+func (s *Schema) Lookup(pkg, secType, option string) *Option {
+	p := s.Package(pkg)
+	if p == nil {
+		return nil
+	}
+	sec := p.Section(secType)
+	if sec == nil {
+		return nil
+	}
+	return sec.Option(option)
+}
+
+// Refs returns the references to sections by name (i.e. those without
+// a Key) of all registered packages, to be used with Config.Rename or
+// Tree.RenameSection.
+func (s *Schema) Refs() ast.RefMap {
+	s.RLock()
+	defer s.RUnlock()
+
+	refs := make(ast.RefMap)
+	for _, p := range s.packages {
+		for _, sec := range p.Sections {
+			for _, o := range sec.Options {
+				if o.Ref == nil || o.Ref.Key != "" {
+					continue
+				}
+				refs[o.Ref.Package] = append(refs[o.Ref.Package], ast.Ref{
+					Config:      p.Name,
+					SectionType: sec.Type,
+					Option:      o.Name,
+					TargetType:  o.Ref.SectionType,
+				})
+			}
+		}
+	}
+	for _, list := range refs {
+		sort.Slice(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.Config != b.Config {
+				return a.Config < b.Config
+			}
+			if a.SectionType != b.SectionType {
+				return a.SectionType < b.SectionType
+			}
+			return a.Option < b.Option
+		})
+	}
+	return refs
+}
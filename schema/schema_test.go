@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestRefs(t *testing.T) {
+	refs := Default.Refs()
+
+	assert.Contains(t, refs["network"], ast.Ref{
+		Config:      "firewall",
+		SectionType: "zone",
+		Option:      "network",
+		TargetType:  "interface",
+	})
+	assert.Contains(t, refs["wireless"], ast.Ref{
+		Config:      "wireless",
+		SectionType: "wifi-iface",
+		Option:      "device",
+		TargetType:  "wifi-device",
+	})
+
+	// zones are referenced by their name option, not the section name
+	assert.Empty(t, refs["firewall"])
+}
+
+func TestPackages(t *testing.T) {
+	s := New(&Package{Name: "b"}, &Package{Name: "a"})
+	pkgs := s.Packages()
+	if assert.Len(t, pkgs, 2) {
+		assert.Equal(t, "a", pkgs[0].Name)
+		assert.Equal(t, "b", pkgs[1].Name)
+	}
+}
+
+func TestFields(t *testing.T) {
+	assert.True(t, Default.Lookup("firewall", "rule", "dest_port").Fields)
+	assert.True(t, Default.Lookup("wireless", "wifi-iface", "network").Fields)
+	assert.False(t, Default.Lookup("firewall", "rule", "name").Fields)
+}
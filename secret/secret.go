@@ -0,0 +1,103 @@
+// Package secret encrypts the values of sensitive options (Wi-Fi keys,
+// PPPoE passwords, WireGuard private keys, ...), so that configs can be
+// exported to YAML, TOML or JSON documents, or be put into backup
+// bundles, without storing credentials in plain text.
+//
+// Encryption is applied to a copy of a config right before exporting it,
+// and reverted right after importing it:
+//
+//	enc, err := secret.Encrypt(cfg, cipher)
+//	doc, err := convert.MarshalYAML(enc)
+//	...
+//	cfg, err := convert.UnmarshalYAML("wireless", doc)
+//	cfg, err = secret.Decrypt(cfg, cipher)
+//
+// Configs written to /etc/config always contain plain text values, as
+// neither the uci CLI nor the daemons reading the files know about the
+// encryption.
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Prefix marks encrypted values. The remainder of the value is the
+// base64 encoded ciphertext.
+const Prefix = "$enc$"
+
+var ErrMalformed = errors.New("malformed encrypted value")
+
+// A Cipher encrypts and decrypts option values.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// DefaultOptions lists the options encrypted by Encrypt, if no patterns
+// are given.
+var DefaultOptions = ast.SecretOptions
+
+// IsEncrypted reports whether v is an encrypted value.
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, Prefix)
+}
+
+// Encrypt returns a copy of cfg, where the values of all options
+// matching one of the patterns (or DefaultOptions, if none are given)
+// are encrypted. Values already encrypted are kept as is.
+func Encrypt(cfg *ast.Config, c Cipher, patterns ...string) (*ast.Config, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultOptions
+	}
+	return transform(cfg, func(opt *ast.Option, v string) (string, error) {
+		if !ast.MatchOption(opt.Name, patterns...) || IsEncrypted(v) {
+			return v, nil
+		}
+		b, err := c.Encrypt([]byte(v))
+		if err != nil {
+			return "", err
+		}
+		return Prefix + base64.StdEncoding.EncodeToString(b), nil
+	})
+}
+
+// Decrypt returns a copy of cfg with all encrypted values decrypted,
+// regardless of the option names.
+func Decrypt(cfg *ast.Config, c Cipher) (*ast.Config, error) {
+	return transform(cfg, func(_ *ast.Option, v string) (string, error) {
+		if !IsEncrypted(v) {
+			return v, nil
+		}
+		b, err := base64.StdEncoding.DecodeString(v[len(Prefix):])
+		if err != nil {
+			return "", ErrMalformed
+		}
+		b, err = c.Decrypt(b)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	})
+}
+
+func transform(cfg *ast.Config, fn func(*ast.Option, string) (string, error)) (*ast.Config, error) {
+	clone := cfg.Clone()
+	for _, sec := range clone.Sections {
+		for _, opt := range sec.Options {
+			for i, v := range opt.Values {
+				nv, err := fn(opt, v)
+				if err != nil {
+					p := ast.Path{Config: clone.Name, Section: clone.SectionName(sec), Option: opt.Name}
+					return nil, fmt.Errorf("%s: %w", p, err)
+				}
+				opt.Values[i] = nv
+			}
+		}
+	}
+	return clone, nil
+}
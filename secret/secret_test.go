@@ -0,0 +1,118 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wsiner/go-uci/ast"
+	"github.com/wsiner/go-uci/convert"
+)
+
+const tcWireless = `
+config wifi-iface 'default_radio0'
+	option ssid 'OpenWrt'
+	option encryption 'psk2'
+	option key 'hunter22'
+
+config interface 'wan'
+	option proto 'pppoe'
+	option username 'alice'
+	option password 'secret'
+	option ppp_password 'other'
+	option key_mgmt 'none'
+`
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncrypt(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := ast.Parse("wireless", tcWireless)
+	require.NoError(t, err)
+	c, err := NewAESGCM(testKey)
+	require.NoError(t, err)
+
+	enc, err := Encrypt(cfg, c)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		section, option string
+		encrypted       bool
+	}{
+		{"default_radio0", "ssid", false},
+		{"default_radio0", "key", true},
+		{"wan", "username", false},
+		{"wan", "password", true},
+		{"wan", "ppp_password", true},
+		{"wan", "key_mgmt", false},
+	} {
+		v := enc.Get(tc.section).LastValue(tc.option)
+		assert.Equal(tc.encrypted, IsEncrypted(v), "%s.%s = %q", tc.section, tc.option, v)
+	}
+
+	// the original is not modified
+	assert.Equal("hunter22", cfg.Get("default_radio0").LastValue("key"))
+
+	// round trip through YAML
+	doc, err := convert.MarshalYAML(enc)
+	require.NoError(t, err)
+	assert.NotContains(string(doc), "hunter22")
+	imported, err := convert.UnmarshalYAML("wireless", doc)
+	require.NoError(t, err)
+	dec, err := Decrypt(imported, c)
+	require.NoError(t, err)
+	assert.Equal(cfg.Hash(), dec.Hash())
+
+	// encrypting twice is a no-op
+	again, err := Encrypt(enc, c)
+	require.NoError(t, err)
+	assert.Equal(enc.Hash(), again.Hash())
+}
+
+func TestEncrypt_patterns(t *testing.T) {
+	cfg, err := ast.Parse("wireless", tcWireless)
+	require.NoError(t, err)
+	c, err := NewAESGCM(testKey)
+	require.NoError(t, err)
+
+	enc, err := Encrypt(cfg, c, "username")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc.Get("wan").LastValue("username")))
+	assert.Equal(t, "secret", enc.Get("wan").LastValue("password"))
+}
+
+func TestDecrypt_errors(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := ast.Parse("wireless", tcWireless)
+	require.NoError(t, err)
+	c, err := NewAESGCM(testKey)
+	require.NoError(t, err)
+	enc, err := Encrypt(cfg, c)
+	require.NoError(t, err)
+
+	other, err := NewAESGCM(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	_, err = Decrypt(enc, other)
+	if assert.Error(err) {
+		assert.True(strings.HasPrefix(err.Error(), "wireless.default_radio0.key: "), err.Error())
+	}
+
+	enc.Get("wan").Get("password").Values[0] = Prefix + "not base64!"
+	enc.Get("default_radio0").Get("key").Values[0] = "hunter22"
+	_, err = Decrypt(enc, c)
+	assert.True(errors.Is(err, ErrMalformed))
+
+	enc.Get("wan").Get("password").Values[0] = Prefix + "AAAA"
+	_, err = Decrypt(enc, c)
+	assert.True(errors.Is(err, ErrShortCiphertext))
+}
+
+func TestNewAESGCM(t *testing.T) {
+	_, err := NewAESGCM([]byte("short"))
+	assert.Error(t, err)
+}
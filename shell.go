@@ -0,0 +1,70 @@
+package uci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteShellVars writes the variables config_load of OpenWrt's
+// /lib/functions.sh sets for cfg, as shell assignments. Shell scripts
+// can source the output and use config_get, config_foreach etc. as
+// usual, without calling uci on the target:
+//
+//	. /tmp/network.sh
+//	config_get proto lan proto
+//
+// Like "uci -n export" (used by config_load), unnamed sections are
+// named by their libuci ID (see AnonymousID). Lists are stored in the
+// _ITEMn and _LENGTH variables, and joined by spaces. Section and
+// option names which can't be part of a variable name are rejected
+// with ErrInvalidIdentifier, before anything is written.
+func WriteShellVars(w io.Writer, cfg *Config) error {
+	names := make([]string, len(cfg.Sections))
+	for i, sec := range cfg.Sections {
+		names[i] = sec.Name
+		if names[i] == "" {
+			names[i] = AnonymousID(cfg, sec)
+		}
+		if !ValidIdentifier(names[i]) {
+			return fmt.Errorf("%w: section %q", ErrInvalidIdentifier, names[i])
+		}
+		for _, opt := range sec.Options {
+			if !ValidIdentifier(opt.Name) {
+				return fmt.Errorf("%w: option %q", ErrInvalidIdentifier, opt.Name)
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	set := func(name, value string) {
+		fmt.Fprintf(bw, "export CONFIG_%s=%s\n", name, quoteValue(value))
+	}
+	var lists []string
+	for i, sec := range cfg.Sections {
+		prefix := names[i] + "_"
+		set(prefix+"TYPE", sec.Type)
+		for _, opt := range sec.Options {
+			switch {
+			case opt.Type == TypeList && len(opt.Values) > 0:
+				lists = append(lists, prefix+opt.Name)
+				for j, v := range opt.Values {
+					set(prefix+opt.Name+"_ITEM"+strconv.Itoa(j+1), v)
+				}
+				set(prefix+opt.Name+"_LENGTH", strconv.Itoa(len(opt.Values)))
+				set(prefix+opt.Name, strings.Join(opt.Values, " "))
+			case len(opt.Values) > 0:
+				set(prefix+opt.Name, opt.Values[len(opt.Values)-1])
+			}
+		}
+	}
+	set("SECTIONS", strings.Join(names, " "))
+	set("NUM_SECTIONS", strconv.Itoa(len(names)))
+	if len(names) > 0 {
+		set("SECTION", names[len(names)-1])
+	}
+	set("LIST_STATE", strings.Join(lists, " "))
+	return bw.Flush()
+}
@@ -0,0 +1,60 @@
+package uci
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+func TestWriteShellVars(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg, err := ast.Parse("network", `
+config interface 'lan'
+	option proto 'static'
+	option descr "it's the lan"
+	list dns '1.1.1.1'
+	list dns '9.9.9.9'
+
+config route
+	option target '10.0.0.0/8'
+`)
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	require.NoError(WriteShellVars(&buf, cfg))
+	assert.Equal(`export CONFIG_lan_TYPE='interface'
+export CONFIG_lan_proto='static'
+export CONFIG_lan_descr='it'\''s the lan'
+export CONFIG_lan_dns_ITEM1='1.1.1.1'
+export CONFIG_lan_dns_ITEM2='9.9.9.9'
+export CONFIG_lan_dns_LENGTH='2'
+export CONFIG_lan_dns='1.1.1.1 9.9.9.9'
+export CONFIG_cfg02c8b4_TYPE='route'
+export CONFIG_cfg02c8b4_target='10.0.0.0/8'
+export CONFIG_SECTIONS='lan cfg02c8b4'
+export CONFIG_NUM_SECTIONS='2'
+export CONFIG_SECTION='cfg02c8b4'
+export CONFIG_LIST_STATE='lan_dns'
+`, buf.String())
+
+	if _, err := exec.LookPath("sh"); err == nil {
+		cmd := exec.Command("sh", "-c", `eval "$VARS"; printf '%s|%s' "$CONFIG_lan_descr" "$CONFIG_SECTIONS"`)
+		cmd.Env = []string{"VARS=" + buf.String()}
+		out, err := cmd.Output()
+		require.NoError(err)
+		assert.Equal("it's the lan|lan cfg02c8b4", string(out))
+	}
+
+	cfg.Get("lan").Add(NewOption("bad-name", TypeOption, "x"))
+	buf.Reset()
+	assert.True(errors.Is(WriteShellVars(&buf, cfg), ErrInvalidIdentifier))
+	assert.Empty(buf.String())
+}
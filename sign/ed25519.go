@@ -0,0 +1,37 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// Ed25519Signer signs data with an ed25519 key.
+type Ed25519Signer struct {
+	Key ed25519.PrivateKey
+}
+
+// Sign implements Signer.
+func (s Ed25519Signer) Sign(data []byte) (*Signature, error) {
+	if len(s.Key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("sign: invalid ed25519 key size %d", len(s.Key))
+	}
+	return &Signature{Algorithm: AlgEd25519, Value: ed25519.Sign(s.Key, data)}, nil
+}
+
+// Ed25519Verifier accepts ed25519 signatures made with any of its keys.
+type Ed25519Verifier struct {
+	Keys []ed25519.PublicKey
+}
+
+// Verify implements Verifier.
+func (v Ed25519Verifier) Verify(data []byte, sig *Signature) error {
+	if sig.Algorithm != AlgEd25519 {
+		return fmt.Errorf("%w: unexpected algorithm %q", ErrBadSignature, sig.Algorithm)
+	}
+	for _, key := range v.Keys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, data, sig.Value) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
@@ -0,0 +1,165 @@
+// Package sign signs serialized configs and backup bundles, so that
+// devices only accept configuration pushed by a trusted controller.
+//
+// Signatures are either kept separately, or embedded into config files
+// as comment header, which uci and the daemons reading the files ignore:
+//
+//	signed, err := sign.Embed(body, signer)
+//	...
+//	cfg, err := sign.VerifyConfig("network", signed, verifier)
+//
+// Both ed25519 keys and x509 certificates (with RSA, ECDSA or ed25519
+// keys) are supported.
+package sign
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Algorithms of signatures.
+const (
+	AlgEd25519 = "ed25519"
+	AlgX509    = "x509"
+)
+
+// Prefixes of the comment lines of a signature header.
+const (
+	signaturePrefix   = "# signature: "
+	certificatePrefix = "# certificate: "
+)
+
+var (
+	ErrUnsigned     = errors.New("not signed")
+	ErrMalformed    = errors.New("malformed signature")
+	ErrBadSignature = errors.New("invalid signature")
+)
+
+// A Signature signs some data.
+type Signature struct {
+	Algorithm string // AlgEd25519 or AlgX509
+	Value     []byte
+
+	// Chain holds the signer's certificate, followed by the
+	// intermediate certificates, for AlgX509 signatures.
+	Chain []*x509.Certificate
+}
+
+// A Signer signs data.
+type Signer interface {
+	Sign(data []byte) (*Signature, error)
+}
+
+// A Verifier checks whether a signature is valid, and made by a trusted
+// signer.
+type Verifier interface {
+	Verify(data []byte, sig *Signature) error
+}
+
+// MarshalText encodes the signature as the comment lines embedded into
+// configs: a "# signature:" line with algorithm and base64 encoded value,
+// followed by a "# certificate:" line per certificate of the chain.
+func (s *Signature) MarshalText() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(signaturePrefix + s.Algorithm + " " + base64.StdEncoding.EncodeToString(s.Value) + "\n")
+	for _, cert := range s.Chain {
+		b.WriteString(certificatePrefix + base64.StdEncoding.EncodeToString(cert.Raw) + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalText decodes a signature encoded by MarshalText.
+func (s *Signature) UnmarshalText(text []byte) error {
+	sig, rest, err := split(text)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return fmt.Errorf("%w: trailing data", ErrMalformed)
+	}
+	*s = *sig
+	return nil
+}
+
+// Embed signs body, and returns it with the signature prepended as
+// comment header. A signature already embedded into body is replaced.
+func Embed(body []byte, s Signer) ([]byte, error) {
+	if _, rest, err := split(body); err == nil {
+		body = rest
+	}
+	sig, err := s.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+	header, err := sig.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return append(header, body...), nil
+}
+
+// Verify checks the signature embedded into signed, and returns the data
+// following it. If there is no signature, ErrUnsigned is returned.
+func Verify(signed []byte, v Verifier) ([]byte, error) {
+	sig, body, err := split(signed)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Verify(body, sig); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// VerifyConfig checks the signature embedded into a config file, like
+// Verify, and parses the config only if it is valid.
+func VerifyConfig(name string, signed []byte, v Verifier) (*ast.Config, error) {
+	body, err := Verify(signed, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return ast.Parse(name, string(body))
+}
+
+// split returns the signature at the start of data, and the data
+// following it.
+func split(data []byte) (*Signature, []byte, error) {
+	line, rest, _ := bytes.Cut(data, []byte("\n"))
+	s, ok := strings.CutPrefix(strings.TrimSuffix(string(line), "\r"), signaturePrefix)
+	if !ok {
+		return nil, nil, ErrUnsigned
+	}
+	alg, value, ok := strings.Cut(s, " ")
+	if !ok {
+		return nil, nil, ErrMalformed
+	}
+	sig := &Signature{Algorithm: alg}
+	var err error
+	if sig.Value, err = base64.StdEncoding.DecodeString(value); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	for {
+		line, next, _ := bytes.Cut(rest, []byte("\n"))
+		s, ok := strings.CutPrefix(strings.TrimSuffix(string(line), "\r"), certificatePrefix)
+		if !ok {
+			break
+		}
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		sig.Chain = append(sig.Chain, cert)
+		rest = next
+	}
+	return sig, rest, nil
+}
@@ -0,0 +1,122 @@
+package sign
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tcNetwork = `config interface 'lan'
+	option proto 'static'
+	option ipaddr '192.168.1.1'
+`
+
+func TestEd25519(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	v := Ed25519Verifier{Keys: []ed25519.PublicKey{other, pub}}
+
+	signed, err := Embed([]byte(tcNetwork), Ed25519Signer{Key: priv})
+	require.NoError(err)
+	assert.True(strings.HasPrefix(string(signed), "# signature: ed25519 "))
+
+	cfg, err := VerifyConfig("network", signed, v)
+	require.NoError(err)
+	assert.Equal("192.168.1.1", cfg.Get("lan").LastValue("ipaddr"))
+
+	// signing again replaces the signature
+	resigned, err := Embed(signed, Ed25519Signer{Key: priv})
+	require.NoError(err)
+	assert.Equal(signed, resigned)
+
+	tampered := []byte(strings.Replace(string(signed), "192.168.1.1", "10.0.0.1", 1))
+	_, err = VerifyConfig("network", tampered, v)
+	assert.ErrorIs(err, ErrBadSignature)
+
+	_, err = Verify(signed, Ed25519Verifier{Keys: []ed25519.PublicKey{other}})
+	assert.ErrorIs(err, ErrBadSignature)
+	_, err = Verify([]byte(tcNetwork), v)
+	assert.ErrorIs(err, ErrUnsigned)
+	_, err = Verify([]byte("# signature: ed25519 !!!\n"+tcNetwork), v)
+	assert.ErrorIs(err, ErrMalformed)
+
+	// detached signatures, e.g. of backup bundles
+	bundle := []byte{0x1f, 0x8b, 0x08, 0x00}
+	sig, err := Ed25519Signer{Key: priv}.Sign(bundle)
+	require.NoError(err)
+	text, err := sig.MarshalText()
+	require.NoError(err)
+	var decoded Signature
+	require.NoError(decoded.UnmarshalText(text))
+	assert.NoError(v.Verify(bundle, &decoded))
+}
+
+func TestX509(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Now()
+	newCert := func(cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, ca bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(err)
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(now.UnixNano()),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			IsCA:                  ca,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		require.NoError(err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(err)
+		return cert, key
+	}
+	root, rootKey := newCert("root", nil, nil, true)
+	inter, interKey := newCert("intermediate", root, rootKey, true)
+	leaf, leafKey := newCert("controller", inter, interKey, false)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	signed, err := Embed([]byte(tcNetwork), X509Signer{Chain: []*x509.Certificate{leaf, inter}, Key: leafKey})
+	require.NoError(err)
+	assert.Equal(2, strings.Count(string(signed), "# certificate: "))
+
+	body, err := Verify(signed, X509Verifier{Roots: roots})
+	require.NoError(err)
+	assert.Equal(tcNetwork, string(body))
+
+	// untrusted or expired signers
+	_, err = Verify(signed, X509Verifier{Roots: x509.NewCertPool()})
+	assert.ErrorIs(err, ErrBadSignature)
+	_, err = Verify(signed, X509Verifier{Roots: roots, CurrentTime: now.Add(2 * time.Hour)})
+	assert.ErrorIs(err, ErrBadSignature)
+
+	// signatures of other algorithms
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	signed, err = Embed([]byte(tcNetwork), Ed25519Signer{Key: priv})
+	require.NoError(err)
+	_, err = Verify(signed, X509Verifier{Roots: roots})
+	assert.ErrorIs(err, ErrBadSignature)
+}
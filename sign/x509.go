@@ -0,0 +1,99 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// X509Signer signs data with the key of a certificate, which is
+// embedded into the signature along with the intermediate certificates.
+type X509Signer struct {
+	Chain []*x509.Certificate // the signer's certificate first
+	Key   crypto.Signer       // an RSA, ECDSA or ed25519 key
+}
+
+// Sign implements Signer.
+func (s X509Signer) Sign(data []byte) (*Signature, error) {
+	if len(s.Chain) == 0 {
+		return nil, errors.New("sign: no certificate")
+	}
+	var (
+		value []byte
+		err   error
+	)
+	if _, ok := s.Key.Public().(ed25519.PublicKey); ok {
+		value, err = s.Key.Sign(rand.Reader, data, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(data)
+		value, err = s.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	return &Signature{Algorithm: AlgX509, Value: value, Chain: s.Chain}, nil
+}
+
+// X509Verifier accepts signatures made with the key of a certificate,
+// which chains up to one of its roots.
+type X509Verifier struct {
+	Roots *x509.CertPool
+
+	// KeyUsages the signer's certificate must allow. If empty, any
+	// usage is accepted.
+	KeyUsages []x509.ExtKeyUsage
+
+	// CurrentTime is used to check the validity of the certificates.
+	// If zero, the current time is used.
+	CurrentTime time.Time
+}
+
+// Verify implements Verifier.
+func (v X509Verifier) Verify(data []byte, sig *Signature) error {
+	if sig.Algorithm != AlgX509 {
+		return fmt.Errorf("%w: unexpected algorithm %q", ErrBadSignature, sig.Algorithm)
+	}
+	if len(sig.Chain) == 0 {
+		return fmt.Errorf("%w: no certificate", ErrBadSignature)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     v.KeyUsages,
+		CurrentTime:   v.CurrentTime,
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	for _, cert := range sig.Chain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	cert := sig.Chain[0]
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+	if err := cert.CheckSignature(signatureAlgorithm(cert.PublicKey), data, sig.Value); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+	return nil
+}
+
+// signatureAlgorithm returns the algorithm X509Signer uses for key.
+func signatureAlgorithm(key any) x509.SignatureAlgorithm {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA
+	case *ecdsa.PublicKey:
+		return x509.ECDSAWithSHA256
+	case ed25519.PublicKey:
+		return x509.PureEd25519
+	}
+	return x509.UnknownSignatureAlgorithm
+}
@@ -0,0 +1,82 @@
+package uci
+
+import "sort"
+
+// A Snapshot is an immutable copy of the configs loaded in a tree, taken
+// by Tree.Snapshot. Later changes of the tree don't affect it, and it is
+// safe for concurrent use.
+type Snapshot struct {
+	configs map[string]*Config
+}
+
+func (t *tree) Snapshot() *Snapshot {
+	t.Lock()
+	defer t.Unlock()
+
+	s := &Snapshot{configs: make(map[string]*Config, len(t.configs))}
+	for name, cfg := range t.configs {
+		s.configs[name] = cfg.Clone()
+	}
+	return s
+}
+
+// Configs returns the names of the configs in s, in alphabetical order.
+func (s *Snapshot) Configs() []string {
+	names := make([]string, 0, len(s.configs))
+	for name := range s.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config returns a copy of the named config, which can be modified
+// without affecting s. The boolean is false if s has no such config.
+func (s *Snapshot) Config(name string) (*Config, bool) {
+	cfg, ok := s.configs[name]
+	if !ok {
+		return nil, false
+	}
+	return cfg.Clone(), true
+}
+
+// GetSections works like Tree.GetSections.
+func (s *Snapshot) GetSections(config, secType string) ([]string, bool) {
+	cfg, ok := s.configs[config]
+	if !ok {
+		return nil, false
+	}
+	names := []string{}
+	for _, sec := range cfg.Sections {
+		if sec.Type == secType {
+			names = append(names, cfg.SectionName(sec))
+		}
+	}
+	return names, true
+}
+
+// Get works like Tree.Get, but returns a copy of the values.
+func (s *Snapshot) Get(config, section, option string) ([]string, bool) {
+	cfg, ok := s.configs[config]
+	if !ok {
+		return nil, false
+	}
+	sec := cfg.Get(section)
+	if sec == nil {
+		return nil, false
+	}
+	opt := sec.Get(option)
+	if opt == nil {
+		return nil, true
+	}
+	return append([]string(nil), opt.Values...), true
+}
+
+// GetLast works like Tree.GetLast.
+func (s *Snapshot) GetLast(config, section, option string) (string, bool) {
+	vals, ok := s.Get(config, section, option)
+	if !ok || len(vals) == 0 {
+		return "", false
+	}
+	return vals[len(vals)-1], true
+}
@@ -0,0 +1,62 @@
+package uci
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{
+		"system":  "\nconfig system\n\toption hostname 'OpenWrt'\n\tlist ntp 'a'\n",
+		"network": "\nconfig interface 'lan'\n",
+	}))
+	require.NoError(r.LoadConfig("system", false))
+	require.NoError(r.LoadConfig("network", false))
+
+	s := r.Snapshot()
+	assert.Equal([]string{"network", "system"}, s.Configs())
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			host, ok := s.GetLast("system", "@system[0]", "hostname")
+			assert.True(ok)
+			assert.Equal("OpenWrt", host)
+		}()
+	}
+	assert.True(r.Set("system", "@system[0]", "hostname", "router"))
+	r.DelSection("network", "lan")
+	wg.Wait()
+
+	host, _ := s.GetLast("system", "@system[0]", "hostname")
+	assert.Equal("OpenWrt", host)
+	names, ok := s.GetSections("network", "interface")
+	assert.True(ok)
+	assert.Equal([]string{"lan"}, names)
+
+	// values and configs are copies
+	ntp, ok := s.Get("system", "@system[0]", "ntp")
+	require.True(ok)
+	ntp[0] = "b"
+	cfg, ok := s.Config("system")
+	require.True(ok)
+	cfg.Get("@system[0]").Del("ntp")
+	ntp, _ = s.Get("system", "@system[0]", "ntp")
+	assert.Equal([]string{"a"}, ntp)
+
+	_, ok = s.Get("missing", "x", "y")
+	assert.False(ok)
+	values, ok := s.Get("system", "@system[0]", "missing")
+	assert.True(ok)
+	assert.Nil(values)
+	_, ok = s.Config("missing")
+	assert.False(ok)
+}
@@ -0,0 +1,321 @@
+// Package sqm provides typed access to the sqm config of OpenWrt's
+// sqm-scripts, whose queue sections shape the traffic of an interface:
+//
+//	down, _ := sqm.ParseRate("250Mbit")
+//	q := &sqm.Queue{
+//		Name: "wan", Enabled: true, Interface: "eth1",
+//		Download: down.Percent(90), Upload: 20 * sqm.Mbit,
+//		Qdisc: sqm.Cake, Script: sqm.PieceOfCake,
+//		LinkLayer: sqm.Ethernet, Overhead: 44,
+//	}
+//	err := sqm.PutQueue(tree, q)
+//
+// Rates are stored in kbit/s, like sqm-scripts expects them.
+package sqm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrNoConfig      = errors.New("sqm config not found")
+	ErrInvalidRate   = errors.New("invalid rate")
+	ErrInvalidNumber = errors.New("invalid number")
+	ErrQdisc         = errors.New("invalid qdisc")
+	ErrScript        = errors.New("invalid script")
+	ErrLinkLayer     = errors.New("invalid link layer")
+	ErrIncompatible  = errors.New("script requires another qdisc")
+	ErrNoInterface   = errors.New("interface must not be empty")
+)
+
+// Rate is a bandwidth in kbit/s; 0 means unlimited.
+type Rate int64
+
+// Units of Rate.
+const (
+	Kbit Rate = 1
+	Mbit Rate = 1000 * Kbit
+	Gbit Rate = 1000 * Mbit
+)
+
+// ParseRate parses a rate like "50000", "100Mbit", "1.5 Gbit/s",
+// "100mbps" or "12.5MB/s" (megabytes, as in an upper case B). Plain
+// numbers are kbit/s. Fractions of kbit/s are rounded.
+func ParseRate(s string) (Rate, error) {
+	v := strings.TrimSpace(s)
+	scale, unit := 1.0, false
+	if rest, ok := strings.CutSuffix(v, "B/s"); ok {
+		v, scale, unit = rest, 8, true
+	} else {
+		lower := strings.ToLower(v)
+		for _, suffix := range []string{"bit/s", "bps", "bit"} {
+			if strings.HasSuffix(lower, suffix) {
+				v, unit = v[:len(v)-len(suffix)], true
+				break
+			}
+		}
+	}
+	switch strings.ToLower(v[max(len(v)-1, 0):]) {
+	case "k":
+		v = v[:len(v)-1]
+	case "m":
+		v, scale = v[:len(v)-1], scale*1e3
+	case "g":
+		v, scale = v[:len(v)-1], scale*1e6
+	default:
+		if unit {
+			scale /= 1000 // bits or bytes
+		}
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("%w %q", ErrInvalidRate, s)
+	}
+	return Rate(math.Round(f * scale)), nil
+}
+
+// String formats the rate in the largest unit it is a whole multiple
+// of, e.g. "100Mbit" or "1500kbit".
+func (r Rate) String() string {
+	switch {
+	case r != 0 && r%Gbit == 0:
+		return strconv.FormatInt(int64(r/Gbit), 10) + "Gbit"
+	case r != 0 && r%Mbit == 0:
+		return strconv.FormatInt(int64(r/Mbit), 10) + "Mbit"
+	}
+	return strconv.FormatInt(int64(r), 10) + "kbit"
+}
+
+// Kbit returns the rate in kbit/s.
+func (r Rate) Kbit() int64 {
+	return int64(r)
+}
+
+// Mbit returns the rate in Mbit/s.
+func (r Rate) Mbit() float64 {
+	return float64(r) / float64(Mbit)
+}
+
+// Percent returns p percent of the rate, rounded down. Shaping to 85-95%
+// of the line rate moves the queue from the modem into the router.
+func (r Rate) Percent(p int) Rate {
+	return r * Rate(p) / 100
+}
+
+// Qdisc is a queueing discipline.
+type Qdisc string
+
+// Qdiscs supported by sqm-scripts.
+const (
+	Cake    Qdisc = "cake"
+	FQCodel Qdisc = "fq_codel"
+	Codel   Qdisc = "codel"
+	SFQ     Qdisc = "sfq"
+	PIE     Qdisc = "pie"
+)
+
+func (q Qdisc) valid() bool {
+	return q == Cake || q == FQCodel || q == Codel || q == SFQ || q == PIE
+}
+
+// Script is a queue setup script of sqm-scripts.
+type Script string
+
+// Scripts. The cake scripts require the cake qdisc.
+const (
+	PieceOfCake Script = "piece_of_cake.qos"
+	LayerCake   Script = "layer_cake.qos"
+	Simple      Script = "simple.qos"
+	Simplest    Script = "simplest.qos"
+	SimplestTBF Script = "simplest_tbf.qos"
+)
+
+func (s Script) valid() bool {
+	return s == PieceOfCake || s == LayerCake || s == Simple || s == Simplest || s == SimplestTBF
+}
+
+func (s Script) cake() bool {
+	return s == PieceOfCake || s == LayerCake
+}
+
+// LinkLayer is the link layer whose overhead is accounted for.
+type LinkLayer string
+
+// Link layers. The empty link layer means "none".
+const (
+	None     LinkLayer = "none"
+	Ethernet LinkLayer = "ethernet"
+	ATM      LinkLayer = "atm"
+)
+
+func (l LinkLayer) valid() bool {
+	return l == "" || l == None || l == Ethernet || l == ATM
+}
+
+// Queue is a "config queue" section.
+type Queue struct {
+	Name      string
+	Enabled   bool
+	Interface string // device, e.g. "eth1" or "pppoe-wan"
+	Download  Rate   // ingress, 0 means not shaped
+	Upload    Rate   // egress, 0 means not shaped
+	Qdisc     Qdisc
+	Script    Script
+	LinkLayer LinkLayer
+	Overhead  int // bytes per packet, with LinkLayer
+}
+
+// queueOptions lists the options managed by Queue.
+var queueOptions = []string{
+	"enabled", "interface", "download", "upload", "qdisc", "script",
+	"linklayer", "overhead",
+}
+
+// ParseQueue converts a queue section.
+func ParseQueue(sec *uci.Section) (*Queue, error) {
+	q := &Queue{
+		Name:      sec.Name,
+		Enabled:   sec.LastValue("enabled") == "1",
+		Interface: sec.LastValue("interface"),
+		Qdisc:     Qdisc(sec.LastValue("qdisc")),
+		Script:    Script(sec.LastValue("script")),
+		LinkLayer: LinkLayer(sec.LastValue("linklayer")),
+	}
+	wrap := func(err error) (*Queue, error) {
+		return nil, fmt.Errorf("queue %s: %w", sec.Name, err)
+	}
+
+	for _, r := range []struct {
+		option string
+		rate   *Rate
+	}{{"download", &q.Download}, {"upload", &q.Upload}} {
+		n, err := parseInt(sec.LastValue(r.option))
+		if err != nil {
+			return wrap(fmt.Errorf("%s: %w", r.option, err))
+		}
+		*r.rate = Rate(n)
+	}
+	if v := sec.LastValue("overhead"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return wrap(fmt.Errorf("overhead: %w %q", ErrInvalidNumber, v))
+		}
+		q.Overhead = n
+	}
+	return q, nil
+}
+
+// Validate checks the interface, rates, qdisc, script and link layer,
+// and that cake scripts are used with the cake qdisc.
+func (q *Queue) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("queue %s: %w", q.Name, err)
+	}
+	if q.Interface == "" {
+		return wrap(ErrNoInterface)
+	}
+	if q.Download < 0 || q.Upload < 0 {
+		return wrap(fmt.Errorf("%w: negative", ErrInvalidRate))
+	}
+	if !q.Qdisc.valid() {
+		return wrap(fmt.Errorf("%w %q", ErrQdisc, q.Qdisc))
+	}
+	if !q.Script.valid() {
+		return wrap(fmt.Errorf("%w %q", ErrScript, q.Script))
+	}
+	if q.Script.cake() && q.Qdisc != Cake {
+		return wrap(fmt.Errorf("%w: %s with %s", ErrIncompatible, q.Script, q.Qdisc))
+	}
+	if !q.LinkLayer.valid() {
+		return wrap(fmt.Errorf("%w %q", ErrLinkLayer, q.LinkLayer))
+	}
+	if q.Overhead < -64 || q.Overhead > 256 {
+		return wrap(fmt.Errorf("%w: overhead %d", ErrInvalidNumber, q.Overhead))
+	}
+	return nil
+}
+
+// Section renders the queue section.
+func (q *Queue) Section() *uci.Section {
+	s := uci.NewSection("queue", q.Name)
+	if q.Enabled {
+		s.SetOption("enabled", "1")
+	} else {
+		s.SetOption("enabled", "0")
+	}
+	s.SetOption("interface", q.Interface)
+	s.SetOption("download", strconv.FormatInt(q.Download.Kbit(), 10))
+	s.SetOption("upload", strconv.FormatInt(q.Upload.Kbit(), 10))
+	s.SetOption("qdisc", string(q.Qdisc))
+	s.SetOption("script", string(q.Script))
+	if q.LinkLayer != "" {
+		s.SetOption("linklayer", string(q.LinkLayer))
+		if q.LinkLayer != None {
+			s.SetOption("overhead", strconv.Itoa(q.Overhead))
+		}
+	}
+	return s
+}
+
+// Queues returns the queues of the sqm config.
+func Queues(t uci.Tree) ([]*Queue, error) {
+	cfg, ok := t.EnsureConfigLoaded("sqm")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	var queues []*Queue
+	for _, sec := range cfg.Sections {
+		if sec.Type != "queue" {
+			continue
+		}
+		q, err := ParseQueue(sec)
+		if err != nil {
+			return nil, err
+		}
+		queues = append(queues, q)
+	}
+	return queues, nil
+}
+
+// GetQueue returns the named queue.
+func GetQueue(t uci.Tree, name string) (*Queue, error) {
+	cfg, ok := t.EnsureConfigLoaded("sqm")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	sec := cfg.Get(name)
+	if sec == nil {
+		return nil, uci.ErrSectionNotFound{Config: "sqm", Section: name}
+	}
+	return ParseQueue(sec)
+}
+
+// PutQueue validates q, and stores it in the sqm config, creating the
+// section (and config), if necessary. Options not represented by Queue
+// (e.g. qdisc_advanced) are kept.
+func PutQueue(t uci.Tree, q *Queue) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		_, err := tx.PutSection("sqm", q.Section(), queueOptions...)
+		return err
+	})
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,147 @@
+package sqm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestRate(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Rate
+		out  string
+	}{
+		{"50000", 50 * Mbit, "50Mbit"},
+		{"100Mbit", 100 * Mbit, "100Mbit"},
+		{"100 mbps", 100 * Mbit, "100Mbit"},
+		{"1.5 Gbit/s", 1500 * Mbit, "1500Mbit"},
+		{"1G", Gbit, "1Gbit"},
+		{"1500k", 1500, "1500kbit"},
+		{"12.5MB/s", 100 * Mbit, "100Mbit"},
+		{"125kB/s", Mbit, "1Mbit"},
+		{"64000bit", 64, "64kbit"},
+		{"0", 0, "0kbit"},
+	} {
+		r, err := ParseRate(tc.in)
+		if assert.NoError(t, err, tc.in) {
+			assert.Equal(t, tc.want, r, tc.in)
+			assert.Equal(t, tc.out, r.String())
+		}
+	}
+	for _, in := range []string{"", "fast", "-1Mbit", "1Tbit", "NaN", "Mbit"} {
+		_, err := ParseRate(in)
+		assert.ErrorIs(t, err, ErrInvalidRate, in)
+	}
+
+	assert := assert.New(t)
+	r := 250 * Mbit
+	assert.Equal(225*Mbit, r.Percent(90))
+	assert.Equal(int64(250000), r.Kbit())
+	assert.InDelta(250.0, r.Mbit(), 0)
+	assert.Equal(Rate(8), Rate(9).Percent(95))
+}
+
+func TestValidate(t *testing.T) {
+	valid := Queue{Name: "wan", Interface: "eth1", Download: 100 * Mbit, Qdisc: Cake, Script: PieceOfCake}
+	with := func(f func(q *Queue)) Queue {
+		q := valid
+		f(&q)
+		return q
+	}
+	for _, tc := range []struct {
+		name  string
+		queue Queue
+		err   error
+	}{
+		{"valid", valid, nil},
+		{"interface", with(func(q *Queue) { q.Interface = "" }), ErrNoInterface},
+		{"rate", with(func(q *Queue) { q.Upload = -1 }), ErrInvalidRate},
+		{"qdisc", with(func(q *Queue) { q.Qdisc = "htb" }), ErrQdisc},
+		{"script", with(func(q *Queue) { q.Script = "fancy.qos" }), ErrScript},
+		{"cake script", with(func(q *Queue) { q.Qdisc = FQCodel }), ErrIncompatible},
+		{"simple", with(func(q *Queue) { q.Qdisc, q.Script = FQCodel, Simple }), nil},
+		{"link layer", with(func(q *Queue) { q.LinkLayer = "docsis" }), ErrLinkLayer},
+		{"overhead", with(func(q *Queue) { q.LinkLayer, q.Overhead = ATM, 1000 }), ErrInvalidNumber},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.queue.Validate()
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestQueues(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "sqm"), []byte(`
+config queue 'wan'
+	option enabled '1'
+	option interface 'eth1'
+	option download '85000'
+	option upload '10000'
+	option qdisc 'cake'
+	option script 'piece_of_cake.qos'
+	option linklayer 'ethernet'
+	option overhead '44'
+	option qdisc_advanced '0'
+`), 0o644))
+	tree := uci.NewTree(dir)
+
+	queues, err := Queues(tree)
+	require.NoError(err)
+	require.Len(queues, 1)
+	q := queues[0]
+	assert.Equal(Queue{
+		Name: "wan", Enabled: true, Interface: "eth1", Download: 85 * Mbit, Upload: 10 * Mbit,
+		Qdisc: Cake, Script: PieceOfCake, LinkLayer: Ethernet, Overhead: 44,
+	}, *q)
+
+	q.Download = (100 * Mbit).Percent(90)
+	q.LinkLayer = None
+	require.NoError(PutQueue(tree, q))
+	q, err = GetQueue(tree, "wan")
+	require.NoError(err)
+	assert.Equal(90*Mbit, q.Download)
+	download, _ := tree.GetLast("sqm", "wan", "download")
+	assert.Equal("90000", download)
+	cfg, _ := tree.EnsureConfigLoaded("sqm")
+	assert.Nil(cfg.Get("wan").Get("overhead"))
+	advanced, _ := tree.GetLast("sqm", "wan", "qdisc_advanced")
+	assert.Equal("0", advanced)
+
+	assert.ErrorIs(PutQueue(tree, &Queue{Name: "lan", Interface: "br-lan", Qdisc: "htb", Script: Simple}), ErrQdisc)
+	_, err = GetQueue(tree, "lan")
+	assert.ErrorAs(err, new(uci.ErrSectionNotFound))
+
+	// changes run the tree's hooks
+	errDenied := errors.New("denied")
+	tree.OnSet(func(e *uci.SetEvent) error {
+		if e.Option == "download" {
+			return errDenied
+		}
+		return nil
+	})
+	q.Download = 80 * Mbit
+	assert.ErrorIs(PutQueue(tree, q), errDenied)
+	download, _ = tree.GetLast("sqm", "wan", "download")
+	assert.Equal("90000", download)
+	_, err = Queues(uci.NewTree(t.TempDir()))
+	assert.ErrorIs(err, ErrNoConfig)
+
+	sec := uci.NewSection("queue", "bad")
+	sec.SetOption("download", "100Mbit")
+	_, err = ParseQueue(sec)
+	assert.ErrorIs(err, ErrInvalidNumber)
+}
@@ -0,0 +1,96 @@
+// Package sshremote runs the uci CLI on a remote system via SSH.
+//
+// It provides a uci.Runner for uci.NewRemoteTree:
+//
+//	client, err := ssh.Dial("tcp", "192.168.1.1:22", &ssh.ClientConfig{...})
+//	if err != nil {
+//		...
+//	}
+//	tree := sshremote.NewTree(client)
+//	values, ok := tree.Get("system", "@system[0]", "hostname")
+package sshremote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// Runner executes commands in a new session of an SSH client.
+type Runner struct {
+	Client *ssh.Client
+}
+
+var _ uci.Runner = (*Runner)(nil)
+
+// NewTree constructs a uci.Tree, which manages the configs of the
+// system client is connected to.
+func NewTree(client *ssh.Client) uci.Tree {
+	return uci.NewRemoteTree(&Runner{Client: client})
+}
+
+// Run implements uci.Runner. If the command fails, the returned error
+// includes its standard error output. When ctx is done, the session is
+// closed, which terminates the command.
+func (r *Runner) Run(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sess, err := r.Client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("cannot open SSH session: %w", err)
+	}
+	defer sess.Close()
+
+	var stdout, stderr bytes.Buffer
+	sess.Stdin = stdin
+	sess.Stdout = &stdout
+	sess.Stderr = &stderr
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sess.Close()
+		case <-done:
+		}
+	}()
+
+	cmd := command(name, args...)
+	if err := sess.Run(cmd); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("%s: %w", cmd, ctxErr)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", cmd, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// command builds a shell command line from name and args.
+func command(name string, args ...string) string {
+	words := make([]string, 0, len(args)+1)
+	words = append(words, quote(name))
+	for _, arg := range args {
+		words = append(words, quote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// quote quotes s for a POSIX shell, if necessary.
+func quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,24 @@
+package sshremote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand(t *testing.T) {
+	tt := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"export", "network"}, "uci export network"},
+		{[]string{"-X", "show", "network"}, "uci -X show network"},
+		{[]string{""}, "uci ''"},
+		{[]string{"a b"}, "uci 'a b'"},
+		{[]string{"it's"}, `uci 'it'\''s'`},
+		{[]string{"$(reboot)"}, "uci '$(reboot)'"},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, command("uci", tc.args...))
+	}
+}
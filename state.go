@@ -0,0 +1,121 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// DefaultStateDir is where OpenWrt keeps runtime state variables, e.g.
+// the ones set with "uci -P /var/state set".
+const DefaultStateDir = "/var/state"
+
+// stateBackend implements the backend interface for trees constructed
+// with WithStateDir. Like libuci with "-P", it reads the state files as
+// delta files, and applies them to the configs it loads. Writing strips
+// the state again, so that it never ends up in the config files.
+//
+// It doesn't implement versionedBackend: options set after loading
+// follow the state variables, while they precede them when reloading.
+type stateBackend struct {
+	store *storeBackend
+	dir   string
+}
+
+// state returns the deltas of the named config's state file.
+func (b *stateBackend) state(name string) ([]ast.Delta, error) {
+	body, err := os.ReadFile(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	deltas, err := ast.ParseDeltas(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("state of %s: %w", name, err)
+	}
+	return deltas, nil
+}
+
+func (b *stateBackend) load(ctx context.Context, name string) (*Config, *ast.Provenances, error) {
+	cfg, prov, err := b.store.load(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	deltas, err := b.state(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.ApplyDeltas(deltas); err != nil && b.store.log != nil {
+		// libuci silently skips them, too
+		b.store.log.WarnContext(ctx, "skipped state variables", "config", name, "err", err)
+	}
+	return cfg, prov, nil
+}
+
+// strip returns c without the state: the changes made to c since it was
+// loaded, applied to the config file.
+func (b *stateBackend) strip(ctx context.Context, c *Config) (*Config, error) {
+	orig, _, err := b.store.load(ctx, c.Name)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	deltas, err := b.state(c.Name)
+	if err != nil || len(deltas) == 0 {
+		return c, err
+	}
+	loaded := orig.Clone()
+	_ = loaded.ApplyDeltas(deltas)
+	_ = orig.ApplyDeltas(ast.Deltas(loaded, c))
+	return orig, nil
+}
+
+func (b *stateBackend) save(ctx context.Context, c *Config) (*ast.Provenances, error) {
+	stripped, err := b.strip(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if stripped != c {
+		// the positions of the written file don't belong to c
+		_, err := b.store.save(ctx, stripped)
+		return nil, err
+	}
+	return b.store.save(ctx, c)
+}
+
+func (b *stateBackend) preview(ctx context.Context, c *Config) ([]byte, []byte, error) {
+	stripped, err := b.strip(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.store.preview(ctx, stripped)
+}
+
+func (b *stateBackend) restore(ctx context.Context, name string, body []byte) error {
+	return b.store.restore(ctx, name, body)
+}
+
+func (b *stateBackend) list(ctx context.Context) ([]string, error) {
+	return b.store.list(ctx)
+}
+
+func (b *stateBackend) setStyle(style Style) {
+	b.store.setStyle(style)
+}
+
+func (b *stateBackend) setLimits(limits Limits) {
+	b.store.setLimits(limits)
+}
+
+func (b *stateBackend) setLogger(l *slog.Logger) {
+	b.store.setLogger(l)
+}
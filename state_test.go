@@ -0,0 +1,54 @@
+package uci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	root, state := t.TempDir(), t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(root, "network"), []byte("config interface 'lan'\n\toption proto 'static'\n"), 0o644))
+	require.NoError(os.WriteFile(filepath.Join(state, "network"), []byte(
+		"network.lan.up=1\nnetwork.lan.ifname='br-lan'\nnetwork.lan.proto=dhcp\n"), 0o644))
+
+	r := NewTree(root, WithStateDir(state))
+	up, ok := r.GetLast("network", "lan", "up")
+	assert.True(ok)
+	assert.Equal("1", up)
+	proto, _ := r.GetLast("network", "lan", "proto")
+	assert.Equal("dhcp", proto)
+
+	// state is never written
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.1"))
+	require.NoError(r.Commit())
+	body, err := os.ReadFile(filepath.Join(root, "network"))
+	require.NoError(err)
+	assert.Contains(string(body), "option ipaddr '10.0.0.1'")
+	assert.Contains(string(body), "option proto 'static'")
+	assert.NotContains(string(body), "up")
+	assert.NotContains(string(body), "br-lan")
+	up, _ = r.GetLast("network", "lan", "up")
+	assert.Equal("1", up)
+
+	// committing again
+	assert.True(r.Set("network", "lan", "ipaddr", "10.0.0.2"))
+	require.NoError(r.Commit())
+
+	// configs without state
+	require.NoError(os.WriteFile(filepath.Join(root, "system"), []byte("config system\n"), 0o644))
+	require.NoError(r.AddSection("system", "ntp", "timeserver"))
+	require.NoError(r.Commit())
+	body, err = os.ReadFile(filepath.Join(root, "system"))
+	require.NoError(err)
+	assert.Contains(string(body), "config timeserver 'ntp'")
+
+	require.NoError(os.WriteFile(filepath.Join(state, "network"), []byte("network.lan.up\x00"), 0o644))
+	assert.Error(NewTree(root, WithStateDir(state)).LoadConfig("network", false))
+}
@@ -0,0 +1,31 @@
+package uci
+
+import "sort"
+
+// ConfigStats describes a loaded config.
+type ConfigStats struct {
+	Name     string
+	Sections int
+	Options  int  // number of options in all sections
+	Values   int  // number of values of all options
+	Tainted  bool // changed, but not yet committed
+}
+
+func (t *tree) Stats() []ConfigStats {
+	t.Lock()
+	defer t.Unlock()
+
+	stats := make([]ConfigStats, 0, len(t.configs))
+	for _, cfg := range t.configs {
+		s := ConfigStats{Name: cfg.Name, Sections: len(cfg.Sections), Tainted: cfg.Tainted()}
+		for _, sec := range cfg.Sections {
+			s.Options += len(sec.Options)
+			for _, opt := range sec.Options {
+				s.Values += len(opt.Values)
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
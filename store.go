@@ -0,0 +1,300 @@
+package uci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Store persists the contents of config files ("packages"). Trees
+// parse and serialize the configs, so stores only deal with raw bytes.
+// This allows to keep configs elsewhere than in a local directory, e.g.
+// in a database, a key/value store like etcd, or an object storage.
+//
+// See NewStoreTree.
+type Store interface {
+	// List returns the names of all configs, in alphabetical order.
+	// Trees only load configs named as ValidConfigName requires, so
+	// DirStore skips other files, e.g. backups like "network.bak",
+	// and rejects such names in Read, Write and Delete.
+	List(ctx context.Context) ([]string, error)
+
+	// Read returns the contents of the named config. If the config
+	// does not exist, the error must wrap fs.ErrNotExist.
+	Read(ctx context.Context, name string) ([]byte, error)
+
+	// Write creates or replaces the named config. It should do so
+	// atomically, i.e. readers either see the old or the new contents.
+	Write(ctx context.Context, name string, data []byte) error
+
+	// Delete removes the named config. Deleting a missing config is
+	// not an error.
+	Delete(ctx context.Context, name string) error
+}
+
+// NewStoreTree constructs a tree, which reads and writes configs using
+// the given store.
+func NewStoreTree(s Store) Tree {
+	return &tree{
+		backend: &storeBackend{store: s},
+		configs: make(map[string]*Config),
+	}
+}
+
+// DirStore stores configs as files in a directory. This is the store of
+// trees created by NewTree.
+type DirStore struct {
+	dir string
+}
+
+var _ Store = (*DirStore)(nil)
+
+// NewDirStore returns a store for the given directory (e.g.
+// /etc/config).
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// Path returns the file name of a config.
+func (s *DirStore) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// path works like Path, but rejects names which aren't valid config
+// names, so that they can't refer to files outside of the directory.
+func (s *DirStore) path(name string) (string, error) {
+	if !ValidConfigName(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidConfigName, name)
+	}
+	return s.Path(name), nil
+}
+
+// List implements Store. Dotfiles and directories are ignored, like
+// UCI does, and so are files not named like configs (see
+// ValidConfigName).
+func (s *DirStore) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading config directory failed: %w", err)
+	}
+	var names []string
+	for _, fi := range files {
+		// skip dotfiles, like UCI does (this includes our temp files)
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || !ValidConfigName(fi.Name()) {
+			continue
+		}
+		names = append(names, fi.Name())
+	}
+	return names, nil
+}
+
+// Read implements Store.
+func (s *DirStore) Read(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file failed: %w", err)
+	}
+	return body, nil
+}
+
+// readLimited works like Read, but reads at most n bytes of the file,
+// so oversized configs don't have to be read completely just to be
+// rejected (see Limits.MaxSize).
+func (s *DirStore) readLimited(ctx context.Context, name string, n int64) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file failed: %w", err)
+	}
+	defer f.Close()
+	body, err := io.ReadAll(io.LimitReader(f, n))
+	if err != nil {
+		return nil, fmt.Errorf("reading config file failed: %w", err)
+	}
+	return body, nil
+}
+
+// Write implements Store. The file is replaced atomically.
+func (s *DirStore) Write(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	// We need to create a tempfile in the tree's base directory, since
+	// os.Rename fails when that directory and ioutil.Tempdir are on
+	// different file systems (os.Rename being not much more than a shim
+	// for syscall.Renameat).
+	//
+	// The full path for f will hence be "$root/.$rnd.$name", which
+	// translates to something like "/etc/config/.42.network" on
+	// OpenWrt devices.
+	//
+	// We rely a bit on the fact that UCI ignores dotfiles in /etc/config,
+	// so this should not interfere with normal operations when we leave
+	// incomplete files behind (for whatever reason).
+	f, err := newTmpFile(s.dir, ".*."+name)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		_ = f.Remove()
+		return err
+	}
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		_ = f.Remove()
+		return fmt.Errorf("save: failed to set permissions: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		_ = f.Remove()
+		return fmt.Errorf("save: failed to sync: %w", err)
+	}
+	f.Close()
+
+	if err = f.Rename(path); err != nil {
+		return fmt.Errorf("save: failed to replace existing config: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *DirStore) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("deleting config file failed: %w", err)
+	}
+	return nil
+}
+
+// tmpFile is used by *DirStore.Write to create/update a config file.
+type tmpFile interface {
+	io.Writer
+	Chmod(os.FileMode) error
+	Close() error
+	Remove() error
+	Rename(string) error
+	Sync() error
+}
+
+// newTmpFile purely exists to be replaced in tests.
+var newTmpFile = func(dir, pattern string) (tmpFile, error) {
+	f, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return &tmpFileImpl{f}, nil
+}
+
+type tmpFileImpl struct{ *os.File }
+
+func (tmp *tmpFileImpl) Chmod(mode os.FileMode) error { return tmp.File.Chmod(mode) }
+func (tmp *tmpFileImpl) Close() error                 { return tmp.File.Close() }
+func (tmp *tmpFileImpl) Remove() error                { return os.Remove(tmp.File.Name()) }
+func (tmp *tmpFileImpl) Rename(newpath string) error  { return os.Rename(tmp.File.Name(), newpath) }
+func (tmp *tmpFileImpl) Sync() error                  { return tmp.File.Sync() }
+
+// MemoryStore keeps configs in memory. It is safe for concurrent use,
+// and mostly useful for tests.
+type MemoryStore struct {
+	files map[string][]byte
+	mu    sync.Mutex
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a store containing the given configs (name →
+// contents).
+func NewMemoryStore(configs map[string]string) *MemoryStore {
+	s := &MemoryStore{files: make(map[string][]byte, len(configs))}
+	for name, body := range configs {
+		s.files[name] = []byte(body)
+	}
+	return s
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Read implements Store.
+func (s *MemoryStore) Read(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), body...), nil
+}
+
+// Write implements Store.
+func (s *MemoryStore) Write(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, name)
+	return nil
+}
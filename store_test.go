@@ -0,0 +1,183 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreTree(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"system": "config system\n\toption hostname 'ap1'\n",
+	})
+	r := NewStoreTree(store)
+
+	hostname, ok := r.GetLast("system", "@system[0]", "hostname")
+	assert.True(ok)
+	assert.Equal("ap1", hostname)
+	_, ok = r.Get("network", "lan", "proto")
+	assert.False(ok)
+	assert.True(errors.Is(r.LoadConfig("network", false), fs.ErrNotExist))
+
+	require.NoError(t, r.AddSection("network", "lan", "interface"))
+	assert.True(r.Set("network", "lan", "proto", "static"))
+	assert.True(r.Set("system", "@system[0]", "hostname", "ap2"))
+	require.NoError(t, r.Commit())
+
+	names, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal([]string{"network", "system"}, names)
+	body, err := store.Read(context.Background(), "network")
+	require.NoError(t, err)
+	assert.Equal("\nconfig interface 'lan'\n\toption proto 'static'\n\n", string(body))
+
+	prov, _ := r.Provenance("network", "lan", "proto")
+	assert.Equal(Provenance{File: "network", Line: 3}, prov)
+
+	hostname, _ = NewStoreTree(store).GetLast("system", "@system[0]", "hostname")
+	assert.Equal("ap2", hostname)
+}
+
+func TestStoreTreeStyle(t *testing.T) {
+	store := NewMemoryStore(nil)
+	r := NewStoreTree(store)
+	r.SetStyle(Style{Indent: "  ", Quote: QuoteMinimal, NoTrailingNewline: true})
+
+	require.NoError(t, r.AddSection("network", "lan", "interface"))
+	assert.True(t, r.Set("network", "lan", "proto", "static"))
+	require.NoError(t, r.Commit())
+
+	body, err := store.Read(context.Background(), "network")
+	require.NoError(t, err)
+	assert.Equal(t, "\nconfig interface lan\n  option proto static\n", string(body))
+
+	prov, _ := r.Provenance("network", "lan", "proto")
+	assert.Equal(t, Provenance{File: "network", Line: 3}, prov)
+}
+
+func TestStoreTreeLimits(t *testing.T) {
+	r := NewStoreTree(NewMemoryStore(map[string]string{
+		"system":  "config system\n\toption hostname 'ap1'\n",
+		"network": "config interface 'lan'\nconfig interface 'wan'\n",
+	}))
+	r.SetLimits(Limits{MaxSections: 1})
+
+	hostname, _ := r.GetLast("system", "@system[0]", "hostname")
+	assert.Equal(t, "ap1", hostname)
+	assert.True(t, errors.Is(r.LoadConfig("network", false), ErrLimitExceeded))
+}
+
+func TestDirStoreLimits(t *testing.T) {
+	dir := t.TempDir()
+	body := "config system\n\toption hostname 'ap1'\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "system"), []byte(body), 0600))
+
+	// oversized configs are read only as far as needed to reject them
+	store := NewDirStore(dir)
+	head, err := store.readLimited(context.Background(), "system", 11)
+	require.NoError(t, err)
+	assert.Equal(t, body[:11], string(head))
+
+	r := NewStoreTree(store)
+	r.SetLimits(Limits{MaxSize: 10})
+	assert.True(t, errors.Is(r.LoadConfig("system", false), ErrLimitExceeded))
+	r.SetLimits(Limits{MaxSize: len(body)})
+	require.NoError(t, r.LoadConfig("system", false))
+}
+
+func TestCommitCoercesTypes(t *testing.T) {
+	store := NewMemoryStore(nil)
+	r := NewStoreTree(store)
+
+	require.NoError(t, r.AddSection("network", "lan", "interface"))
+	assert.True(t, r.SetType("network", "lan", "dns", TypeOption, "1.1.1.1", "9.9.9.9"))
+	require.NoError(t, r.Commit())
+
+	body, err := store.Read(context.Background(), "network")
+	require.NoError(t, err)
+	assert.Equal(t, "\nconfig interface 'lan'\n\tlist dns '1.1.1.1'\n\tlist dns '9.9.9.9'\n\n", string(body))
+}
+
+func TestStoreTreePreserveEncoding(t *testing.T) {
+	store := NewMemoryStore(map[string]string{
+		"system": "\ufeffconfig system\r\n\toption hostname 'ap1'\r\n",
+	})
+	r := NewStoreTree(store)
+	r.SetStyle(Style{PreserveEncoding: true})
+
+	assert.True(t, r.Set("system", "@system[0]", "hostname", "ap2"))
+	require.NoError(t, r.Commit())
+
+	body, err := store.Read(context.Background(), "system")
+	require.NoError(t, err)
+	assert.Equal(t, "\ufeff\r\nconfig system\r\n\toption hostname 'ap2'\r\n\r\n", string(body))
+}
+
+func TestDirStore(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".42.system"), nil, 0644))
+	s := NewDirStore(dir)
+
+	require.NoError(t, s.Write(ctx, "system", []byte("config system\n")))
+	require.NoError(t, s.Write(ctx, "network", []byte("config interface 'lan'\n")))
+	names, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Equal([]string{"network", "system"}, names)
+
+	body, err := s.Read(ctx, "system")
+	require.NoError(t, err)
+	assert.Equal("config system\n", string(body))
+	assert.Equal(filepath.Join(dir, "system"), s.Path("system"))
+
+	require.NoError(t, s.Delete(ctx, "system"))
+	require.NoError(t, s.Delete(ctx, "system"))
+	_, err = s.Read(ctx, "system")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.True(errors.Is(s.Write(cctx, "system", nil), context.Canceled))
+
+	// names can't refer to files outside of the directory
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "network.bak"), nil, 0644))
+	names, err = s.List(ctx)
+	require.NoError(t, err)
+	assert.Equal([]string{"network"}, names)
+	for _, name := range []string{"", "../secret", "a/b", "network.bak"} {
+		_, err = s.Read(ctx, name)
+		assert.ErrorIs(err, ErrInvalidConfigName, name)
+		assert.ErrorIs(s.Write(ctx, name, nil), ErrInvalidConfigName, name)
+		assert.ErrorIs(s.Delete(ctx, name), ErrInvalidConfigName, name)
+	}
+	_, err = NewTree(dir).Lookup("../"+filepath.Base(dir), "lan", "proto")
+	assert.ErrorIs(err, ErrInvalidConfigName)
+}
+
+func TestMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	s := NewMemoryStore(nil)
+	data := []byte("config system\n")
+	require.NoError(t, s.Write(ctx, "system", data))
+	data[0] = 'C' // the store keeps a copy
+
+	body, err := s.Read(ctx, "system")
+	require.NoError(t, err)
+	assert.Equal("config system\n", string(body))
+
+	require.NoError(t, s.Delete(ctx, "system"))
+	_, err = s.Read(ctx, "system")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+}
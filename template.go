@@ -0,0 +1,156 @@
+package uci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// TemplateFuncs returns the functions available to config templates
+// rendered by Tree.ApplyTemplate. Add them before parsing the template:
+//
+//	tmpl := template.Must(template.New("network").Funcs(uci.TemplateFuncs()).Parse(text))
+//
+// The functions are:
+//
+//	quote VALUE     VALUE (of any type) as quoted UCI string
+//	section TYPE N  a section name referring to the N-th unnamed
+//	                section of TYPE in the tree, for use as in
+//	                config rule {{section "rule" 0}}
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"quote":   templateQuote,
+		"section": templateSection,
+	}
+}
+
+// templateQuote quotes v for UCI syntax. Quoted strings can't contain
+// escaped quotation marks or line breaks, so values containing both
+// kinds of quotation marks are rejected.
+func templateQuote(v interface{}) (string, error) {
+	s := fmt.Sprint(v)
+	switch {
+	case strings.ContainsAny(s, "\n\r"):
+		return "", fmt.Errorf("quote: line break in %q", s)
+	case !strings.ContainsRune(s, '\''):
+		return "'" + s + "'", nil
+	case !strings.ContainsAny(s, `"\`):
+		return `"` + s + `"`, nil
+	}
+	return "", fmt.Errorf("quote: can't quote %q", s)
+}
+
+// templateSectionPrefix is prepended to the references returned by the
+// "section" func, as the parser takes "@type[n]" itself to refer to the
+// unnamed sections of the rendered config.
+const templateSectionPrefix = "@"
+
+func templateSection(typ string, n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("section: negative index %d", n)
+	}
+	return "'" + templateSectionPrefix + ast.Num2PlaceholderSection(typ, n) + "'", nil
+}
+
+func (t *tree) ApplyTemplate(config string, tmpl *template.Template, data interface{}) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	rendered, err := ast.Parse(config, buf.String())
+	if err != nil {
+		return fmt.Errorf("template %s: %w", tmpl.Name(), err)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.allowed(config); err != nil {
+		if t.mode == IgnoreUnlisted {
+			return nil
+		}
+		return err
+	}
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if errors.Is(err, ErrConfigNotFound) {
+		cfg = ast.NewConfig(config)
+	} else if err != nil {
+		return err
+	}
+
+	// resolve and validate everything first, so that either all of
+	// the rendered config is merged, or nothing
+	targets := make([]*Section, len(rendered.Sections))
+	var errs []error
+	for i, sec := range rendered.Sections {
+		target, err := templateTarget(cfg, sec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		targets[i] = target
+		name := sec.Name
+		if target == nil && name == "" {
+			name = rendered.SectionName(sec)
+		}
+		for _, opt := range sec.Options {
+			for _, value := range opt.Values {
+				if err := t.checkValue(config, sec.Type, opt.Name, value); err != nil {
+					path := Path{Config: config, Section: name, Option: opt.Name}
+					errs = append(errs, &ValidationError{Path: path, Value: value, Err: err})
+				}
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("template %s: %w", tmpl.Name(), err)
+	}
+
+	snap := t.snapshot(config)
+	for i, sec := range rendered.Sections {
+		target := targets[i]
+		if target == nil {
+			cfg.Add(sec)
+			t.markEdited(config, sec, nil)
+			continue
+		}
+		for _, opt := range sec.Options {
+			target.SaveOrInsert(opt)
+			t.markEdited(config, nil, target.Get(opt.Name))
+		}
+	}
+	t.configs[config] = cfg
+	cfg.SetTainted()
+	t.record(snap)
+	return nil
+}
+
+// templateTarget returns the existing section of cfg a rendered section
+// is merged into, or nil if it is to be added.
+func templateTarget(cfg *Config, sec *Section) (*Section, error) {
+	switch {
+	case sec.Name == "":
+		return nil, nil
+	case strings.HasPrefix(sec.Name, "@"):
+		ref := strings.TrimPrefix(sec.Name, templateSectionPrefix)
+		if ast.IsPlaceholderName(ref, sec.Type) {
+			if target := cfg.Get(ref); target != nil {
+				return target, nil
+			}
+		}
+		return nil, ErrSectionNotFound{Config: cfg.Name, Section: ref}
+	}
+	target := cfg.Get(sec.Name)
+	if target != nil && target.Type != sec.Type {
+		return nil, ErrSectionTypeMismatch{cfg.Name, sec.Name, target.Type, sec.Type}
+	}
+	return target, nil
+}
@@ -0,0 +1,155 @@
+package uci
+
+import (
+	"errors"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/validate"
+)
+
+const tcFirewallTemplate = `
+config defaults {{section "defaults" 0}}
+	option input {{quote .Input}}
+
+{{range .Ports}}
+config rule
+	option name {{printf "Allow-%d" . | quote}}
+	option dest_port {{quote .}}
+{{end}}
+config rule {{section "rule" 0}}
+	option enabled '0'
+
+config zone 'guest'
+	option name 'guest'
+{{- range .Networks}}
+	list network {{quote .}}
+{{- end}}
+`
+
+func TestApplyTemplate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := NewMemoryStore(map[string]string{
+		"firewall": `
+config defaults
+	option input 'ACCEPT'
+	option output 'ACCEPT'
+
+config rule
+	option name 'Allow-SSH'
+	option dest_port '22'
+`,
+	})
+	r := NewStoreTree(store)
+	port, err := validate.Validator("port")
+	require.NoError(err)
+	r.RegisterValidator("dest_port", port)
+
+	tmpl, err := template.New("firewall").Funcs(TemplateFuncs()).Parse(tcFirewallTemplate)
+	require.NoError(err)
+
+	data := map[string]interface{}{
+		"Input":    "REJECT",
+		"Ports":    []int{80, 443},
+		"Networks": []string{"guest", "iot"},
+	}
+	require.NoError(r.ApplyTemplate("firewall", tmpl, data))
+	require.NoError(r.Commit())
+
+	assert.Equal(`
+config defaults
+	option input 'REJECT'
+	option output 'ACCEPT'
+
+config rule
+	option name 'Allow-SSH'
+	option dest_port '22'
+	option enabled '0'
+
+config rule
+	option name 'Allow-80'
+	option dest_port '80'
+
+config rule
+	option name 'Allow-443'
+	option dest_port '443'
+
+config zone 'guest'
+	option name 'guest'
+	list network 'guest'
+	list network 'iot'
+
+`, string(store.files["firewall"]))
+
+	// new configs are created
+	require.NoError(r.ApplyTemplate("guest", template.Must(template.New("guest").Parse(
+		"config zone 'guest'\n\toption input 'REJECT'\n")), nil))
+	value, ok := r.GetLast("guest", "guest", "input")
+	assert.True(ok)
+	assert.Equal("REJECT", value)
+}
+
+func TestApplyTemplateErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const input = "\nconfig zone 'lan'\n\toption name 'lan'\n\nconfig rule\n\toption dest_port '22'\n\n"
+	store := NewMemoryStore(map[string]string{"firewall": input})
+	r := NewStoreTree(store)
+	port, err := validate.Validator("port")
+	require.NoError(err)
+	r.RegisterValidator("dest_port", port)
+
+	apply := func(text string, data interface{}) error {
+		tmpl, err := template.New("t").Funcs(TemplateFuncs()).Parse(text)
+		require.NoError(err)
+		return r.ApplyTemplate("firewall", tmpl, data)
+	}
+
+	err = apply("config rule\n\toption dest_port {{quote .}}\n\nconfig zone 'new'\n", 70000)
+	var verr *ValidationError
+	require.True(errors.As(err, &verr))
+	assert.Equal("70000", verr.Value)
+
+	err = apply("config rule 'lan'\n", nil)
+	assert.True(errors.As(err, &ErrSectionTypeMismatch{}))
+
+	err = apply("config rule {{section \"rule\" 3}}\n\toption dest_port '80'\n", nil)
+	assert.True(errors.As(err, &ErrSectionNotFound{}))
+
+	assert.Error(apply("config rule\n\toption name {{quote .}}\n", `it's "quoted"`))
+	assert.Error(apply("config rule\n\toption name\n", nil))
+
+	// nothing was changed
+	require.NoError(r.Commit())
+	assert.Equal(input, string(store.files["firewall"]))
+	sections, _ := r.GetSections("firewall", "zone")
+	assert.Equal([]string{"lan"}, sections)
+}
+
+func TestTemplateQuote(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		in   interface{}
+		want string
+	}{
+		{"lan", `'lan'`},
+		{42, `'42'`},
+		{"it's", `"it's"`},
+		{`say "hi"`, `'say "hi"'`},
+	} {
+		got, err := templateQuote(tc.in)
+		assert.NoError(err)
+		assert.Equal(tc.want, got)
+	}
+	for _, in := range []string{`it's "quoted"`, `it's \`, "two\nlines"} {
+		_, err := templateQuote(in)
+		assert.Error(err, in)
+	}
+}
@@ -1,477 +1,228 @@
 package uci
 
 import (
-	"bytes"
-	"errors"
-	"fmt"
-	"io"
-	"regexp"
-	"strconv"
-	"strings"
+	"net"
+	"time"
+
+	"github.com/wsiner/go-uci/ast"
 )
 
-// NOTE: config, section and option types basically are AST nodes for the
-// parser. The JSON struct tags are mainly for development and testing
-// purposes: We'er generating JSON dumps of the tree when running tests
-// with DUMP="json". After a manual comparison with the corresponding UCI
-// file in testdata/, we can use the dumps to read them back as test case
-// expectations.
+// The AST types, as well as the parser and serializer, live in the
+// standalone ast package. They are re-exported here, so that users of
+// the Tree don't need to import both packages.
+type (
+	Config               = ast.Config
+	Section              = ast.Section
+	Option               = ast.Option
+	OptionType           = ast.OptionType
+	ParseError           = ast.ParseError
+	ErrUnknownOptionType = ast.ErrUnknownOptionType
+	ErrSectionNotFound   = ast.ErrSectionNotFound
+	ErrSectionExists     = ast.ErrSectionExists
+	Path                 = ast.Path
+	Ref                  = ast.Ref
+	RefMap               = ast.RefMap
+	NamingPolicy         = ast.NamingPolicy
+	WriteOption          = ast.WriteOption
+	Provenance           = ast.Provenance
+	ValueError           = ast.ValueError
+	DuplicatePolicy      = ast.DuplicatePolicy
+	Style                = ast.Style
+	QuoteMode            = ast.QuoteMode
+	WalkFunc             = ast.WalkFunc
+	Patch                = ast.Patch
+	PatchOperation       = ast.PatchOperation
+	PatchValue           = ast.PatchValue
+	PatchError           = ast.PatchError
+	Limits               = ast.Limits
+	Parser               = ast.Parser
+	Builder              = ast.Builder
+	Rate                 = ast.Rate
+	SectionMeta          = ast.SectionMeta
+)
 
-// Config represents a file in UCI. It consists of sections.
-type Config struct {
-	Name     string     `json:"name"`
-	Sections []*Section `json:"sections,omitempty"`
+const (
+	TypeOption = ast.TypeOption // option is not a list
+	TypeList   = ast.TypeList   // option is a list
 
-	tainted bool // changed by tree methods when things were modified
-}
+	RedactedValue = ast.RedactedValue
 
-// newConfig returns a new config object.
-func newConfig(name string) *Config {
-	return &Config{
-		Name:     name,
-		Sections: make([]*Section, 0, 1),
-	}
-}
+	DedupeValues    = ast.DedupeValues    // skip values already present
+	AllowDuplicates = ast.AllowDuplicates // add values regardless
 
-func (c *Config) WriteTo(w io.Writer) (n int64, err error) {
-	var buf bytes.Buffer
-
-	for _, sec := range c.Sections {
-		if sec.Name == "" || IsPlaceholderName(sec.Name, sec.Type) {
-			_, _ = fmt.Fprintf(&buf, "\nconfig %s\n", sec.Type)
-		} else {
-			_, _ = fmt.Fprintf(&buf, "\nconfig %s '%s'\n", sec.Type, sec.Name)
-		}
-
-		for _, opt := range sec.Options {
-			switch opt.Type {
-			case TypeOption:
-				_, _ = fmt.Fprintf(&buf, "\toption %s '%s'\n", opt.Name, opt.Values[0])
-			case TypeList:
-				for _, v := range opt.Values {
-					_, _ = fmt.Fprintf(&buf, "\tlist %s '%s'\n", opt.Name, v)
-				}
-			}
-		}
-	}
-	buf.WriteByte('\n')
-	return buf.WriteTo(w)
-}
+	QuoteSingle  = ast.QuoteSingle  // 'value'
+	QuoteDouble  = ast.QuoteDouble  // "value"
+	QuoteMinimal = ast.QuoteMinimal // quotes only where needed
 
-// Get fetches a section by name.
-//
-// Support for unnamed Section notation (@foo[idx]) is present.
-func (c *Config) Get(name string) *Section {
-	if strings.HasPrefix(name, "@") {
-		sec, _ := c.getUnnamed(name) // TODO: log error?
-		return sec
-	}
-	return c.getNamed(name)
-}
+	InfiniteLease = ast.InfiniteLease // see ParseLeaseTime
+)
 
-func (c *Config) getNamed(name string) *Section {
-	for _, sec := range c.Sections {
-		if sec.Name == name {
-			return sec
-		}
-	}
-	return nil
-}
+// SecretOptions lists the names of options holding credentials. See
+// ast.SecretOptions.
+var SecretOptions = ast.SecretOptions
 
 var (
-	ErrImplausibleSectionSelector = errors.New("implausible section selector: must be at least 5 characters long")
-	ErrMustStartWithAt            = errors.New("invalid syntax: section selector must start with @ sign")
-	ErrMultipleAtSigns            = errors.New("invalid syntax: multiple @ signs found")
-	ErrMultipleOpenBrackets       = errors.New("invalid syntax: multiple open brackets found")
-	ErrMultipleCloseBrackets      = errors.New("invalid syntax: multiple closed brackets found")
-	ErrInvalidSectionSelector     = errors.New("invalid syntax: section selector must have format '@type[index]'")
+	ErrImplausibleSectionSelector = ast.ErrImplausibleSectionSelector
+	ErrMustStartWithAt            = ast.ErrMustStartWithAt
+	ErrMultipleAtSigns            = ast.ErrMultipleAtSigns
+	ErrMultipleOpenBrackets       = ast.ErrMultipleOpenBrackets
+	ErrMultipleCloseBrackets      = ast.ErrMultipleCloseBrackets
+	ErrInvalidSectionSelector     = ast.ErrInvalidSectionSelector
+	ErrUnnamedIndexOutOfBounds    = ast.ErrUnnamedIndexOutOfBounds
+	ErrInvalidName                = ast.ErrInvalidName
+	ErrInvalidType                = ast.ErrInvalidType
+	ErrInvalidIdentifier          = ast.ErrInvalidIdentifier
+	ErrInvalidConfigName          = ast.ErrInvalidConfigName
+	ErrNoSection                  = ast.ErrNoSection
+	ErrDuplicateSection           = ast.ErrDuplicateSection
+	ErrValueIndexOutOfBounds      = ast.ErrValueIndexOutOfBounds
+	ErrValueNotFound              = ast.ErrValueNotFound
+	ErrOptionNotFound             = ast.ErrOptionNotFound
+	ErrTypeMismatch               = ast.ErrTypeMismatch
+	ErrMultipleValues             = ast.ErrMultipleValues
+	ErrUnsupportedType            = ast.ErrUnsupportedType
+	ErrInvalidQuery               = ast.ErrInvalidQuery
+	ErrLimitExceeded              = ast.ErrLimitExceeded
+	ErrInvalidUnit                = ast.ErrInvalidUnit
+	ErrInvalidMAC                 = ast.ErrInvalidMAC
+
+	SkipSection = ast.SkipSection // see Config.Walk
+	SkipAll     = ast.SkipAll     // see Config.Walk
 )
 
-func unmangleSectionName(name string) (typ string, index int, err error) { //nolint:cyclop
-	l := len(name)
-	if l < 5 { // "@a[0]"
-		err = ErrImplausibleSectionSelector
-		return
-	}
-	if name[0] != '@' {
-		err = ErrMustStartWithAt
-		return
-	}
-
-	bra, ket := 0, l-1 // bracket positions
-	for i, r := range name {
-		switch {
-		case i != 0 && r == '@':
-			err = ErrMultipleAtSigns
-			return
-		case r == '[' && bra > 0:
-			err = ErrMultipleOpenBrackets
-			return
-		case r == ']' && i != ket:
-			err = ErrMultipleCloseBrackets
-			return
-		case r == '[':
-			bra = i
-		}
-	}
-
-	if bra == 0 || bra >= ket {
-		err = ErrInvalidSectionSelector
-		return
-	}
-
-	typ = name[1:bra]
-	index, err = strconv.Atoi(name[bra+1 : ket])
-	if err != nil {
-		err = fmt.Errorf("invalid syntax: index must be numeric: %w", err)
-	}
-	return typ, index, err
+// Anonymous is the naming policy, which keeps all sections anonymous.
+// See ast.Anonymous.
+func Anonymous(c *Config, s *Section) string {
+	return ast.Anonymous(c, s)
 }
 
-var ErrUnnamedIndexOutOfBounds = errors.New("invalid name: index out of bounds")
-
-func (c *Config) getUnnamed(name string) (*Section, error) {
-	typ, idx, err := unmangleSectionName(name)
-	if err != nil {
-		return nil, err
-	}
-
-	count := c.count(typ)
-	if -count > idx || idx >= count {
-		return nil, ErrUnnamedIndexOutOfBounds
-	}
-	if idx < 0 {
-		idx += count // count from the end
-	}
-
-	for i, n := 0, 0; i < len(c.Sections); i++ {
-		if c.Sections[i].Type == typ {
-			if idx == n {
-				return c.Sections[i], nil
-			}
-			n++
-		}
-	}
-	return nil, nil
+// NameFromOption names sections after the value of an option. See
+// ast.NameFromOption.
+func NameFromOption(option string) NamingPolicy {
+	return ast.NameFromOption(option)
 }
 
-func (c *Config) Add(s *Section) *Section {
-	c.Sections = append(c.Sections, s)
-	return s
+// WithPrefix prefixes the names chosen by p. See ast.WithPrefix.
+func WithPrefix(prefix string, p NamingPolicy) NamingPolicy {
+	return ast.WithPrefix(prefix, p)
 }
 
-func (c *Config) Insert(index int, s *Section) *Section {
-	if index > len(c.Sections)-1 {
-		return c.Add(s)
-	}
-
-	if index <= 0 {
-		// insert at the beginning of the slice
-		sections := make([]*Section, len(c.Sections)+1)
-		sections = append(sections, s)
-		sections = append(sections, c.Sections...)
-		c.Sections = sections
-
-		return s
-	}
-
-	var sections []*Section
-	sections = append(sections, c.Sections[:index]...)
-	sections = append(sections, s)
-	sections = append(sections, c.Sections[index:]...)
-	c.Sections = sections
-	return s
+// Redact masks the values of secret options in Config.Write. See
+// ast.Redact.
+func Redact(patterns ...string) WriteOption {
+	return ast.Redact(patterns...)
 }
 
-func (c *Config) Merge(s *Section) *Section {
-	var sec *Section
-	for i := range c.Sections {
-		sname := c.sectionName(s)
-		cname := c.sectionName(c.Sections[i])
-
-		if sname == cname {
-			sec = c.Sections[i]
-			break
-		}
-	}
-
-	if sec == nil {
-		return c.Add(s)
-	}
-	for _, o := range s.Options {
-		sec.Merge(o)
-	}
-	return sec
+// WithStyle formats the output of Config.Write. See ast.WithStyle.
+func WithStyle(s Style) WriteOption {
+	return ast.WithStyle(s)
 }
 
-func (c *Config) MergeReplace(original, section *Section) {
-	for index := range original.Options {
-		name := original.Options[index].Name
-		r := section.Get(name)
-		if r != nil {
-			original.Options[index] = r
-		}
-	}
-
-	for index := range section.Options {
-		name := section.Options[index].Name
-		r := original.Get(name)
-		if r == nil {
-			original.Add(section.Options[index])
-		}
-	}
+// ApplyPatch applies a JSON patch to cfg. See ast.ApplyPatch.
+func ApplyPatch(cfg *Config, patch Patch) error {
+	return ast.ApplyPatch(cfg, patch)
 }
 
-func (c *Config) Del(name string) {
-	var i int
-	indexs := make(map[string]int, 5)
-	for i = 0; i < len(c.Sections); i++ {
-		if IsPlaceholderName(name, c.Sections[i].Type) {
-			_, index, _ := unmangleSectionName(name)
-
-			if _, ok := indexs[c.Sections[i].Type]; !ok {
-				indexs[c.Sections[i].Type] = 0
-			}
-
-			if index == indexs[c.Sections[i].Type] {
-				break
-			}
-
-			indexs[c.Sections[i].Type]++
-		}
-
-		if c.Sections[i].Name == name {
-			break
-		}
-	}
-	if i < len(c.Sections) {
-		c.Sections = append(c.Sections[:i], c.Sections[i+1:]...)
-	}
+// GeneratePatch returns a JSON patch turning from into to. See
+// ast.GeneratePatch.
+func GeneratePatch(from, to *Config) Patch {
+	return ast.GeneratePatch(from, to)
 }
 
-func (c *Config) SetTainted() {
-	c.tainted = true
+// AnonymousID returns the ID libuci assigns to the unnamed section s of
+// c, e.g. "cfg03e48a". See ast.AnonymousID.
+func AnonymousID(c *Config, s *Section) string {
+	return ast.AnonymousID(c, s)
 }
 
-func (c *Config) sectionName(s *Section) string {
-	if s.Name != "" {
-		return s.Name
-	}
-	return fmt.Sprintf("@%s[%d]", s.Type, c.index(s))
+// ParseLeaseTime parses lease times like "12h". See ast.ParseLeaseTime.
+func ParseLeaseTime(v string) (time.Duration, error) {
+	return ast.ParseLeaseTime(v)
 }
 
-func (c *Config) index(s *Section) (i int) {
-	for _, sec := range c.Sections {
-		if sec == s {
-			return i
-		}
-		if sec.Type == s.Type {
-			i++
-		}
-	}
-	panic("not reached")
+// FormatLeaseTime formats d for ParseLeaseTime. See ast.FormatLeaseTime.
+func FormatLeaseTime(d time.Duration) string {
+	return ast.FormatLeaseTime(d)
 }
 
-func (c *Config) count(typ string) (n int) {
-	for _, sec := range c.Sections {
-		if sec.Type == typ {
-			n++
-		}
-	}
-	return
+// ParseRate parses rates like "10/minute". See ast.ParseRate.
+func ParseRate(v string) (Rate, error) {
+	return ast.ParseRate(v)
 }
 
-// A Section represents a group of options in UCI. It may be named or
-// unnamed. In the latter case, its synthetic name is constructed from
-// the Section type and index (e.g. "@system[0]").
-type Section struct {
-	Name    string    `json:"name,omitempty"`
-	Type    string    `json:"type"`
-	Options []*Option `json:"options,omitempty"`
+// ParseSize parses sizes like "64k". See ast.ParseSize.
+func ParseSize(v string) (int64, error) {
+	return ast.ParseSize(v)
 }
 
-// NewSection returns a new Section object.
-func NewSection(typ, name string) *Section {
-	return &Section{
-		Type:    typ,
-		Name:    name,
-		Options: make([]*Option, 0, 1),
-	}
+// FormatSize formats n for ParseSize. See ast.FormatSize.
+func FormatSize(n int64) string {
+	return ast.FormatSize(n)
 }
 
-func (s *Section) Add(o *Option) *Option {
-	s.Options = append(s.Options, o)
-	return o
+// ParseMAC parses a MAC address in any common notation. See
+// ast.ParseMAC.
+func ParseMAC(v string) (net.HardwareAddr, error) {
+	return ast.ParseMAC(v)
 }
 
-func (s *Section) Insert(index int, o *Option) *Option {
-	if index > len(s.Options)-1 {
-		return s.Add(o)
-	}
-
-	if index <= 0 {
-		// insert at the beginning of the slice
-		options := make([]*Option, len(s.Options)+1)
-		options = append(options, o)
-		options = append(options, s.Options...)
-		s.Options = options
-
-		return o
-	}
-
-	var options []*Option
-	options = append(options, s.Options[:index]...)
-	options = append(options, o)
-	options = append(options, s.Options[index:]...)
-	s.Options = options
-	return o
+// NormalizeMAC returns the canonical notation of a MAC address. See
+// ast.NormalizeMAC.
+func NormalizeMAC(v string) (string, error) {
+	return ast.NormalizeMAC(v)
 }
 
-func (s *Section) Merge(o *Option) {
-	for _, opt := range s.Options {
-		if opt.Name == o.Name {
-			opt.MergeValues(o.Values...)
-			return
-		}
-	}
-	s.Options = append(s.Options, o)
+// NewConfig returns a new, empty Config object.
+func NewConfig(name string) *Config {
+	return ast.NewConfig(name)
 }
 
-// Del removes an Option with the given name. It returns whether the
-// Option actually existed.
-func (s *Section) Del(name string) bool {
-	var i int
-	for i = 0; i < len(s.Options); i++ {
-		if s.Options[i].Name == name {
-			break
-		}
-	}
-
-	if i == len(s.Options) {
-		return false
-	}
-
-	s.Options = append(s.Options[:i], s.Options[i+1:]...)
-
-	return true
-}
-
-// Get fetches an Option by name.
-func (s *Section) Get(name string) *Option {
-	for _, opt := range s.Options {
-		if opt.Name == name {
-			return opt
-		}
-	}
-	return nil
-}
-
-func (s *Section) SaveOrInsert(option *Option) {
-	original := s.Get(option.Name)
-
-	if original == nil {
-		s.Add(option)
-	} else {
-		*original = *option
-	}
-}
-
-func (s *Section) Value(option string) []string {
-	for _, opt := range s.Options {
-		if opt.Name == option {
-			return opt.Values
-		}
-	}
-	return []string{}
-}
-
-func (s *Section) ValueDefault(option string, values ...string) []string {
-	for _, opt := range s.Options {
-		if opt.Name == option {
-			return opt.Values
-		}
-	}
-	return values
-}
-
-func (s *Section) LastValue(option string) string {
-	for _, opt := range s.Options {
-		if opt.Name == option {
-			return opt.Values[len(opt.Values)-1]
-		}
-	}
-	return ""
-}
-
-func (s *Section) LastValueDefault(option string, value string) string {
-	for _, opt := range s.Options {
-		if opt.Name == option {
-			return opt.Values[len(opt.Values)-1]
-		}
-	}
-	return value
-}
-
-// An Option is the key to one or more values. Multiple values indicate
-// a list option.
-type Option struct {
-	Name   string     `json:"name"`
-	Values []string   `json:"values"`
-	Type   OptionType `json:"type"`
+// NewSection returns a new Section object.
+func NewSection(typ, name string) *Section {
+	return ast.NewSection(typ, name)
 }
 
 // NewOption returns a new option object.
 func NewOption(name string, optionType OptionType, values ...string) *Option {
-	return &Option{
-		Name:   name,
-		Values: values,
-		Type:   optionType,
-	}
-}
-
-func (o *Option) SetValues(vs ...string) {
-	o.Values = vs
-}
-
-func (o *Option) AddValue(v string) {
-	o.Values = append(o.Values, v)
+	return ast.NewOption(name, optionType, values...)
 }
 
-func (o *Option) MergeValues(vs ...string) {
-	have := make(map[string]struct{})
-	for _, v := range o.Values {
-		have[v] = struct{}{}
-	}
-
-	for _, v := range vs {
-		if _, exists := have[v]; exists {
-			continue
-		}
-		o.AddValue(v)
-	}
+// NewBuilder returns a builder for a config. See ast.Builder.
+func NewBuilder(name string) *Builder {
+	return ast.NewBuilder(name)
 }
 
-var placeholderSectionPattern, _ = regexp.Compile(`^@(.*?)\[(\d+)\]$`)
-
 func Num2PlaceholderSection(sectionType string, num int) string {
-	return strings.Join([]string{"@", sectionType, "[", strconv.Itoa(num), "]"}, "")
+	return ast.Num2PlaceholderSection(sectionType, num)
 }
 
 func PlaceholderSection2Num(section string) (int, error) {
-	submatchs := placeholderSectionPattern.FindStringSubmatch(section)
+	return ast.PlaceholderSection2Num(section)
+}
 
-	if len(submatchs) < 3 {
-		return 0, errors.New("匿名section 格式错误")
-	}
+func IsPlaceholderName(name, secType string) bool {
+	return ast.IsPlaceholderName(name, secType)
+}
 
-	atoi, _ := strconv.Atoi(submatchs[2])
+// ValidIdentifier reports whether s is a valid section or option name.
+// See ast.ValidIdentifier.
+func ValidIdentifier(s string) bool {
+	return ast.ValidIdentifier(s)
+}
 
-	return atoi, nil
+// ValidConfigName reports whether s is a valid config name. See
+// ast.ValidConfigName.
+func ValidConfigName(s string) bool {
+	return ast.ValidConfigName(s)
 }
 
-func IsPlaceholderName(name, secType string) bool {
-	expr := strings.Join([]string{"^@", secType, `\[(\d+)\]$`}, "")
-	placeholderNameRegexp := regexp.MustCompile(expr)
+// Minimize returns the parts of cfg which differ from defaults, and the
+// paths of what cfg lacks. See ast.Minimize.
+func Minimize(cfg, defaults *Config) (*Config, []Path) {
+	return ast.Minimize(cfg, defaults)
+}
 
-	return placeholderNameRegexp.MatchString(name)
+// Expand reverses Minimize. See ast.Expand.
+func Expand(minimal, defaults *Config, deleted ...Path) *Config {
+	return ast.Expand(minimal, defaults, deleted...)
 }
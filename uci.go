@@ -1,14 +1,20 @@
 package uci
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
+
+	"github.com/wsiner/go-uci/ast"
 )
 
 // Tree defines the base directory for UCI config files. The default value
@@ -26,13 +32,41 @@ type Tree interface {
 	// load missing files automatically.
 	LoadConfig(name string, forceReload bool) error
 
-	// Commit writes all changes back to the system.
+	// LoadConfigContext works like LoadConfig, but aborts when ctx is
+	// done. This is mostly useful for remote trees.
+	LoadConfigContext(ctx context.Context, name string, forceReload bool) error
+
+	// Commit writes all changes back to the system. Options with
+	// multiple values are written as lists (see Config.CoerceTypes).
 	//
 	// Note: this is not transaction safe. If, for whatever reason, the
 	// writing of any file fails, the succeeding files are left untouched
 	// while the preceding files are not reverted.
+	//
+	// Trees constructed with WithReadOnly return ErrReadOnly.
 	Commit() error
 
+	// CommitContext works like Commit, but stops writing configs when
+	// ctx is done. Configs written before are not reverted.
+	CommitContext(ctx context.Context) error
+
+	// CommitConfigs works like CommitContext, but only writes the given
+	// configs. Changes to other configs stay pending.
+	CommitConfigs(ctx context.Context, configs ...string) error
+
+	// SafeCommit works like CommitContext, but rolls the commit back,
+	// unless PendingCommit.Confirm is called within timeout. This
+	// protects remote devices from being locked out by changes of their
+	// network settings, like LuCI's apply does. If writing a config
+	// fails, the configs written before are restored right away. The
+	// fragments of configs (see SetIncludes) are restored with them.
+	SafeCommit(ctx context.Context, timeout time.Duration) (*PendingCommit, error)
+
+	// CommitDryRun returns what Commit would write, ordered by config
+	// name, without writing anything. Commit hooks are not run, and the
+	// configs stay modified.
+	CommitDryRun(ctx context.Context) ([]CommitPreview, error)
+
 	// Revert undoes changes to the config files given as arguments. If
 	// no argument is given, all changes are reverted. This clears the
 	// internal memory and does not access the file system.
@@ -71,6 +105,22 @@ type Tree interface {
 	// config section and the option exists.
 	GetSlice(config, section, option, separator string) ([]string, bool)
 
+	// GetLeaseDuration works like GetLast, but parses the value as lease
+	// time (see ParseLeaseTime), e.g. of a dhcp pool's leasetime option.
+	// The second result is false, if the option does not exist, or
+	// can't be parsed.
+	GetLeaseDuration(config, section, option string) (time.Duration, bool)
+
+	// GetRate works like GetLeaseDuration, but parses the value as rate
+	// (see ParseRate), e.g. of a firewall rule's limit option.
+	GetRate(config, section, option string) (Rate, bool)
+
+	// Lookup works like Get, but reports why the values can't be found:
+	// the returned error matches ErrConfigNotFound (or the error loading
+	// the config), ErrSectionNotFound{} or ErrOptionNotFound, see
+	// errors.Is.
+	Lookup(config, section, option string) ([]string, error)
+
 	// Set replaces the fully qualified option with the given values. It
 	// returns whether the config file and section exists. For new files
 	// and sections, you first need to initialize them with AddSection().
@@ -85,7 +135,8 @@ type Tree interface {
 	// SetType replaces the fully qualified option with the given values.
 	// It returns whether the config file and section exists. For new
 	// files and sections, you first need to initialize them with
-	// AddSection().
+	// AddSection(). New options must have a valid name (see
+	// ValidIdentifier).
 	SetType(config, section, option string, typ OptionType, values ...string) bool
 
 	// Del removes a fully qualified option.
@@ -93,33 +144,332 @@ type Tree interface {
 
 	// AddSection adds a new config section. If the section already exists,
 	// and the types match (existing type and given type), nothing happens.
-	// Otherwise an ErrSectionTypeMismatch is returned.
+	// Otherwise an ErrSectionTypeMismatch is returned. New sections must
+	// have a valid name (see ValidIdentifier, and SanitizeName to derive
+	// one from user input), or ErrInvalidIdentifier is returned.
 	AddSection(config, section, typ string) error
 
+	// AddUnnamedSection appends an unnamed section of the given type,
+	// creating the config if necessary, and returns the section's
+	// "@type[index]" selector.
+	AddUnnamedSection(config, typ string) (string, error)
+
 	// DelSection remove a config section and its options.
 	DelSection(config, section string)
 
+	// RenameSection changes the name of a section, and updates the
+	// references to it listed in refs (refs[config]), loading the
+	// referring configs as needed. It returns the paths of all changed
+	// options. Missing referring configs are skipped, but if any config
+	// can't be loaded otherwise, or the rename is invalid, nothing is
+	// changed.
+	RenameSection(config, section, name string, refs RefMap) ([]Path, error)
+
+	// Backup writes all configs (including uncommitted changes) as
+	// gzipped tar archive to w. The archive has the same layout as the
+	// ones created by "sysupgrade -b" (i.e. "etc/config/<name>"), and
+	// can be restored on the device with "sysupgrade -r".
+	Backup(w io.Writer, opts ...BackupOption) error
+
+	// Restore reads a backup archive, as written by Backup or
+	// "sysupgrade -b", and replaces the configs found in the archive.
+	// Other files and configs are left untouched. Like other changes,
+	// the restored configs need to be committed. The archive's
+	// manifest is returned, or nil, if it has none.
+	Restore(r io.Reader) (*BackupManifest, error)
+
+	// LoadChanges applies the uncommitted changes of a config, which the
+	// uci tool stores in savedir (usually DefaultSaveDir), to the
+	// config in memory. This allows picking up changes made with "uci
+	// set" etc. on the same device. An empty savedir selects the one
+	// set with WithSaveDir, $UCI_SAVEDIR or DefaultSaveDir.
+	LoadChanges(config, savedir string) error
+
+	// SaveChanges writes the changes of a config into savedir in the
+	// format of the uci tool, instead of committing them. They then
+	// show up in "uci changes", and can be applied by "uci commit" or
+	// discarded by "uci revert". An empty savedir is treated like by
+	// LoadChanges.
+	SaveChanges(config, savedir string) error
+
+	// OnSet registers a hook called by Set and SetType before changing
+	// an option. The hook may rewrite the values, or reject the change
+	// by returning an error (Set then returns false).
+	OnSet(h SetHook)
+
+	// OnDelete registers a hook called by Del and DelSection before
+	// removing an existing option or section. Returning an error keeps
+	// it.
+	OnDelete(h DeleteHook)
+
+	// OnCommit registers a hook called by Commit before writing the
+	// changed configs. Returning an error aborts the commit, which then
+	// returns an ErrVetoed.
+	OnCommit(h CommitHook)
+
+	// OnPreCommit registers a hook called by Commit before writing each
+	// changed config (after the OnCommit hooks). Returning an error
+	// aborts the commit, which then returns an ErrVetoed; configs
+	// written before are kept.
+	OnPreCommit(h PackageHook)
+
+	// OnPostCommit registers a hook called by Commit after writing each
+	// changed config, e.g. to reload services or check connectivity
+	// (see ExecHook). Returning an error restores the previous contents
+	// of the config and its fragments (see SetIncludes), drops the
+	// tree's copy (as Revert does), and aborts the commit, which then
+	// returns an ErrRolledBack.
+	OnPostCommit(h PackageHook)
+
+	// OnLoad registers a hook called after a config was read from the
+	// backend (or failed to), e.g. to collect metrics.
+	OnLoad(h LoadHook)
+
+	// OnCommitDone registers a hook called at the end of Commit (and
+	// SafeCommit) with its outcome, e.g. to collect metrics.
+	OnCommitDone(h CommitDoneHook)
+
+	// Stats describes the loaded configs, ordered by name.
+	Stats() []ConfigStats
+
+	// AddEphemeral adds cfg as ephemeral config, which exists in memory
+	// only: it can be read, changed and queried like other configs, but
+	// is never written by Commit (nor included in backups or delta
+	// files), and LoadConfig doesn't replace it. A loaded config of the
+	// same name is replaced. This is useful for computing would-be
+	// states, test fixtures, and runtime state (like the status of
+	// wireless interfaces). Revert removes ephemeral configs.
+	AddEphemeral(cfg *Config) error
+
+	// IsEphemeral reports whether the config was added by AddEphemeral.
+	IsEphemeral(config string) bool
+
+	// Batch calls fn to make many changes at once, holding the tree's
+	// lock. If fn returns an error, or any change fails, all changes are
+	// rolled back, and the error is returned. Otherwise, the changes are
+	// recorded as a single one in the history, and committed if fn calls
+	// tx.Commit. fn must not call the tree's methods, as they would
+	// block.
+	Batch(fn func(tx *Tx) error) error
+
+	// Snapshot returns an immutable copy of the loaded configs, which
+	// concurrent readers can use without locking the tree.
+	Snapshot() *Snapshot
+
+	// Query returns the paths of all sections or options matching the
+	// expression, e.g. "firewall.@rule[*].dest_port=22", loading the
+	// configs as needed. See ast.Query for the syntax.
+	Query(expr string) ([]Path, error)
+
+	// RegisterValidator adds a validator for the values of options with
+	// the given name (or of all options, if name is empty). Set and
+	// SetType return false without changing anything, if a validator
+	// rejects any of the new values.
+	RegisterValidator(option string, v Validator)
+
+	// Validate checks all values of the given configs (or of all loaded
+	// configs, if none are given) with the registered validators. The
+	// returned error joins a *ValidationError per invalid value.
+	Validate(configs ...string) error
+
+	// RegisterMigration adds a migration applied by Migrate.
+	RegisterMigration(m Migration)
+
+	// Migrate applies the registered migrations to the given configs
+	// (or to all configs named by a migration, skipping missing ones),
+	// and returns the migrated options. Deprecated options are renamed
+	// in place; if the new option is set already, the deprecated one
+	// is removed. The changes are only made in memory, until the tree
+	// is committed.
+	Migrate(configs ...string) ([]AppliedMigration, error)
+
+	// SetHistory enables (or, with nil, disables) recording changes
+	// in h, to be undone with Undo. Changes made through the methods of
+	// the tree are recorded, changes made to configs returned by
+	// EnsureConfigLoaded are not. Revert clears the history.
+	SetHistory(h *History)
+
+	// Undo reverts the last n recorded changes, and returns the number
+	// of changes reverted, which is less, if the history is shorter.
+	// Undone changes are neither checked by validators, nor passed to
+	// hooks; the configs concerned are marked as changed.
+	Undo(n int) int
+
+	// Redo makes the last n undone changes again, and returns their
+	// number. Recording a new change discards the undone ones.
+	Redo(n int) int
+
+	// SetLogger sets the logger for parse warnings, malformed section
+	// selectors (which Get, Set and Del treat like missing sections),
+	// rejected changes and commits. A nil logger disables logging,
+	// which is the default.
+	SetLogger(l *slog.Logger)
+
+	// SetLimits bounds the size of the configs loaded from now on (see
+	// Limits), to protect daemons managing untrusted config files from
+	// memory exhaustion. Loading configs exceeding a limit fails with
+	// ErrLimitExceeded. Trees of a DirStore read at most MaxSize+1 bytes
+	// of a config file. By default, there are no limits.
+	SetLimits(limits Limits)
+
+	// SetForce makes Commit overwrite configs which have been changed
+	// by others since they were loaded (see ErrConcurrentModification).
+	SetForce(force bool)
+
+	// SetIncludes makes the tree inline fragments into the configs it
+	// loads from now on (see Config.Inline). For each section, include
+	// returns the name of the config holding the included fragment, or
+	// "" if the section includes nothing. Missing fragments are empty.
+	// Commit writes changed configs without the inlined sections, and
+	// their fragments with them. Rollbacks (see OnPostCommit and
+	// SafeCommit) restore the fragments, but concurrent modifications
+	// (see SetForce) are only detected in the configs themselves, not in
+	// their fragments. A nil func disables includes.
+	SetIncludes(include func(sec *Section) string)
+
+	// SetStyle changes the formatting of the config files written by
+	// Commit. Remote trees ignore the style, as they write configs with
+	// "uci batch". Use Style.PreserveEncoding to keep the line endings
+	// of files edited on Windows.
+	SetStyle(style Style)
+
+	// ApplyTemplate executes tmpl with data, parses the output as config
+	// in UCI syntax, and merges it into the config (creating it, if it
+	// doesn't exist). Named sections are merged into existing sections
+	// of the same name, replacing their options; sections named by the
+	// "section" template func (see TemplateFuncs) are merged into the
+	// referenced unnamed section; other unnamed sections are appended.
+	//
+	// The values are checked with the registered validators; if any is
+	// rejected, or a section can't be merged, nothing is changed. Set
+	// hooks are not run.
+	ApplyTemplate(config string, tmpl *template.Template, data interface{}) error
+
+	// Apply makes the config named like desired match it, creating the
+	// config if necessary, and returns the changes made (see ast.Diff
+	// for how sections are matched). Only differing options are set, so
+	// applying the same config again returns no changes. Sections not
+	// part of desired are kept, unless opts.Prune is set.
+	//
+	// Like ApplyTemplate, the values are checked with the registered
+	// validators first, and set hooks are not run.
+	Apply(desired *Config, opts ApplyOptions) ([]Change, error)
+
+	// Provenance returns where a section (if option is empty) or an
+	// option came from: the file and line it was read from, the layer
+	// of a layered tree, and whether it was modified by the tree's
+	// methods since. Sections and options created in memory, or by
+	// modifying the config returned by EnsureConfigLoaded, are
+	// reported as edited, without file. The boolean is false if the
+	// section or option does not exist.
+	//
+	// For remote trees, the file is the config name, and line numbers
+	// refer to the output of "uci export".
+	Provenance(config, section, option string) (Provenance, bool)
+
+	// CopyConfig returns a copy of a config, loading it if necessary.
+	// Unlike the config returned by EnsureConfigLoaded, the copy is
+	// safe to read while the tree is changed concurrently. The boolean
+	// is false if the config doesn't exist.
+	CopyConfig(config string) (*Config, bool)
+
 	EnsureConfigLoaded(config string) (*Config, bool)
 }
 
 type tree struct {
-	dir     string
+	backend backend
 	configs map[string]*Config
+	prov    map[string]*ast.Provenances // per config, may be missing
+	loaded  map[string]string           // hashes of loaded configs, "" for missing ones
+	force   bool                        // see SetForce
+	include func(sec *Section) string   // see SetIncludes
+
+	allow map[string]bool // nil allows all configs
+	mode  AllowlistMode
+
+	hooks      hooks
+	validators map[string][]Validator // by option name, "" for all
+	migrations []Migration
+	ephemeral  map[string]bool // configs never written, see AddEphemeral
+	log        *slog.Logger    // nil discards
+	history    *History        // nil disables undo
+	savedir    string          // default savedir, see WithSaveDir
+	readOnly   bool            // see WithReadOnly
 
 	sync.Mutex
 }
 
 var _ Tree = (*tree)(nil)
 
-// NewTree constructs new RootDir pointing to root.
-func NewTree(root string) Tree {
+// NewTree constructs new RootDir pointing to root. If root is empty,
+// the directory named by UCI_CONFIG_DIR is used, falling back to
+// DefaultTreePath.
+func NewTree(root string, opts ...TreeOption) Tree {
+	o := treeOptions{configDir: root}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.configDir == "" {
+		o.configDir = configDir()
+	}
+	store := &storeBackend{store: NewDirStore(o.configDir), strict: o.strict}
+	var b backend = store
+	if o.stateDir != "" {
+		b = &stateBackend{store: store, dir: o.stateDir}
+	}
+	return &tree{
+		backend:  b,
+		configs:  make(map[string]*Config),
+		savedir:  o.saveDir,
+		readOnly: o.readOnly,
+	}
+}
+
+// An AllowlistMode determines how a restricted tree treats configs,
+// which are not on its allowlist.
+type AllowlistMode int
+
+const (
+	// IgnoreUnlisted treats configs not on the allowlist as if they
+	// did not exist: they can't be loaded, and AddSection silently
+	// does nothing.
+	IgnoreUnlisted AllowlistMode = iota
+
+	// RejectUnlisted returns an ErrConfigNotAllowed for any attempt to
+	// load or create configs not on the allowlist.
+	RejectUnlisted
+)
+
+// NewRestrictedTree constructs a tree pointing to root, which only
+// reads and writes the given configs. Other files in root never affect
+// the tree, and can't be written to.
+func NewRestrictedTree(root string, mode AllowlistMode, configs ...string) Tree {
+	allow := make(map[string]bool, len(configs))
+	for _, name := range configs {
+		allow[name] = true
+	}
 	return &tree{
-		dir:     root,
+		backend: &storeBackend{store: NewDirStore(root)},
 		configs: make(map[string]*Config),
+		allow:   allow,
+		mode:    mode,
+	}
+}
+
+// allowed returns an ErrConfigNotAllowed, if name is not on the
+// tree's allowlist.
+func (t *tree) allowed(name string) error {
+	if t.allow != nil && !t.allow[name] {
+		return &ErrConfigNotAllowed{name}
 	}
+	return nil
 }
 
 func (t *tree) LoadConfig(name string, forceReload bool) error {
+	return t.LoadConfigContext(context.Background(), name, forceReload)
+}
+
+func (t *tree) LoadConfigContext(ctx context.Context, name string, forceReload bool) error {
 	t.Lock()
 	defer t.Unlock()
 
@@ -130,17 +480,32 @@ func (t *tree) LoadConfig(name string, forceReload bool) error {
 	if exists && !forceReload {
 		return &ErrConfigAlreadyLoaded{name}
 	}
-	return t.loadConfig(name)
+	return t.loadConfig(ctx, name)
 }
 
 // loadConfig actually reads a config file. Its call must be guarded by
 // locking the tree's mutex.
-func (t *tree) loadConfig(name string) error {
-	body, err := ioutil.ReadFile(filepath.Join(t.dir, name))
-	if err != nil {
-		return fmt.Errorf("reading config file failed: %w", err)
+func (t *tree) loadConfig(ctx context.Context, name string) error {
+	if err := t.allowed(name); err != nil {
+		return err
+	}
+	if t.ephemeral[name] {
+		return nil
+	}
+	if !ValidConfigName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidConfigName, name)
+	}
+	start := time.Now()
+	cfg, prov, err := t.backend.load(ctx, name)
+	if errors.Is(err, os.ErrNotExist) {
+		err = fmt.Errorf("%w: %s: %w", ErrConfigNotFound, name, err)
+	}
+	e := LoadEvent{Config: name, Duration: time.Since(start), Err: err}
+	t.logLoad(ctx, e)
+	t.runLoadHooks(e)
+	if errors.Is(err, ErrConfigNotFound) {
+		t.setLoaded(name, nil)
 	}
-	cfg, err := parse(name, string(body))
 	if err != nil {
 		return err
 	}
@@ -148,33 +513,188 @@ func (t *tree) loadConfig(name string) error {
 	if t.configs == nil {
 		t.configs = make(map[string]*Config)
 	}
+	if t.include != nil {
+		if err := cfg.Inline(t.resolver(ctx)); err != nil {
+			return err
+		}
+	}
 	t.configs[name] = cfg
+	t.setProvenances(name, prov)
+	t.setLoaded(name, cfg)
+	return nil
+}
+
+// resolver returns a Resolver loading the fragments named by the
+// tree's include func. Its call must be guarded by locking the tree's
+// mutex.
+func (t *tree) resolver(ctx context.Context) ast.Resolver {
+	return func(sec *Section) (*Config, error) {
+		name := t.include(sec)
+		if name == "" {
+			return nil, nil
+		}
+		if err := t.allowed(name); err != nil {
+			return nil, err
+		}
+		frag, _, err := t.backend.load(ctx, name)
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrConfigNotFound) {
+			return NewConfig(name), nil
+		}
+		return frag, err
+	}
+}
+
+// setLoaded remembers the hash of a config as loaded or committed (or
+// that it doesn't exist, if cfg is nil), to detect concurrent changes.
+// Its call must be guarded by locking the tree's mutex.
+func (t *tree) setLoaded(name string, cfg *Config) {
+	if t.loaded == nil {
+		t.loaded = make(map[string]string)
+	}
+	if cfg == nil {
+		t.loaded[name] = ""
+	} else {
+		t.loaded[name] = cfg.Hash()
+	}
+}
+
+// checkConcurrent returns ErrConcurrentModification, if the stored
+// config differs from the one loaded, unless the tree is forced. Its
+// call must be guarded by locking the tree's mutex.
+func (t *tree) checkConcurrent(ctx context.Context, name string) error {
+	want, known := t.loaded[name]
+	b, ok := t.backend.(versionedBackend)
+	if t.force || !known || !ok {
+		return nil
+	}
+	got, err := b.version(ctx, name)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		t.logger().WarnContext(ctx, "config changed since it was loaded", "config", name)
+		return fmt.Errorf("%s: %w", name, ErrConcurrentModification)
+	}
 	return nil
 }
 
+// setProvenances replaces the provenance table of a config. A nil prov
+// removes it. Its call must be guarded by locking the tree's mutex.
+func (t *tree) setProvenances(name string, prov *ast.Provenances) {
+	if prov == nil {
+		delete(t.prov, name)
+		return
+	}
+	if t.prov == nil {
+		t.prov = make(map[string]*ast.Provenances)
+	}
+	t.prov[name] = prov
+}
+
+func (t *tree) SetLimits(limits Limits) {
+	t.Lock()
+	defer t.Unlock()
+
+	if b, ok := t.backend.(limitedBackend); ok {
+		b.setLimits(limits)
+	}
+}
+
+func (t *tree) SetForce(force bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.force = force
+}
+
+func (t *tree) SetIncludes(include func(sec *Section) string) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.include = include
+}
+
+func (t *tree) SetStyle(style Style) {
+	t.Lock()
+	defer t.Unlock()
+
+	if b, ok := t.backend.(styledBackend); ok {
+		b.setStyle(style)
+	}
+}
+
 func (t *tree) Commit() error {
+	return t.CommitContext(context.Background())
+}
+
+func (t *tree) CommitContext(ctx context.Context) error {
 	t.Lock()
 	defer t.Unlock()
 
-	for _, config := range t.configs {
-		if !config.tainted {
-			continue
-		}
-		err := t.saveConfig(config)
+	return t.commit(ctx)
+}
+
+func (t *tree) CommitConfigs(ctx context.Context, configs ...string) error {
+	if len(configs) == 0 {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	return t.commit(ctx, configs...)
+}
+
+// commit implements CommitContext and CommitConfigs, writing all
+// tainted configs, if none are given. Its call must be guarded by
+// locking the tree's mutex.
+func (t *tree) commit(ctx context.Context, configs ...string) error {
+	start := time.Now()
+	tainted := t.tainted()
+	if len(configs) > 0 {
+		tainted = slices.DeleteFunc(tainted, func(cfg *Config) bool {
+			return !slices.Contains(configs, cfg.Name)
+		})
+	}
+	err := t.runCommitHooks(ctx, tainted)
+	for _, config := range tainted {
 		if err != nil {
-			return err
+			break
 		}
+		err = t.save(ctx, config)
 	}
-	return nil
+	t.runCommitDoneHooks(tainted, start, err)
+	return err
+}
+
+// tainted returns the configs to be written by Commit, with their
+// types coerced. Its call must be guarded by locking the tree's mutex.
+func (t *tree) tainted() []*Config {
+	var tainted []*Config
+	for name, config := range t.configs {
+		if config.Tainted() && !t.ephemeral[name] {
+			config.CoerceTypes() // don't lose values of mistyped options
+			tainted = append(tainted, config)
+		}
+	}
+	return tainted
 }
 
 func (t *tree) Revert(configs ...string) {
 	t.Lock()
+	if t.history != nil {
+		t.history.undo, t.history.redo = nil, nil
+	}
 	if len(configs) == 0 {
 		t.configs = nil
+		t.prov = nil
+		t.loaded = nil
+		t.ephemeral = nil
 	}
 	for _, config := range configs {
 		delete(t.configs, config)
+		delete(t.prov, config)
+		delete(t.loaded, config)
+		delete(t.ephemeral, config)
 	}
 	t.Unlock()
 }
@@ -188,7 +708,7 @@ func (t *tree) GetSections(config string, secType string) ([]string, bool) {
 	names := []string{}
 	for _, s := range cfg.Sections {
 		if s.Type == secType {
-			names = append(names, cfg.sectionName(s))
+			names = append(names, cfg.SectionName(s))
 		}
 	}
 
@@ -203,7 +723,7 @@ func (t *tree) Get(config, section, option string) ([]string, bool) {
 		return vals, true
 	}
 
-	if err := t.loadConfig(config); err != nil {
+	if err := t.loadConfig(context.Background(), config); err != nil {
 		return nil, false
 	}
 	return t.lookupValues(config, section, option)
@@ -267,15 +787,70 @@ func (t *tree) GetSlice(config, section, option, separator string) ([]string, bo
 	return strings.Split(value, separator), true
 }
 
+func (t *tree) GetLeaseDuration(config, section, option string) (time.Duration, bool) {
+	value, ok := t.GetLast(config, section, option)
+	if !ok {
+		return 0, false
+	}
+	d, err := ParseLeaseTime(value)
+	return d, err == nil
+}
+
+func (t *tree) GetRate(config, section, option string) (Rate, bool) {
+	value, ok := t.GetLast(config, section, option)
+	if !ok {
+		return Rate{}, false
+	}
+	r, err := ParseRate(value)
+	return r, err == nil
+}
+
 func (t *tree) EnsureConfigLoaded(config string) (*Config, bool) {
-	cfg, loaded := t.configs[config]
-	if !loaded {
-		if err := t.loadConfig(config); err != nil {
-			return nil, false
-		}
-		cfg = t.configs[config]
+	cfg, err := t.ensureConfig(context.Background(), config)
+	return cfg, err == nil
+}
+
+func (t *tree) CopyConfig(config string) (*Config, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil {
+		return nil, false
 	}
-	return cfg, true
+	return cfg.Clone(), true
+}
+
+// ensureConfig works like EnsureConfigLoaded, but returns the reason why
+// a config can't be loaded. Its call must be guarded by locking the
+// tree's mutex.
+func (t *tree) ensureConfig(ctx context.Context, config string) (*Config, error) {
+	if cfg, loaded := t.configs[config]; loaded {
+		return cfg, nil
+	}
+	if err := t.loadConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return t.configs[config], nil
+}
+
+func (t *tree) Lookup(config, section, option string) ([]string, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	sec, err := cfg.Lookup(section)
+	if err != nil {
+		return nil, err
+	}
+	opt := sec.Get(option)
+	if opt == nil {
+		return nil, fmt.Errorf("%s: %w", Path{Config: config, Section: section, Option: option}, ErrOptionNotFound)
+	}
+	return opt.Values, nil
 }
 
 func (t *tree) lookupOption(config, section, option string) (*Option, bool) {
@@ -283,7 +858,7 @@ func (t *tree) lookupOption(config, section, option string) (*Option, bool) {
 	if !exists {
 		return nil, false
 	}
-	sec := cfg.Get(section)
+	sec := t.section(cfg, section)
 	if sec == nil {
 		return nil, false
 	}
@@ -302,25 +877,55 @@ func (t *tree) lookupValues(config, section, option string) ([]string, bool) {
 }
 
 func (t *tree) SetType(config, section, option string, typ OptionType, values ...string) bool {
+	if t.readOnly {
+		return false
+	}
 	t.Lock()
 	defer t.Unlock()
 
-	cfg, ok := t.EnsureConfigLoaded(config)
-	if !ok {
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil {
 		return false
 	}
-	sec := cfg.Get(section)
+	sec := t.section(cfg, section)
 	if sec == nil {
 		return false
 	}
+	return t.setOption(cfg, sec, section, option, typ, values) == nil
+}
 
-	if opt := sec.Get(option); opt != nil {
-		opt.SetValues(values...)
+// setOption implements SetType for a resolved section. Its call must be
+// guarded by locking the tree's mutex.
+func (t *tree) setOption(cfg *Config, sec *Section, section, option string, typ OptionType, values []string) error {
+	config := cfg.Name
+	opt := sec.Get(option)
+	if opt == nil && !ValidIdentifier(option) {
+		t.logger().Info("invalid option name", "config", config, "section", section, "option", option)
+		return fmt.Errorf("%w: %q", ErrInvalidIdentifier, option)
+	}
+	e := SetEvent{Config: config, Section: section, Option: option, Type: typ, Values: values}
+	if opt != nil {
+		e.Old = append([]string{}, opt.Values...)
+	}
+	if err := t.runSetHooks(&e); err != nil {
+		return err
+	}
+	for _, v := range e.Values {
+		if err := t.checkValue(config, sec.Type, option, v); err != nil {
+			t.logger().Info("value rejected", "config", config, "section", section, "option", option, "err", err)
+			return &ValidationError{Path: Path{Config: config, Section: section, Option: option}, Value: v, Err: err}
+		}
+	}
+	snap := t.snapshot(config)
+	if opt != nil {
+		opt.SetValues(e.Values...)
 	} else {
-		sec.Add(NewOption(option, typ, values...))
+		opt = sec.Add(NewOption(option, e.Type, e.Values...))
 	}
-	cfg.tainted = true
-	return true
+	t.markEdited(config, sec, opt)
+	cfg.SetTainted()
+	t.record(snap)
+	return nil
 }
 
 func (t *tree) Set(config, section, option string, values ...string) bool {
@@ -331,41 +936,80 @@ func (t *tree) Set(config, section, option string, values ...string) bool {
 }
 
 func (t *tree) Del(config, section, option string) {
+	if t.readOnly {
+		return
+	}
 	t.Lock()
 	defer t.Unlock()
 
-	cfg, ok := t.EnsureConfigLoaded(config)
-	if !ok {
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil {
 		// we want to delete option, but neither config, nor section,
 		// nor config do exist. hence, we've reached our desired state
 		return
 	}
 
-	sec := cfg.Get(section)
+	sec := t.section(cfg, section)
 	if sec == nil {
 		// same logic applies here
 		return
 	}
+	_ = t.delOption(cfg, sec, section, option)
+}
 
-	if sec.Del(option) {
-		cfg.tainted = true
+// delOption implements Del for a resolved section. Its call must be
+// guarded by locking the tree's mutex.
+func (t *tree) delOption(cfg *Config, sec *Section, section, option string) error {
+	if sec.Get(option) == nil {
+		return nil
+	}
+	if err := t.runDeleteHooks(DeleteEvent{cfg.Name, section, option}); err != nil {
+		return err
 	}
+	snap := t.snapshot(cfg.Name)
+	sec.Del(option)
+	cfg.SetTainted()
+	t.record(snap)
+	return nil
 }
 
 func (t *tree) AddSection(config, section, typ string) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
 	t.Lock()
 	defer t.Unlock()
 
-	cfg, ok := t.EnsureConfigLoaded(config)
-	if !ok {
-		cfg = newConfig(config)
-		cfg.tainted = true
+	return t.addSection(config, section, typ)
+}
+
+// addSection implements AddSection. Its call must be guarded by locking
+// the tree's mutex.
+func (t *tree) addSection(config, section, typ string) error {
+	if err := t.allowed(config); err != nil {
+		if t.mode == IgnoreUnlisted {
+			return nil
+		}
+		return err
+	}
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil && !errors.Is(err, ErrConfigNotFound) {
+		return err
+	}
+	if (cfg == nil || cfg.Get(section) == nil) && !ValidIdentifier(section) && !IsPlaceholderName(section, typ) {
+		return fmt.Errorf("%w: %q", ErrInvalidIdentifier, section)
+	}
+	snap := t.snapshot(config)
+	if err != nil {
+		cfg = ast.NewConfig(config)
+		cfg.SetTainted()
 		t.configs[config] = cfg
 	}
 	sec := cfg.Get(section)
 	if sec == nil {
 		cfg.Add(NewSection(typ, section))
-		cfg.tainted = true
+		cfg.SetTainted()
+		t.record(snap)
 		return nil
 	}
 	if sec.Type != typ {
@@ -374,86 +1018,195 @@ func (t *tree) AddSection(config, section, typ string) error {
 	return nil
 }
 
-func (t *tree) DelSection(config, section string) {
+func (t *tree) AddUnnamedSection(config, typ string) (string, error) {
+	if t.readOnly {
+		return "", ErrReadOnly
+	}
 	t.Lock()
 	defer t.Unlock()
 
-	cfg, ok := t.EnsureConfigLoaded(config)
-	if !ok {
+	return t.addUnnamedSection(config, typ)
+}
+
+// addUnnamedSection implements AddUnnamedSection. Its call must be
+// guarded by locking the tree's mutex.
+func (t *tree) addUnnamedSection(config, typ string) (string, error) {
+	if err := t.allowed(config); err != nil {
+		if t.mode == IgnoreUnlisted {
+			return "", nil
+		}
+		return "", err
+	}
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil && !errors.Is(err, ErrConfigNotFound) {
+		return "", err
+	}
+	snap := t.snapshot(config)
+	if err != nil {
+		cfg = ast.NewConfig(config)
+		t.configs[config] = cfg
+	}
+	_, sel := cfg.AddUnnamed(typ)
+	t.record(snap)
+	return sel, nil
+}
+
+func (t *tree) DelSection(config, section string) {
+	if t.readOnly {
 		return
 	}
-	cfg.Del(section)
-	cfg.tainted = true
+	t.Lock()
+	defer t.Unlock()
+
+	_ = t.delSection(config, section)
 }
 
-func (t *tree) saveConfig(c *Config) error {
-	// We need to create a tempfile in the tree's base directory, since
-	// os.Rename fails when that directory and ioutil.Tempdir are on
-	// different file systems (os.Rename being not much more than a shim
-	// for syscall.Renameat).
-	//
-	// The full path for f will hence be "$root/.$rnd.$name", which
-	// translates to something like "/etc/config/.42.network" on
-	// OpenWrt devices.
-	//
-	// We rely a bit on the fact that UCI ignores dotfiles in /etc/config,
-	// so this should not interfere with normal operations when we leave
-	// incomplete files behind (for whatever reason).
-	f, err := newTmpFile(t.dir, ".*."+c.Name)
+// delSection implements DelSection. Its call must be guarded by locking
+// the tree's mutex.
+func (t *tree) delSection(config, section string) error {
+	cfg, err := t.ensureConfig(context.Background(), config)
 	if err != nil {
-		return err
+		return nil //nolint:nilerr // nothing to delete
+	}
+	exists := t.section(cfg, section) != nil
+	if exists {
+		if err := t.runDeleteHooks(DeleteEvent{config, section, ""}); err != nil {
+			return err
+		}
+	}
+	var snap historyEntry
+	if exists {
+		snap = t.snapshot(config)
 	}
+	cfg.Del(section)
+	cfg.SetTainted()
+	t.record(snap)
+	return nil
+}
 
-	_, err = c.WriteTo(f)
-	if err != nil {
-		f.Close()
-		_ = f.Remove()
-		return err
+func (t *tree) RenameSection(config, section, name string, refs RefMap) ([]Path, error) {
+	if t.readOnly {
+		return nil, ErrReadOnly
 	}
+	t.Lock()
+	defer t.Unlock()
+
+	return t.renameSection(config, section, name, refs)
+}
 
-	if err = f.Chmod(0644); err != nil {
-		f.Close()
-		_ = f.Remove()
-		return fmt.Errorf("save: failed to set permissions: %w", err)
+// renameSection implements RenameSection. Its call must be guarded by
+// locking the tree's mutex.
+func (t *tree) renameSection(config, section, name string, refs RefMap) ([]Path, error) {
+	if err := t.allowed(config); err != nil {
+		return nil, err
 	}
-	if err = f.Sync(); err != nil {
-		f.Close()
-		_ = f.Remove()
-		return fmt.Errorf("save: failed to sync: %w", err)
+	cfg, err := t.ensureConfig(context.Background(), config)
+	if err != nil {
+		return nil, ErrSectionNotFound{Config: config, Section: section}
+	}
+	sec := cfg.Get(section)
+	if sec == nil {
+		return nil, ErrSectionNotFound{Config: config, Section: section}
 	}
-	f.Close()
 
-	if err = f.Rename(filepath.Join(t.dir, c.Name)); err != nil {
-		return fmt.Errorf("save: failed to replace existing config: %w", err)
+	// load all referring configs first, so that we either update all
+	// references or none
+	others := make(map[string]*Config)
+	for _, ref := range refs[config] {
+		if ref.Config == config || others[ref.Config] != nil {
+			continue
+		}
+		other, err := t.ensureConfig(context.Background(), ref.Config)
+		var notAllowed *ErrConfigNotAllowed
+		switch {
+		case errors.Is(err, ErrConfigNotFound):
+			continue // no references to update
+		case errors.As(err, &notAllowed) && t.mode == IgnoreUnlisted:
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("rename %s.%s: %w", config, section, err)
+		}
+		others[ref.Config] = other
 	}
 
-	c.tainted = false
-	return nil
-}
+	names := []string{config}
+	for other := range others {
+		names = append(names, other)
+	}
+	snap := t.snapshot(names...)
+	old := sec.Name
+	changed, err := cfg.Rename(section, name, refs[config])
+	if err != nil {
+		return changed, err
+	}
+	defer t.record(snap)
+	if old != "" && old != name {
+		for _, ref := range refs[config] {
+			if other := others[ref.Config]; other != nil {
+				changed = append(changed, other.UpdateRefs([]Ref{ref}, sec.Type, old, name)...)
+			}
+		}
+	}
 
-// tmpFile is used by *tree.saveConfig to create/update a config file.
-type tmpFile interface {
-	io.Writer
-	Chmod(os.FileMode) error
-	Close() error
-	Remove() error
-	Rename(string) error
-	Sync() error
+	t.markEdited(config, sec, nil)
+	for _, p := range changed {
+		if s := t.configs[p.Config].Get(p.Section); s != nil {
+			t.markEdited(p.Config, nil, s.Get(p.Option))
+		}
+	}
+	return changed, nil
 }
 
-// newTmpFile purely exists to be replaced in tests.
-var newTmpFile = func(dir, pattern string) (tmpFile, error) {
-	f, err := ioutil.TempFile(dir, pattern)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+// markEdited flags the provenance of a section and/or option as edited.
+// Both may be nil. Its call must be guarded by locking the tree's
+// mutex.
+func (t *tree) markEdited(config string, sec *Section, opt *Option) {
+	prov := t.prov[config]
+	if prov == nil {
+		return // everything is edited
+	}
+	if sec != nil {
+		p, _ := prov.Section(sec)
+		p.Edited = true
+		prov.SetSection(sec, p)
+	}
+	if opt != nil {
+		p, _ := prov.Option(opt)
+		p.Edited = true
+		prov.SetOption(opt, p)
 	}
-	return &tmpFileImpl{f}, nil
 }
 
-type tmpFileImpl struct{ *os.File }
+func (t *tree) Provenance(config, section, option string) (Provenance, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	cfg, ok := t.EnsureConfigLoaded(config)
+	if !ok {
+		return Provenance{}, false
+	}
+	sec := cfg.Get(section)
+	if sec == nil {
+		return Provenance{}, false
+	}
+	prov := t.prov[config]
+	if option == "" {
+		if prov != nil {
+			if p, ok := prov.Section(sec); ok {
+				return p, true
+			}
+		}
+		return Provenance{Edited: true}, true
+	}
 
-func (tmp *tmpFileImpl) Chmod(mode os.FileMode) error { return tmp.File.Chmod(mode) }
-func (tmp *tmpFileImpl) Close() error                 { return tmp.File.Close() }
-func (tmp *tmpFileImpl) Remove() error                { return os.Remove(tmp.File.Name()) }
-func (tmp *tmpFileImpl) Rename(newpath string) error  { return os.Rename(tmp.File.Name(), newpath) }
-func (tmp *tmpFileImpl) Sync() error                  { return tmp.File.Sync() }
+	opt := sec.Get(option)
+	if opt == nil {
+		return Provenance{}, false
+	}
+	if prov != nil {
+		if p, ok := prov.Option(opt); ok {
+			return p, true
+		}
+	}
+	return Provenance{Edited: true}, true
+}
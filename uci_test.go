@@ -2,15 +2,18 @@ package uci
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func loadExpected(t *testing.T, name string) *Config {
@@ -170,6 +173,39 @@ func TestAddSection(t *testing.T) {
 	values, exists = r.Get("nonexistent", "a", "section")
 	assert.True(exists)
 	assert.ElementsMatch(values, []string{"value"})
+
+	for _, name := range []string{"guest-wifi", "küche", "a.b"} {
+		assert.True(errors.Is(r.AddSection("system", name, "foo"), ErrInvalidIdentifier), name)
+	}
+	assert.True(errors.Is(r.AddSection("new", "a b", "foo"), ErrInvalidIdentifier))
+	assert.NotContains(r.(*tree).configs, "new")
+	assert.NoError(r.AddSection("system", "@foo[1]", "foo")) // unnamed
+}
+
+func TestAddUnnamedSection(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	r := NewTree("testdata")
+
+	sel, err := r.AddUnnamedSection("system", "timeserver")
+	require.NoError(err)
+	assert.Equal("@timeserver[1]", sel)
+	assert.True(r.Set("system", sel, "enabled", "1"))
+	sel, err = r.AddUnnamedSection("nonexistent", "foo")
+	require.NoError(err)
+	assert.Equal("@foo[0]", sel)
+
+	// copies aren't affected by later changes
+	cfg, ok := r.CopyConfig("system")
+	require.True(ok)
+	assert.Equal("1", cfg.Get("@timeserver[1]").LastValue("enabled"))
+	r.DelSection("system", "@timeserver[1]")
+	assert.NotNil(cfg.Get("@timeserver[1]"))
+	_, ok = r.CopyConfig("missing")
+	assert.False(ok)
+
+	_, err = NewTree("testdata", WithReadOnly()).AddUnnamedSection("system", "foo")
+	assert.ErrorIs(err, ErrReadOnly)
 }
 
 func TestDelSection(t *testing.T) {
@@ -216,6 +252,38 @@ func TestGet(t *testing.T) {
 	assert.Nil(values)
 }
 
+func TestLookup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewTree("testdata")
+
+	values, err := r.Lookup("system", "@system[0]", "timezone")
+	require.NoError(err)
+	assert.Equal([]string{"UTC"}, values)
+
+	_, err = r.Lookup("nonexistent", "foo", "bar")
+	assert.True(errors.Is(err, ErrConfigNotFound))
+	assert.True(errors.Is(err, os.ErrNotExist))
+
+	_, err = r.Lookup("system", "nonexistent", "foo")
+	assert.True(errors.Is(err, ErrSectionNotFound{}))
+	assert.EqualError(err, "section system.nonexistent not found")
+
+	_, err = r.Lookup("system", "ntp", "foo")
+	assert.True(errors.Is(err, ErrOptionNotFound))
+	assert.EqualError(err, "system.ntp.foo: option not found")
+
+	_, err = r.Lookup("invalid", "foo", "bar")
+	assert.True(IsParseError(err))
+
+	err = r.AddSection("invalid", "foo", "bar")
+	assert.True(IsParseError(err), "AddSection must not replace unparsable configs")
+
+	err = r.AddSection("system", "ntp", "interface")
+	assert.True(errors.Is(err, ErrTypeMismatch))
+}
+
 func TestGetInt(t *testing.T) {
 	assert := assert.New(t)
 
@@ -230,6 +298,25 @@ func TestGetInt(t *testing.T) {
 	assert.True(value == 512)
 }
 
+func TestGetUnits(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{
+		"firewall": "\nconfig rule 'ssh'\n\toption limit '10/min'\n\toption name 'SSH'\n",
+	}))
+	lease, ok := NewTree("testdata").GetLeaseDuration("dhcp", "lan", "leasetime")
+	assert.True(ok)
+	assert.Equal(12*time.Hour, lease)
+
+	limit, ok := r.GetRate("firewall", "ssh", "limit")
+	assert.True(ok)
+	assert.Equal(Rate{Count: 10, Per: time.Minute}, limit)
+	_, ok = r.GetRate("firewall", "ssh", "name")
+	assert.False(ok)
+	_, ok = r.GetLeaseDuration("firewall", "ssh", "missing")
+	assert.False(ok)
+}
+
 func TestDel(t *testing.T) {
 	assert := assert.New(t)
 	r := NewTree("testdata")
@@ -272,6 +359,8 @@ func TestSet(t *testing.T) {
 	assert.ElementsMatch(values, []string{"testhost"})
 
 	assert.True(r.Set("system", "@system[0]", "hosttest"))
+	assert.False(r.Set("system", "ntp", "my-option", "1"))
+	assert.False(r.Set("system", "ntp", "", "1"))
 
 	assert.False(r.Set("system", "nonexistent", "foo", "bar"))
 	values, exists = r.Get("system", "nonexistent", "foo")
@@ -393,7 +482,7 @@ func TestRevert(t *testing.T) {
 
 	// taint tree
 	assert.True(r.Set("system", "ntp", "foo", "42"))
-	assert.True(tree.configs["system"].tainted)
+	assert.True(tree.configs["system"].Tainted())
 	r.Revert("system")
 	assert.Len(tree.configs, 0)
 }
@@ -483,3 +572,93 @@ func (m *mockTempFile) Sync() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+func TestRenameSection(t *testing.T) {
+	assert := assert.New(t)
+	r := NewTree("testdata")
+
+	refs := RefMap{"network": {
+		{Config: "network", SectionType: "route", Option: "interface", TargetType: "interface"},
+		{Config: "firewall", SectionType: "zone", Option: "network", TargetType: "interface"},
+		{Config: "wireless", SectionType: "wifi-iface", Option: "network", TargetType: "interface"},
+	}}
+
+	changed, err := r.RenameSection("network", "guest", "visitors", refs)
+	assert.NoError(err)
+	assert.Equal([]Path{
+		{Config: "network", Section: "@route[0]", Option: "interface"},
+		{Config: "firewall", Section: "@zone[2]", Option: "network"},
+		{Config: "wireless", Section: "guest_radio0", Option: "network"},
+		{Config: "wireless", Section: "guest_radio1", Option: "network"},
+	}, changed)
+
+	values, _ := r.Get("firewall", "@zone[2]", "network")
+	assert.Equal([]string{"visitors"}, values)
+	_, ok := r.Get("network", "visitors", "proto")
+	assert.True(ok)
+
+	_, err = r.RenameSection("network", "nonexistent", "foo", refs)
+	assert.True(errors.Is(err, ErrSectionNotFound{Config: "network", Section: "nonexistent"}))
+
+	// referring configs which can't be loaded abort the rename, missing
+	// ones are skipped
+	r = NewStoreTree(NewMemoryStore(map[string]string{
+		"network":  "config interface 'guest'\n\toption proto 'static'\n",
+		"firewall": "config zone\n\toption network 'guest\n",
+	}))
+	_, err = r.RenameSection("network", "guest", "visitors", refs)
+	assert.Error(err)
+	_, ok = r.Get("network", "guest", "proto")
+	assert.True(ok)
+	refs["network"] = append(refs["network"][:1], refs["network"][2])
+	_, err = r.RenameSection("network", "guest", "visitors", refs)
+	assert.NoError(err)
+}
+
+func TestRestrictedTree(t *testing.T) {
+	for _, mode := range []AllowlistMode{IgnoreUnlisted, RejectUnlisted} {
+		assert := assert.New(t)
+		r := NewRestrictedTree("testdata", mode, "system", "newconfig")
+
+		_, ok := r.Get("system", "ntp", "enabled")
+		assert.True(ok)
+		_, ok = r.Get("network", "lan", "proto")
+		assert.False(ok)
+		_, ok = r.EnsureConfigLoaded("network")
+		assert.False(ok)
+
+		var notAllowed *ErrConfigNotAllowed
+		err := r.LoadConfig("network", false)
+		assert.True(errors.As(err, &notAllowed))
+		assert.EqualError(err, "config network not allowed")
+
+		err = r.AddSection("network", "lan", "interface")
+		if mode == RejectUnlisted {
+			assert.True(errors.As(err, &notAllowed))
+		} else {
+			assert.NoError(err)
+		}
+		assert.False(r.Set("network", "lan", "proto", "static"))
+		assert.Len(r.(*tree).configs, 1)
+
+		assert.NoError(r.AddSection("newconfig", "sec", "type"))
+		assert.True(r.Set("newconfig", "sec", "opt", "1"))
+	}
+}
+
+func TestContext(t *testing.T) {
+	assert := assert.New(t)
+	r := NewTree("testdata")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.LoadConfigContext(ctx, "system", false)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.NoError(r.LoadConfigContext(context.Background(), "system", false))
+
+	assert.True(r.Set("system", "ntp", "enabled", "0"))
+	err = r.CommitContext(ctx)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.True(r.(*tree).configs["system"].Tainted())
+}
@@ -0,0 +1,307 @@
+// Package ucirpc exposes a uci.Tree as gRPC service, so that central
+// controllers can manage the configs of many Go-based agents. The
+// service is defined in uci.proto; uci.pb.go and uci_grpc.pb.go are
+// generated from it.
+//
+// An agent registers the server with its gRPC server:
+//
+//	s := grpc.NewServer(grpc.Creds(creds))
+//	ucirpc.RegisterUCIServer(s, ucirpc.NewServer(tree))
+//	s.Serve(lis)
+//
+// and a controller uses the generated client:
+//
+//	client := ucirpc.NewUCIClient(conn)
+//	client.Set(ctx, &ucirpc.SetRequest{Config: "system", Section: "@system[0]",
+//		Option: "hostname", Values: []string{"ap1"}})
+//	client.Commit(ctx, &ucirpc.CommitRequest{})
+//
+// Errors are reported with the usual status codes, e.g. NotFound for
+// missing configs, sections and options, PermissionDenied for configs
+// not on the allowlist of a restricted tree, and InvalidArgument for
+// values rejected by a validator or hook.
+package ucirpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative uci.proto
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/wsiner/go-uci"
+)
+
+// watchBuffer is the number of events buffered per watcher. Watchers
+// falling further behind are disconnected.
+const watchBuffer = 64
+
+// Server implements the UCI service for a tree.
+type Server struct {
+	UnimplementedUCIServer
+
+	tree uci.Tree
+	mu   sync.Mutex // serializes access to the tree's configs
+
+	watchMu  sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+// watcher is a client of Watch.
+type watcher struct {
+	configs map[string]bool // nil for all
+	events  chan *Event
+	lost    chan struct{} // closed, when events were dropped
+	once    sync.Once
+}
+
+// NewServer returns a server for t. It registers a post-commit hook
+// (see uci.Tree.OnPostCommit), to notify watchers of commits made by
+// any user of the tree.
+func NewServer(t uci.Tree) *Server {
+	s := &Server{tree: t, watchers: make(map[*watcher]struct{})}
+	t.OnPostCommit(func(e *uci.PackageEvent) error {
+		s.publish(&Event{Kind: Event_KIND_COMMIT, Config: e.Config})
+		return nil
+	})
+	return s
+}
+
+func (s *Server) Load(ctx context.Context, req *LoadRequest) (*LoadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.tree.LoadConfigContext(ctx, req.GetConfig(), req.GetForceReload())
+	var loaded *uci.ErrConfigAlreadyLoaded
+	if err != nil && !errors.As(err, &loaded) {
+		return nil, statusError(err)
+	}
+	cfg, ok := s.tree.EnsureConfigLoaded(req.GetConfig())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "config %s not found", req.GetConfig())
+	}
+	return &LoadResponse{Config: configMessage(cfg)}, nil
+}
+
+// configMessage converts cfg.
+func configMessage(cfg *uci.Config) *Config {
+	msg := &Config{Name: cfg.Name, Sections: make([]*Section, len(cfg.Sections))}
+	for i, sec := range cfg.Sections {
+		options := make([]*Option, len(sec.Options))
+		for j, opt := range sec.Options {
+			options[j] = &Option{
+				Name:   opt.Name,
+				Values: append([]string(nil), opt.Values...),
+				List:   opt.Type == uci.TypeList,
+			}
+		}
+		msg.Sections[i] = &Section{
+			Name:      cfg.SectionName(sec),
+			Type:      sec.Type,
+			Anonymous: sec.Name == "",
+			Options:   options,
+		}
+	}
+	return msg
+}
+
+func (s *Server) Get(_ context.Context, req *GetRequest) (*GetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values, err := s.tree.Lookup(req.GetConfig(), req.GetSection(), req.GetOption())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &GetResponse{Values: values}
+	if cfg, ok := s.tree.EnsureConfigLoaded(req.GetConfig()); ok {
+		resp.List = cfg.Get(req.GetSection()).Get(req.GetOption()).Type == uci.TypeList
+	}
+	return resp, nil
+}
+
+func (s *Server) Set(_ context.Context, req *SetRequest) (*SetResponse, error) {
+	if req.GetConfig() == "" || req.GetSection() == "" || req.GetOption() == "" || len(req.GetValues()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "config, section, option and values are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.GetSectionType() != "" {
+		if err := s.tree.AddSection(req.GetConfig(), req.GetSection(), req.GetSectionType()); err != nil {
+			return nil, statusError(err)
+		}
+	} else if err := s.sectionExists(req.GetConfig(), req.GetSection()); err != nil {
+		return nil, err
+	}
+
+	typ := uci.TypeOption
+	if req.GetList() {
+		typ = uci.TypeList
+	}
+	if !s.tree.SetType(req.GetConfig(), req.GetSection(), req.GetOption(), typ, req.GetValues()...) {
+		return nil, status.Error(codes.InvalidArgument, "values rejected")
+	}
+	values, _ := s.tree.Get(req.GetConfig(), req.GetSection(), req.GetOption()) // possibly rewritten by a hook
+	s.publish(&Event{
+		Kind:    Event_KIND_SET,
+		Config:  req.GetConfig(),
+		Section: req.GetSection(),
+		Option:  req.GetOption(),
+		Values:  values,
+	})
+	return &SetResponse{}, nil
+}
+
+// sectionExists returns a NotFound error, if the section does not exist.
+func (s *Server) sectionExists(config, section string) error {
+	cfg, ok := s.tree.EnsureConfigLoaded(config)
+	if !ok {
+		return status.Errorf(codes.NotFound, "config %s not found", config)
+	}
+	if _, err := cfg.Lookup(section); err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+func (s *Server) Delete(_ context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.sectionExists(req.GetConfig(), req.GetSection()); err != nil {
+		return nil, err
+	}
+	cfg, _ := s.tree.EnsureConfigLoaded(req.GetConfig())
+	sec := cfg.Get(req.GetSection())
+	if req.GetOption() == "" {
+		s.tree.DelSection(req.GetConfig(), req.GetSection())
+		if cfg.Get(req.GetSection()) == sec {
+			return nil, status.Error(codes.FailedPrecondition, "deletion rejected")
+		}
+	} else {
+		if sec.Get(req.GetOption()) == nil {
+			return nil, status.Errorf(codes.NotFound, "option %s not found", req.GetOption())
+		}
+		s.tree.Del(req.GetConfig(), req.GetSection(), req.GetOption())
+		if sec.Get(req.GetOption()) != nil {
+			return nil, status.Error(codes.FailedPrecondition, "deletion rejected")
+		}
+	}
+	s.publish(&Event{
+		Kind:    Event_KIND_DELETE,
+		Config:  req.GetConfig(),
+		Section: req.GetSection(),
+		Option:  req.GetOption(),
+	})
+	return &DeleteResponse{}, nil
+}
+
+func (s *Server) Commit(ctx context.Context, _ *CommitRequest) (*CommitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tree.CommitContext(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	return &CommitResponse{}, nil
+}
+
+func (s *Server) Watch(req *WatchRequest, stream UCI_WatchServer) error {
+	w := &watcher{events: make(chan *Event, watchBuffer), lost: make(chan struct{})}
+	if len(req.GetConfigs()) > 0 {
+		w.configs = make(map[string]bool, len(req.GetConfigs()))
+		for _, name := range req.GetConfigs() {
+			w.configs[name] = true
+		}
+	}
+
+	s.watchMu.Lock()
+	s.watchers[w] = struct{}{}
+	s.watchMu.Unlock()
+	defer func() {
+		s.watchMu.Lock()
+		delete(s.watchers, w)
+		s.watchMu.Unlock()
+	}()
+
+	// tell the client, that events are delivered from now on
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-w.lost:
+			return status.Error(codes.ResourceExhausted, "watcher fell behind")
+		case e := <-w.events:
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publish sends e to the watchers. It doesn't block: watchers, whose
+// buffer is full, lose their connection.
+func (s *Server) publish(e *Event) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for w := range s.watchers {
+		if w.configs != nil && !w.configs[e.Config] {
+			continue
+		}
+		select {
+		case w.events <- e:
+		default:
+			w.once.Do(func() { close(w.lost) })
+		}
+	}
+}
+
+// statusError converts errors of the tree to gRPC status errors.
+func statusError(err error) error {
+	var (
+		notAllowed *uci.ErrConfigNotAllowed
+		validation *uci.ValidationError
+		vetoed     uci.ErrVetoed
+		rolledBack uci.ErrRolledBack
+		mismatch   uci.ErrSectionTypeMismatch
+	)
+	code := codes.Internal
+	switch {
+	case errors.Is(err, uci.ErrConfigNotFound),
+		errors.Is(err, uci.ErrSectionNotFound{}),
+		errors.Is(err, uci.ErrOptionNotFound),
+		errors.Is(err, uci.ErrUnnamedIndexOutOfBounds):
+		code = codes.NotFound
+	case errors.As(err, &notAllowed):
+		code = codes.PermissionDenied
+	case errors.As(err, &validation),
+		errors.Is(err, uci.ErrInvalidSectionSelector),
+		errors.Is(err, uci.ErrInvalidIdentifier),
+		errors.Is(err, uci.ErrInvalidConfigName),
+		errors.Is(err, uci.ErrImplausibleSectionSelector),
+		errors.Is(err, uci.ErrMustStartWithAt),
+		errors.Is(err, uci.ErrMultipleAtSigns),
+		errors.Is(err, uci.ErrMultipleOpenBrackets),
+		errors.Is(err, uci.ErrMultipleCloseBrackets),
+		errors.Is(err, strconv.ErrSyntax): // non-numeric selector index
+		code = codes.InvalidArgument
+	case errors.As(err, &vetoed), errors.As(err, &mismatch):
+		code = codes.FailedPrecondition
+	case errors.As(err, &rolledBack):
+		code = codes.Aborted
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,201 @@
+package ucirpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/wsiner/go-uci"
+	"github.com/wsiner/go-uci/validate"
+)
+
+const tcSystem = `
+config system
+	option hostname 'OpenWrt'
+	list ntp '0.openwrt.pool.ntp.org'
+
+config led 'wan'
+	option trigger 'netdev'
+`
+
+// dial serves the tree over an in-memory connection, and returns a
+// client.
+func dial(t *testing.T, tree uci.Tree) UCIClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 16)
+	s := grpc.NewServer()
+	RegisterUCIServer(s, NewServer(tree))
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewUCIClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	store := uci.NewMemoryStore(map[string]string{"system": tcSystem})
+	client := dial(t, uci.NewStoreTree(store))
+
+	load, err := client.Load(ctx, &LoadRequest{Config: "system"})
+	require.NoError(err)
+	require.Len(load.GetConfig().GetSections(), 2)
+	sec := load.GetConfig().GetSections()[0]
+	assert.Equal("@system[0]", sec.GetName())
+	assert.True(sec.GetAnonymous())
+	assert.Equal([]string{"0.openwrt.pool.ntp.org"}, sec.GetOptions()[1].GetValues())
+	assert.True(sec.GetOptions()[1].GetList())
+
+	get, err := client.Get(ctx, &GetRequest{Config: "system", Section: "@system[0]", Option: "hostname"})
+	require.NoError(err)
+	assert.Equal([]string{"OpenWrt"}, get.GetValues())
+	assert.False(get.GetList())
+
+	_, err = client.Set(ctx, &SetRequest{Config: "system", Section: "@system[0]", Option: "hostname", Values: []string{"ap1"}})
+	require.NoError(err)
+	_, err = client.Set(ctx, &SetRequest{Config: "system", Section: "lan", SectionType: "led", Option: "trigger", Values: []string{"netdev"}})
+	require.NoError(err)
+	_, err = client.Delete(ctx, &DeleteRequest{Config: "system", Section: "wan"})
+	require.NoError(err)
+	_, err = client.Delete(ctx, &DeleteRequest{Config: "system", Section: "@system[0]", Option: "ntp"})
+	require.NoError(err)
+	_, err = client.Commit(ctx, &CommitRequest{})
+	require.NoError(err)
+
+	body, err := store.Read(ctx, "system")
+	require.NoError(err)
+	assert.Equal(`
+config system
+	option hostname 'ap1'
+
+config led 'lan'
+	option trigger 'netdev'
+
+`, string(body))
+}
+
+func TestServerErrors(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"system": tcSystem}))
+	port, err := validate.Validator("uinteger")
+	require.NoError(t, err)
+	tree.RegisterValidator("timezone", port)
+	client := dial(t, tree)
+
+	for _, tc := range []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"missing config", func() error {
+			_, err := client.Load(ctx, &LoadRequest{Config: "missing"})
+			return err
+		}, codes.NotFound},
+		{"missing section", func() error {
+			_, err := client.Get(ctx, &GetRequest{Config: "system", Section: "lan", Option: "trigger"})
+			return err
+		}, codes.NotFound},
+		{"missing option", func() error {
+			_, err := client.Get(ctx, &GetRequest{Config: "system", Section: "wan", Option: "mode"})
+			return err
+		}, codes.NotFound},
+		{"invalid selector", func() error {
+			_, err := client.Get(ctx, &GetRequest{Config: "system", Section: "@system[x]", Option: "hostname"})
+			return err
+		}, codes.InvalidArgument},
+		{"set into missing section", func() error {
+			_, err := client.Set(ctx, &SetRequest{Config: "system", Section: "lan", Option: "trigger", Values: []string{"none"}})
+			return err
+		}, codes.NotFound},
+		{"set without values", func() error {
+			_, err := client.Set(ctx, &SetRequest{Config: "system", Section: "wan", Option: "trigger"})
+			return err
+		}, codes.InvalidArgument},
+		{"invalid value", func() error {
+			_, err := client.Set(ctx, &SetRequest{Config: "system", Section: "@system[0]", Option: "timezone", Values: []string{"UTC"}})
+			return err
+		}, codes.InvalidArgument},
+		{"invalid section name", func() error {
+			_, err := client.Set(ctx, &SetRequest{Config: "system", Section: "guest-wifi", SectionType: "led", Option: "a", Values: []string{"b"}})
+			return err
+		}, codes.InvalidArgument},
+		{"section type mismatch", func() error {
+			_, err := client.Set(ctx, &SetRequest{Config: "system", Section: "wan", SectionType: "system", Option: "a", Values: []string{"b"}})
+			return err
+		}, codes.FailedPrecondition},
+		{"load config outside of tree", func() error {
+			_, err := client.Load(ctx, &LoadRequest{Config: "../system"})
+			return err
+		}, codes.InvalidArgument},
+		{"get config outside of tree", func() error {
+			_, err := client.Get(ctx, &GetRequest{Config: "../secret", Section: "k", Option: "pw"})
+			return err
+		}, codes.InvalidArgument},
+		{"set config outside of tree", func() error {
+			_, err := client.Set(ctx, &SetRequest{Config: "../secret", Section: "k", SectionType: "k", Option: "pw", Values: []string{"x"}})
+			return err
+		}, codes.InvalidArgument},
+		{"delete missing option", func() error {
+			_, err := client.Delete(ctx, &DeleteRequest{Config: "system", Section: "wan", Option: "mode"})
+			return err
+		}, codes.NotFound},
+	} {
+		assert.Equal(tc.code, status.Code(tc.call()), tc.name)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tree := uci.NewStoreTree(uci.NewMemoryStore(map[string]string{"system": tcSystem}))
+	client := dial(t, tree)
+
+	stream, err := client.Watch(ctx, &WatchRequest{Configs: []string{"system"}})
+	require.NoError(err)
+	_, err = stream.Header() // the watcher is registered
+	require.NoError(err)
+
+	_, err = client.Set(ctx, &SetRequest{Config: "system", Section: "wan", Option: "mode", Values: []string{"tx", "rx"}, List: true})
+	require.NoError(err)
+	_, err = client.Delete(ctx, &DeleteRequest{Config: "system", Section: "wan"})
+	require.NoError(err)
+	require.NoError(tree.Commit()) // commits by other users are reported too
+
+	var got []string
+	for range 3 {
+		e, err := stream.Recv()
+		require.NoError(err)
+		got = append(got, e.GetKind().String()+" "+e.GetConfig()+" "+e.GetSection()+" "+e.GetOption())
+		if e.GetKind() == Event_KIND_SET {
+			assert.Equal([]string{"tx", "rx"}, e.GetValues())
+		}
+	}
+	assert.Equal([]string{
+		"KIND_SET system wan mode",
+		"KIND_DELETE system wan ",
+		"KIND_COMMIT system  ",
+	}, got)
+}
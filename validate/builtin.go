@@ -0,0 +1,258 @@
+package validate
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/wsiner/go-uci/ast"
+)
+
+// Built-in datatypes. Names and semantics follow OpenWrt's
+// /lib/functions/validate_data.sh (libubox's validate).
+var builtins = map[string]Func{
+	"string":    func(string) bool { return true },
+	"bool":      Bool,
+	"integer":   Integer,
+	"uinteger":  UInteger,
+	"float":     Float,
+	"ufloat":    UFloat,
+	"port":      Port,
+	"portrange": PortRange,
+	"macaddr":   MACAddr,
+	"ipaddr":    IPAddr,
+	"ip4addr":   IP4Addr,
+	"ip6addr":   IP6Addr,
+	"cidr":      CIDR,
+	"cidr4":     CIDR4,
+	"cidr6":     CIDR6,
+	"ipmask":    IPMask,
+	"ipmask4":   IPMask4,
+	"ipmask6":   IPMask6,
+	"hostname":  Hostname,
+	"host":      Host,
+	"network":   Network,
+
+	// not in validate_data.sh, for unit-suffixed values
+	"leasetime": LeaseTime,
+	"rate":      Rate,
+	"size":      Size,
+}
+
+// Bool accepts the spellings of booleans understood by uci.
+func Bool(v string) bool {
+	switch v {
+	case "0", "off", "false", "no", "disabled",
+		"1", "on", "true", "yes", "enabled":
+		return true
+	}
+	return false
+}
+
+// Integer accepts decimal integers, with optional sign.
+func Integer(v string) bool {
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+// UInteger accepts non-negative decimal integers.
+func UInteger(v string) bool {
+	_, err := strconv.ParseUint(v, 10, 64)
+	return err == nil && !strings.HasPrefix(v, "+")
+}
+
+// Float accepts decimal numbers.
+func Float(v string) bool {
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil && isDecimal(v)
+}
+
+// UFloat accepts non-negative decimal numbers.
+func UFloat(v string) bool {
+	return Float(v) && !strings.HasPrefix(v, "-")
+}
+
+// isDecimal rejects the special values strconv.ParseFloat accepts
+// (Inf, NaN, hexadecimal floats).
+func isDecimal(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; (c < '0' || c > '9') && c != '.' && c != '-' && c != '+' && c != 'e' && c != 'E' {
+			return false
+		}
+	}
+	return true
+}
+
+// Port accepts port numbers (0-65535).
+func Port(v string) bool {
+	n, err := strconv.ParseUint(v, 10, 16)
+	return err == nil && n <= 65535 && !strings.HasPrefix(v, "+")
+}
+
+// PortRange accepts a port, or a range of ports ("1024-65535").
+func PortRange(v string) bool {
+	lo, hi, ok := strings.Cut(v, "-")
+	if !ok {
+		return Port(v)
+	}
+	if !Port(lo) || !Port(hi) {
+		return false
+	}
+	a, _ := strconv.Atoi(lo)
+	b, _ := strconv.Atoi(hi)
+	return a <= b
+}
+
+// MACAddr accepts Ethernet addresses of six hex bytes, separated by
+// colons ("00:11:22:aa:bb:cc"). Like ether_aton, single-digit bytes are
+// allowed.
+func MACAddr(v string) bool {
+	parts := strings.Split(v, ":")
+	if len(parts) != 6 {
+		return false
+	}
+	for _, p := range parts {
+		if len(p) < 1 || len(p) > 2 {
+			return false
+		}
+		if _, err := strconv.ParseUint(p, 16, 8); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IPAddr accepts IPv4 and IPv6 addresses.
+func IPAddr(v string) bool {
+	return IP4Addr(v) || IP6Addr(v)
+}
+
+// IP4Addr accepts IPv4 addresses in dotted decimal notation.
+func IP4Addr(v string) bool {
+	addr, err := netip.ParseAddr(v)
+	return err == nil && addr.Is4()
+}
+
+// IP6Addr accepts IPv6 addresses (without zone).
+func IP6Addr(v string) bool {
+	addr, err := netip.ParseAddr(v)
+	return err == nil && addr.Is6() && addr.Zone() == ""
+}
+
+// CIDR accepts IPv4 and IPv6 networks in CIDR notation.
+func CIDR(v string) bool {
+	return CIDR4(v) || CIDR6(v)
+}
+
+// CIDR4 accepts IPv4 addresses with prefix length ("192.168.1.1/24").
+func CIDR4(v string) bool {
+	addr, _, ok := strings.Cut(v, "/")
+	if !ok || !IP4Addr(addr) {
+		return false
+	}
+	_, err := netip.ParsePrefix(v)
+	return err == nil
+}
+
+// CIDR6 accepts IPv6 addresses with prefix length ("fd00::1/64").
+func CIDR6(v string) bool {
+	addr, _, ok := strings.Cut(v, "/")
+	if !ok || !IP6Addr(addr) {
+		return false
+	}
+	_, err := netip.ParsePrefix(v)
+	return err == nil
+}
+
+// IPMask accepts IPv4 and IPv6 addresses, with optional netmask.
+func IPMask(v string) bool {
+	return IPMask4(v) || IPMask6(v)
+}
+
+// IPMask4 accepts IPv4 addresses, with optional prefix length or
+// dotted netmask ("192.168.1.1/255.255.255.0").
+func IPMask4(v string) bool {
+	addr, mask, ok := strings.Cut(v, "/")
+	if !ok {
+		return IP4Addr(v)
+	}
+	return IP4Addr(addr) && (CIDR4(v) || isNetmask4(mask))
+}
+
+// IPMask6 accepts IPv6 addresses, with optional prefix length.
+func IPMask6(v string) bool {
+	if !strings.Contains(v, "/") {
+		return IP6Addr(v)
+	}
+	return CIDR6(v)
+}
+
+// isNetmask4 reports whether v is a dotted IPv4 netmask, i.e. its bits
+// are a run of ones followed by zeros.
+func isNetmask4(v string) bool {
+	addr, err := netip.ParseAddr(v)
+	if err != nil || !addr.Is4() {
+		return false
+	}
+	b := addr.As4()
+	m := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	return m&(^m>>1) == 0 // no zero bit is followed by a one bit
+}
+
+// Hostname accepts host names: up to 253 characters, in labels of
+// letters, digits, "-" and "_", separated by dots.
+func Hostname(v string) bool {
+	if v == "" || len(v) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(v, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Host accepts host names and IP addresses.
+func Host(v string) bool {
+	return Hostname(v) || IPAddr(v)
+}
+
+// Network accepts the names of network interfaces (UCI section names).
+func Network(v string) bool {
+	if v == "" {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// LeaseTime accepts lease times like "12h" or "infinite", see
+// ast.ParseLeaseTime.
+func LeaseTime(v string) bool {
+	_, err := ast.ParseLeaseTime(v)
+	return err == nil
+}
+
+// Rate accepts rates like "10/minute", see ast.ParseRate.
+func Rate(v string) bool {
+	_, err := ast.ParseRate(v)
+	return err == nil
+}
+
+// Size accepts sizes like "64k", see ast.ParseSize.
+func Size(v string) bool {
+	_, err := ast.ParseSize(v)
+	return err == nil
+}
@@ -0,0 +1,256 @@
+// Package validate checks option values against datatypes, using the
+// names and semantics of OpenWrt's /lib/functions/validate_data.sh
+// (e.g. "port", "macaddr", "ip4addr", "hostname" or "bool").
+//
+// Datatypes can be combined with the same expressions OpenWrt uses,
+// e.g. "or(ip4addr,hostname)", "list(port)" or "range(1,4094)":
+//
+//	check, err := validate.Parse("or(ip4addr,hostname)")
+//	check("192.168.1.1") //=> true
+//
+// Validator adapts a datatype to a uci.Validator:
+//
+//	v, err := validate.Validator("port")
+//	tree.RegisterValidator("dest_port", v)
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidDatatype = errors.New("invalid datatype expression")
+
+// A Func reports whether a value is valid.
+type Func func(v string) bool
+
+// Error is returned by validators, if a value does not match the
+// datatype.
+type Error struct {
+	Datatype string
+	Value    string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("invalid value %q: must be %s", err.Value, err.Datatype)
+}
+
+// Validator returns a function suitable for uci.Tree.RegisterValidator,
+// which checks values against the datatype expression. Errors are of
+// type *Error.
+func Validator(expr string) (func(pkg, sectionType, option, value string) error, error) {
+	check, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(_, _, _, value string) error {
+		if !check(value) {
+			return &Error{Datatype: expr, Value: value}
+		}
+		return nil
+	}, nil
+}
+
+// Check reports whether v matches the datatype expression.
+func Check(expr, v string) (bool, error) {
+	check, err := Parse(expr)
+	if err != nil {
+		return false, err
+	}
+	return check(v), nil
+}
+
+// Parse compiles a datatype expression. Besides the built-in datatypes,
+// it supports:
+//
+//	or(a,b,...)          any of the datatypes
+//	and(a,b,...)         all of the datatypes
+//	not(a)               not the datatype
+//	list(a)              whitespace separated words of the datatype
+//	range(min,max)       numbers between min and max
+//	min(n), max(n)       numbers of at least/most n
+//	minlength(n)         strings of at least n characters
+//	maxlength(n)         strings of at most n characters
+//	rangelength(m,n)     strings of m to n characters
+//	"value"              the literal value (single quotes work, too)
+func Parse(expr string) (Func, error) {
+	p := exprParser{s: expr}
+	f, err := p.parse()
+	if err == nil && p.pos != len(p.s) {
+		err = p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return f, err
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w %q at %d: %s", ErrInvalidDatatype, p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+// word consumes an identifier or number.
+func (p *exprParser) word() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune("(),", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return strings.TrimSpace(p.s[start:p.pos])
+}
+
+// args consumes a parenthesized argument list, without parsing the
+// arguments.
+func (p *exprParser) args() ([]string, error) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		return nil, p.errorf("missing arguments")
+	}
+	p.pos++
+	var args []string
+	depth, start := 0, p.pos
+	for ; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(p.s[start:p.pos]))
+				p.pos++
+				return args, nil
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(p.s[start:p.pos]))
+				start = p.pos + 1
+			}
+		}
+	}
+	return nil, p.errorf("unterminated argument list")
+}
+
+func (p *exprParser) parse() (Func, error) { //nolint:cyclop
+	name := p.word()
+	if name == "" {
+		return nil, p.errorf("missing datatype")
+	}
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		if f, ok := builtins[name]; ok {
+			return f, nil
+		}
+		if len(name) >= 2 && (name[0] == '"' || name[0] == '\'') && name[len(name)-1] == name[0] {
+			lit := name[1 : len(name)-1]
+			return func(v string) bool { return v == lit }, nil
+		}
+		return nil, p.errorf("unknown datatype %q", name)
+	}
+
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "or", "and":
+		funcs, err := parseAll(args)
+		if err != nil {
+			return nil, err
+		}
+		want := name == "or"
+		return func(v string) bool {
+			for _, f := range funcs {
+				if f(v) == want {
+					return want
+				}
+			}
+			return !want
+		}, nil
+
+	case "not", "list":
+		funcs, err := parseAll(args)
+		if err != nil {
+			return nil, err
+		}
+		if len(funcs) != 1 {
+			return nil, p.errorf("%s takes one argument", name)
+		}
+		f := funcs[0]
+		if name == "not" {
+			return func(v string) bool { return !f(v) }, nil
+		}
+		return func(v string) bool {
+			for _, w := range strings.Fields(v) {
+				if !f(w) {
+					return false
+				}
+			}
+			return true
+		}, nil
+
+	case "range", "min", "max":
+		nums, err := p.numbers(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return numberRange(name, nums), nil
+
+	case "minlength", "maxlength", "rangelength":
+		nums, err := p.numbers(name, args)
+		if err != nil {
+			return nil, err
+		}
+		lo, hi := nums[0], nums[len(nums)-1]
+		return func(v string) bool {
+			n := float64(utf8.RuneCountInString(v))
+			return (name == "maxlength" || n >= lo) && (name == "minlength" || n <= hi)
+		}, nil
+	}
+	return nil, p.errorf("unknown function %q", name)
+}
+
+// numbers parses the numeric arguments of name: two for the range
+// functions, one for the others.
+func (p *exprParser) numbers(name string, args []string) ([]float64, error) {
+	want := 1
+	if strings.HasPrefix(name, "range") {
+		want = 2
+	}
+	if len(args) != want {
+		return nil, p.errorf("%s takes %d argument(s)", name, want)
+	}
+	nums := make([]float64, len(args))
+	for i, arg := range args {
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, p.errorf("%s: %q is not a number", name, arg)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+func numberRange(name string, nums []float64) Func {
+	lo, hi := nums[0], nums[len(nums)-1]
+	return func(v string) bool {
+		if !Float(v) {
+			return false
+		}
+		n, _ := strconv.ParseFloat(v, 64)
+		return (name == "max" || n >= lo) && (name == "min" || n <= hi)
+	}
+}
+
+func parseAll(exprs []string) ([]Func, error) {
+	funcs := make([]Func, 0, len(exprs))
+	for _, expr := range exprs {
+		f, err := Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, f)
+	}
+	return funcs, nil
+}
@@ -0,0 +1,90 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatatypes(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		datatype string
+		valid    []string
+		invalid  []string
+	}{
+		{"bool", []string{"0", "1", "on", "off", "yes", "no", "true", "false", "enabled", "disabled"}, []string{"", "2", "True", "y"}},
+		{"integer", []string{"0", "-1", "+42"}, []string{"", "1.5", "0x10", "a"}},
+		{"uinteger", []string{"0", "42"}, []string{"-1", "+1", "1.0"}},
+		{"float", []string{"1", "-1.5", "2e3"}, []string{"", "Inf", "NaN", "0x1p-2"}},
+		{"ufloat", []string{"1.5"}, []string{"-1.5"}},
+		{"port", []string{"0", "22", "65535"}, []string{"65536", "-1", "+1", "ssh"}},
+		{"portrange", []string{"22", "1024-65535", "80-80"}, []string{"80-22", "1-", "-1", "1-2-3"}},
+		{"macaddr", []string{"00:11:22:aa:BB:cc", "0:1:2:a:b:c"}, []string{"00:11:22:aa:bb", "00-11-22-aa-bb-cc", "000:11:22:aa:bb:cc", "00:11:22:aa:bb:gg"}},
+		{"ipaddr", []string{"192.168.1.1", "fd00::1"}, []string{"192.168.1.256", "host", "fe80::1%eth0"}},
+		{"ip4addr", []string{"10.0.0.1"}, []string{"fd00::1", "10.0.0.1/8"}},
+		{"ip6addr", []string{"::1", "2001:db8::1"}, []string{"10.0.0.1"}},
+		{"cidr", []string{"10.0.0.0/8", "fd00::/64"}, []string{"10.0.0.0", "10.0.0.0/33"}},
+		{"cidr4", []string{"192.168.1.1/24"}, []string{"fd00::/64", "192.168.1.1/255.255.255.0"}},
+		{"cidr6", []string{"fd00::1/128"}, []string{"10.0.0.0/8", "fd00::/129"}},
+		{"ipmask4", []string{"192.168.1.1", "192.168.1.1/24", "192.168.1.1/255.255.255.0"}, []string{"192.168.1.1/255.0.255.0", "192.168.1.1/33"}},
+		{"ipmask6", []string{"fd00::1", "fd00::1/64"}, []string{"fd00::1/ffff::"}},
+		{"hostname", []string{"openwrt", "router.lan", "my_host-1.example.com."}, []string{"", "-router", "a..b", "host name", "ä"}},
+		{"host", []string{"openwrt.lan", "192.168.1.1", "fd00::1"}, []string{"", "not valid"}},
+		{"network", []string{"lan", "wan_6"}, []string{"", "lan.1", "lan-1"}},
+		{"string", []string{"", "anything"}, nil},
+		{"leasetime", []string{"12h", "3600", "infinite"}, []string{"", "12 h", "1.5h"}},
+		{"rate", []string{"10/second", "3/min"}, []string{"10", "10/week"}},
+		{"size", []string{"64k", "1M", "512"}, []string{"64kb", "-1"}},
+		{"or(port,hostname)", []string{"22", "router"}, []string{"-router"}},
+		{`or("ACCEPT", 'DROP')`, []string{"ACCEPT", "DROP"}, []string{"accept", `"ACCEPT"`}},
+		{"and(uinteger,max(10))", []string{"0", "10"}, []string{"11", "1.5"}},
+		{"not(bool)", []string{"maybe"}, []string{"1"}},
+		{"list(port)", []string{"22", "80 443"}, []string{"80 https"}},
+		{"list(or(macaddr, network))", []string{"lan 00:11:22:33:44:55"}, []string{"lan 00:11"}},
+		{"range(1,4094)", []string{"1", "4094", "100.5"}, []string{"0", "4095", "vlan"}},
+		{"min(-1)", []string{"-1", "100"}, []string{"-2"}},
+		{"maxlength(3)", []string{"", "abc", "äöü"}, []string{"abcd"}},
+		{"rangelength(8,63)", []string{"password"}, []string{"short"}},
+	} {
+		check, err := Parse(tc.datatype)
+		require.NoError(t, err, tc.datatype)
+		for _, v := range tc.valid {
+			assert.True(check(v), "%s: %q should be valid", tc.datatype, v)
+		}
+		for _, v := range tc.invalid {
+			assert.False(check(v), "%s: %q should be invalid", tc.datatype, v)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"", "unknown", "or(port,unknown)", "or(port", "not(bool,port)",
+		"range(1)", "range(a,b)", "port)", "minlength", "foo(1)",
+	} {
+		_, err := Parse(expr)
+		assert.True(t, errors.Is(err, ErrInvalidDatatype), expr)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	assert := assert.New(t)
+
+	v, err := Validator("port")
+	require.NoError(t, err)
+	assert.NoError(v("firewall", "rule", "dest_port", "22"))
+
+	err = v("firewall", "rule", "dest_port", "ssh")
+	var verr *Error
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(&Error{Datatype: "port", Value: "ssh"}, verr)
+	assert.EqualError(err, `invalid value "ssh": must be port`)
+
+	ok, err := Check("list(ip4addr)", "1.1.1.1 9.9.9.9")
+	assert.NoError(err)
+	assert.True(ok)
+}
@@ -0,0 +1,82 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A Validator checks a value of an option. See Tree.RegisterValidator,
+// and package validate for validators of common datatypes.
+type Validator func(pkg, sectionType, option, value string) error
+
+// ValidationError is returned by Tree.Validate for each invalid value.
+type ValidationError struct {
+	Path  Path
+	Value string
+	Err   error // as returned by the validator
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Path, err.Err)
+}
+
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+func (t *tree) RegisterValidator(option string, v Validator) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.validators == nil {
+		t.validators = make(map[string][]Validator)
+	}
+	t.validators[option] = append(t.validators[option], v)
+}
+
+// checkValue runs the validators registered for the option, and for all
+// options. Its call must be guarded by locking the tree's mutex.
+func (t *tree) checkValue(pkg, sectionType, option, value string) error {
+	for _, name := range [...]string{option, ""} {
+		for _, v := range t.validators[name] {
+			if err := v(pkg, sectionType, option, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *tree) Validate(configs ...string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(configs) == 0 {
+		for name := range t.configs {
+			configs = append(configs, name)
+		}
+		sort.Strings(configs)
+	}
+
+	var errs []error
+	for _, name := range configs {
+		cfg, err := t.ensureConfig(context.Background(), name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("validate: %w", err))
+			continue
+		}
+		for _, sec := range cfg.Sections {
+			for _, opt := range sec.Options {
+				for _, value := range opt.Values {
+					if err := t.checkValue(name, sec.Type, opt.Name, value); err != nil {
+						path := Path{Config: name, Section: cfg.SectionName(sec), Option: opt.Name}
+						errs = append(errs, &ValidationError{Path: path, Value: value, Err: err})
+					}
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
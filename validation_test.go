@@ -0,0 +1,59 @@
+package uci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wsiner/go-uci/validate"
+)
+
+func TestValidators(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewStoreTree(NewMemoryStore(map[string]string{
+		"firewall": `
+config rule
+	option name 'Allow-SSH'
+	option dest_port '22'
+	option enabled 'maybe'
+
+config redirect
+	option dest_port 'http'
+`,
+	}))
+
+	port, err := validate.Validator("portrange")
+	require.NoError(err)
+	r.RegisterValidator("dest_port", port)
+
+	errNotBool := errors.New("not a boolean")
+	r.RegisterValidator("", func(pkg, sectionType, option, value string) error {
+		if option == "enabled" && !validate.Bool(value) {
+			return errNotBool
+		}
+		return nil
+	})
+
+	assert.True(r.Set("firewall", "@rule[0]", "dest_port", "2222"))
+	assert.False(r.Set("firewall", "@rule[0]", "dest_port", "ssh"))
+	assert.False(r.SetType("firewall", "@rule[0]", "dest_port", TypeList, "22", "70000"))
+	value, _ := r.GetLast("firewall", "@rule[0]", "dest_port")
+	assert.Equal("2222", value)
+
+	err = r.Validate()
+	require.Error(err)
+	var verr *ValidationError
+	require.True(errors.As(err, &verr))
+	assert.Equal(Path{Config: "firewall", Section: "@rule[0]", Option: "enabled"}, verr.Path)
+	assert.True(errors.Is(err, errNotBool))
+	assert.EqualError(err, `firewall.@rule[0].enabled: not a boolean
+firewall.@redirect[0].dest_port: invalid value "http": must be portrange`)
+
+	assert.True(r.Set("firewall", "@rule[0]", "enabled", "1"))
+	assert.True(r.Set("firewall", "@redirect[0]", "dest_port", "80"))
+	assert.NoError(r.Validate("firewall"))
+}
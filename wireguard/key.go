@@ -0,0 +1,66 @@
+package wireguard
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// A Key is a WireGuard private, public or preshared key: 32 bytes, which
+// are written base64 encoded, like "wg genkey" does.
+type Key [32]byte
+
+// ParseKey decodes a base64 encoded key.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != len(k) {
+		return Key{}, fmt.Errorf("%w %q", ErrInvalidKey, s)
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// String returns the base64 encoded key.
+func (k Key) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// IsZero reports whether the key is unset.
+func (k Key) IsZero() bool {
+	return k == Key{}
+}
+
+// PublicKey returns the public key of a private key, like "wg pubkey".
+func (k Key) PublicKey() Key {
+	// X25519 accepts any 32 bytes, and clamps them itself
+	priv, err := ecdh.X25519().NewPrivateKey(k[:])
+	if err != nil {
+		panic(err)
+	}
+	var pub Key
+	copy(pub[:], priv.PublicKey().Bytes())
+	return pub
+}
+
+// GenerateKey returns a new private key, like "wg genkey".
+func GenerateKey() (Key, error) {
+	k, err := GeneratePresharedKey()
+	if err != nil {
+		return Key{}, err
+	}
+	k[0] &= 248
+	k[31] = k[31]&127 | 64
+	return k, nil
+}
+
+// GeneratePresharedKey returns a new random preshared key, like
+// "wg genpsk".
+func GeneratePresharedKey() (Key, error) {
+	var k Key
+	if _, err := rand.Read(k[:]); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
@@ -0,0 +1,142 @@
+package wireguard
+
+import (
+	"fmt"
+	"slices"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// Interfaces returns the WireGuard interfaces of the network config,
+// with their peers.
+func Interfaces(t uci.Tree) ([]*Interface, error) {
+	cfg, ok := t.EnsureConfigLoaded("network")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	var ifaces []*Interface
+	for _, sec := range cfg.Sections {
+		if sec.Type != "interface" || sec.LastValue("proto") != Proto {
+			continue
+		}
+		iface, err := ParseInterface(sec)
+		if err != nil {
+			return nil, err
+		}
+		if iface.Peers, err = peers(cfg, iface.Name); err != nil {
+			return nil, fmt.Errorf("interface %s: %w", iface.Name, err)
+		}
+		ifaces = append(ifaces, iface)
+	}
+	return ifaces, nil
+}
+
+// GetInterface returns the named WireGuard interface, with its peers.
+func GetInterface(t uci.Tree, name string) (*Interface, error) {
+	cfg, ok := t.EnsureConfigLoaded("network")
+	if !ok {
+		return nil, ErrNoConfig
+	}
+	return getInterface(cfg, name)
+}
+
+func getInterface(cfg *uci.Config, name string) (*Interface, error) {
+	sec := cfg.Get(name)
+	if sec == nil {
+		return nil, uci.ErrSectionNotFound{Config: "network", Section: name}
+	}
+	iface, err := ParseInterface(sec)
+	if err != nil {
+		return nil, err
+	}
+	if iface.Peers, err = peers(cfg, name); err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	return iface, nil
+}
+
+func peers(cfg *uci.Config, iface string) ([]*Peer, error) {
+	var peers []*Peer
+	for _, sec := range cfg.Sections {
+		if sec.Type != PeerType(iface) {
+			continue
+		}
+		p, err := ParsePeer(sec)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// PutInterface validates iface, and stores it in the network config,
+// creating the section (and config), if necessary. Peer sections are
+// matched by public key: existing ones are updated, new ones appended,
+// and those of peers no longer listed are removed. Options not
+// represented by Interface and Peer are kept.
+func PutInterface(t uci.Tree, iface *Interface) error {
+	if err := iface.Validate(); err != nil {
+		return err
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		if _, err := tx.PutSection("network", iface.Section(), interfaceOptions...); err != nil {
+			return err
+		}
+		cfg, _ := tx.CopyConfig("network")
+		byKey := make(map[Key]*Peer, len(iface.Peers))
+		for _, p := range iface.Peers {
+			byKey[p.PublicKey] = p
+		}
+		var removed []string
+		for _, sec := range cfg.Sections {
+			if sec.Type != PeerType(iface.Name) {
+				continue
+			}
+			key, err := ParseKey(sec.LastValue("public_key"))
+			p := byKey[key]
+			if err != nil || p == nil {
+				removed = append(removed, cfg.SectionName(sec))
+				continue
+			}
+			if err := tx.ReplaceOptions("network", cfg.SectionName(sec), p.Section(iface.Name), peerOptions...); err != nil {
+				return err
+			}
+			delete(byKey, key)
+		}
+		// backwards, so that the selectors of the others stay valid
+		for _, name := range slices.Backward(removed) {
+			if err := tx.DelSection("network", name); err != nil {
+				return err
+			}
+		}
+		for _, p := range iface.Peers {
+			if byKey[p.PublicKey] != nil {
+				if _, err := tx.PutSection("network", p.Section(iface.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// AddPeer adds a peer to an existing WireGuard interface.
+func AddPeer(t uci.Tree, iface string, p *Peer) error {
+	return t.Batch(func(tx *uci.Tx) error {
+		cfg, ok := tx.CopyConfig("network")
+		if !ok {
+			return ErrNoConfig
+		}
+		wg, err := getInterface(cfg, iface)
+		if err != nil {
+			return err
+		}
+		wg.Peers = append(wg.Peers, p)
+		if err := wg.Validate(); err != nil {
+			return err
+		}
+		_, err = tx.PutSection("network", p.Section(iface))
+		return err
+	})
+}
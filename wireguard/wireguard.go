@@ -0,0 +1,337 @@
+// Package wireguard manages WireGuard interfaces of the network config:
+// interface sections with proto "wireguard", and their peers, which are
+// sections of type "wireguard_<interface>".
+//
+// Keys are parsed and validated, and can be generated like "wg genkey"
+// does, so that complete configs can be emitted:
+//
+//	iface, err := wireguard.NewInterface("wg0", 51820, netip.MustParsePrefix("10.14.0.1/24"))
+//	peer, peerKey, err := wireguard.NewPeer("laptop", netip.MustParsePrefix("10.14.0.2/32"))
+//	iface.Peers = append(iface.Peers, peer)
+//	err = wireguard.PutInterface(tree, iface)
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrNoConfig        = errors.New("network config not found")
+	ErrNotWireGuard    = errors.New("not a WireGuard interface")
+	ErrInvalidKey      = errors.New("invalid WireGuard key")
+	ErrMissingKey      = errors.New("missing key")
+	ErrInvalidAddress  = errors.New("invalid address")
+	ErrInvalidEndpoint = errors.New("invalid endpoint")
+	ErrInvalidNumber   = errors.New("invalid number")
+	ErrDuplicatePeer   = errors.New("duplicate peer")
+	ErrOverlappingIPs  = errors.New("allowed IPs of peers overlap")
+)
+
+// Proto is the proto option of WireGuard interfaces.
+const Proto = "wireguard"
+
+// Interface is a WireGuard interface and its peers.
+type Interface struct {
+	Name       string
+	PrivateKey Key
+	ListenPort int            // 0 means random
+	Addresses  []netip.Prefix // "addresses" list
+	MTU        int            // 0 means unset
+	Peers      []*Peer
+}
+
+// Peer is a "wireguard_<interface>" section.
+type Peer struct {
+	Description         string
+	PublicKey           Key
+	PresharedKey        Key // zero means none
+	AllowedIPs          []netip.Prefix
+	RouteAllowedIPs     bool
+	Endpoint            Endpoint // zero means none
+	PersistentKeepalive int      // seconds, 0 means off
+}
+
+// interfaceOptions and peerOptions list the options managed by
+// Interface and Peer.
+var (
+	interfaceOptions = []string{"proto", "private_key", "listen_port", "addresses", "mtu"}
+	peerOptions      = []string{
+		"description", "public_key", "preshared_key", "allowed_ips", "route_allowed_ips",
+		"endpoint_host", "endpoint_port", "persistent_keepalive",
+	}
+)
+
+// PeerType returns the section type of the peers of an interface.
+func PeerType(iface string) string {
+	return "wireguard_" + iface
+}
+
+// NewInterface returns an interface with a new private key.
+func NewInterface(name string, listenPort int, addresses ...netip.Prefix) (*Interface, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &Interface{Name: name, PrivateKey: key, ListenPort: listenPort, Addresses: addresses}, nil
+}
+
+// NewPeer returns a peer with a new key pair. The private key is to be
+// handed to the peer; the interface only stores the public key.
+func NewPeer(description string, allowedIPs ...netip.Prefix) (*Peer, Key, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, Key{}, err
+	}
+	return &Peer{Description: description, PublicKey: key.PublicKey(), AllowedIPs: allowedIPs}, key, nil
+}
+
+// PublicKey returns the public key of the interface, which its peers
+// need.
+func (iface *Interface) PublicKey() Key {
+	return iface.PrivateKey.PublicKey()
+}
+
+// ParseInterface converts a WireGuard interface section. Its peers are
+// not parsed; see Interfaces.
+func ParseInterface(sec *uci.Section) (*Interface, error) {
+	wrap := func(err error) (*Interface, error) {
+		return nil, fmt.Errorf("interface %s: %w", sec.Name, err)
+	}
+	if sec.Type != "interface" || sec.LastValue("proto") != Proto {
+		return wrap(ErrNotWireGuard)
+	}
+
+	iface := &Interface{Name: sec.Name}
+	var err error
+	if iface.PrivateKey, err = parseKey(sec.LastValue("private_key")); err != nil {
+		return wrap(err)
+	}
+	if iface.Addresses, err = parsePrefixes(sec.Fields("addresses")); err != nil {
+		return wrap(err)
+	}
+	if iface.ListenPort, err = parseInt(sec.LastValue("listen_port")); err != nil {
+		return wrap(err)
+	}
+	if iface.MTU, err = parseInt(sec.LastValue("mtu")); err != nil {
+		return wrap(err)
+	}
+	return iface, nil
+}
+
+// ParsePeer converts a peer section.
+func ParsePeer(sec *uci.Section) (*Peer, error) {
+	p := &Peer{
+		Description:     sec.LastValue("description"),
+		RouteAllowedIPs: sec.LastValue("route_allowed_ips") == "1",
+	}
+	wrap := func(err error) (*Peer, error) {
+		name := p.Description
+		if name == "" {
+			name = sec.Name
+		}
+		return nil, fmt.Errorf("peer %s: %w", name, err)
+	}
+
+	var err error
+	if p.PublicKey, err = parseKey(sec.LastValue("public_key")); err != nil {
+		return wrap(err)
+	}
+	if v := sec.LastValue("preshared_key"); v != "" {
+		if p.PresharedKey, err = ParseKey(v); err != nil {
+			return wrap(err)
+		}
+	}
+	if p.AllowedIPs, err = parsePrefixes(sec.Fields("allowed_ips")); err != nil {
+		return wrap(err)
+	}
+	if host := sec.LastValue("endpoint_host"); host != "" {
+		p.Endpoint.Host = host
+		if p.Endpoint.Port, err = parseInt(sec.LastValue("endpoint_port")); err != nil {
+			return wrap(err)
+		}
+	}
+	if p.PersistentKeepalive, err = parseInt(sec.LastValue("persistent_keepalive")); err != nil {
+		return wrap(err)
+	}
+	return p, nil
+}
+
+// Validate checks that the interface and its peers have keys, that no
+// peer is listed twice, and that the allowed IPs of the peers don't
+// overlap (WireGuard routes each address to a single peer).
+func (iface *Interface) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("interface %s: %w", iface.Name, err)
+	}
+	if iface.PrivateKey.IsZero() {
+		return wrap(fmt.Errorf("%w: private_key", ErrMissingKey))
+	}
+	if iface.ListenPort < 0 || iface.ListenPort > 65535 {
+		return wrap(fmt.Errorf("%w: listen_port %d", ErrInvalidNumber, iface.ListenPort))
+	}
+	seen := make(map[Key]bool, len(iface.Peers))
+	var allowed []netip.Prefix
+	for _, p := range iface.Peers {
+		if p.PublicKey.IsZero() {
+			return wrap(fmt.Errorf("%w: public_key of peer %s", ErrMissingKey, p.Description))
+		}
+		if seen[p.PublicKey] {
+			return wrap(fmt.Errorf("%w %s", ErrDuplicatePeer, p.PublicKey))
+		}
+		seen[p.PublicKey] = true
+		for _, ip := range p.AllowedIPs {
+			for _, other := range allowed {
+				if ip.Overlaps(other) {
+					return wrap(fmt.Errorf("%w: %s and %s", ErrOverlappingIPs, other, ip))
+				}
+			}
+		}
+		allowed = append(allowed, p.AllowedIPs...)
+	}
+	return nil
+}
+
+// Section renders the interface section.
+func (iface *Interface) Section() *uci.Section {
+	s := uci.NewSection("interface", iface.Name)
+	s.SetOption("proto", Proto)
+	s.SetOption("private_key", iface.PrivateKey.String())
+	if iface.ListenPort > 0 {
+		s.SetOption("listen_port", strconv.Itoa(iface.ListenPort))
+	}
+	if len(iface.Addresses) > 0 {
+		s.SetList("addresses", prefixStrings(iface.Addresses)...)
+	}
+	if iface.MTU > 0 {
+		s.SetOption("mtu", strconv.Itoa(iface.MTU))
+	}
+	return s
+}
+
+// Section renders an unnamed peer section of the given interface.
+func (p *Peer) Section(iface string) *uci.Section {
+	s := uci.NewSection(PeerType(iface), "")
+	if p.Description != "" {
+		s.SetOption("description", p.Description)
+	}
+	s.SetOption("public_key", p.PublicKey.String())
+	if !p.PresharedKey.IsZero() {
+		s.SetOption("preshared_key", p.PresharedKey.String())
+	}
+	if len(p.AllowedIPs) > 0 {
+		s.SetList("allowed_ips", prefixStrings(p.AllowedIPs)...)
+	}
+	if p.RouteAllowedIPs {
+		s.SetOption("route_allowed_ips", "1")
+	}
+	if p.Endpoint.Host != "" {
+		s.SetOption("endpoint_host", p.Endpoint.Host)
+		if p.Endpoint.Port > 0 {
+			s.SetOption("endpoint_port", strconv.Itoa(p.Endpoint.Port))
+		}
+	}
+	if p.PersistentKeepalive > 0 {
+		s.SetOption("persistent_keepalive", strconv.Itoa(p.PersistentKeepalive))
+	}
+	return s
+}
+
+// Sections renders the interface section, followed by the sections of
+// its peers.
+func (iface *Interface) Sections() []*uci.Section {
+	sections := []*uci.Section{iface.Section()}
+	for _, p := range iface.Peers {
+		sections = append(sections, p.Section(iface.Name))
+	}
+	return sections
+}
+
+// An Endpoint is the address a peer is reached at.
+type Endpoint struct {
+	Host string // host name or IP address
+	Port int    // 0 means the default port of the peer
+}
+
+// ParseEndpoint parses "host:port", where IPv6 addresses are enclosed
+// in brackets, like in wg-quick configs. The port is optional.
+func ParseEndpoint(s string) (Endpoint, error) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return Endpoint{Host: ap.Addr().String(), Port: int(ap.Port())}, nil
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(s, "[]")); err == nil {
+		return Endpoint{Host: addr.String()}, nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = s, ""
+	}
+	if host == "" || strings.ContainsAny(host, " /[]:") {
+		return Endpoint{}, fmt.Errorf("%w %q", ErrInvalidEndpoint, s)
+	}
+	e := Endpoint{Host: host}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return Endpoint{}, fmt.Errorf("%w %q", ErrInvalidEndpoint, s)
+		}
+		e.Port = n
+	}
+	return e, nil
+}
+
+// String formats the endpoint for ParseEndpoint.
+func (e Endpoint) String() string {
+	if e.Port == 0 {
+		return e.Host
+	}
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+func parseKey(v string) (Key, error) {
+	if v == "" {
+		return Key{}, nil // reported by Validate
+	}
+	return ParseKey(v)
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range values {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return nil, fmt.Errorf("%w %q", ErrInvalidAddress, v)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+	}
+	return n, nil
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	s := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+package wireguard
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestKeys(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// RFC 7748, section 6.1
+	var priv, pub Key
+	_, err := hex.Decode(priv[:], []byte("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	require.NoError(err)
+	_, err = hex.Decode(pub[:], []byte("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"))
+	require.NoError(err)
+	assert.Equal(pub, priv.PublicKey())
+
+	parsed, err := ParseKey(priv.String())
+	require.NoError(err)
+	assert.Equal(priv, parsed)
+	for _, s := range []string{"", "abc", "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBm==", "!" + priv.String()[1:]} {
+		_, err := ParseKey(s)
+		assert.True(errors.Is(err, ErrInvalidKey), "%q: %v", s, err)
+	}
+
+	key, err := GenerateKey()
+	require.NoError(err)
+	assert.Equal(byte(0), key[0]&7)
+	assert.Equal(byte(64), key[31]&192)
+	psk, err := GeneratePresharedKey()
+	require.NoError(err)
+	assert.NotEqual(key, psk)
+}
+
+func TestEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Endpoint
+		out  string
+	}{
+		{"vpn.example.com:51820", Endpoint{"vpn.example.com", 51820}, "vpn.example.com:51820"},
+		{"vpn.example.com", Endpoint{"vpn.example.com", 0}, "vpn.example.com"},
+		{"192.0.2.1:51820", Endpoint{"192.0.2.1", 51820}, "192.0.2.1:51820"},
+		{"[2001:db8::1]:51820", Endpoint{"2001:db8::1", 51820}, "[2001:db8::1]:51820"},
+		{"2001:db8::1", Endpoint{"2001:db8::1", 0}, "2001:db8::1"},
+	} {
+		e, err := ParseEndpoint(tc.in)
+		if assert.NoError(t, err, tc.in) {
+			assert.Equal(t, tc.want, e)
+			assert.Equal(t, tc.out, e.String())
+		}
+	}
+	for _, in := range []string{"", ":51820", "host:0", "host:port", "host:70000", "a b:1"} {
+		_, err := ParseEndpoint(in)
+		assert.True(t, errors.Is(err, ErrInvalidEndpoint), "%q: %v", in, err)
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ifaces, err := Interfaces(uci.NewTree("../testdata"))
+	require.NoError(err)
+	require.Len(ifaces, 1)
+	wg := ifaces[0]
+	assert.Equal("wg0", wg.Name)
+	assert.Equal("yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=", wg.PrivateKey.String())
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("10.14.0.1/24")}, wg.Addresses)
+	require.Len(wg.Peers, 1)
+	assert.Equal("laptop", wg.Peers[0].Description)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("10.14.0.2/32")}, wg.Peers[0].AllowedIPs)
+	assert.NoError(wg.Validate())
+
+	sec := uci.NewSection("interface", "lan")
+	sec.SetOption("proto", "static")
+	_, err = ParseInterface(sec)
+	assert.ErrorIs(err, ErrNotWireGuard)
+	sec = uci.NewSection(PeerType("wg0"), "")
+	sec.SetOption("public_key", "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=")
+	sec.SetList("allowed_ips", "10.14.0.3", "fd00::/64")
+	sec.SetOption("endpoint_host", "vpn.example.com")
+	sec.SetOption("endpoint_port", "x")
+	_, err = ParsePeer(sec)
+	assert.ErrorIs(err, ErrInvalidNumber)
+	sec.Del("endpoint_port")
+	p, err := ParsePeer(sec)
+	require.NoError(err)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("10.14.0.3/32"), netip.MustParsePrefix("fd00::/64")}, p.AllowedIPs)
+}
+
+func TestValidate(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	a, _, err := NewPeer("a", netip.MustParsePrefix("10.0.0.2/32"))
+	require.NoError(t, err)
+	b, _, err := NewPeer("b", netip.MustParsePrefix("10.0.0.0/24"))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name  string
+		iface Interface
+		err   error
+	}{
+		{"no key", Interface{Name: "wg0"}, ErrMissingKey},
+		{"port", Interface{Name: "wg0", PrivateKey: key, ListenPort: 70000}, ErrInvalidNumber},
+		{"peer key", Interface{Name: "wg0", PrivateKey: key, Peers: []*Peer{{}}}, ErrMissingKey},
+		{"duplicate", Interface{Name: "wg0", PrivateKey: key, Peers: []*Peer{a, a}}, ErrDuplicatePeer},
+		{"overlap", Interface{Name: "wg0", PrivateKey: key, Peers: []*Peer{a, b}}, ErrOverlappingIPs},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.iface.Validate(), tc.err)
+		})
+	}
+}
+
+func TestPutInterface(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "network"), []byte(`
+config interface 'wg0'
+	option proto 'wireguard'
+	option private_key 'yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk='
+	option listen_port '51820'
+
+config wireguard_wg0
+	option description 'old'
+	option public_key 'xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg='
+	list allowed_ips '10.14.0.2/32'
+
+config wireguard_wg0
+	option description 'phone'
+	option public_key 'TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0='
+	list allowed_ips '10.14.0.3/32'
+	option custom 'kept'
+`), 0o644))
+	tree := uci.NewTree(dir)
+
+	wg, err := GetInterface(tree, "wg0")
+	require.NoError(err)
+	require.Len(wg.Peers, 2)
+	laptop, laptopKey, err := NewPeer("laptop", netip.MustParsePrefix("10.14.0.4/32"))
+	require.NoError(err)
+	laptop.Endpoint = Endpoint{Host: "laptop.example.com", Port: 51820}
+	laptop.PersistentKeepalive = 25
+	wg.Peers[1].Description = "new phone"
+	wg.Peers = append(wg.Peers[1:], laptop)
+	wg.MTU = 1420
+	require.NoError(PutInterface(tree, wg))
+
+	wg, err = GetInterface(tree, "wg0")
+	require.NoError(err)
+	assert.Equal(1420, wg.MTU)
+	assert.Equal(51820, wg.ListenPort)
+	require.Len(wg.Peers, 2)
+	assert.Equal("new phone", wg.Peers[0].Description)
+	assert.Equal(laptopKey.PublicKey(), wg.Peers[1].PublicKey)
+	assert.Equal(Endpoint{"laptop.example.com", 51820}, wg.Peers[1].Endpoint)
+	custom, _ := tree.GetLast("network", "@wireguard_wg0[0]", "custom")
+	assert.Equal("kept", custom)
+
+	other, _, err := NewPeer("other", netip.MustParsePrefix("10.14.0.0/28"))
+	require.NoError(err)
+	assert.ErrorIs(AddPeer(tree, "wg0", other), ErrOverlappingIPs)
+	other.AllowedIPs = []netip.Prefix{netip.MustParsePrefix("10.14.0.16/28")}
+	require.NoError(AddPeer(tree, "wg0", other))
+
+	// new interfaces
+	wg1, err := NewInterface("wg1", 51821, netip.MustParsePrefix("10.15.0.1/24"))
+	require.NoError(err)
+	require.NoError(PutInterface(tree, wg1))
+	sections := wg1.Sections()
+	assert.Len(sections, 1)
+	proto, _ := tree.GetLast("network", "wg1", "proto")
+	assert.Equal(Proto, proto)
+
+	_, err = GetInterface(tree, "missing")
+	assert.ErrorAs(err, new(uci.ErrSectionNotFound))
+	_, err = Interfaces(uci.NewTree(t.TempDir()))
+	assert.ErrorIs(err, ErrNoConfig)
+}
+
+func TestPutInterfaceConcurrent(t *testing.T) {
+	tree := uci.NewStoreTree(uci.NewMemoryStore(nil))
+	wg0, err := NewInterface("wg0", 51820, netip.MustParsePrefix("10.14.0.1/24"))
+	require.NoError(t, err)
+	require.NoError(t, PutInterface(tree, wg0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		p, _, err := NewPeer(fmt.Sprintf("peer%d", i), netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 14, 0, byte(i + 2)}), 32))
+		require.NoError(t, err)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, AddPeer(tree, "wg0", p))
+		}()
+		go func() {
+			defer wg.Done()
+			proto, _ := tree.GetLast("network", "wg0", "proto")
+			assert.Equal(t, Proto, proto)
+		}()
+	}
+	wg.Wait()
+	iface, err := GetInterface(tree, "wg0")
+	require.NoError(t, err)
+	assert.Len(t, iface.Peers, 10)
+
+	assert.ErrorIs(t, PutInterface(uci.NewTree(t.TempDir(), uci.WithReadOnly()), wg0), uci.ErrReadOnly)
+}
@@ -0,0 +1,221 @@
+package wireless
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrInvalidBand    = errors.New("invalid band")
+	ErrInvalidChannel = errors.New("invalid channel for band")
+	ErrInvalidHTMode  = errors.New("invalid htmode for band")
+	ErrInvalidNumber  = errors.New("invalid number")
+)
+
+// Frequency bands.
+const (
+	Band2G  = "2g"
+	Band5G  = "5g"
+	Band6G  = "6g"
+	Band60G = "60g"
+)
+
+// Device is a radio ("config wifi-device").
+type Device struct {
+	Name        string
+	Type        string // driver, e.g. "mac80211"
+	Path        string
+	Band        string
+	Channel     int    // 0 means "auto"
+	HTMode      string // e.g. "HT20", "VHT80", "HE80"
+	Country     string
+	TxPower     int // dBm, 0 means unset
+	CellDensity int
+	Disabled    bool
+}
+
+// deviceOptions lists the options managed by Device.
+var deviceOptions = []string{
+	"type", "path", "band", "hwmode", "channel", "htmode", "country", "txpower", "cell_density", "disabled",
+}
+
+// ParseDevice converts a wifi-device section.
+func ParseDevice(sec *uci.Section) (*Device, error) {
+	dev := &Device{
+		Name:     sec.Name,
+		Type:     sec.LastValue("type"),
+		Path:     sec.LastValue("path"),
+		Band:     sec.LastValue("band"),
+		HTMode:   sec.LastValue("htmode"),
+		Country:  sec.LastValue("country"),
+		Disabled: parseBool(sec.LastValue("disabled")),
+	}
+	if dev.Band == "" {
+		dev.Band = legacyBand(sec.LastValue("hwmode"))
+	}
+
+	var err error
+	if ch := sec.LastValue("channel"); ch != "auto" {
+		if dev.Channel, err = parseInt(ch); err != nil {
+			return nil, fmt.Errorf("wifi-device %s: channel: %w", dev.Name, err)
+		}
+	}
+	if dev.TxPower, err = parseInt(sec.LastValue("txpower")); err != nil {
+		return nil, fmt.Errorf("wifi-device %s: txpower: %w", dev.Name, err)
+	}
+	if dev.CellDensity, err = parseInt(sec.LastValue("cell_density")); err != nil {
+		return nil, fmt.Errorf("wifi-device %s: cell_density: %w", dev.Name, err)
+	}
+	return dev, nil
+}
+
+// legacyBand converts the hwmode option of OpenWrt releases before
+// 21.02.
+func legacyBand(hwmode string) string {
+	switch hwmode {
+	case "11b", "11g":
+		return Band2G
+	case "11a":
+		return Band5G
+	case "11ad":
+		return Band60G
+	}
+	return ""
+}
+
+// Validate checks band, channel and htmode, and whether they fit
+// together.
+func (dev *Device) Validate() error {
+	wrap := func(err error) error {
+		return fmt.Errorf("wifi-device %s: %w", dev.Name, err)
+	}
+
+	switch dev.Band {
+	case Band2G, Band5G, Band6G, Band60G:
+	default:
+		return wrap(fmt.Errorf("%w %q", ErrInvalidBand, dev.Band))
+	}
+	if dev.Channel != 0 && !validChannel(dev.Band, dev.Channel) {
+		return wrap(fmt.Errorf("%w %s: %d", ErrInvalidChannel, dev.Band, dev.Channel))
+	}
+	if dev.HTMode != "" && !validHTMode(dev.Band, dev.HTMode) {
+		return wrap(fmt.Errorf("%w %s: %s", ErrInvalidHTMode, dev.Band, dev.HTMode))
+	}
+	return nil
+}
+
+func validChannel(band string, ch int) bool {
+	switch band {
+	case Band2G:
+		return ch >= 1 && ch <= 14
+	case Band5G:
+		switch {
+		case ch >= 36 && ch <= 64, ch >= 100 && ch <= 144:
+			return ch%4 == 0
+		case ch >= 149 && ch <= 177:
+			return ch%4 == 1
+		}
+		return false
+	case Band6G:
+		return ch >= 1 && ch <= 233 && ch%4 == 1
+	case Band60G:
+		return ch >= 1 && ch <= 6
+	}
+	return false
+}
+
+// validHTMode reports whether the htmode can be used in band: VHT is a
+// 5 GHz only standard, 6 GHz requires HE (802.11ax) or newer, and 160
+// and 320 MHz channels don't exist in the lower bands.
+func validHTMode(band, mode string) bool {
+	if mode == "NOHT" {
+		return band == Band2G || band == Band5G
+	}
+
+	var family string
+	for _, f := range []string{"EHT", "VHT", "HE", "HT"} {
+		if strings.HasPrefix(mode, f) {
+			family = f
+			break
+		}
+	}
+	width := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(mode, family), "+"), "-")
+	switch width {
+	case "20", "40", "80", "160", "320":
+	default:
+		return false
+	}
+
+	switch family {
+	case "HT":
+		return (band == Band2G || band == Band5G) && (width == "20" || width == "40")
+	case "VHT":
+		return band == Band5G && width != "320"
+	case "HE":
+		return band != Band60G && width != "320" && (band != Band2G || width == "20" || width == "40")
+	case "EHT":
+		switch band {
+		case Band2G:
+			return width == "20" || width == "40"
+		case Band5G:
+			return width != "320"
+		case Band6G:
+			return true
+		}
+	}
+	return false
+}
+
+// Section renders dev into a wifi-device section.
+func (dev *Device) Section() *uci.Section {
+	s := uci.NewSection("wifi-device", dev.Name)
+	set := func(name, value string) {
+		if value != "" {
+			s.Add(uci.NewOption(name, uci.TypeOption, value))
+		}
+	}
+
+	set("type", dev.Type)
+	set("path", dev.Path)
+	set("band", dev.Band)
+	if dev.Channel == 0 {
+		set("channel", "auto")
+	} else {
+		set("channel", strconv.Itoa(dev.Channel))
+	}
+	set("htmode", dev.HTMode)
+	set("country", dev.Country)
+	if dev.TxPower > 0 {
+		set("txpower", strconv.Itoa(dev.TxPower))
+	}
+	if dev.CellDensity > 0 {
+		set("cell_density", strconv.Itoa(dev.CellDensity))
+	}
+	if dev.Disabled {
+		set("disabled", "1")
+	}
+	return s
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+	}
+	return n, nil
+}
+
+func parseBool(v string) bool {
+	switch v {
+	case "1", "on", "true", "yes", "enabled":
+		return true
+	}
+	return false
+}
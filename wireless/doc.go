@@ -0,0 +1,17 @@
+// Package wireless provides typed access to the wireless config: radios
+// ("config wifi-device") and wireless networks ("config wifi-iface").
+//
+// Both types validate their settings before they are written, catching
+// combinations which would silently keep a radio or network down, like
+// VHT modes on 2.4 GHz, or WPA3-SAE on an ad-hoc network:
+//
+//	err := wireless.PutInterface(tree, &wireless.Interface{
+//		Name:       "default_radio0",
+//		Device:     "radio0",
+//		Mode:       wireless.ModeAP,
+//		Network:    []string{"lan"},
+//		SSID:       "OpenWrt",
+//		Encryption: "sae-mixed",
+//		Key:        "secret passphrase",
+//	})
+package wireless
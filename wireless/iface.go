@@ -0,0 +1,272 @@
+package wireless
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+var (
+	ErrInvalidMode       = errors.New("invalid mode")
+	ErrInvalidEncryption = errors.New("invalid encryption")
+	ErrInvalidKey        = errors.New("invalid key")
+	ErrInvalidSSID       = errors.New("SSID must have 1 to 32 bytes")
+	ErrIncompatible      = errors.New("incompatible settings")
+	ErrUnknownDevice     = errors.New("unknown wifi-device")
+	ErrInvalidMAC        = uci.ErrInvalidMAC
+)
+
+// Modes of wireless networks.
+const (
+	ModeAP      = "ap"
+	ModeSTA     = "sta"
+	ModeAdHoc   = "adhoc"
+	ModeMesh    = "mesh"
+	ModeMonitor = "monitor"
+)
+
+// Management frame protection settings ("ieee80211w").
+const (
+	MFPDisabled = "0"
+	MFPOptional = "1"
+	MFPRequired = "2"
+)
+
+// Interface is a wireless network ("config wifi-iface").
+type Interface struct {
+	Name       string // section name, may be empty
+	Device     string // name of the wifi-device
+	Mode       string
+	Network    []string // logical interfaces
+	SSID       string
+	MeshID     string
+	Encryption string // e.g. "none", "psk2", "sae-mixed", "wpa2+ccmp"
+	Key        string
+	AuthServer string // RADIUS server for WPA enterprise
+	IEEE80211w string // management frame protection, "" means default
+	MACAddr    string // overrides the MAC address of the interface
+	MACFilter  string // "", "disable", "allow" or "deny"
+	MACList    []string
+	Hidden     bool
+	Isolate    bool
+	Disabled   bool
+}
+
+// ifaceOptions lists the options managed by Interface.
+var ifaceOptions = []string{
+	"device", "mode", "network", "ssid", "mesh_id", "encryption", "key", "auth_server",
+	"ieee80211w", "macaddr", "macfilter", "maclist", "hidden", "isolate", "disabled",
+}
+
+// ParseInterface converts a wifi-iface section.
+func ParseInterface(sec *uci.Section) *Interface {
+	return &Interface{
+		Name:       sec.Name,
+		Device:     sec.LastValue("device"),
+		Mode:       sec.LastValue("mode"),
+		Network:    sec.Fields("network"),
+		SSID:       sec.LastValue("ssid"),
+		MeshID:     sec.LastValue("mesh_id"),
+		Encryption: sec.LastValue("encryption"),
+		Key:        sec.LastValue("key"),
+		AuthServer: sec.LastValue("auth_server"),
+		IEEE80211w: sec.LastValue("ieee80211w"),
+		MACAddr:    sec.LastValue("macaddr"),
+		MACFilter:  sec.LastValue("macfilter"),
+		MACList:    sec.Fields("maclist"),
+		Hidden:     parseBool(sec.LastValue("hidden")),
+		Isolate:    parseBool(sec.LastValue("isolate")),
+		Disabled:   parseBool(sec.LastValue("disabled")),
+	}
+}
+
+// Validate checks the settings of iface. If dev is not nil, it also
+// checks whether they are compatible with the radio (e.g. 6 GHz
+// networks must use WPA3 or OWE).
+func (iface *Interface) Validate(dev *Device) error { //nolint:cyclop
+	wrap := func(err error) error {
+		if iface.Name == "" {
+			return fmt.Errorf("wifi-iface on %s: %w", iface.Device, err)
+		}
+		return fmt.Errorf("wifi-iface %s: %w", iface.Name, err)
+	}
+
+	switch iface.Mode {
+	case ModeAP, ModeSTA, ModeAdHoc:
+		if len(iface.SSID) < 1 || len(iface.SSID) > 32 {
+			return wrap(ErrInvalidSSID)
+		}
+	case ModeMesh, ModeMonitor:
+	default:
+		return wrap(fmt.Errorf("%w %q", ErrInvalidMode, iface.Mode))
+	}
+	switch iface.IEEE80211w {
+	case "", MFPDisabled, MFPOptional, MFPRequired:
+	default:
+		return wrap(fmt.Errorf("%w: ieee80211w %q", ErrIncompatible, iface.IEEE80211w))
+	}
+	if err := iface.validateMACs(); err != nil {
+		return wrap(err)
+	}
+
+	enc, cipher := splitEncryption(iface.Encryption)
+	if !validCiphers(cipher) {
+		return wrap(fmt.Errorf("%w %q", ErrInvalidEncryption, iface.Encryption))
+	}
+	switch enc {
+	case "", "none", "owe":
+		if enc == "owe" && iface.IEEE80211w == MFPDisabled {
+			return wrap(fmt.Errorf("%w: owe requires ieee80211w", ErrIncompatible))
+		}
+	case "psk", "psk2", "psk-mixed":
+		if err := validPSK(iface.Key); err != nil {
+			return wrap(err)
+		}
+	case "sae", "sae-mixed":
+		if iface.Key == "" {
+			return wrap(fmt.Errorf("%w: empty", ErrInvalidKey))
+		}
+		if enc == "sae-mixed" {
+			if err := validPSK(iface.Key); err != nil {
+				return wrap(err)
+			}
+		}
+		// SAE needs a WPA3 capable mode, and mandates management
+		// frame protection
+		if iface.Mode != ModeAP && iface.Mode != ModeSTA && iface.Mode != ModeMesh {
+			return wrap(fmt.Errorf("%w: %s in mode %s", ErrIncompatible, enc, iface.Mode))
+		}
+		if iface.IEEE80211w == MFPDisabled {
+			return wrap(fmt.Errorf("%w: %s requires ieee80211w", ErrIncompatible, enc))
+		}
+		if enc == "sae-mixed" && iface.IEEE80211w == MFPRequired {
+			return wrap(fmt.Errorf("%w: sae-mixed with ieee80211w=2 locks out WPA2 clients", ErrIncompatible))
+		}
+	case "wpa", "wpa2", "wpa3", "wpa3-mixed":
+		if iface.Mode == ModeAP && iface.AuthServer == "" {
+			return wrap(fmt.Errorf("%w: %s requires auth_server", ErrIncompatible, enc))
+		}
+	default:
+		return wrap(fmt.Errorf("%w %q", ErrInvalidEncryption, iface.Encryption))
+	}
+
+	if iface.Mode == ModeMesh && enc != "" && enc != "none" && enc != "sae" {
+		return wrap(fmt.Errorf("%w: %s in mode mesh", ErrIncompatible, enc))
+	}
+
+	if dev != nil && dev.Band == Band6G {
+		switch enc {
+		case "sae", "owe", "wpa3":
+		default:
+			return wrap(fmt.Errorf("%w: 6 GHz requires sae, owe or wpa3, got %q", ErrIncompatible, iface.Encryption))
+		}
+	}
+	return nil
+}
+
+// validateMACs checks the MAC address and the MAC filter.
+func (iface *Interface) validateMACs() error {
+	if iface.MACAddr != "" {
+		if _, err := uci.ParseMAC(iface.MACAddr); err != nil {
+			return err
+		}
+	}
+	for _, mac := range iface.MACList {
+		if _, err := uci.ParseMAC(mac); err != nil {
+			return fmt.Errorf("maclist: %w", err)
+		}
+	}
+	switch iface.MACFilter {
+	case "", "disable", "deny":
+	case "allow":
+		if len(iface.MACList) == 0 {
+			return fmt.Errorf("%w: macfilter allow without maclist locks out all clients", ErrIncompatible)
+		}
+	default:
+		return fmt.Errorf("%w: macfilter %q", ErrIncompatible, iface.MACFilter)
+	}
+	return nil
+}
+
+// normalizeMAC returns the canonical notation of mac, or mac itself,
+// if it is invalid (which Validate reports).
+func normalizeMAC(mac string) string {
+	if s, err := uci.NormalizeMAC(mac); err == nil {
+		return s
+	}
+	return mac
+}
+
+// splitEncryption splits e.g. "psk2+ccmp" into "psk2" and "ccmp".
+func splitEncryption(enc string) (string, string) {
+	if i := strings.IndexByte(enc, '+'); i >= 0 {
+		return enc[:i], enc[i+1:]
+	}
+	return enc, ""
+}
+
+func validCiphers(cipher string) bool {
+	if cipher == "" {
+		return true
+	}
+	for _, c := range strings.Split(cipher, "+") {
+		switch c {
+		case "ccmp", "tkip", "aes", "ccmp256", "gcmp", "gcmp256":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validPSK checks a WPA passphrase (8 to 63 characters), or a raw key
+// (64 hex digits).
+func validPSK(key string) error {
+	if len(key) >= 8 && len(key) <= 63 {
+		return nil
+	}
+	if len(key) == 64 && strings.Trim(strings.ToLower(key), "0123456789abcdef") == "" {
+		return nil
+	}
+	return fmt.Errorf("%w: must have 8 to 63 characters, or 64 hex digits", ErrInvalidKey)
+}
+
+// Section renders iface into a wifi-iface section.
+func (iface *Interface) Section() *uci.Section {
+	s := uci.NewSection("wifi-iface", iface.Name)
+	set := func(name, value string) {
+		if value != "" {
+			s.Add(uci.NewOption(name, uci.TypeOption, value))
+		}
+	}
+	flag := func(name string, value bool) {
+		if value {
+			set(name, "1")
+		}
+	}
+
+	set("device", iface.Device)
+	set("mode", iface.Mode)
+	set("network", strings.Join(iface.Network, " "))
+	set("ssid", iface.SSID)
+	set("mesh_id", iface.MeshID)
+	set("encryption", iface.Encryption)
+	set("key", iface.Key)
+	set("auth_server", iface.AuthServer)
+	set("ieee80211w", iface.IEEE80211w)
+	set("macaddr", normalizeMAC(iface.MACAddr))
+	set("macfilter", iface.MACFilter)
+	if len(iface.MACList) > 0 {
+		macs := make([]string, len(iface.MACList))
+		for i, mac := range iface.MACList {
+			macs[i] = normalizeMAC(mac)
+		}
+		s.Add(uci.NewOption("maclist", uci.TypeList, macs...))
+	}
+	flag("hidden", iface.Hidden)
+	flag("isolate", iface.Isolate)
+	flag("disabled", iface.Disabled)
+	return s
+}
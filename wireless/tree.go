@@ -0,0 +1,80 @@
+package wireless
+
+import (
+	"fmt"
+
+	uci "github.com/wsiner/go-uci"
+)
+
+// Devices returns all radios of the wireless config (none, if there is
+// no wireless config).
+func Devices(t uci.Tree) ([]*Device, error) {
+	cfg, ok := t.EnsureConfigLoaded("wireless")
+	if !ok {
+		return nil, nil
+	}
+	var devs []*Device
+	for _, sec := range cfg.Sections {
+		if sec.Type != "wifi-device" {
+			continue
+		}
+		dev, err := ParseDevice(sec)
+		if err != nil {
+			return nil, err
+		}
+		devs = append(devs, dev)
+	}
+	return devs, nil
+}
+
+// Interfaces returns all wireless networks of the wireless config.
+func Interfaces(t uci.Tree) []*Interface {
+	cfg, ok := t.EnsureConfigLoaded("wireless")
+	if !ok {
+		return nil
+	}
+	var ifaces []*Interface
+	for _, sec := range cfg.Sections {
+		if sec.Type == "wifi-iface" {
+			ifaces = append(ifaces, ParseInterface(sec))
+		}
+	}
+	return ifaces
+}
+
+// PutDevice validates dev, and stores it in the wireless config. Options
+// not represented by Device are kept.
+func PutDevice(t uci.Tree, dev *Device) error {
+	if err := dev.Validate(); err != nil {
+		return err
+	}
+	return t.Batch(func(tx *uci.Tx) error {
+		_, err := tx.PutSection("wireless", dev.Section(), deviceOptions...)
+		return err
+	})
+}
+
+// PutInterface validates iface against its radio, and stores it in the
+// wireless config. Named interfaces are created or updated (keeping
+// options not represented by Interface), unnamed ones are appended.
+func PutInterface(t uci.Tree, iface *Interface) error {
+	return t.Batch(func(tx *uci.Tx) error {
+		cfg, ok := tx.CopyConfig("wireless")
+		var sec *uci.Section
+		if ok {
+			sec = cfg.Get(iface.Device)
+		}
+		if sec == nil || sec.Type != "wifi-device" {
+			return fmt.Errorf("wifi-iface %s: %w %q", iface.Name, ErrUnknownDevice, iface.Device)
+		}
+		dev, err := ParseDevice(sec)
+		if err != nil {
+			return err
+		}
+		if err := iface.Validate(dev); err != nil {
+			return err
+		}
+		_, err = tx.PutSection("wireless", iface.Section(), ifaceOptions...)
+		return err
+	})
+}
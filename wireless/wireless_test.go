@@ -0,0 +1,155 @@
+package wireless
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uci "github.com/wsiner/go-uci"
+)
+
+func TestDeviceValidate(t *testing.T) {
+	tt := []struct {
+		band    string
+		channel int
+		htmode  string
+		err     error
+	}{
+		{Band2G, 0, "HT20", nil},
+		{Band2G, 11, "HE40", nil},
+		{Band5G, 36, "VHT80", nil},
+		{Band5G, 149, "HE160", nil},
+		{Band6G, 37, "EHT320", nil},
+		{"", 1, "", ErrInvalidBand},
+		{"5ghz", 1, "", ErrInvalidBand},
+		{Band2G, 36, "", ErrInvalidChannel},
+		{Band5G, 38, "", ErrInvalidChannel},
+		{Band6G, 36, "", ErrInvalidChannel},
+		{Band2G, 6, "VHT20", ErrInvalidHTMode},
+		{Band2G, 6, "HE80", ErrInvalidHTMode},
+		{Band6G, 1, "HT40", ErrInvalidHTMode},
+		{Band5G, 36, "VHT320", ErrInvalidHTMode},
+		{Band5G, 36, "HT80", ErrInvalidHTMode},
+		{Band5G, 36, "FOO20", ErrInvalidHTMode},
+	}
+	for _, tc := range tt {
+		dev := &Device{Name: "radio0", Band: tc.band, Channel: tc.channel, HTMode: tc.htmode}
+		err := dev.Validate()
+		if tc.err == nil {
+			assert.NoError(t, err, "%+v", tc)
+		} else {
+			assert.True(t, errors.Is(err, tc.err), "%+v: got %v", tc, err)
+		}
+	}
+}
+
+func TestInterfaceValidate(t *testing.T) {
+	radio2g := &Device{Name: "radio0", Band: Band2G}
+	radio6g := &Device{Name: "radio1", Band: Band6G}
+
+	tt := []struct {
+		name  string
+		iface Interface
+		dev   *Device
+		err   error
+	}{
+		{"open", Interface{Mode: ModeAP, SSID: "x", Encryption: "none"}, radio2g, nil},
+		{"psk2", Interface{Mode: ModeAP, SSID: "x", Encryption: "psk2+ccmp", Key: "12345678"}, radio2g, nil},
+		{"sae-mixed", Interface{Mode: ModeAP, SSID: "x", Encryption: "sae-mixed", Key: "12345678"}, radio2g, nil},
+		{"sae mesh", Interface{Mode: ModeMesh, MeshID: "m", Encryption: "sae", Key: "k"}, radio2g, nil},
+		{"sae 6g", Interface{Mode: ModeAP, SSID: "x", Encryption: "sae", Key: "k"}, radio6g, nil},
+		{"wpa2 enterprise", Interface{Mode: ModeAP, SSID: "x", Encryption: "wpa2", AuthServer: "10.0.0.1"}, nil, nil},
+		{"mac filter", Interface{Mode: ModeAP, SSID: "x", MACAddr: "02-00-00-00-00-01", MACFilter: "allow", MACList: []string{"001122334455"}}, nil, nil},
+		{"raw psk", Interface{Mode: ModeSTA, SSID: "x", Encryption: "psk2", Key: "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"}, nil, nil},
+
+		{"bad mode", Interface{Mode: "master", SSID: "x"}, nil, ErrInvalidMode},
+		{"no ssid", Interface{Mode: ModeAP}, nil, ErrInvalidSSID},
+		{"bad encryption", Interface{Mode: ModeAP, SSID: "x", Encryption: "wep"}, nil, ErrInvalidEncryption},
+		{"bad cipher", Interface{Mode: ModeAP, SSID: "x", Encryption: "psk2+rot13", Key: "12345678"}, nil, ErrInvalidEncryption},
+		{"short psk", Interface{Mode: ModeAP, SSID: "x", Encryption: "psk2", Key: "1234567"}, nil, ErrInvalidKey},
+		{"sae adhoc", Interface{Mode: ModeAdHoc, SSID: "x", Encryption: "sae", Key: "k"}, nil, ErrIncompatible},
+		{"sae without mfp", Interface{Mode: ModeAP, SSID: "x", Encryption: "sae", Key: "k", IEEE80211w: MFPDisabled}, nil, ErrIncompatible},
+		{"sae-mixed mfp required", Interface{Mode: ModeAP, SSID: "x", Encryption: "sae-mixed", Key: "12345678", IEEE80211w: MFPRequired}, nil, ErrIncompatible},
+		{"psk mesh", Interface{Mode: ModeMesh, Encryption: "psk2", Key: "12345678"}, nil, ErrIncompatible},
+		{"wpa2 without server", Interface{Mode: ModeAP, SSID: "x", Encryption: "wpa2"}, nil, ErrIncompatible},
+		{"psk2 6g", Interface{Mode: ModeAP, SSID: "x", Encryption: "psk2", Key: "12345678"}, radio6g, ErrIncompatible},
+		{"bad macaddr", Interface{Mode: ModeAP, SSID: "x", MACAddr: "00:11:22"}, nil, ErrInvalidMAC},
+		{"bad maclist", Interface{Mode: ModeAP, SSID: "x", MACFilter: "deny", MACList: []string{"00:11:22:33:44:55", "x"}}, nil, ErrInvalidMAC},
+		{"allow nobody", Interface{Mode: ModeAP, SSID: "x", MACFilter: "allow"}, nil, ErrIncompatible},
+		{"bad macfilter", Interface{Mode: ModeAP, SSID: "x", MACFilter: "block"}, nil, ErrIncompatible},
+	}
+	for i := range tt {
+		tc := tt[i]
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.iface.Validate(tc.dev)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.err), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	ifaces := Interfaces(uci.NewTree("../testdata"))
+	require.Len(t, ifaces, 2)
+	assert.Equal(t, &Interface{
+		Name:    "guest_radio0",
+		Device:  "radio0",
+		Mode:    ModeAP,
+		Network: []string{"guest"},
+		SSID:    "OpenWrt",
+		Isolate: true,
+	}, ifaces[0])
+	assert.True(t, ifaces[1].Disabled)
+}
+
+func TestPut(t *testing.T) {
+	assert := assert.New(t)
+	tree := uci.NewTree(t.TempDir())
+
+	iface := &Interface{Device: "radio0", Mode: ModeAP, SSID: "x", Encryption: "sae", Key: "k"}
+	assert.True(errors.Is(PutInterface(tree, iface), ErrUnknownDevice))
+
+	radio := &Device{Name: "radio0", Type: "mac80211", Band: Band6G, Channel: 37, HTMode: "HE80"}
+	assert.NoError(PutDevice(tree, radio))
+	assert.NoError(PutInterface(tree, iface))
+
+	iface.Encryption = "psk2"
+	iface.Key = "12345678"
+	assert.True(errors.Is(PutInterface(tree, iface), ErrIncompatible))
+
+	devs, err := Devices(tree)
+	assert.NoError(err)
+	assert.Equal([]*Device{radio}, devs)
+	assert.Len(Interfaces(tree), 1)
+
+	radio.Channel = 0
+	assert.NoError(PutDevice(tree, radio))
+	values, _ := tree.Get("wireless", "radio0", "channel")
+	assert.Equal([]string{"auto"}, values)
+	assert.NoError(tree.Commit())
+
+	// changes run the tree's hooks
+	errDenied := errors.New("denied")
+	tree.OnSet(func(e *uci.SetEvent) error {
+		if e.Option == "channel" {
+			return errDenied
+		}
+		return nil
+	})
+	radio.Channel = 33
+	assert.ErrorIs(PutDevice(tree, radio), errDenied)
+	values, _ = tree.Get("wireless", "radio0", "channel")
+	assert.Equal([]string{"auto"}, values)
+}
+
+func TestInterfaceMACs(t *testing.T) {
+	iface := &Interface{Mode: ModeAP, SSID: "x", MACAddr: "02-00-00-00-00-01", MACFilter: "deny", MACList: []string{"0011.2233.4455", "AA:BB:CC:DD:EE:FF"}}
+	sec := iface.Section()
+	assert.Equal(t, "02:00:00:00:00:01", sec.LastValue("macaddr"))
+	assert.Equal(t, []string{"00:11:22:33:44:55", "aa:bb:cc:dd:ee:ff"}, sec.Value("maclist"))
+	assert.Equal(t, []string{"00:11:22:33:44:55", "aa:bb:cc:dd:ee:ff"}, ParseInterface(sec).MACList)
+}